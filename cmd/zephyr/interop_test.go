@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/installer/wheeltest"
+)
+
+// metadataCheck reports what importlib.metadata sees in the environment as JSON
+const metadataCheck = `
+import json
+import importlib.metadata as md
+
+def group(name):
+    eps = md.entry_points()
+    if hasattr(eps, "select"):
+        return list(eps.select(group=name))
+    return list(eps.get(name, []))
+
+cli = md.distribution("interop-cli")
+plugins = group("interop.plugins")
+print(json.dumps({
+    "versions": {d.metadata["Name"]: d.version for d in md.distributions() if d.metadata["Name"].lower().startswith("interop")},
+    "requires": cli.requires,
+    "summary": cli.metadata["Summary"],
+    "files": sorted(str(f) for f in cli.files if str(f).endswith(".py")),
+    "console_scripts": sorted(ep.name for ep in group("console_scripts") if ep.name.startswith("interop")),
+    "plugins": {ep.name: ep.load()() for ep in plugins},
+}))
+`
+
+// TestImportlibMetadataInterop installs wheels with zephyr and checks that
+// importlib.metadata, entry point discovery, console scripts and pip all see
+// them as if pip had installed them
+func TestImportlibMetadataInterop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the POSIX venv layout")
+	}
+	dir := t.TempDir()
+	bin := buildZephyrBinary(t)
+	cmd := exec.Command(bin, "venv", "create")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("zephyr venv create failed (skip if no Python): %v, out=%s", err, out)
+	}
+
+	wheels := t.TempDir()
+	lib := wheeltest.Wheel{Name: "interop_lib", Version: "0.5.0", Files: map[string]string{
+		"interop_lib/__init__.py": "def plugin():\n    return 'lib plugin'\n",
+	}}.Build(t, wheels)
+	cli := wheeltest.Wheel{
+		Name:     "Interop_CLI",
+		Version:  "1.2.0",
+		Metadata: "Summary: Interop test command\nRequires-Dist: interop-lib>=0.5\nRequires-Dist: rich; extra == \"color\"\nProvides-Extra: color\n",
+		Files: map[string]string{
+			"interop_cli/__init__.py":                      "def main():\n    print('interop-cli ok')\n",
+			"Interop_CLI-1.2.0.dist-info/entry_points.txt": "[console_scripts]\ninterop-cli = interop_cli:main\n\n[interop.plugins]\nlib = interop_lib:plugin\n",
+		},
+	}.Build(t, wheels)
+	cmd = exec.Command(bin, "install", lib, cli)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("zephyr install failed: %v, out=%s", err, out)
+	}
+	python := filepath.Join(dir, ".venv", "bin", "python")
+
+	out, err := exec.Command(python, "-c", metadataCheck).CombinedOutput()
+	if err != nil {
+		t.Fatalf("importlib.metadata check failed: %v, out=%s", err, out)
+	}
+	var seen struct {
+		Versions       map[string]string `json:"versions"`
+		Requires       []string          `json:"requires"`
+		Summary        string            `json:"summary"`
+		Files          []string          `json:"files"`
+		ConsoleScripts []string          `json:"console_scripts"`
+		Plugins        map[string]string `json:"plugins"`
+	}
+	if err := json.Unmarshal(out, &seen); err != nil {
+		t.Fatalf("unexpected output from importlib.metadata check: %v\n%s", err, out)
+	}
+	if want := map[string]string{"Interop_CLI": "1.2.0", "interop_lib": "0.5.0"}; !reflect.DeepEqual(seen.Versions, want) {
+		t.Errorf("distributions() saw %v, want %v", seen.Versions, want)
+	}
+	if want := []string{"interop-lib>=0.5", `rich; extra == "color"`}; !reflect.DeepEqual(seen.Requires, want) {
+		t.Errorf("requires saw %v, want %v", seen.Requires, want)
+	}
+	if seen.Summary != "Interop test command" {
+		t.Errorf("metadata saw summary %q", seen.Summary)
+	}
+	if !reflect.DeepEqual(seen.Files, []string{"interop_cli/__init__.py"}) {
+		t.Errorf("files() saw %v", seen.Files)
+	}
+	if !reflect.DeepEqual(seen.ConsoleScripts, []string{"interop-cli"}) {
+		t.Errorf("console_scripts saw %v", seen.ConsoleScripts)
+	}
+	if seen.Plugins["lib"] != "lib plugin" {
+		t.Errorf("custom entry point group saw %v", seen.Plugins)
+	}
+
+	out, err = exec.Command(filepath.Join(dir, ".venv", "bin", "interop-cli")).CombinedOutput()
+	if err != nil || strings.TrimSpace(string(out)) != "interop-cli ok" {
+		t.Errorf("console script failed: %v, out=%s", err, out)
+	}
+
+	out, err = exec.Command(python, "-m", "pip", "--version").CombinedOutput()
+	if err != nil {
+		t.Logf("pip not available in the environment, skipping pip list: %s", out)
+		return
+	}
+	cmd = exec.Command(python, "-m", "pip", "list", "--format=json", "--disable-pip-version-check")
+	cmd.Env = append(os.Environ(), "PIP_NO_INDEX=1")
+	out, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("pip list failed: %v", err)
+	}
+	var listed []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(out, &listed); err != nil {
+		t.Fatalf("unexpected pip list output: %v\n%s", err, out)
+	}
+	found := map[string]string{}
+	for _, pkg := range listed {
+		found[strings.ToLower(strings.ReplaceAll(pkg.Name, "_", "-"))] = pkg.Version
+	}
+	if found["interop-cli"] != "1.2.0" || found["interop-lib"] != "0.5.0" {
+		t.Errorf("pip list did not report the zephyr-installed packages: %s", out)
+	}
+	cmd = exec.Command(python, "-m", "pip", "check", "--disable-pip-version-check")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("pip check found problems: %v, out=%s", err, out)
+	}
+}
@@ -1,18 +1,65 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"net"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"rimraf-adi.com/zephyr/pkg/auditlog"
+	"rimraf-adi.com/zephyr/pkg/bugreport"
+	"rimraf-adi.com/zephyr/pkg/builder"
 	"rimraf-adi.com/zephyr/pkg/buildmeta"
+	"rimraf-adi.com/zephyr/pkg/cache"
+	"rimraf-adi.com/zephyr/pkg/credentials"
+	"rimraf-adi.com/zephyr/pkg/dirs"
+	"rimraf-adi.com/zephyr/pkg/environment"
+	"rimraf-adi.com/zephyr/pkg/flock"
+	"rimraf-adi.com/zephyr/pkg/fsutil"
+	"rimraf-adi.com/zephyr/pkg/hooks"
 	"rimraf-adi.com/zephyr/pkg/installer"
+	"rimraf-adi.com/zephyr/pkg/interpreters"
+	"rimraf-adi.com/zephyr/pkg/jsonrpc"
+	"rimraf-adi.com/zephyr/pkg/markers"
+	"rimraf-adi.com/zephyr/pkg/netutil"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+	"rimraf-adi.com/zephyr/pkg/publish"
 	"rimraf-adi.com/zephyr/pkg/pypi"
+	"rimraf-adi.com/zephyr/pkg/remedy"
 	"rimraf-adi.com/zephyr/pkg/solver"
+	"rimraf-adi.com/zephyr/pkg/sysreq"
+	"rimraf-adi.com/zephyr/pkg/tasks"
+	pkgversion "rimraf-adi.com/zephyr/pkg/version"
+	"rimraf-adi.com/zephyr/pkg/watch"
 )
 
+// version is the zephyr release, set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// debugLog records the current command for zephyr bug-report
+var debugLog *bugreport.Log
+
+// commandStarted is when the current command began, for --timings
+var commandStarted = time.Now()
+
+// resolveTime adds up the time spent in dependency resolution, for --timings
+var resolveTime time.Duration
+
 var rootCmd = &cobra.Command{
 	Use:   "zephyr",
 	Short: "Zephyr - A modern Python package manager",
@@ -25,6 +72,45 @@ Features:
 - Lockfile support
 - buildmeta.yaml configuration
 - PEP 517/518/621 compliance`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		enterProject(cmd)
+		if profileName == "" {
+			profileName = os.Getenv("ZEPHYR_PROFILE")
+		}
+		if err := netutil.SetProfile(profileName); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Invalid --profile: %v\n", err)
+			os.Exit(1)
+		}
+		if offlineMode || os.Getenv("ZEPHYR_OFFLINE") != "" {
+			netutil.SetOffline(true)
+		}
+		if limitRate != "" {
+			rate, err := netutil.ParseRate(limitRate)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Invalid --limit-rate: %v\n", err)
+				os.Exit(1)
+			}
+			netutil.SetRateLimit(rate)
+		}
+		if cmd.Flags().Changed("max-parallel-downloads") {
+			netutil.SetMaxParallelDownloads(maxParallelDownloads)
+		}
+		netutil.SetToolVersion(version)
+		netutil.SetCredentials(credentials.NewDefaultStore().Resolver())
+		pypi.SetMetadataStore(cache.NewDefaultMetadataStore())
+		// Keep the log of the command being reported on, and do not log
+		// every completion request the shell makes
+		if cmd == bugReportCmd || cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd {
+			return
+		}
+		log, err := bugreport.StartLog(bugreport.DefaultLogDir(), os.Args)
+		if err != nil {
+			return
+		}
+		debugLog = log
+		debugLog.Printf("zephyr %s (%s, %s/%s)", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		pypi.SetDebugLog(debugLog)
+	},
 }
 
 var initCmd = &cobra.Command{
@@ -48,9 +134,11 @@ var initCmd = &cobra.Command{
 		}
 		buildMeta := buildmeta.NewBuildMeta(projectName, "0.1.0")
 		buildMeta.Description = "A Python project created with Zephyr"
-		buildMeta.Author = "Your Name"
-		buildMeta.Email = "your.email@example.com"
-		buildMeta.License = "MIT"
+		if !noDetectFlag {
+			applyDetectedInfo(buildMeta)
+		}
+		// Placeholders for whatever could not be detected
+		buildMeta.ApplyProjectInfo(buildmeta.ProjectInfo{License: "MIT", Author: "Your Name", Email: "your.email@example.com"})
 		if err := buildmeta.WriteToDirectory(".", buildMeta); err != nil {
 			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not create buildmeta.yaml: %v\n", err)
 			os.Exit(1)
@@ -69,8 +157,8 @@ var initCmd = &cobra.Command{
 		fmt.Println("  zephyr install           # Install dependencies")
 		fmt.Println("  zephyr venv create       # Create virtual environment")
 		if pyprojectFlag {
-			pyproject := fmt.Sprintf(`[tool.poetry]\nname = "%s"\nversion = "0.1.0"\ndescription = "A Python project created with Zephyr"\nauthors = ["Your Name <your.email@example.com>"]\nreadme = "README.md"\n\n[tool.poetry.dependencies]\npython = "^3.11.4"\n\n[build-system]\nrequires = ["poetry-core>=1.0.0", "poetry>=1.0.0"]\nbuild-backend = "poetry.core.masonry.api"\n`, projectName)
-			if err := os.WriteFile("pyproject.toml", []byte(pyproject), 0644); err != nil {
+			pyproject := fmt.Sprintf(`[tool.poetry]\nname = "%s"\nversion = "0.1.0"\ndescription = "A Python project created with Zephyr"\nauthors = ["%s <%s>"]\nreadme = "README.md"\n\n[tool.poetry.dependencies]\npython = "^3.11.4"\n\n[build-system]\nrequires = ["poetry-core>=1.0.0", "poetry>=1.0.0"]\nbuild-backend = "poetry.core.masonry.api"\n`, projectName, buildMeta.Author, buildMeta.Email)
+			if err := fsutil.WriteFileAtomic("pyproject.toml", []byte(pyproject), 0644); err != nil {
 				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not create pyproject.toml: %v\n", err)
 				os.Exit(1)
 			}
@@ -83,6 +171,7 @@ var addCmd = &cobra.Command{
 	Use:   "add [package] [constraint]",
 	Short: "Add a dependency to the project",
 	Args:  cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeAddArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		packageName := args[0]
 		constraint := ""
@@ -96,6 +185,24 @@ var addCmd = &cobra.Command{
 			os.Exit(1)
 		}
 		buildMeta.AddDependency(packageName, constraint)
+		for _, source := range addConstraintSets {
+			if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+				source = filepath.ToSlash(invocationPath(source))
+			}
+			constraints, err := buildmeta.LoadConstraints(source, ".", netutil.NewHTTPClient(0))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load constraints from %s: %v\n", source, err)
+				os.Exit(1)
+			}
+			if buildMeta.AddConstraintSource(source) {
+				fmt.Printf("[zephyr] Resolving with %d constraints from %s\n", len(constraints), source)
+			}
+			for name, spec := range constraints {
+				if pkgname.Normalize(name) == pkgname.Normalize(packageName) {
+					fmt.Printf("[zephyr] %s is constrained to %s by %s\n", packageName, spec, source)
+				}
+			}
+		}
 		if err := buildmeta.WriteToDirectory(".", buildMeta); err != nil {
 			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not save buildmeta.yaml: %v\n", err)
 			os.Exit(1)
@@ -126,26 +233,100 @@ var removeCmd = &cobra.Command{
 
 var updateCmd = &cobra.Command{
 	Use:   "update",
-	Short: "Update all dependencies to the latest allowed by constraints",
+	Short: "Update dependencies within their update policies",
+	Long: `Update dependencies in buildmeta.yaml, starting from the versions in zephyr.lock.
+
+Each package moves as far as its update policy allows: "minor" (the default)
+takes semver-compatible releases, "patch" only bug-fix releases, "latest" any
+release, and "security" only the lowest release fixing a known advisory.
+Policies are set under update.policy and update.packages in buildmeta.yaml.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		buildMeta, err := buildmeta.ParseFromDirectory(".")
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
 			os.Exit(1)
 		}
+		locked := map[string]installer.LockPackage{}
+		if lockfile, err := installer.NewLockfileManager(".").Load(); err == nil {
+			locked = lockfile.Packages
+		}
 		client := pypi.NewPyPIClient()
+		deps := buildMeta.GetDependencies()
+		names := make([]string, 0, len(deps))
+		for name := range deps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
 		updated := false
-		for name, constraint := range buildMeta.GetDependencies() {
-			latest, err := client.GetLatestVersion(name)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not fetch latest version for %s: %v\n", name, err)
+		for _, name := range names {
+			constraint := deps[name]
+			current := constraintVersion(constraint)
+			if pkg, ok := locked[name]; ok {
+				current = pkg.Version
+			}
+			if buildMeta.IsHeld(name) {
+				fmt.Printf("⏸️  %s is held at %s\n", name, current)
+				warnHeldAdvisories(client, name, current)
+				continue
+			}
+			if constraint == "" {
 				continue
 			}
-			if constraint == "" || constraint == latest || strings.HasSuffix(constraint, latest) {
+			policyName := buildMeta.UpdatePolicy(name)
+			if updatePolicy != "" {
+				policyName = updatePolicy
+			}
+			policy, err := pypi.ParseUpdatePolicy(policyName)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Invalid update policy for %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			var target string
+			if updateSecurity || policy == pypi.PolicySecurity {
+				if current == "" {
+					fmt.Fprintf(os.Stderr, "[zephyr] Warning: Skipping %s: no locked version to check for advisories\n", name)
+					continue
+				}
+				metadata, err := client.FetchVersionMetadata(name, current)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not fetch advisories for %s: %v\n", name, err)
+					continue
+				}
+				vulns := pypi.ActiveVulnerabilities(metadata.Vulnerabilities)
+				if len(vulns) == 0 {
+					continue
+				}
+				ids := make([]string, 0, len(vulns))
+				for _, vuln := range vulns {
+					ids = append(ids, vuln.ID)
+				}
+				target = pypi.FixedVersion(current, vulns)
+				if target == "" {
+					fmt.Fprintf(os.Stderr, "[zephyr] Warning: %s %s is affected by %s and no fixed release is available\n", name, current, strings.Join(ids, ", "))
+					continue
+				}
+				fmt.Printf("🔒 %s %s is affected by %s, fixed in %s\n", name, current, strings.Join(ids, ", "), target)
+			} else {
+				metadata, err := client.FetchPackageMetadata(name)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not fetch latest version for %s: %v\n", name, err)
+					continue
+				}
+				history := pypi.VersionHistory(metadata)
+				target = pypi.SelectUpdate(history, current, policy)
+				if latest := pypi.SelectUpdate(history, current, pypi.PolicyLatest); latest != "" && latest != target {
+					fmt.Printf("⏸️  %s %s is available but held back by the %q policy\n", name, latest, policy)
+				}
+				if target == "" {
+					continue
+				}
+			}
+			newConstraint := rewriteConstraint(constraint, target)
+			if newConstraint == constraint {
 				continue
 			}
-			buildMeta.AddDependency(name, latest)
-			fmt.Printf("Updated %s to %s\n", name, latest)
+			buildMeta.AddDependency(name, newConstraint)
+			fmt.Printf("Updated %s %s -> %s\n", name, constraint, newConstraint)
 			updated = true
 		}
 		if updated {
@@ -161,38 +342,27 @@ var updateCmd = &cobra.Command{
 }
 
 var installCmd = &cobra.Command{
-	Use:   "install",
-	Short: "Install project dependencies",
+	Use:   "install [wheel-path-or-url...]",
+	Short: "Install project dependencies, or wheels given by path or URL",
+	Long: `Without arguments, resolve buildmeta.yaml and install the project dependencies.
+
+With wheel paths or http(s)/file URLs, install those wheels into .venv and pin
+them in zephyr.lock. Each wheel is checked before installing against --hash, a
+#sha256= URL fragment, and <wheel>.sha256 and <wheel>.asc sidecars next to it.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			installDirect(args)
+			return
+		}
 		fmt.Println("[zephyr] Resolving dependencies...")
 		buildMeta, err := buildmeta.ParseFromDirectory(".")
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
 			os.Exit(1)
 		}
-		s := solver.NewSolver(buildMeta.Name, buildMeta.Version)
-		for name, constraint := range buildMeta.GetDependencies() {
-			incompatibility := solver.Incompatibility{
-				Terms: []solver.Term{
-					{
-						Package: buildMeta.Name,
-						Version: solver.VersionConstraint{Specific: buildMeta.Version},
-						Negated: false,
-					},
-					{
-						Package: name,
-						Version: parseVersionConstraint(constraint),
-						Negated: true,
-					},
-				},
-			}
-			s.AddIncompatibility(incompatibility)
-		}
-		solution, err := s.Solve()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "[zephyr] Dependency resolution failed: %v\n", err)
-			os.Exit(1)
-		}
+		warnSystemRequirements(buildMeta)
+		s := projectSolver(buildMeta)
+		solution := solve(s)
 		fmt.Println("[zephyr] Installing dependencies...")
 		venv := installer.NewVirtualEnvironment(".venv")
 		if !venv.Exists() {
@@ -200,412 +370,4424 @@ var installCmd = &cobra.Command{
 			fmt.Fprintln(os.Stderr, "Create it first with: zephyr venv create")
 			os.Exit(1)
 		}
-		for name := range buildMeta.GetDependencies() {
+		defer lockVenv(".venv").Release()
+		warnVenvPython(".venv")
+		wheelInstaller := newWheelInstaller(".venv")
+		for requirement := range buildMeta.GetDependencies() {
+			name, _, _ := solver.SplitExtraPackage(solver.ExpandExtras(requirement)[0])
 			assign := solution.GetAssignmentByPackage(name)
 			if assign != nil {
 				ver := assign.Term.Version.String()
 				fmt.Printf("[zephyr] Installing %s %s...\n", name, ver)
-				wheelInstaller := installer.NewWheelInstaller(".venv")
 				if err := wheelInstaller.InstallWheelFromPyPI(name, ver); err != nil {
+					recordInstalls("install", ".venv", wheelInstaller, err)
 					fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not install %s: %v\n", name, err)
+					printRemedy(err)
 					os.Exit(1)
 				}
 			}
 		}
+		recordInstalls("install", ".venv", wheelInstaller, nil)
+		if checkSharedLibs {
+			warnMissingSharedLibraries(".venv", wheelInstaller)
+		}
 		lockManager := installer.NewLockfileManager(".")
-		if err := lockManager.Update("buildmeta.yaml", solution, "3.11"); err != nil {
+		if err := lockManager.Update(buildmeta.ManifestName(), solution, projectPythonMinor()); err != nil {
 			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not create lockfile: %v\n", err)
 			os.Exit(1)
 		}
+		pinLockfileHashes(lockManager, wheelInstaller)
 		fmt.Println("\n[zephyr] ✅ All dependencies installed and lockfile updated!")
+		if showTimings {
+			printTimings(wheelInstaller)
+		}
 	},
 }
 
 var syncCmd = &cobra.Command{
-	Use:   "sync",
+	Use:   "sync [package...]",
 	Short: "Install dependencies from lockfile (no resolution)",
+	Long: `Install the packages pinned in zephyr.lock into .venv without resolving.
+
+Name packages to install or repair only them and the locked packages they
+depend on, leaving the rest of .venv alone: zephyr sync requests
+
+With --verify-only, change nothing: check that .venv holds exactly the locked
+packages at their locked versions and that every installed file still matches
+the hash in its RECORD, without touching the network. Exits non-zero on any
+difference, for immutable production hosts.
+
+With --prune, uninstall every package in .venv that zephyr.lock does not
+lock, leaving the environment exactly as declared. The seed packages pip,
+setuptools and wheel, the dev-dependencies in buildmeta.yaml and packages
+named with --keep, and whatever those require, are kept.
+
+With --smoke-test, import every top-level module of the installed packages
+after installing, each in its own interpreter, and exit non-zero if any fail.
+This catches platform wheels that install but cannot load.
+
+With --prefix, install into that prefix's bin and lib/pythonX.Y/site-packages
+instead of .venv, for building OS packages (deb, rpm) from the locked
+dependencies. --root stages the files under a DESTDIR while scripts still
+point at the interpreter's installed path; --python names that interpreter
+(default: the project's Python found on PATH).
+  zephyr sync --prefix /usr --root debian/tmp --python /usr/bin/python3`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("[zephyr] Installing dependencies from lockfile...")
 		venvPath := ".venv"
+		if syncPrefix != "" {
+			syncIntoPrefix(args)
+			return
+		}
+		if syncRoot != "" || syncPython != "" {
+			fmt.Fprintln(os.Stderr, "[zephyr] Error: --root and --python apply to --prefix installs")
+			os.Exit(1)
+		}
+		if syncVerifyOnly && len(args) > 0 {
+			fmt.Fprintln(os.Stderr, "[zephyr] Error: --verify-only checks the whole environment and takes no packages")
+			os.Exit(1)
+		}
+		if syncVerifyOnly {
+			verifyEnvironment(venvPath)
+			return
+		}
+		fmt.Println("[zephyr] Installing dependencies from lockfile...")
 		venv := installer.NewVirtualEnvironment(venvPath)
 		if !venv.Exists() {
 			fmt.Fprintf(os.Stderr, "[zephyr] Error: Virtual environment does not exist at %s\n", venvPath)
 			fmt.Fprintln(os.Stderr, "Create it first with: zephyr venv create")
 			os.Exit(1)
 		}
+		defer lockVenv(venvPath).Release()
+		warnVenvPython(venvPath)
 		lockManager := installer.NewLockfileManager(".")
 		lockfile, err := lockManager.Load()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
 			os.Exit(1)
 		}
-		wheelInstaller := installer.NewWheelInstaller(venvPath)
-		for name, pkg := range lockfile.Packages {
-			fmt.Printf("[zephyr] Installing %s %s...\n", name, pkg.Version)
-			if err := wheelInstaller.InstallWheelFromPyPI(name, pkg.Version); err != nil {
-				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not install %s: %v\n", name, err)
+		// --prune compares .venv with the whole lock, even when syncing part of it
+		fullLock := lockfile
+		if len(args) > 0 {
+			lockfile, err = lockfile.Subtree(args)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
 				os.Exit(1)
 			}
+			fmt.Printf("[zephyr] Syncing %s and its dependencies (%d locked package(s))\n", strings.Join(args, ", "), len(lockfile.Packages))
+		}
+		if locked := lockfile.Metadata.Profile; locked != netutil.Profile() {
+			fmt.Fprintf(os.Stderr, "[zephyr] Warning: %s was resolved with profile %q but profile %q is selected; artifacts come from %s\n", installer.LockfileName(), locked, netutil.Profile(), netutil.GetPyPIBaseURL())
+		}
+		keep := append([]string(nil), syncKeep...)
+		if buildMeta, err := buildmeta.ParseFromDirectory("."); err == nil {
+			warnSystemRequirements(buildMeta)
+			for requirement := range buildMeta.GetDevDependencies() {
+				name, _, _ := solver.SplitExtraPackage(solver.ExpandExtras(requirement)[0])
+				keep = append(keep, name)
+			}
+		}
+		wheelInstaller := newWheelInstaller(venvPath)
+		err = installLockfile(wheelInstaller, lockfile)
+		recordInstalls("sync", venvPath, wheelInstaller, err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not install %v\n", err)
+			printRemedy(err)
+			os.Exit(1)
+		}
+		pinLockfileHashes(lockManager, wheelInstaller)
+		if syncPrune {
+			pruneEnvironment(venvPath, fullLock, keep)
+		}
+		if checkSharedLibs {
+			warnMissingSharedLibraries(venvPath, wheelInstaller)
 		}
 		fmt.Println("[zephyr] ✅ All packages installed from lockfile!")
+		if syncSmokeTest {
+			smokeTestEnvironment(venvPath)
+		}
+		autoPruneCache()
+		if showTimings {
+			printTimings(wheelInstaller)
+		}
 	},
 }
 
 var lockCmd = &cobra.Command{
 	Use:   "lock",
 	Short: "Generate lockfile without installing",
+	Long: `Resolve buildmeta.yaml and write zephyr.lock without installing anything.
+
+With --output urls, also print the artifact chosen for every locked package,
+with its download URL and sha256, as JSON or (--format csv) CSV on stdout, so
+that Bazel rules, Nix expressions and other build systems can fetch exactly
+what zephyr would install. Packages locked with --target get one row per target.
+
+--platform and --python-version take pip-style wheel tags instead of --target,
+to lock for a machine other than this one, e.g. an AWS Lambda bundle built on
+a Mac: zephyr lock --python-version 3.10 --platform manylinux2014_x86_64`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if lockOutput != "" && lockOutput != "urls" {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Unknown --output %q (expected urls)\n", lockOutput)
+			os.Exit(1)
+		}
+		if lockFormat != "json" && lockFormat != "csv" {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Unknown --format %q (expected json or csv)\n", lockFormat)
+			os.Exit(1)
+		}
+		crossTargets, err := platformTargets(lockPlatforms, lockPythonVersion)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Invalid --platform: %v\n", err)
+			os.Exit(1)
+		}
+		// Keep stdout for the artifact list when one was asked for
+		status := os.Stdout
+		if lockOutput != "" {
+			status = os.Stderr
+		}
 		buildMeta, err := buildmeta.ParseFromDirectory(".")
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
 			os.Exit(1)
 		}
-		s := solver.NewSolver(buildMeta.Name, buildMeta.Version)
-		for name, constraint := range buildMeta.GetDependencies() {
-			incompatibility := solver.Incompatibility{
-				Terms: []solver.Term{
-					{
-						Package: buildMeta.Name,
-						Version: solver.VersionConstraint{Specific: buildMeta.Version},
-						Negated: false,
-					},
-					{
-						Package: name,
-						Version: parseVersionConstraint(constraint),
-						Negated: true,
-					},
-				},
-			}
-			s.AddIncompatibility(incompatibility)
+		lockManager := installer.NewLockfileManager(".")
+		lockProject(lockManager)
+		defer lockManager.Unlock()
+		cutoff := lockExcludeNewer
+		if !cmd.Flags().Changed("exclude-newer") {
+			// Re-locking keeps the snapshot an earlier lock was resolved against
+			if previous, err := lockManager.Load(); err == nil {
+				cutoff = previous.Metadata.ExcludeNewer
+			}
 		}
-		solution, err := s.Solve()
+		if cutoff != "" {
+			t, err := pypi.ParseCutoff(cutoff)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Invalid --exclude-newer: %v\n", err)
+				os.Exit(1)
+			}
+			pypi.SetExcludeNewer(t)
+			fmt.Fprintf(status, "[zephyr] Ignoring files uploaded after %s\n", t.Format(time.RFC3339))
+		}
+		s := projectSolver(buildMeta)
+		solution := solve(s)
+		if lockCheck {
+			checkLockfile(lockManager, solution)
+			return
+		}
+		resolved, err := lockManager.Build(buildmeta.ManifestName(), solution, projectPythonMinor())
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "[zephyr] Dependency resolution failed: %v\n", err)
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not create lockfile: %v\n", err)
 			os.Exit(1)
 		}
-		lockManager := installer.NewLockfileManager(".")
-		if err := lockManager.Update("buildmeta.yaml", solution, "3.11"); err != nil {
+		enforcePolicy(buildMeta, resolved, nil)
+		if err := lockManager.Save(resolved); err != nil {
 			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not create lockfile: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Println("✅ Lockfile generated: zephyr.lock")
-	},
-}
-
-var venvCmd = &cobra.Command{
-	Use:   "venv",
-	Short: "Manage virtual environments",
-}
-
-var venvCreateCmd = &cobra.Command{
-	Use:   "create [path]",
-	Short: "Create a new virtual environment",
-	Args:  cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		venvPath := ".venv"
-		if len(args) > 0 {
-			venvPath = args[0]
+		if !pypi.ExcludeNewer().IsZero() {
+			lockfile, err := lockManager.Load()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+				os.Exit(1)
+			}
+			lockfile.Metadata.ExcludeNewer = pypi.ExcludeNewer().Format(time.RFC3339)
+			if err := lockManager.Save(lockfile); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not save lockfile: %v\n", err)
+				os.Exit(1)
+			}
 		}
-		venv := installer.NewVirtualEnvironment(venvPath)
-		if err := venv.Create(); err != nil {
-			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not create virtual environment: %v\n", err)
-			os.Exit(1)
+		if len(lockTargets) > 0 || len(crossTargets) > 0 {
+			targets := make([]markers.Target, 0, len(lockTargets)+len(crossTargets))
+			for _, spec := range lockTargets {
+				target, err := markers.ParseTarget(spec)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "[zephyr] Error: Invalid --target: %v\n", err)
+					os.Exit(1)
+				}
+				targets = append(targets, target)
+			}
+			targets = append(targets, crossTargets...)
+			lockfile, err := lockManager.Load()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+				os.Exit(1)
+			}
+			roots := make([]string, 0, len(buildMeta.GetDependencies()))
+			for name := range buildMeta.GetDependencies() {
+				roots = append(roots, name)
+			}
+			fmt.Fprintf(status, "[zephyr] Resolving %d targets...\n", len(targets))
+			if err := lockfile.ResolveTargets(pypi.NewPyPIClient(), roots, targets); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not resolve lockfile targets: %v\n", err)
+				os.Exit(1)
+			}
+			if err := lockManager.Save(lockfile); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not save lockfile: %v\n", err)
+				os.Exit(1)
+			}
 		}
-		fmt.Printf("✅ Created virtual environment at %s\n", venvPath)
-		fmt.Println("\nTo activate:")
-		if venvPath == ".venv" {
-			fmt.Println("  source .venv/bin/activate  # Linux/macOS")
-			fmt.Println("  .venv\\Scripts\\activate     # Windows")
-		} else {
-			fmt.Printf("  source %s/bin/activate\n", venvPath)
+		fmt.Fprintf(status, "✅ Lockfile generated: %s\n", installer.LockfileName())
+		if lockOutput == "urls" {
+			printLockedArtifacts(lockManager, buildMeta.Name)
+		}
+		if showTimings {
+			printTimings(nil)
 		}
 	},
 }
 
-var venvInstallCmd = &cobra.Command{
-	Use:   "install [venv-path]",
-	Short: "Install dependencies into virtual environment",
-	Args:  cobra.MaximumNArgs(1),
+var buildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build reproducible wheel and sdist archives",
+	Long:  "Build a pure-Python wheel and sdist into dist/. Archive timestamps come from SOURCE_DATE_EPOCH (1980-01-01 when unset) so repeated builds are byte-identical. The wheel is tagged py3-none-any unless --python-tag or --plat-name say otherwise; its metadata is checked against buildmeta.yaml and pyproject.toml and its compatibility tags are reported.",
 	Run: func(cmd *cobra.Command, args []string) {
-		venvPath := ".venv"
-		if len(args) > 0 {
-			venvPath = args[0]
+		// The default dist/ is the project's; an explicit directory is the caller's
+		if cmd.Flags().Changed("out-dir") {
+			buildOutDir = invocationPath(buildOutDir)
 		}
-		fmt.Printf("[zephyr] Installing dependencies into %s...\n", venvPath)
-		venv := installer.NewVirtualEnvironment(venvPath)
-		if !venv.Exists() {
-			fmt.Fprintf(os.Stderr, "[zephyr] Error: Virtual environment does not exist at %s\n", venvPath)
-			fmt.Fprintln(os.Stderr, "Create it first with: zephyr venv create")
+		buildMeta, err := buildmeta.ParseFromDirectory(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
 			os.Exit(1)
 		}
-		lockManager := installer.NewLockfileManager(".")
-		lockfile, err := lockManager.Load()
+		b, err := builder.NewBuilder(".", buildMeta)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not start build: %v\n", err)
 			os.Exit(1)
 		}
-		wheelInstaller := installer.NewWheelInstaller(venvPath)
-		for name, pkg := range lockfile.Packages {
-			fmt.Printf("[zephyr] Installing %s %s...\n", name, pkg.Version)
-			if err := wheelInstaller.InstallWheelFromPyPI(name, pkg.Version); err != nil {
-				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not install %s: %v\n", name, err)
+		b.PythonTag, b.PlatformTag = buildPythonTag, buildPlatName
+		// Build both when neither kind was requested explicitly
+		wantWheel, wantSdist := buildWheel || !buildSdist, buildSdist || !buildWheel
+		if wantSdist {
+			path, err := b.BuildSdist(buildOutDir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not build sdist: %v\n", err)
+				printRemedy(err)
 				os.Exit(1)
 			}
+			fmt.Printf("✅ Built %s\n", path)
+		}
+		if wantWheel {
+			path, err := b.BuildWheel(buildOutDir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not build wheel: %v\n", err)
+				printRemedy(err)
+				os.Exit(1)
+			}
+			tags, err := b.CheckWheel(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Built %s with invalid metadata: %v\n", path, err)
+				os.Exit(1)
+			}
+			fmt.Printf("✅ Built %s\n", path)
+			fmt.Printf("   Tags: %s\n", strings.Join(tags, ", "))
 		}
-		fmt.Printf("[zephyr] ✅ All packages installed into %s!\n", venvPath)
 	},
 }
 
-var venvListCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List available virtual environments",
+var publishCmd = &cobra.Command{
+	Use:   "publish [dist-file...]",
+	Short: "Upload built wheels and sdists to PyPI or another index",
+	Long: `Upload the given distributions, or every wheel and sdist in dist/, to the
+index's upload endpoint (PyPI by default).
+
+--repository names an upload target from the repositories section of the
+config, giving its URL, username and the environment variable that holds its
+token, so a token is only ever sent to the index it was scoped for.
+
+Credentials come from --token, the repository's token_env, ZEPHYR_PUBLISH_TOKEN
+or a token stored for the repository URL with zephyr auth login. PyPI and
+TestPyPI accept only API tokens (pypi-...) with the __token__ username, as
+accounts with two-factor authentication cannot upload with a password; other
+tokens are rejected before anything is sent. Without a token, in
+GitHub Actions (with permissions: id-token: write) or GitLab CI (with an
+id_tokens entry named PYPI_ID_TOKEN, aud: pypi), zephyr uses trusted publishing:
+the job's OIDC token is exchanged with the index for a short-lived API token,
+so no long-lived secret needs to be stored. --trusted-publishing always forces
+the exchange and never disables it.
+
+Every file is checked first, like twine check: metadata and version fields,
+classifiers, the license, and that the README long description will render.
+Any problem stops the upload before anything is sent; --check-only runs the
+checks alone.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if _, err := os.Stat(".venv"); err == nil {
-			fmt.Println(".venv (default)")
-		} else {
-			fmt.Println("No virtual environments found.")
+		var files []string
+		for _, file := range args {
+			files = append(files, invocationPath(file))
+		}
+		if len(files) == 0 {
+			for _, pattern := range []string{"dist/*.whl", "dist/*.tar.gz"} {
+				matches, _ := filepath.Glob(pattern)
+				files = append(files, matches...)
+			}
+			if len(files) == 0 {
+				fmt.Fprintln(os.Stderr, "[zephyr] Error: No distributions found in dist/")
+				fmt.Fprintln(os.Stderr, "Build them first with: zephyr build")
+				os.Exit(1)
+			}
+		}
+		var artifacts []*publish.Artifact
+		for _, file := range files {
+			artifact, err := publish.OpenArtifact(file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not read %s: %v\n", file, err)
+				os.Exit(1)
+			}
+			artifacts = append(artifacts, artifact)
+		}
+		failed := false
+		for _, artifact := range artifacts {
+			name := filepath.Base(artifact.Path)
+			result := publish.Check(artifact)
+			for _, warning := range result.Warnings {
+				fmt.Fprintf(os.Stderr, "[zephyr] Warning: %s: %s\n", name, warning)
+			}
+			for _, problem := range result.Errors {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: %s: %s\n", name, problem)
+			}
+			if result.OK() {
+				fmt.Printf("✅ %s passed checks\n", name)
+			}
+			failed = failed || !result.OK()
+		}
+		if failed {
+			fmt.Fprintln(os.Stderr, "Fix the problems above, rebuild with zephyr build, and publish again")
+			os.Exit(1)
+		}
+		if buildMeta, err := buildmeta.ParseFromDirectory("."); err == nil {
+			lockfile, _ := installer.NewLockfileManager(".").Load()
+			enforcePolicy(buildMeta, lockfile, artifacts)
+		}
+		if publishCheckOnly {
+			return
 		}
-	},
-}
 
-var venvActivateCmd = &cobra.Command{
-	Use:   "activate [venv-path]",
-	Short: "Print activation instructions for a virtual environment",
-	Args:  cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		venvPath := ".venv"
-		if len(args) > 0 {
-			venvPath = args[0]
+		token, username := publishToken, ""
+		if publishRepository != "" {
+			repository, err := configuredRepository(publishRepository)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Invalid --repository: %v\n", err)
+				os.Exit(1)
+			}
+			if !cmd.Flags().Changed("repository-url") {
+				publishRepositoryURL = repository.URL
+			}
+			username = repository.Username
+			if token == "" && repository.TokenEnv != "" {
+				token = os.Getenv(repository.TokenEnv)
+			}
 		}
-		if _, err := os.Stat(venvPath); err != nil {
-			fmt.Fprintf(os.Stderr, "[zephyr] Error: Virtual environment does not exist at %s\n", venvPath)
+		if token == "" {
+			token = os.Getenv("ZEPHYR_PUBLISH_TOKEN")
+		}
+		if token == "" {
+			var err error
+			var stored string
+			stored, token, err = credentials.NewDefaultStore().Lookup(publishRepositoryURL)
+			if err != nil && !errors.Is(err, credentials.ErrNotFound) {
+				fmt.Fprintf(os.Stderr, "[zephyr] Warning: Ignoring stored credentials: %v\n", err)
+			}
+			if token != "" {
+				username = stored
+			}
+		}
+		token = strings.TrimSpace(token)
+		if token != "" {
+			if err := publish.CheckCredentials(publishRepositoryURL, username, token); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		switch publishTrusted {
+		case "auto", "always", "never":
+		default:
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Invalid --trusted-publishing %q: expected auto, always or never\n", publishTrusted)
 			os.Exit(1)
 		}
-		fmt.Println("To activate:")
-		fmt.Printf("  source %s/bin/activate  # Linux/macOS\n", venvPath)
-		fmt.Printf("  %s\\Scripts\\activate     # Windows\n", venvPath)
+		if publishTrusted == "always" || (token == "" && publishTrusted == "auto") {
+			publisher, err := publish.NewTrustedPublisher(publishRepositoryURL)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+				os.Exit(1)
+			}
+			if publishTrusted == "always" || publisher.Available() {
+				fmt.Printf("[zephyr] Exchanging the CI OIDC token with %s (trusted publishing)...\n", publisher.IndexURL)
+				if token, err = publisher.Token(); err != nil {
+					fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not use trusted publishing: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		}
+		if token == "" {
+			fmt.Fprintln(os.Stderr, "[zephyr] Error: No credentials for publishing")
+			fmt.Fprintln(os.Stderr, "Pass --token, set ZEPHYR_PUBLISH_TOKEN, store a token with zephyr auth login, or run in GitHub Actions or GitLab CI with a trusted publisher configured")
+			os.Exit(1)
+		}
+
+		uploader := publish.NewUploader(publishRepositoryURL, token)
+		if username != "" {
+			uploader.Username = username
+		}
+		for _, artifact := range artifacts {
+			fmt.Printf("[zephyr] Uploading %s...\n", filepath.Base(artifact.Path))
+			if err := uploader.Upload(artifact); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not upload %s: %v\n", filepath.Base(artifact.Path), err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("✅ Published %d file(s) to %s\n", len(artifacts), uploader.RepositoryURL)
 	},
 }
 
-var searchCmd = &cobra.Command{
-	Use:   "search [query]",
-	Short: "Search for packages on PyPI",
-	Args:  cobra.ExactArgs(1),
+var packCmd = &cobra.Command{
+	Use:   "pack",
+	Short: "Bundle the project and its locked dependencies into an executable .pyz",
 	Run: func(cmd *cobra.Command, args []string) {
-		query := args[0]
-		client := pypi.NewPyPIClient()
-		metadata, err := client.FetchPackageMetadata(query)
+		format, err := builder.ParsePackFormat(packFormat)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not search for package: %v\n", err)
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Invalid --format: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("📦 %s %s\n", metadata.Info.Name, metadata.Info.Version)
-		fmt.Printf("📝 %s\n", metadata.Info.Summary)
-		if metadata.Info.Author != "" {
-			fmt.Printf("👤 Author: %s\n", metadata.Info.Author)
-		}
-		if metadata.Info.HomePage != "" {
-			fmt.Printf("🌐 Homepage: %s\n", metadata.Info.HomePage)
-		}
-		fmt.Println("\nAvailable versions:")
-		versions, err := client.GetVersions(query)
+		buildMeta, err := buildmeta.ParseFromDirectory(".")
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not get versions: %v\n", err)
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
 			os.Exit(1)
 		}
-		for _, version := range versions {
-			fmt.Printf("  %s\n", version)
+		b, err := builder.NewBuilder(".", buildMeta)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not start build: %v\n", err)
+			os.Exit(1)
 		}
+		entryPoint := packEntryPoint
+		if entryPoint == "" {
+			if entryPoint, err = b.DefaultEntryPoint(); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		var wheels []string
+		lockManager := installer.NewLockfileManager(".")
+		if lockManager.Exists() {
+			lockfile, err := lockManager.Load()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+				os.Exit(1)
+			}
+			wheelDir, err := os.MkdirTemp("", "zephyr-pack-*")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not create temp directory: %v\n", err)
+				os.Exit(1)
+			}
+			defer os.RemoveAll(wheelDir)
+			wheelInstaller := installer.NewWheelInstaller(".venv")
+			for _, name := range sortedPackageNames(lockfile.Packages) {
+				pkg := lockfile.Packages[name]
+				fmt.Printf("[zephyr] Fetching %s %s...\n", name, pkg.Version)
+				path, err := wheelInstaller.DownloadWheel(name, pkg.Version, wheelDir)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not fetch %s: %v\n", name, err)
+					os.Exit(1)
+				}
+				wheels = append(wheels, path)
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, "[zephyr] Warning: No zephyr.lock found, packing the project without dependencies")
+		}
+		output := invocationPath(packOutput)
+		if output == "" {
+			output = filepath.Join("dist", b.DistributionName()+".pyz")
+		}
+		opts := builder.PackOptions{Format: format, EntryPoint: entryPoint, Interpreter: packPython, Wheels: wheels}
+		if err := b.Pack(output, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not pack application: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Packed %s (%s, entry point %s)\n", output, format, entryPoint)
 	},
 }
 
-var solveCmd = &cobra.Command{
-	Use:   "solve",
-	Short: "Solve dependencies using Pubgrub algorithm",
+var downloadCmd = &cobra.Command{
+	Use:   "download [dir]",
+	Short: "Download the locked wheels, optionally for another platform",
+	Long: `Download the wheel of every package pinned in zephyr.lock into dir (default
+wheels/), verified against the locked hashes. With --platform and
+--python-version the wheels are chosen for that platform instead of this
+machine, and packages whose markers exclude it are skipped, so a bundle for
+Linux containers or AWS Lambda can be built from a Mac:
+
+  zephyr download --python-version 3.10 --platform manylinux2014_x86_64 wheels/
+  pip install --no-index --find-links wheels/ --target bundle/ <packages>
+
+Packages installed from a URL or file are skipped with a warning.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		s := solver.NewSolver("example", "1.0.0")
-		dependencies := map[string]string{
-			"requests": ">=2.25.0",
-			"urllib3":  ">=1.26.0",
-			"certifi":  ">=2020.12.0",
+		dir := "wheels"
+		if len(args) > 0 {
+			dir = invocationPath(args[0])
 		}
-		for name, constraint := range dependencies {
-			incompatibility := solver.Incompatibility{
-				Terms: []solver.Term{
-					{
-						Package: "example",
-						Version: solver.VersionConstraint{Specific: "1.0.0"},
-						Negated: false,
-					},
-					{
-						Package: name,
-						Version: parseVersionConstraint(constraint),
-						Negated: true,
-					},
-				},
-			}
-			s.AddIncompatibility(incompatibility)
+		targets, err := platformTargets(downloadPlatforms, downloadPythonVersion)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Invalid --platform: %v\n", err)
+			os.Exit(1)
 		}
-		solution, err := s.Solve()
+		lockfile, err := installer.NewLockfileManager(".").Load()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "[zephyr] Dependency resolution failed: %v\n", err)
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Generate it first with: zephyr lock")
 			os.Exit(1)
 		}
-		fmt.Println("✅ Dependencies solved successfully!")
-		fmt.Println("\nSolution:")
-		for _, assignment := range solution.Assignments {
-			if assignment.IsDecision {
-				fmt.Printf("  %s == %s\n", assignment.Term.Package, assignment.Term.Version.String())
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not create %s: %v\n", dir, err)
+			os.Exit(1)
+		}
+		if len(targets) == 0 {
+			// A nil target downloads what this machine would install
+			targets = []markers.Target{{}}
+		}
+		downloaded := 0
+		for _, target := range targets {
+			wheelInstaller := installer.NewWheelInstaller(".venv")
+			wheelInstaller.SetLockedHashes(lockfile)
+			suffix := ""
+			if target != (markers.Target{}) {
+				wheelInstaller.SetTarget(target)
+				suffix = " for " + target.String()
+			}
+			for _, name := range sortedPackageNames(lockfile.Packages) {
+				pkg := lockfile.Packages[name]
+				if pkg.Source == installer.SourceURL || pkg.Source == installer.SourceFile {
+					fmt.Fprintf(os.Stderr, "[zephyr] Warning: Skipping %s, which was installed from %s\n", name, pkg.URL)
+					continue
+				}
+				if target != (markers.Target{}) && pkg.Markers != "" {
+					if applies, err := markers.Evaluate(pkg.Markers, target.Environment()); err == nil && !applies {
+						continue
+					}
+				}
+				fmt.Printf("[zephyr] Fetching %s %s%s...\n", name, pkg.Version, suffix)
+				if _, err := wheelInstaller.DownloadWheel(name, pkg.Version, dir); err != nil {
+					fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not fetch %s: %v\n", name, err)
+					os.Exit(1)
+				}
+				downloaded++
 			}
 		}
+		fmt.Printf("✅ Downloaded %d wheel(s) to %s\n", downloaded, displayPath(dir))
 	},
 }
 
-var demoCmd = &cobra.Command{
-	Use:   "demo",
-	Short: "Run Pubgrub algorithm demo",
+var vendorCmd = &cobra.Command{
+	Use:   "vendor [dir]",
+	Short: "Copy the locked pure-Python dependencies into the project tree",
+	Long: `Extract every package pinned in zephyr.lock into dir (default vendor/), for
+projects that must ship all their code in-tree. The directory is rebuilt
+from scratch and gets a vendor.txt listing name==version for each package,
+with a note on putting the directory on sys.path.
+
+Only pure-Python wheels can be vendored; packages with compiled code, or
+installed from a URL or file, are skipped with a warning. An existing
+directory is only replaced if an earlier zephyr vendor created it.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		solver.ExampleConflictResolution()
+		dir := "vendor"
+		if len(args) > 0 {
+			dir = invocationPath(args[0])
+		}
+		lockfile, err := installer.NewLockfileManager(".").Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Generate it first with: zephyr lock")
+			os.Exit(1)
+		}
+		wheelDir, err := os.MkdirTemp("", "zephyr-vendor-*")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not create temp directory: %v\n", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(wheelDir)
+		wheelInstaller := installer.NewWheelInstaller(".venv")
+		wheelInstaller.SetLockedHashes(lockfile)
+		var packages []builder.VendoredPackage
+		var skipped []string
+		for _, name := range sortedPackageNames(lockfile.Packages) {
+			pkg := lockfile.Packages[name]
+			if pkg.Source == installer.SourceURL || pkg.Source == installer.SourceFile {
+				fmt.Fprintf(os.Stderr, "[zephyr] Warning: Skipping %s, which was installed from %s\n", name, pkg.URL)
+				skipped = append(skipped, name)
+				continue
+			}
+			fmt.Printf("[zephyr] Fetching %s %s...\n", name, pkg.Version)
+			path, err := wheelInstaller.DownloadWheel(name, pkg.Version, wheelDir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not fetch %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			if pure, err := builder.IsPureWheel(filepath.Base(path)); err != nil || !pure {
+				fmt.Fprintf(os.Stderr, "[zephyr] Warning: Skipping %s, which has no pure-Python wheel (%s)\n", name, filepath.Base(path))
+				skipped = append(skipped, name)
+				continue
+			}
+			packages = append(packages, builder.VendoredPackage{Name: name, Version: pkg.Version, Wheel: path})
+		}
+		if err := builder.Vendor(dir, packages); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not vendor dependencies: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Vendored %d package(s) into %s (see %s)\n", len(packages), dir, filepath.Join(dir, builder.VendorManifest))
+		fmt.Println("Put it on sys.path ahead of site-packages to import them, e.g.:")
+		fmt.Printf("  sys.path.insert(0, os.path.join(os.path.dirname(__file__), %q))\n", filepath.Base(dir))
+		if len(skipped) > 0 {
+			fmt.Printf("[zephyr] Not vendored, install these normally: %s\n", strings.Join(skipped, ", "))
+		}
 	},
 }
 
-var examplesCmd = &cobra.Command{
-	Use:   "examples",
-	Short: "Show Pubgrub algorithm examples",
+var runCmd = &cobra.Command{
+	Use:   "run [task|command] [args...]",
+	Short: "Run a script task or command with the project environment and virtualenv activated",
+	Long: `Run a script from buildmeta.yaml, or any other command, with the project's
+environment variables applied and .venv activated. Without arguments, list the
+available scripts.
+
+Scripts may declare depends_on, cwd and env. Dependencies run first, with
+independent ones in parallel (see --jobs); extra arguments are appended to the
+requested script's command.
+
+Variables are merged from lowest to highest precedence: the env section of
+buildmeta.yaml, the project's .env file, the calling shell environment,
+each --env-file in the order given, and finally the script's own env.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		solver.RunAllExamples()
+		buildMeta, env := loadProjectEnv()
+		runner := tasks.NewRunner(buildMeta.Scripts, ".", env)
+		if len(args) == 0 {
+			if len(buildMeta.Scripts) == 0 {
+				fmt.Println("No scripts defined in buildmeta.yaml")
+				return
+			}
+			fmt.Println("Scripts:")
+			for _, name := range runner.Names() {
+				task := buildMeta.Scripts[name]
+				details := []string{}
+				if task.Cmd != "" {
+					details = append(details, task.Cmd)
+				}
+				if len(task.DependsOn) > 0 {
+					details = append(details, fmt.Sprintf("(after %s)", strings.Join(task.DependsOn, ", ")))
+				}
+				fmt.Printf("  %-16s %s\n", name, strings.Join(details, " "))
+			}
+			return
+		}
+		if !runner.Has(args[0]) {
+			execWithEnv(args[0], args[1:], env)
+			return
+		}
+		runTask(runner, args[0], args[1:])
 	},
 }
 
-var importCmd = &cobra.Command{
-	Use:   "import [file]",
-	Short: "Import dependencies from requirements.txt or pyproject.toml",
-	Args:  cobra.ExactArgs(1),
+var testCmd = &cobra.Command{
+	Use:   "test [args...]",
+	Short: "Sync dev dependencies and run the test suite inside the virtualenv",
+	Long: `Install any missing dev-dependencies into .venv, then run the project's tests
+with the same environment as zephyr run. The "test" script from buildmeta.yaml is
+used when defined, otherwise pytest. Arguments are passed through to the test
+command and its exit code is returned.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		file := args[0]
-		if strings.HasSuffix(file, ".txt") {
-			reqs, err := buildmeta.ParseRequirementsFile(file)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not parse requirements.txt: %v\n", err)
+		buildMeta, err := buildmeta.ParseFromDirectory(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
+			os.Exit(1)
+		}
+		venv := installer.NewVirtualEnvironment(".venv")
+		if !venv.Exists() {
+			fmt.Println("[zephyr] Creating virtual environment at .venv...")
+			if err := venv.Create(); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not create virtual environment: %v\n", err)
 				os.Exit(1)
 			}
-			buildMeta, err := buildmeta.ParseFromDirectory(".")
-			if err != nil {
-				buildMeta = buildmeta.NewBuildMeta("imported-project", "0.1.0")
-			}
-			for name, constraint := range reqs {
-				buildMeta.AddDependency(name, constraint)
+		}
+		if !testNoSync {
+			syncDevDependencies(buildMeta, venv)
+		}
+		_, env := loadProjectEnv()
+		runner := tasks.NewRunner(buildMeta.Scripts, ".", env)
+		if runner.Has("test") {
+			runTask(runner, "test", args)
+			return
+		}
+		execWithEnv("pytest", args, env)
+	},
+}
+
+var holdCmd = &cobra.Command{
+	Use:   "hold [package...]",
+	Short: "Keep packages at their locked versions during update and re-resolution",
+	Long:  "Add packages to the holds list in buildmeta.yaml. Held packages are pinned to their zephyr.lock versions by install and lock, and skipped by update. Without arguments, list the current holds.",
+	Run: func(cmd *cobra.Command, args []string) {
+		buildMeta, err := buildmeta.ParseFromDirectory(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
+			os.Exit(1)
+		}
+		locked := map[string]installer.LockPackage{}
+		if lockfile, err := installer.NewLockfileManager(".").Load(); err == nil {
+			locked = lockfile.Packages
+		}
+		if len(args) == 0 {
+			if len(buildMeta.Holds) == 0 {
+				fmt.Println("No packages are held.")
+				return
 			}
-			if err := buildmeta.WriteToDirectory(".", buildMeta); err != nil {
-				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not save buildmeta.yaml: %v\n", err)
-				os.Exit(1)
+			client := pypi.NewPyPIClient()
+			for _, name := range buildMeta.Holds {
+				if pkg, ok := locked[name]; ok {
+					fmt.Printf("⏸️  %s %s\n", name, pkg.Version)
+					warnHeldAdvisories(client, name, pkg.Version)
+				} else {
+					fmt.Printf("⏸️  %s (not locked yet)\n", name)
+				}
 			}
-			fmt.Println("✅ Imported dependencies from requirements.txt into buildmeta.yaml")
-		} else if strings.HasSuffix(file, ".toml") {
-			pyMeta, err := buildmeta.ParsePyProjectToml(file)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not parse pyproject.toml: %v\n", err)
-				os.Exit(1)
+			return
+		}
+		changed := false
+		for _, name := range args {
+			if !buildMeta.AddHold(name) {
+				fmt.Printf("%s is already held\n", name)
+				continue
 			}
-			buildMeta := buildmeta.NewBuildMeta(pyMeta.Name, pyMeta.Version)
-			for name, constraint := range pyMeta.Dependencies {
-				buildMeta.AddDependency(name, constraint)
+			changed = true
+			if pkg, ok := locked[name]; ok {
+				fmt.Printf("✅ Holding %s at %s\n", name, pkg.Version)
+			} else {
+				fmt.Printf("✅ Holding %s at the version it is next locked at\n", name)
 			}
+		}
+		if changed {
 			if err := buildmeta.WriteToDirectory(".", buildMeta); err != nil {
 				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not save buildmeta.yaml: %v\n", err)
 				os.Exit(1)
 			}
-			fmt.Println("✅ Imported dependencies from pyproject.toml into buildmeta.yaml")
-		} else {
-			fmt.Fprintln(os.Stderr, "[zephyr] Error: Unsupported file type. Use requirements.txt or pyproject.toml.")
-			os.Exit(1)
 		}
 	},
 }
 
-var exportCmd = &cobra.Command{
-	Use:   "export [file]",
-	Short: "Export dependencies to requirements.txt or pyproject.toml",
-	Args:  cobra.ExactArgs(1),
+var unholdCmd = &cobra.Command{
+	Use:   "unhold [package...]",
+	Short: "Allow held packages to move again",
+	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		file := args[0]
 		buildMeta, err := buildmeta.ParseFromDirectory(".")
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
 			os.Exit(1)
 		}
-		if strings.HasSuffix(file, ".txt") {
-			if err := buildmeta.ExportRequirementsFile(file, buildMeta.GetDependencies()); err != nil {
-				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not write requirements.txt: %v\n", err)
-				os.Exit(1)
+		changed := false
+		for _, name := range args {
+			if buildMeta.RemoveHold(name) {
+				fmt.Printf("✅ Released hold on %s\n", name)
+				changed = true
+			} else {
+				fmt.Printf("%s is not held\n", name)
 			}
-			fmt.Println("✅ Exported dependencies to requirements.txt")
-		} else if strings.HasSuffix(file, ".toml") {
-			if err := buildmeta.ExportPyProjectToml(file, buildMeta); err != nil {
-				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not write pyproject.toml: %v\n", err)
+		}
+		if changed {
+			if err := buildmeta.WriteToDirectory(".", buildMeta); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not save buildmeta.yaml: %v\n", err)
 				os.Exit(1)
 			}
-			fmt.Println("✅ Exported dependencies to pyproject.toml")
-		} else {
-			fmt.Fprintln(os.Stderr, "[zephyr] Error: Unsupported file type. Use requirements.txt or pyproject.toml.")
-			os.Exit(1)
 		}
 	},
 }
 
-// Enhance init to optionally create pyproject.toml
-var pyprojectFlag bool
-
-func init() {
-	rootCmd.AddCommand(initCmd)
-	rootCmd.AddCommand(addCmd)
-	rootCmd.AddCommand(removeCmd)
-	rootCmd.AddCommand(updateCmd)
-	rootCmd.AddCommand(installCmd)
-	rootCmd.AddCommand(syncCmd)
-	rootCmd.AddCommand(lockCmd)
-	rootCmd.AddCommand(venvCmd)
-	rootCmd.AddCommand(searchCmd)
-	rootCmd.AddCommand(solveCmd)
-	rootCmd.AddCommand(demoCmd)
-	rootCmd.AddCommand(examplesCmd)
-	rootCmd.AddCommand(importCmd)
-	rootCmd.AddCommand(exportCmd)
-
-	venvCmd.AddCommand(venvCreateCmd)
-	venvCmd.AddCommand(venvInstallCmd)
-	venvCmd.AddCommand(venvListCmd)
-	venvCmd.AddCommand(venvActivateCmd)
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the distributions installed in .venv",
+	Run: func(cmd *cobra.Command, args []string) {
+		dists, err := environment.New(".venv").Distributions()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not read installed packages: %v\n", err)
+			os.Exit(1)
+		}
+		if len(dists) == 0 {
+			fmt.Println("No packages installed.")
+			return
+		}
+		width := 0
+		for _, dist := range dists {
+			if len(dist.Name) > width {
+				width = len(dist.Name)
+			}
+		}
+		for _, dist := range dists {
+			fmt.Printf("%-*s  %s\n", width, dist.Name, dist.Version)
+		}
+	},
+}
 
-	initCmd.Flags().BoolVar(&pyprojectFlag, "pyproject", false, "Also create pyproject.toml")
+var treeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Show the distributions installed in .venv as a dependency tree",
+	Long: `Print each installed distribution that nothing else requires, with the
+requirements that apply to this environment below it, in the layout of
+pipdeptree. --format pipdeptree-json prints the flat list pipdeptree --json
+does instead, with every installed distribution and its requirements, so
+scripts and dashboards written for pipdeptree read it unchanged.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := environment.New(".venv").Tree()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not read installed packages: %v\n", err)
+			os.Exit(1)
+		}
+		switch treeFormat {
+		case "text":
+			printTree(entries)
+		case "pipdeptree-json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetEscapeHTML(false)
+			encoder.SetIndent("", "    ")
+			if err := encoder.Encode(environment.Pipdeptree(entries)); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Unknown --format %q (expected text or pipdeptree-json)\n", treeFormat)
+			os.Exit(1)
+		}
+	},
 }
 
-// parseVersionConstraint parses a version constraint string
-func parseVersionConstraint(constraint string) solver.VersionConstraint {
-	if constraint == "" {
-		return solver.VersionConstraint{}
+// printTree prints the installed distributions nothing requires, each with
+// its requirements indented below it. A requirement already printed further
+// up the same branch is not expanded again.
+func printTree(entries []environment.TreeEntry) {
+	if len(entries) == 0 {
+		fmt.Println("No packages installed.")
+		return
+	}
+	byName := make(map[string]environment.TreeEntry, len(entries))
+	required := make(map[string]bool)
+	for _, entry := range entries {
+		byName[pkgname.Normalize(entry.Distribution.Name)] = entry
+		for _, requirement := range entry.Requirements {
+			required[pkgname.Normalize(requirement.Name)] = true
+		}
+	}
+	var roots []environment.TreeEntry
+	for _, entry := range entries {
+		if !required[pkgname.Normalize(entry.Distribution.Name)] {
+			roots = append(roots, entry)
+		}
+	}
+	if len(roots) == 0 {
+		// Every distribution is part of a cycle
+		roots = entries
+	}
+	var walk func(entry environment.TreeEntry, depth int, branch map[string]bool)
+	walk = func(entry environment.TreeEntry, depth int, branch map[string]bool) {
+		name := pkgname.Normalize(entry.Distribution.Name)
+		branch[name] = true
+		defer delete(branch, name)
+		for _, requirement := range entry.Requirements {
+			specifier, installed := requirement.Specifier, "?"
+			if specifier == "" {
+				specifier = "Any"
+			}
+			if requirement.Installed != nil {
+				installed = requirement.Installed.Version
+			}
+			fmt.Printf("%s- %s [required: %s, installed: %s]\n", strings.Repeat("  ", depth+1), requirement.Name, specifier, installed)
+			key := pkgname.Normalize(requirement.Name)
+			if child, ok := byName[key]; ok && !branch[key] {
+				walk(child, depth+1, branch)
+			}
+		}
 	}
-	
-	// Simple parsing - in real implementation this would be more robust
-	if strings.HasPrefix(constraint, ">=") {
-		return solver.VersionConstraint{Min: constraint[2:]}
-	} else if strings.HasPrefix(constraint, "<=") {
-		return solver.VersionConstraint{Max: constraint[2:]}
-	} else if strings.HasPrefix(constraint, "==") {
-		return solver.VersionConstraint{Specific: constraint[2:]}
-	} else if strings.HasPrefix(constraint, ">") {
-		return solver.VersionConstraint{Min: constraint[1:]}
-	} else if strings.HasPrefix(constraint, "<") {
-		return solver.VersionConstraint{Max: constraint[1:]}
+	for _, root := range roots {
+		fmt.Printf("%s==%s\n", root.Distribution.Name, root.Distribution.Version)
+		walk(root, 0, make(map[string]bool))
 	}
-	
-	// Default to specific version
-	return solver.VersionConstraint{Specific: constraint}
 }
 
-func main() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-} 
\ No newline at end of file
+var pruneCmd = &cobra.Command{
+	Use:   "prune --analyze [--remove]",
+	Short: "Find declared dependencies the project never imports",
+	Long: `With --analyze, scan the project's Python files for import statements, map
+them to the modules each dependency in buildmeta.yaml installed into .venv
+(from RECORD and top_level.txt), and list the dependencies that are never
+imported. With --remove, also delete those from buildmeta.yaml.
+
+Only the main dependencies are analyzed. Imports are found statically, so
+check the list before removing: modules loaded dynamically or by name, and
+packages used only as plugins or command-line tools, are not seen.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !pruneAnalyze {
+			fmt.Fprintln(os.Stderr, "[zephyr] Error: zephyr prune needs --analyze")
+			os.Exit(1)
+		}
+		buildMeta, err := buildmeta.ParseFromDirectory(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
+			os.Exit(1)
+		}
+		// Analyze by distribution name, but remove by the key as written
+		keys := make(map[string]string)
+		var declared []string
+		for requirement := range buildMeta.GetDependencies() {
+			name, _, _ := solver.SplitExtraPackage(solver.ExpandExtras(requirement)[0])
+			keys[name] = requirement
+			declared = append(declared, name)
+		}
+		if len(declared) == 0 {
+			fmt.Println("No dependencies declared in buildmeta.yaml.")
+			return
+		}
+		sort.Strings(declared)
+		imports, err := environment.ScanImports(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not scan the project's Python files: %v\n", err)
+			os.Exit(1)
+		}
+		usages, err := environment.New(".venv").DependencyUsage(declared, imports)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not read installed packages: %v\n", err)
+			os.Exit(1)
+		}
+		width := 0
+		for _, name := range declared {
+			if len(name) > width {
+				width = len(name)
+			}
+		}
+		var unused []string
+		for _, usage := range usages {
+			switch {
+			case !usage.Installed:
+				fmt.Printf("⚠️  %-*s  not installed; run 'zephyr install' to analyze it\n", width, usage.Name)
+			case len(usage.Modules) == 0:
+				fmt.Printf("⚠️  %-*s  installs no importable modules, so its use cannot be told from imports\n", width, usage.Name)
+			case usage.Unused():
+				fmt.Printf("❌ %-*s  never imported (provides %s)\n", width, usage.Name, strings.Join(usage.Modules, ", "))
+				unused = append(unused, usage.Name)
+			default:
+				more := ""
+				if len(usage.ImportedBy) > 1 {
+					more = fmt.Sprintf(" and %d more", len(usage.ImportedBy)-1)
+				}
+				fmt.Printf("✅ %-*s  imported by %s%s\n", width, usage.Name, usage.ImportedBy[0], more)
+			}
+		}
+		if len(unused) == 0 {
+			fmt.Println("\n✅ Every analyzed dependency is imported.")
+			return
+		}
+		if !pruneRemove {
+			fmt.Printf("\n%d of %d dependencies unused. Remove them from buildmeta.yaml with: zephyr prune --analyze --remove\n", len(unused), len(declared))
+			return
+		}
+		for _, name := range unused {
+			buildMeta.RemoveDependency(keys[name])
+		}
+		if err := buildmeta.WriteToDirectory(".", buildMeta); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not save buildmeta.yaml: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\n✅ Removed %s from dependencies. Run 'zephyr install' to update the lockfile.\n", strings.Join(unused, ", "))
+	},
+}
+
+var sizeCmd = &cobra.Command{
+	Use:   "size",
+	Short: "Report the disk space installed packages and .venv take",
+	Long: `Report each installed package's size from its RECORD, largest first, the
+total size of .venv, and the heaviest dependency chains: for each direct
+dependency in buildmeta.yaml (or each package nothing else requires), the
+size of everything it pulls in and the path to the largest package among them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		env := environment.New(".venv")
+		sizes, err := env.PackageSizes()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not read installed packages: %v\n", err)
+			os.Exit(1)
+		}
+		if len(sizes) == 0 {
+			fmt.Println("No packages installed.")
+			return
+		}
+		var roots []string
+		if buildMeta, err := buildmeta.ParseFromDirectory("."); err == nil {
+			for requirement := range buildMeta.GetDependencies() {
+				name, _, _ := solver.SplitExtraPackage(solver.ExpandExtras(requirement)[0])
+				roots = append(roots, name)
+			}
+			sort.Strings(roots)
+		}
+		chains, err := env.DependencyChains(roots)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not read installed packages: %v\n", err)
+			os.Exit(1)
+		}
+		footprint, err := env.Footprint()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not measure .venv: %v\n", err)
+			os.Exit(1)
+		}
+
+		var installed int64
+		width := len("Package")
+		for _, size := range sizes {
+			installed += size.Size
+			if len(size.Name) > width {
+				width = len(size.Name)
+			}
+		}
+		fmt.Printf("%-*s  %-12s  %10s  %6s\n", width, "Package", "Version", "Size", "Files")
+		for i, size := range sizes {
+			if sizeTop > 0 && i == sizeTop {
+				fmt.Printf("... %d more (--top 0 lists all)\n", len(sizes)-sizeTop)
+				break
+			}
+			fmt.Printf("%-*s  %-12s  %10s  %6d\n", width, size.Name, size.Version, formatSize(size.Size), size.Files)
+		}
+		fmt.Printf("\nInstalled packages: %s in %d packages\n", formatSize(installed), len(sizes))
+		fmt.Printf(".venv footprint:    %s\n", formatSize(footprint))
+
+		if len(chains) > 0 {
+			fmt.Println("\nHeaviest dependency chains:")
+			for i, chain := range chains {
+				if sizeTop > 0 && i == sizeTop {
+					break
+				}
+				fmt.Printf("  %-*s  %10s  %s\n", width, chain.Root, formatSize(chain.Size), strings.Join(chain.Path, " -> "))
+			}
+		}
+	},
+}
+
+var infoCmd = &cobra.Command{
+	Use:   "info <package>",
+	Short: "Show metadata, files and entry points of an installed distribution",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dist, err := environment.New(".venv").Get(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not find %s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		printCoreMetadata(dist.Metadata)
+		fmt.Printf("\nLocation: %s\n", dist.SitePackages)
+		if dist.Installer != "" {
+			fmt.Printf("Installer: %s\n", dist.Installer)
+		}
+		if dist.DirectURL != nil {
+			fmt.Printf("Direct URL: %s\n", dist.DirectURL.URL)
+		}
+		if len(dist.EntryPoints) > 0 {
+			fmt.Println("\nEntry points:")
+			for _, entry := range dist.EntryPoints {
+				target := entry.Module
+				if entry.Attr != "" {
+					target += ":" + entry.Attr
+				}
+				fmt.Printf("  [%s] %s = %s\n", entry.Group, entry.Name, target)
+			}
+		}
+		if infoFiles {
+			fmt.Println("\nFiles:")
+			for _, file := range dist.Files {
+				fmt.Printf("  %s\n", file)
+			}
+		}
+	},
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Verify that installed distributions have compatible dependencies",
+	Long: `Verify that every installed distribution's requirements are met by the
+other installed distributions, and that no project has more than one
+.dist-info directory.
+
+With --shared-libs, instead scan the extension modules in .venv with ldd (or
+otool -L on macOS) for shared libraries the system lacks, such as libGL, and
+suggest the system package to install for each.
+
+With --imports, instead scan the project's Python files for imports that no
+dependency in buildmeta.yaml provides: modules installed only because another
+package requires them, or not installed at all. Code relying on those breaks
+when the transitive dependencies shift, so each comes with the zephyr add
+command declaring it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if checkSharedLibsOnly {
+			checkSharedLibraries(".venv")
+			return
+		}
+		if checkImports {
+			checkUndeclaredImports(".venv")
+			return
+		}
+		env := environment.New(".venv")
+		problems, err := env.Check()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not check installed packages: %v\n", err)
+			os.Exit(1)
+		}
+		duplicates, err := env.Duplicates()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not check installed packages: %v\n", err)
+			os.Exit(1)
+		}
+		if len(problems) == 0 && len(duplicates) == 0 {
+			fmt.Println("✅ No broken requirements found.")
+			return
+		}
+		for _, problem := range problems {
+			fmt.Printf("❌ %s\n", problem)
+		}
+		for _, duplicate := range duplicates {
+			fmt.Printf("❌ %s\n", duplicate)
+		}
+		if len(duplicates) > 0 {
+			fmt.Fprintln(os.Stderr, "Remove the stale installations with: zephyr repair")
+		}
+		os.Exit(1)
+	},
+}
+
+var repairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Remove stale .dist-info directories and reconcile .venv with zephyr.lock",
+	Long: `Repair .venv after other installers or interrupted upgrades:
+
+  - of a project with several .dist-info directories, keep the one at the
+    locked version (or the newest) and remove the others, along with any
+    files only they own
+  - uninstall packages that are not locked or are at the wrong version
+  - reinstall locked packages that are missing, at the wrong version, or
+    whose files no longer match their RECORD
+
+Without a lockfile only duplicate installations are repaired.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		venvPath := ".venv"
+		if !installer.NewVirtualEnvironment(venvPath).Exists() {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Virtual environment does not exist at %s\n", venvPath)
+			os.Exit(1)
+		}
+		defer lockVenv(venvPath).Release()
+		lockfile, err := installer.NewLockfileManager(".").Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not load lockfile, so only duplicate installations are repaired: %v\n", err)
+			lockfile = nil
+		}
+		env := environment.New(venvPath)
+		plan, err := env.PlanRepair(lockfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not inspect %s: %v\n", venvPath, err)
+			os.Exit(1)
+		}
+		if plan.Empty() {
+			fmt.Printf("✅ %s needs no repair.\n", venvPath)
+			return
+		}
+		for _, stale := range plan.Stale {
+			fmt.Printf("Remove %s\n", stale)
+		}
+		for _, removal := range plan.Remove {
+			fmt.Printf("Uninstall %s\n", removal)
+		}
+		for _, name := range plan.Reinstall {
+			fmt.Printf("Reinstall %s %s\n", name, lockfile.Packages[name].Version)
+		}
+		if repairDryRun {
+			return
+		}
+
+		entry := auditlog.NewEntry("repair", venvPath)
+		for _, stale := range plan.Stale {
+			entry.Packages = append(entry.Packages, auditlog.Package{Name: stale.Dist.Name, Version: stale.Dist.Version, Action: auditlog.ActionUninstall})
+		}
+		for _, removal := range plan.Remove {
+			entry.Packages = append(entry.Packages, auditlog.Package{Name: removal.Dist.Name, Version: removal.Dist.Version, Action: auditlog.ActionUninstall})
+		}
+		removed, err := env.ApplyRepair(plan)
+		if err != nil {
+			recordAudit(entry, err)
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not repair %s: %v\n", venvPath, err)
+			os.Exit(1)
+		}
+		recordAudit(entry, nil)
+		if len(plan.Reinstall) > 0 {
+			reinstall := *lockfile
+			reinstall.Packages = make(map[string]installer.LockPackage, len(plan.Reinstall))
+			for _, name := range plan.Reinstall {
+				reinstall.Packages[name] = lockfile.Packages[name]
+			}
+			wheelInstaller := newWheelInstaller(venvPath)
+			err := installLockfile(wheelInstaller, &reinstall)
+			recordInstalls("repair", venvPath, wheelInstaller, err)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not reinstall %v\n", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("✅ Repaired %s (%d paths removed, %d packages reinstalled)\n", venvPath, len(removed), len(plan.Reinstall))
+	},
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check for the system tools and libraries buildmeta.yaml requires",
+	Long: `Check that the system provides what buildmeta.yaml declares under
+system-requirements: tools must be on PATH, and libraries must be in the
+linker's search path or known to pkg-config. For each missing one, print the
+package to install with the host's package manager. Exits non-zero if any are
+missing.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		buildMeta, err := buildmeta.ParseFromDirectory(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
+			os.Exit(1)
+		}
+		reqs := buildMeta.SystemRequirements
+		if len(reqs.Tools) == 0 && len(reqs.Libraries) == 0 {
+			fmt.Println("No system requirements declared in buildmeta.yaml.")
+			return
+		}
+		results := sysreq.Check(reqs.Tools, reqs.Libraries)
+		for _, r := range results {
+			if r.Found() {
+				fmt.Printf("✅ %s\n", r)
+			} else {
+				fmt.Printf("❌ %s\n", r)
+			}
+		}
+		if missing := sysreq.Missing(results); len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: %d system requirement(s) missing\n", len(missing))
+			os.Exit(1)
+		}
+		fmt.Println("✅ All system requirements are met.")
+	},
+}
+
+var reinstallCmd = &cobra.Command{
+	Use:   "reinstall [package...]",
+	Short: "Uninstall locked packages and install them again from freshly downloaded artifacts",
+	Long: `Remove the named packages from .venv using their RECORD, then download
+their locked artifacts again, verify them against the hashes in zephyr.lock
+and install them, without reusing cached copies. Use it to recover from bit
+rot or from files patched in site-packages; --all reinstalls every locked
+package. Dependencies are not reinstalled unless named.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if reinstallAll == (len(args) > 0) {
+			fmt.Fprintln(os.Stderr, "[zephyr] Error: Name the packages to reinstall, or pass --all")
+			os.Exit(1)
+		}
+		venvPath := ".venv"
+		if !installer.NewVirtualEnvironment(venvPath).Exists() {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Virtual environment does not exist at %s\n", venvPath)
+			fmt.Fprintln(os.Stderr, "Create it first with: zephyr venv create")
+			os.Exit(1)
+		}
+		defer lockVenv(venvPath).Release()
+		lockfile, err := installer.NewLockfileManager(".").Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+			os.Exit(1)
+		}
+		if !reinstallAll {
+			if lockfile, err = lockfile.Only(args); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		env := environment.New(venvPath)
+		entry := auditlog.NewEntry("reinstall", venvPath)
+		names := make([]string, 0, len(lockfile.Packages))
+		for name := range lockfile.Packages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			dist, err := env.Get(name)
+			if errors.Is(err, environment.ErrNotInstalled) {
+				continue
+			}
+			if err == nil {
+				_, err = env.Uninstall(name)
+			}
+			if err != nil {
+				recordAudit(entry, err)
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not uninstall %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			fmt.Printf("[zephyr] Uninstalled %s %s\n", dist.Name, dist.Version)
+			entry.Packages = append(entry.Packages, auditlog.Package{Name: dist.Name, Version: dist.Version, Action: auditlog.ActionUninstall})
+		}
+		recordAudit(entry, nil)
+
+		wheelInstaller := newWheelInstaller(venvPath)
+		wheelInstaller.SetRefresh(true)
+		err = installLockfile(wheelInstaller, lockfile)
+		recordInstalls("reinstall", venvPath, wheelInstaller, err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not reinstall %v\n", err)
+			printRemedy(err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Reinstalled %d package(s) from fresh downloads\n", len(names))
+	},
+}
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall <package...>",
+	Short: "Remove installed distributions from .venv without touching buildmeta.yaml",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		defer lockVenv(".venv").Release()
+		env := environment.New(".venv")
+		entry := auditlog.NewEntry("uninstall", ".venv")
+		for _, name := range args {
+			pkg := auditlog.Package{Name: name, Action: auditlog.ActionUninstall}
+			if dist, err := env.Get(name); err == nil {
+				pkg.Name, pkg.Version = dist.Name, dist.Version
+			}
+			removed, err := env.Uninstall(name)
+			if len(removed) > 0 {
+				entry.Packages = append(entry.Packages, pkg)
+			}
+			if err != nil {
+				recordAudit(entry, err)
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not uninstall %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			fmt.Printf("✅ Uninstalled %s (%d paths removed)\n", name, len(removed))
+		}
+		recordAudit(entry, nil)
+	},
+}
+
+var bugReportCmd = &cobra.Command{
+	Use:   "bug-report",
+	Short: "Bundle version, config, the last command's log and solver trace for an issue report",
+	Long: `Collect diagnostics into a tarball to attach to an issue: zephyr and Go versions,
+the platform and Python interpreters, buildmeta.yaml, pyproject.toml and zephyr.lock,
+the debug log of the last zephyr command and the solver state of the last resolution.
+Passwords, tokens and URL credentials are redacted. Nothing is uploaded.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		output := invocationPath(bugReportOutput)
+		if output == "" {
+			output = fmt.Sprintf("zephyr-bug-report-%s.tar.gz", time.Now().Format("20060102-150405"))
+		}
+		report := bugreport.NewReport()
+		report.Add("version.txt", fmt.Sprintf("zephyr: %s\ngo: %s\nplatform: %s/%s\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH))
+		report.Add("environment.txt", bugReportEnvironment())
+		logDir := bugreport.DefaultLogDir()
+		files := []struct{ name, path string }{
+			{"config/" + buildmeta.ManifestName(), buildmeta.ManifestName()},
+			{"config/pyproject.toml", "pyproject.toml"},
+			{"config/" + installer.LockfileName(), installer.LockfileName()},
+			{"logs/" + bugreport.LastCommandLog, filepath.Join(logDir, bugreport.LastCommandLog)},
+			{"logs/" + bugreport.SolverTraceLog, filepath.Join(logDir, bugreport.SolverTraceLog)},
+		}
+		for _, f := range files {
+			if _, err := report.AddFile(f.name, f.path); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not include %s: %v\n", f.path, err)
+			}
+		}
+		if err := report.Write(output); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not write bug report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Wrote %s\n", output)
+		for _, name := range report.Names() {
+			fmt.Printf("  %s\n", name)
+		}
+		fmt.Println("Secrets are redacted, but review the contents before attaching the report to an issue.")
+	},
+}
+
+// bugReportEnvironment describes the working directory, Python interpreters
+// and zephyr-related environment variables
+func bugReportEnvironment() string {
+	var b strings.Builder
+	cwd, _ := os.Getwd()
+	fmt.Fprintf(&b, "cwd: %s\n", cwd)
+	fmt.Fprintf(&b, "cache: %s\nconfig: %s\ndata: %s\nlogs: %s\n", dirs.CacheDir(), dirs.ConfigDir(), dirs.DataDir(), dirs.LogDir())
+	for _, name := range []string{"python3", "python"} {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		output, _ := exec.Command(path, "--version").CombinedOutput()
+		fmt.Fprintf(&b, "%s: %s (%s)\n", name, strings.TrimSpace(string(output)), path)
+	}
+	venv := installer.NewVirtualEnvironment(".venv")
+	if venv.Exists() {
+		if output, err := venv.GetPythonVersion(); err == nil {
+			fmt.Fprintf(&b, ".venv: %s\n", output)
+		} else {
+			fmt.Fprintf(&b, ".venv: %v\n", err)
+		}
+	} else {
+		b.WriteString(".venv: not present\n")
+	}
+	prefixes := []string{"ZEPHYR_", "PIP_", "PYTHON", "VIRTUAL_ENV", "SOURCE_DATE_EPOCH", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"}
+	var vars []string
+	for _, kv := range os.Environ() {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(strings.ToUpper(kv), prefix) {
+				vars = append(vars, kv)
+				break
+			}
+		}
+	}
+	sort.Strings(vars)
+	b.WriteString("environment:\n")
+	for _, kv := range vars {
+		fmt.Fprintf(&b, "%s\n", kv)
+	}
+	return b.String()
+}
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Manage git hooks that keep the lockfile in sync",
+}
+
+var hooksInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install git hooks that run zephyr lock --check and optional scripts",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		hooksDir, projectDir := locateHooks()
+		script := hooks.Script(hooks.Options{ProjectDir: projectDir, Tasks: hookTasks})
+		for _, name := range hookNames {
+			if err := hooks.Install(hooksDir, name, script, hookForce); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not install %s hook: %v\n", name, err)
+				os.Exit(1)
+			}
+			fmt.Printf("✅ Installed %s hook\n", name)
+		}
+	},
+}
+
+var hooksUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove git hooks installed by zephyr",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		hooksDir, _ := locateHooks()
+		for _, name := range hooks.SupportedHooks {
+			removed, err := hooks.Uninstall(hooksDir, name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not remove %s hook: %v\n", name, err)
+				os.Exit(1)
+			}
+			if removed {
+				fmt.Printf("✅ Removed %s hook\n", name)
+			}
+		}
+	},
+}
+
+var pythonCmd = &cobra.Command{
+	Use:   "python",
+	Short: "Manage standalone Python interpreters",
+}
+
+var pythonInstallCmd = &cobra.Command{
+	Use:   "install [version]",
+	Short: "Download a standalone CPython build, e.g. zephyr python install 3.12",
+	Long: `Download the newest python-build-standalone CPython build matching the
+version (or the one named by .python-version), verify it against the release's
+SHA256SUMS and unpack it into the data directory. zephyr venv create uses it
+when no suitable interpreter is installed on the system.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		request := ""
+		if len(args) > 0 {
+			request = args[0]
+		} else if request, _, _ = projectPythonRequest(); request == "" {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: No version given and no %s found. Usage: zephyr python install 3.12\n", installer.PythonVersionFile)
+			os.Exit(1)
+		}
+		fmt.Printf("[zephyr] Installing Python %s...\n", request)
+		install, downloaded, err := interpreters.NewManager().Install(request)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not install Python %s: %v\n", request, err)
+			os.Exit(1)
+		}
+		if !downloaded {
+			fmt.Printf("✅ Python %s is already installed at %s\n", install.Version, install.Executable())
+			return
+		}
+		fmt.Printf("✅ Installed Python %s at %s\n", install.Version, install.Executable())
+	},
+}
+
+var pythonListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List interpreters installed with zephyr python install",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		installs, err := interpreters.NewManager().List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not list interpreters: %v\n", err)
+			os.Exit(1)
+		}
+		if len(installs) == 0 {
+			fmt.Println("No managed interpreters installed. Install one with: zephyr python install 3.12")
+			return
+		}
+		for _, install := range installs {
+			fmt.Printf("%-10s %s\n", install.Version, install.Executable())
+		}
+	},
+}
+
+var pythonUninstallCmd = &cobra.Command{
+	Use:   "uninstall <version>",
+	Short: "Remove an interpreter installed with zephyr python install",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := interpreters.NewManager().Uninstall(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Removed Python %s\n", args[0])
+	},
+}
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage index credentials kept in the OS keyring",
+	Long: `Store API tokens for package indexes in the OS keyring (the macOS keychain,
+or the Secret Service on Linux), or where none is available in an obfuscated
+file only you can read. The file's key is stored beside it, so it protects
+tokens no better than the file permissions do. Stored credentials are sent
+to the index and to zephyr publish, so they never need to be written into
+config files or typed on the command line. ZEPHYR_KEYRING=file or keyring
+forces a backend.`,
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:   "login <index>",
+	Short: "Prompt for a token and store it for an index, e.g. zephyr auth login upload.pypi.org",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := readSecret(fmt.Sprintf("Token for %s: ", args[0]))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not read token: %v\n", err)
+			os.Exit(1)
+		}
+		entry, err := credentials.NewDefaultStore().Login(args[0], authUsername, token)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not store credentials: %v\n", err)
+			os.Exit(1)
+		}
+		where := "the OS keyring"
+		if entry.Backend != "keyring" {
+			where = "an obfuscated file"
+			fmt.Fprintf(os.Stderr, "[zephyr] Warning: No OS keyring is available; the token is only protected by the file permissions of %s\n", filepath.Join(dirs.DataDir(), "credentials"))
+		}
+		fmt.Printf("✅ Stored credentials for %s in %s\n", entry.Index, where)
+	},
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout <index>",
+	Short: "Remove the credentials stored for an index",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := credentials.NewDefaultStore().Logout(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Removed credentials for %s\n", args[0])
+	},
+}
+
+var authListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List indexes with stored credentials, without showing the tokens",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := credentials.NewDefaultStore().List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not list credentials: %v\n", err)
+			os.Exit(1)
+		}
+		if len(entries) == 0 {
+			fmt.Println("No stored credentials. Add some with: zephyr auth login <index>")
+			return
+		}
+		for _, entry := range entries {
+			fmt.Printf("%-40s %-12s %s\n", entry.Index, entry.Username, entry.Backend)
+		}
+	},
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the machine-wide wheel cache",
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Evict cached wheels by last use and total size",
+	Long: `Evict cached wheels and their extracted copies that have not been used for
+longer than --max-age, then the least recently used ones until the cache fits
+in --max-size. Defaults come from cache_max_age and cache_max_size in config.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := netutil.LoadConfig()
+		maxAge, maxSize := cfg.CacheMaxAge, cfg.CacheMaxSize
+		if cmd.Flags().Changed("max-age") {
+			maxAge = cachePruneMaxAge
+		}
+		if cmd.Flags().Changed("max-size") {
+			maxSize = cachePruneMaxSize
+		}
+		policy, err := cachePolicy(maxAge, maxSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+			os.Exit(1)
+		}
+		if policy.MaxAge == 0 && policy.MaxSize == 0 {
+			fmt.Fprintln(os.Stderr, "[zephyr] Error: Nothing to prune by. Pass --max-age or --max-size, or set cache_max_age or cache_max_size in config")
+			os.Exit(1)
+		}
+		result, err := cache.Prune(cache.DefaultCacheDir(), policy, cachePruneDryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not prune cache: %v\n", err)
+			os.Exit(1)
+		}
+		verb := "Removed"
+		if cachePruneDryRun {
+			verb = "Would remove"
+			for _, path := range result.Removed {
+				fmt.Println(path)
+			}
+		}
+		fmt.Printf("✅ %s %d cache entries (%s), %s left\n", verb, len(result.Removed), formatSize(result.Freed), formatSize(result.Size))
+	},
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Re-lock and sync .venv whenever buildmeta.yaml or pyproject.toml changes",
+	Long: `Watch buildmeta.yaml and pyproject.toml and, once an edit has settled for the
+debounce period, run 'zephyr lock' and then 'zephyr sync', so dependencies
+edited in an IDE are installed right away. A failed lock or sync, for example
+while the file is half-edited, is reported and watching continues. Stop with
+Ctrl+C.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := os.Stat(buildmeta.ManifestName()); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not find buildmeta.yaml: %v\n", err)
+			os.Exit(1)
+		}
+		self, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not locate the zephyr executable: %v\n", err)
+			os.Exit(1)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		watcher := watch.New(buildmeta.ManifestName(), "pyproject.toml")
+		watcher.Debounce = watchDebounce
+		fmt.Printf("👀 Watching %s for dependency changes (Ctrl+C to stop)\n", strings.Join(watcher.Paths, ", "))
+		watcher.Run(ctx, func(changed []string) {
+			fmt.Printf("\n[zephyr] %s changed, re-locking and syncing...\n", strings.Join(changed, ", "))
+			for _, step := range []string{"lock", "sync"} {
+				run := exec.CommandContext(ctx, self, step)
+				run.Stdout, run.Stderr = os.Stdout, os.Stderr
+				if offlineMode {
+					run.Args = append(run.Args, "--offline")
+				}
+				if err := run.Run(); err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					fmt.Fprintf(os.Stderr, "[zephyr] Warning: zephyr %s failed (%v); waiting for the next change\n", step, err)
+					return
+				}
+			}
+			fmt.Printf("✅ .venv is up to date (%s)\n", time.Now().Format("15:04:05"))
+		})
+		fmt.Println("\n[zephyr] Stopped watching")
+	},
+}
+
+var serveAPICmd = &cobra.Command{
+	Use:   "serve-api",
+	Short: "Answer resolve, outdated and metadata queries over JSON-RPC for editor plugins",
+	Long: `Run a long-lived JSON-RPC 2.0 server for editor and language server
+integrations, so they can offer version completions and conflict diagnostics
+without starting zephyr for every query. Package metadata is cached in memory
+between requests.
+
+Requests are read from stdin, or from connections to --socket, framed either
+with LSP Content-Length headers or one JSON object per line. Methods:
+
+  metadata  {"name", "version"?}                summary, requirements and latest versions
+  versions  {"name", "prefix"?, "prereleases"?}  released versions, newest first
+  outdated  {}                                  locked dependencies with newer releases
+  resolve   {"dependencies"?: {name: spec}}     resolve buildmeta.yaml or the given set
+  shutdown                                      finish running requests and exit`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		server := newAPIServer()
+		serveAPISocket = invocationPath(serveAPISocket)
+		if serveAPISocket == "" {
+			if err := server.Serve(os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not read request: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		os.Remove(serveAPISocket)
+		listener, err := net.Listen("unix", serveAPISocket)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not listen on %s: %v\n", serveAPISocket, err)
+			os.Exit(1)
+		}
+		defer os.Remove(serveAPISocket)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			listener.Close()
+		}()
+		fmt.Fprintf(os.Stderr, "[zephyr] Serving %s on %s\n", strings.Join(server.Methods(), ", "), serveAPISocket)
+		if err := server.ServeListener(listener); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not accept connection: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Start a subshell with the project environment and virtualenv activated",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		shell := os.Getenv("SHELL")
+		if runtime.GOOS == "windows" {
+			shell = os.Getenv("COMSPEC")
+		}
+		if shell == "" {
+			shell = "/bin/sh"
+		}
+		fmt.Printf("[zephyr] Starting %s (exit to leave)\n", shell)
+		_, env := loadProjectEnv()
+		execWithEnv(shell, nil, env)
+	},
+}
+
+var venvCmd = &cobra.Command{
+	Use:   "venv",
+	Short: "Manage virtual environments",
+}
+
+var envsCmd = &cobra.Command{
+	Use:   "envs",
+	Short: "Manage per-interpreter project environments",
+}
+
+var envsMatrixCmd = &cobra.Command{
+	Use:   "matrix [task] [-- args...]",
+	Short: "Sync and run a task in one environment per Python version of the buildmeta matrix",
+	Long: `Create an environment under .zephyr/envs for every interpreter listed in the
+matrix section of buildmeta.yaml, install zephyr.lock and the dev-dependencies
+into it, and run a task in each, like a lightweight tox:
+
+  matrix:
+    python: ["3.9-3.12"]
+    task: test
+
+The task defaults to the matrix's task, then "test"; an undefined "test" task
+runs pytest. Interpreters are found on PATH, in pyenv, or among those installed
+with zephyr python install. Every environment runs even after a failure, and
+the command fails if any did.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		buildMeta, err := buildmeta.ParseFromDirectory(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
+			os.Exit(1)
+		}
+		pythons, err := buildMeta.Matrix.Pythons()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not read the matrix: %v\n", err)
+			os.Exit(1)
+		}
+		if len(envsMatrixPythons) > 0 {
+			pythons = envsMatrixPythons
+		}
+		if len(pythons) == 0 {
+			fmt.Fprintln(os.Stderr, "[zephyr] Error: No Python versions in the buildmeta.yaml matrix")
+			fmt.Fprintln(os.Stderr, "Add them with:\n  matrix:\n    python: [\"3.9-3.12\"]")
+			os.Exit(1)
+		}
+		task := buildMeta.Matrix.Task
+		if len(args) > 0 {
+			task, args = args[0], args[1:]
+		}
+		if task == "" {
+			task = "test"
+		}
+		lockManager := installer.NewLockfileManager(".")
+		lockfile, err := lockManager.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Create it first with: zephyr lock")
+			os.Exit(1)
+		}
+		scripts := make(map[string]buildmeta.Task, len(buildMeta.Scripts)+1)
+		for name, script := range buildMeta.Scripts {
+			scripts[name] = script
+		}
+		if _, ok := scripts[task]; !ok && task == "test" {
+			scripts[task] = buildmeta.Task{Cmd: "pytest"}
+		}
+		_, _, requires := projectPythonRequest()
+
+		results := make([]string, 0, len(pythons))
+		failed := false
+		for _, v := range pythons {
+			fmt.Printf("\n[zephyr] === Python %s ===\n", v)
+			if err := runMatrixEnv(buildMeta, lockfile, scripts, v, requires, task, args); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Python %s: %v\n", v, err)
+				results = append(results, fmt.Sprintf("  ❌ %-8s %v", v, err))
+				failed = true
+				continue
+			}
+			results = append(results, fmt.Sprintf("  ✅ %-8s %s passed", v, task))
+		}
+		fmt.Println("\n[zephyr] Matrix results:")
+		for _, line := range results {
+			fmt.Println(line)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var venvCreateCmd = &cobra.Command{
+	Use:   "create [path]",
+	Short: "Create a new virtual environment",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		venvPath := ".venv"
+		if len(args) > 0 {
+			venvPath = invocationPath(args[0])
+		}
+		request, source, requires := projectPythonRequest()
+		if venvPython != "" {
+			request, source = venvPython, "--python"
+		}
+		python, pythonVersion, err := installer.FindPython(request, requires)
+		if err != nil {
+			// Fall back to an interpreter installed with zephyr python install
+			managed, findErr := interpreters.NewManager().Find(request, requires)
+			if findErr != nil || managed == nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not find an interpreter: %v\n", err)
+				hint := strings.TrimPrefix(request, "python")
+				if hint == "" {
+					hint = "<version>"
+				}
+				fmt.Fprintf(os.Stderr, "Install one with: zephyr python install %s\n", hint)
+				os.Exit(1)
+			}
+			python, pythonVersion = managed.Executable(), managed.Version
+		}
+		if request != "" {
+			fmt.Printf("[zephyr] Using Python %s from %s (requested by %s)\n", pythonVersion, python, source)
+		} else {
+			fmt.Printf("[zephyr] Using Python %s from %s\n", pythonVersion, python)
+		}
+		venv := installer.NewVirtualEnvironment(venvPath)
+		if err := venv.CreateWithPython(python); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not create virtual environment: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Created virtual environment at %s\n", venvPath)
+		fmt.Println("\nTo activate:")
+		if venvPath == ".venv" {
+			fmt.Println("  source .venv/bin/activate  # Linux/macOS")
+			fmt.Println("  .venv\\Scripts\\activate     # Windows")
+		} else {
+			fmt.Printf("  source %s/bin/activate\n", venvPath)
+		}
+	},
+}
+
+var venvInstallCmd = &cobra.Command{
+	Use:   "install [venv-path]",
+	Short: "Install dependencies into virtual environment",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		venvPath := ".venv"
+		if len(args) > 0 {
+			venvPath = invocationPath(args[0])
+		}
+		fmt.Printf("[zephyr] Installing dependencies into %s...\n", venvPath)
+		venv := installer.NewVirtualEnvironment(venvPath)
+		if !venv.Exists() {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Virtual environment does not exist at %s\n", venvPath)
+			fmt.Fprintln(os.Stderr, "Create it first with: zephyr venv create")
+			os.Exit(1)
+		}
+		defer lockVenv(venvPath).Release()
+		warnVenvPython(venvPath)
+		lockManager := installer.NewLockfileManager(".")
+		lockfile, err := lockManager.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+			os.Exit(1)
+		}
+		wheelInstaller := newWheelInstaller(venvPath)
+		err = installLockfile(wheelInstaller, lockfile)
+		recordInstalls("venv install", venvPath, wheelInstaller, err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not install %v\n", err)
+			printRemedy(err)
+			os.Exit(1)
+		}
+		pinLockfileHashes(lockManager, wheelInstaller)
+		fmt.Printf("[zephyr] ✅ All packages installed into %s!\n", venvPath)
+	},
+}
+
+var venvListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List available virtual environments",
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := os.Stat(".venv"); err == nil {
+			fmt.Println(".venv (default)")
+		} else {
+			fmt.Println("No virtual environments found.")
+		}
+	},
+}
+
+var venvActivateCmd = &cobra.Command{
+	Use:   "activate [venv-path]",
+	Short: "Print activation instructions for a virtual environment",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		venvPath := ".venv"
+		if len(args) > 0 {
+			venvPath = invocationPath(args[0])
+		}
+		if _, err := os.Stat(venvPath); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Virtual environment does not exist at %s\n", venvPath)
+			os.Exit(1)
+		}
+		// The instructions are run from where zephyr was invoked
+		venvPath = displayPath(venvPath)
+		fmt.Println("To activate:")
+		fmt.Printf("  source %s/bin/activate  # Linux/macOS\n", venvPath)
+		fmt.Printf("  %s\\Scripts\\activate     # Windows\n", venvPath)
+	},
+}
+
+var searchCmd = &cobra.Command{
+	Use:     "search [query]",
+	Aliases: []string{"show"},
+	Short:   "Search for packages on PyPI",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		query := args[0]
+		client := pypi.NewPyPIClient()
+		metadata, err := client.FetchPackageMetadata(query)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not search for package: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("📦 %s %s\n", metadata.Info.Name, metadata.Info.Version)
+		fmt.Printf("📝 %s\n", metadata.Info.Summary)
+		if metadata.Info.Author != "" {
+			fmt.Printf("👤 Author: %s\n", metadata.Info.Author)
+		}
+		if searchDownloads {
+			if stats, err := pypi.NewStatsClient().FetchRecentDownloads(metadata.Info.Name); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not fetch download stats: %v\n", err)
+			} else {
+				fmt.Printf("📈 Downloads last month: %s\n", formatCount(stats.LastMonth))
+			}
+		}
+		links := pypi.ProjectLinks(metadata.Info)
+		for _, link := range links {
+			fmt.Printf("🔗 %s: %s\n", link.Label, link.URL)
+		}
+		history := pypi.VersionHistory(metadata)
+		stable, prerelease := pypi.LatestVersions(history)
+		if stable != "" {
+			fmt.Printf("\n⭐ Latest stable: %s\n", stable)
+		}
+		if prerelease != "" {
+			fmt.Printf("🧪 Latest pre-release: %s\n", prerelease)
+		}
+		fmt.Println("\nAvailable versions:")
+		for _, release := range history {
+			line := fmt.Sprintf("  %-16s", release.Version)
+			if !release.UploadTime.IsZero() {
+				line += " " + release.UploadTime.Format("2006-01-02")
+			}
+			switch {
+			case release.Version == stable:
+				line += "  (latest)"
+			case release.Version == prerelease:
+				line += "  (pre-release, latest)"
+			case release.Prerelease:
+				line += "  (pre-release)"
+			}
+			if release.Yanked {
+				line += "  (yanked)"
+			}
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+	},
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit project dependencies",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !auditUnmaintained {
+			fmt.Fprintln(os.Stderr, "[zephyr] Error: No audit checks selected. Use --unmaintained.")
+			os.Exit(1)
+		}
+		buildMeta, err := buildmeta.ParseFromDirectory(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
+			os.Exit(1)
+		}
+		client := pypi.NewPyPIClient()
+		stats := pypi.NewStatsClient()
+		maxAge := time.Duration(auditMaxAgeDays) * 24 * time.Hour
+		deps := buildMeta.GroupDependencies(selectedGroups(buildMeta))
+		names := make([]string, 0, len(deps))
+		for name := range deps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		flagged := 0
+		for _, name := range names {
+			metadata, err := client.FetchPackageMetadata(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not fetch metadata for %s: %v\n", name, err)
+				continue
+			}
+			report := pypi.CheckMaintenance(metadata, maxAge, time.Now())
+			status := "✅"
+			if report.Unmaintained {
+				status = "⚠️ "
+				flagged++
+			}
+			line := fmt.Sprintf("%s %s %s", status, name, report.LatestVersion)
+			if !report.LastRelease.IsZero() {
+				line += fmt.Sprintf(" (last release %s)", report.LastRelease.Format("2006-01-02"))
+			}
+			if auditDownloads {
+				if recent, err := stats.FetchRecentDownloads(name); err == nil {
+					line += fmt.Sprintf(", %s downloads/month", formatCount(recent.LastMonth))
+				}
+			}
+			fmt.Println(line)
+		}
+		if flagged > 0 {
+			fmt.Printf("\n%d dependencies have had no release in the last %d days.\n", flagged, auditMaxAgeDays)
+			os.Exit(1)
+		}
+		fmt.Println("\nAll dependencies are actively maintained.")
+	},
+}
+
+var auditLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show the installs and uninstalls recorded in this project's environments",
+	Long: `Show the append-only audit log in .zephyr/audit.log: every install,
+uninstall and sync, who ran it and when, and the packages and artifact hashes
+it changed. With --json, print the raw JSON lines for other tools.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		log := auditlog.Open(".")
+		entries, err := log.Entries()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not read audit log: %v\n", err)
+			os.Exit(1)
+		}
+		if auditLogLimit > 0 && len(entries) > auditLogLimit {
+			entries = entries[len(entries)-auditLogLimit:]
+		}
+		if len(entries) == 0 {
+			fmt.Printf("No changes recorded in %s\n", log.Path)
+			return
+		}
+		for _, entry := range entries {
+			if auditLogJSON {
+				line, _ := json.Marshal(entry)
+				fmt.Println(string(line))
+			} else {
+				fmt.Println(entry)
+			}
+		}
+	},
+}
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [package[==version] | file.whl]",
+	Short: "Show index metadata for a package version, or the metadata of a local wheel, without installing it",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if strings.HasSuffix(args[0], ".whl") {
+			if _, err := os.Stat(invocationPath(args[0])); err == nil {
+				core, err := pypi.ReadWheelCoreMetadata(invocationPath(args[0]))
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not read wheel metadata: %v\n", err)
+					os.Exit(1)
+				}
+				printCoreMetadata(core)
+				return
+			}
+		}
+		name, version := args[0], ""
+		if idx := strings.Index(name, "=="); idx >= 0 {
+			name, version = name[:idx], name[idx+2:]
+		}
+		client := pypi.NewPyPIClient()
+		var metadata *pypi.PyPIMetadata
+		var err error
+		if version != "" {
+			metadata, err = client.FetchVersionMetadata(name, version)
+		} else {
+			metadata, err = client.FetchPackageMetadata(name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not fetch metadata for %s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		printCoreMetadata(metadata.Info.CoreMetadata())
+		fmt.Println("\nArtifacts:")
+		if len(metadata.URLs) == 0 {
+			fmt.Println("  (none)")
+		}
+		for _, release := range metadata.URLs {
+			fmt.Printf("  %s\n", release.Filename)
+			details := []string{release.Packagetype, formatSize(release.Size)}
+			if wheel, err := pypi.ParseWheelFilename(release.Filename); err == nil {
+				details = append(details, "tags "+wheel.TagString())
+			}
+			if !release.UploadTime.IsZero() {
+				details = append(details, "uploaded "+release.UploadTime.Format("2006-01-02 15:04"))
+			}
+			fmt.Printf("    %s\n", strings.Join(details, ", "))
+			if release.Yanked {
+				reason := release.YankedReason
+				if reason == "" {
+					reason = "no reason given"
+				}
+				fmt.Printf("    ⚠️  yanked: %s\n", reason)
+			}
+		}
+	},
+}
+
+var solveCmd = &cobra.Command{
+	Use:   "solve",
+	Short: "Solve dependencies using Pubgrub algorithm",
+	Run: func(cmd *cobra.Command, args []string) {
+		s := newSolver("example", "1.0.0")
+		dependencies := map[string]string{
+			"requests": ">=2.25.0",
+			"urllib3":  ">=1.26.0",
+			"certifi":  ">=2020.12.0",
+		}
+		addRootDependencies(s, dependencies)
+		solution := solve(s)
+		fmt.Println("✅ Dependencies solved successfully!")
+		fmt.Println("\nSolution:")
+		for _, assignment := range solution.Assignments {
+			if assignment.IsDecision {
+				fmt.Printf("  %s == %s\n", assignment.Term.Package, assignment.Term.Version.String())
+			}
+		}
+	},
+}
+
+var demoCmd = &cobra.Command{
+	Use:   "demo",
+	Short: "Run Pubgrub algorithm demo",
+	Run: func(cmd *cobra.Command, args []string) {
+		solver.ExampleConflictResolution()
+	},
+}
+
+var examplesCmd = &cobra.Command{
+	Use:   "examples",
+	Short: "Show Pubgrub algorithm examples",
+	Run: func(cmd *cobra.Command, args []string) {
+		solver.RunAllExamples()
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import dependencies from requirements.txt or pyproject.toml",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		file := invocationPath(args[0])
+		if strings.HasSuffix(file, ".txt") {
+			reqs, err := buildmeta.ParseRequirementsFile(file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not parse requirements.txt: %v\n", err)
+				os.Exit(1)
+			}
+			buildMeta, err := buildmeta.ParseFromDirectory(".")
+			if err != nil {
+				buildMeta = buildmeta.NewBuildMeta("imported-project", "0.1.0")
+			}
+			for name, constraint := range reqs {
+				buildMeta.AddDependency(name, constraint)
+			}
+			if !noDetectFlag {
+				applyDetectedInfo(buildMeta)
+			}
+			if err := buildmeta.WriteToDirectory(".", buildMeta); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not save buildmeta.yaml: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("✅ Imported dependencies from requirements.txt into buildmeta.yaml")
+		} else if strings.HasSuffix(file, ".toml") {
+			pyMeta, err := buildmeta.ParsePyProjectToml(file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not parse pyproject.toml: %v\n", err)
+				os.Exit(1)
+			}
+			buildMeta := buildmeta.NewBuildMeta(pyMeta.Name, pyMeta.Version)
+			for name, constraint := range pyMeta.Dependencies {
+				buildMeta.AddDependency(name, constraint)
+			}
+			if !noDetectFlag {
+				applyDetectedInfo(buildMeta)
+			}
+			if err := buildmeta.WriteToDirectory(".", buildMeta); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not save buildmeta.yaml: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("✅ Imported dependencies from pyproject.toml into buildmeta.yaml")
+		} else {
+			fmt.Fprintln(os.Stderr, "[zephyr] Error: Unsupported file type. Use requirements.txt or pyproject.toml.")
+			os.Exit(1)
+		}
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export dependencies to requirements.txt or pyproject.toml",
+	Long: `Export the dependencies in buildmeta.yaml to requirements.txt or pyproject.toml.
+
+With --split, write the versions pinned in zephyr.lock to one requirements file
+per part instead, named after the given file: --split direct,transitive with
+requirements.txt writes requirements-direct.txt and requirements-transitive.txt.
+A Dockerfile can then install the rarely changing transitive pins in an
+earlier, cached layer:
+
+  COPY requirements-transitive.txt .
+  RUN pip install --no-deps -r requirements-transitive.txt
+  COPY requirements-direct.txt .
+  RUN pip install --no-deps -r requirements-direct.txt
+
+With --format nix or --format bazel, describe every artifact in zephyr.lock with
+its URL and sha256 instead: a Nix expression taking fetchurl (e.g. deps.nix) or
+a .bzl file whose zephyr_dependencies() macro declares an http_file repository
+per artifact (e.g. python_deps.bzl). Index packages are looked up on PyPI.
+
+Requirements files cover the main dependencies. --group dev (or an
+optional-dependencies group) adds a group, --only selects exactly the groups
+named and --without leaves one out; locked output then keeps only those
+groups' dependencies and what they require, so production artifacts can
+exclude dev and test tooling.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		file := invocationPath(args[0])
+		buildMeta, err := buildmeta.ParseFromDirectory(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
+			os.Exit(1)
+		}
+		groups := selectedGroups(buildMeta)
+		if exportSplit != "" {
+			exportSplitRequirements(file, buildMeta, groups)
+			return
+		}
+		if exportFormat == installer.ExportNix || exportFormat == installer.ExportBazel {
+			exportBuildSystem(file, buildMeta, groups)
+			return
+		}
+		if strings.HasSuffix(file, ".toml") && groupsSelected() {
+			fmt.Fprintln(os.Stderr, "[zephyr] Error: pyproject.toml keeps every dependency group; --group, --only and --without apply to requirements files and --format nix or bazel")
+			os.Exit(1)
+		}
+		if strings.HasSuffix(file, ".txt") {
+			if err := buildmeta.ExportRequirementsFile(file, buildMeta.GroupDependencies(groups)); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not write requirements.txt: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("✅ Exported dependencies to requirements.txt")
+		} else if strings.HasSuffix(file, ".toml") && exportFormat != "" {
+			format, err := buildmeta.ParseExportFormat(exportFormat)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+				os.Exit(1)
+			}
+			var sources []buildmeta.PackageSource
+			if indexURL := netutil.GetPyPIBaseURL(); indexURL != netutil.DefaultPyPIBaseURL {
+				sources = append(sources, buildmeta.PackageSource{Name: "zephyr", URL: indexURL})
+			}
+			if err := buildmeta.ExportPyProjectFormat(file, buildMeta, format, sources); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not write pyproject.toml: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("✅ Exported dependencies to %s (%s format)\n", file, format)
+		} else if strings.HasSuffix(file, ".toml") {
+			if err := buildmeta.ExportPyProjectToml(file, buildMeta); err != nil {
+				fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not write pyproject.toml: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("✅ Exported dependencies to pyproject.toml")
+		} else {
+			fmt.Fprintln(os.Stderr, "[zephyr] Error: Unsupported file type. Use requirements.txt or pyproject.toml.")
+			os.Exit(1)
+		}
+	},
+}
+
+// Enhance init to optionally create pyproject.toml
+var pyprojectFlag bool
+
+// noDetectFlag stops init and import from filling metadata from git and LICENSE
+var noDetectFlag bool
+
+// exportFormat selects the pyproject.toml flavour written by export
+var exportFormat string
+
+// exportSplit lists the lock parts zephyr export writes to separate pinned files
+var exportSplit string
+
+// Dependency groups export and audit cover: main unless these are given
+var (
+	groupWith    []string
+	groupOnly    []string
+	groupWithout []string
+)
+
+// Download statistics and maintenance audit options
+var (
+	searchDownloads   bool
+	auditUnmaintained bool
+	auditDownloads    bool
+	auditMaxAgeDays   int
+)
+
+// Audit log output options
+var (
+	auditLogJSON  bool
+	auditLogLimit int
+)
+
+// lockTargets lists the os-arch-python targets lock resolves artifacts for
+var lockTargets []string
+
+// Update policy overrides
+var (
+	updatePolicy   string
+	updateSecurity bool
+)
+
+// lockCheck verifies zephyr.lock instead of rewriting it
+var lockCheck bool
+
+// Hook installation options
+var (
+	hookNames []string
+	hookTasks []string
+	hookForce bool
+)
+
+// maxIterations bounds the solver before it aborts with a diagnostic dump
+var maxIterations int
+
+// maxResolveTime bounds the wall-clock time of dependency resolution
+var maxResolveTime = solver.DefaultTimeout
+
+// showTimings prints where install, sync and lock spent their time
+var showTimings bool
+
+// minimizeTarget names what the solver minimizes among valid solutions
+var minimizeTarget string
+
+// conflictReportPath receives a JSON description of the conflict when resolution fails
+var conflictReportPath string
+
+// solverSeed reorders the solver's ties between packages; 0 orders them by name
+var solverSeed int64
+
+// Build options
+var (
+	buildWheel     bool
+	buildSdist     bool
+	buildOutDir    string
+	buildPythonTag string
+	buildPlatName  string
+)
+
+// Pack options
+var (
+	packFormat     string
+	packEntryPoint string
+	packPython     string
+	packOutput     string
+)
+
+// envFiles are extra .env files applied by run and shell, later files winning
+var envFiles []string
+
+// infoFiles lists the installed files in zephyr info
+var infoFiles bool
+
+// venvPython is the interpreter version zephyr venv create uses instead of .python-version
+var venvPython string
+
+// lockExcludeNewer is the upload cutoff zephyr lock resolves against
+var lockExcludeNewer string
+
+// Cross-platform targets for lock and download, as pip-style wheel platform tags
+var (
+	lockPlatforms         []string
+	lockPythonVersion     string
+	downloadPlatforms     []string
+	downloadPythonVersion string
+)
+
+// lockOutput names extra output zephyr lock prints once locked; "urls" lists the chosen artifacts
+var lockOutput string
+
+// lockFormat is the format of the lock --output listing, json or csv
+var lockFormat string
+
+// treeFormat is how zephyr tree prints: text or pipdeptree-json
+var treeFormat string
+
+// authUsername is stored with the token by zephyr auth login
+var authUsername string
+
+// cachePruneMaxAge evicts cache entries unused for longer than this
+var cachePruneMaxAge string
+
+// cachePruneMaxSize evicts least recently used cache entries until the cache fits
+var cachePruneMaxSize string
+
+// cachePruneDryRun lists what zephyr cache prune would remove without removing it
+var cachePruneDryRun bool
+
+// Publish options
+var (
+	publishRepositoryURL string
+	publishToken         string
+	publishRepository    string
+	publishTrusted       string
+	publishCheckOnly     bool
+)
+
+// installHash is the sha256 a wheel given to zephyr install by path or URL must match
+var installHash string
+
+// bugReportOutput is where zephyr bug-report writes its tarball
+var bugReportOutput string
+
+// limitRate caps the combined download speed, overriding limit_rate in config
+var limitRate string
+
+// maxParallelDownloads bounds concurrent downloads, overriding max_parallel_downloads in config
+var maxParallelDownloads int
+
+// serveAPISocket is the unix socket serve-api listens on instead of stdio
+var serveAPISocket string
+
+// watchDebounce is how long watched files must stay unchanged before syncing
+var watchDebounce time.Duration
+
+// offlineMode refuses every network connection
+var offlineMode bool
+
+// projectDir is where zephyr runs as if started there, like git -C
+var projectDir string
+
+// profileName selects a configuration profile, such as dev or prod
+var profileName string
+
+// manifestPath selects the project manifest, and with it the project
+// directory and lockfile, instead of finding buildmeta.yaml
+var manifestPath string
+
+// invocationDir is the directory relative paths given on the command line
+// are resolved against: the working directory, or -C when given
+var invocationDir string
+
+// syncVerifyOnly checks .venv against the lockfile instead of installing
+var syncVerifyOnly bool
+
+// syncPrefix, syncRoot and syncPython install the lockfile into a prefix,
+// staged under a DESTDIR, for an interpreter instead of into .venv
+var (
+	syncPrefix string
+	syncRoot   string
+	syncPython string
+)
+
+// pruneAnalyze finds declared dependencies the project never imports
+var pruneAnalyze bool
+
+// pruneRemove deletes the unused dependencies pruneAnalyze finds from buildmeta.yaml
+var pruneRemove bool
+
+// sizeTop limits how many packages and chains zephyr size lists
+var sizeTop int
+
+// repairDryRun lists what zephyr repair would change without changing it
+var repairDryRun bool
+
+// reinstallAll reinstalls every locked package
+var reinstallAll bool
+
+// syncSmokeTest imports the installed packages' modules after sync
+var syncSmokeTest bool
+
+// syncPrune uninstalls packages the lockfile does not lock after sync
+var syncPrune bool
+
+// syncKeep names packages --prune leaves installed, with their dependencies
+var syncKeep []string
+
+// checkSharedLibs scans newly installed extension modules for missing shared
+// libraries after install and sync
+var checkSharedLibs bool
+
+// checkImports makes zephyr check look for imports no declared dependency
+// provides instead of checking requirements
+var checkImports bool
+
+// checkSharedLibsOnly makes zephyr check scan for missing shared libraries
+// instead of checking requirements
+var checkSharedLibsOnly bool
+
+// envsMatrixPythons overrides the matrix's Python versions for zephyr envs matrix
+var envsMatrixPythons []string
+
+// testNoSync skips installing missing dev dependencies before zephyr test
+var testNoSync bool
+
+// runJobs bounds how many independent script dependencies run at once
+var runJobs int
+
+// addConstraintSets lists constraint files, by URL or path, that zephyr add
+// records for every later resolution to be seeded with
+var addConstraintSets []string
+
+// linkMode selects how cached wheels are placed into the environment
+var linkMode string
+
+// allowOverwrite lets installs replace files owned by other packages
+var allowOverwrite bool
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&limitRate, "limit-rate", "", "Cap the combined download speed, e.g. 500K or 10MB/s")
+	rootCmd.PersistentFlags().StringVarP(&projectDir, "directory", "C", "", "Run as if zephyr was started in this directory")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Apply this profile from the config files, e.g. a dev or prod index; also set by ZEPHYR_PROFILE")
+	rootCmd.PersistentFlags().StringVar(&manifestPath, "manifest", "", "Use this manifest instead of buildmeta.yaml; its lockfile is named after it")
+	rootCmd.PersistentFlags().BoolVar(&offlineMode, "offline", false, "Refuse all network access; also set by ZEPHYR_OFFLINE")
+	rootCmd.PersistentFlags().IntVar(&maxParallelDownloads, "max-parallel-downloads", 0, "Download at most this many artifacts at once (0 for no limit)")
+	rootCmd.PersistentFlags().DurationVar(&flock.DefaultTimeout, "lock-timeout", flock.DefaultTimeout, "How long to wait for another zephyr process to release a project, cache or venv lock")
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(addCmd)
+	rootCmd.AddCommand(removeCmd)
+	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(installCmd)
+	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(serveAPICmd)
+	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(buildCmd)
+	rootCmd.AddCommand(packCmd)
+	rootCmd.AddCommand(vendorCmd)
+	rootCmd.AddCommand(downloadCmd)
+	rootCmd.AddCommand(publishCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(shellCmd)
+	rootCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(treeCmd)
+	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(repairCmd)
+	rootCmd.AddCommand(reinstallCmd)
+	rootCmd.AddCommand(sizeCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(uninstallCmd)
+	rootCmd.AddCommand(bugReportCmd)
+	rootCmd.AddCommand(hooksCmd)
+	rootCmd.AddCommand(authCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(pythonCmd)
+	rootCmd.AddCommand(holdCmd)
+	rootCmd.AddCommand(unholdCmd)
+	rootCmd.AddCommand(venvCmd)
+	rootCmd.AddCommand(envsCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(inspectCmd)
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditLogCmd)
+	rootCmd.AddCommand(solveCmd)
+	rootCmd.AddCommand(demoCmd)
+	rootCmd.AddCommand(examplesCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(exportCmd)
+
+	authCmd.AddCommand(authLoginCmd)
+	authCmd.AddCommand(authLogoutCmd)
+	authCmd.AddCommand(authListCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	envsCmd.AddCommand(envsMatrixCmd)
+	pythonCmd.AddCommand(pythonInstallCmd)
+	pythonCmd.AddCommand(pythonListCmd)
+	pythonCmd.AddCommand(pythonUninstallCmd)
+	hooksCmd.AddCommand(hooksInstallCmd)
+	hooksCmd.AddCommand(hooksUninstallCmd)
+
+	venvCmd.AddCommand(venvCreateCmd)
+	venvCmd.AddCommand(venvInstallCmd)
+	venvCmd.AddCommand(venvListCmd)
+	venvCmd.AddCommand(venvActivateCmd)
+
+	addCmd.Flags().StringSliceVar(&addConstraintSets, "with-constraints", nil, "Seed every resolution with the pins in this constraints file (URL or path); recorded in buildmeta.yaml")
+	initCmd.Flags().BoolVar(&pyprojectFlag, "pyproject", false, "Also create pyproject.toml")
+	initCmd.Flags().BoolVar(&noDetectFlag, "no-detect", false, "Do not fill repository, license and author from git and LICENSE")
+	importCmd.Flags().BoolVar(&noDetectFlag, "no-detect", false, "Do not fill repository, license and author from git and LICENSE")
+	searchCmd.Flags().BoolVar(&searchDownloads, "downloads", false, "Show monthly download counts from pypistats.org")
+	auditCmd.Flags().BoolVar(&auditUnmaintained, "unmaintained", false, "Flag dependencies without recent releases")
+	auditCmd.Flags().BoolVar(&auditDownloads, "downloads", false, "Include monthly download counts from pypistats.org")
+	auditCmd.Flags().IntVar(&auditMaxAgeDays, "max-age", 730, "Days without a release before a dependency is considered unmaintained")
+	auditLogCmd.Flags().BoolVar(&auditLogJSON, "json", false, "Print entries as JSON lines")
+	auditLogCmd.Flags().IntVarP(&auditLogLimit, "limit", "n", 0, "Show only the most recent entries")
+	for _, c := range []*cobra.Command{exportCmd, auditCmd} {
+		c.Flags().StringSliceVar(&groupWith, "group", nil, "Also cover this dependency group: dev or an optional-dependencies group (repeatable)")
+		c.Flags().StringSliceVar(&groupOnly, "only", nil, "Cover only this dependency group, e.g. main (repeatable)")
+		c.Flags().StringSliceVar(&groupWithout, "without", nil, "Leave out this dependency group (repeatable)")
+	}
+	exportCmd.Flags().StringVar(&exportSplit, "split", "", "Write the locked pins to one requirements file per part: direct, transitive or both, e.g. direct,transitive")
+	exportCmd.Flags().StringVar(&exportFormat, "format", "", "pyproject.toml flavour to write (poetry, pep621 or uv), or a build system to describe zephyr.lock for (nix or bazel)")
+	lockCmd.Flags().StringSliceVar(&lockTargets, "target", nil, "Resolve artifacts for os-arch-python targets, e.g. linux-x86_64-3.11 (repeatable)")
+	lockCmd.Flags().StringSliceVar(&lockPlatforms, "platform", nil, "Resolve artifacts for a wheel platform tag, e.g. manylinux2014_x86_64 (repeatable)")
+	lockCmd.Flags().StringVar(&lockPythonVersion, "python-version", "", "Python version for --platform targets, e.g. 3.10; alone, targets this platform")
+	downloadCmd.Flags().StringSliceVar(&downloadPlatforms, "platform", nil, "Download wheels for a wheel platform tag, e.g. manylinux2014_x86_64 (repeatable)")
+	downloadCmd.Flags().StringVar(&downloadPythonVersion, "python-version", "", "Python version to download wheels for, e.g. 3.10; alone, targets this platform")
+	updateCmd.Flags().StringVar(&updatePolicy, "policy", "", "Override the update policy for this run: latest, minor, patch or security")
+	updateCmd.Flags().BoolVar(&updateSecurity, "security", false, "Only update packages with known advisories, to the lowest fixed release")
+	venvCreateCmd.Flags().StringVar(&venvPython, "python", "", "Python version to create the environment with, e.g. 3.12 (default from .python-version)")
+	lockCmd.Flags().StringVar(&lockExcludeNewer, "exclude-newer", "", "Ignore files uploaded after this date or RFC 3339 time, e.g. 2024-06-01; recorded in zephyr.lock and reused by later locks")
+	lockCmd.Flags().StringVar(&lockOutput, "output", "", "Also print the artifact URL and sha256 chosen for each locked package: urls")
+	treeCmd.Flags().StringVar(&treeFormat, "format", "text", "Output format: text or pipdeptree-json")
+	lockCmd.Flags().StringVar(&lockFormat, "format", "json", "Format of the --output listing: json or csv")
+	lockCmd.Flags().BoolVar(&lockCheck, "check", false, "Exit non-zero if zephyr.lock does not match a fresh resolution, without writing it")
+	hooksInstallCmd.Flags().StringSliceVar(&hookNames, "hook", []string{"pre-commit"}, "Hooks to install: pre-commit, pre-push (repeatable)")
+	hooksInstallCmd.Flags().StringArrayVar(&hookTasks, "task", nil, "Also run this buildmeta script from the hook (repeatable)")
+	hooksInstallCmd.Flags().BoolVar(&hookForce, "force", false, "Replace existing hooks not written by zephyr")
+	buildCmd.Flags().BoolVar(&buildWheel, "wheel", false, "Build only the wheel")
+	buildCmd.Flags().BoolVar(&buildSdist, "sdist", false, "Build only the sdist")
+	buildCmd.Flags().StringVarP(&buildOutDir, "out-dir", "o", "dist", "Directory to write artifacts to")
+	publishCmd.Flags().StringVar(&publishRepositoryURL, "repository-url", publish.DefaultRepositoryURL, "Upload endpoint of the index, e.g. https://test.pypi.org/legacy/")
+	publishCmd.Flags().StringVar(&publishRepository, "repository", "", "Upload target named in the repositories section of the config")
+	publishCmd.Flags().StringVar(&publishToken, "token", "", "API token (default $ZEPHYR_PUBLISH_TOKEN)")
+	publishCmd.Flags().BoolVar(&publishCheckOnly, "check-only", false, "Check the distributions without uploading them")
+	publishCmd.Flags().StringVar(&publishTrusted, "trusted-publishing", "auto", "Exchange a CI OIDC token for an API token: auto (in CI without a token), always or never")
+	buildCmd.Flags().StringVar(&buildPythonTag, "python-tag", "", "Python tag of the wheel, e.g. py2.py3 or cp312 (default py3)")
+	buildCmd.Flags().StringVar(&buildPlatName, "plat-name", "", "Platform tag of the wheel, e.g. linux_x86_64 (default any)")
+	packCmd.Flags().StringVar(&packFormat, "format", "zipapp", "Archive layout: zipapp or pex-like")
+	packCmd.Flags().StringVarP(&packEntryPoint, "entry-point", "e", "", "module:function (or module) to run; defaults to the only console script")
+	packCmd.Flags().StringVar(&packPython, "python", "/usr/bin/env python3", "Interpreter for the shebang line")
+	packCmd.Flags().StringVarP(&packOutput, "output", "o", "", "Output path (default dist/<name>.pyz)")
+	runCmd.Flags().SetInterspersed(false)
+	testCmd.Flags().SetInterspersed(false)
+	authLoginCmd.Flags().StringVar(&authUsername, "username", credentials.DefaultUsername, "Username sent with the token")
+	cachePruneCmd.Flags().StringVar(&cachePruneMaxAge, "max-age", "", "Evict entries not used for this long, e.g. 30d, 2w or 12h (default cache_max_age)")
+	cachePruneCmd.Flags().StringVar(&cachePruneMaxSize, "max-size", "", "Evict least recently used entries until the cache fits, e.g. 5GB (default cache_max_size)")
+	cachePruneCmd.Flags().BoolVar(&cachePruneDryRun, "dry-run", false, "List the entries that would be removed without removing them")
+	installCmd.Flags().StringVar(&installHash, "hash", "", "Expected sha256 of the wheel given by path or URL (hex, optionally prefixed with sha256:)")
+	serveAPICmd.Flags().StringVar(&serveAPISocket, "socket", "", "Listen on this unix socket path instead of stdin/stdout")
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", watch.DefaultDebounce, "How long files must stay unchanged before re-locking")
+	pruneCmd.Flags().BoolVar(&pruneAnalyze, "analyze", false, "Scan the project's imports for dependencies that are never used")
+	pruneCmd.Flags().BoolVar(&pruneRemove, "remove", false, "Remove the unused dependencies from buildmeta.yaml")
+	sizeCmd.Flags().IntVar(&sizeTop, "top", 20, "List only the largest N packages and chains (0 for all)")
+	repairCmd.Flags().BoolVar(&repairDryRun, "dry-run", false, "List the repairs without making them")
+	reinstallCmd.Flags().BoolVar(&reinstallAll, "all", false, "Reinstall every package in zephyr.lock")
+	syncCmd.Flags().BoolVar(&syncSmokeTest, "smoke-test", false, "After installing, import each installed package's top-level modules and fail if any cannot be imported")
+	syncCmd.Flags().BoolVar(&syncPrune, "prune", false, "After installing, uninstall every package in .venv that zephyr.lock does not lock")
+	syncCmd.Flags().StringSliceVar(&syncKeep, "keep", nil, "Package --prune leaves installed along with its dependencies (repeatable); dev-dependencies are always kept")
+	syncCmd.Flags().BoolVar(&syncVerifyOnly, "verify-only", false, "Check .venv matches zephyr.lock and RECORD hashes without changing anything or using the network")
+	syncCmd.Flags().StringVar(&syncPrefix, "prefix", "", "Install into this absolute prefix (bin, lib/pythonX.Y/site-packages) instead of .venv")
+	syncCmd.Flags().StringVar(&syncRoot, "root", "", "With --prefix, stage the files under this directory (DESTDIR) for packaging")
+	syncCmd.Flags().StringVar(&syncPython, "python", "", "With --prefix, the interpreter path or version scripts run with (default: the project's Python)")
+	for _, c := range []*cobra.Command{installCmd, syncCmd} {
+		c.Flags().BoolVar(&checkSharedLibs, "check-shared-libs", false, "After installing, scan extension modules for shared libraries the system lacks")
+	}
+	checkCmd.Flags().BoolVar(&checkImports, "imports", false, "Find project imports that no declared dependency provides instead")
+	checkCmd.Flags().BoolVar(&checkSharedLibsOnly, "shared-libs", false, "Scan extension modules in .venv for missing shared libraries instead")
+	infoCmd.Flags().BoolVarP(&infoFiles, "files", "f", false, "List the files recorded for the distribution")
+	bugReportCmd.Flags().StringVarP(&bugReportOutput, "output", "o", "", "Tarball to write (default zephyr-bug-report-<time>.tar.gz)")
+	envsMatrixCmd.Flags().StringSliceVar(&envsMatrixPythons, "python", nil, "Python versions to run instead of the matrix's, e.g. --python 3.11,3.12")
+	envsMatrixCmd.Flags().SetInterspersed(false)
+	testCmd.Flags().BoolVar(&testNoSync, "no-sync", false, "Do not install missing dev dependencies first")
+	runCmd.Flags().IntVarP(&runJobs, "jobs", "j", 0, "Maximum number of independent script dependencies to run in parallel (default: number of CPUs)")
+	for _, c := range []*cobra.Command{runCmd, shellCmd, testCmd} {
+		c.Flags().StringArrayVar(&envFiles, "env-file", nil, "Load variables from this file, overriding all other sources (repeatable)")
+	}
+	for _, c := range []*cobra.Command{installCmd, syncCmd, lockCmd} {
+		c.Flags().BoolVar(&showTimings, "timings", false, "Print requests made, bytes downloaded, cache hits and the time spent per phase")
+	}
+	for _, c := range []*cobra.Command{installCmd, lockCmd, solveCmd} {
+		c.Flags().IntVar(&maxIterations, "max-iterations", solver.DefaultMaxIterations, "Abort dependency resolution after this many solver steps (0 for no limit)")
+		c.Flags().DurationVar(&maxResolveTime, "max-resolve-time", solver.DefaultTimeout, "Stop dependency resolution after this long and report the partial solution, e.g. 60s (0 for no limit)")
+		c.Flags().StringVar(&minimizeTarget, "minimize", "", "Prefer the valid solution with the fewest packages, avoiding optional extras (packages)")
+		c.Flags().StringVar(&conflictReportPath, "conflict-report", "", "When resolution fails, also write the conflict as JSON to this file (- for stdout)")
+		c.Flags().Int64Var(&solverSeed, "seed", 0, "Break ties between packages in a fixed pseudo-random order instead of by name; the same seed always resolves the same way")
+	}
+	for _, c := range []*cobra.Command{installCmd, syncCmd, venvInstallCmd} {
+		c.Flags().StringVar(&linkMode, "link-mode", "copy", "How to place cached wheel files into the environment: copy, hardlink or clone")
+		c.Flags().BoolVar(&allowOverwrite, "allow-overwrite", false, "Let packages replace files owned by other installed packages, recording the new owner")
+	}
+}
+
+// platformTargets turns --platform tags and a --python-version into targets.
+// The Python version defaults to the project's, and a version alone targets
+// this machine's platform.
+func platformTargets(platforms []string, python string) ([]markers.Target, error) {
+	if len(platforms) == 0 && python == "" {
+		return nil, nil
+	}
+	if python == "" {
+		python = projectPythonMinor()
+	}
+	if len(platforms) == 0 {
+		target, err := markers.HostTarget(python)
+		if err != nil {
+			return nil, err
+		}
+		return []markers.Target{target}, nil
+	}
+	targets := make([]markers.Target, 0, len(platforms))
+	for _, platform := range platforms {
+		target, err := markers.ParsePlatformTag(platform, python)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// sortedPackageNames returns the names of locked packages in a stable order
+func sortedPackageNames(packages map[string]installer.LockPackage) []string {
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// solve runs the solver, saving its final state for zephyr bug-report, and
+// exits when resolution fails
+func solve(s *solver.Solver) *solver.PartialSolution {
+	started := time.Now()
+	solution, err := s.Solve()
+	resolveTime += time.Since(started)
+	trace := s.DumpState()
+	if err != nil {
+		trace = fmt.Sprintf("Resolution failed: %v\n%s", err, trace)
+		debugLog.Printf("resolution failed: %v", err)
+	} else {
+		debugLog.Printf("resolution succeeded")
+	}
+	bugreport.WriteSolverTrace(bugreport.DefaultLogDir(), trace)
+	if err != nil {
+		reportSolveFailure(err)
+	}
+	return solution
+}
+
+// printTimings reports the time spent resolving and, when wheelInstaller is
+// set, downloading and extracting, with the HTTP traffic of the command
+func printTimings(wheelInstaller *installer.WheelInstaller) {
+	metrics := netutil.Metrics()
+	fmt.Fprintln(os.Stderr, "[zephyr] Timings:")
+	fmt.Fprintf(os.Stderr, "  resolve   %s\n", resolveTime.Round(time.Millisecond))
+	if wheelInstaller != nil {
+		timings := wheelInstaller.Timings()
+		fmt.Fprintf(os.Stderr, "  download  %s (%d cache hits)\n", timings.Download.Round(time.Millisecond), timings.CacheHits)
+		fmt.Fprintf(os.Stderr, "  extract   %s\n", timings.Extract.Round(time.Millisecond))
+	}
+	fmt.Fprintf(os.Stderr, "  network   %d requests, %s received, %s waiting for responses\n", metrics.Requests, formatSize(metrics.Bytes), metrics.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(os.Stderr, "  total     %s\n", time.Since(commandStarted).Round(time.Millisecond))
+}
+
+// newSolver returns a solver for rootName configured from the resolution
+// flags, reading versions and dependencies from the index as seen by the
+// project's Python on this platform
+func newSolver(rootName, rootVersion string) *solver.Solver {
+	target, err := markers.HostTarget(projectPythonMinor())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+		os.Exit(1)
+	}
+	s := solver.NewSolver(rootName, rootVersion)
+	s.SetMaxIterations(maxIterations)
+	s.SetObjective(solverObjective())
+	s.SetTimeout(maxResolveTime)
+	s.SetSeed(solverSeed)
+	s.SetProvider(pypi.NewProvider(pypi.NewPyPIClient(), target))
+	return s
+}
+
+// projectSolver returns a solver for the project's dependencies, with holds
+// and constraint files applied
+func projectSolver(buildMeta *buildmeta.BuildMeta) *solver.Solver {
+	s := newSolver(buildMeta.Name, buildMeta.Version)
+	addRootDependencies(s, heldDependencies(buildMeta))
+	applyConstraintSets(s, buildMeta)
+	return s
+}
+
+// addRootDependencies adds the requirements in deps to s by name, so the
+// same manifest always gives the solver the same input
+func addRootDependencies(s *solver.Solver, deps map[string]string) {
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.AddRootDependency(name, solver.ParseConstraint(deps[name]))
+	}
+}
+
+// solverObjective parses --minimize, exiting on an unknown value
+func solverObjective() solver.Objective {
+	objective, err := solver.ParseObjective(minimizeTarget)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Invalid --minimize: %v\n", err)
+		os.Exit(1)
+	}
+	return objective
+}
+
+// reportSolveFailure prints a resolution error, with what a conflict was derived
+// from or the solver state when it was aborted, and exits
+func reportSolveFailure(err error) {
+	fmt.Fprintf(os.Stderr, "[zephyr] Dependency resolution failed: %v\n", err)
+	var conflict *solver.ConflictError
+	if errors.As(err, &conflict) && len(conflict.Conflict.Derivation) > 0 {
+		fmt.Fprintln(os.Stderr, "[zephyr] The conflict follows from:")
+		for _, incompatibility := range conflict.Conflict.Derivation {
+			fmt.Fprintf(os.Stderr, "  %s\n", incompatibility)
+		}
+	}
+	if conflictReportPath != "" && errors.As(err, &conflict) {
+		writeConflictReport(conflict.Conflict.Report())
+	}
+	var watchdog *solver.WatchdogError
+	if errors.As(err, &watchdog) {
+		fmt.Fprintln(os.Stderr, "[zephyr] Solver state at abort:")
+		fmt.Fprint(os.Stderr, watchdog.Dump)
+		if len(watchdog.Decisions) > 0 {
+			fmt.Fprintln(os.Stderr, "[zephyr] Partial solution found so far:")
+			for _, term := range watchdog.Decisions {
+				fmt.Fprintf(os.Stderr, "  %s==%s\n", term.Package, term.Version)
+			}
+		}
+		if len(watchdog.MostConstrained) > 0 {
+			fmt.Fprintln(os.Stderr, "[zephyr] Most constrained packages still undecided:")
+			for _, pkg := range watchdog.MostConstrained {
+				fmt.Fprintf(os.Stderr, "  %s (%d incompatibilities)\n", pkg.Name, pkg.Incompatibilities)
+			}
+			fmt.Fprintln(os.Stderr, "[zephyr] Pinning these in buildmeta.yaml, or raising --max-resolve-time, can help resolution finish")
+		}
+	}
+	os.Exit(1)
+}
+
+// writeConflictReport writes report as JSON to --conflict-report, or to
+// stdout when it is "-"
+func writeConflictReport(report *solver.ConflictReport) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not encode the conflict report: %v\n", err)
+		return
+	}
+	data = append(data, '\n')
+	if conflictReportPath == "-" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(invocationPath(conflictReportPath), data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not write the conflict report: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "[zephyr] Conflict report written to %s\n", conflictReportPath)
+}
+
+// printCoreMetadata prints the fields inspect shows for a release or wheel
+func printCoreMetadata(core *pypi.CoreMetadata) {
+	fmt.Printf("📦 %s %s\n", core.Name, core.Version)
+	if core.Summary != "" {
+		fmt.Printf("📝 %s\n", core.Summary)
+	}
+	requiresPython := core.RequiresPython
+	if requiresPython == "" {
+		requiresPython = "(any)"
+	}
+	fmt.Printf("🐍 Requires-Python: %s\n", requiresPython)
+	if len(core.ProvidesExtra) > 0 {
+		fmt.Printf("🧩 Provides-Extra: %s\n", strings.Join(core.ProvidesExtra, ", "))
+	}
+	fmt.Println("\nRequires-Dist:")
+	if len(core.RequiresDist) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, req := range core.RequiresDist {
+		fmt.Printf("  %s\n", req)
+	}
+	if len(core.ProjectURLs) > 0 {
+		fmt.Println("\nProject-URLs:")
+		for _, u := range core.ProjectURLs {
+			if u.Label == "" {
+				fmt.Printf("  %s\n", u.URL)
+			} else {
+				fmt.Printf("  %s: %s\n", u.Label, u.URL)
+			}
+		}
+	}
+	if len(core.Classifiers) > 0 {
+		fmt.Println("\nClassifiers:")
+		for _, c := range core.Classifiers {
+			fmt.Printf("  %s\n", c)
+		}
+	}
+}
+
+// newWheelInstaller creates a wheel installer honouring --link-mode,
+// --allow-overwrite and the project's console script precedence
+func newWheelInstaller(venvPath string) *installer.WheelInstaller {
+	mode, err := cache.ParseLinkMode(linkMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Invalid --link-mode: %v\n", err)
+		os.Exit(1)
+	}
+	wheelInstaller := installer.NewWheelInstaller(venvPath)
+	wheelInstaller.SetLinkMode(mode)
+	wheelInstaller.SetAllowOverwrite(allowOverwrite)
+	if buildMeta, err := buildmeta.ParseFromDirectory("."); err == nil {
+		wheelInstaller.SetScriptPrecedence(buildMeta.ScriptPrecedence)
+	}
+	return wheelInstaller
+}
+
+// runMatrixEnv creates or reuses the environment for one matrix interpreter,
+// installs the lockfile and dev dependencies into it and runs task there
+func runMatrixEnv(buildMeta *buildmeta.BuildMeta, lockfile *installer.Lockfile, scripts map[string]buildmeta.Task, request, requires, task string, args []string) error {
+	venv := installer.NewVirtualEnvironment(filepath.Join(".zephyr", "envs", "py"+request))
+	if !venv.Exists() {
+		python, pythonVersion, err := installer.FindPython(request, requires)
+		if err != nil {
+			managed, findErr := interpreters.NewManager().Find(request, requires)
+			if findErr != nil || managed == nil {
+				return fmt.Errorf("no interpreter found. Install one with: zephyr python install %s", request)
+			}
+			python, pythonVersion = managed.Executable(), managed.Version
+		}
+		fmt.Printf("[zephyr] Creating %s with Python %s from %s\n", venv.Path, pythonVersion, python)
+		if err := venv.CreateWithPython(python); err != nil {
+			return fmt.Errorf("could not create environment: %w", err)
+		}
+	}
+
+	lock := lockVenv(venv.Path)
+	wheelInstaller := newWheelInstaller(venv.Path)
+	err := installLockfile(wheelInstaller, lockfile)
+	recordInstalls("envs matrix", venv.Path, wheelInstaller, err)
+	lock.Release()
+	if err != nil {
+		return fmt.Errorf("could not install %w", err)
+	}
+	syncDevDependencies(buildMeta, venv)
+
+	env, err := buildMeta.ResolveEnv(".", envFiles)
+	if err != nil {
+		return fmt.Errorf("could not load environment: %w", err)
+	}
+	venv.ActivateEnv(env)
+	runner := tasks.NewRunner(scripts, ".", env)
+	if runJobs > 0 {
+		runner.Jobs = runJobs
+	}
+	return runner.Run(task, args)
+}
+
+// printLockedArtifacts prints the artifact each package in the saved lockfile
+// installs from, looking index packages up on PyPI
+func printLockedArtifacts(lockManager *installer.LockfileManager, project string) {
+	lockfile, err := lockManager.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+		os.Exit(1)
+	}
+	artifacts, err := lockfile.Artifacts(pypi.NewPyPIClient(), project)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not look up locked artifacts: %v\n", err)
+		os.Exit(1)
+	}
+	if err := installer.WriteArtifacts(os.Stdout, artifacts, lockFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not write artifact list: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// installLockfile installs every locked package, stopping at the first failure
+func installLockfile(wheelInstaller *installer.WheelInstaller, lockfile *installer.Lockfile) error {
+	wheelInstaller.SetLockedHashes(lockfile)
+	for name, pkg := range lockfile.Packages {
+		fmt.Printf("[zephyr] Installing %s %s...\n", name, pkg.Version)
+		if pkg.Source == installer.SourceURL || pkg.Source == installer.SourceFile {
+			if _, err := wheelInstaller.InstallDirect(pkg.URL, pkg.Hash); err != nil {
+				return fmt.Errorf("%s from %s: %w", name, pkg.URL, err)
+			}
+			continue
+		}
+		if err := wheelInstaller.InstallWheelFromPyPI(name, pkg.Version); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// syncIntoPrefix installs the locked packages, or those named and their
+// dependencies, into --prefix instead of a virtual environment
+func syncIntoPrefix(packages []string) {
+	if syncVerifyOnly || syncPrune || syncSmokeTest {
+		fmt.Fprintln(os.Stderr, "[zephyr] Error: --verify-only, --prune and --smoke-test work on .venv and cannot be combined with --prefix")
+		os.Exit(1)
+	}
+	if !filepath.IsAbs(syncPrefix) {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: --prefix %s must be an absolute path, as installed scripts refer to it; stage elsewhere with --root\n", syncPrefix)
+		os.Exit(1)
+	}
+	python := syncPython
+	if python == "" || !strings.ContainsRune(python, filepath.Separator) {
+		request, _, requires := projectPythonRequest()
+		if python != "" {
+			request = python
+		}
+		found, _, err := installer.FindPython(request, requires)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+			os.Exit(1)
+		}
+		python = found
+	} else {
+		python = invocationPath(python)
+	}
+	version, err := installer.InterpreterVersion(python)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+		os.Exit(1)
+	}
+	minor, _ := installer.PythonMinor(version)
+	if abs, err := filepath.Abs(python); err == nil {
+		python = abs
+	}
+
+	lockManager := installer.NewLockfileManager(".")
+	lockfile, err := lockManager.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+		os.Exit(1)
+	}
+	if len(packages) > 0 {
+		lockfile, err = lockfile.Subtree(packages)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	scheme := installer.PrefixScheme(syncPrefix, python, minor)
+	target := syncPrefix
+	if syncRoot != "" {
+		root := invocationPath(syncRoot)
+		scheme = scheme.Staged(root)
+		target = filepath.Join(root, syncPrefix)
+	}
+	fmt.Printf("[zephyr] Installing %d locked package(s) into %s for Python %s...\n", len(lockfile.Packages), target, version)
+	wheelInstaller := newWheelInstaller(target)
+	wheelInstaller.SetScheme(scheme)
+	err = installLockfile(wheelInstaller, lockfile)
+	recordInstalls("sync", target, wheelInstaller, err)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not install %v\n", err)
+		printRemedy(err)
+		os.Exit(1)
+	}
+	pinLockfileHashes(lockManager, wheelInstaller)
+	fmt.Printf("[zephyr] ✅ All packages installed into %s\n", target)
+	if showTimings {
+		printTimings(wheelInstaller)
+	}
+}
+
+// installDirect installs wheels given by path or URL into .venv after verifying
+// them, and pins their location and hash in zephyr.lock
+func installDirect(refs []string) {
+	if installHash != "" && len(refs) != 1 {
+		fmt.Fprintln(os.Stderr, "[zephyr] Error: --hash applies to a single wheel")
+		os.Exit(1)
+	}
+	for _, ref := range refs {
+		if !installer.IsDirectReference(ref) {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: %s is not a wheel path or URL. Add packages from the index with: zephyr add %s\n", ref, ref)
+			os.Exit(1)
+		}
+	}
+	venv := installer.NewVirtualEnvironment(".venv")
+	if !venv.Exists() {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Virtual environment does not exist at .venv\n")
+		fmt.Fprintln(os.Stderr, "Create it first with: zephyr venv create")
+		os.Exit(1)
+	}
+	defer lockVenv(".venv").Release()
+	lockManager := installer.NewLockfileManager(".")
+	lockProject(lockManager)
+	defer lockManager.Unlock()
+	lockfile, err := lockManager.Load()
+	if err != nil {
+		lockfile = installer.NewLockfile(projectPythonMinor())
+	}
+	wheelInstaller := newWheelInstaller(".venv")
+	for _, ref := range refs {
+		if !strings.Contains(ref, "://") {
+			ref = invocationPath(ref)
+		}
+		result, err := wheelInstaller.InstallDirect(ref, installHash)
+		if err != nil {
+			recordInstalls("install", ".venv", wheelInstaller, err)
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not install %s: %v\n", ref, err)
+			printRemedy(err)
+			os.Exit(1)
+		}
+		verified := "unverified"
+		if len(result.Verified) > 0 {
+			verified = "verified by " + strings.Join(result.Verified, ", ")
+		}
+		fmt.Printf("✅ Installed %s %s (%s)\n", result.Name, result.Version, verified)
+		lockfile.AddPackage(result.Name, result.LockPackage())
+	}
+	recordInstalls("install", ".venv", wheelInstaller, nil)
+	if checkSharedLibs {
+		warnMissingSharedLibraries(".venv", wheelInstaller)
+	}
+	if err := lockManager.Save(lockfile); err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not update lockfile: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// exportSplitRequirements writes the locked direct and transitive pins to
+// separate requirements files named after file
+func exportSplitRequirements(file string, buildMeta *buildmeta.BuildMeta, groups []string) {
+	parts, err := installer.ParseSplit(exportSplit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !strings.HasSuffix(file, ".txt") {
+		fmt.Fprintln(os.Stderr, "[zephyr] Error: --split writes requirements files; give a .txt file such as requirements.txt")
+		os.Exit(1)
+	}
+	lockfile, err := installer.NewLockfileManager(".").Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+		fmt.Fprintln(os.Stderr, "Create it first with: zephyr lock")
+		os.Exit(1)
+	}
+	var direct []string
+	for requirement := range buildMeta.GroupDependencies(groups) {
+		name, _, _ := solver.SplitExtraPackage(solver.ExpandExtras(requirement)[0])
+		direct = append(direct, name)
+		if !lockfile.HasPackage(name) {
+			fmt.Fprintf(os.Stderr, "[zephyr] Warning: %s is not in %s; run 'zephyr lock' to pin it\n", name, installer.LockfileName())
+		}
+	}
+	lockfile = lockedGroups(lockfile, buildMeta, groups)
+	directNames, transitiveNames := lockfile.SplitPackages(direct, buildMeta.Name)
+	for _, part := range parts {
+		names, header := directNames, "Direct dependencies of %s, pinned by %s.\nInstall after the transitive dependencies, in a later image layer."
+		if part == installer.SplitTransitive {
+			names, header = transitiveNames, "Transitive dependencies of %s, pinned by %s.\nInstall before the direct dependencies, in an earlier image layer."
+		}
+		header = fmt.Sprintf(header, buildMeta.Name, installer.LockfileName()) + "\nGenerated by zephyr export --split; do not edit."
+		path := strings.TrimSuffix(file, ".txt") + "-" + part + ".txt"
+		if err := fsutil.WriteFileAtomic(path, []byte(lockfile.RenderRequirements(names, header)), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not write %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Exported %d %s dependencies to %s\n", len(names), part, displayPath(path))
+	}
+}
+
+// exportBuildSystem writes a Nix expression or Bazel macro fetching every
+// artifact in the lockfile
+func exportBuildSystem(file string, buildMeta *buildmeta.BuildMeta, groups []string) {
+	lockfile, err := installer.NewLockfileManager(".").Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+		fmt.Fprintln(os.Stderr, "Create it first with: zephyr lock")
+		os.Exit(1)
+	}
+	lockfile = lockedGroups(lockfile, buildMeta, groups)
+	artifacts, err := lockfile.Artifacts(pypi.NewPyPIClient(), buildMeta.Name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not look up locked artifacts: %v\n", err)
+		os.Exit(1)
+	}
+	content, err := installer.RenderBuildSystem(exportFormat, artifacts, buildMeta.Name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := fsutil.WriteFileAtomic(file, []byte(content), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not write %s: %v\n", file, err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Exported %d locked artifacts to %s (%s format)\n", len(artifacts), displayPath(file), exportFormat)
+}
+
+// enforcePolicy exits with the violations when lockfile or the Requires-Dist
+// of artifacts break the policy in buildMeta; either may be nil
+func enforcePolicy(buildMeta *buildmeta.BuildMeta, lockfile *installer.Lockfile, artifacts []*publish.Artifact) {
+	policy := installer.Policy{
+		ForbidLocalVersions:    buildMeta.Policy.ForbidLocalVersions,
+		ForbidDirectReferences: buildMeta.Policy.ForbidDirectReferences,
+		ForbidVCS:              buildMeta.Policy.ForbidVCS,
+	}
+	if !policy.Enabled() {
+		return
+	}
+	var violations []installer.PolicyViolation
+	if lockfile != nil {
+		violations = policy.CheckLock(lockfile)
+	}
+	for _, artifact := range artifacts {
+		for _, requirement := range artifact.Metadata["Requires-Dist"] {
+			if problem := policy.CheckRequirement(requirement); problem != "" {
+				violations = append(violations, installer.PolicyViolation{Package: fmt.Sprintf("%s requires %s", filepath.Base(artifact.Path), requirement), Problem: problem})
+			}
+		}
+	}
+	if len(violations) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "[zephyr] Error: The policy in %s forbids %d dependencies:\n", buildmeta.ManifestName(), len(violations))
+	for _, violation := range violations {
+		fmt.Fprintf(os.Stderr, "  %s\n", violation)
+	}
+	fmt.Fprintln(os.Stderr, "Replace them, or relax the policy section of "+buildmeta.ManifestName())
+	os.Exit(1)
+}
+
+// groupsSelected reports whether --group, --only or --without was given
+func groupsSelected() bool {
+	return len(groupWith)+len(groupOnly)+len(groupWithout) > 0
+}
+
+// selectedGroups returns the dependency groups the group flags select,
+// exiting on an unknown group
+func selectedGroups(buildMeta *buildmeta.BuildMeta) []string {
+	groups, err := buildMeta.SelectGroups(groupWith, groupOnly, groupWithout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+		os.Exit(1)
+	}
+	return groups
+}
+
+// lockedGroups narrows lockfile to the locked dependencies of groups and
+// everything they require. Without group flags the whole lock is kept, so
+// exports match what zephyr sync installs.
+func lockedGroups(lockfile *installer.Lockfile, buildMeta *buildmeta.BuildMeta, groups []string) *installer.Lockfile {
+	if !groupsSelected() {
+		return lockfile
+	}
+	locked := make(map[string]bool, len(lockfile.Packages))
+	for name := range lockfile.Packages {
+		locked[pkgname.Normalize(name)] = true
+	}
+	// Dependencies that were never locked, such as dev-dependencies, have no subtree
+	var names []string
+	for requirement := range buildMeta.GroupDependencies(groups) {
+		name, _, _ := solver.SplitExtraPackage(solver.ExpandExtras(requirement)[0])
+		if locked[pkgname.Normalize(name)] {
+			names = append(names, name)
+		}
+	}
+	subtree, err := lockfile.Subtree(names)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+		os.Exit(1)
+	}
+	return subtree
+}
+
+// pinLockfileHashes records in zephyr.lock the artifact hashes of packages the
+// installer downloaded, so later syncs fail if an artifact changes
+func pinLockfileHashes(lockManager *installer.LockfileManager, wheelInstaller *installer.WheelInstaller) {
+	if err := lockManager.Lock(); err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not record artifact hashes in zephyr.lock: %v\n", err)
+		return
+	}
+	defer lockManager.Unlock()
+	lockfile, err := lockManager.Load()
+	if err != nil {
+		return
+	}
+	if added := lockfile.PinHashes(wheelInstaller); added > 0 {
+		if err := lockManager.Save(lockfile); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not record artifact hashes in zephyr.lock: %v\n", err)
+			return
+		}
+		fmt.Printf("[zephyr] Pinned %d artifact hash(es) in zephyr.lock\n", added)
+	}
+}
+
+// cachePolicy parses cache prune limits; empty values leave a limit unset
+func cachePolicy(maxAge, maxSize string) (cache.PrunePolicy, error) {
+	var policy cache.PrunePolicy
+	if maxAge != "" {
+		age, err := cache.ParseAge(maxAge)
+		if err != nil {
+			return policy, err
+		}
+		policy.MaxAge = age
+	}
+	if maxSize != "" {
+		size, err := netutil.ParseRate(maxSize)
+		if err != nil || strings.HasSuffix(maxSize, "/s") {
+			return policy, fmt.Errorf("invalid cache size %q: expected a size such as 500MB or 5GB", maxSize)
+		}
+		policy.MaxSize = size
+	}
+	return policy, nil
+}
+
+// autoPruneCache applies the configured cache policy after an install once
+// the cache has grown past cache_max_size
+func autoPruneCache() {
+	cfg, _ := netutil.LoadConfig()
+	if cfg.CacheMaxSize == "" {
+		return
+	}
+	policy, err := cachePolicy(cfg.CacheMaxAge, cfg.CacheMaxSize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: Not pruning cache: %v\n", err)
+		return
+	}
+	size, err := cache.Size(cache.DefaultCacheDir())
+	if err != nil || size <= policy.MaxSize {
+		return
+	}
+	result, err := cache.Prune(cache.DefaultCacheDir(), policy, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not prune cache: %v\n", err)
+		return
+	}
+	fmt.Printf("[zephyr] Cache was over %s; pruned %d entries (%s)\n", cfg.CacheMaxSize, len(result.Removed), formatSize(result.Freed))
+}
+
+// applyDetectedInfo fills empty project metadata from the git remote, git
+// user and LICENSE file of the current directory
+func applyDetectedInfo(buildMeta *buildmeta.BuildMeta) {
+	if filled := buildMeta.ApplyProjectInfo(buildmeta.DetectProjectInfo(".")); len(filled) > 0 {
+		fmt.Printf("🔎 Detected %s from git and LICENSE\n", strings.Join(filled, ", "))
+	}
+}
+
+// projectPythonRequest returns the interpreter version named by .python-version,
+// the file naming it, and the project's requires-python
+func projectPythonRequest() (string, string, string) {
+	request, source, err := installer.ReadPythonVersion(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: Ignoring %s: %v\n", installer.PythonVersionFile, err)
+	}
+	if cwd, err := os.Getwd(); err == nil && source != "" {
+		if rel, err := filepath.Rel(cwd, source); err == nil {
+			source = rel
+		}
+	}
+	requires := ""
+	if _, err := os.Stat(buildmeta.ManifestName()); err == nil {
+		if buildMeta, err := buildmeta.ParseFromDirectory("."); err == nil {
+			requires = buildMeta.Python.Requires
+		}
+	} else if pyproject, err := buildmeta.ParsePyProjectToml("pyproject.toml"); err == nil {
+		requires = pyproject.RequiresPython
+	}
+	return request, source, requires
+}
+
+// projectPythonMinor returns the major.minor Python version markers are
+// evaluated for when locking: the venv's interpreter, else .python-version,
+// else 3.11
+func projectPythonMinor() string {
+	candidates := []string{}
+	if v, err := installer.InterpreterVersion(installer.NewVirtualEnvironment(".venv").GetPythonPath()); err == nil {
+		candidates = append(candidates, v)
+	}
+	if request, _, _ := projectPythonRequest(); request != "" {
+		candidates = append(candidates, request)
+	}
+	for _, candidate := range candidates {
+		if minor, ok := installer.PythonMinor(candidate); ok {
+			return minor
+		}
+	}
+	return "3.11"
+}
+
+// warnVenvPython warns when the environment's interpreter does not match
+// .python-version or falls outside the project's requires-python
+func warnVenvPython(venvPath string) {
+	current, err := installer.InterpreterVersion(installer.NewVirtualEnvironment(venvPath).GetPythonPath())
+	if err != nil {
+		return
+	}
+	request, source, requires := projectPythonRequest()
+	if request != "" && request != "system" && !installer.MatchesPythonRequest(current, request) {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: %s uses Python %s but %s asks for %s. Recreate it with: zephyr venv create\n", venvPath, current, source, request)
+	}
+	if requires != "" && !installer.SatisfiesRequiresPython(current, requires) {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: %s uses Python %s, outside the project's requires-python %s\n", venvPath, current, requires)
+	}
+}
+
+// lockVenv takes the advisory lock guarding changes to a virtual environment,
+// exiting when another zephyr process keeps holding it
+func lockVenv(venvPath string) *flock.Lock {
+	lock, err := flock.Acquire(filepath.Join(venvPath, ".zephyr.lock"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not lock %s: %v\n", venvPath, err)
+		os.Exit(1)
+	}
+	return lock
+}
+
+// lockProject takes the project lock of lockManager, exiting when another
+// zephyr process keeps holding it. Callers release it with Unlock once the
+// lockfile they loaded and changed is saved.
+func lockProject(lockManager *installer.LockfileManager) {
+	if err := lockManager.Lock(); err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not lock the project: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadProjectEnv loads buildmeta.yaml, if present, and the environment run and shell use
+func loadProjectEnv() (*buildmeta.BuildMeta, map[string]string) {
+	buildMeta := &buildmeta.BuildMeta{}
+	if _, err := os.Stat(buildmeta.ManifestName()); err == nil {
+		if buildMeta, err = buildmeta.ParseFromDirectory("."); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	env, err := buildMeta.ResolveEnv(".", envFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load environment: %v\n", err)
+		os.Exit(1)
+	}
+	if venv := installer.NewVirtualEnvironment(".venv"); venv.Exists() {
+		warnVenvPython(venv.Path)
+		venv.ActivateEnv(env)
+	}
+	return buildMeta, env
+}
+
+// heldDependencies returns the project dependencies with held packages pinned
+// to their locked versions, so re-resolution cannot move them
+func heldDependencies(buildMeta *buildmeta.BuildMeta) map[string]string {
+	deps := make(map[string]string)
+	for name, constraint := range buildMeta.GetDependencies() {
+		deps[name] = constraint
+	}
+	if len(buildMeta.Holds) == 0 {
+		return deps
+	}
+	lockfile, err := installer.NewLockfileManager(".").Load()
+	if err != nil {
+		return deps
+	}
+	for name := range deps {
+		if pkg, ok := lockfile.Packages[name]; ok && buildMeta.IsHeld(name) {
+			deps[name] = "==" + pkg.Version
+		}
+	}
+	return deps
+}
+
+// applyConstraintSets seeds s with the pins from the project's constraint files.
+func applyConstraintSets(s *solver.Solver, buildMeta *buildmeta.BuildMeta) {
+	for _, source := range buildMeta.Constraints {
+		constraints, err := buildmeta.LoadConstraints(source, ".", netutil.NewHTTPClient(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load constraints from %s: %v\n", source, err)
+			os.Exit(1)
+		}
+		names := make([]string, 0, len(constraints))
+		for name := range constraints {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			s.AddConstraint(name, solver.ParseConstraint(constraints[name]))
+		}
+	}
+}
+
+// warnHeldAdvisories warns when a hold keeps a package on a version with known advisories
+func warnHeldAdvisories(client *pypi.PyPIClient, name, current string) {
+	if current == "" {
+		return
+	}
+	metadata, err := client.FetchVersionMetadata(name, current)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not fetch advisories for %s: %v\n", name, err)
+		return
+	}
+	vulns := pypi.ActiveVulnerabilities(metadata.Vulnerabilities)
+	if len(vulns) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(vulns))
+	for _, vuln := range vulns {
+		ids = append(ids, vuln.ID)
+	}
+	fix := pypi.FixedVersion(current, vulns)
+	if fix == "" {
+		fix = "no fixed release yet"
+	} else {
+		fix = "fixed in " + fix
+	}
+	fmt.Fprintf(os.Stderr, "[zephyr] Warning: The hold on %s keeps %s, which is affected by %s (%s). Run 'zephyr unhold %s' to allow the fix.\n", name, current, strings.Join(ids, ", "), fix, name)
+}
+
+// checkLockfile compares zephyr.lock with a fresh resolution and exits non-zero if they differ
+func checkLockfile(lockManager *installer.LockfileManager, solution *solver.PartialSolution) {
+	existing, err := lockManager.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+		fmt.Fprintln(os.Stderr, "Run 'zephyr lock' to create it.")
+		os.Exit(1)
+	}
+	fresh := installer.NewLockfile(existing.Python)
+	if err := fresh.UpdateFromSolution(solution); err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not build lockfile: %v\n", err)
+		os.Exit(1)
+	}
+	diff := existing.Diff(fresh)
+	if len(diff) == 0 {
+		fmt.Println("✅ zephyr.lock is up to date")
+		return
+	}
+	fmt.Fprintln(os.Stderr, "[zephyr] Error: zephyr.lock is out of date with buildmeta.yaml:")
+	for _, line := range diff {
+		fmt.Fprintf(os.Stderr, "  %s\n", line)
+	}
+	fmt.Fprintln(os.Stderr, "Run 'zephyr lock' and commit the result.")
+	os.Exit(1)
+}
+
+// locateHooks returns the git hooks directory and the project path relative to the repository root
+func locateHooks() (string, string) {
+	hooksDir, err := hooks.Dir(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+		os.Exit(1)
+	}
+	root, err := hooks.RepoRoot(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+		os.Exit(1)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not determine working directory: %v\n", err)
+		os.Exit(1)
+	}
+	// git reports the root with symlinks resolved
+	if resolved, err := filepath.EvalSymlinks(cwd); err == nil {
+		cwd = resolved
+	}
+	projectDir, err := filepath.Rel(root, cwd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not locate project in repository: %v\n", err)
+		os.Exit(1)
+	}
+	return hooksDir, projectDir
+}
+
+// runTask runs a script task and exits with the failing command's status
+func runTask(runner *tasks.Runner, name string, args []string) {
+	if runJobs > 0 {
+		runner.Jobs = runJobs
+	}
+	if err := runner.Run(name, args); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+			os.Exit(exitErr.ExitCode())
+		}
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not run %s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// syncDevDependencies installs dev-dependencies missing from venv
+func syncDevDependencies(buildMeta *buildmeta.BuildMeta, venv *installer.VirtualEnvironment) {
+	installed, err := venv.InstalledDistributions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not inspect %s: %v\n", venv.Path, err)
+		os.Exit(1)
+	}
+	var missing []string
+	for requirement := range buildMeta.GetDevDependencies() {
+		name, _, _ := solver.SplitExtraPackage(solver.ExpandExtras(requirement)[0])
+		if _, ok := installed[pkgname.Normalize(name)]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	sort.Strings(missing)
+	defer lockVenv(venv.Path).Release()
+	fmt.Printf("[zephyr] Syncing dev dependencies: %s\n", strings.Join(missing, ", "))
+	s := projectSolver(buildMeta)
+	addRootDependencies(s, buildMeta.GetDevDependencies())
+	solution := solve(s)
+	wheelInstaller := newWheelInstaller(venv.Path)
+	for _, name := range missing {
+		assign := solution.GetAssignmentByPackage(name)
+		if assign == nil {
+			continue
+		}
+		ver := assign.Term.Version.String()
+		fmt.Printf("[zephyr] Installing %s %s...\n", name, ver)
+		if err := wheelInstaller.InstallWheelFromPyPI(name, ver); err != nil {
+			recordInstalls("sync dev-dependencies", venv.Path, wheelInstaller, err)
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not install %s: %v\n", name, err)
+			printRemedy(err)
+			os.Exit(1)
+		}
+	}
+	recordInstalls("sync dev-dependencies", venv.Path, wheelInstaller, nil)
+}
+
+// resolutionFailed is the serve-api error code for dependency conflicts
+const resolutionFailed = -32001
+
+// newAPIServer returns the JSON-RPC server behind serve-api. Project queries
+// read buildmeta.yaml and zephyr.lock afresh on each request, so edits are
+// seen without restarting; index metadata is cached for the process.
+func newAPIServer() *jsonrpc.Server {
+	cache := pypi.NewMetadataCache(pypi.NewPyPIClient(), pypi.DefaultMetadataTTL)
+	server := jsonrpc.NewServer()
+
+	server.Register("metadata", func(params json.RawMessage) (interface{}, error) {
+		var p struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		if json.Unmarshal(params, &p) != nil || p.Name == "" {
+			return nil, jsonrpc.InvalidParams(`metadata needs {"name": ...}`)
+		}
+		all, err := cache.Package(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		info := all.Info
+		if p.Version != "" {
+			release, err := cache.Version(p.Name, p.Version)
+			if err != nil {
+				return nil, err
+			}
+			info = release.Info
+		}
+		stable, prerelease := pypi.LatestVersions(pypi.VersionHistory(all))
+		return map[string]interface{}{
+			"name":              info.Name,
+			"version":           info.Version,
+			"summary":           info.Summary,
+			"requires_python":   info.RequiresPython,
+			"requires_dist":     info.RequiresDist,
+			"project_urls":      info.ProjectURLs,
+			"latest":            stable,
+			"latest_prerelease": prerelease,
+		}, nil
+	})
+
+	server.Register("versions", func(params json.RawMessage) (interface{}, error) {
+		var p struct {
+			Name        string `json:"name"`
+			Prefix      string `json:"prefix"`
+			Prereleases bool   `json:"prereleases"`
+		}
+		if json.Unmarshal(params, &p) != nil || p.Name == "" {
+			return nil, jsonrpc.InvalidParams(`versions needs {"name": ...}`)
+		}
+		all, err := cache.Package(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		type versionInfo struct {
+			Version    string `json:"version"`
+			UploadTime string `json:"upload_time,omitempty"`
+			Prerelease bool   `json:"prerelease"`
+		}
+		versions := []versionInfo{}
+		for _, summary := range pypi.VersionHistory(all) {
+			if summary.Yanked || (summary.Prerelease && !p.Prereleases) || !strings.HasPrefix(summary.Version, p.Prefix) {
+				continue
+			}
+			v := versionInfo{Version: summary.Version, Prerelease: summary.Prerelease}
+			if !summary.UploadTime.IsZero() {
+				v.UploadTime = summary.UploadTime.UTC().Format(time.RFC3339)
+			}
+			versions = append(versions, v)
+		}
+		return map[string]interface{}{"name": all.Info.Name, "versions": versions}, nil
+	})
+
+	server.Register("outdated", func(json.RawMessage) (interface{}, error) {
+		buildMeta, err := buildmeta.ParseFromDirectory(".")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load buildmeta.yaml: %w", err)
+		}
+		locked := map[string]installer.LockPackage{}
+		if lockfile, err := installer.NewLockfileManager(".").Load(); err == nil {
+			locked = lockfile.Packages
+		}
+		type dependency struct {
+			Name       string `json:"name"`
+			Constraint string `json:"constraint"`
+			Locked     string `json:"locked,omitempty"`
+			Latest     string `json:"latest,omitempty"`
+			Outdated   bool   `json:"outdated"`
+			// Allowed is whether the constraint admits the latest release
+			Allowed bool   `json:"allowed"`
+			Error   string `json:"error,omitempty"`
+		}
+		deps := buildMeta.GetDependencies()
+		names := make([]string, 0, len(deps))
+		for name := range deps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		results := make([]dependency, len(names))
+		var wg sync.WaitGroup
+		for i, name := range names {
+			wg.Add(1)
+			go func(i int, name string) {
+				defer wg.Done()
+				d := dependency{Name: name, Constraint: deps[name], Locked: locked[name].Version}
+				all, err := cache.Package(name)
+				if err != nil {
+					d.Error = err.Error()
+					results[i] = d
+					return
+				}
+				d.Latest, _ = pypi.LatestVersions(pypi.VersionHistory(all))
+				d.Outdated = d.Locked != "" && d.Latest != "" && pkgversion.Compare(d.Latest, d.Locked) > 0
+				d.Allowed = d.Constraint == ""
+				if ok, err := pkgversion.Satisfies(d.Latest, d.Constraint); err == nil && d.Constraint != "" {
+					d.Allowed = ok
+				}
+				results[i] = d
+			}(i, name)
+		}
+		wg.Wait()
+		return results, nil
+	})
+
+	server.Register("resolve", func(params json.RawMessage) (interface{}, error) {
+		var p struct {
+			Dependencies map[string]string `json:"dependencies"`
+		}
+		if len(params) > 0 && json.Unmarshal(params, &p) != nil {
+			return nil, jsonrpc.InvalidParams(`resolve takes {"dependencies": {name: specifier}}`)
+		}
+		rootName, rootVersion := "root", "0"
+		deps := p.Dependencies
+		if deps == nil {
+			buildMeta, err := buildmeta.ParseFromDirectory(".")
+			if err != nil {
+				return nil, fmt.Errorf("failed to load buildmeta.yaml: %w", err)
+			}
+			rootName, rootVersion = buildMeta.Name, buildMeta.Version
+			deps = heldDependencies(buildMeta)
+		}
+		s := newSolver(rootName, rootVersion)
+		addRootDependencies(s, deps)
+		solution, err := s.Solve()
+		if err != nil {
+			return nil, &jsonrpc.Error{Code: resolutionFailed, Message: "dependency resolution failed", Data: map[string]string{"conflict": err.Error()}}
+		}
+		lockfile := installer.NewLockfile(projectPythonMinor())
+		if err := lockfile.UpdateFromSolution(solution); err != nil {
+			return nil, err
+		}
+		packages := make(map[string]string, len(lockfile.Packages))
+		for name, pkg := range lockfile.Packages {
+			if name != rootName {
+				packages[name] = pkg.Version
+			}
+		}
+		return map[string]interface{}{"packages": packages}, nil
+	})
+	return server
+}
+
+// verifyEnvironment checks venvPath against zephyr.lock with the network
+// disabled and without changing anything, exiting non-zero on any difference
+func verifyEnvironment(venvPath string) {
+	netutil.SetOffline(true)
+	if !installer.NewVirtualEnvironment(venvPath).Exists() {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Virtual environment does not exist at %s\n", venvPath)
+		os.Exit(1)
+	}
+	lockfile, err := installer.NewLockfileManager(".").Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load lockfile: %v\n", err)
+		os.Exit(1)
+	}
+	result, err := environment.New(venvPath).Verify(lockfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not verify %s: %v\n", venvPath, err)
+		os.Exit(1)
+	}
+	for _, mismatch := range result.Mismatches {
+		fmt.Printf("❌ %s\n", mismatch)
+	}
+	if !result.OK() {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: %s does not match zephyr.lock (%d differences). Run 'zephyr sync' to repair it\n", venvPath, len(result.Mismatches))
+		os.Exit(1)
+	}
+	fmt.Printf("✅ %s matches zephyr.lock (%d packages, %d files verified)\n", venvPath, result.Packages, result.Files)
+}
+
+// pruneEnvironment uninstalls the packages in venvPath that lockfile does
+// not lock, except those in keep and what they require
+func pruneEnvironment(venvPath string, lockfile *installer.Lockfile, keep []string) {
+	env := environment.New(venvPath)
+	orphans, err := env.Orphans(lockfile, keep)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not inspect %s: %v\n", venvPath, err)
+		os.Exit(1)
+	}
+	if len(orphans) == 0 {
+		return
+	}
+	entry := auditlog.NewEntry("sync --prune", venvPath)
+	for _, orphan := range orphans {
+		fmt.Printf("[zephyr] Uninstalling %s\n", orphan)
+		entry.Packages = append(entry.Packages, auditlog.Package{Name: orphan.Dist.Name, Version: orphan.Dist.Version, Action: auditlog.ActionUninstall})
+	}
+	_, err = env.ApplyRepair(&environment.RepairPlan{Remove: orphans})
+	recordAudit(entry, err)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not prune %s: %v\n", venvPath, err)
+		printRemedy(err)
+		os.Exit(1)
+	}
+	fmt.Printf("[zephyr] Pruned %d package(s) not in %s\n", len(orphans), installer.LockfileName())
+}
+
+// smokeTestEnvironment imports the top-level modules of the packages in
+// venvPath, exiting non-zero if any fail
+func smokeTestEnvironment(venvPath string) {
+	fmt.Println("[zephyr] Importing installed modules...")
+	result, err := environment.New(venvPath).SmokeTest()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not run the import smoke test: %v\n", err)
+		os.Exit(1)
+	}
+	for _, failure := range result.Failures {
+		fmt.Printf("❌ %s\n", failure)
+	}
+	if !result.OK() {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: %d of %d modules failed to import\n", len(result.Failures), result.Modules)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Imported %d modules\n", result.Modules)
+}
+
+// warnSystemRequirements warns about the system requirements in
+// buildMeta that are missing, since packages built from source may need them
+func warnSystemRequirements(buildMeta *buildmeta.BuildMeta) {
+	reqs := buildMeta.SystemRequirements
+	for _, r := range sysreq.Missing(sysreq.Check(reqs.Tools, reqs.Libraries)) {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: Missing system requirement: %s\n", r)
+	}
+}
+
+// warnMissingSharedLibraries warns about shared libraries the wheels
+// wheelInstaller installed need but the system lacks. The install has
+// succeeded, so nothing here fails it.
+func warnMissingSharedLibraries(venvPath string, wheelInstaller *installer.WheelInstaller) {
+	var names []string
+	for _, artifact := range wheelInstaller.Installed() {
+		names = append(names, artifact.Name)
+	}
+	if len(names) == 0 {
+		return
+	}
+	missing, err := environment.New(venvPath).MissingSharedLibraries(names...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not scan for missing shared libraries: %v\n", err)
+		return
+	}
+	for _, lib := range missing {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: %s\n", lib)
+	}
+}
+
+// checkUndeclaredImports reports the modules the project imports that no
+// declared dependency provides, exiting non-zero if there are any
+func checkUndeclaredImports(venvPath string) {
+	buildMeta, err := buildmeta.ParseFromDirectory(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not load buildmeta.yaml: %v\n", err)
+		os.Exit(1)
+	}
+	var declared []string
+	groups := []map[string]string{buildMeta.GetDependencies(), buildMeta.GetDevDependencies()}
+	for group := range buildMeta.OptionalDependencies {
+		groups = append(groups, buildMeta.GetOptionalDependencies(group))
+	}
+	for _, deps := range groups {
+		for requirement := range deps {
+			name, _, _ := solver.SplitExtraPackage(solver.ExpandExtras(requirement)[0])
+			declared = append(declared, name)
+		}
+	}
+	env := environment.New(venvPath)
+	ignore, err := env.StdlibModules()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+		os.Exit(1)
+	}
+	local, err := environment.ProjectModules(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not scan the project's Python files: %v\n", err)
+		os.Exit(1)
+	}
+	for module := range local {
+		ignore[module] = true
+	}
+	imports, err := environment.ScanImports(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not scan the project's Python files: %v\n", err)
+		os.Exit(1)
+	}
+	undeclared, err := env.UndeclaredImports(declared, imports, ignore)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not read installed packages: %v\n", err)
+		os.Exit(1)
+	}
+	if len(undeclared) == 0 {
+		fmt.Println("✅ Every import is provided by a declared dependency.")
+		return
+	}
+	for _, u := range undeclared {
+		where := u.ImportedBy[0]
+		if len(u.ImportedBy) > 1 {
+			where = fmt.Sprintf("%s and %d more", where, len(u.ImportedBy)-1)
+		}
+		switch {
+		case u.Distribution == "":
+			fmt.Printf("❌ %s is imported by %s, but no installed package provides it\n", u.Module, where)
+		case len(u.RequiredBy) > 0:
+			fmt.Printf("❌ %s is imported by %s, but %s is only installed because %s requires it\n", u.Module, where, u.Distribution, strings.Join(u.RequiredBy, ", "))
+		default:
+			fmt.Printf("❌ %s is imported by %s, but %s is not declared in buildmeta.yaml\n", u.Module, where, u.Distribution)
+		}
+		fmt.Printf("   zephyr add %s\n", u.Suggestion())
+	}
+	os.Exit(1)
+}
+
+// checkSharedLibraries reports the shared libraries extension modules in
+// venvPath need but the system lacks, exiting non-zero if there are any
+func checkSharedLibraries(venvPath string) {
+	missing, err := environment.New(venvPath).MissingSharedLibraries()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not scan for missing shared libraries: %v\n", err)
+		os.Exit(1)
+	}
+	if len(missing) == 0 {
+		fmt.Println("✅ No missing shared libraries found.")
+		return
+	}
+	for _, lib := range missing {
+		fmt.Printf("❌ %s\n", lib)
+	}
+	os.Exit(1)
+}
+
+// recordInstalls appends the wheels wheelInstaller installed into venvPath to
+// the project's audit log, noting opErr when the operation failed part way
+func recordInstalls(operation, venvPath string, wheelInstaller *installer.WheelInstaller, opErr error) {
+	entry := auditlog.NewEntry(operation, venvPath)
+	for _, artifact := range wheelInstaller.Installed() {
+		entry.Packages = append(entry.Packages, auditlog.Package{
+			Name:    artifact.Name,
+			Version: artifact.Version,
+			Action:  auditlog.ActionInstall,
+			Source:  artifact.Source,
+			SHA256:  artifact.SHA256,
+		})
+	}
+	recordAudit(entry, opErr)
+}
+
+// recordAudit appends entry to the project's audit log. Failing to write the
+// log only warns, since the environment has already changed.
+func recordAudit(entry auditlog.Entry, opErr error) {
+	if opErr != nil {
+		entry.Error = opErr.Error()
+	}
+	if err := auditlog.Open(".").Append(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not record the change in the audit log: %v\n", err)
+	}
+}
+
+// execWithEnv runs name with env and exits with its status
+func execWithEnv(name string, args []string, env map[string]string) {
+	// Resolve the command against the activated PATH, not zephyr's own
+	os.Setenv("PATH", env["PATH"])
+	path, err := exec.LookPath(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not find command %s: %v\n", name, err)
+		os.Exit(1)
+	}
+	child := exec.Command(path, args...)
+	child.Env = buildmeta.EnvList(env)
+	// Commands run where zephyr was invoked, so their relative arguments
+	// still work after the switch to the project root
+	child.Dir = invocationDir
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	if err := child.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not run %s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// enterProject applies -C and then moves to the project root: the directory
+// of --manifest when given, or else the nearest directory at or above the
+// working directory holding buildmeta.yaml, so commands work from anywhere
+// inside a project. Without --manifest, init and import create a project
+// where they are run, so they stay put.
+func enterProject(cmd *cobra.Command) {
+	if projectDir != "" {
+		if err := os.Chdir(projectDir); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not change to directory %s: %v\n", projectDir, err)
+			os.Exit(1)
+		}
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	invocationDir = cwd
+	if manifestPath != "" {
+		manifest := filepath.Join(cwd, manifestPath)
+		if filepath.IsAbs(manifestPath) {
+			manifest = manifestPath
+		}
+		buildmeta.SetManifestName(filepath.Base(manifest))
+		installer.SetLockfileName(installer.LockfileNameFor(manifest))
+		if err := os.Chdir(filepath.Dir(manifest)); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not change to the directory of %s: %v\n", manifestPath, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if cmd == initCmd || cmd == importCmd {
+		return
+	}
+	if root, ok := buildmeta.FindProjectRoot(cwd); ok && root != cwd {
+		if err := os.Chdir(root); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not change to project root %s: %v\n", root, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// invocationPath resolves a relative path given on the command line against
+// the invocation directory, returning it relative to the project root when
+// it lies inside it
+func invocationPath(path string) string {
+	if path == "" || filepath.IsAbs(path) || invocationDir == "" {
+		return path
+	}
+	abs := filepath.Join(invocationDir, path)
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return rel
+		}
+	}
+	return abs
+}
+
+// displayPath shows a path relative to the invocation directory, for
+// instructions the user runs from there
+func displayPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil || invocationDir == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(invocationDir, abs); err == nil {
+		return rel
+	}
+	return abs
+}
+
+// completionMetadataAge is how old stored metadata may be before completion
+// tries to refresh it
+const completionMetadataAge = time.Hour
+
+// completionFetchTimeout bounds how long a completion waits on the index
+// before falling back to stale metadata
+const completionFetchTimeout = 2 * time.Second
+
+// completionOperators are the specifier operators completion recognizes, longest first
+var completionOperators = []string{"===", "==", "!=", "~=", ">=", "<=", ">", "<"}
+
+// completeAddArgs completes package names from the metadata store, and the
+// constraint of `zephyr add <package> ==<TAB>` with the package's versions
+func completeAddArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		names, err := cache.NewDefaultMetadataStore().Names()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var matches []string
+		for _, name := range names {
+			if strings.HasPrefix(name, pkgname.Normalize(toComplete)) {
+				matches = append(matches, name)
+			}
+		}
+		return matches, cobra.ShellCompDirectiveNoFileComp
+	case 1:
+		operator := "=="
+		for _, op := range completionOperators {
+			if strings.HasPrefix(toComplete, op) {
+				operator = op
+				break
+			}
+		}
+		partial := strings.TrimSpace(strings.TrimPrefix(toComplete, operator))
+		var matches []string
+		for _, summary := range completionVersions(args[0]) {
+			if !summary.Yanked && strings.HasPrefix(summary.Version, partial) {
+				matches = append(matches, operator+summary.Version)
+			}
+		}
+		return matches, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completionVersions returns a package's releases, newest first, from the
+// metadata store. Missing or stale metadata is fetched when the index answers
+// quickly enough; otherwise whatever was stored is used.
+func completionVersions(name string) []pypi.VersionSummary {
+	if metadata, _, ok := pypi.StoredPackageMetadata(name, completionMetadataAge); ok {
+		return pypi.VersionHistory(metadata)
+	}
+	if !netutil.Offline() {
+		fetched := make(chan *pypi.PyPIMetadata, 1)
+		go func() {
+			metadata, err := pypi.NewPyPIClient().FetchPackageMetadata(name)
+			if err != nil {
+				metadata = nil
+			}
+			fetched <- metadata
+		}()
+		select {
+		case metadata := <-fetched:
+			if metadata != nil {
+				return pypi.VersionHistory(metadata)
+			}
+		case <-time.After(completionFetchTimeout):
+		}
+	}
+	if metadata, _, ok := pypi.StoredPackageMetadata(name, 0); ok {
+		return pypi.VersionHistory(metadata)
+	}
+	return nil
+}
+
+// constraintPrefixes are the operators update rewrites, longest first
+var constraintPrefixes = []string{"==", ">=", "~=", ""}
+
+// constraintVersion returns the version a constraint pins or starts from, or ""
+func constraintVersion(constraint string) string {
+	for _, prefix := range constraintPrefixes {
+		if strings.HasPrefix(constraint, prefix) {
+			v := strings.TrimSpace(constraint[len(prefix):])
+			if strings.ContainsAny(v, "<>=!~,* ") {
+				return ""
+			}
+			return v
+		}
+	}
+	return ""
+}
+
+// rewriteConstraint moves a constraint to version, keeping its operator.
+// Constraints that are not a single pin or lower bound are left unchanged.
+func rewriteConstraint(constraint, version string) string {
+	if constraintVersion(constraint) == "" {
+		return constraint
+	}
+	for _, prefix := range constraintPrefixes {
+		if strings.HasPrefix(constraint, prefix) {
+			return prefix + version
+		}
+	}
+	return constraint
+}
+
+// formatCount renders large counts with thousands separators
+func formatCount(n int64) string {
+	digits := fmt.Sprintf("%d", n)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+// formatSize renders a byte count in human readable units
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+} 
+
+// configuredRepository returns the upload target named in the config
+func configuredRepository(name string) (*netutil.Repository, error) {
+	cfg, _ := netutil.LoadConfig()
+	repository, ok := cfg.Repositories[name]
+	if !ok || repository == nil {
+		names := make([]string, 0, len(cfg.Repositories))
+		for defined := range cfg.Repositories {
+			names = append(names, defined)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("unknown repository %q: no repositories are defined in the config", name)
+		}
+		return nil, fmt.Errorf("unknown repository %q: defined repositories are %s", name, strings.Join(names, ", "))
+	}
+	if repository.URL == "" {
+		return nil, fmt.Errorf("repository %q has no url", name)
+	}
+	return repository, nil
+}
+
+// printRemedy prints the remedy catalog's advice for err under its message
+func printRemedy(err error) {
+	if advice := remedy.Explain(err); advice != "" {
+		fmt.Fprintf(os.Stderr, "Hint: %s\n", advice)
+	}
+}
+
+// readSecret prompts for a secret on the terminal without echoing it, or
+// reads the first line of stdin when it is piped in
+func readSecret(prompt string) (string, error) {
+	info, err := os.Stdin.Stat()
+	if err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, prompt)
+		if setEcho(false) == nil {
+			defer func() {
+				setEcho(true)
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// setEcho turns terminal echo on or off with stty; it fails where there is
+// no stty, such as on Windows, and the secret is then echoed
+func setEcho(on bool) error {
+	mode := "-echo"
+	if on {
+		mode = "echo"
+	}
+	stty := exec.Command("stty", mode)
+	stty.Stdin = os.Stdin
+	return stty.Run()
+}
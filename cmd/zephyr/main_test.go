@@ -38,6 +38,42 @@ func TestZephyrInitAndAddRemove(t *testing.T) {
 	}
 }
 
+func TestZephyrBuildOutDirFollowsInvocation(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildZephyrBinary(t)
+	cmd := exec.Command(bin, "init", "proj")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("zephyr init failed: %v, out=%s", err, out)
+	}
+	sub := filepath.Join(dir, "proj", "sub")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// -C moves the invocation to sub, so -o out means sub/out
+	cmd = exec.Command(bin, "-C", filepath.Join("proj", "sub"), "build", "-o", "out")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("zephyr -C build -o failed: %v, out=%s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(sub, "out", "proj-0.1.0-py3-none-any.whl")); err != nil {
+		t.Errorf("wheel not written to the -o directory under -C: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "proj", "out")); err == nil {
+		t.Error("-o was resolved against the project root instead of the invocation directory")
+	}
+
+	// Without -C a relative -o is resolved against the working directory too
+	cmd = exec.Command(bin, "build", "--wheel", "-o", "wheels")
+	cmd.Dir = sub
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("zephyr build -o from a subdirectory failed: %v, out=%s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(sub, "wheels", "proj-0.1.0-py3-none-any.whl")); err != nil {
+		t.Errorf("wheel not written relative to the subdirectory: %v", err)
+	}
+}
+
 func TestZephyrVenvCreateListActivate(t *testing.T) {
 	dir := t.TempDir()
 	bin := buildZephyrBinary(t)
@@ -0,0 +1,173 @@
+// Package auditlog keeps a per-project, append-only record of every change
+// zephyr makes to a virtual environment, so what was installed or removed,
+// by whom and when can be reviewed after an incident.
+package auditlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultPath is where a project's audit log lives, relative to its root
+var DefaultPath = filepath.Join(".zephyr", "audit.log")
+
+// Actions recorded for a package
+const (
+	ActionInstall   = "install"
+	ActionUninstall = "uninstall"
+)
+
+// Package is one distribution an operation installed or removed
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Action  string `json:"action"`
+	Source  string `json:"source,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+}
+
+// Entry is one operation on an environment
+type Entry struct {
+	Time time.Time `json:"time"`
+	User string    `json:"user"`
+	Host string    `json:"host"`
+	// Operation is the zephyr command that made the change, e.g. "sync"
+	Operation   string    `json:"operation"`
+	Environment string    `json:"environment"`
+	Args        []string  `json:"args,omitempty"`
+	Packages    []Package `json:"packages"`
+	// Error is set when the operation failed part way; Packages then lists
+	// only what was changed before the failure
+	Error string `json:"error,omitempty"`
+}
+
+// NewEntry returns an entry for operation on environment stamped with the
+// current time, user, host and command line
+func NewEntry(operation, environment string) Entry {
+	host, _ := os.Hostname()
+	return Entry{
+		Time:        time.Now().UTC(),
+		User:        currentUser(),
+		Host:        host,
+		Operation:   operation,
+		Environment: environment,
+		Args:        os.Args[1:],
+		Packages:    []Package{},
+	}
+}
+
+// currentUser names who ran zephyr, including the invoking user under sudo
+func currentUser() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" && sudoUser != name {
+		name += " (via sudo from " + sudoUser + ")"
+	}
+	return name
+}
+
+// String formats the entry as one human-readable line
+func (e Entry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s@%s %s %s:", e.Time.Local().Format("2006-01-02 15:04:05"), e.User, e.Host, e.Operation, e.Environment)
+	if len(e.Packages) == 0 {
+		b.WriteString(" no changes")
+	}
+	for i, pkg := range e.Packages {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		sign := "+"
+		if pkg.Action == ActionUninstall {
+			sign = "-"
+		}
+		fmt.Fprintf(&b, " %s%s", sign, pkg.Name)
+		if pkg.Version != "" {
+			b.WriteString("==" + pkg.Version)
+		}
+		if pkg.SHA256 != "" {
+			b.WriteString(" (sha256:" + shortHash(pkg.SHA256) + ")")
+		}
+	}
+	if e.Error != "" {
+		b.WriteString(" [failed: " + e.Error + "]")
+	}
+	return b.String()
+}
+
+func shortHash(digest string) string {
+	if len(digest) > 12 {
+		return digest[:12]
+	}
+	return digest
+}
+
+// Log is an append-only JSON lines file of entries
+type Log struct {
+	Path string
+}
+
+// Open returns the audit log of the project in dir
+func Open(dir string) *Log {
+	return &Log{Path: filepath.Join(dir, DefaultPath)}
+}
+
+// Append adds an entry to the end of the log. Each entry is written with a
+// single O_APPEND write, so concurrent zephyr processes never interleave
+// lines and existing entries are never rewritten.
+func (l *Log) Append(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log %s: %w", l.Path, err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write audit log %s: %w", l.Path, err)
+	}
+	return f.Close()
+}
+
+// Entries reads every entry in the log, oldest first. A missing log has no
+// entries.
+func (l *Log) Entries() ([]Entry, error) {
+	f, err := os.Open(l.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %s: %w", l.Path, err)
+	}
+	defer f.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return entries, fmt.Errorf("audit log %s line %d is corrupt: %w", l.Path, lineNo, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return entries, fmt.Errorf("failed to read audit log %s: %w", l.Path, err)
+	}
+	return entries, nil
+}
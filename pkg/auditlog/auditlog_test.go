@@ -0,0 +1,62 @@
+package auditlog
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAppendAndEntries(t *testing.T) {
+	log := Open(t.TempDir())
+	if entries, err := log.Entries(); err != nil || len(entries) != 0 {
+		t.Fatalf("missing log: Entries = %v, %v", entries, err)
+	}
+
+	install := NewEntry("sync", ".venv")
+	install.Packages = append(install.Packages, Package{Name: "requests", Version: "2.31.0", Action: ActionInstall, Source: "pypi", SHA256: "58cd2187c01e70e6e26505bca751777aa9f2ee0b7f4300988b709f44e013003f"})
+	if err := log.Append(install); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	uninstall := NewEntry("uninstall", ".venv")
+	uninstall.Packages = append(uninstall.Packages, Package{Name: "six", Version: "1.16.0", Action: ActionUninstall})
+	uninstall.Error = "failed to remove six.py"
+	if err := log.Append(uninstall); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	entries, err := log.Entries()
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Operation != "sync" || entries[1].Packages[0].Name != "six" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if entries[0].User == "" || entries[0].Time.IsZero() || entries[0].Packages[0].SHA256 != install.Packages[0].SHA256 {
+		t.Errorf("entry lost who, when or hash: %+v", entries[0])
+	}
+
+	line := entries[0].String()
+	if !strings.Contains(line, "sync .venv: +requests==2.31.0 (sha256:58cd2187c01e)") {
+		t.Errorf("String() = %q", line)
+	}
+	if line := entries[1].String(); !strings.Contains(line, "-six==1.16.0") || !strings.Contains(line, "[failed: failed to remove six.py]") {
+		t.Errorf("String() = %q", line)
+	}
+}
+
+func TestEntriesCorrupt(t *testing.T) {
+	log := Open(t.TempDir())
+	if err := log.Append(NewEntry("install", ".venv")); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(log.Path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{not json\n")
+	f.Close()
+	entries, err := log.Entries()
+	if err == nil || !strings.Contains(err.Error(), "line 2") || len(entries) != 1 {
+		t.Errorf("Entries = %v, %v; want the first entry and a line 2 error", entries, err)
+	}
+}
@@ -0,0 +1,117 @@
+package bugreport
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// redacted replaces secret values in report files
+const redacted = "<redacted>"
+
+var (
+	// urlCredentials matches the user:password@ part of a URL
+	urlCredentials = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^/\s@]+@`)
+	// secretAssignment matches key: value and KEY=value pairs whose key names a secret
+	secretAssignment = regexp.MustCompile(`(?im)^(\s*(?:export\s+)?[\w.-]*(?:token|password|passwd|secret|api[_-]?key|auth|credential)[\w.-]*\s*[:=]\s*)(\S.*)$`)
+	// secretFlag matches command-line flags whose value is a secret
+	secretFlag = regexp.MustCompile(`(?i)(--[\w-]*(?:token|password|secret|api-key)[\w-]*[= ])(\S+)`)
+	// knownTokens matches token formats that are secret wherever they appear
+	knownTokens = regexp.MustCompile(`\b(?:pypi-[A-Za-z0-9_-]{16,}|gh[pousr]_[A-Za-z0-9]{20,})`)
+)
+
+// Redact removes credentials from config files, logs and command lines:
+// URL user info, values of secret-looking keys and flags, and known token formats
+func Redact(content string) string {
+	content = urlCredentials.ReplaceAllString(content, "${1}"+redacted+"@")
+	content = secretAssignment.ReplaceAllString(content, "${1}"+redacted)
+	content = secretFlag.ReplaceAllString(content, "${1}"+redacted)
+	return knownTokens.ReplaceAllString(content, redacted)
+}
+
+// Report collects the files of a bug-report bundle
+type Report struct {
+	files map[string][]byte
+}
+
+// NewReport returns an empty report
+func NewReport() *Report {
+	return &Report{files: make(map[string][]byte)}
+}
+
+// Add stores content under name after redacting it
+func (r *Report) Add(name, content string) {
+	r.files[name] = []byte(Redact(content))
+}
+
+// AddFile stores the redacted contents of the file at path under name. It
+// reports false, without error, when the file does not exist.
+func (r *Report) AddFile(name, path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	r.Add(name, string(data))
+	return true, nil
+}
+
+// Names returns the files in the report, sorted
+func (r *Report) Names() []string {
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Content returns a file in the report
+func (r *Report) Content(name string) (string, bool) {
+	data, ok := r.files[name]
+	return string(data), ok
+}
+
+// Write saves the report as a gzip-compressed tarball at path, with every
+// file under a top-level directory named after the archive
+func (r *Report) Write(path string) error {
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer out.Close()
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	base := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".gz"), ".tar")
+	now := time.Now()
+	for _, name := range r.Names() {
+		data := r.files[name]
+		header := &tar.Header{
+			Name:    base + "/" + name,
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,80 @@
+// Package bugreport records diagnostics about recent zephyr runs and bundles
+// them, with secrets redacted, into an archive users can attach to issues.
+// Nothing is ever sent anywhere.
+package bugreport
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rimraf-adi.com/zephyr/pkg/dirs"
+)
+
+const (
+	// LastCommandLog is the debug log of the most recent zephyr command
+	LastCommandLog = "last-command.log"
+	// SolverTraceLog holds the solver state after the most recent resolution
+	SolverTraceLog = "solver-trace.log"
+)
+
+// DefaultLogDir returns the directory zephyr keeps its debug logs in
+func DefaultLogDir() string {
+	return dirs.LogDir()
+}
+
+// Log is the debug log of a single command. Each entry is written straight to
+// disk so the log is complete even when the command exits abruptly.
+type Log struct {
+	file *os.File
+}
+
+// StartLog replaces the last-command log in dir with a new one for args
+func StartLog(dir string, args []string) (*Log, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, LastCommandLog), os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open debug log: %w", err)
+	}
+	l := &Log{file: f}
+	cwd, _ := os.Getwd()
+	l.Printf("command: %s", Redact(strings.Join(args, " ")))
+	l.Printf("cwd: %s", cwd)
+	l.Printf("pid: %d", os.Getpid())
+	return l, nil
+}
+
+// Printf appends a timestamped entry. It is a no-op on a nil Log.
+func (l *Log) Printf(format string, args ...interface{}) {
+	if l == nil || l.file == nil {
+		return
+	}
+	line := fmt.Sprintf(format, args...)
+	fmt.Fprintf(l.file, "%s %s\n", time.Now().Format(time.RFC3339), strings.TrimRight(line, "\n"))
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// WriteSolverTrace replaces the solver trace in dir
+func WriteSolverTrace(dir, trace string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	header := fmt.Sprintf("# %s\n", time.Now().Format(time.RFC3339))
+	if err := os.WriteFile(filepath.Join(dir, SolverTraceLog), []byte(header+trace), 0600); err != nil {
+		return fmt.Errorf("failed to write solver trace: %w", err)
+	}
+	return nil
+}
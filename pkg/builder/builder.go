@@ -0,0 +1,243 @@
+package builder
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"rimraf-adi.com/zephyr/pkg/buildmeta"
+)
+
+// minZipTime is the earliest timestamp representable in a zip archive
+var minZipTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Builder builds wheels and sdists for a pure-Python project described by buildmeta.yaml
+type Builder struct {
+	ProjectDir string
+	Meta       *buildmeta.BuildMeta
+	// Timestamp is written for every archive member so repeated builds are byte-identical
+	Timestamp time.Time
+	// PythonTag and PlatformTag override the wheel's py3 and any tags, as
+	// bdist_wheel's --python-tag and --plat-name do
+	PythonTag   string
+	PlatformTag string
+}
+
+// NewBuilder creates a builder for the project in projectDir, honouring SOURCE_DATE_EPOCH
+func NewBuilder(projectDir string, meta *buildmeta.BuildMeta) (*Builder, error) {
+	timestamp, err := SourceDateEpoch()
+	if err != nil {
+		return nil, err
+	}
+	return &Builder{ProjectDir: projectDir, Meta: meta, Timestamp: timestamp}, nil
+}
+
+// SourceDateEpoch returns the timestamp from SOURCE_DATE_EPOCH, or 1980-01-01 when it is unset.
+// Timestamps before 1980 are clamped because zip cannot represent them.
+func SourceDateEpoch() (time.Time, error) {
+	value := strings.TrimSpace(os.Getenv("SOURCE_DATE_EPOCH"))
+	if value == "" {
+		return minZipTime, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH '%s': must be seconds since the Unix epoch", value)
+	}
+	timestamp := time.Unix(seconds, 0).UTC()
+	if timestamp.Before(minZipTime) {
+		return minZipTime, nil
+	}
+	return timestamp, nil
+}
+
+// sourceFile is a project file and its path inside an archive
+type sourceFile struct {
+	Path       string
+	ArchiveKey string
+	Executable bool
+}
+
+// DistributionName returns the project name normalized for wheel and sdist filenames
+func (b *Builder) DistributionName() string {
+	return strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToLower(b.Meta.Name))
+}
+
+// collectSources returns the project's packages and modules in sorted archive order
+func (b *Builder) collectSources() ([]sourceFile, error) {
+	var files []sourceFile
+	packages := b.Meta.Python.Packages
+	if len(packages) == 0 {
+		if pkg := b.DistributionName(); b.findSourceRoot(pkg) != "" {
+			packages = []string{pkg}
+		}
+	}
+	for _, pkg := range packages {
+		relDir := filepath.FromSlash(strings.ReplaceAll(pkg, ".", "/"))
+		root := b.findSourceRoot(relDir)
+		if root == "" {
+			return nil, fmt.Errorf("package '%s' not found in %s or %s", pkg, b.ProjectDir, filepath.Join(b.ProjectDir, "src"))
+		}
+		err := filepath.WalkDir(filepath.Join(root, relDir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == "__pycache__" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if isBuildArtifact(d.Name()) {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, b.newSourceFile(path, filepath.ToSlash(rel)))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect package '%s': %w", pkg, err)
+		}
+	}
+	for _, module := range b.Meta.Python.PyModules {
+		relPath := filepath.FromSlash(strings.ReplaceAll(module, ".", "/")) + ".py"
+		root := b.findSourceRoot(relPath)
+		if root == "" {
+			return nil, fmt.Errorf("module '%s' not found in %s", module, b.ProjectDir)
+		}
+		files = append(files, b.newSourceFile(filepath.Join(root, relPath), filepath.ToSlash(relPath)))
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ArchiveKey < files[j].ArchiveKey })
+	return files, nil
+}
+
+// findSourceRoot returns the project directory or src/ layout directory containing rel
+func (b *Builder) findSourceRoot(rel string) string {
+	for _, root := range []string{b.ProjectDir, filepath.Join(b.ProjectDir, "src")} {
+		if _, err := os.Stat(filepath.Join(root, rel)); err == nil {
+			return root
+		}
+	}
+	return ""
+}
+
+// newSourceFile records a file and whether it should keep its executable bit
+func (b *Builder) newSourceFile(path, archiveKey string) sourceFile {
+	executable := false
+	if info, err := os.Stat(path); err == nil {
+		executable = info.Mode()&0111 != 0
+	}
+	return sourceFile{Path: path, ArchiveKey: archiveKey, Executable: executable}
+}
+
+// isBuildArtifact reports whether a file is interpreter output that must not be packaged
+func isBuildArtifact(name string) bool {
+	return strings.HasSuffix(name, ".pyc") || strings.HasSuffix(name, ".pyo") || name == ".DS_Store"
+}
+
+// fileMode returns the normalized permission bits for an archive member
+func fileMode(executable bool) os.FileMode {
+	if executable {
+		return 0755
+	}
+	return 0644
+}
+
+// CoreMetadata renders the METADATA/PKG-INFO document with fields in a fixed order
+func (b *Builder) CoreMetadata() string {
+	meta := b.Meta
+	var lines []string
+	add := func(key, value string) {
+		if value != "" {
+			lines = append(lines, fmt.Sprintf("%s: %s", key, value))
+		}
+	}
+	add("Metadata-Version", "2.1")
+	add("Name", meta.Name)
+	add("Version", meta.Version)
+	add("Summary", meta.Description)
+	add("Home-page", meta.Homepage)
+	add("Author", meta.Author)
+	add("Author-email", meta.Email)
+	add("License", meta.License)
+	if len(meta.Keywords) > 0 {
+		add("Keywords", strings.Join(meta.Keywords, ","))
+	}
+	classifiers := append([]string(nil), meta.Classifiers...)
+	sort.Strings(classifiers)
+	for _, classifier := range classifiers {
+		add("Classifier", classifier)
+	}
+	if meta.Repository != "" {
+		add("Project-URL", "Repository, "+meta.Repository)
+	}
+	add("Requires-Python", meta.Python.Requires)
+	for _, requirement := range requirementList(meta.GetDependencies(), "") {
+		add("Requires-Dist", requirement)
+	}
+	readme, contentType := b.readme()
+	add("Description-Content-Type", contentType)
+	groups := make([]string, 0, len(meta.OptionalDependencies))
+	for group := range meta.OptionalDependencies {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		add("Provides-Extra", group)
+		for _, requirement := range requirementList(meta.GetOptionalDependencies(group), group) {
+			add("Requires-Dist", requirement)
+		}
+	}
+	content := strings.Join(lines, "\n") + "\n"
+	if readme != "" {
+		// The long description is the message body after a blank line
+		content += "\n" + strings.TrimRight(readme, "\n") + "\n"
+	}
+	return content
+}
+
+// readmeFiles are the long description sources, with their content types, in preference order
+var readmeFiles = []struct{ name, contentType string }{
+	{"README.md", "text/markdown"},
+	{"README.rst", "text/x-rst"},
+	{"README.txt", "text/plain"},
+	{"README", "text/plain"},
+}
+
+// readme returns the project's README, used as the long description, and its content type
+func (b *Builder) readme() (string, string) {
+	for _, file := range readmeFiles {
+		if data, err := os.ReadFile(filepath.Join(b.ProjectDir, file.name)); err == nil {
+			return strings.ReplaceAll(string(data), "\r\n", "\n"), file.contentType
+		}
+	}
+	return "", ""
+}
+
+// requirementList renders sorted PEP 508 requirements, guarded by an extra marker when extra is set
+func requirementList(deps map[string]string, extra string) []string {
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	requirements := make([]string, 0, len(names))
+	for _, name := range names {
+		requirement := name
+		if constraint := strings.TrimSpace(deps[name]); constraint != "" && constraint != "*" {
+			requirement += constraint
+		}
+		if extra != "" {
+			requirement += fmt.Sprintf(" ; extra == \"%s\"", extra)
+		}
+		requirements = append(requirements, requirement)
+	}
+	return requirements
+}
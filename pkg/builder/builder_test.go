@@ -0,0 +1,396 @@
+package builder
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"rimraf-adi.com/zephyr/pkg/buildmeta"
+)
+
+func createTestProject(t *testing.T) (string, *buildmeta.BuildMeta) {
+	dir := t.TempDir()
+	pkgDir := filepath.Join(dir, "src", "my_tool")
+	os.MkdirAll(filepath.Join(pkgDir, "__pycache__"), 0755)
+	os.WriteFile(filepath.Join(pkgDir, "__init__.py"), []byte("__version__ = '1.2.0'\n"), 0644)
+	os.WriteFile(filepath.Join(pkgDir, "cli.py"), []byte("def main():\n    pass\n"), 0644)
+	os.WriteFile(filepath.Join(pkgDir, "__pycache__", "cli.cpython-311.pyc"), []byte("junk"), 0644)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# my-tool\n"), 0644)
+	meta := buildmeta.NewBuildMeta("my-tool", "1.2.0")
+	meta.AddDependency("requests", ">=2.0")
+	meta.AddDependency("click", "")
+	meta.AddOptionalDependency("socks", "pysocks", ">=1.7")
+	return dir, meta
+}
+
+func TestSourceDateEpoch(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	ts, err := SourceDateEpoch()
+	if err != nil || !ts.Equal(minZipTime) {
+		t.Errorf("Expected 1980 default, got %v, %v", ts, err)
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	ts, err = SourceDateEpoch()
+	if err != nil || ts.Unix() != 1700000000 {
+		t.Errorf("Expected SOURCE_DATE_EPOCH to be honoured, got %v, %v", ts, err)
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "0")
+	if ts, _ := SourceDateEpoch(); !ts.Equal(minZipTime) {
+		t.Errorf("Expected pre-1980 epoch to be clamped, got %v", ts)
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	if _, err := SourceDateEpoch(); err == nil {
+		t.Error("Expected error for invalid SOURCE_DATE_EPOCH")
+	}
+}
+
+func TestBuildWheelReproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	dir, meta := createTestProject(t)
+	b, err := NewBuilder(dir, meta)
+	if err != nil {
+		t.Fatalf("NewBuilder failed: %v", err)
+	}
+	first, err := b.BuildWheel(filepath.Join(dir, "dist1"))
+	if err != nil {
+		t.Fatalf("BuildWheel failed: %v", err)
+	}
+	// Touching sources must not change the artifact
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(dir, "src", "my_tool", "cli.py"), later, later)
+	second, err := b.BuildWheel(filepath.Join(dir, "dist2"))
+	if err != nil {
+		t.Fatalf("BuildWheel failed: %v", err)
+	}
+	a, _ := os.ReadFile(first)
+	c, _ := os.ReadFile(second)
+	if !bytes.Equal(a, c) {
+		t.Error("Repeated wheel builds are not byte-identical")
+	}
+	if filepath.Base(first) != "my_tool-1.2.0-py3-none-any.whl" {
+		t.Errorf("Unexpected wheel filename %s", filepath.Base(first))
+	}
+
+	reader, err := zip.OpenReader(first)
+	if err != nil {
+		t.Fatalf("Failed to open wheel: %v", err)
+	}
+	defer reader.Close()
+	var names []string
+	for _, f := range reader.File {
+		names = append(names, f.Name)
+		if f.Modified.UTC().Unix() != 1700000000 {
+			t.Errorf("%s has timestamp %v", f.Name, f.Modified)
+		}
+	}
+	want := []string{
+		"my_tool/__init__.py",
+		"my_tool/cli.py",
+		"my_tool-1.2.0.dist-info/METADATA",
+		"my_tool-1.2.0.dist-info/WHEEL",
+		"my_tool-1.2.0.dist-info/RECORD",
+	}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("Unexpected wheel members %v", names)
+	}
+}
+
+func TestCoreMetadata(t *testing.T) {
+	dir, meta := createTestProject(t)
+	b := &Builder{ProjectDir: dir, Meta: meta, Timestamp: minZipTime}
+	metadata := b.CoreMetadata()
+	for _, line := range []string{
+		"Name: my-tool",
+		"Requires-Dist: click\nRequires-Dist: requests>=2.0",
+		"Provides-Extra: socks\nRequires-Dist: pysocks>=1.7 ; extra == \"socks\"",
+	} {
+		if !strings.Contains(metadata, line) {
+			t.Errorf("METADATA missing %q:\n%s", line, metadata)
+		}
+	}
+}
+
+func TestBuildSdistReproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	dir, meta := createTestProject(t)
+	b, _ := NewBuilder(dir, meta)
+	first, err := b.BuildSdist(filepath.Join(dir, "dist1"))
+	if err != nil {
+		t.Fatalf("BuildSdist failed: %v", err)
+	}
+	second, _ := b.BuildSdist(filepath.Join(dir, "dist2"))
+	a, _ := os.ReadFile(first)
+	c, _ := os.ReadFile(second)
+	if !bytes.Equal(a, c) {
+		t.Error("Repeated sdist builds are not byte-identical")
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(a))
+	if err != nil {
+		t.Fatalf("Failed to open sdist: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read sdist: %v", err)
+		}
+		names = append(names, header.Name)
+		if !header.ModTime.Equal(minZipTime) || header.Uid != 0 || header.Uname != "" {
+			t.Errorf("%s has nondeterministic header %+v", header.Name, header)
+		}
+	}
+	want := "my_tool-1.2.0/PKG-INFO,my_tool-1.2.0/README.md,my_tool-1.2.0/src/my_tool/__init__.py,my_tool-1.2.0/src/my_tool/cli.py"
+	if strings.Join(names, ",") != want {
+		t.Errorf("Unexpected sdist members %v", names)
+	}
+}
+
+func TestPackRunsWithBundledDependency(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not available")
+	}
+	// Build a dependency wheel to bundle
+	depDir := t.TempDir()
+	os.MkdirAll(filepath.Join(depDir, "greeting"), 0755)
+	os.WriteFile(filepath.Join(depDir, "greeting", "__init__.py"), []byte("def hello():\n    return 'hello from dep'\n"), 0644)
+	depBuilder := &Builder{ProjectDir: depDir, Meta: buildmeta.NewBuildMeta("greeting", "0.1.0"), Timestamp: minZipTime}
+	depWheel, err := depBuilder.BuildWheel(filepath.Join(depDir, "dist"))
+	if err != nil {
+		t.Fatalf("BuildWheel failed: %v", err)
+	}
+
+	dir, meta := createTestProject(t)
+	os.WriteFile(filepath.Join(dir, "src", "my_tool", "cli.py"), []byte("import greeting\n\ndef main():\n    print(greeting.hello())\n    return 0\n"), 0644)
+	meta.AddEntryPoint("console_scripts", "my-tool", "my_tool.cli:main")
+	b := &Builder{ProjectDir: dir, Meta: meta, Timestamp: minZipTime}
+	entryPoint, err := b.DefaultEntryPoint()
+	if err != nil || entryPoint != "my_tool.cli:main" {
+		t.Fatalf("DefaultEntryPoint = %q, %v", entryPoint, err)
+	}
+	for _, format := range []PackFormat{PackZipapp, PackPexLike} {
+		out := filepath.Join(dir, "dist", string(format)+".pyz")
+		err := b.Pack(out, PackOptions{Format: format, EntryPoint: entryPoint, Wheels: []string{depWheel}})
+		if err != nil {
+			t.Fatalf("Pack(%s) failed: %v", format, err)
+		}
+		output, err := exec.Command(python, out).CombinedOutput()
+		if err != nil {
+			t.Fatalf("Running %s archive failed: %v\n%s", format, err, output)
+		}
+		if strings.TrimSpace(string(output)) != "hello from dep" {
+			t.Errorf("Unexpected %s output: %q", format, output)
+		}
+	}
+}
+
+func TestPackRejectsPlatformWheels(t *testing.T) {
+	dir, meta := createTestProject(t)
+	wheelPath := filepath.Join(dir, "native-1.0-cp311-cp311-manylinux_2_17_x86_64.whl")
+	w, _ := os.Create(wheelPath)
+	zip.NewWriter(w).Close()
+	w.Close()
+	b := &Builder{ProjectDir: dir, Meta: meta, Timestamp: minZipTime}
+	err := b.Pack(filepath.Join(dir, "app.pyz"), PackOptions{EntryPoint: "my_tool.cli:main", Wheels: []string{wheelPath}})
+	if err == nil || !strings.Contains(err.Error(), "not a pure-Python wheel") {
+		t.Errorf("Expected pure-Python error, got %v", err)
+	}
+}
+
+func TestBuildWheelTags(t *testing.T) {
+	dir, meta := createTestProject(t)
+	meta.Python.Requires = ">=3.9"
+	b := &Builder{ProjectDir: dir, Meta: meta, Timestamp: minZipTime, PythonTag: "py2.py3", PlatformTag: "linux-x86_64"}
+	path, err := b.BuildWheel(filepath.Join(dir, "dist"))
+	if err != nil {
+		t.Fatalf("BuildWheel failed: %v", err)
+	}
+	if filepath.Base(path) != "my_tool-1.2.0-py2.py3-none-linux_x86_64.whl" {
+		t.Errorf("Unexpected wheel filename %s", filepath.Base(path))
+	}
+	tags, err := b.CheckWheel(path)
+	if err != nil {
+		t.Fatalf("CheckWheel failed: %v", err)
+	}
+	if strings.Join(tags, ",") != "py2-none-linux_x86_64,py3-none-linux_x86_64" {
+		t.Errorf("Unexpected tags %v", tags)
+	}
+
+	// A pyproject.toml that disagrees with buildmeta.yaml fails the check
+	os.WriteFile(filepath.Join(dir, "pyproject.toml"), []byte("[project]\nname = \"my-tool\"\nversion = \"1.3.0\"\n"), 0644)
+	if _, err := b.CheckWheel(path); err == nil || !strings.Contains(err.Error(), "pyproject.toml gives '1.3.0'") {
+		t.Errorf("Expected a version mismatch, got %v", err)
+	}
+
+	for _, bad := range []*Builder{
+		{ProjectDir: dir, Meta: meta, PythonTag: "python3"},
+		{ProjectDir: dir, Meta: meta, PlatformTag: "linux x86"},
+	} {
+		if _, err := bad.BuildWheel(filepath.Join(dir, "bad")); err == nil {
+			t.Errorf("Expected invalid tags to be rejected: %+v", bad)
+		}
+	}
+}
+
+// sdistMembers lists the member names of an sdist
+func sdistMembers(t *testing.T, path string) []string {
+	data, _ := os.ReadFile(path)
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to open sdist: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatalf("Failed to read sdist: %v", err)
+		}
+		names = append(names, header.Name)
+	}
+}
+
+func TestBuildSdistIncludeExclude(t *testing.T) {
+	dir, meta := createTestProject(t)
+	for name, content := range map[string]string{
+		"docs/index.md":          "# docs\n",
+		"docs/_build/index.html": "<html>\n",
+		"tests/test_cli.py":      "def test(): pass\n",
+		"tests/data/big.bin":     "x",
+		"notes.txt":              "scratch\n",
+		".venv/lib/site.py":      "",
+		".gitignore":             "_build/\n*.bin\n",
+	} {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	meta.Python.Include = []string{"docs", "tests/**/*", "**/*.py"}
+	meta.Python.Exclude = []string{"src/my_tool/cli.py", "*.md"}
+	b := &Builder{ProjectDir: dir, Meta: meta, Timestamp: minZipTime}
+	path, err := b.BuildSdist(filepath.Join(dir, "dist"))
+	if err != nil {
+		t.Fatalf("BuildSdist failed: %v", err)
+	}
+	want := "my_tool-1.2.0/PKG-INFO,my_tool-1.2.0/src/my_tool/__init__.py,my_tool-1.2.0/tests/test_cli.py"
+	if got := strings.Join(sdistMembers(t, path), ","); got != want {
+		t.Errorf("Unexpected sdist members %s", got)
+	}
+}
+
+func TestGitignore(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("# comment\n*.log\n!keep.log\n/build\nsecret/\ndocs/*.tmp\n"), 0644)
+	ignore, err := loadGitignore(dir)
+	if err != nil {
+		t.Fatalf("loadGitignore failed: %v", err)
+	}
+	for _, tt := range []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"pkg/app.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"pkg/build", true, false},
+		{"secret", true, true},
+		{"secret", false, false},
+		{"docs/a.tmp", false, true},
+		{"docs/sub/a.tmp", false, false},
+	} {
+		if got := ignore.ignored(tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestBuildWheelEntryPoints(t *testing.T) {
+	dir, meta := createTestProject(t)
+	meta.AddEntryPoint("console_scripts", "my-tool", "my_tool.cli:main")
+	meta.AddEntryPoint("my_tool.plugins", "json", "my_tool.cli:JSONPlugin [json]")
+	meta.Build.Scripts = map[string]string{"my-tool": "ignored:main", "my-tool-admin": "my_tool.cli:admin"}
+	b := &Builder{ProjectDir: dir, Meta: meta, Timestamp: minZipTime}
+	path, err := b.BuildWheel(filepath.Join(dir, "dist"))
+	if err != nil {
+		t.Fatalf("BuildWheel failed: %v", err)
+	}
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("Failed to open wheel: %v", err)
+	}
+	defer reader.Close()
+	f, err := reader.Open("my_tool-1.2.0.dist-info/entry_points.txt")
+	if err != nil {
+		t.Fatalf("wheel has no entry_points.txt: %v", err)
+	}
+	data, _ := io.ReadAll(f)
+	want := "[console_scripts]\nmy-tool = my_tool.cli:main\nmy-tool-admin = my_tool.cli:admin\n\n[my_tool.plugins]\njson = my_tool.cli:JSONPlugin [json]\n"
+	if string(data) != want {
+		t.Errorf("entry_points.txt =\n%s", data)
+	}
+
+	meta.AddEntryPoint("console_scripts", "broken", "my_tool/cli.py")
+	if _, err := b.BuildWheel(filepath.Join(dir, "dist")); err == nil {
+		t.Error("Expected an invalid entry point to fail the build")
+	}
+}
+
+func TestVendor(t *testing.T) {
+	depDir := t.TempDir()
+	os.MkdirAll(filepath.Join(depDir, "greeting"), 0755)
+	os.WriteFile(filepath.Join(depDir, "greeting", "__init__.py"), []byte("def hello():\n    return 'hi'\n"), 0644)
+	depBuilder := &Builder{ProjectDir: depDir, Meta: buildmeta.NewBuildMeta("greeting", "0.1.0"), Timestamp: minZipTime}
+	depWheel, err := depBuilder.BuildWheel(filepath.Join(depDir, "dist"))
+	if err != nil {
+		t.Fatalf("BuildWheel failed: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "src", "_vendor")
+	packages := []VendoredPackage{{Name: "greeting", Version: "0.1.0", Wheel: depWheel}}
+	if err := Vendor(dir, packages); err != nil {
+		t.Fatalf("Vendor failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "greeting", "__init__.py")); err != nil {
+		t.Errorf("package not vendored: %v", err)
+	}
+	manifest, _ := os.ReadFile(filepath.Join(dir, VendorManifest))
+	if !strings.Contains(string(manifest), "\ngreeting==0.1.0\n") || !strings.Contains(string(manifest), `"_vendor"`) {
+		t.Errorf("unexpected %s:\n%s", VendorManifest, manifest)
+	}
+
+	// Re-vendoring replaces the previous tree
+	os.WriteFile(filepath.Join(dir, "stale.py"), []byte(""), 0644)
+	if err := Vendor(dir, packages); err != nil {
+		t.Fatalf("re-Vendor failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stale.py")); !os.IsNotExist(err) {
+		t.Error("files from the previous vendor run were kept")
+	}
+
+	// A directory zephyr did not create is left alone
+	other := t.TempDir()
+	os.WriteFile(filepath.Join(other, "main.py"), []byte(""), 0644)
+	if err := Vendor(other, packages); err == nil || !strings.Contains(err.Error(), VendorManifest) {
+		t.Errorf("Vendor into an unrelated directory = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(other, "main.py")); err != nil {
+		t.Error("unrelated directory was modified")
+	}
+}
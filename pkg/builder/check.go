@@ -0,0 +1,106 @@
+package builder
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/buildmeta"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+	"rimraf-adi.com/zephyr/pkg/pypi"
+)
+
+// CheckWheel reads a built wheel back and checks that its filename, METADATA
+// and WHEEL files agree with buildmeta.yaml and, when the project has one,
+// pyproject.toml. It returns the wheel's compatibility tags.
+func (b *Builder) CheckWheel(path string) ([]string, error) {
+	wheel, err := pypi.ParseWheelFilename(filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wheel '%s': %w", path, err)
+	}
+	defer reader.Close()
+
+	distInfo := fmt.Sprintf("%s-%s.dist-info", wheel.Name, wheel.Version)
+	metadata, err := readHeaders(&reader.Reader, distInfo+"/METADATA")
+	if err != nil {
+		return nil, err
+	}
+	wheelInfo, err := readHeaders(&reader.Reader, distInfo+"/WHEEL")
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	expect := func(field, got, want, source string) {
+		if got != want {
+			problems = append(problems, fmt.Sprintf("%s is '%s' but %s gives '%s'", field, got, source, want))
+		}
+	}
+	expect("filename name", wheel.Name, b.DistributionName(), "buildmeta.yaml")
+	expect("filename version", wheel.Version, b.Meta.Version, "buildmeta.yaml")
+	expect("METADATA Name", first(metadata["Name"]), b.Meta.Name, "buildmeta.yaml")
+	expect("METADATA Version", first(metadata["Version"]), b.Meta.Version, "buildmeta.yaml")
+	expect("METADATA Requires-Python", first(metadata["Requires-Python"]), b.Meta.Python.Requires, "buildmeta.yaml")
+	expect("WHEEL Tag", strings.Join(wheelInfo["Tag"], ", "), strings.Join(wheel.Tags(), ", "), "the filename")
+
+	pyprojectPath := filepath.Join(b.ProjectDir, "pyproject.toml")
+	if _, err := os.Stat(pyprojectPath); err == nil {
+		pyproject, err := buildmeta.ParsePyProjectToml(pyprojectPath)
+		if err != nil {
+			return nil, err
+		}
+		if pyproject.Name != "" && pkgname.Normalize(pyproject.Name) != pkgname.Normalize(b.Meta.Name) {
+			problems = append(problems, fmt.Sprintf("METADATA Name is '%s' but pyproject.toml gives '%s'", b.Meta.Name, pyproject.Name))
+		}
+		if pyproject.Version != "" && pyproject.Version != b.Meta.Version {
+			problems = append(problems, fmt.Sprintf("METADATA Version is '%s' but pyproject.toml gives '%s'", b.Meta.Version, pyproject.Version))
+		}
+		if pyproject.RequiresPython != "" && pyproject.RequiresPython != b.Meta.Python.Requires {
+			problems = append(problems, fmt.Sprintf("METADATA Requires-Python is '%s' but pyproject.toml gives '%s'", b.Meta.Python.Requires, pyproject.RequiresPython))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("wheel metadata does not match the project: %s. Update buildmeta.yaml or pyproject.toml so they agree", strings.Join(problems, "; "))
+	}
+	return wheel.Tags(), nil
+}
+
+// readHeaders parses an email-header style dist-info file from a wheel
+func readHeaders(reader *zip.Reader, name string) (map[string][]string, error) {
+	f, err := reader.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("wheel has no %s: %w", name, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	headers := make(map[string][]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			// The body, such as a long description, follows the first blank line
+			break
+		}
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			headers[key] = append(headers[key], strings.TrimSpace(value))
+		}
+	}
+	return headers, nil
+}
+
+// first returns the first value of a header, or "" when it is absent
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,233 @@
+package builder
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// vcsDirs are never walked for sdist includes
+var vcsDirs = map[string]bool{".git": true, ".hg": true, ".svn": true, ".bzr": true}
+
+// rootSkipDirs are project-root directories holding environments and build output
+var rootSkipDirs = map[string]bool{".venv": true, ".zephyr": true, "build": true, "dist": true}
+
+// sdistFiles returns the files of the source distribution in archive order:
+// the project files and sources, plus any file matching python.include that
+// git does not ignore, minus every file matching python.exclude
+func (b *Builder) sdistFiles() ([]sourceFile, error) {
+	sources, err := b.collectSources()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]sourceFile)
+	add := func(path string) error {
+		rel, err := filepath.Rel(b.ProjectDir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, "../") {
+			return fmt.Errorf("'%s' is outside the project directory", path)
+		}
+		files[key] = b.newSourceFile(path, key)
+		return nil
+	}
+	for _, name := range sdistProjectFiles {
+		path := filepath.Join(b.ProjectDir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			if err := add(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, source := range sources {
+		if err := add(source.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(b.Meta.Python.Include) > 0 {
+		ignore, err := loadGitignore(b.ProjectDir)
+		if err != nil {
+			return nil, err
+		}
+		err = filepath.WalkDir(b.ProjectDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(b.ProjectDir, path)
+			if err != nil || rel == "." {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if d.IsDir() {
+				if vcsDirs[d.Name()] || d.Name() == "__pycache__" || (!strings.Contains(rel, "/") && rootSkipDirs[rel]) || ignore.ignored(rel, true) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() || isBuildArtifact(d.Name()) || ignore.ignored(rel, false) {
+				return nil
+			}
+			if matchesAny(b.Meta.Python.Include, rel) {
+				return add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect sdist includes: %w", err)
+		}
+	}
+
+	var result []sourceFile
+	for key, file := range files {
+		if !matchesAny(b.Meta.Python.Exclude, key) {
+			result = append(result, file)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ArchiveKey < result[j].ArchiveKey })
+	return result, nil
+}
+
+// matchesAny reports whether rel matches any of the patterns
+func matchesAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches a slash-separated project path against a glob in the
+// style of MANIFEST.in and .gitignore: a pattern without a slash matches a
+// file or directory name at any depth, "**" matches any number of
+// directories, and a pattern matching a directory covers everything beneath it
+func matchPattern(pattern, rel string) bool {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(pattern), "/"), "/")
+	if pattern == "" {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	if !strings.Contains(pattern, "/") {
+		for _, part := range parts {
+			if ok, _ := path.Match(pattern, part); ok {
+				return true
+			}
+		}
+		return false
+	}
+	patternParts := strings.Split(pattern, "/")
+	// A match of any leading directories includes their contents
+	for n := 1; n <= len(parts); n++ {
+		if matchParts(patternParts, parts[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchParts matches path segments against pattern segments, where "**"
+// stands for zero or more segments
+func matchParts(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchParts(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return matchParts(pattern[1:], parts[1:])
+}
+
+// ignoreRule is one line of a .gitignore file
+type ignoreRule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// gitignore holds the rules of a project's top-level .gitignore
+type gitignore struct {
+	rules []ignoreRule
+}
+
+// loadGitignore reads the .gitignore in dir; a missing file ignores nothing
+func loadGitignore(dir string) (*gitignore, error) {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if os.IsNotExist(err) {
+		return &gitignore{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .gitignore: %w", err)
+	}
+	defer f.Close()
+	ignore := &gitignore{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate, line = true, line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly, line = true, strings.TrimSuffix(line, "/")
+		}
+		// A slash anywhere but the end anchors the pattern to the root
+		if strings.Contains(line, "/") && !strings.HasPrefix(line, "**/") {
+			line = "/" + strings.TrimPrefix(line, "/")
+		}
+		rule.pattern = line
+		ignore.rules = append(ignore.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read .gitignore: %w", err)
+	}
+	return ignore, nil
+}
+
+// ignored reports whether git would ignore the path; the last matching rule wins
+func (g *gitignore) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matchIgnoreRule(rule.pattern, rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchIgnoreRule matches a whole path against a .gitignore pattern; anchored
+// patterns start with a slash, others match the last path segment
+func matchIgnoreRule(pattern, rel string) bool {
+	if strings.HasPrefix(pattern, "/") {
+		return matchParts(strings.Split(pattern[1:], "/"), strings.Split(rel, "/"))
+	}
+	if strings.HasPrefix(pattern, "**/") {
+		return matchParts(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+	}
+	ok, _ := path.Match(pattern, path.Base(rel))
+	return ok
+}
@@ -0,0 +1,205 @@
+package builder
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/pypi"
+)
+
+// PackFormat selects the layout of a packed application
+type PackFormat string
+
+const (
+	// PackZipapp flattens the project and its dependencies into the archive root, like python -m zipapp
+	PackZipapp PackFormat = "zipapp"
+	// PackPexLike keeps each dependency wheel in its own .deps/ directory added to sys.path at startup
+	PackPexLike PackFormat = "pex-like"
+)
+
+// ParsePackFormat parses a --format value for pack
+func ParsePackFormat(s string) (PackFormat, error) {
+	switch PackFormat(strings.ToLower(s)) {
+	case "", PackZipapp:
+		return PackZipapp, nil
+	case PackPexLike, "pex":
+		return PackPexLike, nil
+	}
+	return "", fmt.Errorf("unknown pack format '%s' (expected zipapp or pex-like)", s)
+}
+
+// PackOptions configures Pack
+type PackOptions struct {
+	Format PackFormat
+	// EntryPoint is "module:function", or "module" to run it like python -m
+	EntryPoint string
+	// Interpreter is written into the shebang line
+	Interpreter string
+	// Wheels are the locked pure-Python dependency wheels to bundle
+	Wheels []string
+}
+
+// DefaultEntryPoint returns the project's only console script, if it declares exactly one
+func (b *Builder) DefaultEntryPoint() (string, error) {
+	scripts := b.EntryPoints()["console_scripts"]
+	if len(scripts) == 1 {
+		for _, target := range scripts {
+			return target, nil
+		}
+	}
+	if len(scripts) == 0 {
+		return "", fmt.Errorf("no console_scripts entry point declared; pass one with --entry-point module:function")
+	}
+	return "", fmt.Errorf("%d console_scripts entry points declared; choose one with --entry-point module:function", len(scripts))
+}
+
+// Pack writes an executable .pyz archive with the project and its dependencies to outPath
+func (b *Builder) Pack(outPath string, opts PackOptions) error {
+	if opts.EntryPoint == "" {
+		return fmt.Errorf("an entry point is required")
+	}
+	if opts.Interpreter == "" {
+		opts.Interpreter = "/usr/bin/env python3"
+	}
+	sources, err := b.collectSources()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("#!" + opts.Interpreter + "\n")
+	w := zip.NewWriter(&buf)
+	// zip offsets must account for the shebang prepended to the archive
+	w.SetOffset(int64(buf.Len()))
+	written := make(map[string]bool)
+	addEntry := func(name string, data []byte) error {
+		if written[name] {
+			return fmt.Errorf("'%s' is provided by more than one distribution", name)
+		}
+		written[name] = true
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: b.Timestamp}
+		header.SetMode(fileMode(false))
+		f, err := w.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	}
+
+	for _, source := range sources {
+		data, err := os.ReadFile(source.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read '%s': %w", source.Path, err)
+		}
+		if err := addEntry(source.ArchiveKey, data); err != nil {
+			return fmt.Errorf("failed to pack '%s': %w", source.ArchiveKey, err)
+		}
+	}
+
+	wheels := append([]string(nil), opts.Wheels...)
+	sort.Slice(wheels, func(i, j int) bool { return filepath.Base(wheels[i]) < filepath.Base(wheels[j]) })
+	var depDirs []string
+	for _, wheelPath := range wheels {
+		prefix := ""
+		if opts.Format == PackPexLike {
+			prefix = ".deps/" + filepath.Base(wheelPath) + "/"
+			depDirs = append(depDirs, prefix)
+		}
+		if err := packWheel(wheelPath, prefix, addEntry); err != nil {
+			return err
+		}
+	}
+
+	if err := addEntry("__main__.py", []byte(mainModule(opts.EntryPoint, depDirs))); err != nil {
+		return fmt.Errorf("failed to write __main__.py: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finalize archive: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(outPath, buf.Bytes(), 0755); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", outPath, err)
+	}
+	return nil
+}
+
+// IsPureWheel reports whether a wheel filename is tagged for any platform
+func IsPureWheel(filename string) (bool, error) {
+	wheel, err := pypi.ParseWheelFilename(filename)
+	if err != nil {
+		return false, err
+	}
+	for _, tag := range wheel.PlatformTags {
+		if tag != "any" {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// packWheel copies the members of a pure-Python wheel into the archive under prefix
+func packWheel(wheelPath, prefix string, addEntry func(string, []byte) error) error {
+	name := filepath.Base(wheelPath)
+	pure, err := IsPureWheel(name)
+	if err != nil {
+		return err
+	}
+	if !pure {
+		return fmt.Errorf("%s is not a pure-Python wheel", name)
+	}
+	reader, err := zip.OpenReader(wheelPath)
+	if err != nil {
+		return fmt.Errorf("failed to open wheel '%s': %w", wheelPath, err)
+	}
+	defer reader.Close()
+	files := append([]*zip.File(nil), reader.File...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	for _, file := range files {
+		if file.FileInfo().IsDir() || strings.Contains(file.Name, ".data/") {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read '%s' from %s: %w", file.Name, name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read '%s' from %s: %w", file.Name, name, err)
+		}
+		if err := addEntry(prefix+file.Name, data); err != nil {
+			return fmt.Errorf("failed to pack '%s' from %s: %w", file.Name, name, err)
+		}
+	}
+	return nil
+}
+
+// mainModule renders the __main__.py bootstrap for an entry point
+func mainModule(entryPoint string, depDirs []string) string {
+	var b strings.Builder
+	b.WriteString("import os\nimport sys\n")
+	if len(depDirs) > 0 {
+		b.WriteString("_root = os.path.dirname(os.path.abspath(__file__))\n")
+		for i := len(depDirs) - 1; i >= 0; i-- {
+			fmt.Fprintf(&b, "sys.path.insert(1, os.path.join(_root, %q))\n", strings.TrimSuffix(depDirs[i], "/"))
+		}
+	}
+	module, function, hasFunction := strings.Cut(entryPoint, ":")
+	if !hasFunction {
+		fmt.Fprintf(&b, "import runpy\nrunpy.run_module(%q, run_name=\"__main__\", alter_sys=True)\n", module)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "import importlib\n_entry = importlib.import_module(%q)\n", strings.TrimSpace(module))
+	fmt.Fprintf(&b, "for _attr in %q.split(\".\"):\n    _entry = getattr(_entry, _attr)\n", strings.TrimSpace(function))
+	b.WriteString("sys.exit(_entry())\n")
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package builder
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"rimraf-adi.com/zephyr/pkg/remedy"
+)
+
+// sdistProjectFiles are copied into the sdist root when present
+var sdistProjectFiles = []string{"LICENSE", "README", "README.md", "README.rst", "README.txt", "buildmeta.yaml", "pyproject.toml"}
+
+// SdistFilename returns the filename of the source distribution for the project
+func (b *Builder) SdistFilename() string {
+	return fmt.Sprintf("%s-%s.tar.gz", b.DistributionName(), b.Meta.Version)
+}
+
+// BuildSdist writes a reproducible source distribution into outDir and returns
+// its path. The archive holds PKG-INFO, the project files and sources, and
+// the files selected by python.include and python.exclude.
+func (b *Builder) BuildSdist(outDir string) (string, error) {
+	files, err := b.sdistFiles()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", remedy.Annotate(fmt.Errorf("failed to create output directory '%s': %w", outDir, err))
+	}
+
+	var buf bytes.Buffer
+	// gzip headers carry no name and the normalized timestamp
+	gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return "", fmt.Errorf("failed to create sdist: %w", err)
+	}
+	gz.ModTime = b.Timestamp
+	tw := tar.NewWriter(gz)
+	prefix := fmt.Sprintf("%s-%s/", b.DistributionName(), b.Meta.Version)
+	addEntry := func(name string, data []byte, executable bool) error {
+		header := &tar.Header{
+			Name:     prefix + name,
+			Mode:     int64(fileMode(executable)),
+			Size:     int64(len(data)),
+			ModTime:  b.Timestamp,
+			Typeflag: tar.TypeReg,
+			Format:   tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if err := addEntry("PKG-INFO", []byte(b.CoreMetadata()), false); err != nil {
+		return "", fmt.Errorf("failed to add PKG-INFO to sdist: %w", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read '%s': %w", file.Path, err)
+		}
+		if err := addEntry(file.ArchiveKey, data, file.Executable); err != nil {
+			return "", fmt.Errorf("failed to add '%s' to sdist: %w", file.ArchiveKey, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", fmt.Errorf("failed to finalize sdist: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("failed to finalize sdist: %w", err)
+	}
+
+	sdistPath := filepath.Join(outDir, b.SdistFilename())
+	if err := os.WriteFile(sdistPath, buf.Bytes(), 0644); err != nil {
+		return "", remedy.Annotate(fmt.Errorf("failed to write sdist '%s': %w", sdistPath, err))
+	}
+	return sdistPath, nil
+}
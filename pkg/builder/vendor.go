@@ -0,0 +1,89 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// VendorManifest lists the packages in a vendor directory, one name==version
+// per line, like pip's vendor.txt
+const VendorManifest = "vendor.txt"
+
+// VendoredPackage is a locked dependency to copy into a vendor directory
+type VendoredPackage struct {
+	Name    string
+	Version string
+	// Wheel is the path of the package's pure-Python wheel
+	Wheel string
+}
+
+// Vendor replaces the contents of dir with the importable files of each
+// package's wheel and a VendorManifest listing them. The new tree is built
+// beside dir and swapped in, so a failure leaves the old one intact. To
+// avoid deleting unrelated files, an existing dir must be empty or hold a
+// VendorManifest from an earlier run.
+func Vendor(dir string, packages []VendoredPackage) error {
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		if _, err := os.Stat(filepath.Join(dir, VendorManifest)); err != nil {
+			return fmt.Errorf("'%s' is not empty and has no %s, so it was not created by zephyr vendor. Choose another directory or empty it first.", dir, VendorManifest)
+		}
+	}
+	parent := filepath.Dir(filepath.Clean(dir))
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("failed to create directory '%s': %w", parent, err)
+	}
+	tmp, err := os.MkdirTemp(parent, "."+filepath.Base(dir)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	var manifest strings.Builder
+	manifest.WriteString(vendorNote(filepath.Base(dir)))
+	for _, pkg := range packages {
+		err := packWheel(pkg.Wheel, "", func(name string, data []byte) error {
+			return writeVendored(tmp, name, data)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to vendor %s %s: %w", pkg.Name, pkg.Version, err)
+		}
+		fmt.Fprintf(&manifest, "%s==%s\n", pkg.Name, pkg.Version)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, VendorManifest), []byte(manifest.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", VendorManifest, err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove the previous '%s': %w", dir, err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return fmt.Errorf("failed to move vendored packages into '%s': %w", dir, err)
+	}
+	return nil
+}
+
+// vendorNote is the header of a VendorManifest, explaining how to import
+// the vendored packages
+func vendorNote(dirName string) string {
+	return fmt.Sprintf(`# Generated by zephyr vendor from the lockfile; do not edit.
+# These packages are importable once this directory is on sys.path, ahead of
+# site-packages, e.g. from a module next to it:
+#   sys.path.insert(0, os.path.join(os.path.dirname(__file__), %q))
+`, dirName)
+}
+
+// writeVendored writes one wheel member below root, refusing names that
+// would escape it
+func writeVendored(root, name string, data []byte) error {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("wheel member '%s' points outside the vendor directory", name)
+	}
+	dest := filepath.Join(root, filepath.FromSlash(clean))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dest, data, 0644)
+}
@@ -0,0 +1,223 @@
+package builder
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/pypi"
+	"rimraf-adi.com/zephyr/pkg/remedy"
+)
+
+// pythonTagPattern matches one interpreter tag of a wheel, e.g. py3 or cp312
+var pythonTagPattern = regexp.MustCompile(`^(py|cp|pp|ip|jy)\d+$`)
+
+// platformTagPattern matches a normalized platform tag, e.g. manylinux_2_17_x86_64
+var platformTagPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// WheelFilename returns the filename of the wheel for the project
+func (b *Builder) WheelFilename() string {
+	pythonTag, platformTag := b.wheelTags()
+	return fmt.Sprintf("%s-%s-%s-none-%s.whl", b.DistributionName(), b.Meta.Version, pythonTag, platformTag)
+}
+
+// wheelTags returns the compressed python and platform tags of the wheel.
+// Platform names are normalized like bdist_wheel's, so linux-x86_64 becomes linux_x86_64.
+func (b *Builder) wheelTags() (string, string) {
+	pythonTag, platformTag := b.PythonTag, b.PlatformTag
+	if pythonTag == "" {
+		pythonTag = "py3"
+	}
+	if platformTag == "" {
+		platformTag = "any"
+	}
+	platformTag = strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToLower(platformTag))
+	return pythonTag, platformTag
+}
+
+// ValidateTags checks the python and platform tag overrides
+func (b *Builder) ValidateTags() error {
+	pythonTag, platformTag := b.wheelTags()
+	for _, tag := range strings.Split(pythonTag, ".") {
+		if !pythonTagPattern.MatchString(tag) {
+			return fmt.Errorf("invalid python tag '%s': expected tags such as py3, py2.py3 or cp312", pythonTag)
+		}
+	}
+	if !platformTagPattern.MatchString(platformTag) {
+		return fmt.Errorf("invalid platform name '%s': expected a name such as any or linux_x86_64", b.PlatformTag)
+	}
+	return nil
+}
+
+// BuildWheel writes a reproducible pure-Python wheel into outDir and returns its path
+func (b *Builder) BuildWheel(outDir string) (string, error) {
+	if err := b.ValidateTags(); err != nil {
+		return "", err
+	}
+	sources, err := b.collectSources()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", remedy.Annotate(fmt.Errorf("failed to create output directory '%s': %w", outDir, err))
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	var record []string
+	addEntry := func(name string, data []byte, executable bool) error {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: b.Timestamp}
+		header.SetMode(fileMode(executable))
+		f, err := w.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		record = append(record, fmt.Sprintf("%s,sha256=%s,%d", name, base64.RawURLEncoding.EncodeToString(sum[:]), len(data)))
+		return nil
+	}
+
+	for _, source := range sources {
+		data, err := os.ReadFile(source.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read '%s': %w", source.Path, err)
+		}
+		if err := addEntry(source.ArchiveKey, data, source.Executable); err != nil {
+			return "", fmt.Errorf("failed to add '%s' to wheel: %w", source.ArchiveKey, err)
+		}
+	}
+
+	distInfo := fmt.Sprintf("%s-%s.dist-info", b.DistributionName(), b.Meta.Version)
+	entryPoints, err := b.entryPointsFile()
+	if err != nil {
+		return "", err
+	}
+	metadataFiles := []struct {
+		name    string
+		content string
+	}{
+		{"METADATA", b.CoreMetadata()},
+		{"WHEEL", b.wheelFile()},
+		{"entry_points.txt", entryPoints},
+	}
+	for _, file := range metadataFiles {
+		if file.content == "" {
+			continue
+		}
+		if err := addEntry(distInfo+"/"+file.name, []byte(file.content), false); err != nil {
+			return "", fmt.Errorf("failed to add %s to wheel: %w", file.name, err)
+		}
+	}
+
+	// RECORD lists itself without a hash and must be the last member
+	record = append(record, distInfo+"/RECORD,,")
+	header := &zip.FileHeader{Name: distInfo + "/RECORD", Method: zip.Deflate, Modified: b.Timestamp}
+	header.SetMode(fileMode(false))
+	f, err := w.CreateHeader(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to add RECORD to wheel: %w", err)
+	}
+	if _, err := f.Write([]byte(strings.Join(record, "\n") + "\n")); err != nil {
+		return "", fmt.Errorf("failed to add RECORD to wheel: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to finalize wheel: %w", err)
+	}
+
+	wheelPath := filepath.Join(outDir, b.WheelFilename())
+	if err := os.WriteFile(wheelPath, buf.Bytes(), 0644); err != nil {
+		return "", remedy.Annotate(fmt.Errorf("failed to write wheel '%s': %w", wheelPath, err))
+	}
+	return wheelPath, nil
+}
+
+// wheelFile renders the dist-info WHEEL file with one Tag line per expanded tag
+func (b *Builder) wheelFile() string {
+	content := "Wheel-Version: 1.0\nGenerator: zephyr\nRoot-Is-Purelib: true\n"
+	for _, tag := range b.Tags() {
+		content += "Tag: " + tag + "\n"
+	}
+	return content
+}
+
+// entryPointTarget matches an entry point object reference, module.path:attr.path
+var entryPointTarget = regexp.MustCompile(`^[A-Za-z_][\w.]*:[A-Za-z_][\w.]*$`)
+
+// EntryPoints returns the project's entry point groups: the entry-points
+// section plus build.scripts, which are console scripts as in pyproject's
+// [project.scripts]. The entry-points section wins when both name a script.
+func (b *Builder) EntryPoints() map[string]map[string]string {
+	groups := make(map[string]map[string]string)
+	if len(b.Meta.Build.Scripts) > 0 {
+		groups["console_scripts"] = make(map[string]string)
+		for name, target := range b.Meta.Build.Scripts {
+			groups["console_scripts"][name] = target
+		}
+	}
+	for group, entries := range b.Meta.EntryPoints {
+		if len(entries) == 0 {
+			continue
+		}
+		if groups[group] == nil {
+			groups[group] = make(map[string]string)
+		}
+		for name, target := range entries {
+			groups[group][name] = target
+		}
+	}
+	return groups
+}
+
+// entryPointsFile renders the dist-info entry_points.txt with groups and
+// names sorted, or "" when the project declares none
+func (b *Builder) entryPointsFile() (string, error) {
+	groups := b.EntryPoints()
+	var names []string
+	for group := range groups {
+		names = append(names, group)
+	}
+	sort.Strings(names)
+	var content strings.Builder
+	for i, group := range names {
+		if i > 0 {
+			content.WriteString("\n")
+		}
+		fmt.Fprintf(&content, "[%s]\n", group)
+		var entries []string
+		for name := range groups[group] {
+			entries = append(entries, name)
+		}
+		sort.Strings(entries)
+		for _, name := range entries {
+			target := strings.TrimSpace(groups[group][name])
+			// Allow a trailing [extras] annotation after the object reference
+			reference, _, _ := strings.Cut(target, "[")
+			if name == "" || strings.ContainsAny(name, "=[] \t") || !entryPointTarget.MatchString(strings.TrimSpace(reference)) {
+				return "", fmt.Errorf("invalid entry point '%s = %s' in group '%s': expected name = module:function", name, target, group)
+			}
+			fmt.Fprintf(&content, "%s = %s\n", name, target)
+		}
+	}
+	return content.String(), nil
+}
+
+// Tags returns the compatibility tags of the project's wheel, e.g. py3-none-any
+func (b *Builder) Tags() []string {
+	pythonTag, platformTag := b.wheelTags()
+	wheel := &pypi.WheelFilename{
+		PythonTags:   strings.Split(pythonTag, "."),
+		ABITags:      []string{"none"},
+		PlatformTags: []string{platformTag},
+	}
+	return wheel.Tags()
+}
@@ -0,0 +1,84 @@
+package buildmeta
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// constraintLine matches "name[extras] specifier" in a constraints file
+var constraintLine = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[[^\]]*\])?\s*(.*)$`)
+
+// ParseConstraints reads a pip constraints file, returning the version
+// specifier for each package it constrains. Options such as -r and --hash,
+// environment markers and comments are ignored, as are requirements without
+// a specifier or given by URL.
+func ParseConstraints(r io.Reader) (map[string]string, error) {
+	constraints := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			line = line[:i]
+		}
+		line, _, _ = strings.Cut(line, ";")
+		line, _, _ = strings.Cut(line, " --")
+		line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), "\\"))
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue
+		}
+		m := constraintLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("invalid constraint %q", line)
+		}
+		spec := strings.ReplaceAll(m[2], " ", "")
+		if spec == "" || strings.HasPrefix(spec, "@") {
+			continue
+		}
+		constraints[m[1]] = spec
+	}
+	return constraints, scanner.Err()
+}
+
+// LoadConstraints reads a constraints file from an http(s) URL with client,
+// or from a path relative to projectDir
+func LoadConstraints(source, projectDir string, client *http.Client) (map[string]string, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch constraints: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch constraints: %s", resp.Status)
+		}
+		return ParseConstraints(resp.Body)
+	}
+	path := source
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(projectDir, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read constraints: %w", err)
+	}
+	defer f.Close()
+	return ParseConstraints(f)
+}
+
+// AddConstraintSource records a constraints file to seed resolutions with,
+// reporting false when it is already listed
+func (bm *BuildMeta) AddConstraintSource(source string) bool {
+	for _, existing := range bm.Constraints {
+		if existing == source {
+			return false
+		}
+	}
+	bm.Constraints = append(bm.Constraints, source)
+	return true
+}
@@ -0,0 +1,72 @@
+package buildmeta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseConstraints(t *testing.T) {
+	content := `# shared pins
+-r base.txt
+--index-url https://pypi.example/simple
+requests==2.31.0 \
+    --hash=sha256:aa
+urllib3 >= 1.26, < 3  # comment
+Django[argon2]==4.2.7; python_version >= "3.8"
+six
+mylib @ https://files.example/mylib.whl
+`
+	constraints, err := ParseConstraints(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("ParseConstraints failed: %v", err)
+	}
+	expected := map[string]string{
+		"requests": "==2.31.0",
+		"urllib3":  ">=1.26,<3",
+		"Django":   "==4.2.7",
+	}
+	if len(constraints) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, constraints)
+	}
+	for name, spec := range expected {
+		if constraints[name] != spec {
+			t.Errorf("expected %s%s, got %q", name, spec, constraints[name])
+		}
+	}
+
+	if _, err := ParseConstraints(strings.NewReader("==1.0\n")); err == nil {
+		t.Error("expected an error for a constraint without a name")
+	}
+}
+
+func TestLoadConstraints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/constraints.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("requests==2.31.0\n"))
+	}))
+	defer server.Close()
+
+	constraints, err := LoadConstraints(server.URL+"/constraints.txt", "", server.Client())
+	if err != nil || constraints["requests"] != "==2.31.0" {
+		t.Errorf("expected requests==2.31.0 from URL, got %v (%v)", constraints, err)
+	}
+	if _, err := LoadConstraints(server.URL+"/missing.txt", "", server.Client()); err == nil {
+		t.Error("expected an error for a missing URL")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "constraints.txt"), []byte("six==1.16.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	constraints, err = LoadConstraints("constraints.txt", dir, nil)
+	if err != nil || constraints["six"] != "==1.16.0" {
+		t.Errorf("expected six==1.16.0 from file, got %v (%v)", constraints, err)
+	}
+}
@@ -0,0 +1,153 @@
+package buildmeta
+
+import (
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ProjectInfo is metadata detected from a project's git checkout and files
+type ProjectInfo struct {
+	Repository string
+	Homepage   string
+	License    string
+	Author     string
+	Email      string
+}
+
+// licenseFiles are the names a project's license text is looked up under
+var licenseFiles = []string{"LICENSE", "LICENSE.txt", "LICENSE.md", "LICENCE", "COPYING"}
+
+// DetectProjectInfo reads the origin remote and user from git config, and
+// the license from a LICENSE file, for the project in dir. Fields that
+// cannot be determined are left empty.
+func DetectProjectInfo(dir string) ProjectInfo {
+	info := ProjectInfo{
+		Author: gitConfig(dir, "user.name"),
+		Email:  gitConfig(dir, "user.email"),
+	}
+	if remote := gitConfig(dir, "remote.origin.url"); remote != "" {
+		info.Repository = RepositoryURL(remote)
+		info.Homepage = info.Repository
+	}
+	for _, name := range licenseFiles {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			info.License = IdentifyLicense(string(data))
+			break
+		}
+	}
+	return info
+}
+
+// gitConfig returns a git config value for the repository containing dir, or ""
+func gitConfig(dir, key string) string {
+	cmd := exec.Command("git", "config", "--get", key)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// scpRemote matches scp-style remotes such as git@github.com:owner/repo.git
+var scpRemote = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// RepositoryURL turns a git remote into a browsable https URL, dropping
+// credentials and the .git suffix. Remotes that are not URLs, such as local
+// paths, return "".
+func RepositoryURL(remote string) string {
+	remote = strings.TrimSpace(remote)
+	host, path := "", ""
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return ""
+		}
+		switch u.Scheme {
+		case "http", "https", "ssh", "git", "git+ssh", "ssh+git":
+		default:
+			return ""
+		}
+		host, path = u.Hostname(), u.Path
+		if u.Scheme == "http" || u.Scheme == "https" {
+			// Keep a non-default web port; ssh ports do not apply to https
+			host = u.Host
+		}
+	} else if match := scpRemote.FindStringSubmatch(remote); match != nil && !strings.Contains(match[1], `\`) && len(match[1]) > 1 {
+		host, path = match[1], match[2]
+	} else {
+		return ""
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || path == "" {
+		return ""
+	}
+	return "https://" + host + "/" + path
+}
+
+// licenseMarkers identify common licenses by phrases of their text, most
+// specific first; each entry needs all of its phrases
+var licenseMarkers = []struct {
+	id      string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"GNU AFFERO GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"LGPL-3.0", []string{"GNU LESSER GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"LGPL-2.1", []string{"GNU LESSER GENERAL PUBLIC LICENSE", "Version 2.1"}},
+	{"GPL-3.0", []string{"GNU GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"GPL-2.0", []string{"GNU GENERAL PUBLIC LICENSE", "Version 2"}},
+	{"Apache-2.0", []string{"Apache License", "Version 2.0"}},
+	{"MPL-2.0", []string{"Mozilla Public License", "2.0"}},
+	{"BSD-3-Clause", []string{"Redistribution and use in source and binary forms", "Neither the name"}},
+	{"BSD-2-Clause", []string{"Redistribution and use in source and binary forms"}},
+	{"ISC", []string{"Permission to use, copy, modify, and/or distribute this software for any"}},
+	{"MIT", []string{"Permission is hereby granted, free of charge"}},
+	{"Unlicense", []string{"This is free and unencumbered software released into the public domain"}},
+}
+
+// IdentifyLicense returns the SPDX identifier of a license text, or "" when
+// it is not recognized
+func IdentifyLicense(text string) string {
+	// Compare on single-spaced text so line wrapping does not matter
+	text = strings.Join(strings.Fields(text), " ")
+	for _, marker := range licenseMarkers {
+		matched := true
+		for _, phrase := range marker.phrases {
+			if !strings.Contains(strings.ToLower(text), strings.ToLower(phrase)) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return marker.id
+		}
+	}
+	return ""
+}
+
+// ApplyProjectInfo fills the empty Repository, Homepage, License, Author and
+// Email fields from info and returns the names of the fields it set
+func (bm *BuildMeta) ApplyProjectInfo(info ProjectInfo) []string {
+	var filled []string
+	for _, field := range []struct {
+		name  string
+		dst   *string
+		value string
+	}{
+		{"repository", &bm.Repository, info.Repository},
+		{"homepage", &bm.Homepage, info.Homepage},
+		{"license", &bm.License, info.License},
+		{"author", &bm.Author, info.Author},
+		{"email", &bm.Email, info.Email},
+	} {
+		if *field.dst == "" && field.value != "" {
+			*field.dst = field.value
+			filled = append(filled, field.name)
+		}
+	}
+	return filled
+}
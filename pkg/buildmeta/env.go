@@ -0,0 +1,159 @@
+package buildmeta
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DotEnvFile is the project-level environment file loaded automatically
+const DotEnvFile = ".env"
+
+// ParseDotEnv reads KEY=VALUE assignments from a .env file
+func ParseDotEnv(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file %s: %w", path, err)
+	}
+	defer f.Close()
+	vars, err := ParseDotEnvReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse env file %s: %w", path, err)
+	}
+	return vars, nil
+}
+
+// ParseDotEnvReader parses .env content. Lines may start with "export",
+// values may be single or double quoted, and "#" starts a comment outside
+// quotes. ${VAR} references in unquoted and double quoted values expand
+// against earlier assignments in the same file, then the process environment.
+func ParseDotEnvReader(r io.Reader) (map[string]string, error) {
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if !isValidEnvName(key) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", lineNo, key)
+		}
+		value, expand, err := parseDotEnvValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if expand {
+			value = os.Expand(value, func(name string) string {
+				if v, ok := vars[name]; ok {
+					return v
+				}
+				return os.Getenv(name)
+			})
+		}
+		vars[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// parseDotEnvValue unquotes a raw value and reports whether it should be expanded
+func parseDotEnvValue(raw string) (string, bool, error) {
+	if raw == "" {
+		return "", false, nil
+	}
+	switch quote := raw[0]; quote {
+	case '\'', '"':
+		end := strings.LastIndexByte(raw, quote)
+		if end == 0 {
+			return "", false, errors.New("unterminated quoted value")
+		}
+		if rest := strings.TrimSpace(raw[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", false, fmt.Errorf("unexpected text after quoted value: %q", rest)
+		}
+		value := raw[1:end]
+		if quote == '\'' {
+			return value, false, nil
+		}
+		value = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(value)
+		return value, true, nil
+	}
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+	return raw, true, nil
+}
+
+// isValidEnvName reports whether name is a portable environment variable name
+func isValidEnvName(name string) bool {
+	for i, c := range name {
+		if c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return name != ""
+}
+
+// ResolveEnv computes the variables a project's scripts run with.
+// Sources are applied lowest precedence first:
+//
+//  1. the env section of buildmeta.yaml
+//  2. the project's .env file, when present
+//  3. the calling process environment
+//  4. each of envFiles in order, as passed with --env-file
+//
+// Project files only supply defaults, so a variable exported in the shell
+// always wins over them, while explicit env files override everything.
+func (bm *BuildMeta) ResolveEnv(projectDir string, envFiles []string) (map[string]string, error) {
+	env := make(map[string]string)
+	for k, v := range bm.Env {
+		env[k] = v
+	}
+	dotenv, err := ParseDotEnv(filepath.Join(projectDir, DotEnvFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for k, v := range dotenv {
+		env[k] = v
+	}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok && k != "" {
+			env[k] = v
+		}
+	}
+	for _, file := range envFiles {
+		vars, err := ParseDotEnv(file)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range vars {
+			env[k] = v
+		}
+	}
+	return env, nil
+}
+
+// EnvList flattens an environment map into sorted KEY=VALUE pairs for exec
+func EnvList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,77 @@
+package buildmeta
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDotEnvReader(t *testing.T) {
+	content := `# comment
+export PLAIN=value
+SPACED = padded  # trailing comment
+SINGLE='no ${PLAIN} expansion'
+DOUBLE="line\nbreak ${PLAIN}"
+EMPTY=
+`
+	vars, err := ParseDotEnvReader(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("ParseDotEnvReader failed: %v", err)
+	}
+	expected := map[string]string{
+		"PLAIN":  "value",
+		"SPACED": "padded",
+		"SINGLE": "no ${PLAIN} expansion",
+		"DOUBLE": "line\nbreak value",
+		"EMPTY":  "",
+	}
+	for k, v := range expected {
+		if vars[k] != v {
+			t.Errorf("%s = %q, expected %q", k, vars[k], v)
+		}
+	}
+}
+
+func TestParseDotEnvReaderInvalid(t *testing.T) {
+	for _, content := range []string{"NOEQUALS", "1BAD=x", `Q="unterminated`} {
+		if _, err := ParseDotEnvReader(strings.NewReader(content)); err == nil {
+			t.Errorf("ParseDotEnvReader(%q) should fail", content)
+		}
+	}
+}
+
+func TestResolveEnvPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, DotEnvFile), []byte("ZEPHYR_T_DOTENV=dotenv\nZEPHYR_T_SHELL=dotenv\nZEPHYR_T_FILE=dotenv\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	override := filepath.Join(dir, "override.env")
+	if err := os.WriteFile(override, []byte("ZEPHYR_T_FILE=file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ZEPHYR_T_SHELL", "shell")
+	t.Setenv("ZEPHYR_T_FILE", "shell")
+
+	bm := NewBuildMeta("foo", "1.0.0")
+	bm.Env = map[string]string{"ZEPHYR_T_META": "meta", "ZEPHYR_T_DOTENV": "meta"}
+	env, err := bm.ResolveEnv(dir, []string{override})
+	if err != nil {
+		t.Fatalf("ResolveEnv failed: %v", err)
+	}
+	expected := map[string]string{
+		"ZEPHYR_T_META":   "meta",
+		"ZEPHYR_T_DOTENV": "dotenv",
+		"ZEPHYR_T_SHELL":  "shell",
+		"ZEPHYR_T_FILE":   "file",
+	}
+	for k, v := range expected {
+		if env[k] != v {
+			t.Errorf("%s = %q, expected %q", k, env[k], v)
+		}
+	}
+
+	if _, err := bm.ResolveEnv(dir, []string{filepath.Join(dir, "missing.env")}); err == nil {
+		t.Error("ResolveEnv should fail for a missing --env-file")
+	}
+}
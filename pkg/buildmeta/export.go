@@ -0,0 +1,461 @@
+package buildmeta
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/fsutil"
+)
+
+// ExportFormat identifies the tool flavour used when exporting to pyproject.toml
+type ExportFormat string
+
+const (
+	// FormatPEP621 writes standard [project] tables plus PEP 735 dependency groups
+	FormatPEP621 ExportFormat = "pep621"
+	// FormatPoetry writes [tool.poetry] tables
+	FormatPoetry ExportFormat = "poetry"
+	// FormatUV writes PEP 621 tables plus [tool.uv] index sources
+	FormatUV ExportFormat = "uv"
+)
+
+// ParseExportFormat validates a user supplied export format name
+func ParseExportFormat(name string) (ExportFormat, error) {
+	switch ExportFormat(strings.ToLower(name)) {
+	case FormatPEP621:
+		return FormatPEP621, nil
+	case FormatPoetry:
+		return FormatPoetry, nil
+	case FormatUV:
+		return FormatUV, nil
+	}
+	return "", fmt.Errorf("unsupported export format %q (expected poetry, pep621 or uv)", name)
+}
+
+// PackageSource describes a package index written into exported pyproject files
+type PackageSource struct {
+	Name string
+	URL  string
+}
+
+// exportOwnership lists the parts of pyproject.toml an export format rewrites.
+// Everything else, including the rest of the tables it writes keys into
+// (scripts, urls, authors, readme, ...), is left as it is.
+type exportOwnership struct {
+	// keys maps a table to the keys in it that hold dependencies
+	keys map[string][]string
+	// tables are rewritten as a whole. Entries ending in "." match every
+	// sub-table with that prefix.
+	tables []string
+	// sources names the array of tables listing package indexes, which is
+	// only rewritten when the export writes sources
+	sources string
+}
+
+// ownership returns what format rewrites on export
+func ownership(format ExportFormat) exportOwnership {
+	switch format {
+	case FormatPoetry:
+		return exportOwnership{
+			tables:  []string{"tool.poetry.dependencies", "tool.poetry.dev-dependencies", "tool.poetry.group."},
+			sources: "tool.poetry.source",
+		}
+	case FormatUV:
+		return exportOwnership{
+			keys:    map[string][]string{"project": {"dependencies"}},
+			tables:  []string{"project.dependencies", "project.optional-dependencies", "dependency-groups"},
+			sources: "tool.uv.index",
+		}
+	default:
+		return exportOwnership{
+			keys:   map[string][]string{"project": {"dependencies"}},
+			tables: []string{"project.dependencies", "project.optional-dependencies", "dependency-groups"},
+		}
+	}
+}
+
+// ownsTable reports whether a table is rewritten as a whole
+func (o exportOwnership) ownsTable(name string) bool {
+	if name != "" && name == o.sources {
+		return true
+	}
+	for _, prefix := range o.tables {
+		if strings.HasSuffix(prefix, ".") {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderPyProjectTables renders the pyproject.toml tables for the given format
+func RenderPyProjectTables(buildMeta *BuildMeta, format ExportFormat, sources []PackageSource) string {
+	switch format {
+	case FormatPoetry:
+		return renderPoetryTables(buildMeta, sources)
+	case FormatUV:
+		content := renderPEP621Tables(buildMeta)
+		for _, source := range sources {
+			content += "\n[[tool.uv.index]]\n"
+			content += fmt.Sprintf("name = %q\n", source.Name)
+			content += fmt.Sprintf("url = %q\n", source.URL)
+		}
+		return content
+	default:
+		return renderPEP621Tables(buildMeta)
+	}
+}
+
+// renderPEP621Tables renders [project], optional dependencies and dependency groups
+func renderPEP621Tables(buildMeta *BuildMeta) string {
+	var b strings.Builder
+	b.WriteString("[project]\n")
+	b.WriteString(fmt.Sprintf("name = %q\n", buildMeta.Name))
+	b.WriteString(fmt.Sprintf("version = %q\n", buildMeta.Version))
+	if buildMeta.Description != "" {
+		b.WriteString(fmt.Sprintf("description = %q\n", buildMeta.Description))
+	}
+	if buildMeta.Python.Requires != "" {
+		b.WriteString(fmt.Sprintf("requires-python = %q\n", buildMeta.Python.Requires))
+	}
+	b.WriteString(renderRequirementArray("dependencies", buildMeta.GetDependencies()))
+
+	if len(buildMeta.OptionalDependencies) > 0 {
+		b.WriteString("\n[project.optional-dependencies]\n")
+		for _, group := range sortedKeys(buildMeta.OptionalDependencies) {
+			b.WriteString(renderRequirementArray(group, buildMeta.OptionalDependencies[group].Direct))
+		}
+	}
+
+	if len(buildMeta.GetDevDependencies()) > 0 {
+		b.WriteString("\n[dependency-groups]\n")
+		b.WriteString(renderRequirementArray("dev", buildMeta.GetDevDependencies()))
+	}
+	return b.String()
+}
+
+// renderPoetryTables renders the [tool.poetry] family of tables
+func renderPoetryTables(buildMeta *BuildMeta, sources []PackageSource) string {
+	var b strings.Builder
+	b.WriteString("[tool.poetry]\n")
+	b.WriteString(fmt.Sprintf("name = %q\n", buildMeta.Name))
+	b.WriteString(fmt.Sprintf("version = %q\n", buildMeta.Version))
+	b.WriteString(fmt.Sprintf("description = %q\n", buildMeta.Description))
+	if buildMeta.Author != "" {
+		author := buildMeta.Author
+		if buildMeta.Email != "" {
+			author = fmt.Sprintf("%s <%s>", buildMeta.Author, buildMeta.Email)
+		}
+		b.WriteString(fmt.Sprintf("authors = [%q]\n", author))
+	}
+
+	b.WriteString("\n[tool.poetry.dependencies]\n")
+	if buildMeta.Python.Requires != "" {
+		b.WriteString(fmt.Sprintf("python = %q\n", buildMeta.Python.Requires))
+	}
+	b.WriteString(renderPoetryDependencies(buildMeta.GetDependencies()))
+
+	if len(buildMeta.GetDevDependencies()) > 0 {
+		b.WriteString("\n[tool.poetry.group.dev.dependencies]\n")
+		b.WriteString(renderPoetryDependencies(buildMeta.GetDevDependencies()))
+	}
+
+	for _, group := range sortedKeys(buildMeta.OptionalDependencies) {
+		b.WriteString(fmt.Sprintf("\n[tool.poetry.group.%s]\noptional = true\n", group))
+		b.WriteString(fmt.Sprintf("\n[tool.poetry.group.%s.dependencies]\n", group))
+		b.WriteString(renderPoetryDependencies(buildMeta.OptionalDependencies[group].Direct))
+	}
+
+	for _, source := range sources {
+		b.WriteString("\n[[tool.poetry.source]]\n")
+		b.WriteString(fmt.Sprintf("name = %q\n", source.Name))
+		b.WriteString(fmt.Sprintf("url = %q\n", source.URL))
+		b.WriteString("priority = \"primary\"\n")
+	}
+	return b.String()
+}
+
+// renderRequirementArray renders a TOML array of PEP 508 requirement strings
+func renderRequirementArray(key string, deps map[string]string) string {
+	if len(deps) == 0 {
+		return fmt.Sprintf("%s = []\n", key)
+	}
+	content := fmt.Sprintf("%s = [\n", key)
+	for _, name := range sortedKeys(deps) {
+		content += fmt.Sprintf("    %q,\n", name+requirementSpecifier(deps[name]))
+	}
+	return content + "]\n"
+}
+
+// requirementSpecifier turns a stored constraint into a PEP 440 specifier.
+// zephyr update stores the bare version it picked, which pins it.
+func requirementSpecifier(constraint string) string {
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "" || constraint == "*" {
+		return ""
+	}
+	if c := constraint[0]; c >= '0' && c <= '9' {
+		return "==" + constraint
+	}
+	return constraint
+}
+
+// renderPoetryDependencies renders name = "constraint" pairs
+func renderPoetryDependencies(deps map[string]string) string {
+	var content string
+	for _, name := range sortedKeys(deps) {
+		constraint := deps[name]
+		if constraint == "" {
+			constraint = "*"
+		}
+		content += fmt.Sprintf("%s = %q\n", name, constraint)
+	}
+	return content
+}
+
+// MergePyProjectTables replaces the dependency keys and tables owned by
+// format in existing with those in generated. Everything else is kept as it
+// is: other keys of the tables written into, their sub-tables (scripts, urls,
+// entry-points, ...) and tables owned by other tools (build-system,
+// tool.black, ...). Generated tables missing from existing are appended.
+func MergePyProjectTables(existing, generated string, format ExportFormat) string {
+	if strings.TrimSpace(existing) == "" {
+		return generated
+	}
+	owned := ownership(format)
+	fresh := splitSections(generated)
+	if !hasSection(fresh, owned.sources) {
+		owned.sources = ""
+	}
+	emitted := make([]bool, len(fresh))
+	var out []string
+	for _, section := range splitSections(existing) {
+		switch keys := owned.keys[section.name]; {
+		case section.name != "" && owned.ownsTable(section.name):
+			// Replaced by the generated tables of the same name, if any
+			trailing := trailingBlankLines(section.lines)
+			for i, table := range fresh {
+				if table.name != section.name || emitted[i] {
+					continue
+				}
+				emitted[i] = true
+				if len(out) > 0 && out[len(out)-1] != "" {
+					out = append(out, "")
+				}
+				out = append(out, trimBlankLines(table.lines)...)
+			}
+			out = append(out, trailing...)
+		case keys != nil:
+			lines, _, at := partitionKeys(section.lines, keys)
+			for i, table := range fresh {
+				if table.name != section.name || emitted[i] {
+					continue
+				}
+				emitted[i] = true
+				_, picked, _ := partitionKeys(table.lines, keys)
+				if at < 0 {
+					at = len(lines) - len(trailingBlankLines(lines))
+				}
+				lines = append(lines[:at], append(picked, lines[at:]...)...)
+			}
+			out = append(out, lines...)
+		default:
+			for i, table := range fresh {
+				if table.name == section.name {
+					emitted[i] = true
+				}
+			}
+			out = append(out, section.lines...)
+		}
+	}
+	content := strings.TrimRight(strings.Join(out, "\n"), "\n")
+	for i, table := range fresh {
+		if !emitted[i] && table.name != "" {
+			content += "\n\n" + strings.Join(trimBlankLines(table.lines), "\n")
+		}
+	}
+	return content + "\n"
+}
+
+// tomlSection is a table header line and the lines up to the next header.
+// Lines before the first header form a section with an empty name.
+type tomlSection struct {
+	name  string
+	lines []string
+}
+
+// splitSections splits TOML content at its table headers
+func splitSections(content string) []tomlSection {
+	sections := []tomlSection{{}}
+	var scanner tomlScanner
+	for _, line := range strings.Split(content, "\n") {
+		if scanner.topLevel() {
+			if name, ok := tableHeader(line); ok {
+				sections = append(sections, tomlSection{name: name, lines: []string{line}})
+				continue
+			}
+		}
+		scanner.scan(line)
+		last := &sections[len(sections)-1]
+		last.lines = append(last.lines, line)
+	}
+	return sections
+}
+
+// hasSection reports whether a section called name exists
+func hasSection(sections []tomlSection, name string) bool {
+	for _, section := range sections {
+		if name != "" && section.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// partitionKeys splits a section's lines into the ones holding keys and the
+// rest, multi-line values included. at is the position in rest the first of
+// keys was found at, or -1.
+func partitionKeys(lines []string, keys []string) (rest, picked []string, at int) {
+	at = -1
+	var scanner tomlScanner
+	inKey := false
+	for i, line := range lines {
+		if scanner.topLevel() {
+			inKey = i > 0 && containsString(keys, keyName(line))
+			if inKey && at < 0 {
+				at = len(rest)
+			}
+		}
+		scanner.scan(line)
+		if inKey {
+			picked = append(picked, line)
+		} else {
+			rest = append(rest, line)
+		}
+	}
+	return rest, picked, at
+}
+
+// keyName returns the key a "key = value" line assigns, unquoted
+func keyName(line string) string {
+	line = strings.TrimSpace(line)
+	idx := strings.Index(line, "=")
+	if idx <= 0 || strings.HasPrefix(line, "#") {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(line[:idx]), `"'`)
+}
+
+// tomlScanner follows the lines of a TOML document and tracks whether an
+// array or multi-line string is still open, so headers and keys are only
+// recognised where they start a statement
+type tomlScanner struct {
+	depth     int
+	multiline string
+}
+
+// topLevel reports whether the next line starts a new statement
+func (s *tomlScanner) topLevel() bool {
+	return s.depth <= 0 && s.multiline == ""
+}
+
+// scan reads one line, skipping strings and comments
+func (s *tomlScanner) scan(line string) {
+	for i := 0; i < len(line); i++ {
+		if s.multiline != "" {
+			if strings.HasPrefix(line[i:], s.multiline) {
+				i += len(s.multiline) - 1
+				s.multiline = ""
+			} else if line[i] == '\\' && s.multiline == `"""` {
+				i++
+			}
+			continue
+		}
+		switch c := line[i]; c {
+		case '#':
+			return
+		case '"', '\'':
+			if triple := strings.Repeat(string(c), 3); strings.HasPrefix(line[i:], triple) {
+				s.multiline = triple
+				i += 2
+				continue
+			}
+			for i++; i < len(line) && line[i] != c; i++ {
+				if c == '"' && line[i] == '\\' {
+					i++
+				}
+			}
+		case '[':
+			s.depth++
+		case ']':
+			s.depth--
+		}
+	}
+}
+
+// trailingBlankLines returns the blank lines lines ends with
+func trailingBlankLines(lines []string) []string {
+	end := len(lines)
+	for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	return lines[end:]
+}
+
+// trimBlankLines drops the blank lines lines ends with
+func trimBlankLines(lines []string) []string {
+	return lines[:len(lines)-len(trailingBlankLines(lines))]
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// tableHeader extracts the table name from a [table] or [[array]] header line
+func tableHeader(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") {
+		return "", false
+	}
+	if idx := strings.Index(line, "#"); idx > 0 {
+		line = strings.TrimSpace(line[:idx])
+	}
+	line = strings.TrimPrefix(strings.TrimPrefix(line, "["), "[")
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "]"), "]")
+	return strings.TrimSpace(line), true
+}
+
+// ExportPyProjectFormat writes buildmeta dependencies into filePath using the given
+// tool format, preserving any unrelated tables already present in the file
+func ExportPyProjectFormat(filePath string, buildMeta *BuildMeta, format ExportFormat, sources []PackageSource) error {
+	existing := ""
+	if data, err := os.ReadFile(filePath); err == nil {
+		existing = string(data)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	generated := RenderPyProjectTables(buildMeta, format, sources)
+	content := MergePyProjectTables(existing, generated, format)
+	return fsutil.WriteFileAtomic(filePath, []byte(content), 0644)
+}
+
+// sortedKeys returns the keys of a string-keyed map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,184 @@
+package buildmeta
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newExportFixture() *BuildMeta {
+	bm := NewBuildMeta("foo", "1.0.0")
+	bm.AddDependency("requests", ">=2.25.0")
+	bm.AddDependency("click", "")
+	bm.AddDevDependency("pytest", ">=7.0")
+	bm.AddOptionalDependency("socks", "pysocks", ">=1.5")
+	return bm
+}
+
+func TestParseExportFormat(t *testing.T) {
+	for _, name := range []string{"poetry", "pep621", "UV"} {
+		if _, err := ParseExportFormat(name); err != nil {
+			t.Errorf("ParseExportFormat(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := ParseExportFormat("pipenv"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+func TestRenderPyProjectTables_PEP621(t *testing.T) {
+	out := RenderPyProjectTables(newExportFixture(), FormatPEP621, nil)
+	for _, want := range []string{
+		"[project]",
+		`"click",`,
+		`"requests>=2.25.0",`,
+		"[project.optional-dependencies]",
+		`"pysocks>=1.5",`,
+		"[dependency-groups]",
+		`"pytest>=7.0",`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("PEP 621 output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, `"click"`) > strings.Index(out, `"requests`) {
+		t.Error("Dependencies should be sorted")
+	}
+}
+
+func TestRenderPyProjectTables_PoetryAndUV(t *testing.T) {
+	sources := []PackageSource{{Name: "internal", URL: "https://pypi.example.com/simple"}}
+	poetry := RenderPyProjectTables(newExportFixture(), FormatPoetry, sources)
+	for _, want := range []string{
+		"[tool.poetry.dependencies]",
+		`python = ">=3.8"`,
+		`click = "*"`,
+		"[tool.poetry.group.dev.dependencies]",
+		"[tool.poetry.group.socks.dependencies]",
+		"[[tool.poetry.source]]",
+	} {
+		if !strings.Contains(poetry, want) {
+			t.Errorf("Poetry output missing %q:\n%s", want, poetry)
+		}
+	}
+	uv := RenderPyProjectTables(newExportFixture(), FormatUV, sources)
+	if !strings.Contains(uv, "[[tool.uv.index]]") || !strings.Contains(uv, "[project]") {
+		t.Errorf("uv output missing tables:\n%s", uv)
+	}
+}
+
+func TestExportPyProjectFormat_PreservesUnrelatedTables(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pyproject.toml")
+	existing := "[build-system]\nrequires = [\"hatchling\"]\n\n[tool.poetry]\nname = \"old\"\n\n[tool.poetry.dependencies]\nflask = \"*\"\n\n[tool.black]\nline-length = 100\n"
+	os.WriteFile(path, []byte(existing), 0644)
+	if err := ExportPyProjectFormat(path, newExportFixture(), FormatPoetry, nil); err != nil {
+		t.Fatalf("ExportPyProjectFormat failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	out := string(data)
+	if !strings.Contains(out, "[build-system]") || !strings.Contains(out, "line-length = 100") {
+		t.Errorf("Unrelated tables were dropped:\n%s", out)
+	}
+	if strings.Contains(out, "flask") {
+		t.Errorf("Stale poetry dependencies were kept:\n%s", out)
+	}
+	if !strings.Contains(out, `name = "old"`) {
+		t.Errorf("Existing [tool.poetry] keys were rewritten:\n%s", out)
+	}
+	if strings.Count(out, "[tool.poetry]") != 1 {
+		t.Errorf("Expected exactly one [tool.poetry] table:\n%s", out)
+	}
+}
+
+func TestRenderPyProjectTables_PinsBareVersions(t *testing.T) {
+	bm := NewBuildMeta("foo", "1.0.0")
+	bm.AddDependency("requests", "2.31.0")
+	bm.AddDependency("click", "*")
+	out := RenderPyProjectTables(bm, FormatPEP621, nil)
+	if !strings.Contains(out, `"requests==2.31.0",`) || !strings.Contains(out, `"click",`) {
+		t.Errorf("Expected bare versions pinned and * dropped:\n%s", out)
+	}
+}
+
+func TestExportPyProjectFormat_RoundTripKeepsProjectData(t *testing.T) {
+	existing := `[build-system]
+requires = ["hatchling"]
+
+[project]
+name = "foo"
+version = "0.9.0"
+readme = "README.md"
+authors = [{ name = "Ada", email = "ada@example.com" }]
+classifiers = [
+    "License :: OSI Approved :: MIT License", # [not a table]
+    "Programming Language :: Python :: 3",
+]
+dependencies = [
+    "flask>=2",
+]
+description = """
+A [tool] that
+[spans] lines
+"""
+
+[project.optional-dependencies]
+stale = ["old"]
+
+[project.scripts]
+foo = "foo.cli:main"
+
+[project.urls]
+Homepage = "https://example.com"
+
+[tool.poetry.scripts]
+foo-poetry = "foo.cli:main"
+
+[tool.poetry.dependencies]
+flask = "^2"
+`
+	for _, format := range []ExportFormat{FormatPEP621, FormatUV, FormatPoetry} {
+		path := filepath.Join(t.TempDir(), "pyproject.toml")
+		os.WriteFile(path, []byte(existing), 0644)
+		if err := ExportPyProjectFormat(path, newExportFixture(), format, nil); err != nil {
+			t.Fatalf("%s: ExportPyProjectFormat failed: %v", format, err)
+		}
+		data, _ := os.ReadFile(path)
+		out := string(data)
+		for _, kept := range []string{
+			"[build-system]",
+			`readme = "README.md"`,
+			`authors = [{ name = "Ada", email = "ada@example.com" }]`,
+			`"Programming Language :: Python :: 3",`,
+			"A [tool] that\n[spans] lines\n",
+			"[project.scripts]\nfoo = \"foo.cli:main\"\n",
+			"[project.urls]\nHomepage = \"https://example.com\"\n",
+			"[tool.poetry.scripts]\nfoo-poetry = \"foo.cli:main\"\n",
+		} {
+			if !strings.Contains(out, kept) {
+				t.Errorf("%s: export dropped %q:\n%s", format, kept, out)
+			}
+		}
+		if format == FormatPoetry {
+			if strings.Contains(out, `flask = "^2"`) || !strings.Contains(out, `requests = ">=2.25.0"`) {
+				t.Errorf("poetry: dependencies were not rewritten:\n%s", out)
+			}
+		} else {
+			if strings.Contains(out, "flask>=2") || strings.Contains(out, "stale") || !strings.Contains(out, `"requests>=2.25.0",`) {
+				t.Errorf("%s: dependencies were not rewritten:\n%s", format, out)
+			}
+			if strings.Count(out, "[project]") != 1 || strings.Count(out, "dependencies = [") != 1 {
+				t.Errorf("%s: expected one [project] table with one dependencies key:\n%s", format, out)
+			}
+		}
+
+		if err := ExportPyProjectFormat(path, newExportFixture(), format, nil); err != nil {
+			t.Fatalf("%s: second export failed: %v", format, err)
+		}
+		again, _ := os.ReadFile(path)
+		if string(again) != out {
+			t.Errorf("%s: exporting twice changed the file:\n%s\n---\n%s", format, out, again)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package buildmeta
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dependency groups that are not optional-dependencies entries
+const (
+	// GroupMain holds the project's dependencies
+	GroupMain = "main"
+	// GroupDev holds the dev-dependencies
+	GroupDev = "dev"
+)
+
+// GroupNames returns the project's dependency groups: main, dev, then the
+// optional-dependencies groups in name order
+func (bm *BuildMeta) GroupNames() []string {
+	names := []string{GroupMain, GroupDev}
+	return append(names, sortedKeys(bm.OptionalDependencies)...)
+}
+
+// SelectGroups works out which dependency groups a command covers from its
+// --group, --only and --without flags. Without flags only main is selected,
+// so compliance reports and exports leave out development dependencies.
+// --group adds groups to main, --only replaces the selection, and --without
+// removes groups from it.
+func (bm *BuildMeta) SelectGroups(with, only, without []string) ([]string, error) {
+	known := make(map[string]bool)
+	for _, name := range bm.GroupNames() {
+		known[name] = true
+	}
+	for _, names := range [][]string{with, only, without} {
+		for _, name := range names {
+			if !known[name] {
+				return nil, fmt.Errorf("unknown dependency group %q (expected one of %s)", name, strings.Join(bm.GroupNames(), ", "))
+			}
+		}
+	}
+	if len(with) > 0 && len(only) > 0 {
+		return nil, fmt.Errorf("--group and --only cannot be combined")
+	}
+	selected := map[string]bool{GroupMain: true}
+	if len(only) > 0 {
+		selected = make(map[string]bool)
+	}
+	for _, name := range append(append([]string(nil), with...), only...) {
+		selected[name] = true
+	}
+	for _, name := range without {
+		delete(selected, name)
+	}
+	var groups []string
+	for _, name := range bm.GroupNames() {
+		if selected[name] {
+			groups = append(groups, name)
+		}
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("no dependency groups selected")
+	}
+	return groups, nil
+}
+
+// GroupDependencies merges the direct dependencies of groups into one map of
+// name to constraint
+func (bm *BuildMeta) GroupDependencies(groups []string) map[string]string {
+	deps := make(map[string]string)
+	for _, group := range groups {
+		var members map[string]string
+		switch group {
+		case GroupMain:
+			members = bm.GetDependencies()
+		case GroupDev:
+			members = bm.GetDevDependencies()
+		default:
+			members = bm.GetOptionalDependencies(group)
+		}
+		for name, constraint := range members {
+			deps[name] = constraint
+		}
+	}
+	return deps
+}
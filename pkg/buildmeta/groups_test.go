@@ -0,0 +1,48 @@
+package buildmeta
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectGroups(t *testing.T) {
+	bm := NewBuildMeta("app", "1.0.0")
+	bm.AddDependency("requests", ">=2.0")
+	bm.AddDevDependency("pytest", ">=8.0")
+	bm.AddOptionalDependency("test", "hypothesis", "*")
+	bm.AddOptionalDependency("docs", "sphinx", ">=7")
+
+	tests := []struct {
+		with, only, without []string
+		want                string
+	}{
+		{want: "main"},
+		{with: []string{"docs"}, want: "main,docs"},
+		{only: []string{"dev", "test"}, want: "dev,test"},
+		{with: []string{"dev", "test"}, without: []string{"test"}, want: "main,dev"},
+	}
+	for _, tt := range tests {
+		groups, err := bm.SelectGroups(tt.with, tt.only, tt.without)
+		if err != nil {
+			t.Errorf("SelectGroups(%v, %v, %v) failed: %v", tt.with, tt.only, tt.without, err)
+			continue
+		}
+		if got := strings.Join(groups, ","); got != tt.want {
+			t.Errorf("SelectGroups(%v, %v, %v) = %s, want %s", tt.with, tt.only, tt.without, got, tt.want)
+		}
+	}
+	for _, bad := range [][3][]string{
+		{{"lint"}, nil, nil},
+		{{"dev"}, {"test"}, nil},
+		{nil, nil, {"main"}},
+	} {
+		if _, err := bm.SelectGroups(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("SelectGroups(%v) should fail", bad)
+		}
+	}
+
+	deps := bm.GroupDependencies([]string{GroupMain, "test"})
+	if len(deps) != 2 || deps["requests"] != ">=2.0" || deps["hypothesis"] != "*" {
+		t.Errorf("GroupDependencies = %v", deps)
+	}
+}
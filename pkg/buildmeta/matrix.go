@@ -0,0 +1,78 @@
+package buildmeta
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MatrixConfig lists the interpreters zephyr envs matrix tests against and
+// the task it runs in each environment
+type MatrixConfig struct {
+	// Python entries are versions such as "3.12" or inclusive minor ranges such as "3.9-3.12"
+	Python []string `yaml:"python,omitempty"`
+	// Task is the script run in every environment, "test" when empty
+	Task string `yaml:"task,omitempty"`
+}
+
+// Pythons expands the matrix's Python entries into individual versions,
+// keeping their order and dropping duplicates
+func (m MatrixConfig) Pythons() ([]string, error) {
+	var versions []string
+	seen := make(map[string]bool)
+	for _, entry := range m.Python {
+		expanded, err := expandPythonRange(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range expanded {
+			if !seen[v] {
+				seen[v] = true
+				versions = append(versions, v)
+			}
+		}
+	}
+	return versions, nil
+}
+
+// expandPythonRange turns "3.9-3.12" into 3.9, 3.10, 3.11 and 3.12; other
+// entries are returned unchanged
+func expandPythonRange(entry string) ([]string, error) {
+	low, high, ok := strings.Cut(entry, "-")
+	if !ok {
+		if entry == "" {
+			return nil, fmt.Errorf("empty matrix python entry")
+		}
+		return []string{entry}, nil
+	}
+	lowMajor, lowMinor, err := splitMinor(strings.TrimSpace(low))
+	if err != nil {
+		return nil, fmt.Errorf("invalid matrix python range %q: %w", entry, err)
+	}
+	highMajor, highMinor, err := splitMinor(strings.TrimSpace(high))
+	if err != nil {
+		return nil, fmt.Errorf("invalid matrix python range %q: %w", entry, err)
+	}
+	if lowMajor != highMajor || lowMinor > highMinor {
+		return nil, fmt.Errorf("invalid matrix python range %q: expected increasing minor versions of one major version, e.g. 3.9-3.12", entry)
+	}
+	var versions []string
+	for minor := lowMinor; minor <= highMinor; minor++ {
+		versions = append(versions, fmt.Sprintf("%d.%d", lowMajor, minor))
+	}
+	return versions, nil
+}
+
+// splitMinor parses a major.minor version
+func splitMinor(v string) (int, int, error) {
+	major, minor, ok := strings.Cut(v, ".")
+	if !ok {
+		return 0, 0, fmt.Errorf("%q is not a major.minor version", v)
+	}
+	ma, err1 := strconv.Atoi(major)
+	mi, err2 := strconv.Atoi(minor)
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("%q is not a major.minor version", v)
+	}
+	return ma, mi, nil
+}
@@ -0,0 +1,23 @@
+package buildmeta
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatrixPythons(t *testing.T) {
+	m := MatrixConfig{Python: []string{"3.9-3.11", "3.11", "pypy3.10"}}
+	got, err := m.Pythons()
+	if err != nil {
+		t.Fatalf("Pythons failed: %v", err)
+	}
+	want := []string{"3.9", "3.10", "3.11", "pypy3.10"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Pythons = %v, want %v", got, want)
+	}
+	for _, entry := range []string{"3.12-3.9", "3.x-3.12", "2.7-3.12", ""} {
+		if _, err := (MatrixConfig{Python: []string{entry}}).Pythons(); err == nil {
+			t.Errorf("expected an error for %q", entry)
+		}
+	}
+}
@@ -7,23 +7,32 @@ import (
 	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"rimraf-adi.com/zephyr/pkg/fsutil"
 )
 
 // Parser handles parsing and writing of buildmeta.yaml files
 type Parser struct {
 	filePath string
+	fs       fsutil.FS
 }
 
 // NewParser creates a new parser for buildmeta.yaml
 func NewParser(filePath string) *Parser {
 	return &Parser{
 		filePath: filePath,
+		fs:       fsutil.OS,
 	}
 }
 
+// SetFS makes the parser read and write the manifest through fsys instead of the disk
+func (p *Parser) SetFS(fsys fsutil.FS) {
+	p.fs = fsys
+}
+
 // Parse parses a buildmeta.yaml file
 func (p *Parser) Parse() (*BuildMeta, error) {
-	data, err := os.ReadFile(p.filePath)
+	data, err := p.fs.ReadFile(p.filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read buildmeta.yaml: %w", err)
 	}
@@ -55,11 +64,11 @@ func (p *Parser) Write(buildMeta *BuildMeta) error {
 	
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(p.filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := p.fs.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 	
-	if err := os.WriteFile(p.filePath, data, 0644); err != nil {
+	if err := p.fs.WriteFileAtomic(p.filePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write buildmeta.yaml: %w", err)
 	}
 	
@@ -68,25 +77,59 @@ func (p *Parser) Write(buildMeta *BuildMeta) error {
 
 // Exists checks if the buildmeta.yaml file exists
 func (p *Parser) Exists() bool {
-	_, err := os.Stat(p.filePath)
+	_, err := p.fs.Stat(p.filePath)
 	return err == nil
 }
 
 // Remove removes the buildmeta.yaml file
 func (p *Parser) Remove() error {
-	return os.Remove(p.filePath)
+	return p.fs.Remove(p.filePath)
+}
+
+// manifestName is the file a project directory keeps its configuration in
+var manifestName = "buildmeta.yaml"
+
+// SetManifestName makes ParseFromDirectory, WriteToDirectory and
+// FindProjectRoot use name instead of buildmeta.yaml, for repositories that
+// keep several manifests side by side
+func SetManifestName(name string) {
+	manifestName = name
+}
+
+// ManifestName returns the manifest file name set by SetManifestName
+func ManifestName() string {
+	return manifestName
+}
+
+// FindProjectRoot returns the nearest directory at or above dir that holds a
+// manifest, the way git finds its repository from a subdirectory
+func FindProjectRoot(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		if info, err := os.Stat(filepath.Join(dir, manifestName)); err == nil && !info.IsDir() {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
 }
 
 // ParseFromDirectory parses buildmeta.yaml from a directory
 func ParseFromDirectory(dir string) (*BuildMeta, error) {
-	filePath := filepath.Join(dir, "buildmeta.yaml")
+	filePath := filepath.Join(dir, manifestName)
 	parser := NewParser(filePath)
 	return parser.Parse()
 }
 
 // WriteToDirectory writes buildmeta.yaml to a directory
 func WriteToDirectory(dir string, buildMeta *BuildMeta) error {
-	filePath := filepath.Join(dir, "buildmeta.yaml")
+	filePath := filepath.Join(dir, manifestName)
 	parser := NewParser(filePath)
 	return parser.Write(buildMeta)
 }
@@ -285,13 +328,14 @@ func ExportRequirementsFile(filePath string, deps map[string]string) error {
 		}
 	}
 	content := strings.Join(lines, "\n")
-	return os.WriteFile(filePath, []byte(content), 0644)
+	return fsutil.WriteFileAtomic(filePath, []byte(content), 0644)
 }
 
 // PyProjectMeta is a minimal struct for pyproject.toml import/export
 type PyProjectMeta struct {
 	Name         string
 	Version      string
+	RequiresPython string
 	Dependencies map[string]string
 }
 
@@ -310,6 +354,8 @@ func ParsePyProjectToml(filePath string) (*PyProjectMeta, error) {
 			meta.Name = strings.Trim(line[7:], `"`)
 		} else if strings.HasPrefix(line, "version = ") {
 			meta.Version = strings.Trim(line[10:], `"`)
+		} else if strings.HasPrefix(line, "requires-python = ") {
+			meta.RequiresPython = strings.Trim(line[18:], `"'`)
 		} else if strings.HasPrefix(line, "[project.dependencies]") || strings.HasPrefix(line, "[tool.poetry.dependencies]") {
 			inDeps = true
 			continue
@@ -343,5 +389,5 @@ version = "%s"
 			content += fmt.Sprintf("%s = \"*\"\n", name)
 		}
 	}
-	return os.WriteFile(filePath, []byte(content), 0644)
+	return fsutil.WriteFileAtomic(filePath, []byte(content), 0644)
 } 
\ No newline at end of file
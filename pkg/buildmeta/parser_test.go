@@ -3,7 +3,10 @@ package buildmeta
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/fsutil"
 )
 
 func TestParseAndWriteBuildMeta(t *testing.T) {
@@ -14,6 +17,9 @@ func TestParseAndWriteBuildMeta(t *testing.T) {
 	if err := WriteToDirectory(dir, bm); err != nil {
 		t.Fatalf("WriteToDirectory failed: %v", err)
 	}
+	if _, err := os.Stat(file); err != nil {
+		t.Fatalf("buildmeta.yaml not written: %v", err)
+	}
 	bm2, err := ParseFromDirectory(dir)
 	if err != nil {
 		t.Fatalf("ParseFromDirectory failed: %v", err)
@@ -23,6 +29,26 @@ func TestParseAndWriteBuildMeta(t *testing.T) {
 	}
 }
 
+func TestParserMemFS(t *testing.T) {
+	fsys := fsutil.NewMemFS()
+	parser := NewParser("/project/buildmeta.yaml")
+	parser.SetFS(fsys)
+	bm := NewBuildMeta("foo", "1.0.0")
+	if err := parser.Write(bm); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !parser.Exists() {
+		t.Fatal("Exists() should be true after Write")
+	}
+	bm2, err := parser.Parse()
+	if err != nil || bm2.Name != "foo" {
+		t.Errorf("Parse mismatch: %+v %v", bm2, err)
+	}
+	if _, err := os.Stat("/project/buildmeta.yaml"); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing on disk, got %v", err)
+	}
+}
+
 func TestBuildMetaValidation(t *testing.T) {
 	bm := &BuildMeta{}
 	if err := bm.Validate(); err == nil {
@@ -62,7 +88,7 @@ func TestRequirementsImportExport(t *testing.T) {
 func TestPyProjectImportExport(t *testing.T) {
 	dir := t.TempDir()
 	pyPath := filepath.Join(dir, "pyproject.toml")
-	os.WriteFile(pyPath, []byte(`[project]\nname = "foo"\nversion = "1.0.0"\n[project.dependencies]\nbar = ">=2.0.0"\n`), 0644)
+	os.WriteFile(pyPath, []byte("[project]\nname = \"foo\"\nversion = \"1.0.0\"\n[project.dependencies]\nbar = \">=2.0.0\"\n"), 0644)
 	meta, err := ParsePyProjectToml(pyPath)
 	if err != nil {
 		t.Fatalf("ParsePyProjectToml failed: %v", err)
@@ -82,4 +108,101 @@ func TestPyProjectImportExport(t *testing.T) {
 	if string(data) == "" {
 		t.Error("Exported pyproject.toml is empty")
 	}
-} 
\ No newline at end of file
+} 
+func TestScriptsTaskForms(t *testing.T) {
+	dir := t.TempDir()
+	content := `name: foo
+version: 1.0.0
+scripts:
+  lint: ruff check .
+  test:
+    cmd: pytest
+    depends_on: [lint]
+    cwd: tests
+    env:
+      CI: "1"
+`
+	if err := os.WriteFile(filepath.Join(dir, "buildmeta.yaml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bm, err := ParseFromDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseFromDirectory failed: %v", err)
+	}
+	if bm.Scripts["lint"].Cmd != "ruff check ." {
+		t.Errorf("string script not parsed: %+v", bm.Scripts["lint"])
+	}
+	test := bm.Scripts["test"]
+	if test.Cmd != "pytest" || len(test.DependsOn) != 1 || test.Cwd != "tests" || test.Env["CI"] != "1" {
+		t.Errorf("task script not parsed: %+v", test)
+	}
+	if err := WriteToDirectory(dir, bm); err != nil {
+		t.Fatalf("WriteToDirectory failed: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "buildmeta.yaml"))
+	if !strings.Contains(string(data), "lint: ruff check .") {
+		t.Errorf("command-only script should be written as a string:\n%s", data)
+	}
+
+	bm.Scripts["broken"] = Task{Cmd: "x", DependsOn: []string{"missing"}}
+	if err := bm.Validate(); err == nil {
+		t.Error("Validate should reject a dependency on an undefined script")
+	}
+}
+
+func TestHoldsAndUpdatePolicy(t *testing.T) {
+	bm := NewBuildMeta("foo", "1.0.0")
+	if !bm.AddHold("Flask_Login") || bm.AddHold("flask-login") {
+		t.Error("AddHold should add a package once, comparing normalized names")
+	}
+	bm.AddHold("django")
+	if len(bm.Holds) != 2 || bm.Holds[0] != "Flask_Login" || !bm.IsHeld("django") {
+		t.Errorf("unexpected holds: %v", bm.Holds)
+	}
+	if !bm.RemoveHold("flask.login") || bm.IsHeld("Flask_Login") || bm.RemoveHold("flask-login") {
+		t.Errorf("RemoveHold failed: %v", bm.Holds)
+	}
+
+	bm.Update = UpdateConfig{Policy: "patch", Packages: map[string]string{"django": "security"}}
+	if bm.UpdatePolicy("django") != "security" || bm.UpdatePolicy("requests") != "patch" {
+		t.Errorf("UpdatePolicy lookup failed: %+v", bm.Update)
+	}
+}
+
+func TestFindProjectRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := WriteToDirectory(root, NewBuildMeta("foo", "1.0.0")); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(root, "src", "foo")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{root, nested} {
+		got, ok := FindProjectRoot(dir)
+		if !ok || got != root {
+			t.Errorf("FindProjectRoot(%s) = %q, %v, want %q", dir, got, ok, root)
+		}
+	}
+	if got, ok := FindProjectRoot(filepath.Dir(root)); ok && got == root {
+		t.Errorf("FindProjectRoot searched below its start: %q", got)
+	}
+}
+
+func TestManifestName(t *testing.T) {
+	dir := t.TempDir()
+	SetManifestName("api.buildmeta.yaml")
+	defer SetManifestName("buildmeta.yaml")
+	if err := WriteToDirectory(dir, NewBuildMeta("api", "1.0.0")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "api.buildmeta.yaml")); err != nil {
+		t.Fatalf("manifest not written under its configured name: %v", err)
+	}
+	if bm, err := ParseFromDirectory(dir); err != nil || bm.Name != "api" {
+		t.Errorf("ParseFromDirectory = %v, %v", bm, err)
+	}
+	if root, ok := FindProjectRoot(dir); !ok || root != dir {
+		t.Errorf("FindProjectRoot = %q, %v", root, ok)
+	}
+}
@@ -0,0 +1,56 @@
+package buildmeta
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Task is a script entry. A plain string in buildmeta.yaml is shorthand for
+// a task with only a command; the mapping form adds dependencies, a working
+// directory and extra environment variables.
+type Task struct {
+	Cmd       string            `yaml:"cmd,omitempty"`
+	DependsOn []string          `yaml:"depends_on,omitempty"`
+	Cwd       string            `yaml:"cwd,omitempty"`
+	Env       map[string]string `yaml:"env,omitempty"`
+}
+
+// UnmarshalYAML accepts both the string shorthand and the mapping form
+func (t *Task) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*t = Task{Cmd: node.Value}
+		return nil
+	}
+	type plain Task
+	var p plain
+	if err := node.Decode(&p); err != nil {
+		return err
+	}
+	*t = Task(p)
+	return nil
+}
+
+// MarshalYAML writes command-only tasks back in the string shorthand
+func (t Task) MarshalYAML() (interface{}, error) {
+	if len(t.DependsOn) == 0 && t.Cwd == "" && len(t.Env) == 0 {
+		return t.Cmd, nil
+	}
+	type plain Task
+	return plain(t), nil
+}
+
+// validateScripts checks that every depends_on entry names a defined script
+func (bm *BuildMeta) validateScripts() error {
+	for name, task := range bm.Scripts {
+		if task.Cmd == "" && len(task.DependsOn) == 0 {
+			return fmt.Errorf("script %q has neither cmd nor depends_on", name)
+		}
+		for _, dep := range task.DependsOn {
+			if _, ok := bm.Scripts[dep]; !ok {
+				return fmt.Errorf("script %q depends on undefined script %q", name, dep)
+			}
+		}
+	}
+	return nil
+}
@@ -2,7 +2,10 @@ package buildmeta
 
 import (
 	"fmt"
+	"sort"
 	"time"
+
+	"rimraf-adi.com/zephyr/pkg/pkgname"
 )
 
 // BuildMeta represents the buildmeta.yaml structure
@@ -26,9 +29,33 @@ type BuildMeta struct {
 	OptionalDependencies map[string]DependenciesConfig `yaml:"optional-dependencies,omitempty"`
 	
 	// Scripts and entry points
-	Scripts     map[string]string `yaml:"scripts,omitempty"`
+	Scripts     map[string]Task   `yaml:"scripts,omitempty"`
 	EntryPoints map[string]map[string]string `yaml:"entry-points,omitempty"`
 	
+	// Packages whose console scripts win when installed packages collide, highest first
+	ScriptPrecedence []string `yaml:"script-precedence,omitempty"`
+	
+	// Update policies applied by zephyr update
+	Update      UpdateConfig      `yaml:"update,omitempty"`
+	
+	// Packages kept at their locked versions by update and re-resolution
+	Holds       []string          `yaml:"holds,omitempty"`
+	
+	// Constraint files, by URL or path, whose pins seed every resolution
+	Constraints []string          `yaml:"constraints,omitempty"`
+	
+	// Environment variables applied by zephyr run and zephyr shell
+	Env         map[string]string `yaml:"env,omitempty"`
+	
+	// Interpreters zephyr envs matrix creates environments for
+	Matrix      MatrixConfig      `yaml:"matrix,omitempty"`
+	
+	// System tools and libraries checked by zephyr doctor and before installing
+	SystemRequirements SystemRequirementsConfig `yaml:"system-requirements,omitempty"`
+	
+	// What the locked graph may contain, checked by zephyr lock and zephyr publish
+	Policy      PolicyConfig      `yaml:"policy,omitempty"`
+	
 	// Metadata
 	Created     time.Time         `yaml:"created,omitempty"`
 	Updated     time.Time         `yaml:"updated,omitempty"`
@@ -61,6 +88,30 @@ type DependenciesConfig struct {
 	Platform    map[string]map[string]string `yaml:"platform,omitempty"`
 }
 
+// UpdateConfig represents how far zephyr update may move dependencies
+type UpdateConfig struct {
+	Policy      string            `yaml:"policy,omitempty"`
+	Packages    map[string]string `yaml:"packages,omitempty"`
+}
+
+// SystemRequirementsConfig lists what the project needs from the system to
+// build its dependencies from source
+type SystemRequirementsConfig struct {
+	// Tools are commands that must be on PATH, such as gcc or pkg-config
+	Tools       []string          `yaml:"tools,omitempty"`
+	// Libraries are native libraries with their headers, such as libpq
+	Libraries   []string          `yaml:"libraries,omitempty"`
+}
+
+// PolicyConfig is the policy section of buildmeta.yaml. Each field switches
+// on the installer.Policy check of the same name, which documents what it
+// forbids.
+type PolicyConfig struct {
+	ForbidLocalVersions    bool `yaml:"forbid-local-versions,omitempty"`
+	ForbidDirectReferences bool `yaml:"forbid-direct-references,omitempty"`
+	ForbidVCS              bool `yaml:"forbid-vcs,omitempty"`
+}
+
 // DataFile represents a data file entry
 type DataFile struct {
 	Source      string   `yaml:"source"`
@@ -93,7 +144,7 @@ func NewBuildMeta(name, version string) *BuildMeta {
 			Direct: make(map[string]string),
 		},
 		OptionalDependencies: make(map[string]DependenciesConfig),
-		Scripts:             make(map[string]string),
+		Scripts:             make(map[string]Task),
 		EntryPoints:         make(map[string]map[string]string),
 		Maintainers:         []Maintainer{},
 		Created:             time.Now(),
@@ -183,9 +234,9 @@ func (bm *BuildMeta) GetOptionalDependencies(group string) map[string]string {
 // AddScript adds a script entry
 func (bm *BuildMeta) AddScript(name, command string) {
 	if bm.Scripts == nil {
-		bm.Scripts = make(map[string]string)
+		bm.Scripts = make(map[string]Task)
 	}
-	bm.Scripts[name] = command
+	bm.Scripts[name] = Task{Cmd: command}
 	bm.Updated = time.Now()
 }
 
@@ -203,6 +254,48 @@ func (bm *BuildMeta) AddEntryPoint(group, name, target string) {
 	bm.Updated = time.Now()
 }
 
+// UpdatePolicy returns the update policy configured for a package, falling
+// back to the project-wide policy
+func (bm *BuildMeta) UpdatePolicy(name string) string {
+	if policy, ok := bm.Update.Packages[name]; ok {
+		return policy
+	}
+	return bm.Update.Policy
+}
+
+// AddHold keeps a package at its locked version. It reports whether the hold is new.
+func (bm *BuildMeta) AddHold(name string) bool {
+	if bm.IsHeld(name) {
+		return false
+	}
+	bm.Holds = append(bm.Holds, name)
+	sort.Strings(bm.Holds)
+	bm.Updated = time.Now()
+	return true
+}
+
+// RemoveHold releases a held package. It reports whether a hold was removed.
+func (bm *BuildMeta) RemoveHold(name string) bool {
+	for i, held := range bm.Holds {
+		if pkgname.Normalize(held) == pkgname.Normalize(name) {
+			bm.Holds = append(bm.Holds[:i], bm.Holds[i+1:]...)
+			bm.Updated = time.Now()
+			return true
+		}
+	}
+	return false
+}
+
+// IsHeld reports whether a package is on the hold list
+func (bm *BuildMeta) IsHeld(name string) bool {
+	for _, held := range bm.Holds {
+		if pkgname.Normalize(held) == pkgname.Normalize(name) {
+			return true
+		}
+	}
+	return false
+}
+
 // AddMaintainer adds a maintainer
 func (bm *BuildMeta) AddMaintainer(name, email string) {
 	maintainer := Maintainer{
@@ -256,6 +349,10 @@ func (bm *BuildMeta) Validate() error {
 		return fmt.Errorf("invalid package name: %s", bm.Name)
 	}
 	
+	if err := bm.validateScripts(); err != nil {
+		return err
+	}
+	
 	return nil
 }
 
@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/dirs"
+	"rimraf-adi.com/zephyr/pkg/flock"
+)
+
+// ArtifactCache stores downloaded artifacts content-addressed by their SHA256 digest
+// under <root>/<sha256[:2]>/<sha256>, so identical wheels are stored once per machine
+type ArtifactCache struct {
+	Root string
+}
+
+// DefaultCacheDir returns the directory zephyr uses for cached data
+func DefaultCacheDir() string {
+	return dirs.CacheDir()
+}
+
+// NewArtifactCache creates an artifact cache rooted at root
+func NewArtifactCache(root string) *ArtifactCache {
+	return &ArtifactCache{Root: root}
+}
+
+// NewDefaultArtifactCache creates an artifact cache in the default cache directory
+func NewDefaultArtifactCache() *ArtifactCache {
+	return NewArtifactCache(filepath.Join(DefaultCacheDir(), "artifacts"))
+}
+
+// Path returns the location of the artifact with the given digest
+func (c *ArtifactCache) Path(digest string) string {
+	digest = strings.ToLower(digest)
+	if len(digest) < 2 {
+		return filepath.Join(c.Root, digest)
+	}
+	return filepath.Join(c.Root, digest[:2], digest)
+}
+
+// Has reports whether an artifact with the given digest is cached
+func (c *ArtifactCache) Has(digest string) bool {
+	if digest == "" {
+		return false
+	}
+	_, err := os.Stat(c.Path(digest))
+	return err == nil
+}
+
+// Store copies r into the cache and returns the artifact digest. When expected
+// is non-empty the content must hash to it, otherwise nothing is stored.
+func (c *ArtifactCache) Store(r io.Reader, expected string) (string, error) {
+	if err := os.MkdirAll(c.Root, 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory '%s': %w", c.Root, err)
+	}
+	tmp, err := os.CreateTemp(c.Root, ".incoming-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create cache temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hasher), r); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write artifact to cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write artifact to cache: %w", err)
+	}
+
+	digest := hex.EncodeToString(hasher.Sum(nil))
+	if expected != "" && !strings.EqualFold(digest, expected) {
+		return "", &HashMismatchError{Expected: strings.ToLower(expected), Actual: digest}
+	}
+
+	lock, err := c.lock()
+	if err != nil {
+		return "", err
+	}
+	defer lock.Release()
+	target := c.Path(digest)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache shard: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", fmt.Errorf("failed to move artifact into cache: %w", err)
+	}
+	// Cached artifacts are shared between projects and must never be modified in place
+	os.Chmod(target, 0444)
+	return digest, nil
+}
+
+// StoreFile adds an existing file to the cache
+func (c *ArtifactCache) StoreFile(path, expected string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open '%s': %w", path, err)
+	}
+	defer f.Close()
+	return c.Store(f, expected)
+}
+
+// Verify re-hashes a cached artifact and removes it if it no longer matches its digest
+func (c *ArtifactCache) Verify(digest string) error {
+	lock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	f, err := os.Open(c.Path(digest))
+	if err != nil {
+		return fmt.Errorf("artifact %s is not cached: %w", digest, err)
+	}
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read cached artifact %s: %w", digest, err)
+	}
+	actual := hex.EncodeToString(hasher.Sum(nil))
+	if !strings.EqualFold(actual, digest) {
+		os.Remove(c.Path(digest))
+		return &HashMismatchError{Expected: strings.ToLower(digest), Actual: actual}
+	}
+	markUsed(c.Path(digest))
+	return nil
+}
+
+// Link places the cached artifact at dest, using a hard link when possible and
+// falling back to a copy when the cache lives on another filesystem
+func (c *ArtifactCache) Link(digest, dest string) error {
+	source := c.Path(digest)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for '%s': %w", dest, err)
+	}
+	os.Remove(dest)
+	markUsed(source)
+	return LinkFile(source, dest, LinkModeHardlink)
+}
+
+// lock takes the cache's advisory lock, serializing writers across processes
+func (c *ArtifactCache) lock() (*flock.Lock, error) {
+	lock, err := flock.Acquire(filepath.Join(c.Root, ".lock"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock cache: %w", err)
+	}
+	return lock, nil
+}
+
+// copyFile copies source to dest
+func copyFile(source, dest string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open cached artifact: %w", err)
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create '%s': %w", dest, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy artifact to '%s': %w", dest, err)
+	}
+	return out.Close()
+}
+
+// HashMismatchError reports content that does not match its expected digest
+type HashMismatchError struct {
+	Expected string
+	Actual   string
+}
+
+// Error implements the error interface
+func (e *HashMismatchError) Error() string {
+	return fmt.Sprintf("SHA256 hash mismatch: expected %s, got %s", e.Expected, e.Actual)
+}
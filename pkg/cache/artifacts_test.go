@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// wheelDigest is a well-formed digest that does not match "wheel content"
+const wheelDigest = "9a0e3e2ab6a1d1f3d3b2c5b8d1b0f7e2b0f4d6c0e0f0a9a1c8e7d6b5a4f3e2d1"
+
+func TestArtifactCacheStoreAndLink(t *testing.T) {
+	c := NewArtifactCache(t.TempDir())
+	digest, err := c.Store(strings.NewReader("wheel content"), "")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if len(digest) != 64 || !c.Has(digest) {
+		t.Fatalf("Artifact not cached under digest %q", digest)
+	}
+	if filepath.Base(filepath.Dir(c.Path(digest))) != digest[:2] {
+		t.Errorf("Artifact not sharded by digest prefix: %s", c.Path(digest))
+	}
+	// Storing the same content again must be idempotent
+	again, err := c.Store(strings.NewReader("wheel content"), strings.ToUpper(digest))
+	if err != nil || again != digest {
+		t.Errorf("Re-store failed: %v, %s", err, again)
+	}
+	dest := filepath.Join(t.TempDir(), "project", "foo.whl")
+	if err := c.Link(digest, dest); err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+	data, _ := os.ReadFile(dest)
+	if string(data) != "wheel content" {
+		t.Errorf("Linked artifact content mismatch: %q", data)
+	}
+	if err := c.Verify(digest); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+}
+
+func TestArtifactCacheStoreHashMismatch(t *testing.T) {
+	c := NewArtifactCache(t.TempDir())
+	_, err := c.Store(strings.NewReader("wheel content"), wheelDigest)
+	var mismatch *HashMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected HashMismatchError, got %v", err)
+	}
+	if c.Has(wheelDigest) || c.Has(mismatch.Actual) {
+		t.Error("Mismatched artifact should not be cached")
+	}
+}
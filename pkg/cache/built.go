@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BuiltWheelCache keeps wheels built from sdists under <root>/<key[:2]>/<key>/,
+// so a package is compiled once per machine rather than once per sync
+type BuiltWheelCache struct {
+	Root string
+}
+
+// NewBuiltWheelCache creates a built wheel cache rooted at root
+func NewBuiltWheelCache(root string) *BuiltWheelCache {
+	return &BuiltWheelCache{Root: root}
+}
+
+// NewDefaultBuiltWheelCache creates a built wheel cache in the default cache directory
+func NewDefaultBuiltWheelCache() *BuiltWheelCache {
+	return NewBuiltWheelCache(filepath.Join(DefaultCacheDir(), "built"))
+}
+
+// BuildKey is everything that affects the wheel built from an sdist
+type BuildKey struct {
+	SdistSHA256 string
+	// Python is the interpreter version, e.g. 3.11.4
+	Python string
+	// Platform is the interpreter's platform tag, e.g. linux-x86_64
+	Platform string
+	// Options are the build settings passed to the backend
+	Options []string
+}
+
+// Digest hashes the key into the cache entry name. Option order does not matter.
+func (k BuildKey) Digest() string {
+	options := append([]string(nil), k.Options...)
+	sort.Strings(options)
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "sdist=%s\npython=%s\nplatform=%s\n", strings.ToLower(k.SdistSHA256), k.Python, k.Platform)
+	for _, option := range options {
+		fmt.Fprintf(hasher, "option=%s\n", option)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// Lookup returns the cached wheel built for key
+func (c *BuiltWheelCache) Lookup(key BuildKey) (string, bool) {
+	dir := NewUnpackedCache(c.Root).Path(key.Digest())
+	wheel, err := builtWheel(dir)
+	if err != nil {
+		return "", false
+	}
+	markUsed(dir)
+	return wheel, true
+}
+
+// Ensure returns the wheel built for key, calling build to write it into a
+// staging directory the first time. Concurrent builds of the same key are
+// serialized, and a failed build leaves nothing behind.
+func (c *BuiltWheelCache) Ensure(key BuildKey, build func(dir string) error) (string, error) {
+	dir, err := NewUnpackedCache(c.Root).Ensure(key.Digest(), func(staging string) error {
+		if err := build(staging); err != nil {
+			return err
+		}
+		_, err := builtWheel(staging)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return builtWheel(dir)
+}
+
+// builtWheel finds the single wheel in a cache entry
+func builtWheel(dir string) (string, error) {
+	wheels, err := filepath.Glob(filepath.Join(dir, "*.whl"))
+	if err != nil {
+		return "", err
+	}
+	if len(wheels) != 1 {
+		if _, err := os.Stat(dir); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("expected one wheel in '%s', found %d", dir, len(wheels))
+	}
+	return wheels[0], nil
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltWheelCache(t *testing.T) {
+	c := NewBuiltWheelCache(t.TempDir())
+	key := BuildKey{SdistSHA256: wheelDigest, Python: "3.11.4", Platform: "linux-x86_64", Options: []string{"CFLAGS=-O2", "CC=gcc"}}
+	reordered := key
+	reordered.Options = []string{"CC=gcc", "CFLAGS=-O2"}
+	if key.Digest() != reordered.Digest() {
+		t.Error("Option order should not change the key")
+	}
+	other := key
+	other.Python = "3.12.0"
+	if key.Digest() == other.Digest() {
+		t.Error("A different interpreter must not share a build")
+	}
+
+	if _, ok := c.Lookup(key); ok {
+		t.Fatal("Empty cache should have no build")
+	}
+	if _, err := c.Ensure(key, func(dir string) error { return errors.New("compiler missing") }); err == nil {
+		t.Fatal("Expected the build error")
+	}
+	if _, ok := c.Lookup(key); ok {
+		t.Fatal("A failed build must not be cached")
+	}
+	if _, err := c.Ensure(key, func(dir string) error { return nil }); err == nil {
+		t.Fatal("A build producing no wheel should fail")
+	}
+
+	builds := 0
+	build := func(dir string) error {
+		builds++
+		return os.WriteFile(filepath.Join(dir, "pkg-1.0-cp311-cp311-linux_x86_64.whl"), []byte("wheel"), 0644)
+	}
+	first, err := c.Ensure(key, build)
+	if err != nil {
+		t.Fatalf("Ensure failed: %v", err)
+	}
+	second, err := c.Ensure(reordered, build)
+	if err != nil || second != first || builds != 1 {
+		t.Errorf("Second Ensure should reuse the build: %s, %v, %d builds", second, err, builds)
+	}
+	if wheel, ok := c.Lookup(key); !ok || filepath.Base(wheel) != "pkg-1.0-cp311-cp311-linux_x86_64.whl" {
+		t.Errorf("Lookup = %s, %v", wheel, ok)
+	}
+}
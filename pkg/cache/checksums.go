@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/dirs"
+	"rimraf-adi.com/zephyr/pkg/flock"
+	"rimraf-adi.com/zephyr/pkg/fsutil"
+)
+
+// ChecksumDB pins the SHA256 of artifacts whose index publishes no digest.
+// The first download of an artifact URL is trusted and recorded; later
+// downloads must match it (trust on first use). It lives in the data
+// directory so clearing the cache does not forget the pins.
+type ChecksumDB struct {
+	Path string
+}
+
+// NewChecksumDB returns the checksum database stored at path
+func NewChecksumDB(path string) *ChecksumDB {
+	return &ChecksumDB{Path: path}
+}
+
+// NewDefaultChecksumDB returns the machine-wide checksum database
+func NewDefaultChecksumDB() *ChecksumDB {
+	return NewChecksumDB(filepath.Join(dirs.DataDir(), "checksums.json"))
+}
+
+// ChecksumKey identifies an artifact by its URL, without any fragment
+func ChecksumKey(url string) string {
+	url, _, _ = strings.Cut(url, "#")
+	return url
+}
+
+// Lookup returns the digest pinned for an artifact URL
+func (db *ChecksumDB) Lookup(url string) (string, bool, error) {
+	pins, err := db.load()
+	if err != nil {
+		return "", false, err
+	}
+	digest, ok := pins[ChecksumKey(url)]
+	return digest, ok, nil
+}
+
+// Pin records the digest of an artifact URL. An existing pin is kept, and a
+// different digest for it is reported as a ChecksumMismatchError.
+func (db *ChecksumDB) Pin(url, digest string) error {
+	lock, err := flock.Acquire(db.Path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	pins, err := db.load()
+	if err != nil {
+		return err
+	}
+	key := ChecksumKey(url)
+	digest = strings.ToLower(digest)
+	if pinned, ok := pins[key]; ok {
+		if pinned != digest {
+			return &ChecksumMismatchError{URL: key, Pinned: pinned, Actual: digest, DB: db.Path}
+		}
+		return nil
+	}
+	pins[key] = digest
+	data, err := json.MarshalIndent(pins, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checksum database: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(db.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create checksum database directory: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(db.Path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write checksum database: %w", err)
+	}
+	return nil
+}
+
+// load reads the pins, treating a missing database as empty
+func (db *ChecksumDB) load() (map[string]string, error) {
+	pins := make(map[string]string)
+	data, err := os.ReadFile(db.Path)
+	if os.IsNotExist(err) {
+		return pins, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checksum database: %w", err)
+	}
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return nil, fmt.Errorf("failed to parse checksum database %s: %w", db.Path, err)
+	}
+	return pins, nil
+}
+
+// ChecksumMismatchError reports an artifact that changed since its hash was pinned
+type ChecksumMismatchError struct {
+	URL    string
+	Pinned string
+	Actual string
+	// DB is where the pin is stored, or the lockfile that pinned it
+	DB string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s has sha256 %s but %s pinned %s when it was first downloaded. The artifact changed on the index; if that is expected, remove its entry from %s", e.URL, e.Actual, e.DB, e.Pinned, e.DB)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package cache
+
+import "errors"
+
+// cloneFile reports that reflinks are unavailable on this platform
+func cloneFile(source, dest string) error {
+	return errors.New("reflinks are not supported on this platform")
+}
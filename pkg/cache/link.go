@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LinkMode controls how files are materialized from the cache into an environment
+type LinkMode string
+
+const (
+	// LinkModeCopy copies every file, the cache and environment stay independent
+	LinkModeCopy LinkMode = "copy"
+	// LinkModeHardlink hard-links files, falling back to a copy across filesystems
+	LinkModeHardlink LinkMode = "hardlink"
+	// LinkModeClone uses copy-on-write reflinks where the filesystem supports them
+	LinkModeClone LinkMode = "clone"
+)
+
+// ParseLinkMode parses a --link-mode value, the empty string selects copy
+func ParseLinkMode(s string) (LinkMode, error) {
+	switch LinkMode(strings.ToLower(strings.TrimSpace(s))) {
+	case "", LinkModeCopy:
+		return LinkModeCopy, nil
+	case LinkModeHardlink:
+		return LinkModeHardlink, nil
+	case LinkModeClone:
+		return LinkModeClone, nil
+	}
+	return "", fmt.Errorf("unknown link mode '%s' (expected copy, hardlink or clone)", s)
+}
+
+// LinkFile materializes source at dest using mode. Hardlinks and clones silently
+// fall back to a plain copy when the filesystem cannot provide them.
+func LinkFile(source, dest string, mode LinkMode) error {
+	switch mode {
+	case LinkModeHardlink:
+		if err := os.Link(source, dest); err == nil {
+			return nil
+		}
+	case LinkModeClone:
+		if err := cloneFile(source, dest); err == nil {
+			return nil
+		}
+	}
+	return copyFile(source, dest)
+}
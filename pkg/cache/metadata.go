@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"rimraf-adi.com/zephyr/pkg/fsutil"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// MetadataStore keeps the last index document fetched for each package under
+// <root>/<normalized name>.json, so short-lived processes such as shell
+// completion can answer without going to the network
+type MetadataStore struct {
+	Root string
+}
+
+// NewMetadataStore creates a metadata store rooted at root
+func NewMetadataStore(root string) *MetadataStore {
+	return &MetadataStore{Root: root}
+}
+
+// NewDefaultMetadataStore creates a metadata store in the default cache directory
+func NewDefaultMetadataStore() *MetadataStore {
+	return NewMetadataStore(filepath.Join(DefaultCacheDir(), "metadata"))
+}
+
+// path returns where the document for a package is stored
+func (s *MetadataStore) path(name string) string {
+	return filepath.Join(s.Root, pkgname.Normalize(name)+".json")
+}
+
+// Get returns the stored document for a package and when it was stored.
+// A maxAge above zero treats older documents as missing.
+func (s *MetadataStore) Get(name string, maxAge time.Duration) ([]byte, time.Time, bool) {
+	path := s.path(name)
+	info, err := os.Stat(path)
+	if err != nil || (maxAge > 0 && time.Since(info.ModTime()) > maxAge) {
+		return nil, time.Time{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return data, info.ModTime(), true
+}
+
+// Validators are the response headers that let a stored document be
+// revalidated with a conditional GET instead of downloaded again
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// validatorsPath returns where the validators for a package's document are kept
+func (s *MetadataStore) validatorsPath(name string) string {
+	return strings.TrimSuffix(s.path(name), ".json") + ".validators"
+}
+
+// Put stores the document for a package, replacing any earlier one
+func (s *MetadataStore) Put(name string, data []byte) error {
+	return s.PutValidated(name, data, Validators{})
+}
+
+// PutValidated stores the document for a package with the validators the
+// index sent for it
+func (s *MetadataStore) PutValidated(name string, data []byte, validators Validators) error {
+	if err := os.MkdirAll(s.Root, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory '%s': %w", s.Root, err)
+	}
+	if err := fsutil.WriteFileAtomic(s.path(name), data, 0644); err != nil {
+		return err
+	}
+	if validators == (Validators{}) {
+		os.Remove(s.validatorsPath(name))
+		return nil
+	}
+	encoded, err := json.Marshal(validators)
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(s.validatorsPath(name), encoded, 0644)
+}
+
+// Validators returns the validators stored with a package's document
+func (s *MetadataStore) Validators(name string) (Validators, bool) {
+	var validators Validators
+	data, err := os.ReadFile(s.validatorsPath(name))
+	if err != nil || json.Unmarshal(data, &validators) != nil {
+		return Validators{}, false
+	}
+	return validators, validators != (Validators{})
+}
+
+// Touch marks the stored document for a package as fresh, after the index
+// confirmed it is unchanged
+func (s *MetadataStore) Touch(name string) error {
+	now := time.Now()
+	return os.Chtimes(s.path(name), now, now)
+}
+
+// Delete removes the stored document for a package, if any
+func (s *MetadataStore) Delete(name string) error {
+	if err := os.Remove(s.path(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cached metadata for '%s': %w", name, err)
+	}
+	os.Remove(s.validatorsPath(name))
+	return nil
+}
+
+// Names lists the normalized names of the stored packages, sorted
+func (s *MetadataStore) Names() ([]string, error) {
+	items, err := os.ReadDir(s.Root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory '%s': %w", s.Root, err)
+	}
+	var names []string
+	for _, item := range items {
+		name := item.Name()
+		if item.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(name, ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
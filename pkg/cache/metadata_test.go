@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetadataStore(t *testing.T) {
+	s := NewMetadataStore(t.TempDir())
+	if _, _, ok := s.Get("requests", 0); ok {
+		t.Fatal("Get found a document in an empty store")
+	}
+	if names, err := s.Names(); err != nil || len(names) != 0 {
+		t.Fatalf("Names of an empty store = %v, %v", names, err)
+	}
+	if err := s.Put("Typing_Extensions", []byte(`{"a":1}`)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Put("requests", []byte(`{"b":2}`)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	// Lookups normalize the name like the index does
+	data, stored, ok := s.Get("typing.extensions", 0)
+	if !ok || string(data) != `{"a":1}` || stored.IsZero() {
+		t.Errorf("Get = %q, %v, %v", data, stored, ok)
+	}
+	names, err := s.Names()
+	if err != nil || !reflect.DeepEqual(names, []string{"requests", "typing-extensions"}) {
+		t.Errorf("Names = %v, %v", names, err)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(s.path("requests"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := s.Get("requests", time.Hour); ok {
+		t.Error("Get returned a document older than maxAge")
+	}
+	if _, _, ok := s.Get("requests", 0); !ok {
+		t.Error("Get without maxAge ignored an old document")
+	}
+}
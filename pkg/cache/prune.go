@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"rimraf-adi.com/zephyr/pkg/flock"
+)
+
+// PrunePolicy selects which cache entries Prune evicts. Zero fields are not enforced.
+type PrunePolicy struct {
+	// MaxAge evicts entries not used for longer than this
+	MaxAge time.Duration
+	// MaxSize evicts the least recently used entries until the cache fits
+	MaxSize int64
+}
+
+// PruneResult summarizes a prune run
+type PruneResult struct {
+	Removed []string
+	Freed   int64
+	// Size is the cache size after pruning
+	Size int64
+}
+
+// cacheEntry is an artifact or extracted wheel that can be evicted as a unit
+type cacheEntry struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+// Prune evicts artifacts, extracted wheels and wheels built from sdists under the cache root that the
+// policy rejects, oldest first. Entries are timestamped when stored and each
+// time they are used, so age is measured from the last install that needed
+// them. With dryRun set nothing is removed.
+func Prune(root string, policy PrunePolicy, dryRun bool) (*PruneResult, error) {
+	artifacts := NewArtifactCache(filepath.Join(root, "artifacts"))
+	unpacked := NewUnpackedCache(filepath.Join(root, "unpacked"))
+	built := NewBuiltWheelCache(filepath.Join(root, "built"))
+	for _, dir := range []string{artifacts.Root, unpacked.Root, built.Root} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory '%s': %w", dir, err)
+		}
+		lock, err := flock.Acquire(filepath.Join(dir, ".lock"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock cache: %w", err)
+		}
+		defer lock.Release()
+	}
+
+	entries, err := scanEntries(artifacts.Root)
+	if err != nil {
+		return nil, err
+	}
+	extracted, err := scanEntries(unpacked.Root)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, extracted...)
+	builds, err := scanEntries(built.Root)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, builds...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].lastUsed.Before(entries[j].lastUsed) })
+
+	result := &PruneResult{}
+	for _, e := range entries {
+		result.Size += e.size
+	}
+	cutoff := time.Now().Add(-policy.MaxAge)
+	for _, e := range entries {
+		expired := policy.MaxAge > 0 && e.lastUsed.Before(cutoff)
+		oversize := policy.MaxSize > 0 && result.Size > policy.MaxSize
+		if !expired && !oversize {
+			continue
+		}
+		if !dryRun {
+			if err := os.RemoveAll(e.path); err != nil {
+				return result, fmt.Errorf("failed to remove '%s' from cache: %w", e.path, err)
+			}
+		}
+		result.Removed = append(result.Removed, e.path)
+		result.Freed += e.size
+		result.Size -= e.size
+	}
+	return result, nil
+}
+
+// Size returns the total size of the artifacts, extracted wheels and built wheels under the cache root
+func Size(root string) (int64, error) {
+	var total int64
+	for _, dir := range []string{filepath.Join(root, "artifacts"), filepath.Join(root, "unpacked"), filepath.Join(root, "built")} {
+		entries, err := scanEntries(dir)
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range entries {
+			total += e.size
+		}
+	}
+	return total, nil
+}
+
+// scanEntries lists the <shard>/<digest> entries of a content-addressed cache
+func scanEntries(root string) ([]cacheEntry, error) {
+	shards, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory '%s': %w", root, err)
+	}
+	var entries []cacheEntry
+	for _, shard := range shards {
+		// Skip the lock file and in-progress downloads or extractions
+		if !shard.IsDir() || strings.HasPrefix(shard.Name(), ".") {
+			continue
+		}
+		items, err := os.ReadDir(filepath.Join(root, shard.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cache directory '%s': %w", root, err)
+		}
+		for _, item := range items {
+			if strings.HasPrefix(item.Name(), ".") {
+				continue
+			}
+			path := filepath.Join(root, shard.Name(), item.Name())
+			info, err := item.Info()
+			if err != nil {
+				continue
+			}
+			size, err := diskUsage(path)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, cacheEntry{path: path, size: size, lastUsed: info.ModTime()})
+		}
+	}
+	return entries, nil
+}
+
+// diskUsage returns the total size of the files at or under path
+func diskUsage(path string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure '%s': %w", path, err)
+	}
+	return total, nil
+}
+
+// markUsed refreshes the timestamp Prune uses to judge an entry's age
+func markUsed(path string) {
+	now := time.Now()
+	os.Chtimes(path, now, now)
+}
+
+// ParseAge parses a maximum age such as "30d", "2w" or any time.ParseDuration value
+func ParseAge(value string) (time.Duration, error) {
+	s := strings.TrimSpace(value)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			days, err := strconv.ParseFloat(n, 64)
+			if err != nil || days <= 0 {
+				break
+			}
+			return time.Duration(days * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q: expected a positive duration such as 30d, 2w or 12h", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// storeAged caches content and backdates its last use
+func storeAged(t *testing.T, c *ArtifactCache, content string, age time.Duration) string {
+	digest, err := c.Store(strings.NewReader(content), "")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	when := time.Now().Add(-age)
+	os.Chtimes(c.Path(digest), when, when)
+	return digest
+}
+
+func TestPrune_MaxAge(t *testing.T) {
+	root := t.TempDir()
+	c := NewArtifactCache(filepath.Join(root, "artifacts"))
+	old := storeAged(t, c, "old wheel", 40*24*time.Hour)
+	fresh := storeAged(t, c, "fresh wheel", time.Hour)
+
+	result, err := Prune(root, PrunePolicy{MaxAge: 30 * 24 * time.Hour}, true)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(result.Removed) != 1 || !c.Has(old) {
+		t.Fatalf("dry run removed %v", result.Removed)
+	}
+
+	if _, err := Prune(root, PrunePolicy{MaxAge: 30 * 24 * time.Hour}, false); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if c.Has(old) || !c.Has(fresh) {
+		t.Errorf("expected only the old artifact to be evicted")
+	}
+}
+
+func TestPrune_MaxSizeEvictsLeastRecentlyUsed(t *testing.T) {
+	root := t.TempDir()
+	c := NewArtifactCache(filepath.Join(root, "artifacts"))
+	u := NewUnpackedCache(filepath.Join(root, "unpacked"))
+	first := storeAged(t, c, "aaaaaaaaaa", 3*time.Hour)
+	second := storeAged(t, c, "bbbbbbbbbb", 2*time.Hour)
+	third := storeAged(t, c, "cccccccccc", time.Hour)
+
+	// Using the oldest artifact makes it the most recently used
+	if err := c.Verify(first); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	dir, err := u.Ensure(third, func(dir string) error {
+		return os.WriteFile(filepath.Join(dir, "mod.py"), []byte("12345"), 0644)
+	})
+	if err != nil {
+		t.Fatalf("Ensure failed: %v", err)
+	}
+	if size, _ := Size(root); size != 35 {
+		t.Fatalf("Size = %d, want 35", size)
+	}
+
+	result, err := Prune(root, PrunePolicy{MaxSize: 25}, false)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if c.Has(second) || !c.Has(first) || !c.Has(third) || !u.Has(third) {
+		t.Errorf("expected only the least recently used artifact to be evicted, removed %v", result.Removed)
+	}
+	if result.Freed != 10 || result.Size != 25 {
+		t.Errorf("Freed = %d, Size = %d", result.Freed, result.Size)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("extracted wheel was removed: %v", err)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+	} {
+		if got, err := ParseAge(value); err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "soon", "-1d"} {
+		if _, err := ParseAge(value); err == nil {
+			t.Errorf("ParseAge(%q) succeeded", value)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/flock"
+)
+
+// UnpackedCache holds wheels extracted once per digest so their files can be
+// linked into any number of environments
+type UnpackedCache struct {
+	Root string
+}
+
+// NewUnpackedCache creates an unpacked wheel cache rooted at root
+func NewUnpackedCache(root string) *UnpackedCache {
+	return &UnpackedCache{Root: root}
+}
+
+// NewDefaultUnpackedCache creates an unpacked wheel cache in the default cache directory
+func NewDefaultUnpackedCache() *UnpackedCache {
+	return NewUnpackedCache(filepath.Join(DefaultCacheDir(), "unpacked"))
+}
+
+// Path returns the directory holding the extracted artifact with the given digest
+func (c *UnpackedCache) Path(digest string) string {
+	digest = strings.ToLower(digest)
+	if len(digest) < 2 {
+		return filepath.Join(c.Root, digest)
+	}
+	return filepath.Join(c.Root, digest[:2], digest)
+}
+
+// Has reports whether the artifact with the given digest has been extracted
+func (c *UnpackedCache) Has(digest string) bool {
+	info, err := os.Stat(c.Path(digest))
+	return err == nil && info.IsDir()
+}
+
+// Remove deletes the extracted copy of the artifact with the given digest, so
+// the next Ensure extracts it afresh
+func (c *UnpackedCache) Remove(digest string) error {
+	lock, err := flock.Acquire(filepath.Join(c.Root, ".lock"))
+	if err != nil {
+		return fmt.Errorf("failed to lock cache: %w", err)
+	}
+	defer lock.Release()
+	return os.RemoveAll(c.Path(digest))
+}
+
+// Ensure returns the extracted directory for digest, calling extract to fill a
+// staging directory the first time. The staging directory is renamed into place
+// so a partially extracted wheel is never visible to other installs.
+func (c *UnpackedCache) Ensure(digest string, extract func(dir string) error) (string, error) {
+	target := c.Path(digest)
+	if c.Has(digest) {
+		markUsed(target)
+		return target, nil
+	}
+	lock, err := flock.Acquire(filepath.Join(c.Root, ".lock"))
+	if err != nil {
+		return "", fmt.Errorf("failed to lock cache: %w", err)
+	}
+	defer lock.Release()
+	// Another process may have extracted it while we waited for the lock
+	if c.Has(digest) {
+		return target, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache shard: %w", err)
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(target), ".incoming-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+	if err := extract(staging); err != nil {
+		return "", err
+	}
+	if err := os.Rename(staging, target); err != nil {
+		// Another install may have populated the entry concurrently
+		if c.Has(digest) {
+			return target, nil
+		}
+		return "", fmt.Errorf("failed to move extracted wheel into cache: %w", err)
+	}
+	return target, nil
+}
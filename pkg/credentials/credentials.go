@@ -0,0 +1,269 @@
+// Package credentials stores tokens for package indexes outside plaintext
+// config: in the OS keyring when one is available, otherwise in an obfuscated
+// file only the current user can read. A list of the indexes with stored
+// credentials, which holds no secrets, is kept beside them.
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"rimraf-adi.com/zephyr/pkg/dirs"
+	"rimraf-adi.com/zephyr/pkg/fsutil"
+)
+
+// DefaultUsername is sent with a token when no username was given, as PyPI
+// and most token-based indexes expect
+const DefaultUsername = "__token__"
+
+// BackendEnv selects the backend: "keyring", "file", or unset for the keyring
+// when one is available and the file otherwise
+const BackendEnv = "ZEPHYR_KEYRING"
+
+// ErrNotFound is returned for an index without stored credentials
+var ErrNotFound = errors.New("no credentials stored for this index")
+
+// Entry describes the credentials stored for one index
+type Entry struct {
+	Index    string `yaml:"index"`
+	Username string `yaml:"username"`
+	// Backend is where the token is kept: "keyring" or "file"
+	Backend string `yaml:"backend"`
+}
+
+// Store keeps credentials for indexes, one token per index
+type Store struct {
+	dir     string
+	keyring backend
+	file    backend
+}
+
+// NewStore returns a store keeping its index list and encrypted file in dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, keyring: systemKeyring(), file: newFileVault(dir)}
+}
+
+// NewDefaultStore returns the store in the zephyr data directory
+func NewDefaultStore() *Store {
+	return NewStore(filepath.Join(dirs.DataDir(), "credentials"))
+}
+
+// NormalizeIndex reduces an index URL to the form credentials are stored
+// under: scheme and host lowercased, no user info, query or trailing slash.
+// A bare host is taken to be https.
+func NormalizeIndex(index string) (string, error) {
+	if !strings.Contains(index, "://") {
+		index = "https://" + index
+	}
+	u, err := url.Parse(index)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid index URL %q", index)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+// Login stores token for index, replacing any credentials it had
+func (s *Store) Login(index, username, token string) (Entry, error) {
+	index, err := NormalizeIndex(index)
+	if err != nil {
+		return Entry{}, err
+	}
+	if token == "" {
+		return Entry{}, fmt.Errorf("empty token")
+	}
+	if username == "" {
+		username = DefaultUsername
+	}
+	entries, err := s.List()
+	if err != nil {
+		return Entry{}, err
+	}
+	b, err := s.backend()
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := b.set(index, token); err != nil {
+		return Entry{}, fmt.Errorf("failed to store token in %s: %w", b.name(), err)
+	}
+	entry := Entry{Index: index, Username: username, Backend: b.name()}
+	kept := []Entry{entry}
+	for _, existing := range entries {
+		if existing.Index != index {
+			kept = append(kept, existing)
+		} else if existing.Backend != entry.Backend {
+			// Do not leave the old token behind in the other backend
+			s.backendNamed(existing.Backend).delete(index)
+		}
+	}
+	return entry, s.save(kept)
+}
+
+// Logout removes the credentials stored for index
+func (s *Store) Logout(index string) error {
+	index, err := NormalizeIndex(index)
+	if err != nil {
+		return err
+	}
+	entries, err := s.List()
+	if err != nil {
+		return err
+	}
+	var kept []Entry
+	found := false
+	for _, entry := range entries {
+		if entry.Index != index {
+			kept = append(kept, entry)
+			continue
+		}
+		found = true
+		if err := s.backendNamed(entry.Backend).delete(index); err != nil {
+			return fmt.Errorf("failed to remove token from %s: %w", entry.Backend, err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s: %w", index, ErrNotFound)
+	}
+	return s.save(kept)
+}
+
+// List returns the indexes with stored credentials, sorted by URL
+func (s *Store) List() ([]Entry, error) {
+	data, err := os.ReadFile(s.indexPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.indexPath(), err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Index < entries[j].Index })
+	return entries, nil
+}
+
+// Lookup returns the credentials for the index rawURL belongs to: the stored
+// index that is the longest prefix of it
+func (s *Store) Lookup(rawURL string) (username, token string, err error) {
+	target, err := NormalizeIndex(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	entries, err := s.List()
+	if err != nil {
+		return "", "", err
+	}
+	best := longestPrefix(entries, target)
+	if best == nil {
+		return "", "", ErrNotFound
+	}
+	token, err = s.backendNamed(best.Backend).get(best.Index)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read token for %s from %s: %w", best.Index, best.Backend, err)
+	}
+	return best.Username, token, nil
+}
+
+// Resolver returns a function for netutil.SetCredentials that looks each index
+// up once, so the keyring is not asked again for every request
+func (s *Store) Resolver() func(rawURL string) (username, token string, ok bool) {
+	var mu sync.Mutex
+	var entries []Entry
+	loaded := false
+	tokens := make(map[string]string)
+	return func(rawURL string) (string, string, bool) {
+		target, err := NormalizeIndex(rawURL)
+		if err != nil {
+			return "", "", false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !loaded {
+			entries, _ = s.List()
+			loaded = true
+		}
+		best := longestPrefix(entries, target)
+		if best == nil {
+			return "", "", false
+		}
+		token, seen := tokens[best.Index]
+		if !seen {
+			token, _ = s.backendNamed(best.Backend).get(best.Index)
+			tokens[best.Index] = token
+		}
+		return best.Username, token, token != ""
+	}
+}
+
+// longestPrefix returns the entry whose index is the longest prefix of target
+func longestPrefix(entries []Entry, target string) *Entry {
+	var best *Entry
+	for i, entry := range entries {
+		if target != entry.Index && !strings.HasPrefix(target, entry.Index+"/") {
+			continue
+		}
+		if best == nil || len(entry.Index) > len(best.Index) {
+			best = &entries[i]
+		}
+	}
+	return best
+}
+
+// backend returns where new tokens are stored
+func (s *Store) backend() (backend, error) {
+	switch os.Getenv(BackendEnv) {
+	case "file":
+		return s.file, nil
+	case "keyring":
+		if s.keyring == nil {
+			return nil, fmt.Errorf("%s=keyring but no OS keyring is available", BackendEnv)
+		}
+		return s.keyring, nil
+	case "":
+		if s.keyring != nil {
+			return s.keyring, nil
+		}
+		return s.file, nil
+	}
+	return nil, fmt.Errorf("invalid %s %q: expected keyring or file", BackendEnv, os.Getenv(BackendEnv))
+}
+
+// backendNamed returns the backend an entry was stored in
+func (s *Store) backendNamed(name string) backend {
+	if name == keyringBackend && s.keyring != nil {
+		return s.keyring
+	}
+	if name == keyringBackend {
+		return unavailableKeyring{}
+	}
+	return s.file
+}
+
+func (s *Store) indexPath() string {
+	return filepath.Join(s.dir, "indexes.yaml")
+}
+
+func (s *Store) save(entries []Entry) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(s.indexPath(), data, 0600)
+}
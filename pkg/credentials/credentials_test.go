@@ -0,0 +1,87 @@
+package credentials
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore_FileBackend(t *testing.T) {
+	t.Setenv(BackendEnv, "file")
+	dir := t.TempDir()
+	store := NewStore(dir)
+
+	entry, err := store.Login("HTTPS://Upload.PyPI.org/legacy/", "", "pypi-secret")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if entry.Index != "https://upload.pypi.org/legacy" || entry.Username != DefaultUsername || entry.Backend != "file" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if _, err := store.Login("pypi.internal/simple", "ci", "internal-secret"); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	username, token, err := store.Lookup("https://upload.pypi.org/legacy/")
+	if err != nil || username != DefaultUsername || token != "pypi-secret" {
+		t.Errorf("Lookup = %q, %q, %v", username, token, err)
+	}
+	username, token, err = store.Lookup("https://pypi.internal/simple/requests/")
+	if err != nil || username != "ci" || token != "internal-secret" {
+		t.Errorf("Lookup = %q, %q, %v", username, token, err)
+	}
+	if _, _, err := store.Lookup("https://pypi.internal/simpler"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a URL outside the index, got %v", err)
+	}
+
+	// Neither the index list nor the vault may hold the token in plaintext
+	for _, name := range []string{"indexes.yaml", "tokens.enc"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		if strings.Contains(string(data), "secret") {
+			t.Errorf("%s contains a token in plaintext", name)
+		}
+	}
+
+	if err := store.Logout("https://upload.pypi.org/legacy"); err != nil {
+		t.Fatalf("Logout failed: %v", err)
+	}
+	entries, _ := store.List()
+	if len(entries) != 1 || entries[0].Index != "https://pypi.internal/simple" {
+		t.Errorf("unexpected entries after logout: %+v", entries)
+	}
+	if err := store.Logout("https://upload.pypi.org/legacy"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound logging out twice, got %v", err)
+	}
+}
+
+func TestStore_Resolver(t *testing.T) {
+	t.Setenv(BackendEnv, "file")
+	store := NewStore(t.TempDir())
+	store.Login("https://pypi.internal", "", "outer")
+	store.Login("https://pypi.internal/team", "team", "inner")
+
+	resolve := store.Resolver()
+	if username, token, ok := resolve("https://pypi.internal/team/simple/foo/"); !ok || username != "team" || token != "inner" {
+		t.Errorf("resolve = %q, %q, %v; want the longest matching index", username, token, ok)
+	}
+	if _, token, ok := resolve("https://pypi.internal/simple/foo/"); !ok || token != "outer" {
+		t.Errorf("resolve = %q, %v", token, ok)
+	}
+	if _, _, ok := resolve("https://pypi.org/simple/foo/"); ok {
+		t.Error("credentials must not be sent to other hosts")
+	}
+}
+
+func TestStore_KeyringUnavailable(t *testing.T) {
+	t.Setenv(BackendEnv, "keyring")
+	store := NewStore(t.TempDir())
+	store.keyring = nil
+	if _, err := store.Login("https://pypi.org", "", "token"); err == nil {
+		t.Error("expected an error when the keyring is forced but unavailable")
+	}
+}
@@ -0,0 +1,275 @@
+package credentials
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"rimraf-adi.com/zephyr/pkg/fsutil"
+)
+
+// Backend names recorded in the index list
+const (
+	keyringBackend = "keyring"
+	fileBackend    = "file"
+)
+
+// keyringService is the service name tokens are filed under in the OS keyring
+const keyringService = "zephyr"
+
+// backend holds one token per index
+type backend interface {
+	name() string
+	get(index string) (string, error)
+	set(index, token string) error
+	delete(index string) error
+}
+
+// systemKeyring returns the OS keyring, or nil when none can be reached:
+// the macOS login keychain, or the Secret Service (GNOME Keyring, KWallet)
+// through secret-tool elsewhere
+func systemKeyring() backend {
+	switch {
+	case runtime.GOOS == "darwin":
+		if _, err := exec.LookPath("security"); err == nil {
+			return macKeychain{}
+		}
+	case runtime.GOOS != "windows":
+		// secret-tool needs a session bus to reach the keyring daemon
+		if _, err := exec.LookPath("secret-tool"); err == nil && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
+			return secretService{}
+		}
+	}
+	return nil
+}
+
+// runTool runs a keyring command with stdin as its input, folding its stderr
+// into the error
+func runTool(stdin string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", name, msg)
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+// macKeychain stores tokens as generic passwords in the login keychain
+type macKeychain struct{}
+
+func (macKeychain) name() string { return keyringBackend }
+
+func (macKeychain) get(index string) (string, error) {
+	token, err := runTool("", "security", "find-generic-password", "-s", keyringService, "-a", index, "-w")
+	if err != nil {
+		return "", ErrNotFound
+	}
+	return token, nil
+}
+
+func (macKeychain) set(index, token string) error {
+	if strings.ContainsAny(token, "\"\\\n") {
+		return fmt.Errorf("the token contains quotes, backslashes or newlines")
+	}
+	// security -i reads the command from stdin, so the token does not appear
+	// in the process list as an argument would
+	command := fmt.Sprintf("add-generic-password -U -s %s -a \"%s\" -l \"zephyr: %s\" -w \"%s\"\n", keyringService, index, index, token)
+	_, err := runTool(command, "security", "-i")
+	return err
+}
+
+func (macKeychain) delete(index string) error {
+	_, err := runTool("", "security", "delete-generic-password", "-s", keyringService, "-a", index)
+	return err
+}
+
+// secretService stores tokens in the freedesktop Secret Service
+type secretService struct{}
+
+func (secretService) name() string { return keyringBackend }
+
+func (secretService) get(index string) (string, error) {
+	token, err := runTool("", "secret-tool", "lookup", "service", keyringService, "index", index)
+	if err != nil || token == "" {
+		return "", ErrNotFound
+	}
+	return token, nil
+}
+
+func (secretService) set(index, token string) error {
+	_, err := runTool(token, "secret-tool", "store", "--label=zephyr: "+index, "service", keyringService, "index", index)
+	return err
+}
+
+func (secretService) delete(index string) error {
+	_, err := runTool("", "secret-tool", "clear", "service", keyringService, "index", index)
+	return err
+}
+
+// unavailableKeyring stands in for a keyring an entry was stored in that
+// cannot be reached now, e.g. over SSH without a session bus
+type unavailableKeyring struct{}
+
+func (unavailableKeyring) name() string { return keyringBackend }
+
+func (unavailableKeyring) get(string) (string, error) {
+	return "", fmt.Errorf("the OS keyring is not available")
+}
+
+func (unavailableKeyring) set(string, string) error {
+	return fmt.Errorf("the OS keyring is not available")
+}
+
+func (unavailableKeyring) delete(string) error {
+	return fmt.Errorf("the OS keyring is not available")
+}
+
+// fileVault keeps tokens in a file encrypted with AES-256-GCM under a random
+// key stored next to it. Both files are readable only by the current user,
+// and that is all the protection there is: anyone who can read the vault can
+// read the key too, so the encryption only obfuscates the tokens, keeping
+// them out of grep results and casual views of the file. Use the OS keyring
+// where real protection is needed.
+type fileVault struct {
+	dir string
+}
+
+func newFileVault(dir string) *fileVault {
+	return &fileVault{dir: dir}
+}
+
+func (*fileVault) name() string { return fileBackend }
+
+func (v *fileVault) get(index string) (string, error) {
+	tokens, err := v.load()
+	if err != nil {
+		return "", err
+	}
+	token, ok := tokens[index]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return token, nil
+}
+
+func (v *fileVault) set(index, token string) error {
+	tokens, err := v.load()
+	if err != nil {
+		return err
+	}
+	tokens[index] = token
+	return v.store(tokens)
+}
+
+func (v *fileVault) delete(index string) error {
+	tokens, err := v.load()
+	if err != nil {
+		return err
+	}
+	delete(tokens, index)
+	return v.store(tokens)
+}
+
+func (v *fileVault) vaultPath() string {
+	return filepath.Join(v.dir, "tokens.enc")
+}
+
+func (v *fileVault) keyPath() string {
+	return filepath.Join(v.dir, "tokens.key")
+}
+
+// key reads the encryption key, creating it on first use
+func (v *fileVault) key() ([]byte, error) {
+	encoded, err := os.ReadFile(v.keyPath())
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(encoded)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("corrupt key file %s", v.keyPath())
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(v.dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := fsutil.WriteFileAtomic(v.keyPath(), []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (v *fileVault) cipher() (cipher.AEAD, error) {
+	key, err := v.key()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (v *fileVault) load() (map[string]string, error) {
+	tokens := make(map[string]string)
+	sealed, err := os.ReadFile(v.vaultPath())
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	aead, err := v.cipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("corrupt credentials file %s", v.vaultPath())
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt %s: it was written with a different key", v.vaultPath())
+	}
+	if err := yaml.Unmarshal(plain, &tokens); err != nil {
+		return nil, fmt.Errorf("corrupt credentials file %s: %w", v.vaultPath(), err)
+	}
+	return tokens, nil
+}
+
+func (v *fileVault) store(tokens map[string]string) error {
+	aead, err := v.cipher()
+	if err != nil {
+		return err
+	}
+	plain, err := yaml.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(v.vaultPath(), aead.Seal(nonce, nonce, plain, nil), 0600)
+}
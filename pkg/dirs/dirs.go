@@ -0,0 +1,102 @@
+// Package dirs locates the per-user directories zephyr keeps its cache,
+// configuration, data and logs in. ZEPHYR_HOME puts all of them under one
+// directory; otherwise the XDG base directory variables are honoured, with
+// the usual macOS and Windows locations as defaults.
+package dirs
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// HomeEnv overrides every zephyr directory with subdirectories of its value
+const HomeEnv = "ZEPHYR_HOME"
+
+// appName is the directory zephyr uses inside the platform base directories
+const appName = "zephyr"
+
+// CacheDir returns the directory for cached downloads and unpacked wheels
+func CacheDir() string {
+	if home := os.Getenv(HomeEnv); home != "" {
+		return filepath.Join(home, "cache")
+	}
+	dir := filepath.Join(baseDir("XDG_CACHE_HOME", ".cache", "Library/Caches", "LOCALAPPDATA"), appName)
+	if runtime.GOOS == "windows" {
+		// Keep the cache apart from the data and logs that share %LOCALAPPDATA%
+		return filepath.Join(dir, "cache")
+	}
+	return dir
+}
+
+// ConfigDir returns the directory holding the global config.yaml
+func ConfigDir() string {
+	if home := os.Getenv(HomeEnv); home != "" {
+		return home
+	}
+	return filepath.Join(baseDir("XDG_CONFIG_HOME", ".config", "Library/Application Support", "APPDATA"), appName)
+}
+
+// DataDir returns the directory for data zephyr manages, such as Python
+// interpreters and environments shared between projects
+func DataDir() string {
+	if home := os.Getenv(HomeEnv); home != "" {
+		return filepath.Join(home, "data")
+	}
+	base := baseDir("XDG_DATA_HOME", ".local/share", "Library/Application Support", "LOCALAPPDATA")
+	if runtime.GOOS == "windows" || (runtime.GOOS == "darwin" && os.Getenv("XDG_DATA_HOME") == "") {
+		// These platforms share the base with the config or cache directory
+		return filepath.Join(base, appName, "data")
+	}
+	return filepath.Join(base, appName)
+}
+
+// LogDir returns the directory for debug logs
+func LogDir() string {
+	if home := os.Getenv(HomeEnv); home != "" {
+		return filepath.Join(home, "logs")
+	}
+	switch {
+	case runtime.GOOS == "windows":
+		return filepath.Join(baseDir("", "", "", "LOCALAPPDATA"), appName, "logs")
+	case runtime.GOOS == "darwin" && os.Getenv("XDG_STATE_HOME") == "":
+		return filepath.Join(baseDir("", "", "Library/Logs", ""), appName)
+	}
+	return filepath.Join(baseDir("XDG_STATE_HOME", ".local/state", "", ""), appName, "logs")
+}
+
+// LegacyDir returns ~/.zephyr, where older releases kept everything
+func LegacyDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".zephyr")
+}
+
+// baseDir resolves a base directory: the XDG variable xdgEnv when it holds an
+// absolute path, %winEnv% on Windows, ~/darwinDir on macOS and ~/unixDir
+// elsewhere. Without a home directory it falls back to the temp directory.
+func baseDir(xdgEnv, unixDir, darwinDir, winEnv string) string {
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv(winEnv); dir != "" {
+			return dir
+		}
+	} else if xdgEnv != "" {
+		// The XDG spec says relative paths are invalid and must be ignored
+		if dir := os.Getenv(xdgEnv); filepath.IsAbs(dir) {
+			return dir
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return os.TempDir()
+	}
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(home, "AppData", "Local")
+	case "darwin":
+		return filepath.Join(home, filepath.FromSlash(darwinDir))
+	}
+	return filepath.Join(home, filepath.FromSlash(unixDir))
+}
@@ -0,0 +1,70 @@
+package dirs
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestZephyrHomeOverride(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(HomeEnv, home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, "ignored"))
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"CacheDir", CacheDir(), filepath.Join(home, "cache")},
+		{"ConfigDir", ConfigDir(), home},
+		{"DataDir", DataDir(), filepath.Join(home, "data")},
+		{"LogDir", LogDir(), filepath.Join(home, "logs")},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s() = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestXDGDirectories(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("XDG variables are not used on Windows")
+	}
+	base := t.TempDir()
+	t.Setenv(HomeEnv, "")
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(base, "cache"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(base, "config"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(base, "data"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(base, "state"))
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"CacheDir", CacheDir(), filepath.Join(base, "cache", "zephyr")},
+		{"ConfigDir", ConfigDir(), filepath.Join(base, "config", "zephyr")},
+		{"DataDir", DataDir(), filepath.Join(base, "data", "zephyr")},
+		{"LogDir", LogDir(), filepath.Join(base, "state", "zephyr", "logs")},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s() = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestRelativeXDGIgnored(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("checks the Linux defaults")
+	}
+	home := t.TempDir()
+	t.Setenv(HomeEnv, "")
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", "relative/cache")
+	if got, want := CacheDir(), filepath.Join(home, ".cache", "zephyr"); got != want {
+		t.Errorf("CacheDir() = %q, want %q", got, want)
+	}
+}
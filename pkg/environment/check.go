@@ -0,0 +1,85 @@
+package environment
+
+import (
+	"fmt"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/markers"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// Problem is an unmet requirement of an installed distribution
+type Problem struct {
+	Distribution string
+	Requirement  string
+	// Installed is the version present, or "" when the requirement is missing
+	Installed string
+}
+
+func (p Problem) String() string {
+	if p.Installed == "" {
+		return fmt.Sprintf("%s requires %s, which is not installed", p.Distribution, p.Requirement)
+	}
+	return fmt.Sprintf("%s requires %s, but %s is installed", p.Distribution, p.Requirement, p.Installed)
+}
+
+// Check verifies that the requirements of every installed distribution that
+// apply to this environment are installed at a matching version
+func (e *Environment) Check() ([]Problem, error) {
+	dists, err := e.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	installed := make(map[string]string, len(dists))
+	for _, dist := range dists {
+		installed[pkgname.Normalize(dist.Name)] = dist.Version
+	}
+	env := e.MarkerEnvironment()
+	var problems []Problem
+	for _, dist := range dists {
+		for _, requirement := range dist.Requires() {
+			spec, marker := markers.SplitRequirement(requirement)
+			applies, err := markers.Evaluate(marker, env)
+			if err != nil {
+				return nil, fmt.Errorf("%s requirement '%s': %w", dist.Name, requirement, err)
+			}
+			if !applies {
+				continue
+			}
+			name := markers.RequirementName(spec)
+			current, ok := installed[pkgname.Normalize(name)]
+			if !ok {
+				problems = append(problems, Problem{Distribution: dist.Name, Requirement: spec})
+				continue
+			}
+			specifier := requirementSpecifier(spec)
+			if specifier == "" {
+				continue
+			}
+			satisfied, err := version.Satisfies(current, specifier)
+			if err != nil {
+				return nil, fmt.Errorf("%s requirement '%s': %w", dist.Name, requirement, err)
+			}
+			if !satisfied {
+				problems = append(problems, Problem{Distribution: dist.Name, Requirement: spec, Installed: current})
+			}
+		}
+	}
+	return problems, nil
+}
+
+// requirementSpecifier returns the version specifier of a PEP 508 requirement,
+// or "" for unconstrained and direct URL requirements
+func requirementSpecifier(spec string) string {
+	rest := strings.TrimSpace(spec[len(markers.RequirementName(spec)):])
+	if strings.HasPrefix(rest, "[") {
+		if end := strings.Index(rest, "]"); end >= 0 {
+			rest = strings.TrimSpace(rest[end+1:])
+		}
+	}
+	if strings.HasPrefix(rest, "@") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(rest, "("), ")"))
+}
@@ -0,0 +1,234 @@
+// Package environment inspects the distributions installed in a virtual environment
+package environment
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/installer"
+	"rimraf-adi.com/zephyr/pkg/markers"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+	"rimraf-adi.com/zephyr/pkg/pypi"
+)
+
+// ErrNotInstalled is returned when a distribution is not present in the environment
+var ErrNotInstalled = errors.New("distribution is not installed")
+
+// Environment is a virtual environment whose site-packages can be inspected
+type Environment struct {
+	Path string
+}
+
+// New returns an Environment for the virtual environment at path
+func New(path string) *Environment {
+	return &Environment{Path: path}
+}
+
+// DirectURL is the PEP 610 direct_url.json of a distribution installed from a URL
+type DirectURL struct {
+	URL          string `json:"url"`
+	Subdirectory string `json:"subdirectory,omitempty"`
+	DirInfo      *struct {
+		Editable bool `json:"editable,omitempty"`
+	} `json:"dir_info,omitempty"`
+	VCSInfo *struct {
+		VCS               string `json:"vcs"`
+		CommitID          string `json:"commit_id"`
+		RequestedRevision string `json:"requested_revision,omitempty"`
+	} `json:"vcs_info,omitempty"`
+	ArchiveInfo *struct {
+		Hash   string            `json:"hash,omitempty"`
+		Hashes map[string]string `json:"hashes,omitempty"`
+	} `json:"archive_info,omitempty"`
+}
+
+// Distribution is one installed distribution and its .dist-info metadata
+type Distribution struct {
+	Name    string
+	Version string
+	// DistInfo is the path of the .dist-info directory
+	DistInfo string
+	// SitePackages is the directory RECORD paths are relative to
+	SitePackages string
+	Metadata     *pypi.CoreMetadata
+	// Files are the RECORD entries, relative to SitePackages
+	Files       []string
+	EntryPoints []installer.EntryPoint
+	DirectURL   *DirectURL
+	Installer   string
+	// record holds the hash and size columns of each RECORD row
+	record []recordRow
+}
+
+// SitePackages returns the environment's site-packages directories
+func (e *Environment) SitePackages() []string {
+	patterns := []string{
+		filepath.Join(e.Path, "lib", "python*", "site-packages"),
+		filepath.Join(e.Path, "lib64", "python*", "site-packages"),
+		filepath.Join(e.Path, "Lib", "site-packages"),
+	}
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			resolved, err := filepath.EvalSymlinks(match)
+			if err != nil || seen[resolved] {
+				continue
+			}
+			seen[resolved] = true
+			dirs = append(dirs, match)
+		}
+	}
+	return dirs
+}
+
+// Distributions returns every installed distribution, sorted by name
+func (e *Environment) Distributions() ([]*Distribution, error) {
+	var dists []*Distribution
+	for _, sitePackages := range e.SitePackages() {
+		matches, err := filepath.Glob(filepath.Join(sitePackages, "*.dist-info"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", sitePackages, err)
+		}
+		for _, distInfo := range matches {
+			dist, err := readDistribution(sitePackages, distInfo)
+			if err != nil {
+				return nil, err
+			}
+			dists = append(dists, dist)
+		}
+	}
+	sort.Slice(dists, func(i, j int) bool {
+		return pkgname.Normalize(dists[i].Name) < pkgname.Normalize(dists[j].Name)
+	})
+	return dists, nil
+}
+
+// Get returns the installed distribution with the given name
+func (e *Environment) Get(name string) (*Distribution, error) {
+	dists, err := e.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	for _, dist := range dists {
+		if pkgname.Normalize(dist.Name) == pkgname.Normalize(name) {
+			return dist, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", name, ErrNotInstalled)
+}
+
+// readDistribution loads a .dist-info directory
+func readDistribution(sitePackages, distInfo string) (*Distribution, error) {
+	base := strings.TrimSuffix(filepath.Base(distInfo), ".dist-info")
+	name, version, _ := strings.Cut(base, "-")
+	dist := &Distribution{Name: name, Version: version, DistInfo: distInfo, SitePackages: sitePackages}
+
+	if data, err := os.ReadFile(filepath.Join(distInfo, "METADATA")); err == nil {
+		meta, err := pypi.ParseCoreMetadata(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s/METADATA: %w", distInfo, err)
+		}
+		dist.Metadata = meta
+		if meta.Name != "" {
+			dist.Name = meta.Name
+		}
+		if meta.Version != "" {
+			dist.Version = meta.Version
+		}
+	} else {
+		dist.Metadata = &pypi.CoreMetadata{Name: name, Version: version}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(distInfo, "RECORD")); err == nil {
+		r := csv.NewReader(bytes.NewReader(data))
+		r.FieldsPerRecord = -1
+		records, err := r.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s/RECORD: %w", distInfo, err)
+		}
+		for _, record := range records {
+			if len(record) > 0 && record[0] != "" {
+				dist.Files = append(dist.Files, record[0])
+				row := recordRow{path: record[0]}
+				if len(record) >= 3 {
+					row.hash, row.size = record[1], record[2]
+				}
+				dist.record = append(dist.record, row)
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(distInfo, "entry_points.txt")); err == nil {
+		entries, err := installer.ParseEntryPoints(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s/entry_points.txt: %w", distInfo, err)
+		}
+		dist.EntryPoints = entries
+	}
+
+	if data, err := os.ReadFile(filepath.Join(distInfo, "direct_url.json")); err == nil {
+		var direct DirectURL
+		if err := json.Unmarshal(data, &direct); err != nil {
+			return nil, fmt.Errorf("failed to parse %s/direct_url.json: %w", distInfo, err)
+		}
+		dist.DirectURL = &direct
+	}
+
+	if data, err := os.ReadFile(filepath.Join(distInfo, "INSTALLER")); err == nil {
+		dist.Installer = strings.TrimSpace(string(data))
+	}
+	return dist, nil
+}
+
+// Requires returns the distribution's Requires-Dist entries
+func (d *Distribution) Requires() []string {
+	if d.Metadata == nil {
+		return nil
+	}
+	return d.Metadata.RequiresDist
+}
+
+// Scripts returns the console and GUI script names the distribution declares
+func (d *Distribution) Scripts() []string {
+	var scripts []string
+	for _, entry := range d.EntryPoints {
+		if entry.Group == "console_scripts" || entry.Group == "gui_scripts" {
+			scripts = append(scripts, entry.Name)
+		}
+	}
+	return scripts
+}
+
+// MarkerEnvironment returns the PEP 508 marker variables of the environment's
+// interpreter. When it cannot be run, the version in the project's
+// .python-version is assumed, and failing that Python 3.11.
+func (e *Environment) MarkerEnvironment() markers.Environment {
+	pythonVersion := "3.11"
+	if output, err := installer.NewVirtualEnvironment(e.Path).GetPythonVersion(); err == nil {
+		pythonVersion = strings.TrimPrefix(output, "Python ")
+	} else if request, _, err := installer.ReadPythonVersion(filepath.Dir(e.Path)); err == nil {
+		if _, ok := installer.PythonMinor(request); ok {
+			pythonVersion = strings.TrimPrefix(request, "python")
+		}
+	}
+	target, err := markers.ParseTarget(fmt.Sprintf("%s-%s-%s", runtime.GOOS, runtime.GOARCH, pythonVersion))
+	if err != nil {
+		target = markers.Target{OS: "linux", Arch: "x86_64", Python: pythonVersion}
+	}
+	return target.Environment()
+}
+
+// binPath returns the environment's scripts directory
+func (e *Environment) binPath() string {
+	return installer.NewVirtualEnvironment(e.Path).GetBinPath()
+}
@@ -0,0 +1,526 @@
+package environment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"rimraf-adi.com/zephyr/pkg/installer"
+	"rimraf-adi.com/zephyr/pkg/installer/wheeltest"
+)
+
+// installWheel builds a wheel from the given members and installs it into venv
+func installWheel(t *testing.T, venv, dist, version, metadata string, files map[string]string) {
+	t.Helper()
+	wheelPath := wheeltest.Wheel{Name: dist, Version: version, Metadata: metadata, Files: files}.Build(t, t.TempDir())
+	if err := installer.NewWheelInstaller(venv).InstallWheel(wheelPath, dist); err != nil {
+		t.Fatalf("InstallWheel(%s) failed: %v", dist, err)
+	}
+}
+
+func TestDistributions(t *testing.T) {
+	venv := filepath.Join(t.TempDir(), "venv")
+	installWheel(t, venv, "web", "2.0.0", "Requires-Dist: helper>=1.0\n", map[string]string{
+		"web/__init__.py":                      "",
+		"web-2.0.0.dist-info/entry_points.txt": "[console_scripts]\nweb = web:main\n",
+	})
+	installWheel(t, venv, "helper", "1.5", "", map[string]string{"helper.py": ""})
+
+	env := New(venv)
+	dists, err := env.Distributions()
+	if err != nil {
+		t.Fatalf("Distributions failed: %v", err)
+	}
+	if len(dists) != 2 || dists[0].Name != "helper" || dists[1].Name != "web" {
+		t.Fatalf("unexpected distributions: %+v", dists)
+	}
+	web, err := env.Get("Web")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if web.Version != "2.0.0" || len(web.Requires()) != 1 || len(web.Scripts()) != 1 {
+		t.Errorf("unexpected distribution: %+v", web)
+	}
+	if _, err := env.Get("missing"); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("expected ErrNotInstalled, got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	venv := filepath.Join(t.TempDir(), "venv")
+	installWheel(t, venv, "web", "2.0.0", "Requires-Dist: helper (>=2.0)\nRequires-Dist: absent\nRequires-Dist: winonly; sys_platform == \"nonexistent\"\nRequires-Dist: docs; extra == \"docs\"\n", map[string]string{"web/__init__.py": ""})
+	installWheel(t, venv, "helper", "1.5", "", map[string]string{"helper.py": ""})
+
+	problems, err := New(venv).Check()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %v", problems)
+	}
+	if problems[0].Installed != "1.5" || problems[1].Requirement != "absent" {
+		t.Errorf("unexpected problems: %+v", problems)
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	venv := filepath.Join(t.TempDir(), "venv")
+	installWheel(t, venv, "web", "2.0.0", "", map[string]string{
+		"web/__init__.py":                      "",
+		"web/static/app.js":                    "",
+		"web-2.0.0.dist-info/entry_points.txt": "[console_scripts]\nweb = web:main\n",
+	})
+	installWheel(t, venv, "helper", "1.5", "", map[string]string{"helper.py": ""})
+	env := New(venv)
+	web, _ := env.Get("web")
+	script := filepath.Join(env.binPath(), "web")
+	if _, err := os.Stat(script); err != nil {
+		t.Fatalf("launcher not installed: %v", err)
+	}
+
+	if _, err := env.Uninstall("web"); err != nil {
+		t.Fatalf("Uninstall failed: %v", err)
+	}
+	for _, path := range []string{filepath.Join(web.SitePackages, "web"), web.DistInfo, script} {
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("%s should be removed", path)
+		}
+	}
+	if _, err := env.Get("helper"); err != nil {
+		t.Errorf("other distributions must be kept: %v", err)
+	}
+	if _, err := env.Uninstall("web"); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("expected ErrNotInstalled on second uninstall, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	venv := filepath.Join(t.TempDir(), "venv")
+	installWheel(t, venv, "web", "2.0.0", "", map[string]string{"web/__init__.py": "VERSION = 2\n", "web/views.py": "def index(): pass\n"})
+	installWheel(t, venv, "helper", "1.5", "", map[string]string{"helper.py": "x = 1\n"})
+	lockfile := installer.NewLockfile("3.11")
+	lockfile.AddPackage("web", installer.LockPackage{Version: "2.0.0", Source: "pypi"})
+	lockfile.AddPackage("helper", installer.LockPackage{Version: "1.5", Source: "pypi"})
+
+	env := New(venv)
+	result, err := env.Verify(lockfile)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !result.OK() || result.Packages != 2 || result.Files < 3 {
+		t.Fatalf("fresh install should verify, got %+v", result)
+	}
+
+	web, _ := env.Get("web")
+	os.WriteFile(filepath.Join(web.SitePackages, "web", "__init__.py"), []byte("VERSION = 3\n"), 0644)
+	os.Remove(filepath.Join(web.SitePackages, "web", "views.py"))
+	lockfile.AddPackage("helper", installer.LockPackage{Version: "1.6", Source: "pypi"})
+	lockfile.AddPackage("absent", installer.LockPackage{Version: "1.0", Source: "pypi"})
+	installWheel(t, venv, "stray", "0.1", "", map[string]string{"stray.py": ""})
+
+	result, err = env.Verify(lockfile)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	var problems []string
+	for _, m := range result.Mismatches {
+		problems = append(problems, m.String())
+	}
+	got := strings.Join(problems, "\n")
+	for _, want := range []string{
+		"absent: locked at 1.0 but not installed",
+		"helper: locked at 1.6 but 1.5 is installed",
+		"web: web/__init__.py was modified",
+		"web: web/views.py is missing",
+		"stray: 0.1 is installed but not in the lockfile",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in mismatches:\n%s", want, got)
+		}
+	}
+}
+
+func TestMissingSharedLibraries(t *testing.T) {
+	venv := filepath.Join(t.TempDir(), "venv")
+	installWheel(t, venv, "cv", "4.0", "", map[string]string{
+		"cv/__init__.py": "",
+		"cv/_core.cpython-311-x86_64-linux-gnu.so": "elf",
+		"cv/_draw.cpython-311-x86_64-linux-gnu.so": "elf",
+		"cv.libs/libpng16-1a2b.so.16.37.0":         "elf",
+	})
+	installWheel(t, venv, "pure", "1.0", "", map[string]string{"pure.py": ""})
+
+	defer func(original func(string) ([]string, error)) { unresolvedLibraries = original }(unresolvedLibraries)
+	var inspected []string
+	unresolvedLibraries = func(path string) ([]string, error) {
+		inspected = append(inspected, filepath.Base(path))
+		if strings.HasPrefix(filepath.Base(path), "_") {
+			return []string{"libGL.so.1", "libgthread-2.0.so.0"}, nil
+		}
+		return nil, nil
+	}
+	missing, err := New(venv).MissingSharedLibraries()
+	if err != nil {
+		t.Fatalf("MissingSharedLibraries failed: %v", err)
+	}
+	if len(inspected) != 3 {
+		t.Errorf("inspected %v, want the three native libraries", inspected)
+	}
+	if len(missing) != 2 || missing[0].Package != "cv" || missing[0].Library != "libGL.so.1" {
+		t.Fatalf("missing = %v, want each library reported once", missing)
+	}
+	if !strings.Contains(missing[0].String(), "install libgl1") {
+		t.Errorf("no install hint in %q", missing[0])
+	}
+
+	inspected = nil
+	if missing, err := New(venv).MissingSharedLibraries("pure"); err != nil || len(missing) != 0 || len(inspected) != 0 {
+		t.Errorf("scanning only pure = %v, %v (inspected %v)", missing, err, inspected)
+	}
+}
+
+func TestParseLinkerOutput(t *testing.T) {
+	ldd := "\tlinux-vdso.so.1 (0x00007ffd)\n\tlibGL.so.1 => not found\n\tlibc.so.6 => /lib/x86_64-linux-gnu/libc.so.6 (0x00007f)\n"
+	if got := parseLdd([]byte(ldd)); len(got) != 1 || got[0] != "libGL.so.1" {
+		t.Errorf("parseLdd = %v", got)
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "libpresent.dylib"), nil, 0644)
+	otool := "/site/_mod.so:\n" +
+		"\t@loader_path/libpresent.dylib (compatibility version 1.0.0)\n" +
+		"\t@loader_path/libabsent.dylib (compatibility version 1.0.0)\n" +
+		"\t/opt/homebrew/lib/libnothere.1.dylib (compatibility version 1.0.0)\n" +
+		"\t/usr/lib/libSystem.B.dylib (compatibility version 1.0.0)\n" +
+		"\t@rpath/libfoo.dylib (compatibility version 1.0.0)\n"
+	got := parseOtool([]byte(otool), dir)
+	if len(got) != 2 || got[0] != "@loader_path/libabsent.dylib" || got[1] != "/opt/homebrew/lib/libnothere.1.dylib" {
+		t.Errorf("parseOtool = %v", got)
+	}
+}
+
+func TestSmokeTest(t *testing.T) {
+	venv := filepath.Join(t.TempDir(), "venv")
+	installWheel(t, venv, "widgets", "1.0", "", map[string]string{
+		"widgets/__init__.py": "",
+		"widgets/core.py":     "",
+		"_widgets_speedups.cpython-311-x86_64-linux-gnu.so": "elf",
+		"widgets_compat.py":           "",
+		"google/protobuf/__init__.py": "",
+		"google/protobuf/message.py":  "",
+		"widgets-stubs/__init__.pyi":  "",
+		"README.txt":                  "",
+	})
+	installWheel(t, venv, "pip", "24.0", "", map[string]string{"pip/__init__.py": ""})
+	installWheel(t, venv, "other", "1.0", "", map[string]string{"other.py": ""})
+
+	dist, err := New(venv).Get("widgets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"_widgets_speedups", "google.protobuf", "widgets", "widgets_compat"}
+	if got := dist.TopLevelModules(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("TopLevelModules = %v, want %v", got, want)
+	}
+
+	defer func(original func(string, string, string) error) { importModule = original }(importModule)
+	var imported []string
+	importModule = func(python, dir, module string) error {
+		imported = append(imported, module)
+		if module == "_widgets_speedups" {
+			return errors.New("ImportError: libfoo.so.1: cannot open shared object file")
+		}
+		return nil
+	}
+	result, err := New(venv).SmokeTest()
+	if err != nil {
+		t.Fatalf("SmokeTest failed: %v", err)
+	}
+	// pip came with the venv and is skipped
+	if result.Modules != 5 || len(imported) != 5 || result.OK() {
+		t.Fatalf("SmokeTest = %+v, imported %v", result, imported)
+	}
+	if got := result.Failures[0].String(); got != "widgets: import _widgets_speedups failed: ImportError: libfoo.so.1: cannot open shared object file" {
+		t.Errorf("failure = %q", got)
+	}
+
+	imported = nil
+	if result, err := New(venv).SmokeTest("Other"); err != nil || !result.OK() || strings.Join(imported, ",") != "other" {
+		t.Errorf("SmokeTest(Other) = %+v, %v (imported %v)", result, err, imported)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	venv := filepath.Join(t.TempDir(), "venv")
+	installWheel(t, venv, "web", "1.0", "", map[string]string{"web/__init__.py": "V = 1\n", "web/old.py": ""})
+	installWheel(t, venv, "web", "2.0", "", map[string]string{"web/__init__.py": "V = 2\n", "web/new.py": ""})
+	installWheel(t, venv, "helper", "1.4", "", map[string]string{"helper.py": ""})
+	installWheel(t, venv, "stray", "0.1", "", map[string]string{"stray.py": ""})
+	installWheel(t, venv, "pip", "24.0", "", map[string]string{"pip/__init__.py": ""})
+	env := New(venv)
+	old, _ := filepath.Glob(filepath.Join(env.SitePackages()[0], "web-1.0.dist-info"))
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(old[0], past, past)
+
+	dups, err := env.Duplicates()
+	if err != nil || len(dups) != 1 {
+		t.Fatalf("Duplicates = %v, %v", dups, err)
+	}
+	if got := dups[0].String(); got != "web is installed 2 times: 2.0 (web-2.0.dist-info), 1.0 (web-1.0.dist-info)" {
+		t.Errorf("duplicate = %q", got)
+	}
+
+	lockfile := installer.NewLockfile("3.11")
+	lockfile.AddPackage("web", installer.LockPackage{Version: "2.0", Source: "pypi"})
+	lockfile.AddPackage("helper", installer.LockPackage{Version: "1.5", Source: "pypi"})
+	lockfile.AddPackage("absent", installer.LockPackage{Version: "1.0", Source: "pypi"})
+	plan, err := env.PlanRepair(lockfile)
+	if err != nil {
+		t.Fatalf("PlanRepair failed: %v", err)
+	}
+	var got []string
+	for _, s := range plan.Stale {
+		got = append(got, s.String())
+	}
+	for _, r := range plan.Remove {
+		got = append(got, r.String())
+	}
+	got = append(got, plan.Reinstall...)
+	want := []string{
+		"web 1.0: stale web-1.0.dist-info, superseded by 2.0",
+		"helper 1.4: locked at 1.5",
+		"stray 0.1: not in the lockfile",
+		"absent", "helper",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("plan =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if _, err := env.ApplyRepair(plan); err != nil {
+		t.Fatalf("ApplyRepair failed: %v", err)
+	}
+	site := env.SitePackages()[0]
+	for path, exists := range map[string]bool{"web/__init__.py": true, "web/new.py": true, "web/old.py": false, "web-1.0.dist-info": false, "helper.py": false, "stray.py": false, "pip": true} {
+		if _, err := os.Stat(filepath.Join(site, path)); (err == nil) != exists {
+			t.Errorf("%s exists = %v after repair, want %v", path, err == nil, exists)
+		}
+	}
+	plan, err = env.PlanRepair(lockfile)
+	if err != nil || len(plan.Stale)+len(plan.Remove) != 0 || strings.Join(plan.Reinstall, ",") != "absent,helper" {
+		t.Errorf("plan after repair = %+v, %v", plan, err)
+	}
+}
+
+func TestSizes(t *testing.T) {
+	venv := filepath.Join(t.TempDir(), "venv")
+	installWheel(t, venv, "app", "1.0", "Requires-Dist: numpy\nRequires-Dist: tiny\n", map[string]string{"app.py": "x"})
+	installWheel(t, venv, "numpy", "1.26", "Requires-Dist: openblas\n", map[string]string{"numpy/__init__.py": strings.Repeat("n", 100)})
+	installWheel(t, venv, "openblas", "0.3", "", map[string]string{"openblas/lib.so": strings.Repeat("b", 1000)})
+	installWheel(t, venv, "tiny", "1.0", "Requires-Dist: missing\n", map[string]string{"tiny.py": "t"})
+	installWheel(t, venv, "tool", "2.0", "", map[string]string{"tool.py": strings.Repeat("t", 10)})
+	installWheel(t, venv, "pip", "24.0", "", map[string]string{"pip/__init__.py": strings.Repeat("p", 5000)})
+	env := New(venv)
+
+	sizes, err := env.PackageSizes()
+	if err != nil {
+		t.Fatalf("PackageSizes failed: %v", err)
+	}
+	if len(sizes) != 6 || sizes[0].Name != "pip" || sizes[1].Name != "openblas" || sizes[1].Size < 1000 || sizes[1].Files < 2 {
+		t.Errorf("PackageSizes = %+v", sizes)
+	}
+	total, err := env.Footprint()
+	if err != nil || total < 6111 {
+		t.Errorf("Footprint = %d, %v", total, err)
+	}
+
+	chains, err := env.DependencyChains(nil)
+	if err != nil {
+		t.Fatalf("DependencyChains failed: %v", err)
+	}
+	// pip is a seed package and tiny is required by app, so neither is a root
+	if len(chains) != 2 || chains[0].Root != "app" || chains[1].Root != "tool" {
+		t.Fatalf("chains = %+v", chains)
+	}
+	if got := strings.Join(chains[0].Path, " -> "); got != "app -> numpy -> openblas" {
+		t.Errorf("heaviest path = %q", got)
+	}
+	var app int64
+	for _, s := range sizes {
+		if s.Name != "pip" && s.Name != "tool" {
+			app += s.Size
+		}
+	}
+	if chains[0].Size != app {
+		t.Errorf("app chain size = %d, want %d", chains[0].Size, app)
+	}
+
+	chains, err = env.DependencyChains([]string{"Tiny", "absent"})
+	if err != nil || len(chains) != 1 || strings.Join(chains[0].Path, ",") != "tiny" {
+		t.Errorf("DependencyChains(tiny) = %+v, %v", chains, err)
+	}
+}
+
+func TestDependencyUsage(t *testing.T) {
+	project := t.TempDir()
+	files := map[string]string{
+		"app/__init__.py":   "import requests, yaml as pyyaml\nfrom google.protobuf import message  # noqa\n",
+		"app/util.py":       "from . import models\nif True:\n    import requests.adapters\n# import flask\n",
+		"tests/test_app.py": "from app import util\n",
+		".venv/pyvenv.cfg":  "",
+		"env/pyvenv.cfg":    "",
+		"env/lib/mod.py":    "import click\n",
+		"notes.txt":         "import flask\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(project, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+	imports, err := ScanImports(project)
+	if err != nil {
+		t.Fatalf("ScanImports failed: %v", err)
+	}
+	var modules []string
+	for module := range imports {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	if got := strings.Join(modules, ","); got != "app,google.protobuf,requests,requests.adapters,yaml" {
+		t.Errorf("imported modules = %s", got)
+	}
+
+	venv := filepath.Join(t.TempDir(), "venv")
+	installWheel(t, venv, "requests", "2.31", "", map[string]string{"requests/__init__.py": "", "requests/adapters.py": ""})
+	installWheel(t, venv, "PyYAML", "6.0", "", map[string]string{"yaml/__init__.py": "", "_yaml/__init__.py": ""})
+	installWheel(t, venv, "protobuf", "4.25", "", map[string]string{"google/protobuf/__init__.py": "", "protobuf-4.25.dist-info/top_level.txt": "google\n"})
+	installWheel(t, venv, "flask", "3.0", "", map[string]string{"flask/__init__.py": ""})
+	installWheel(t, venv, "gunicorn-plugin", "1.0", "", map[string]string{"gunicorn-plugin-1.0.dist-info/entry_points.txt": "[x]\ny = z\n"})
+	usages, err := New(venv).DependencyUsage([]string{"requests", "pyyaml", "protobuf", "Flask", "gunicorn-plugin", "absent"}, imports)
+	if err != nil {
+		t.Fatalf("DependencyUsage failed: %v", err)
+	}
+	var unused []string
+	for _, usage := range usages {
+		if usage.Unused() {
+			unused = append(unused, usage.Name)
+		}
+	}
+	if strings.Join(unused, ",") != "Flask" {
+		t.Errorf("unused = %v, usages = %+v", unused, usages)
+	}
+	if got := strings.Join(usages[0].ImportedBy, ","); got != "app/__init__.py,app/util.py" {
+		t.Errorf("requests imported by %s", got)
+	}
+	if usages[5].Installed {
+		t.Error("absent reported as installed")
+	}
+}
+
+func TestUndeclaredImports(t *testing.T) {
+	project := t.TempDir()
+	files := map[string]string{
+		"src/app/__init__.py": "import os, sys\nimport urllib3.util\nfrom app import helpers\nimport certifi\n",
+		"src/app/helpers.py":  "import requests\nfrom __future__ import annotations\nimport yaml\nimport cv2\nimport idna.core\n",
+		"scripts/run.py":      "import helpers\nimport urllib3\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(project, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+	imports, err := ScanImports(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := ProjectModules(project)
+	if err != nil || !local["app"] || !local["helpers"] || !local["run"] || !local["src"] {
+		t.Fatalf("ProjectModules = %v, %v", local, err)
+	}
+	ignore := map[string]bool{"os": true, "sys": true}
+	for module := range local {
+		ignore[module] = true
+	}
+
+	venv := filepath.Join(t.TempDir(), "venv")
+	installWheel(t, venv, "requests", "2.31", "Requires-Dist: urllib3\nRequires-Dist: idna\n", map[string]string{"requests/__init__.py": ""})
+	installWheel(t, venv, "urllib3", "2.0", "", map[string]string{"urllib3/__init__.py": "", "urllib3/util/__init__.py": ""})
+	installWheel(t, venv, "idna", "3.4", "", map[string]string{"idna/__init__.py": ""})
+	installWheel(t, venv, "certifi", "2024.2", "", map[string]string{"certifi/__init__.py": ""})
+	result, err := New(venv).UndeclaredImports([]string{"requests", "certifi", "PyYAML"}, imports, ignore)
+	if err != nil {
+		t.Fatalf("UndeclaredImports failed: %v", err)
+	}
+	var got []string
+	for _, u := range result {
+		got = append(got, fmt.Sprintf("%s=%s via %v in %v", u.Module, u.Suggestion(), u.RequiredBy, u.ImportedBy))
+	}
+	want := []string{
+		"idna.core=idna via [requests] in [src/app/helpers.py]",
+		"urllib3=urllib3 via [requests] in [scripts/run.py src/app/__init__.py]",
+		"cv2=opencv-python via [] in [src/app/helpers.py]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("UndeclaredImports =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	venv := filepath.Join(t.TempDir(), "venv")
+	installWheel(t, venv, "web", "2.0", "", map[string]string{"web/__init__.py": ""})
+	installWheel(t, venv, "pytest", "8.0", "Requires-Dist: pluggy>=1.0\n", map[string]string{"pytest.py": ""})
+	installWheel(t, venv, "pluggy", "1.4", "", map[string]string{"pluggy.py": ""})
+	installWheel(t, venv, "stray", "0.1", "", map[string]string{"stray.py": ""})
+	installWheel(t, venv, "pip", "24.0", "", map[string]string{"pip/__init__.py": ""})
+	env := New(venv)
+	lockfile := installer.NewLockfile("3.11")
+	lockfile.AddPackage("web", installer.LockPackage{Version: "2.0", Source: "pypi"})
+
+	orphans, err := env.Orphans(lockfile, []string{"PyTest"})
+	if err != nil {
+		t.Fatalf("Orphans failed: %v", err)
+	}
+	if len(orphans) != 1 || orphans[0].String() != "stray 0.1: not in the lockfile" {
+		t.Fatalf("orphans = %v, want only stray", orphans)
+	}
+	orphans, err = env.Orphans(lockfile, nil)
+	if err != nil || len(orphans) != 3 {
+		t.Fatalf("orphans without keep = %v, %v; want pluggy, pytest and stray", orphans, err)
+	}
+	if _, err := env.ApplyRepair(&RepairPlan{Remove: orphans}); err != nil {
+		t.Fatalf("ApplyRepair failed: %v", err)
+	}
+	if orphans, err := env.Orphans(lockfile, nil); err != nil || len(orphans) != 0 {
+		t.Errorf("orphans after prune = %v, %v", orphans, err)
+	}
+}
+
+func TestPipdeptree(t *testing.T) {
+	venv := filepath.Join(t.TempDir(), "venv")
+	installWheel(t, venv, "Web_App", "2.0.0", "Requires-Dist: helper (>=1.0, <2)\nRequires-Dist: absent\nRequires-Dist: docs; extra == \"docs\"\n", map[string]string{"web_app/__init__.py": ""})
+	installWheel(t, venv, "helper", "1.5", "", map[string]string{"helper.py": ""})
+
+	entries, err := New(venv).Tree()
+	if err != nil {
+		t.Fatalf("Tree failed: %v", err)
+	}
+	var out strings.Builder
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(Pipdeptree(entries)); err != nil {
+		t.Fatal(err)
+	}
+	data := strings.TrimSpace(out.String())
+	want := `[{"package":{"key":"helper","package_name":"helper","installed_version":"1.5"},"dependencies":[]},` +
+		`{"package":{"key":"web-app","package_name":"Web_App","installed_version":"2.0.0"},"dependencies":[` +
+		`{"key":"helper","package_name":"helper","installed_version":"1.5","required_version":">=1.0,<2"},` +
+		`{"key":"absent","package_name":"absent","installed_version":"?","required_version":"Any"}]}]`
+	if data != want {
+		t.Errorf("unexpected pipdeptree output:\n%s\nwant:\n%s", data, want)
+	}
+}
@@ -0,0 +1,370 @@
+package environment
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/installer"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+var (
+	// importStatement matches "import a.b, c as d"
+	importStatement = regexp.MustCompile(`^\s*import\s+(.+)$`)
+	// fromStatement matches "from a.b import c", but not relative imports
+	fromStatement = regexp.MustCompile(`^\s*from\s+([A-Za-z_][\w.]*)\s+import\b`)
+)
+
+// skippedSourceDirs are never scanned for imports
+var skippedSourceDirs = map[string]bool{
+	"__pycache__": true, "node_modules": true, "build": true, "dist": true, "site-packages": true,
+}
+
+// ScanImports returns the absolute module names the Python files under root
+// import, each with the files importing it relative to root. Virtual
+// environments, hidden directories and build output are skipped. Imports are
+// found line by line, so ones inside strings are counted and ones split
+// across lines may be missed; dynamic imports are not seen at all.
+func ScanImports(root string) (map[string][]string, error) {
+	imports := make(map[string][]string)
+	err := walkSources(root, func(path, rel string) error {
+		modules, err := fileImports(path)
+		if err != nil {
+			return err
+		}
+		for _, module := range modules {
+			imports[module] = append(imports[module], rel)
+		}
+		return nil
+	})
+	return imports, err
+}
+
+// ProjectModules returns the top-level names the project's own Python files
+// can be imported as: every module file and every package directory, at any
+// depth so that src/ layouts and scripts run from subdirectories are covered
+func ProjectModules(root string) (map[string]bool, error) {
+	modules := make(map[string]bool)
+	err := walkSources(root, func(path, rel string) error {
+		modules[strings.TrimSuffix(filepath.Base(rel), ".py")] = true
+		for dir := filepath.Dir(rel); dir != "."; dir = filepath.Dir(dir) {
+			modules[filepath.Base(dir)] = true
+		}
+		return nil
+	})
+	return modules, err
+}
+
+// walkSources calls fn with the path and slash-separated path relative to
+// root of each Python file in the project, skipping virtual environments,
+// hidden directories and build output
+func walkSources(root string, fn func(path, rel string) error) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			if strings.HasPrefix(d.Name(), ".") || skippedSourceDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "pyvenv.cfg")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".py") {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		return fn(path, filepath.ToSlash(rel))
+	})
+}
+
+// fileImports returns the modules one Python file imports, each once
+func fileImports(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	seen := make(map[string]bool)
+	var modules []string
+	add := func(module string) {
+		if module != "" && !seen[module] {
+			seen[module] = true
+			modules = append(modules, module)
+		}
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if m := fromStatement.FindStringSubmatch(line); m != nil {
+			add(m[1])
+			continue
+		}
+		if m := importStatement.FindStringSubmatch(line); m != nil {
+			for _, part := range strings.Split(strings.Trim(m[1], " \t()\\"), ",") {
+				name, _, _ := strings.Cut(strings.TrimSpace(part), " ")
+				add(name)
+			}
+		}
+	}
+	return modules, scanner.Err()
+}
+
+// DependencyUsage is whether the project imports a declared dependency
+type DependencyUsage struct {
+	Name      string
+	Installed bool
+	// Modules are the modules the installed distribution provides
+	Modules []string
+	// ImportedBy lists the project files importing any of Modules
+	ImportedBy []string
+}
+
+// Unused reports whether the dependency provides modules none of which are
+// imported. Distributions without modules, such as command-line tools and
+// plugins, are never reported unused.
+func (u DependencyUsage) Unused() bool {
+	return u.Installed && len(u.Modules) > 0 && len(u.ImportedBy) == 0
+}
+
+// DependencyUsage matches the imports found by ScanImports against the
+// modules each declared dependency installed, from its RECORD and
+// top_level.txt
+func (e *Environment) DependencyUsage(declared []string, imports map[string][]string) ([]DependencyUsage, error) {
+	dists, err := e.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	installed := make(map[string]*Distribution, len(dists))
+	for _, dist := range dists {
+		installed[pkgname.Normalize(dist.Name)] = dist
+	}
+	var usages []DependencyUsage
+	for _, name := range declared {
+		usage := DependencyUsage{Name: name}
+		if dist, ok := installed[pkgname.Normalize(name)]; ok {
+			usage.Installed = true
+			usage.Modules = dist.providedModules()
+			files := make(map[string]bool)
+			for imported, importers := range imports {
+				for _, module := range usage.Modules {
+					if imported == module || strings.HasPrefix(imported, module+".") {
+						for _, file := range importers {
+							files[file] = true
+						}
+					}
+				}
+			}
+			for file := range files {
+				usage.ImportedBy = append(usage.ImportedBy, file)
+			}
+			sort.Strings(usage.ImportedBy)
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+// providedModules returns the distribution's top-level modules from RECORD,
+// together with any top_level.txt names for modules RECORD does not show,
+// such as ones installed outside site-packages
+func (d *Distribution) providedModules() []string {
+	modules := d.TopLevelModules()
+	seen := make(map[string]bool, len(modules))
+	for _, module := range modules {
+		seen[module] = true
+		seen[strings.SplitN(module, ".", 2)[0]] = true
+	}
+	if data, err := os.ReadFile(filepath.Join(d.DistInfo, "top_level.txt")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			module := strings.ReplaceAll(strings.TrimSpace(line), "/", ".")
+			if module != "" && !seen[module] {
+				seen[module] = true
+				modules = append(modules, module)
+			}
+		}
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// UndeclaredImport is a module the project imports that no declared
+// dependency provides
+type UndeclaredImport struct {
+	Module     string
+	ImportedBy []string
+	// Distribution is the installed distribution providing Module, or ""
+	// when none does
+	Distribution string
+	// RequiredBy lists the installed distributions that require
+	// Distribution, through which it was installed
+	RequiredBy []string
+}
+
+// Suggestion is the distribution to add for the import: the one installing
+// it, or failing that a guess from the module name
+func (u UndeclaredImport) Suggestion() string {
+	if u.Distribution != "" {
+		return u.Distribution
+	}
+	top := strings.SplitN(u.Module, ".", 2)[0]
+	if name, ok := moduleDistributions[top]; ok {
+		return name
+	}
+	return strings.ReplaceAll(top, "_", "-")
+}
+
+// moduleDistributions names the distributions of well-known modules whose
+// import name differs from their project name
+var moduleDistributions = map[string]string{
+	"yaml":        "PyYAML",
+	"PIL":         "Pillow",
+	"cv2":         "opencv-python",
+	"sklearn":     "scikit-learn",
+	"skimage":     "scikit-image",
+	"bs4":         "beautifulsoup4",
+	"dateutil":    "python-dateutil",
+	"dotenv":      "python-dotenv",
+	"jwt":         "PyJWT",
+	"magic":       "python-magic",
+	"serial":      "pyserial",
+	"usb":         "pyusb",
+	"Crypto":      "pycryptodome",
+	"OpenSSL":     "pyOpenSSL",
+	"MySQLdb":     "mysqlclient",
+	"psycopg2":    "psycopg2-binary",
+	"google":      "protobuf",
+	"attr":        "attrs",
+	"jose":        "python-jose",
+	"multipart":   "python-multipart",
+	"docx":        "python-docx",
+	"pptx":        "python-pptx",
+	"zmq":         "pyzmq",
+	"git":         "GitPython",
+	"win32api":    "pywin32",
+	"gi":          "PyGObject",
+	"fitz":        "PyMuPDF",
+	"Levenshtein": "python-Levenshtein",
+}
+
+// UndeclaredImports finds the imports that none of the declared
+// distributions provide. Modules in ignore, such as the standard library and
+// the project's own, are skipped, as are relative and __future__ imports.
+func (e *Environment) UndeclaredImports(declared []string, imports map[string][]string, ignore map[string]bool) ([]UndeclaredImport, error) {
+	dists, err := e.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	isDeclared := make(map[string]bool, len(declared))
+	for _, name := range declared {
+		isDeclared[pkgname.Normalize(name)] = true
+	}
+	providers := make(map[string]*Distribution)
+	for _, dist := range dists {
+		for _, module := range dist.providedModules() {
+			providers[module] = dist
+		}
+	}
+	requiredBy := make(map[string][]string)
+	for name, deps := range e.requirementGraph(dists) {
+		for _, dep := range deps {
+			requiredBy[dep] = append(requiredBy[dep], name)
+		}
+	}
+
+	// Report each distribution, or each unprovided top-level module, once
+	found := make(map[string]*UndeclaredImport)
+	var keys []string
+	for module, importers := range imports {
+		top := strings.SplitN(module, ".", 2)[0]
+		if ignore[top] || top == "__future__" {
+			continue
+		}
+		var provider *Distribution
+		for prefix := module; prefix != ""; {
+			if dist, ok := providers[prefix]; ok {
+				provider = dist
+				break
+			}
+			i := strings.LastIndex(prefix, ".")
+			if i < 0 {
+				break
+			}
+			prefix = prefix[:i]
+		}
+		key := "module:" + top
+		if provider == nil && isDeclared[pkgname.Normalize(UndeclaredImport{Module: top}.Suggestion())] {
+			// Declared but not installed yet
+			continue
+		}
+		if provider != nil {
+			if isDeclared[pkgname.Normalize(provider.Name)] {
+				continue
+			}
+			key = "dist:" + pkgname.Normalize(provider.Name)
+		}
+		u, ok := found[key]
+		if !ok {
+			u = &UndeclaredImport{Module: top}
+			if provider != nil {
+				u.Module = module
+				u.Distribution = provider.Name
+				u.RequiredBy = requiredBy[pkgname.Normalize(provider.Name)]
+				sort.Strings(u.RequiredBy)
+			}
+			found[key] = u
+			keys = append(keys, key)
+		} else if provider != nil && module < u.Module {
+			u.Module = module
+		}
+		u.ImportedBy = append(u.ImportedBy, importers...)
+	}
+	sort.Strings(keys)
+	var result []UndeclaredImport
+	for _, key := range keys {
+		u := found[key]
+		u.ImportedBy = uniqueSorted(u.ImportedBy)
+		result = append(result, *u)
+	}
+	return result, nil
+}
+
+// uniqueSorted sorts values and drops repeats
+func uniqueSorted(values []string) []string {
+	sort.Strings(values)
+	var out []string
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// StdlibModules asks the environment's interpreter for the names of the
+// standard library's top-level modules, which needs Python 3.10 or later
+func (e *Environment) StdlibModules() (map[string]bool, error) {
+	python := installer.NewVirtualEnvironment(e.Path).GetPythonPath()
+	out, err := exec.Command(python, "-c", "import sys; print('\\n'.join(sorted(set(sys.stdlib_module_names) | set(sys.builtin_module_names))))").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the standard library modules with %s (Python 3.10 or later is needed): %w", python, err)
+	}
+	modules := make(map[string]bool)
+	for _, line := range strings.Fields(string(out)) {
+		modules[line] = true
+	}
+	return modules, nil
+}
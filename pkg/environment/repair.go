@@ -0,0 +1,242 @@
+package environment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/installer"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// Duplicate is a project with more than one .dist-info directory, as left by
+// installers that upgrade in place without removing the old metadata. Which
+// version is importable depends on the files, not on either directory.
+type Duplicate struct {
+	Name string
+	// Dists are the installations, newest .dist-info directory first
+	Dists []*Distribution
+}
+
+func (d Duplicate) String() string {
+	var installs []string
+	for _, dist := range d.Dists {
+		installs = append(installs, fmt.Sprintf("%s (%s)", dist.Version, filepath.Base(dist.DistInfo)))
+	}
+	return fmt.Sprintf("%s is installed %d times: %s", d.Name, len(d.Dists), strings.Join(installs, ", "))
+}
+
+// Duplicates returns the projects installed more than once, sorted by name
+func (e *Environment) Duplicates() ([]Duplicate, error) {
+	dists, err := e.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	return duplicates(dists), nil
+}
+
+// duplicates groups dists, which are sorted by name, into the projects
+// installed more than once
+func duplicates(dists []*Distribution) []Duplicate {
+	var result []Duplicate
+	for i := 0; i < len(dists); {
+		j := i + 1
+		for j < len(dists) && pkgname.Normalize(dists[j].Name) == pkgname.Normalize(dists[i].Name) {
+			j++
+		}
+		if j-i > 1 {
+			group := append([]*Distribution(nil), dists[i:j]...)
+			sort.SliceStable(group, func(a, b int) bool { return modTime(group[a].DistInfo) > modTime(group[b].DistInfo) })
+			result = append(result, Duplicate{Name: dists[i].Name, Dists: group})
+		}
+		i = j
+	}
+	return result
+}
+
+// modTime returns when path was last modified, in nanoseconds
+func modTime(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.ModTime().UnixNano()
+}
+
+// StaleDistribution is a .dist-info directory shadowed by another
+// installation of the same project
+type StaleDistribution struct {
+	Dist    *Distribution
+	Current *Distribution
+}
+
+func (s StaleDistribution) String() string {
+	return fmt.Sprintf("%s %s: stale %s, superseded by %s", s.Dist.Name, s.Dist.Version, filepath.Base(s.Dist.DistInfo), s.Current.Version)
+}
+
+// Removal is a distribution to uninstall and why
+type Removal struct {
+	Dist   *Distribution
+	Reason string
+}
+
+func (r Removal) String() string {
+	return fmt.Sprintf("%s %s: %s", r.Dist.Name, r.Dist.Version, r.Reason)
+}
+
+// RepairPlan lists the changes that bring an environment back in line with
+// its lockfile
+type RepairPlan struct {
+	// Stale installations are removed, keeping the files their current
+	// installation also owns
+	Stale []StaleDistribution
+	// Remove lists distributions that are not locked or are at the wrong version
+	Remove []Removal
+	// Reinstall names the locked packages to install once the others are
+	// removed, because they are missing, at the wrong version or modified
+	Reinstall []string
+}
+
+// Empty reports whether the environment needs no repair
+func (p *RepairPlan) Empty() bool {
+	return len(p.Stale) == 0 && len(p.Remove) == 0 && len(p.Reinstall) == 0
+}
+
+// PlanRepair works out how to repair the environment without changing it.
+// Of a project's duplicate installations, the one at its locked version is
+// kept, or failing that the newest. When lf is nil only the duplicates are
+// repaired.
+func (e *Environment) PlanRepair(lf *installer.Lockfile) (*RepairPlan, error) {
+	dists, err := e.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	lockedVersions := make(map[string]string)
+	if lf != nil {
+		for name, pkg := range lf.Packages {
+			lockedVersions[pkgname.Normalize(name)] = pkg.Version
+		}
+	}
+
+	plan := &RepairPlan{}
+	current := make(map[string]*Distribution, len(dists))
+	for _, dist := range dists {
+		current[pkgname.Normalize(dist.Name)] = dist
+	}
+	for _, dup := range duplicates(dists) {
+		name := pkgname.Normalize(dup.Name)
+		keep := dup.Dists[0]
+		for _, dist := range dup.Dists {
+			if locked, ok := lockedVersions[name]; ok && dist.Version == locked {
+				keep = dist
+				break
+			}
+		}
+		current[name] = keep
+		for _, dist := range dup.Dists {
+			if dist != keep {
+				plan.Stale = append(plan.Stale, StaleDistribution{Dist: dist, Current: keep})
+			}
+		}
+	}
+	if lf == nil {
+		return plan, nil
+	}
+
+	names := make([]string, 0, len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dist := current[name]
+		locked, ok := lockedVersions[name]
+		switch {
+		case !ok && !seedPackages[name]:
+			plan.Remove = append(plan.Remove, Removal{Dist: dist, Reason: "not in the lockfile"})
+		case ok && dist.Version != locked:
+			plan.Remove = append(plan.Remove, Removal{Dist: dist, Reason: fmt.Sprintf("locked at %s", locked)})
+		}
+	}
+
+	lockedNames := make([]string, 0, len(lf.Packages))
+	for name := range lf.Packages {
+		lockedNames = append(lockedNames, name)
+	}
+	sort.Strings(lockedNames)
+	for _, name := range lockedNames {
+		dist, ok := current[pkgname.Normalize(name)]
+		if !ok || dist.Version != lf.Packages[name].Version {
+			plan.Reinstall = append(plan.Reinstall, name)
+			continue
+		}
+		if _, mismatches, err := e.verifyRecord(dist); err != nil {
+			return nil, err
+		} else if len(mismatches) > 0 {
+			plan.Reinstall = append(plan.Reinstall, name)
+		}
+	}
+	return plan, nil
+}
+
+// ApplyRepair removes the stale and unwanted distributions in plan and
+// returns the removed paths. Installing plan.Reinstall is left to the caller.
+func (e *Environment) ApplyRepair(plan *RepairPlan) ([]string, error) {
+	var removed []string
+	for _, stale := range plan.Stale {
+		paths, err := e.remove(stale.Dist, stale.Current)
+		removed = append(removed, paths...)
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", filepath.Base(stale.Dist.DistInfo), err)
+		}
+	}
+	for _, removal := range plan.Remove {
+		paths, err := e.remove(removal.Dist, nil)
+		removed = append(removed, paths...)
+		if err != nil {
+			return removed, fmt.Errorf("failed to uninstall %s: %w", removal.Dist.Name, err)
+		}
+	}
+	return removed, nil
+}
+
+// Orphans returns the installed distributions lf does not lock, for pruning
+// an environment down to its lockfile. The seed packages, the packages named
+// in keep and the installed packages they require are left alone.
+func (e *Environment) Orphans(lf *installer.Lockfile, keep []string) ([]Removal, error) {
+	dists, err := e.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool)
+	for name := range lf.Packages {
+		wanted[pkgname.Normalize(name)] = true
+	}
+	// Keep the whole installed subtree of each kept package
+	graph := e.requirementGraph(dists)
+	queue := make([]string, 0, len(keep))
+	for _, name := range keep {
+		queue = append(queue, pkgname.Normalize(name))
+	}
+	kept := make(map[string]bool)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if kept[name] {
+			continue
+		}
+		kept[name] = true
+		queue = append(queue, graph[name]...)
+	}
+
+	var orphans []Removal
+	for _, dist := range dists {
+		name := pkgname.Normalize(dist.Name)
+		if !wanted[name] && !kept[name] && !seedPackages[name] {
+			orphans = append(orphans, Removal{Dist: dist, Reason: "not in the lockfile"})
+		}
+	}
+	return orphans, nil
+}
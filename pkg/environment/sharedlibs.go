@@ -0,0 +1,197 @@
+package environment
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+	"rimraf-adi.com/zephyr/pkg/sysreq"
+)
+
+// MissingLibrary is a shared library an installed extension module links
+// against that the system's dynamic loader cannot find. Importing the module
+// fails until it is installed.
+type MissingLibrary struct {
+	Package string
+	// Module is the extension module, relative to site-packages
+	Module  string
+	Library string
+}
+
+func (m MissingLibrary) String() string {
+	return fmt.Sprintf("%s: %s needs %s, which is not installed (%s)", m.Package, m.Module, m.Library, LibraryHint(m.Library))
+}
+
+// libraryPackages names the system packages providing commonly missing
+// libraries, as Debian/Ubuntu and Fedora/RHEL packages, keyed by soname
+// without its version
+var libraryPackages = map[string][2]string{
+	"libGL.so":          {"libgl1", "mesa-libGL"},
+	"libEGL.so":         {"libegl1", "mesa-libEGL"},
+	"libglib-2.0.so":    {"libglib2.0-0", "glib2"},
+	"libgthread-2.0.so": {"libglib2.0-0", "glib2"},
+	"libgomp.so":        {"libgomp1", "libgomp"},
+	"libgfortran.so":    {"libgfortran5", "libgfortran"},
+	"libstdc++.so":      {"libstdc++6", "libstdc++"},
+	"libX11.so":         {"libx11-6", "libX11"},
+	"libXext.so":        {"libxext6", "libXext"},
+	"libXrender.so":     {"libxrender1", "libXrender"},
+	"libSM.so":          {"libsm6", "libSM"},
+	"libICE.so":         {"libice6", "libICE"},
+	"libxcb.so":         {"libxcb1", "libxcb"},
+	"libxkbcommon.so":   {"libxkbcommon0", "libxkbcommon"},
+	"libfontconfig.so":  {"libfontconfig1", "fontconfig"},
+	"libfreetype.so":    {"libfreetype6", "freetype"},
+	"libssl.so":         {"libssl3", "openssl-libs"},
+	"libcrypto.so":      {"libssl3", "openssl-libs"},
+	"libffi.so":         {"libffi8", "libffi"},
+	"libz.so":           {"zlib1g", "zlib"},
+	"libpq.so":          {"libpq5", "libpq"},
+	"libsndfile.so":     {"libsndfile1", "libsndfile"},
+	"libportaudio.so":   {"libportaudio2", "portaudio"},
+	"libaio.so":         {"libaio1", "libaio"},
+}
+
+// LibraryHint suggests how to install a missing shared library
+func LibraryHint(library string) string {
+	base := filepath.Base(library)
+	stem := base
+	if i := strings.Index(base, ".so"); i >= 0 {
+		stem = base[:i+len(".so")]
+	}
+	if pkgs, ok := libraryPackages[stem]; ok {
+		switch pm := sysreq.DetectPackageManager(); pm {
+		case sysreq.Apt:
+			return "install it with: " + pm.InstallCommand(pkgs[0])
+		case sysreq.Dnf:
+			return "install it with: " + pm.InstallCommand(pkgs[1])
+		}
+		return fmt.Sprintf("install it with: apt install %s, or dnf install %s", pkgs[0], pkgs[1])
+	}
+	if runtime.GOOS == "darwin" {
+		return fmt.Sprintf("install the Homebrew or MacPorts package that provides %s", base)
+	}
+	return fmt.Sprintf("install the system package that provides it; apt-file search %s or dnf provides '*/%s' will name it", base, base)
+}
+
+// unresolvedLibraries lists the libraries a binary needs that cannot be
+// found; it is replaced in tests
+var unresolvedLibraries = platformUnresolvedLibraries
+
+// MissingSharedLibraries scans the extension modules of the named
+// distributions, or of every distribution when names is empty, for shared
+// libraries the system lacks, using ldd on Linux and otool on macOS
+func (e *Environment) MissingSharedLibraries(names ...string) ([]MissingLibrary, error) {
+	dists, err := e.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[pkgname.Normalize(name)] = true
+	}
+	var missing []MissingLibrary
+	for _, dist := range dists {
+		if len(wanted) > 0 && !wanted[pkgname.Normalize(dist.Name)] {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, file := range dist.Files {
+			if !isExtensionModule(file) {
+				continue
+			}
+			libraries, err := unresolvedLibraries(filepath.Join(dist.SitePackages, filepath.FromSlash(file)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to inspect %s from %s: %w", file, dist.Name, err)
+			}
+			for _, library := range libraries {
+				// Report each library once per package, at its first module
+				if !seen[library] {
+					seen[library] = true
+					missing = append(missing, MissingLibrary{Package: dist.Name, Module: file, Library: library})
+				}
+			}
+		}
+	}
+	sort.SliceStable(missing, func(i, j int) bool { return missing[i].Package < missing[j].Package })
+	return missing, nil
+}
+
+// isExtensionModule reports whether a RECORD path is a native library
+func isExtensionModule(file string) bool {
+	base := filepath.Base(file)
+	return strings.HasSuffix(base, ".so") || strings.Contains(base, ".so.") || strings.HasSuffix(base, ".dylib")
+}
+
+// platformUnresolvedLibraries asks the platform's dynamic linker tools which
+// libraries of path cannot be found
+func platformUnresolvedLibraries(path string) ([]string, error) {
+	switch runtime.GOOS {
+	case "linux":
+		out, err := exec.Command("ldd", path).Output()
+		if err != nil && len(out) == 0 {
+			if _, lookErr := exec.LookPath("ldd"); lookErr != nil {
+				return nil, fmt.Errorf("ldd is not installed")
+			}
+			// ldd fails on files that are not dynamically linked
+			return nil, nil
+		}
+		return parseLdd(out), nil
+	case "darwin":
+		out, err := exec.Command("otool", "-L", path).Output()
+		if err != nil {
+			return nil, fmt.Errorf("otool -L failed: %w", err)
+		}
+		return parseOtool(out, filepath.Dir(path)), nil
+	}
+	return nil, fmt.Errorf("shared library scanning is not supported on %s", runtime.GOOS)
+}
+
+// parseLdd returns the libraries ldd reports as "not found"
+func parseLdd(out []byte) []string {
+	var missing []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		name, target, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=>")
+		if ok && strings.TrimSpace(target) == "not found" {
+			missing = append(missing, strings.TrimSpace(name))
+		}
+	}
+	return missing
+}
+
+// parseOtool returns the libraries listed by otool -L that do not exist.
+// System libraries live in the dyld shared cache rather than on disk, and
+// @rpath references depend on the loading binary, so both are assumed present.
+func parseOtool(out []byte, moduleDir string) []string {
+	var missing []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for first := true; scanner.Scan(); first = false {
+		line := scanner.Text()
+		// The first line names the file being inspected
+		if first || !strings.HasPrefix(line, "\t") {
+			continue
+		}
+		library, _, _ := strings.Cut(strings.TrimSpace(line), " (")
+		path := library
+		switch {
+		case strings.HasPrefix(library, "/usr/lib/"), strings.HasPrefix(library, "/System/"), strings.HasPrefix(library, "@rpath/"):
+			continue
+		case strings.HasPrefix(library, "@loader_path/"):
+			path = filepath.Join(moduleDir, strings.TrimPrefix(library, "@loader_path/"))
+		case !filepath.IsAbs(library):
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			missing = append(missing, library)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,176 @@
+package environment
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/markers"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// PackageSize is the disk space a distribution's installed files take
+type PackageSize struct {
+	Name    string
+	Version string
+	Size    int64
+	Files   int
+}
+
+// PackageSizes returns the installed size of every distribution, largest
+// first. Sizes come from RECORD, or from the file itself where RECORD has
+// none; files that no longer exist are not counted.
+func (e *Environment) PackageSizes() ([]PackageSize, error) {
+	dists, err := e.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	sizes := make([]PackageSize, 0, len(dists))
+	for _, dist := range dists {
+		sizes = append(sizes, e.packageSize(dist))
+	}
+	sort.SliceStable(sizes, func(i, j int) bool { return sizes[i].Size > sizes[j].Size })
+	return sizes, nil
+}
+
+// packageSize adds up the files in dist's RECORD
+func (e *Environment) packageSize(dist *Distribution) PackageSize {
+	result := PackageSize{Name: dist.Name, Version: dist.Version}
+	root := filepath.Clean(e.Path) + string(filepath.Separator)
+	for _, row := range dist.record {
+		path := filepath.Clean(filepath.Join(dist.SitePackages, filepath.FromSlash(row.path)))
+		if !strings.HasPrefix(path, root) {
+			continue
+		}
+		info, err := os.Lstat(path)
+		if err != nil {
+			continue
+		}
+		size, err := strconv.ParseInt(row.size, 10, 64)
+		if err != nil {
+			size = info.Size()
+		}
+		result.Size += size
+		result.Files++
+	}
+	return result
+}
+
+// Footprint returns the disk space of everything in the environment,
+// including the interpreter, bytecode caches and files no RECORD lists
+func (e *Environment) Footprint() (int64, error) {
+	var total int64
+	err := filepath.WalkDir(e.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// Chain is a root package together with everything installed because of it
+type Chain struct {
+	Root string
+	// Size is the installed size of Root and its transitive requirements
+	Size int64
+	// Path leads from Root through its requirements to the largest package
+	// it pulls in, which is Root itself when that is the largest
+	Path []string
+}
+
+// DependencyChains returns, for each root, the installed size of the root and
+// everything it requires directly or indirectly, largest first. Packages
+// shared by several roots count towards each. When roots is empty, the
+// installed packages nothing else requires are used, except pip, setuptools
+// and wheel.
+func (e *Environment) DependencyChains(roots []string) ([]Chain, error) {
+	dists, err := e.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]int64, len(dists))
+	names := make(map[string]string, len(dists))
+	graph := e.requirementGraph(dists)
+	required := make(map[string]bool)
+	for _, dist := range dists {
+		name := pkgname.Normalize(dist.Name)
+		sizes[name] = e.packageSize(dist).Size
+		names[name] = dist.Name
+		for _, dep := range graph[name] {
+			required[dep] = true
+		}
+	}
+	if len(roots) == 0 {
+		for _, dist := range dists {
+			name := pkgname.Normalize(dist.Name)
+			if !required[name] && !seedPackages[name] {
+				roots = append(roots, dist.Name)
+			}
+		}
+	}
+
+	var chains []Chain
+	for _, root := range roots {
+		start := pkgname.Normalize(root)
+		if _, ok := sizes[start]; !ok {
+			continue
+		}
+		// Walk breadth first so each package's recorded parent lies on a
+		// shortest path from the root
+		parent := map[string]string{start: ""}
+		queue := []string{start}
+		chain := Chain{Root: names[start]}
+		heaviest := start
+		for len(queue) > 0 {
+			name := queue[0]
+			queue = queue[1:]
+			chain.Size += sizes[name]
+			if sizes[name] > sizes[heaviest] {
+				heaviest = name
+			}
+			for _, dep := range graph[name] {
+				if _, seen := parent[dep]; !seen {
+					if _, installed := sizes[dep]; installed {
+						parent[dep] = name
+						queue = append(queue, dep)
+					}
+				}
+			}
+		}
+		for name := heaviest; name != ""; name = parent[name] {
+			chain.Path = append([]string{names[name]}, chain.Path...)
+		}
+		chains = append(chains, chain)
+	}
+	sort.SliceStable(chains, func(i, j int) bool { return chains[i].Size > chains[j].Size })
+	return chains, nil
+}
+
+// requirementGraph maps the normalized name of each distribution to the
+// normalized names of the requirements that apply to this environment
+func (e *Environment) requirementGraph(dists []*Distribution) map[string][]string {
+	graph := make(map[string][]string, len(dists))
+	env := e.MarkerEnvironment()
+	for _, dist := range dists {
+		name := pkgname.Normalize(dist.Name)
+		for _, requirement := range dist.Requires() {
+			spec, marker := markers.SplitRequirement(requirement)
+			if applies, err := markers.Evaluate(marker, env); err != nil || !applies {
+				continue
+			}
+			graph[name] = append(graph[name], pkgname.Normalize(markers.RequirementName(spec)))
+		}
+	}
+	return graph
+}
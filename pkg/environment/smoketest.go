@@ -0,0 +1,174 @@
+package environment
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"rimraf-adi.com/zephyr/pkg/installer"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// importTimeout bounds how long one smoke-test import may take
+const importTimeout = 60 * time.Second
+
+// ImportFailure is a top-level module that could not be imported
+type ImportFailure struct {
+	Package string
+	Module  string
+	// Error is the exception, or how the interpreter died
+	Error string
+}
+
+func (f ImportFailure) String() string {
+	return fmt.Sprintf("%s: import %s failed: %s", f.Package, f.Module, f.Error)
+}
+
+// SmokeTest is the result of importing an environment's modules
+type SmokeTest struct {
+	Failures []ImportFailure
+	// Modules counts the modules imported
+	Modules int
+}
+
+// OK reports whether every module imported
+func (s *SmokeTest) OK() bool {
+	return len(s.Failures) == 0
+}
+
+// importModule imports module with python and returns why it failed; it is
+// replaced in tests
+var importModule = pythonImportModule
+
+// SmokeTest imports each top-level module of the named distributions, or of
+// every distribution except pip, setuptools and wheel when names is empty.
+// Each import runs in its own interpreter, so a module that crashes the
+// process, as a broken platform wheel can, is reported like any other.
+func (e *Environment) SmokeTest(names ...string) (*SmokeTest, error) {
+	dists, err := e.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[pkgname.Normalize(name)] = true
+	}
+	// The imports run elsewhere, so a relative venv path would not resolve
+	python, err := filepath.Abs(installer.NewVirtualEnvironment(e.Path).GetPythonPath())
+	if err != nil {
+		return nil, err
+	}
+	// Import from an empty directory so the project's own files cannot
+	// shadow the installed modules
+	dir, err := os.MkdirTemp("", "zephyr-smoke-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	result := &SmokeTest{}
+	for _, dist := range dists {
+		name := pkgname.Normalize(dist.Name)
+		if (len(wanted) > 0 && !wanted[name]) || (len(wanted) == 0 && seedPackages[name]) {
+			continue
+		}
+		for _, module := range dist.TopLevelModules() {
+			result.Modules++
+			if err := importModule(python, dir, module); err != nil {
+				result.Failures = append(result.Failures, ImportFailure{Package: dist.Name, Module: module, Error: err.Error()})
+			}
+		}
+	}
+	return result, nil
+}
+
+// TopLevelModules returns the importable top-level modules and packages the
+// distribution installed, from its RECORD. For namespace packages, which
+// have no __init__.py, the regular packages inside them are returned
+// instead, such as google.protobuf.
+func (d *Distribution) TopLevelModules() []string {
+	files := make(map[string]bool, len(d.Files))
+	for _, file := range d.Files {
+		files[file] = true
+	}
+	seen := make(map[string]bool)
+	var modules []string
+	for _, file := range d.Files {
+		parts := strings.Split(file, "/")
+		module := ""
+		switch {
+		case len(parts) == 1:
+			module = moduleName(parts[0])
+		case !isIdentifier(parts[0]) || parts[0] == "__pycache__":
+		case files[parts[0]+"/__init__.py"]:
+			module = parts[0]
+		case len(parts) > 2 && isIdentifier(parts[1]) && files[parts[0]+"/"+parts[1]+"/__init__.py"]:
+			module = parts[0] + "." + parts[1]
+		}
+		if module != "" && !seen[module] {
+			seen[module] = true
+			modules = append(modules, module)
+		}
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// moduleName returns the module a top-level file defines, or "" if it is
+// not a module: foo.py and foo.cpython-311-x86_64-linux-gnu.so both define foo
+func moduleName(file string) string {
+	stem, ext, ok := strings.Cut(file, ".")
+	if !ok || !isIdentifier(stem) {
+		return ""
+	}
+	if ext == "py" || ext == "so" || ext == "pyd" || strings.HasSuffix(ext, ".so") || strings.HasSuffix(ext, ".pyd") {
+		return stem
+	}
+	return ""
+}
+
+// isIdentifier reports whether s is an ASCII Python identifier
+func isIdentifier(s string) bool {
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		return false
+	}
+	for _, c := range s {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// pythonImportModule imports module in a fresh python process run in dir,
+// returning the exception's last line or how the process died
+func pythonImportModule(python, dir, module string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, python, "-c", "import importlib, sys; importlib.import_module(sys.argv[1])", module)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("timed out after %s", importTimeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+			return errors.New(last)
+		}
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package environment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/markers"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// TreeEntry is an installed distribution with the requirements that apply to
+// this environment, in the order its metadata lists them
+type TreeEntry struct {
+	Distribution *Distribution
+	Requirements []TreeRequirement
+}
+
+// TreeRequirement is one requirement of an installed distribution and the
+// distribution satisfying it
+type TreeRequirement struct {
+	// Name is the requirement's name as written
+	Name string
+	// Specifier is the version specifier, or "" when any version will do
+	Specifier string
+	// Installed is the distribution installed for it, or nil when missing
+	Installed *Distribution
+}
+
+// Tree returns every installed distribution with its requirements, sorted by
+// normalized name
+func (e *Environment) Tree() ([]TreeEntry, error) {
+	dists, err := e.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*Distribution, len(dists))
+	for _, dist := range dists {
+		byName[pkgname.Normalize(dist.Name)] = dist
+	}
+	env := e.MarkerEnvironment()
+	entries := make([]TreeEntry, 0, len(dists))
+	for _, dist := range dists {
+		entry := TreeEntry{Distribution: dist}
+		for _, requirement := range dist.Requires() {
+			spec, marker := markers.SplitRequirement(requirement)
+			applies, err := markers.Evaluate(marker, env)
+			if err != nil {
+				return nil, fmt.Errorf("%s requirement '%s': %w", dist.Name, requirement, err)
+			}
+			if !applies {
+				continue
+			}
+			name := markers.RequirementName(spec)
+			entry.Requirements = append(entry.Requirements, TreeRequirement{
+				Name:      name,
+				Specifier: strings.ReplaceAll(requirementSpecifier(spec), " ", ""),
+				Installed: byName[pkgname.Normalize(name)],
+			})
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return pkgname.Normalize(entries[i].Distribution.Name) < pkgname.Normalize(entries[j].Distribution.Name)
+	})
+	return entries, nil
+}
+
+// PipdeptreePackage is one element of the list pipdeptree --json prints
+type PipdeptreePackage struct {
+	Package      PipdeptreeNode         `json:"package"`
+	Dependencies []PipdeptreeDependency `json:"dependencies"`
+}
+
+// PipdeptreeNode identifies an installed package in pipdeptree's schema
+type PipdeptreeNode struct {
+	Key              string `json:"key"`
+	PackageName      string `json:"package_name"`
+	InstalledVersion string `json:"installed_version"`
+}
+
+// PipdeptreeDependency is a requirement in pipdeptree's schema
+type PipdeptreeDependency struct {
+	Key              string `json:"key"`
+	PackageName      string `json:"package_name"`
+	InstalledVersion string `json:"installed_version"`
+	RequiredVersion  string `json:"required_version"`
+}
+
+// Pipdeptree converts a Tree to the flat list pipdeptree --json prints, so
+// scripts written for pipdeptree can read it. As there, a requirement without
+// a specifier has required_version "Any", and a missing one installed_version "?".
+func Pipdeptree(entries []TreeEntry) []PipdeptreePackage {
+	packages := make([]PipdeptreePackage, 0, len(entries))
+	for _, entry := range entries {
+		pkg := PipdeptreePackage{
+			Package: PipdeptreeNode{
+				Key:              pkgname.Normalize(entry.Distribution.Name),
+				PackageName:      entry.Distribution.Name,
+				InstalledVersion: entry.Distribution.Version,
+			},
+			Dependencies: []PipdeptreeDependency{},
+		}
+		for _, requirement := range entry.Requirements {
+			dependency := PipdeptreeDependency{
+				Key:              pkgname.Normalize(requirement.Name),
+				PackageName:      requirement.Name,
+				InstalledVersion: "?",
+				RequiredVersion:  requirement.Specifier,
+			}
+			if requirement.Installed != nil {
+				dependency.PackageName = requirement.Installed.Name
+				dependency.InstalledVersion = requirement.Installed.Version
+			}
+			if dependency.RequiredVersion == "" {
+				dependency.RequiredVersion = "Any"
+			}
+			pkg.Dependencies = append(pkg.Dependencies, dependency)
+		}
+		packages = append(packages, pkg)
+	}
+	return packages
+}
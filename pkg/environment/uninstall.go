@@ -0,0 +1,115 @@
+package environment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/installer"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// Uninstall removes a distribution's files, its .dist-info directory and the
+// console scripts it owns, then prunes directories left empty. It returns the
+// removed paths.
+func (e *Environment) Uninstall(name string) ([]string, error) {
+	dist, err := e.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return e.remove(dist, nil)
+}
+
+// remove uninstalls dist, leaving the files and console scripts that keep,
+// another installation of the same project, also owns
+func (e *Environment) remove(dist, keep *Distribution) ([]string, error) {
+	kept := make(map[string]bool)
+	if keep != nil {
+		for _, file := range keep.Files {
+			kept[filepath.Clean(filepath.Join(keep.SitePackages, filepath.FromSlash(file)))] = true
+		}
+		for _, script := range keep.Scripts() {
+			kept[filepath.Join(e.binPath(), script)] = true
+		}
+	}
+	var removed []string
+	dirs := make(map[string]bool)
+	for _, file := range dist.Files {
+		path := filepath.Clean(filepath.Join(dist.SitePackages, filepath.FromSlash(file)))
+		if !strings.HasPrefix(path, filepath.Clean(e.Path)+string(filepath.Separator)) {
+			// Never follow RECORD entries out of the environment
+			continue
+		}
+		if kept[path] {
+			continue
+		}
+		if err := os.Remove(path); err == nil {
+			removed = append(removed, path)
+			dirs[filepath.Dir(path)] = true
+		} else if !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		if strings.HasSuffix(path, ".py") {
+			// Drop bytecode compiled from the module
+			caches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "__pycache__", strings.TrimSuffix(filepath.Base(path), ".py")+".*.pyc"))
+			for _, pyc := range caches {
+				if os.Remove(pyc) == nil {
+					dirs[filepath.Dir(pyc)] = true
+				}
+			}
+		}
+	}
+	for _, script := range dist.Scripts() {
+		path := filepath.Join(e.binPath(), script)
+		data, err := os.ReadFile(path)
+		if err != nil || kept[path] {
+			continue
+		}
+		// Leave launchers another package took over
+		if owner := launcherOwner(string(data)); owner != "" && pkgname.Normalize(owner) != pkgname.Normalize(dist.Name) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		removed = append(removed, path)
+	}
+	if err := os.RemoveAll(dist.DistInfo); err != nil {
+		return removed, fmt.Errorf("failed to remove %s: %w", dist.DistInfo, err)
+	}
+	removed = append(removed, dist.DistInfo)
+	pruneEmptyDirs(dirs, dist.SitePackages)
+	return removed, nil
+}
+
+// launcherOwner returns the distribution recorded in a zephyr launcher, or ""
+func launcherOwner(content string) string {
+	for _, line := range strings.SplitN(content, "\n", 5) {
+		if strings.HasPrefix(line, installer.ScriptOwnerPrefix) {
+			return strings.TrimSpace(strings.TrimPrefix(line, installer.ScriptOwnerPrefix))
+		}
+	}
+	return ""
+}
+
+// pruneEmptyDirs removes the given directories and their parents, up to but
+// not including stop, while they are empty
+func pruneEmptyDirs(dirs map[string]bool, stop string) {
+	list := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		list = append(list, dir)
+	}
+	// Deepest first so parents are empty by the time they are visited
+	sort.Slice(list, func(i, j int) bool { return len(list[i]) > len(list[j]) })
+	stop = filepath.Clean(stop)
+	for _, dir := range list {
+		for dir != stop && strings.HasPrefix(dir, stop) {
+			if os.Remove(dir) != nil {
+				break
+			}
+			dir = filepath.Dir(dir)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package environment
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/installer"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// recordRow is one RECORD line: a path relative to site-packages and, when
+// the installer recorded them, its hash and size
+type recordRow struct {
+	path string
+	hash string
+	size string
+}
+
+// seedPackages are installed by `python -m venv` itself and allowed in a
+// verified environment without being locked
+var seedPackages = map[string]bool{"pip": true, "setuptools": true, "wheel": true}
+
+// Mismatch is one way an environment differs from its lockfile
+type Mismatch struct {
+	Package string
+	// Path is the installed file that differs, or "" for the package as a whole
+	Path    string
+	Problem string
+}
+
+func (m Mismatch) String() string {
+	if m.Path == "" {
+		return fmt.Sprintf("%s: %s", m.Package, m.Problem)
+	}
+	return fmt.Sprintf("%s: %s %s", m.Package, m.Path, m.Problem)
+}
+
+// Verification is the result of comparing an environment with a lockfile
+type Verification struct {
+	Mismatches []Mismatch
+	// Packages and Files count what was checked
+	Packages int
+	Files    int
+}
+
+// OK reports whether the environment matches the lockfile exactly
+func (v *Verification) OK() bool {
+	return len(v.Mismatches) == 0
+}
+
+// Verify compares the environment with lf without changing either. Every
+// locked package must be installed at its locked version, no unlocked
+// package may be installed, and every file listed in each RECORD must still
+// have its recorded size and hash.
+func (e *Environment) Verify(lf *installer.Lockfile) (*Verification, error) {
+	dists, err := e.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	result := &Verification{}
+	installed := make(map[string]*Distribution, len(dists))
+	for _, dist := range dists {
+		installed[pkgname.Normalize(dist.Name)] = dist
+	}
+
+	names := make([]string, 0, len(lf.Packages))
+	for name := range lf.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	locked := make(map[string]bool, len(names))
+	for _, name := range names {
+		pkg := lf.Packages[name]
+		locked[pkgname.Normalize(name)] = true
+		dist, ok := installed[pkgname.Normalize(name)]
+		if !ok {
+			result.Mismatches = append(result.Mismatches, Mismatch{Package: name, Problem: fmt.Sprintf("locked at %s but not installed", pkg.Version)})
+			continue
+		}
+		if dist.Version != pkg.Version {
+			result.Mismatches = append(result.Mismatches, Mismatch{Package: name, Problem: fmt.Sprintf("locked at %s but %s is installed", pkg.Version, dist.Version)})
+		}
+		result.Packages++
+		files, mismatches, err := e.verifyRecord(dist)
+		if err != nil {
+			return nil, err
+		}
+		result.Files += files
+		result.Mismatches = append(result.Mismatches, mismatches...)
+	}
+
+	for _, dist := range dists {
+		name := pkgname.Normalize(dist.Name)
+		if !locked[name] && !seedPackages[name] {
+			result.Mismatches = append(result.Mismatches, Mismatch{Package: dist.Name, Problem: fmt.Sprintf("%s is installed but not in the lockfile", dist.Version)})
+		}
+	}
+	return result, nil
+}
+
+// verifyRecord checks the files of dist against its RECORD and returns how
+// many were hashed
+func (e *Environment) verifyRecord(dist *Distribution) (int, []Mismatch, error) {
+	var mismatches []Mismatch
+	if len(dist.record) == 0 {
+		return 0, []Mismatch{{Package: dist.Name, Problem: "has no RECORD, so its files cannot be verified"}}, nil
+	}
+	root := filepath.Clean(e.Path) + string(filepath.Separator)
+	checked, unhashed := 0, 0
+	for _, row := range dist.record {
+		if row.hash == "" {
+			// RECORD cannot hash itself, and bytecode is written after install
+			if !strings.HasSuffix(row.path, ".dist-info/RECORD") && !strings.HasSuffix(row.path, ".pyc") {
+				unhashed++
+			}
+			continue
+		}
+		path := filepath.Clean(filepath.Join(dist.SitePackages, filepath.FromSlash(row.path)))
+		if !strings.HasPrefix(path, root) {
+			mismatches = append(mismatches, Mismatch{Package: dist.Name, Path: row.path, Problem: "points outside the environment"})
+			continue
+		}
+		algorithm, want, ok := strings.Cut(row.hash, "=")
+		newHash := recordHashes[algorithm]
+		if !ok || newHash == nil {
+			mismatches = append(mismatches, Mismatch{Package: dist.Name, Path: row.path, Problem: fmt.Sprintf("has unsupported RECORD hash %q", row.hash)})
+			continue
+		}
+		got, size, err := hashFile(path, newHash())
+		if os.IsNotExist(err) {
+			mismatches = append(mismatches, Mismatch{Package: dist.Name, Path: row.path, Problem: "is missing"})
+			continue
+		}
+		if err != nil {
+			return checked, nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		checked++
+		if wantSize, err := strconv.ParseInt(row.size, 10, 64); err == nil && wantSize != size {
+			mismatches = append(mismatches, Mismatch{Package: dist.Name, Path: row.path, Problem: fmt.Sprintf("is %d bytes, RECORD says %d", size, wantSize)})
+		} else if got != want {
+			mismatches = append(mismatches, Mismatch{Package: dist.Name, Path: row.path, Problem: "was modified: its hash does not match RECORD"})
+		}
+	}
+	if unhashed > 0 {
+		mismatches = append(mismatches, Mismatch{Package: dist.Name, Problem: fmt.Sprintf("RECORD lists %d files without hashes; reinstall it with zephyr sync so they can be verified", unhashed)})
+	}
+	return checked, mismatches, nil
+}
+
+// recordHashes are the RECORD hash algorithms verification understands
+var recordHashes = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// hashFile returns the unpadded urlsafe base64 digest and size of a file
+func hashFile(path string, h hash.Hash) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)), size, nil
+}
@@ -0,0 +1,115 @@
+// Package flock provides advisory file locks that serialize zephyr processes
+// working on the same project, cache or virtual environment
+package flock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is how long Acquire waits for another process to release a lock
+var DefaultTimeout = 5 * time.Minute
+
+// pollInterval is how often a busy lock is retried
+const pollInterval = 100 * time.Millisecond
+
+// errBusy is returned by tryLock when another process holds the lock
+var errBusy = errors.New("lock is held by another process")
+
+// Lock is an acquired advisory lock
+type Lock struct {
+	path string
+	file *os.File
+}
+
+// BusyError reports a lock that could not be acquired before the timeout
+type BusyError struct {
+	Path    string
+	Holder  string
+	Timeout time.Duration
+}
+
+func (e *BusyError) Error() string {
+	holder := ""
+	if e.Holder != "" {
+		holder = " (" + e.Holder + ")"
+	}
+	return fmt.Sprintf("another zephyr process is running%s and holds %s; gave up after %s. Wait for it to finish or raise --lock-timeout", holder, e.Path, e.Timeout)
+}
+
+// Acquire locks path, waiting up to DefaultTimeout for other processes
+func Acquire(path string) (*Lock, error) {
+	return AcquireTimeout(path, DefaultTimeout)
+}
+
+// AcquireTimeout locks path, waiting up to timeout for other processes to
+// release it. A zero timeout fails immediately when the lock is busy. The lock
+// file, readable only by its owner, records the holder's pid for the message
+// other processes print while they wait.
+func AcquireTimeout(path string, timeout time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %s: %w", path, err)
+	}
+	deadline := time.Now().Add(timeout)
+	waiting := false
+	for {
+		err := tryLock(f)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errBusy) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		holder := readHolder(path)
+		if !time.Now().Before(deadline) {
+			f.Close()
+			return nil, &BusyError{Path: path, Holder: holder, Timeout: timeout}
+		}
+		if !waiting {
+			waiting = true
+			if holder != "" {
+				holder = " (" + holder + ")"
+			}
+			fmt.Fprintf(os.Stderr, "[zephyr] Waiting for another zephyr process%s to release %s...\n", holder, path)
+		}
+		time.Sleep(pollInterval)
+	}
+	f.Truncate(0)
+	f.WriteAt([]byte(fmt.Sprintf("pid %d\n", os.Getpid())), 0)
+	return &Lock{path: path, file: f}, nil
+}
+
+// Release unlocks and closes the lock file
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	l.file.Truncate(0)
+	err := unlock(l.file)
+	l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Path returns the lock file location
+func (l *Lock) Path() string {
+	return l.path
+}
+
+// readHolder returns the pid the current holder wrote to the lock file
+func readHolder(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package flock
+
+import "os"
+
+// tryLock always succeeds; this platform has no advisory file locks
+func tryLock(f *os.File) error {
+	return nil
+}
+
+// unlock is a no-op on platforms without advisory file locks
+func unlock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,87 @@
+//go:build unix
+
+package flock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "project.lock")
+	lock, err := AcquireTimeout(path, 0)
+	if err != nil {
+		t.Fatalf("AcquireTimeout failed: %v", err)
+	}
+	if lock.Path() != path {
+		t.Errorf("Path() = %q, want %q", lock.Path(), path)
+	}
+	if holder := readHolder(path); holder != fmt.Sprintf("pid %d", os.Getpid()) {
+		t.Errorf("holder = %q, want the current pid", holder)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("lock file mode = %v, want 0600", info.Mode().Perm())
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if holder := readHolder(path); holder != "" {
+		t.Errorf("holder after release = %q, want empty", holder)
+	}
+
+	again, err := AcquireTimeout(path, 0)
+	if err != nil {
+		t.Fatalf("re-acquiring a released lock failed: %v", err)
+	}
+	again.Release()
+}
+
+func TestAcquireTimeout_Busy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "venv.lock")
+	held, err := AcquireTimeout(path, 0)
+	if err != nil {
+		t.Fatalf("AcquireTimeout failed: %v", err)
+	}
+	defer held.Release()
+
+	start := time.Now()
+	_, err = AcquireTimeout(path, 250*time.Millisecond)
+	var busy *BusyError
+	if !errors.As(err, &busy) {
+		t.Fatalf("expected BusyError, got %v", err)
+	}
+	if time.Since(start) < 250*time.Millisecond {
+		t.Errorf("gave up after %s, before the timeout", time.Since(start))
+	}
+	if !strings.Contains(busy.Error(), "another zephyr process is running") {
+		t.Errorf("unexpected message: %v", busy)
+	}
+	if !strings.Contains(busy.Holder, fmt.Sprintf("pid %d", os.Getpid())) {
+		t.Errorf("Holder = %q, want the holding pid", busy.Holder)
+	}
+}
+
+func TestAcquire_WaitsForRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.lock")
+	held, err := AcquireTimeout(path, 0)
+	if err != nil {
+		t.Fatalf("AcquireTimeout failed: %v", err)
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		held.Release()
+	}()
+
+	lock, err := AcquireTimeout(path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("expected the lock once released, got %v", err)
+	}
+	lock.Release()
+}
@@ -0,0 +1,104 @@
+package fsutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FS is the filesystem the installer, lockfile manager and manifest parser
+// read and write through. OS is the real one; MemFS keeps files in memory
+// so unit tests never touch the disk, and other targets can be plugged in.
+// Paths are OS paths, as accepted by the os package.
+type FS interface {
+	// Open opens a file for reading
+	Open(name string) (fs.File, error)
+	// Create creates or truncates a file for writing; its directory must exist
+	Create(name string) (io.WriteCloser, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	// WriteFileAtomic replaces a file so readers see the old or new contents,
+	// never a mix, as the package-level WriteFileAtomic does on disk
+	WriteFileAtomic(name string, data []byte, perm fs.FileMode) error
+	Stat(name string) (fs.FileInfo, error)
+	MkdirAll(path string, perm fs.FileMode) error
+	Chmod(name string, mode fs.FileMode) error
+	Remove(name string) error
+	RemoveAll(path string) error
+	// Glob returns the paths matching a filepath.Match pattern, sorted
+	Glob(pattern string) ([]string, error)
+}
+
+// OS is the real filesystem
+var OS FS = osFS{}
+
+// osFS implements FS with the os package
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) { return os.Open(name) }
+
+func (osFS) Create(name string) (io.WriteCloser, error) { return os.Create(name) }
+
+func (osFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (osFS) WriteFileAtomic(name string, data []byte, perm fs.FileMode) error {
+	return WriteFileAtomic(name, data, perm)
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+func (osFS) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+
+func (osFS) Chmod(name string, mode fs.FileMode) error { return os.Chmod(name, mode) }
+
+func (osFS) Remove(name string) error { return os.Remove(name) }
+
+func (osFS) RemoveAll(path string) error { return os.RemoveAll(path) }
+
+func (osFS) Glob(pattern string) ([]string, error) { return filepath.Glob(pattern) }
+
+// Or returns fsys, or OS when fsys is nil, so zero-valued structs use the disk
+func Or(fsys FS) FS {
+	if fsys == nil {
+		return OS
+	}
+	return fsys
+}
+
+// OpenZip opens the zip archive name in fsys. Close the returned closer when
+// done with the reader.
+func OpenZip(fsys FS, name string) (*zip.Reader, io.Closer, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	readerAt, ok := f.(io.ReaderAt)
+	if !ok {
+		// Archives need random access; read ones that do not offer it into memory
+		data, err := io.ReadAll(f)
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		readerAt = bytes.NewReader(data)
+	}
+	reader, err := zip.NewReader(readerAt, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return reader, f, nil
+}
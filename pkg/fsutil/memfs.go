@@ -0,0 +1,248 @@
+package fsutil
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemFS is an FS held in memory. Directories are created implicitly by
+// MkdirAll only, as on disk, so code that forgets to create one still fails.
+type MemFS struct {
+	mu    sync.Mutex
+	files map[string]*memNode
+}
+
+// memNode is one file or directory of a MemFS
+type memNode struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// NewMemFS returns an empty in-memory filesystem
+func NewMemFS() *MemFS {
+	return &MemFS{files: make(map[string]*memNode)}
+}
+
+// clean makes name the key a node is stored under
+func (m *MemFS) clean(name string) string {
+	return filepath.Clean(name)
+}
+
+// dirExists reports whether dir is the root or a directory; m.mu must be held
+func (m *MemFS) dirExists(dir string) bool {
+	if dir == "." || dir == string(filepath.Separator) || filepath.Dir(dir) == dir {
+		return true
+	}
+	node, ok := m.files[dir]
+	return ok && node.mode.IsDir()
+}
+
+// Open implements FS
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := m.clean(name)
+	node, ok := m.files[key]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	info := memInfo{name: filepath.Base(key), node: *node}
+	return &memFile{Reader: bytes.NewReader(append([]byte(nil), node.data...)), info: info}, nil
+}
+
+// Create implements FS; the file's contents are stored when it is closed
+func (m *MemFS) Create(name string) (io.WriteCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := m.clean(name)
+	if !m.dirExists(filepath.Dir(key)) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if node, ok := m.files[key]; ok && node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+	m.files[key] = &memNode{mode: 0644, modTime: time.Now()}
+	return &memWriter{fs: m, key: key}, nil
+}
+
+// ReadFile implements FS
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, ok := m.files[m.clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	return append([]byte(nil), node.data...), nil
+}
+
+// WriteFile implements FS
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := m.clean(name)
+	if !m.dirExists(filepath.Dir(key)) {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if node, ok := m.files[key]; ok {
+		if node.mode.IsDir() {
+			return &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+		}
+		// As on disk, rewriting a file keeps its permissions
+		perm = node.mode.Perm()
+	}
+	m.files[key] = &memNode{data: append([]byte(nil), data...), mode: perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+// WriteFileAtomic implements FS; every MemFS write is atomic
+func (m *MemFS) WriteFileAtomic(name string, data []byte, perm fs.FileMode) error {
+	return m.WriteFile(name, data, perm)
+}
+
+// Stat implements FS
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := m.clean(name)
+	if filepath.Dir(key) == key {
+		return memInfo{name: key, node: memNode{mode: fs.ModeDir | 0755}}, nil
+	}
+	node, ok := m.files[key]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memInfo{name: filepath.Base(key), node: *node}, nil
+}
+
+// MkdirAll implements FS
+func (m *MemFS) MkdirAll(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for dir := m.clean(path); !m.dirExists(dir); dir = filepath.Dir(dir) {
+		if _, ok := m.files[dir]; ok {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: fs.ErrExist}
+		}
+		m.files[dir] = &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	}
+	return nil
+}
+
+// Chmod implements FS
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, ok := m.files[m.clean(name)]
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+	}
+	node.mode = node.mode&fs.ModeType | mode.Perm()
+	return nil
+}
+
+// Remove implements FS; directories must be empty
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := m.clean(name)
+	node, ok := m.files[key]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if node.mode.IsDir() {
+		prefix := key + string(filepath.Separator)
+		for other := range m.files {
+			if strings.HasPrefix(other, prefix) {
+				return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+			}
+		}
+	}
+	delete(m.files, key)
+	return nil
+}
+
+// RemoveAll implements FS
+func (m *MemFS) RemoveAll(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := m.clean(path)
+	prefix := key + string(filepath.Separator)
+	for other := range m.files {
+		if other == key || strings.HasPrefix(other, prefix) {
+			delete(m.files, other)
+		}
+	}
+	return nil
+}
+
+// Glob implements FS
+func (m *MemFS) Glob(pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var matches []string
+	for name := range m.files {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// memInfo implements fs.FileInfo for a MemFS node
+type memInfo struct {
+	name string
+	node memNode
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return int64(len(i.node.data)) }
+func (i memInfo) Mode() fs.FileMode  { return i.node.mode }
+func (i memInfo) ModTime() time.Time { return i.node.modTime }
+func (i memInfo) IsDir() bool        { return i.node.mode.IsDir() }
+func (i memInfo) Sys() interface{}   { return nil }
+
+// memFile is an open MemFS file; it also implements io.ReaderAt and
+// io.Seeker, so archives can be read from it
+type memFile struct {
+	*bytes.Reader
+	info memInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *memFile) Close() error { return nil }
+
+// memWriter buffers a file created in a MemFS until it is closed
+type memWriter struct {
+	fs  *MemFS
+	key string
+	buf bytes.Buffer
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *memWriter) Close() error {
+	w.fs.mu.Lock()
+	defer w.fs.mu.Unlock()
+	if node, ok := w.fs.files[w.key]; ok {
+		node.data = append([]byte(nil), w.buf.Bytes()...)
+		node.modTime = time.Now()
+	}
+	return nil
+}
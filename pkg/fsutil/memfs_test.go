@@ -0,0 +1,54 @@
+package fsutil
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemFS(t *testing.T) {
+	m := NewMemFS()
+	root := filepath.Join(string(filepath.Separator), "venv")
+	file := filepath.Join(root, "lib", "a.txt")
+	if err := m.WriteFile(file, []byte("a"), 0644); !os.IsNotExist(err) {
+		t.Errorf("Expected writing into a missing directory to fail with not-exist, got %v", err)
+	}
+	if err := m.MkdirAll(filepath.Join(root, "lib"), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	w, err := m.Create(file)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	io.WriteString(w, "hello")
+	w.Close()
+	if err := m.Chmod(file, 0755); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	info, err := m.Stat(file)
+	if err != nil || info.Size() != 5 || info.Mode().Perm() != 0755 || info.IsDir() {
+		t.Errorf("Stat mismatch: %v %v", info, err)
+	}
+	if info, err := m.Stat(filepath.Join(root, "lib")); err != nil || !info.IsDir() {
+		t.Errorf("Expected lib to be a directory: %v %v", info, err)
+	}
+	matches, _ := m.Glob(filepath.Join(root, "*", "*.txt"))
+	if len(matches) != 1 || matches[0] != file {
+		t.Errorf("Glob mismatch: %v", matches)
+	}
+	reader, closer, err := OpenZip(m, file)
+	if err == nil {
+		closer.Close()
+		t.Errorf("Expected OpenZip to reject a text file, got %v", reader)
+	}
+	if err := m.Remove(filepath.Join(root, "lib")); err == nil {
+		t.Error("Expected removing a non-empty directory to fail")
+	}
+	if err := m.RemoveAll(root); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if _, err := m.ReadFile(file); !os.IsNotExist(err) {
+		t.Errorf("Expected the file to be gone, got %v", err)
+	}
+}
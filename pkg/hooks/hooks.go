@@ -0,0 +1,124 @@
+// Package hooks installs git hooks that keep zephyr projects consistent
+package hooks
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Marker identifies hook scripts written by zephyr so they can be replaced safely
+const Marker = "# Installed by zephyr hooks install"
+
+// SupportedHooks are the git hooks zephyr knows how to write
+var SupportedHooks = []string{"pre-commit", "pre-push"}
+
+// Options controls the generated hook script
+type Options struct {
+	// ProjectDir is the project directory relative to the repository root
+	ProjectDir string
+	// Tasks are buildmeta scripts run after the lockfile check
+	Tasks []string
+}
+
+// Script renders the shell script for a hook
+func Script(opts Options) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString(Marker + "\n")
+	b.WriteString("set -e\n")
+	b.WriteString(`cd "$(git rev-parse --show-toplevel)"` + "\n")
+	if dir := filepath.ToSlash(opts.ProjectDir); dir != "" && dir != "." {
+		fmt.Fprintf(&b, "cd %s\n", shellQuote(dir))
+	}
+	b.WriteString(`ZEPHYR="${ZEPHYR:-zephyr}"` + "\n")
+	b.WriteString(`"$ZEPHYR" lock --check` + "\n")
+	for _, task := range opts.Tasks {
+		fmt.Fprintf(&b, "\"$ZEPHYR\" run %s\n", shellQuote(task))
+	}
+	return b.String()
+}
+
+// Dir returns the hooks directory of the git repository containing dir,
+// honouring core.hooksPath and linked worktrees
+func Dir(dir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--git-path", "hooks")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate git hooks directory (is %s inside a git repository?): %w", dir, err)
+	}
+	hooksDir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(dir, hooksDir)
+	}
+	return hooksDir, nil
+}
+
+// RepoRoot returns the top-level directory of the git repository containing dir
+func RepoRoot(dir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate git repository root: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Install writes a hook script into hooksDir. An existing hook that was not
+// written by zephyr is only replaced when force is set.
+func Install(hooksDir, name, script string, force bool) error {
+	if !isSupported(name) {
+		return fmt.Errorf("unsupported hook %q (expected one of %s)", name, strings.Join(SupportedHooks, ", "))
+	}
+	path := filepath.Join(hooksDir, name)
+	if existing, err := os.ReadFile(path); err == nil && !force && !bytes.Contains(existing, []byte(Marker)) {
+		return fmt.Errorf("%s already exists and was not written by zephyr; use --force to replace it", path)
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		return fmt.Errorf("failed to write hook %s: %w", path, err)
+	}
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(path, 0755)
+}
+
+// Uninstall removes a hook previously written by zephyr. Hooks written by
+// other tools are left alone. It reports whether a hook was removed.
+func Uninstall(hooksDir, name string) (bool, error) {
+	path := filepath.Join(hooksDir, name)
+	existing, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read hook %s: %w", path, err)
+	}
+	if !bytes.Contains(existing, []byte(Marker)) {
+		return false, nil
+	}
+	if err := os.Remove(path); err != nil {
+		return false, fmt.Errorf("failed to remove hook %s: %w", path, err)
+	}
+	return true, nil
+}
+
+func isSupported(name string) bool {
+	for _, hook := range SupportedHooks {
+		if hook == name {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote single-quotes s for /bin/sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,89 @@
+package hooks
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScript(t *testing.T) {
+	script := Script(Options{ProjectDir: "services/api", Tasks: []string{"lint", "type check"}})
+	for _, want := range []string{
+		"#!/bin/sh\n",
+		Marker,
+		"cd 'services/api'\n",
+		`"$ZEPHYR" lock --check`,
+		`"$ZEPHYR" run 'lint'`,
+		`"$ZEPHYR" run 'type check'`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Contains(Script(Options{ProjectDir: "."}), "cd '.'") {
+		t.Error("script should not cd into the repository root twice")
+	}
+}
+
+func TestInstallAndUninstall(t *testing.T) {
+	dir := t.TempDir()
+	if err := Install(dir, "pre-commit", Script(Options{}), false); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "pre-commit"))
+	if err != nil || info.Mode().Perm()&0111 == 0 {
+		t.Fatalf("hook should be executable: %v %v", info, err)
+	}
+	// Reinstalling over our own hook is allowed
+	if err := Install(dir, "pre-commit", Script(Options{Tasks: []string{"lint"}}), false); err != nil {
+		t.Errorf("reinstall failed: %v", err)
+	}
+	if err := Install(dir, "post-merge", Script(Options{}), false); err == nil {
+		t.Error("Install should reject unsupported hooks")
+	}
+
+	foreign := filepath.Join(dir, "pre-push")
+	if err := os.WriteFile(foreign, []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(dir, "pre-push", Script(Options{}), false); err == nil {
+		t.Error("Install should refuse to replace a foreign hook")
+	}
+	if removed, err := Uninstall(dir, "pre-push"); err != nil || removed {
+		t.Errorf("Uninstall should leave foreign hooks alone: %v %v", removed, err)
+	}
+	if err := Install(dir, "pre-push", Script(Options{}), true); err != nil {
+		t.Errorf("forced install failed: %v", err)
+	}
+	if removed, err := Uninstall(dir, "pre-commit"); err != nil || !removed {
+		t.Errorf("Uninstall failed: %v %v", removed, err)
+	}
+}
+
+func TestDir(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, out)
+	}
+	sub := filepath.Join(repo, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	hooksDir, err := Dir(sub)
+	if err != nil {
+		t.Fatalf("Dir failed: %v", err)
+	}
+	expected, _ := filepath.EvalSymlinks(filepath.Join(repo, ".git", "hooks"))
+	actual, _ := filepath.EvalSymlinks(hooksDir)
+	if actual != expected {
+		t.Errorf("Dir = %s, expected %s", actual, expected)
+	}
+	if _, err := Dir(t.TempDir()); err == nil {
+		t.Error("Dir should fail outside a git repository")
+	}
+}
@@ -0,0 +1,141 @@
+package installer
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/netutil"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+	"rimraf-adi.com/zephyr/pkg/pypi"
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// ratioExemptSize is the uncompressed size below which members are not held
+// to max_compression_ratio, since small files of padding compress very well
+const ratioExemptSize = 1 << 20
+
+// UnsafeMemberError is returned for a wheel member that could be written
+// outside the directory the wheel is installed into
+type UnsafeMemberError struct {
+	Member string
+	Reason string
+}
+
+// Error implements the error interface
+func (e *UnsafeMemberError) Error() string {
+	return fmt.Sprintf("security error: refusing to install wheel member %q: %s", e.Member, e.Reason)
+}
+
+// projectName matches a PEP 508 project name
+var projectName = regexp.MustCompile(`(?i)^([a-z0-9]|[a-z0-9][a-z0-9._-]*[a-z0-9])$`)
+
+// versionChars are the characters a PEP 440 version may be spelled with
+var versionChars = regexp.MustCompile(`^[A-Za-z0-9!+._-]+$`)
+
+// UnsafeMetadataError is returned for a wheel whose METADATA names a
+// project or version that cannot safely become part of an installed path
+type UnsafeMetadataError struct {
+	Field  string
+	Value  string
+	Reason string
+}
+
+// Error implements the error interface
+func (e *UnsafeMetadataError) Error() string {
+	return fmt.Sprintf("security error: refusing to install wheel with METADATA %s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+// checkDistribution rejects a METADATA Name that is not a PEP 508 project
+// name and a Version that is not a PEP 440 version. Both are joined into the
+// dist-info and headers paths, so neither may carry separators, ".." or NUL.
+func checkDistribution(name, ver string) error {
+	if !projectName.MatchString(name) || strings.Contains(name, "..") {
+		return &UnsafeMetadataError{Field: "Name", Value: name, Reason: "not a valid project name"}
+	}
+	if !versionChars.MatchString(ver) || strings.Contains(ver, "..") {
+		return &UnsafeMetadataError{Field: "Version", Value: ver, Reason: "not a valid version"}
+	}
+	if _, err := version.Parse(ver); err != nil {
+		return &UnsafeMetadataError{Field: "Version", Value: ver, Reason: "not a valid version"}
+	}
+	return nil
+}
+
+// checkWheelFilename rejects a wheel whose METADATA names another project or
+// version than its filename. Paths that are not wheel filenames, such as
+// cache entries named by digest, are not checked.
+func checkWheelFilename(wheelPath string, metadata *WheelMetadata) error {
+	wheel, err := pypi.ParseWheelFilename(filepath.Base(wheelPath))
+	if err != nil {
+		return nil
+	}
+	if pkgname.Normalize(wheel.Name) != pkgname.Normalize(metadata.Name) {
+		return &UnsafeMetadataError{Field: "Name", Value: metadata.Name, Reason: "does not match the wheel filename " + filepath.Base(wheelPath)}
+	}
+	if version.Compare(wheel.Version, metadata.Version) != 0 {
+		return &UnsafeMetadataError{Field: "Version", Value: metadata.Version, Reason: "does not match the wheel filename " + filepath.Base(wheelPath)}
+	}
+	return nil
+}
+
+// checkArchiveMembers rejects archives with absolute member names, ".."
+// components or symlink entries, before anything is extracted. Extracting
+// such members would let a malicious wheel write outside the environment.
+// Archives that would unpack past the configured size limits are rejected too.
+func checkArchiveMembers(files []*zip.File) error {
+	limits := netutil.ArtifactLimits()
+	var total uint64
+	for _, file := range files {
+		total += file.UncompressedSize64
+		if limits.MaxExtractedSize > 0 && total > uint64(limits.MaxExtractedSize) {
+			return &netutil.SizeLimitError{What: "archive contents", Limit: "max_extracted_size", Size: int64(total), Max: limits.MaxExtractedSize}
+		}
+		if ratio := compressionRatio(file); limits.MaxCompressionRatio > 0 && file.UncompressedSize64 >= ratioExemptSize && ratio > limits.MaxCompressionRatio {
+			return &netutil.SizeLimitError{What: file.Name + " compression ratio", Limit: "max_compression_ratio", Size: ratio, Max: limits.MaxCompressionRatio}
+		}
+		if err := checkMemberName(file.Name); err != nil {
+			return err
+		}
+		if file.Mode()&os.ModeSymlink != 0 {
+			return &UnsafeMemberError{Member: file.Name, Reason: "symlink entries are not allowed"}
+		}
+	}
+	return nil
+}
+
+// checkMemberName rejects names that do not stay relative to the archive root
+// on every platform: leading slashes or backslashes, drive letters and ".."
+func checkMemberName(name string) error {
+	if name == "" {
+		return &UnsafeMemberError{Member: name, Reason: "empty name"}
+	}
+	if strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") || (len(name) >= 2 && name[1] == ':') {
+		return &UnsafeMemberError{Member: name, Reason: "absolute path"}
+	}
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return &UnsafeMemberError{Member: name, Reason: "path traversal"}
+		}
+	}
+	if strings.ContainsRune(name, 0) {
+		return &UnsafeMemberError{Member: name, Reason: "NUL byte in name"}
+	}
+	return nil
+}
+
+// compressionRatio is how many times larger file is once decompressed. The
+// zip reader fails on members that decompress past their declared size, so
+// the header sizes can be trusted.
+func compressionRatio(file *zip.File) int64 {
+	if file.CompressedSize64 == 0 {
+		if file.UncompressedSize64 == 0 {
+			return 0
+		}
+		return int64(file.UncompressedSize64)
+	}
+	return int64(file.UncompressedSize64 / file.CompressedSize64)
+}
@@ -0,0 +1,130 @@
+package installer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/installer/wheeltest"
+	"rimraf-adi.com/zephyr/pkg/netutil"
+)
+
+func TestInstallWheel_RejectsUnsafeMembers(t *testing.T) {
+	tests := []struct {
+		name   string
+		member string
+		target string
+	}{
+		{"traversal", "../../../../evil.py", ""},
+		{"nested traversal", "foo/../../../../evil.py", ""},
+		{"data traversal", "foo-1.0.0.data/scripts/../../../../../evil.py", ""},
+		{"backslash traversal", "..\\..\\..\\..\\evil.py", ""},
+		{"absolute", "/tmp/evil.py", ""},
+		{"drive letter", "C:/evil.py", ""},
+		{"symlink", "foo/link", "../../../../../.."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			venvPath := filepath.Join(dir, "venv")
+			os.MkdirAll(venvPath, 0755)
+			wi := NewWheelInstaller(venvPath)
+			crafted := wheeltest.Wheel{Name: "foo", Files: map[string]string{tt.member: "pwned"}}
+			if tt.target != "" {
+				crafted = wheeltest.Wheel{Name: "foo", Symlinks: map[string]string{tt.member: tt.target}}
+			}
+			wheelPath := crafted.Build(t, dir)
+			err := wi.InstallWheel(wheelPath, "foo")
+			var unsafe *UnsafeMemberError
+			if !errors.As(err, &unsafe) {
+				t.Fatalf("expected UnsafeMemberError, got %v", err)
+			}
+			if unsafe.Member != tt.member {
+				t.Errorf("Member = %q, want %q", unsafe.Member, tt.member)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "evil.py")); err == nil {
+				t.Error("member was written outside the environment")
+			}
+			if _, err := os.Stat(filepath.Join(venvPath, "lib", "python3.11", "site-packages", "foo")); err == nil {
+				t.Error("nothing should be extracted from an unsafe wheel")
+			}
+		})
+	}
+}
+
+func TestCheckMemberName_AllowsWheelLayouts(t *testing.T) {
+	for _, name := range []string{
+		"foo/__init__.py",
+		"foo-1.0.0.data/scripts/foo",
+		"foo/..hidden/x.py",
+		"foo/a..b.py",
+	} {
+		if err := checkMemberName(name); err != nil {
+			t.Errorf("checkMemberName(%q) = %v, want nil", name, err)
+		}
+	}
+}
+
+func TestInstallWheel_RejectsZipBomb(t *testing.T) {
+	dir := t.TempDir()
+	venvPath := filepath.Join(dir, "venv")
+	os.MkdirAll(venvPath, 0755)
+	wi := NewWheelInstaller(venvPath)
+	// 8 MiB of zeros deflates to a few KiB, far past max_compression_ratio
+	wheelPath := wheeltest.Wheel{Name: "foo", Files: map[string]string{
+		"foo/__init__.py": "",
+		"foo/bomb.bin":    string(make([]byte, 8<<20)),
+	}}.Build(t, dir)
+	err := wi.InstallWheel(wheelPath, "foo")
+	var limit *netutil.SizeLimitError
+	if !errors.As(err, &limit) {
+		t.Fatalf("expected SizeLimitError, got %v", err)
+	}
+	if limit.Limit != "max_compression_ratio" {
+		t.Errorf("Limit = %q, want max_compression_ratio", limit.Limit)
+	}
+	if _, err := os.Stat(filepath.Join(venvPath, "lib", "python3.11", "site-packages", "foo", "bomb.bin")); err == nil {
+		t.Error("nothing should be extracted from a zip bomb")
+	}
+}
+
+func TestInstallWheel_RejectsUnsafeMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata string
+		field    string
+	}{
+		{"traversal name", "Name: ../../../../evil\nVersion: 1.0.0\n", "Name"},
+		{"separator in name", "Name: foo/evil\nVersion: 1.0.0\n", "Name"},
+		{"NUL in name", "Name: foo\x00\nVersion: 1.0.0\n", "Name"},
+		{"traversal version", "Name: foo\nVersion: 1.0.0/../../../../evil\n", "Version"},
+		{"invalid version", "Name: foo\nVersion: latest\n", "Version"},
+		{"other project", "Name: bar\nVersion: 1.0.0\n", "Name"},
+		{"other version", "Name: foo\nVersion: 2.0.0\n", "Version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			venvPath := filepath.Join(dir, "a", "b", "venv")
+			wi := NewWheelInstaller(venvPath)
+			wheelPath := wheeltest.Wheel{Name: "foo", Files: map[string]string{
+				"foo-1.0.0.dist-info/METADATA": tt.metadata,
+				"foo/__init__.py":              "",
+			}}.Build(t, dir)
+			err := wi.InstallWheel(wheelPath, "foo")
+			var unsafe *UnsafeMetadataError
+			if !errors.As(err, &unsafe) {
+				t.Fatalf("expected UnsafeMetadataError, got %v", err)
+			}
+			if unsafe.Field != tt.field {
+				t.Errorf("Field = %q, want %q", unsafe.Field, tt.field)
+			}
+			matches, _ := filepath.Glob(filepath.Join(dir, "*", "*.dist-info"))
+			more, _ := filepath.Glob(filepath.Join(dir, "*.dist-info"))
+			if len(matches)+len(more) > 0 {
+				t.Errorf("dist-info written outside the environment: %v %v", matches, more)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package installer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// Build system formats zephyr export can describe a lockfile in
+const (
+	ExportNix   = "nix"
+	ExportBazel = "bazel"
+)
+
+// RenderBuildSystem renders artifacts, as listed by Artifacts, in the named
+// build system format. Hermetic builds need a hash for every file, so an
+// artifact without one is an error.
+func RenderBuildSystem(format string, artifacts []LockedArtifact, project string) (string, error) {
+	for _, a := range artifacts {
+		if a.SHA256 == "" {
+			return "", fmt.Errorf("%s %s has no sha256 for %s; run zephyr sync to pin one", a.Name, a.Version, a.Filename)
+		}
+	}
+	switch format {
+	case ExportNix:
+		return renderNix(artifacts, project), nil
+	case ExportBazel:
+		return renderBazel(artifacts, project), nil
+	}
+	return "", fmt.Errorf("unknown build system %q (expected nix or bazel)", format)
+}
+
+// renderNix renders a function of fetchurl returning an attribute set keyed
+// by package name. Each package has its version and either src, or srcs keyed
+// by target when the lockfile was resolved for targets.
+func renderNix(artifacts []LockedArtifact, project string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Locked dependencies of %s, generated by zephyr export from %s; do not edit\n", project, LockfileName())
+	b.WriteString("{ fetchurl }:\n{\n")
+	for i := 0; i < len(artifacts); {
+		j := i + 1
+		for j < len(artifacts) && artifacts[j].Name == artifacts[i].Name {
+			j++
+		}
+		a := artifacts[i]
+		fmt.Fprintf(&b, "  %s = {\n", strconv.Quote(a.Name))
+		fmt.Fprintf(&b, "    version = %s;\n", strconv.Quote(a.Version))
+		if a.Target == "" {
+			fmt.Fprintf(&b, "    src = %s;\n", nixFetchurl(a))
+		} else {
+			b.WriteString("    srcs = {\n")
+			for _, t := range artifacts[i:j] {
+				fmt.Fprintf(&b, "      %s = %s;\n", strconv.Quote(t.Target), nixFetchurl(t))
+			}
+			b.WriteString("    };\n")
+		}
+		b.WriteString("  };\n")
+		i = j
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func nixFetchurl(a LockedArtifact) string {
+	return fmt.Sprintf("fetchurl { url = %s; sha256 = %s; name = %s; }", strconv.Quote(a.URL), strconv.Quote(a.SHA256), strconv.Quote(a.Filename))
+}
+
+// renderBazel renders a .bzl file with a PACKAGES dict of the locked versions
+// and a macro declaring an http_file repository per artifact, named
+// pypi_<package>, or pypi_<package>_<target> for target-specific files
+func renderBazel(artifacts []LockedArtifact, project string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\"\"\"Locked dependencies of %s, generated by zephyr export from %s; do not edit\"\"\"\n\n", project, LockfileName())
+	b.WriteString("load(\"@bazel_tools//tools/build_defs/repo:http.bzl\", \"http_file\")\n\n")
+	b.WriteString("PACKAGES = {\n")
+	for i, a := range artifacts {
+		if i == 0 || artifacts[i-1].Name != a.Name {
+			fmt.Fprintf(&b, "    %s: %s,\n", strconv.Quote(a.Name), strconv.Quote(a.Version))
+		}
+	}
+	b.WriteString("}\n\n")
+	b.WriteString("def zephyr_dependencies():\n")
+	b.WriteString("    \"\"\"Declares a repository for every locked artifact\"\"\"\n")
+	if len(artifacts) == 0 {
+		b.WriteString("    pass\n")
+	}
+	for _, a := range artifacts {
+		name := "pypi_" + a.Name
+		if a.Target != "" {
+			name += "_" + a.Target
+		}
+		b.WriteString("    http_file(\n")
+		fmt.Fprintf(&b, "        name = %s,\n", strconv.Quote(bazelRepositoryName(name)))
+		fmt.Fprintf(&b, "        urls = [%s],\n", strconv.Quote(a.URL))
+		fmt.Fprintf(&b, "        sha256 = %s,\n", strconv.Quote(a.SHA256))
+		fmt.Fprintf(&b, "        downloaded_file_path = %s,\n", strconv.Quote(a.Filename))
+		b.WriteString("    )\n")
+	}
+	return b.String()
+}
+
+// bazelRepositoryName replaces the characters Bazel does not allow in
+// repository names with underscores
+func bazelRepositoryName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, pkgname.Normalize(name))
+}
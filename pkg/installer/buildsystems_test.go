@@ -0,0 +1,58 @@
+package installer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderBuildSystem(t *testing.T) {
+	artifacts := []LockedArtifact{
+		{Name: "requests", Version: "2.31.0", Filename: "requests-2.31.0-py3-none-any.whl", URL: "https://files.example/requests.whl", SHA256: "aa"},
+		{Name: "ruamel.yaml", Version: "0.18.5", Target: "linux-x86_64-3.11", Filename: "ruamel.yaml-linux.whl", URL: "https://files.example/linux.whl", SHA256: "bb"},
+		{Name: "ruamel.yaml", Version: "0.18.5", Target: "macos-arm64-3.11", Filename: "ruamel.yaml-macos.whl", URL: "https://files.example/macos.whl", SHA256: "cc"},
+	}
+
+	nix, err := RenderBuildSystem(ExportNix, artifacts, "myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"{ fetchurl }:\n{\n",
+		`  "requests" = {` + "\n" + `    version = "2.31.0";` + "\n" +
+			`    src = fetchurl { url = "https://files.example/requests.whl"; sha256 = "aa"; name = "requests-2.31.0-py3-none-any.whl"; };`,
+		`    srcs = {` + "\n" + `      "linux-x86_64-3.11" = fetchurl { url = "https://files.example/linux.whl"; sha256 = "bb";`,
+		`      "macos-arm64-3.11" = fetchurl {`,
+	} {
+		if !strings.Contains(nix, want) {
+			t.Errorf("nix output missing %q:\n%s", want, nix)
+		}
+	}
+	if strings.Count(nix, `"ruamel.yaml" = {`) != 1 {
+		t.Errorf("targeted package should appear once:\n%s", nix)
+	}
+
+	bzl, err := RenderBuildSystem(ExportBazel, artifacts, "myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`load("@bazel_tools//tools/build_defs/repo:http.bzl", "http_file")`,
+		"PACKAGES = {\n    \"requests\": \"2.31.0\",\n    \"ruamel.yaml\": \"0.18.5\",\n}\n",
+		`name = "pypi_requests",`,
+		`name = "pypi_ruamel_yaml_linux_x86_64_3_11",`,
+		`sha256 = "cc",`,
+		`downloaded_file_path = "ruamel.yaml-macos.whl",`,
+	} {
+		if !strings.Contains(bzl, want) {
+			t.Errorf("bazel output missing %q:\n%s", want, bzl)
+		}
+	}
+
+	artifacts[0].SHA256 = ""
+	if _, err := RenderBuildSystem(ExportNix, artifacts, "myapp"); err == nil || !strings.Contains(err.Error(), "requests") {
+		t.Errorf("expected an error for a missing hash, got %v", err)
+	}
+	if _, err := RenderBuildSystem("make", nil, "myapp"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
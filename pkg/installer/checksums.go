@@ -0,0 +1,74 @@
+package installer
+
+import (
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/cache"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+	"rimraf-adi.com/zephyr/pkg/pypi"
+)
+
+// lockHashPrefix marks the algorithm of LockPackage.Hash
+const lockHashPrefix = "sha256:"
+
+// SetChecksumDB overrides the database pinning digests of artifacts whose
+// index publishes none
+func (wi *WheelInstaller) SetChecksumDB(db *cache.ChecksumDB) {
+	wi.checksums = db
+}
+
+// SetLockedHashes makes downloads of locked packages verify against the
+// hashes recorded in the lockfile
+func (wi *WheelInstaller) SetLockedHashes(lf *Lockfile) {
+	wi.lockedHashes = make(map[string]string, len(lf.Packages))
+	for name, pkg := range lf.Packages {
+		if pkg.Hash != "" {
+			wi.lockedHashes[artifactKey(name, pkg.Version)] = strings.ToLower(strings.TrimPrefix(pkg.Hash, lockHashPrefix))
+		}
+	}
+}
+
+// PinHashes records the artifact hashes the installer saw for locked packages
+// that have none yet and reports how many were added
+func (lf *Lockfile) PinHashes(wi *WheelInstaller) int {
+	added := 0
+	for name, pkg := range lf.Packages {
+		digest, ok := wi.hashes[artifactKey(name, pkg.Version)]
+		if !ok || pkg.Hash != "" {
+			continue
+		}
+		pkg.Hash = lockHashPrefix + digest
+		lf.Packages[name] = pkg
+		added++
+	}
+	return added
+}
+
+// expectedHash returns the digest a download must match and, when that digest
+// was pinned rather than published by the index, where it was pinned. The
+// index digest wins but must agree with the lockfile; without one the
+// lockfile and then the checksum database are used. An empty digest means
+// the artifact has never been seen and is trusted on first use.
+func (wi *WheelInstaller) expectedHash(release *pypi.Release, packageName, version string) (string, string, error) {
+	locked := wi.lockedHashes[artifactKey(packageName, version)]
+	published := strings.ToLower(release.Digests.SHA256)
+	if published != "" {
+		if locked != "" && locked != published {
+			return "", "", &cache.ChecksumMismatchError{URL: cache.ChecksumKey(release.URL), Pinned: locked, Actual: published, DB: lockfileName}
+		}
+		return published, "", nil
+	}
+	if locked != "" {
+		return locked, lockfileName, nil
+	}
+	pinned, ok, err := wi.checksums.Lookup(release.URL)
+	if err != nil || !ok {
+		return "", "", err
+	}
+	return pinned, wi.checksums.Path, nil
+}
+
+// artifactKey identifies a package version in the hash maps
+func artifactKey(name, version string) string {
+	return pkgname.Normalize(name) + "==" + version
+}
@@ -0,0 +1,133 @@
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/cache"
+	"rimraf-adi.com/zephyr/pkg/progress"
+	"rimraf-adi.com/zephyr/pkg/pypi"
+)
+
+func newTOFUInstaller(t *testing.T) *WheelInstaller {
+	dir := t.TempDir()
+	wi := NewWheelInstaller(filepath.Join(dir, "venv"))
+	wi.SetCache(cache.NewArtifactCache(filepath.Join(dir, "artifacts")))
+	wi.SetChecksumDB(cache.NewChecksumDB(filepath.Join(dir, "checksums.json")))
+	return wi
+}
+
+func TestFetchWheel_TrustOnFirstUse(t *testing.T) {
+	content := "original wheel"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	wi := newTOFUInstaller(t)
+	release := &pypi.Release{Filename: "demo-1.0-py3-none-any.whl", URL: server.URL + "/demo-1.0-py3-none-any.whl"}
+	client := pypi.NewPyPIClient()
+
+	if _, err := wi.fetchWheel(client, release, "demo", "1.0"); err != nil {
+		t.Fatalf("first download failed: %v", err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	want := hex.EncodeToString(sum[:])
+	pinned, ok, err := wi.checksums.Lookup(release.URL)
+	if err != nil || !ok || pinned != want {
+		t.Fatalf("Lookup = %q, %v, %v; want %q", pinned, ok, err, want)
+	}
+
+	// The same artifact is served from the cache under its pinned hash
+	if _, err := wi.fetchWheel(client, release, "demo", "1.0"); err != nil {
+		t.Fatalf("second fetch failed: %v", err)
+	}
+
+	// A changed artifact at the same URL is refused by a fresh cache
+	content = "tampered wheel"
+	other := newTOFUInstaller(t)
+	other.SetChecksumDB(wi.checksums)
+	_, err = other.fetchWheel(client, release, "demo", "1.0")
+	var mismatch *cache.ChecksumMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected ChecksumMismatchError, got %v", err)
+	}
+	if mismatch.Pinned != want {
+		t.Errorf("Pinned = %q, want %q", mismatch.Pinned, want)
+	}
+}
+
+func TestFetchWheel_LockedHash(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("wheel"))
+	}))
+	defer server.Close()
+
+	wi := newTOFUInstaller(t)
+	lf := NewLockfile("3.11")
+	lf.Packages["demo"] = LockPackage{Version: "1.0", Hash: "sha256:" + hex.EncodeToString(make([]byte, 32))}
+	wi.SetLockedHashes(lf)
+
+	release := &pypi.Release{Filename: "demo-1.0-py3-none-any.whl", URL: server.URL + "/demo.whl"}
+	_, err := wi.fetchWheel(pypi.NewPyPIClient(), release, "demo", "1.0")
+	var mismatch *cache.ChecksumMismatchError
+	if !errors.As(err, &mismatch) || mismatch.DB != "zephyr.lock" {
+		t.Fatalf("expected a mismatch against zephyr.lock, got %v", err)
+	}
+
+	// An index digest that disagrees with the lockfile is refused before downloading
+	release.Digests.SHA256 = "ff" + hex.EncodeToString(make([]byte, 31))
+	if _, err := wi.fetchWheel(pypi.NewPyPIClient(), release, "demo", "1.0"); !errors.As(err, &mismatch) {
+		t.Fatalf("expected a mismatch between index and lockfile, got %v", err)
+	}
+}
+
+func TestLockfilePinHashes(t *testing.T) {
+	wi := newTOFUInstaller(t)
+	wi.hashes[artifactKey("Demo", "1.0")] = "abc"
+	wi.hashes[artifactKey("other", "2.0")] = "def"
+
+	lf := NewLockfile("3.11")
+	lf.Packages["demo"] = LockPackage{Version: "1.0"}
+	lf.Packages["other"] = LockPackage{Version: "2.0", Hash: "sha256:kept"}
+	lf.Packages["missing"] = LockPackage{Version: "3.0"}
+
+	if added := lf.PinHashes(wi); added != 1 {
+		t.Errorf("PinHashes added %d, want 1", added)
+	}
+	if got := lf.Packages["demo"].Hash; got != "sha256:abc" {
+		t.Errorf("demo hash = %q", got)
+	}
+	if got := lf.Packages["other"].Hash; got != "sha256:kept" {
+		t.Errorf("existing hash was replaced: %q", got)
+	}
+	if got := lf.Packages["missing"].Hash; got != "" {
+		t.Errorf("missing hash = %q", got)
+	}
+}
+
+func TestFetchWheel_Progress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("wheel"))
+	}))
+	defer server.Close()
+
+	wi := newTOFUInstaller(t)
+	var events []progress.Event
+	wi.SetProgress(func(e progress.Event) { events = append(events, e) })
+	release := &pypi.Release{Filename: "demo-1.0-py3-none-any.whl", URL: server.URL + "/demo.whl", Size: 5}
+	if _, err := wi.fetchWheel(pypi.NewPyPIClient(), release, "demo", "1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) < 3 || events[0].Kind != progress.DownloadStarted || events[len(events)-1].Kind != progress.DownloadFinished {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if last := events[len(events)-2]; last.Kind != progress.DownloadProgress || last.Bytes != 5 || last.Total != 5 {
+		t.Errorf("expected the download to report all 5 bytes, got %+v", last)
+	}
+}
@@ -0,0 +1,213 @@
+package installer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/fsutil"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// overwritesFile records, inside a dist-info directory, files the distribution
+// took over from another one when installed with overwriting allowed
+const overwritesFile = "OVERWRITES"
+
+// FileCollision is a file a wheel would write that another distribution owns
+type FileCollision struct {
+	Path  string
+	Owner string
+}
+
+// FileCollisionError reports the files a wheel would overwrite
+type FileCollisionError struct {
+	Package    string
+	Collisions []FileCollision
+}
+
+func (e *FileCollisionError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s would overwrite %d file(s) owned by other packages:", e.Package, len(e.Collisions))
+	for _, c := range e.Collisions {
+		fmt.Fprintf(&b, "\n  %s (owned by %s)", c.Path, c.Owner)
+	}
+	fmt.Fprintf(&b, "\nRemove the conflicting package or re-run with --allow-overwrite to make %s the owner of these files", e.Package)
+	return b.String()
+}
+
+// SetAllowOverwrite lets a wheel replace files owned by other distributions.
+// Replaced files are recorded so later installs treat the new package as owner.
+func (wi *WheelInstaller) SetAllowOverwrite(allow bool) {
+	wi.allowOverwrite = allow
+}
+
+// checkFileCollisions compares the files in a wheel with those owned by other
+// installed distributions. Identical files, such as the shared __init__.py of
+// pkgutil-style namespace packages, are not collisions. Files no installed
+// distribution owns are replaced silently.
+func (wi *WheelInstaller) checkFileCollisions(reader *zip.Reader, metadata *WheelMetadata) error {
+	owners, err := wi.fileOwners()
+	if err != nil {
+		return err
+	}
+	var collisions []FileCollision
+	for _, file := range reader.File {
+		if strings.Contains(file.Name, ".dist-info/") || file.FileInfo().IsDir() {
+			continue
+		}
+		targetPath := wi.installPath(file.Name, metadata)
+		recordPath := wi.recordPath(targetPath)
+		owner, ok := owners[recordPath]
+		if !ok || pkgname.Normalize(owner) == pkgname.Normalize(metadata.Name) {
+			continue
+		}
+		if _, err := wi.fs.Stat(targetPath); err != nil {
+			continue
+		}
+		if same, err := sameContent(wi.fs, file, targetPath); err == nil && same {
+			continue
+		}
+		collisions = append(collisions, FileCollision{Path: recordPath, Owner: owner})
+	}
+	if len(collisions) == 0 {
+		return nil
+	}
+	if !wi.allowOverwrite {
+		return &FileCollisionError{Package: metadata.Name, Collisions: collisions}
+	}
+	for _, c := range collisions {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: %s from %s overwrites the file owned by %s\n", c.Path, metadata.Name, c.Owner)
+	}
+	metadata.Overwrites = collisions
+	return nil
+}
+
+// fileOwners maps site-packages relative paths to the distribution owning them,
+// based on the RECORD and OVERWRITES files of installed distributions
+func (wi *WheelInstaller) fileOwners() (map[string]string, error) {
+	matches, err := wi.fs.Glob(filepath.Join(wi.getSitePackagesPath(), "*.dist-info"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan site-packages: %w", err)
+	}
+	sort.Strings(matches)
+	claims := make(map[string][]string)
+	replaced := make(map[string]map[string]bool)
+	for _, distInfo := range matches {
+		dist, _, _ := strings.Cut(strings.TrimSuffix(filepath.Base(distInfo), ".dist-info"), "-")
+		paths, err := readRecordPaths(wi.fs, filepath.Join(distInfo, "RECORD"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			claims[path] = append(claims[path], dist)
+		}
+		overwrites, err := readOverwrites(wi.fs, filepath.Join(distInfo, overwritesFile))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range overwrites {
+			if replaced[c.Path] == nil {
+				replaced[c.Path] = make(map[string]bool)
+			}
+			replaced[c.Path][pkgname.Normalize(c.Owner)] = true
+		}
+	}
+	owners := make(map[string]string, len(claims))
+	for path, dists := range claims {
+		owners[path] = dists[len(dists)-1]
+		for _, dist := range dists {
+			if !replaced[path][pkgname.Normalize(dist)] {
+				owners[path] = dist
+				break
+			}
+		}
+	}
+	return owners, nil
+}
+
+// readRecordPaths returns the paths listed in a RECORD file, skipping
+// anything outside site-packages such as scripts
+func readRecordPaths(fsys fsutil.FS, path string) ([]string, error) {
+	data, err := fsys.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	var paths []string
+	for _, record := range records {
+		if len(record) == 0 || record[0] == "" || strings.HasPrefix(record[0], "..") || strings.HasPrefix(record[0], "/") {
+			continue
+		}
+		paths = append(paths, record[0])
+	}
+	return paths, nil
+}
+
+// readOverwrites parses an OVERWRITES file of path,previous-owner lines
+func readOverwrites(fsys fsutil.FS, path string) ([]FileCollision, error) {
+	data, err := fsys.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = 2
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	overwrites := make([]FileCollision, 0, len(records))
+	for _, record := range records {
+		overwrites = append(overwrites, FileCollision{Path: record[0], Owner: record[1]})
+	}
+	return overwrites, nil
+}
+
+// formatOverwrites renders collisions in the OVERWRITES file format
+func formatOverwrites(collisions []FileCollision) string {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	for _, c := range collisions {
+		w.Write([]string{c.Path, c.Owner})
+	}
+	w.Flush()
+	return b.String()
+}
+
+// sameContent reports whether a wheel member matches the file at path byte for byte
+func sameContent(fsys fsutil.FS, file *zip.File, path string) (bool, error) {
+	info, err := fsys.Stat(path)
+	if err != nil || info.IsDir() || uint64(info.Size()) != file.UncompressedSize64 {
+		return false, err
+	}
+	existing, err := fsys.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+	incoming, err := io.ReadAll(rc)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(existing, incoming), nil
+}
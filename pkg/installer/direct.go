@@ -0,0 +1,266 @@
+package installer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/netutil"
+	"rimraf-adi.com/zephyr/pkg/pypi"
+)
+
+const (
+	// SourceURL marks lockfile packages installed from an http(s) URL
+	SourceURL = "url"
+	// SourceFile marks lockfile packages installed from a local wheel
+	SourceFile = "file"
+
+	// maxSidecarSize bounds a remote .sha256 or .asc file, which is a few hundred bytes
+	maxSidecarSize = 64 << 10
+)
+
+// DirectInstall describes a wheel installed from a URL or local path
+type DirectInstall struct {
+	Name     string
+	Version  string
+	Location string
+	Source   string
+	SHA256   string
+	// Verified lists how the artifact was checked, e.g. "sha256 hash" or "PGP signature"
+	Verified []string
+}
+
+// LockPackage returns the lockfile entry pinning the installed artifact
+func (d *DirectInstall) LockPackage() LockPackage {
+	return LockPackage{Version: d.Version, Source: d.Source, URL: d.Location, Hash: lockHashPrefix + d.SHA256}
+}
+
+// IsDirectReference reports whether arg names a wheel by URL or path rather than a package
+func IsDirectReference(arg string) bool {
+	return strings.HasSuffix(strings.ToLower(stripFragment(arg)), ".whl") &&
+		(strings.Contains(arg, "://") || strings.ContainsAny(arg, `/\`) || fileExists(arg))
+}
+
+// InstallDirect installs the wheel at location, an http(s) or file URL or a
+// local path. Before installing, the wheel must match expectedHash when one is
+// given, and the <location>.sha256 and <location>.asc sidecars when they exist.
+func (wi *WheelInstaller) InstallDirect(location, expectedHash string) (*DirectInstall, error) {
+	if _, fragment, ok := strings.Cut(location, "#sha256="); ok && expectedHash == "" {
+		expectedHash = fragment
+	}
+	location = stripFragment(location)
+	filename := path.Base(filepath.ToSlash(location))
+	wheel, err := pypi.ParseWheelFilename(filename)
+	if err != nil {
+		return nil, err
+	}
+	install := &DirectInstall{Name: wheel.Name, Version: wheel.Version, Location: location, Source: SourceFile}
+	localPath := location
+	if isRemote(location) {
+		install.Source = SourceURL
+		tmp, err := os.MkdirTemp("", "zephyr-direct-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create download directory: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		localPath = filepath.Join(tmp, filename)
+		if err := downloadTo(location, localPath); err != nil {
+			return nil, err
+		}
+	} else if strings.HasPrefix(location, "file://") {
+		localPath = fileURLPath(location)
+	}
+
+	if err := wi.verifyDirect(install, localPath, expectedHash); err != nil {
+		return nil, err
+	}
+	if err := wi.InstallWheel(localPath, install.Name); err != nil {
+		return nil, err
+	}
+	wi.installed = append(wi.installed, InstalledArtifact{Name: install.Name, Version: install.Version, Source: install.Source, SHA256: install.SHA256})
+	return install, nil
+}
+
+// verifyDirect hashes the wheel and checks it against every available source of truth
+func (wi *WheelInstaller) verifyDirect(install *DirectInstall, localPath, expectedHash string) error {
+	digest, err := netutil.FileSHA256(localPath)
+	if err != nil {
+		return err
+	}
+	install.SHA256 = digest
+	if expectedHash != "" {
+		want := strings.ToLower(strings.TrimPrefix(expectedHash, lockHashPrefix))
+		if want != digest {
+			return fmt.Errorf("%s has sha256 %s but %s was expected. Check the --hash value or the lockfile entry", install.Location, digest, want)
+		}
+		install.Verified = append(install.Verified, "sha256 hash")
+	}
+
+	if sidecar, err := readSidecar(install.Location + ".sha256"); err != nil {
+		return err
+	} else if sidecar != nil {
+		want, err := parseSHA256Sidecar(string(sidecar), path.Base(filepath.ToSlash(install.Location)))
+		if err != nil {
+			return fmt.Errorf("invalid %s.sha256: %w", install.Location, err)
+		}
+		if want != digest {
+			return fmt.Errorf("%s has sha256 %s but its .sha256 sidecar lists %s", install.Location, digest, want)
+		}
+		install.Verified = append(install.Verified, ".sha256 sidecar")
+	}
+
+	if signature, err := readSidecar(install.Location + ".asc"); err != nil {
+		return err
+	} else if signature != nil {
+		if err := verifyPGP(localPath, signature); err != nil {
+			return fmt.Errorf("PGP verification of %s failed: %w", install.Location, err)
+		}
+		install.Verified = append(install.Verified, "PGP signature")
+	}
+
+	if len(install.Verified) == 0 {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: %s has no .sha256 or .asc sidecar and no --hash was given; installing unverified (sha256 %s)\n", install.Location, digest)
+		if install.Source == SourceURL {
+			return wi.checksums.Pin(install.Location, digest)
+		}
+	}
+	return nil
+}
+
+// parseSHA256Sidecar reads a sha256sum style file: either a bare digest or
+// "digest  filename" lines, in which case the line for filename is used
+func parseSHA256Sidecar(content, filename string) (string, error) {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name := ""
+		if len(fields) > 1 {
+			name = strings.TrimPrefix(fields[1], "*")
+		}
+		if name != "" && name != filename && len(lines) > 1 {
+			continue
+		}
+		digest := strings.ToLower(fields[0])
+		if _, err := hex.DecodeString(digest); err != nil || len(digest) != 64 {
+			return "", fmt.Errorf("%q is not a sha256 digest", fields[0])
+		}
+		return digest, nil
+	}
+	return "", fmt.Errorf("no digest for %s", filename)
+}
+
+// verifyPGP checks a detached ASCII-armoured signature with gpg against the
+// keys in the user's keyring
+func verifyPGP(file string, signature []byte) error {
+	gpg, err := exec.LookPath("gpg")
+	if err != nil {
+		return errors.New("a .asc signature was found but gpg is not installed")
+	}
+	sig, err := os.CreateTemp("", "zephyr-*.asc")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sig.Name())
+	if _, err := sig.Write(signature); err != nil {
+		sig.Close()
+		return err
+	}
+	sig.Close()
+	output, err := exec.Command(gpg, "--batch", "--verify", sig.Name(), file).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// readSidecar returns the contents of a sidecar file or URL, or nil when it does not exist
+func readSidecar(location string) ([]byte, error) {
+	if !isRemote(location) {
+		if strings.HasPrefix(location, "file://") {
+			location = fileURLPath(location)
+		}
+		data, err := os.ReadFile(location)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return data, err
+	}
+	// Fetch like any other download: paced, sharing the download slots and headers
+	var resp *http.Response
+	body, err := netutil.StartDownload(func() (io.ReadCloser, error) {
+		req, err := netutil.CreatePyPIRequest("GET", location)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = netutil.NewHTTPClient(0).Do(req)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Body, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", location, err)
+	}
+	defer body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: HTTP %d", location, resp.StatusCode)
+	}
+	resp.Body = body
+	if err := netutil.DecodeBody(resp); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", location, err)
+	}
+	data, err := io.ReadAll(netutil.LimitSize(resp.Body, location, maxSidecarSize))
+	var tooLarge *netutil.SizeLimitError
+	if errors.As(err, &tooLarge) {
+		return nil, fmt.Errorf("%s is larger than %d bytes, too large for a checksum or signature", location, maxSidecarSize)
+	}
+	return data, err
+}
+
+// downloadTo saves an http(s) URL to dest, paced by the download limits
+func downloadTo(location, dest string) error {
+	if err := netutil.DownloadFile(netutil.NewHTTPClient(0), location, dest, netutil.DownloadOptions{}); err != nil {
+		return fmt.Errorf("failed to download %s: %w", location, err)
+	}
+	return nil
+}
+
+// isRemote reports whether location is an http(s) URL
+func isRemote(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// fileURLPath converts a file:// URL to a local path
+func fileURLPath(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return strings.TrimPrefix(location, "file://")
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// stripFragment drops a #fragment, such as #sha256=..., from a location
+func stripFragment(location string) string {
+	location, _, _ = strings.Cut(location, "#")
+	return location
+}
+
+// fileExists reports whether path names an existing file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
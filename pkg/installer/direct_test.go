@@ -0,0 +1,132 @@
+package installer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/netutil"
+)
+
+func TestInstallDirect_Hash(t *testing.T) {
+	dir := t.TempDir()
+	wi := newTOFUInstaller(t)
+	wheelPath := createTestWheel(t, dir, "foo-1.0.0-py3-none-any.whl")
+	digest, err := netutil.FileSHA256(wheelPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := wi.InstallDirect(wheelPath, "sha256:"+strings.Repeat("0", 64)); err == nil {
+		t.Fatal("expected a hash mismatch")
+	}
+	result, err := wi.InstallDirect(wheelPath, "sha256:"+digest)
+	if err != nil {
+		t.Fatalf("InstallDirect failed: %v", err)
+	}
+	if result.Name != "foo" || result.Version != "1.0.0" || result.Source != SourceFile {
+		t.Errorf("unexpected result %+v", result)
+	}
+	lp := result.LockPackage()
+	if lp.Hash != "sha256:"+digest || lp.URL != wheelPath {
+		t.Errorf("LockPackage = %+v", lp)
+	}
+	if installed := wi.Installed(); len(installed) != 1 || installed[0].SHA256 != digest || installed[0].Source != SourceFile {
+		t.Errorf("Installed = %+v, want only the verified install", installed)
+	}
+}
+
+func TestInstallDirect_SidecarMismatch(t *testing.T) {
+	dir := t.TempDir()
+	wi := newTOFUInstaller(t)
+	wheelPath := createTestWheel(t, dir, "foo-1.0.0-py3-none-any.whl")
+	os.WriteFile(wheelPath+".sha256", []byte(strings.Repeat("a", 64)+"  foo-1.0.0-py3-none-any.whl\n"), 0644)
+	if _, err := wi.InstallDirect(wheelPath, ""); err == nil || !strings.Contains(err.Error(), ".sha256 sidecar") {
+		t.Fatalf("expected a sidecar mismatch, got %v", err)
+	}
+}
+
+func TestInstallDirect_URLWithSidecar(t *testing.T) {
+	dir := t.TempDir()
+	wheelPath := createTestWheel(t, dir, "foo-1.0.0-py3-none-any.whl")
+	digest, _ := netutil.FileSHA256(wheelPath)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/foo-1.0.0-py3-none-any.whl":
+			http.ServeFile(w, r, wheelPath)
+		case "/foo-1.0.0-py3-none-any.whl.sha256":
+			w.Write([]byte(digest + "\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	wi := newTOFUInstaller(t)
+	result, err := wi.InstallDirect(server.URL+"/foo-1.0.0-py3-none-any.whl", "")
+	if err != nil {
+		t.Fatalf("InstallDirect failed: %v", err)
+	}
+	if result.Source != SourceURL || len(result.Verified) != 1 || result.Verified[0] != ".sha256 sidecar" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestInstallDirect_OversizedSidecar(t *testing.T) {
+	dir := t.TempDir()
+	wheelPath := createTestWheel(t, dir, "foo-1.0.0-py3-none-any.whl")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/foo-1.0.0-py3-none-any.whl":
+			http.ServeFile(w, r, wheelPath)
+		case "/foo-1.0.0-py3-none-any.whl.sha256":
+			w.Write([]byte(strings.Repeat("a", maxSidecarSize+1)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	wi := newTOFUInstaller(t)
+	_, err := wi.InstallDirect(server.URL+"/foo-1.0.0-py3-none-any.whl", "")
+	if err == nil || !strings.Contains(err.Error(), "too large for a checksum") {
+		t.Fatalf("expected the oversized sidecar to be rejected, got %v", err)
+	}
+}
+
+func TestParseSHA256Sidecar(t *testing.T) {
+	a, b := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{a + "\n", a},
+		{a + "  foo.whl\n", a},
+		{b + "  other.whl\n" + a + " *foo.whl\n", a},
+	}
+	for _, tt := range tests {
+		got, err := parseSHA256Sidecar(tt.content, "foo.whl")
+		if err != nil || got != tt.want {
+			t.Errorf("parseSHA256Sidecar(%q) = %q, %v; want %q", tt.content, got, err, tt.want)
+		}
+	}
+	if _, err := parseSHA256Sidecar("nothex  foo.whl", "foo.whl"); err == nil {
+		t.Error("expected an error for an invalid digest")
+	}
+}
+
+func TestIsDirectReference(t *testing.T) {
+	for arg, want := range map[string]bool{
+		"requests":                        false,
+		"./dist/foo-1.0-py3-none-any.whl": true,
+		"https://example.com/foo-1.0-py3-none-any.whl#sha256=ab": true,
+		filepath.Join("wheels", "foo.tar.gz"):                    false,
+	} {
+		if got := IsDirectReference(arg); got != want {
+			t.Errorf("IsDirectReference(%q) = %v, want %v", arg, got, want)
+		}
+	}
+}
@@ -1,12 +1,22 @@
 package installer
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"rimraf-adi.com/zephyr/pkg/cache"
+	"rimraf-adi.com/zephyr/pkg/flock"
+	"rimraf-adi.com/zephyr/pkg/fsutil"
+	"rimraf-adi.com/zephyr/pkg/netutil"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
 	"rimraf-adi.com/zephyr/pkg/solver"
 )
 
@@ -15,6 +25,7 @@ type Lockfile struct {
 	Version     string                 `json:"version"`
 	GeneratedAt time.Time              `json:"generated_at"`
 	Python      string                 `json:"python"`
+	Targets     []string               `json:"targets,omitempty"`
 	Packages    map[string]LockPackage `json:"packages"`
 	Groups      map[string]LockGroup   `json:"groups,omitempty"`
 	Metadata    LockMetadata           `json:"metadata"`
@@ -29,6 +40,8 @@ type LockPackage struct {
 	Dependencies map[string]string `json:"dependencies,omitempty"`
 	Extras      []string          `json:"extras,omitempty"`
 	Markers     string            `json:"markers,omitempty"`
+	Targets     []string          `json:"targets,omitempty"`
+	Wheels      map[string]string `json:"wheels,omitempty"`
 }
 
 // LockGroup represents a group of packages
@@ -45,12 +58,18 @@ type LockMetadata struct {
 	ResolvedAt   time.Time         `json:"resolved_at"`
 	Constraints  map[string]string `json:"constraints"`
 	Conflicts    []string          `json:"conflicts,omitempty"`
+	// ExcludeNewer is the upload cutoff the lock was resolved with, in RFC 3339
+	ExcludeNewer string            `json:"exclude_newer,omitempty"`
+	// Profile is the configuration profile the lock was resolved under
+	Profile      string            `json:"profile,omitempty"`
+	// IndexURL is the package index the lock was resolved against
+	IndexURL     string            `json:"index_url,omitempty"`
 }
 
 // NewLockfile creates a new lockfile
 func NewLockfile(pythonVersion string) *Lockfile {
 	return &Lockfile{
-		Version:     "1.0",
+		Version:     LockfileVersion,
 		GeneratedAt: time.Now(),
 		Python:      pythonVersion,
 		Packages:    make(map[string]LockPackage),
@@ -66,10 +85,23 @@ func NewLockfile(pythonVersion string) *Lockfile {
 
 // LoadLockfile loads a lockfile from disk
 func LoadLockfile(path string) (*Lockfile, error) {
-	data, err := os.ReadFile(path)
+	return loadLockfile(fsutil.OS, path)
+}
+
+// loadLockfile loads a lockfile from fsys
+func loadLockfile(fsys fsutil.FS, path string) (*Lockfile, error) {
+	data, err := fsys.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read lockfile '%s': %w. Ensure the file exists and is readable.", path, err)
 	}
+	data, err = migrateLockfile(path, data)
+	var versionErr *LockfileVersionError
+	if errors.As(err, &versionErr) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile '%s': %w. The file may be corrupted or not a valid lockfile.", path, err)
+	}
 	var lockfile Lockfile
 	if err := json.Unmarshal(data, &lockfile); err != nil {
 		return nil, fmt.Errorf("failed to parse lockfile '%s': %w. The file may be corrupted or not a valid lockfile.", path, err)
@@ -79,11 +111,16 @@ func LoadLockfile(path string) (*Lockfile, error) {
 
 // Save saves the lockfile to disk
 func (lf *Lockfile) Save(path string) error {
+	return lf.save(fsutil.OS, path)
+}
+
+// save writes the lockfile to fsys
+func (lf *Lockfile) save(fsys fsutil.FS, path string) error {
 	data, err := json.MarshalIndent(lf, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal lockfile: %w. This is likely a bug in Zephyr.", err)
 	}
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := fsys.WriteFileAtomic(path, data, 0644); err != nil {
 		return fmt.Errorf("failed to write lockfile '%s': %w. Check permissions and disk space.", path, err)
 	}
 	return nil
@@ -117,9 +154,14 @@ func (lf *Lockfile) UpdateFromSolution(solution *solver.PartialSolution) error {
 	lf.Packages = make(map[string]LockPackage)
 	
 	// Add packages from solution
+	extras := solution.Extras()
 	for _, assignment := range solution.Assignments {
 		if assignment.IsDecision {
 			packageName := assignment.Term.Package
+			if _, _, isExtra := solver.SplitExtraPackage(packageName); isExtra {
+				// Extras are recorded on their base package
+				continue
+			}
 			version := assignment.Term.Version.String()
 			
 			// Create lock package
@@ -127,6 +169,7 @@ func (lf *Lockfile) UpdateFromSolution(solution *solver.PartialSolution) error {
 				Version: version,
 				Source:  "pypi",
 				URL:     fmt.Sprintf("https://pypi.org/pypi/%s/%s/json", packageName, version),
+				Extras:  extras[packageName],
 			}
 			
 			lf.AddPackage(packageName, lockPkg)
@@ -166,7 +209,12 @@ func (lf *Lockfile) IsStale(requirementsPath string) (bool, error) {
 
 // UpdateHash updates the lockfile hash
 func (lf *Lockfile) UpdateHash(requirementsPath string) error {
-	data, err := os.ReadFile(requirementsPath)
+	return lf.updateHash(fsutil.OS, requirementsPath)
+}
+
+// updateHash updates the lockfile hash from a requirements file in fsys
+func (lf *Lockfile) updateHash(fsys fsutil.FS, requirementsPath string) error {
+	data, err := fsys.ReadFile(requirementsPath)
 	if err != nil {
 		return fmt.Errorf("failed to read requirements file '%s': %w. Ensure the file exists and is readable.", requirementsPath, err)
 	}
@@ -185,6 +233,49 @@ func calculateHash(s string) string {
 	return fmt.Sprintf("%d", hash)
 }
 
+// Diff lists how the packages in other differ from lf, one line per
+// package in sorted order: "+ name version" for additions, "- name version"
+// for removals and "~ name old -> new" for version or extras changes
+func (lf *Lockfile) Diff(other *Lockfile) []string {
+	names := make(map[string]bool)
+	for name := range lf.Packages {
+		names[name] = true
+	}
+	for name := range other.Packages {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	
+	var diff []string
+	for _, name := range sorted {
+		old, inOld := lf.Packages[name]
+		cur, inNew := other.Packages[name]
+		switch {
+		case !inOld:
+			diff = append(diff, fmt.Sprintf("+ %s %s", name, describeLockPackage(cur)))
+		case !inNew:
+			diff = append(diff, fmt.Sprintf("- %s %s", name, describeLockPackage(old)))
+		case describeLockPackage(old) != describeLockPackage(cur):
+			diff = append(diff, fmt.Sprintf("~ %s %s -> %s", name, describeLockPackage(old), describeLockPackage(cur)))
+		}
+	}
+	return diff
+}
+
+// describeLockPackage formats the parts of a package that Diff compares
+func describeLockPackage(pkg LockPackage) string {
+	if len(pkg.Extras) == 0 {
+		return pkg.Version
+	}
+	extras := append([]string(nil), pkg.Extras...)
+	sort.Strings(extras)
+	return fmt.Sprintf("%s [%s]", pkg.Version, strings.Join(extras, ","))
+}
+
 // GetDependencyTree returns the dependency tree from the lockfile
 func (lf *Lockfile) GetDependencyTree() map[string][]string {
 	tree := make(map[string][]string)
@@ -202,6 +293,77 @@ func (lf *Lockfile) GetDependencyTree() map[string][]string {
 	return tree
 }
 
+// Subtree returns a copy of the lock holding only the named packages and
+// everything they depend on, for syncing part of an environment. Names match
+// after normalization; naming a package the lock does not pin is an error.
+func (lf *Lockfile) Subtree(names []string) (*Lockfile, error) {
+	locked := make(map[string]string, len(lf.Packages))
+	for name := range lf.Packages {
+		locked[pkgname.Normalize(name)] = name
+	}
+	var queue []string
+	for _, name := range names {
+		lockedName, ok := locked[pkgname.Normalize(name)]
+		if !ok {
+			return nil, fmt.Errorf("%s is not in %s", name, LockfileName())
+		}
+		queue = append(queue, lockedName)
+	}
+	subtree := *lf
+	subtree.Packages = make(map[string]LockPackage)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, done := subtree.Packages[name]; done {
+			continue
+		}
+		pkg := lf.Packages[name]
+		subtree.Packages[name] = pkg
+		for dep := range pkg.Dependencies {
+			if base, _, _ := strings.Cut(dep, "["); locked[pkgname.Normalize(base)] != "" {
+				queue = append(queue, locked[pkgname.Normalize(base)])
+			}
+		}
+	}
+	return &subtree, nil
+}
+
+// Only returns a copy of the lock holding just the named packages, matched
+// after normalization; naming a package the lock does not pin is an error
+func (lf *Lockfile) Only(names []string) (*Lockfile, error) {
+	only := *lf
+	only.Packages = make(map[string]LockPackage, len(names))
+	for _, name := range names {
+		found := false
+		for lockedName, pkg := range lf.Packages {
+			if pkgname.Normalize(lockedName) == pkgname.Normalize(name) {
+				only.Packages[lockedName] = pkg
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s is not in %s", name, LockfileName())
+		}
+	}
+	return &only, nil
+}
+
+// Dependents returns the locked packages that depend on name, sorted
+func (lf *Lockfile) Dependents(name string) []string {
+	var dependents []string
+	for parent, pkg := range lf.Packages {
+		for dep := range pkg.Dependencies {
+			if base, _, _ := strings.Cut(dep, "["); pkgname.Normalize(base) == pkgname.Normalize(name) {
+				dependents = append(dependents, parent)
+				break
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
 // GetDirectDependencies returns direct dependencies (no transitive deps)
 func (lf *Lockfile) GetDirectDependencies() []string {
 	// This is a simplified implementation
@@ -216,32 +378,127 @@ func (lf *Lockfile) GetDirectDependencies() []string {
 	return direct
 }
 
+// lockfileName is the file a project's lock is kept in
+var lockfileName = "zephyr.lock"
+
+// SetLockfileName makes lockfile managers use name instead of zephyr.lock
+func SetLockfileName(name string) {
+	lockfileName = name
+}
+
+// LockfileName returns the lockfile name set by SetLockfileName
+func LockfileName() string {
+	return lockfileName
+}
+
+// LockfileNameFor returns the lockfile that belongs to a manifest:
+// buildmeta.yaml is locked in zephyr.lock and <name>.buildmeta.yaml, or any
+// other <name>.yaml, in <name>.zephyr.lock, so manifests sharing a
+// directory do not share a lock
+func LockfileNameFor(manifest string) string {
+	stem := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(manifest), ".yaml"), ".yml")
+	stem = strings.TrimSuffix(strings.TrimSuffix(stem, "buildmeta"), ".")
+	if stem == "" {
+		return "zephyr.lock"
+	}
+	return stem + ".zephyr.lock"
+}
+
 // LockfileManager manages lockfile operations
 type LockfileManager struct {
 	ProjectDir string
 	LockPath   string
+	// fs holds the project; nil means the disk
+	fs fsutil.FS
+	// held is the project lock while Lock holds it
+	held *flock.Lock
 }
 
 // NewLockfileManager creates a new lockfile manager
 func NewLockfileManager(projectDir string) *LockfileManager {
 	return &LockfileManager{
 		ProjectDir: projectDir,
-		LockPath:   filepath.Join(projectDir, "zephyr.lock"),
+		LockPath:   filepath.Join(projectDir, lockfileName),
 	}
 }
 
+// SetFS makes the manager read and write the lockfile through fsys instead
+// of the disk. The advisory project lock is still taken on disk.
+func (lm *LockfileManager) SetFS(fsys fsutil.FS) {
+	lm.fs = fsys
+}
+
 // Load loads the lockfile
 func (lm *LockfileManager) Load() (*Lockfile, error) {
-	if _, err := os.Stat(lm.LockPath); os.IsNotExist(err) {
+	if _, err := fsutil.Or(lm.fs).Stat(lm.LockPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("lockfile does not exist")
 	}
 	
-	return LoadLockfile(lm.LockPath)
+	return loadLockfile(fsutil.Or(lm.fs), lm.LockPath)
 }
 
-// Save saves the lockfile
+// Save saves the lockfile while holding the project lock, so concurrent
+// zephyr processes never interleave their writes
 func (lm *LockfileManager) Save(lockfile *Lockfile) error {
-	return lockfile.Save(lm.LockPath)
+	return lm.locked(func() error {
+		// Never overwrite a newer lockfile with one that lacks its additions
+		if data, err := fsutil.Or(lm.fs).ReadFile(lm.LockPath); err == nil {
+			if _, err := migrateLockfile(lm.LockPath, data); err != nil {
+				var versionErr *LockfileVersionError
+				if errors.As(err, &versionErr) {
+					return err
+				}
+			}
+		}
+		return lockfile.save(fsutil.Or(lm.fs), lm.LockPath)
+	})
+}
+
+// Lock takes the project lock and holds it until Unlock, so that a Load,
+// a change and the Save after it cannot interleave with another zephyr
+// process changing the lockfile. Save takes the lock itself when it is not
+// already held.
+func (lm *LockfileManager) Lock() error {
+	if lm.held != nil {
+		return nil
+	}
+	lock, err := flock.Acquire(lm.lockPath())
+	if err != nil {
+		return fmt.Errorf("failed to lock project: %w", err)
+	}
+	lm.held = lock
+	return nil
+}
+
+// Unlock releases the project lock taken by Lock
+func (lm *LockfileManager) Unlock() error {
+	err := lm.held.Release()
+	lm.held = nil
+	return err
+}
+
+// locked runs fn with the project lock, taking it for the call unless Lock
+// already holds it
+func (lm *LockfileManager) locked(fn func() error) error {
+	if lm.held != nil {
+		return fn()
+	}
+	if err := lm.Lock(); err != nil {
+		return err
+	}
+	defer lm.Unlock()
+	return fn()
+}
+
+// lockPath returns the advisory lock file for the project, kept in the cache
+// directory so it never shows up in the project tree
+func (lm *LockfileManager) lockPath() string {
+	dir, err := filepath.Abs(lm.ProjectDir)
+	if err != nil {
+		dir = lm.ProjectDir
+	}
+	sum := sha256.Sum256([]byte(dir))
+	return filepath.Join(cache.DefaultCacheDir(), "locks", "project-"+hex.EncodeToString(sum[:8])+".lock")
 }
 
 // Create creates a new lockfile
@@ -251,29 +508,58 @@ func (lm *LockfileManager) Create(pythonVersion string) *Lockfile {
 
 // Exists checks if the lockfile exists
 func (lm *LockfileManager) Exists() bool {
-	_, err := os.Stat(lm.LockPath)
+	_, err := fsutil.Or(lm.fs).Stat(lm.LockPath)
 	return err == nil
 }
 
 // Remove removes the lockfile
 func (lm *LockfileManager) Remove() error {
-	return os.Remove(lm.LockPath)
+	return fsutil.Or(lm.fs).Remove(lm.LockPath)
 }
 
-// Update updates the lockfile from requirements and solution
+// Update updates the lockfile from requirements and solution, holding the
+// project lock from reading the previous lockfile until the new one is saved
 func (lm *LockfileManager) Update(requirementsPath string, solution *solver.PartialSolution, pythonVersion string) error {
+	return lm.locked(func() error {
+		lockfile, err := lm.Build(requirementsPath, solution, pythonVersion)
+		if err != nil {
+			return err
+		}
+		return lm.Save(lockfile)
+	})
+}
+
+// Build returns the lockfile Update would write, without writing it, so it
+// can be checked first
+func (lm *LockfileManager) Build(requirementsPath string, solution *solver.PartialSolution, pythonVersion string) (*Lockfile, error) {
 	lockfile := lm.Create(pythonVersion)
 	
 	// Update from solution
 	if err := lockfile.UpdateFromSolution(solution); err != nil {
-		return err
+		return nil, err
 	}
 	
 	// Update hash
-	if err := lockfile.UpdateHash(requirementsPath); err != nil {
-		return err
+	if err := lockfile.updateHash(fsutil.Or(lm.fs), requirementsPath); err != nil {
+		return nil, err
 	}
-	
-	// Save lockfile
-	return lm.Save(lockfile)
+	lockfile.Metadata.Profile = netutil.Profile()
+	lockfile.Metadata.IndexURL = netutil.GetPyPIBaseURL()
+
+	// Keep artifact hashes pinned for versions that did not change, and
+	// packages installed from URLs or files, which the solver does not see
+	if previous, err := lm.Load(); err == nil {
+		for name, pkg := range lockfile.Packages {
+			if old, ok := previous.Packages[name]; ok && old.Version == pkg.Version && old.Hash != "" {
+				pkg.Hash = old.Hash
+				lockfile.Packages[name] = pkg
+			}
+		}
+		for name, old := range previous.Packages {
+			if _, ok := lockfile.Packages[name]; !ok && (old.Source == SourceURL || old.Source == SourceFile) {
+				lockfile.Packages[name] = old
+			}
+		}
+	}
+	return lockfile, nil
 } 
\ No newline at end of file
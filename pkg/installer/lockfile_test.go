@@ -1,10 +1,16 @@
 package installer
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
+
+	"rimraf-adi.com/zephyr/pkg/dirs"
+	"rimraf-adi.com/zephyr/pkg/flock"
+	"rimraf-adi.com/zephyr/pkg/fsutil"
+	"rimraf-adi.com/zephyr/pkg/solver"
 )
 
 func TestLockfileLifecycle(t *testing.T) {
@@ -39,6 +45,9 @@ func TestLockfileManager(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Load failed: %v", err)
 	}
+	if lf2.Packages["bar"].Version != "2.0.0" {
+		t.Errorf("Loaded lockfile mismatch: got %+v", lf2)
+	}
 	if !mgr.Exists() {
 		t.Error("Exists() should be true after save")
 	}
@@ -50,6 +59,31 @@ func TestLockfileManager(t *testing.T) {
 	}
 }
 
+func TestLockfileManager_MemFS(t *testing.T) {
+	fsys := fsutil.NewMemFS()
+	fsys.MkdirAll("/project", 0755)
+	mgr := NewLockfileManager("/project")
+	mgr.SetFS(fsys)
+	if mgr.Exists() {
+		t.Fatal("Exists() should be false before save")
+	}
+	lf := mgr.Create("3.12")
+	lf.Packages["bar"] = LockPackage{Version: "2.0.0", Source: "pypi"}
+	if err := mgr.Save(lf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := fsys.Stat(filepath.Join("/project", lockfileName)); err != nil {
+		t.Fatalf("Lockfile not written to the in-memory filesystem: %v", err)
+	}
+	lf2, err := mgr.Load()
+	if err != nil || lf2.Packages["bar"].Version != "2.0.0" {
+		t.Errorf("Load mismatch: %+v %v", lf2, err)
+	}
+	if _, err := os.Stat(filepath.Join("/project", lockfileName)); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing on disk, got %v", err)
+	}
+}
+
 func TestLockfileHashAndStale(t *testing.T) {
 	dir := t.TempDir()
 	reqPath := filepath.Join(dir, "requirements.txt")
@@ -92,4 +126,143 @@ func TestLockfileValidationErrors(t *testing.T) {
 	if err := lf.Validate(); err != nil {
 		t.Errorf("Validate should succeed for valid lockfile: %v", err)
 	}
-} 
\ No newline at end of file
+} 
+func TestLockfileUpdateFromSolutionExtras(t *testing.T) {
+	solution := &solver.PartialSolution{}
+	solution.AddAssignment(solver.Assignment{Term: solver.Term{Package: "requests[socks]", Version: solver.VersionConstraint{Specific: "2.31.0"}}, IsDecision: true})
+	solution.AddAssignment(solver.Assignment{Term: solver.Term{Package: "requests", Version: solver.VersionConstraint{Specific: "2.31.0"}}, IsDecision: true})
+	lf := NewLockfile("3.11")
+	if err := lf.UpdateFromSolution(solution); err != nil {
+		t.Fatalf("UpdateFromSolution failed: %v", err)
+	}
+	if lf.HasPackage("requests[socks]") {
+		t.Error("Extra pseudo-package should not be locked as a package")
+	}
+	pkg, ok := lf.GetPackage("requests")
+	if !ok || len(pkg.Extras) != 1 || pkg.Extras[0] != "socks" {
+		t.Errorf("Expected requests locked with extra socks, got %+v", pkg)
+	}
+}
+
+func TestLockfileDiff(t *testing.T) {
+	old := NewLockfile("3.11")
+	old.Packages["kept"] = LockPackage{Version: "1.0.0"}
+	old.Packages["bumped"] = LockPackage{Version: "1.0.0"}
+	old.Packages["dropped"] = LockPackage{Version: "0.1.0"}
+	old.Packages["extras"] = LockPackage{Version: "2.0.0", Extras: []string{"b", "a"}}
+	cur := NewLockfile("3.11")
+	cur.Packages["kept"] = LockPackage{Version: "1.0.0"}
+	cur.Packages["bumped"] = LockPackage{Version: "1.1.0"}
+	cur.Packages["added"] = LockPackage{Version: "3.0.0"}
+	cur.Packages["extras"] = LockPackage{Version: "2.0.0", Extras: []string{"a"}}
+
+	diff := old.Diff(cur)
+	expected := []string{
+		"+ added 3.0.0",
+		"~ bumped 1.0.0 -> 1.1.0",
+		"- dropped 0.1.0",
+		"~ extras 2.0.0 [a,b] -> 2.0.0 [a]",
+	}
+	if len(diff) != len(expected) {
+		t.Fatalf("Diff = %v, expected %v", diff, expected)
+	}
+	for i := range expected {
+		if diff[i] != expected[i] {
+			t.Errorf("Diff[%d] = %q, expected %q", i, diff[i], expected[i])
+		}
+	}
+	if d := cur.Diff(cur); len(d) != 0 {
+		t.Errorf("Diff of identical lockfiles should be empty, got %v", d)
+	}
+}
+
+func TestLockfileNameFor(t *testing.T) {
+	cases := map[string]string{
+		"buildmeta.yaml":               "zephyr.lock",
+		"services/api/buildmeta.yaml":  "zephyr.lock",
+		"api.buildmeta.yaml":           "api.zephyr.lock",
+		"worker.yml":                   "worker.zephyr.lock",
+		"deploy/billing.buildmeta.yml": "billing.zephyr.lock",
+	}
+	for manifest, want := range cases {
+		if got := LockfileNameFor(manifest); got != want {
+			t.Errorf("LockfileNameFor(%q) = %q, want %q", manifest, got, want)
+		}
+	}
+
+	SetLockfileName("api.zephyr.lock")
+	defer SetLockfileName("zephyr.lock")
+	if lm := NewLockfileManager("proj"); lm.LockPath != filepath.Join("proj", "api.zephyr.lock") {
+		t.Errorf("LockPath = %q, want the configured lockfile name", lm.LockPath)
+	}
+}
+
+func TestLockfileSubtree(t *testing.T) {
+	lf := NewLockfile("3.11")
+	lf.Packages["Flask"] = LockPackage{Version: "3.0.0", Dependencies: map[string]string{"werkzeug": ">=3.0", "jinja2": ">=3.1"}}
+	lf.Packages["Jinja2"] = LockPackage{Version: "3.1.2", Dependencies: map[string]string{"markupsafe[speedups]": ">=2.0"}}
+	lf.Packages["werkzeug"] = LockPackage{Version: "3.0.1", Dependencies: map[string]string{"markupsafe": ">=2.1"}}
+	lf.Packages["MarkupSafe"] = LockPackage{Version: "2.1.3"}
+	lf.Packages["requests"] = LockPackage{Version: "2.31.0"}
+
+	sub, err := lf.Subtree([]string{"jinja2"})
+	if err != nil {
+		t.Fatalf("Subtree failed: %v", err)
+	}
+	if len(sub.Packages) != 2 || sub.Packages["Jinja2"].Version != "3.1.2" || sub.Packages["MarkupSafe"].Version != "2.1.3" {
+		t.Errorf("Subtree(jinja2) = %v, want Jinja2 and MarkupSafe", sub.Packages)
+	}
+	sub, err = lf.Subtree([]string{"flask"})
+	if err != nil {
+		t.Fatalf("Subtree failed: %v", err)
+	}
+	if len(sub.Packages) != 4 {
+		t.Errorf("Subtree(flask) has %d packages, want 4", len(sub.Packages))
+	}
+	if len(lf.Packages) != 5 {
+		t.Error("Subtree modified the lockfile")
+	}
+	if _, err := lf.Subtree([]string{"django"}); err == nil {
+		t.Error("Subtree should fail for a package not in the lock")
+	}
+}
+
+func TestLockfileOnly(t *testing.T) {
+	lf := NewLockfile("3.11")
+	lf.Packages["Flask"] = LockPackage{Version: "3.0.0", Dependencies: map[string]string{"werkzeug": ">=3.0"}}
+	lf.Packages["werkzeug"] = LockPackage{Version: "3.0.1"}
+	only, err := lf.Only([]string{"flask"})
+	if err != nil {
+		t.Fatalf("Only failed: %v", err)
+	}
+	if len(only.Packages) != 1 || only.Packages["Flask"].Version != "3.0.0" {
+		t.Errorf("Only(flask) = %v, want just Flask", only.Packages)
+	}
+	if _, err := lf.Only([]string{"django"}); err == nil {
+		t.Error("Only should fail for a package not in the lock")
+	}
+}
+
+func TestLockfileManagerLockSpansLoadAndSave(t *testing.T) {
+	t.Setenv(dirs.HomeEnv, t.TempDir())
+	defer func(timeout time.Duration) { flock.DefaultTimeout = timeout }(flock.DefaultTimeout)
+	flock.DefaultTimeout = 0
+	dir := t.TempDir()
+	lm := NewLockfileManager(dir)
+	if err := lm.Lock(); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if err := lm.Save(NewLockfile("3.11")); err != nil {
+		t.Fatalf("Save while holding the lock failed: %v", err)
+	}
+	var busy *flock.BusyError
+	if err := NewLockfileManager(dir).Save(NewLockfile("3.12")); !errors.As(err, &busy) {
+		t.Fatalf("expected another manager to find the project locked, got %v", err)
+	}
+	if err := lm.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if err := NewLockfileManager(dir).Save(NewLockfile("3.12")); err != nil {
+		t.Errorf("Save after Unlock failed: %v", err)
+	}
+}
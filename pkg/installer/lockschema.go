@@ -0,0 +1,126 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LockfileVersion is the lockfile schema this release reads and writes.
+// Bump the minor version for additions older releases could skip, and the
+// major version for changes they would misread; either way, add a migration
+// from the previous version to lockMigrations.
+const LockfileVersion = "1.0"
+
+// LockfileVersionError reports a lockfile written for a newer schema than
+// this release understands. Reading it anyway would silently drop whatever
+// the newer schema added.
+type LockfileVersionError struct {
+	Path    string
+	Version string
+}
+
+func (e *LockfileVersionError) Error() string {
+	return fmt.Sprintf("lockfile '%s' uses schema version %s, but this zephyr only understands up to %s. Upgrade zephyr to read it.", e.Path, e.Version, LockfileVersion)
+}
+
+// lockMigration upgrades a decoded lockfile document from one schema
+// version to the next
+type lockMigration struct {
+	from, to string
+	migrate  func(doc map[string]interface{})
+}
+
+// lockMigrations are applied in order to bring an older lockfile up to
+// LockfileVersion
+var lockMigrations = []lockMigration{
+	// Lockfiles from before the version field existed have the 1.0 layout
+	// but may lack the groups and constraints maps
+	{from: "", to: "1.0", migrate: func(doc map[string]interface{}) {
+		if _, ok := doc["groups"]; !ok {
+			doc["groups"] = map[string]interface{}{}
+		}
+		if metadata, ok := doc["metadata"].(map[string]interface{}); ok {
+			if _, ok := metadata["constraints"]; !ok {
+				metadata["constraints"] = map[string]interface{}{}
+			}
+		}
+	}},
+}
+
+// migrateLockfile returns the lockfile document data upgraded to
+// LockfileVersion
+func migrateLockfile(path string, data []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	version, _ := doc["version"].(string)
+	original := version
+	if version == LockfileVersion {
+		return data, nil
+	}
+	if version != "" {
+		newer, err := compareSchemaVersions(version, LockfileVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lockfile version %q: %w", version, err)
+		}
+		if newer > 0 {
+			return nil, &LockfileVersionError{Path: path, Version: version}
+		}
+	}
+	for _, m := range lockMigrations {
+		if m.from == version {
+			m.migrate(doc)
+			version = m.to
+			doc["version"] = version
+		}
+	}
+	if version != LockfileVersion {
+		return nil, fmt.Errorf("no migration from lockfile version %q to %s", original, LockfileVersion)
+	}
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return migrated, nil
+}
+
+// compareSchemaVersions compares two major.minor schema versions
+func compareSchemaVersions(a, b string) (int, error) {
+	pa, err := parseSchemaVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parseSchemaVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] > pb[i] {
+				return 1, nil
+			}
+			return -1, nil
+		}
+	}
+	return 0, nil
+}
+
+// parseSchemaVersion splits a major.minor schema version
+func parseSchemaVersion(v string) ([2]int, error) {
+	var parts [2]int
+	major, minor, ok := strings.Cut(v, ".")
+	if !ok {
+		return parts, fmt.Errorf("expected major.minor")
+	}
+	var err error
+	if parts[0], err = strconv.Atoi(major); err != nil {
+		return parts, fmt.Errorf("expected major.minor")
+	}
+	if parts[1], err = strconv.Atoi(minor); err != nil {
+		return parts, fmt.Errorf("expected major.minor")
+	}
+	return parts, nil
+}
@@ -0,0 +1,61 @@
+package installer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadLockfile_NewerSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "zephyr.lock")
+	newer := `{"version": "1.7", "packages": {"foo": {"version": "1.0", "source": "pypi", "provenance": "x"}}, "metadata": {}}`
+	if err := os.WriteFile(path, []byte(newer), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadLockfile(path)
+	var versionErr *LockfileVersionError
+	if !errors.As(err, &versionErr) || versionErr.Version != "1.7" || !strings.Contains(err.Error(), "Upgrade zephyr") {
+		t.Fatalf("LoadLockfile of a newer schema = %v, want a LockfileVersionError", err)
+	}
+
+	// Saving must not replace the newer lockfile with a lossy rewrite
+	lm := NewLockfileManager(dir)
+	if err := lm.Save(NewLockfile("3.11")); !errors.As(err, &versionErr) {
+		t.Errorf("Save over a newer lockfile = %v, want a LockfileVersionError", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != newer {
+		t.Error("newer lockfile was overwritten")
+	}
+}
+
+func TestLoadLockfile_Migrates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zephyr.lock")
+	// Written before the version field existed
+	old := `{"python": "3.11", "packages": {"foo": {"version": "1.0", "source": "pypi"}}, "metadata": {"hash": "abc"}}`
+	if err := os.WriteFile(path, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lf, err := LoadLockfile(path)
+	if err != nil {
+		t.Fatalf("LoadLockfile failed: %v", err)
+	}
+	if lf.Version != LockfileVersion || lf.Groups == nil || lf.Metadata.Constraints == nil || lf.Packages["foo"].Version != "1.0" {
+		t.Errorf("migrated lockfile = %+v", lf)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version": "0.3", "packages": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLockfile(path); err == nil || !strings.Contains(err.Error(), "no migration") {
+		t.Errorf("LoadLockfile of an unmigratable schema = %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"version": "two"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLockfile(path); err == nil {
+		t.Error("expected an error for an invalid version")
+	}
+}
@@ -0,0 +1,96 @@
+package installer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/markers"
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// Policy restricts what a locked dependency graph may contain, for projects
+// whose locks and artifacts are published. Indexes reject some of these
+// outright, and the rest make installs depend on hosts outside the index.
+type Policy struct {
+	// ForbidLocalVersions rejects versions with a +local label
+	ForbidLocalVersions bool
+	// ForbidDirectReferences rejects packages taken from a URL or file
+	// rather than the index, including VCS references
+	ForbidDirectReferences bool
+	// ForbidVCS rejects packages taken from a version control repository
+	ForbidVCS bool
+}
+
+// Enabled reports whether the policy forbids anything
+func (p Policy) Enabled() bool {
+	return p.ForbidLocalVersions || p.ForbidDirectReferences || p.ForbidVCS
+}
+
+// PolicyViolation is one locked package or requirement a policy forbids
+type PolicyViolation struct {
+	Package string
+	Problem string
+}
+
+func (v PolicyViolation) String() string {
+	return fmt.Sprintf("%s: %s", v.Package, v.Problem)
+}
+
+// vcsURL matches the PEP 440 VCS URL schemes, such as git+https://
+var vcsURL = regexp.MustCompile(`(?i)^(git|hg|svn|bzr)\+`)
+
+// CheckLock returns the locked packages the policy forbids, sorted by name
+func (p Policy) CheckLock(lf *Lockfile) []PolicyViolation {
+	names := make([]string, 0, len(lf.Packages))
+	for name := range lf.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var violations []PolicyViolation
+	for _, name := range names {
+		pkg := lf.Packages[name]
+		location := ""
+		if pkg.Source == SourceURL || pkg.Source == SourceFile {
+			location = pkg.URL
+			if location == "" {
+				location = pkg.Source
+			}
+		}
+		if problem := p.check(pkg.Version, location); problem != "" {
+			violations = append(violations, PolicyViolation{Package: name, Problem: problem})
+		}
+	}
+	return violations
+}
+
+// CheckRequirement returns the problem with a PEP 508 requirement, such as a
+// Requires-Dist entry, under the policy, or ""
+func (p Policy) CheckRequirement(requirement string) string {
+	spec, _ := markers.SplitRequirement(requirement)
+	if _, location, ok := strings.Cut(spec, "@"); ok {
+		return p.check("", strings.TrimSpace(location))
+	}
+	if i := strings.Index(spec, "=="); i >= 0 {
+		return p.check(strings.TrimSpace(strings.TrimLeft(spec[i+2:], "=")), "")
+	}
+	return ""
+}
+
+// check returns why a package at version, taken from location when that is
+// not the index, is forbidden, or ""
+func (p Policy) check(v, location string) string {
+	switch {
+	case location != "" && vcsURL.MatchString(location) && (p.ForbidVCS || p.ForbidDirectReferences):
+		return fmt.Sprintf("comes from the version control repository %s; publish it to the index and depend on a released version", location)
+	case location != "" && !vcsURL.MatchString(location) && p.ForbidDirectReferences:
+		return fmt.Sprintf("is a direct reference to %s; indexes reject published packages that depend on URLs, so depend on a release from the index", location)
+	}
+	if p.ForbidLocalVersions && v != "" {
+		if parsed, err := version.Parse(v); err == nil && parsed.IsLocal() {
+			return fmt.Sprintf("version %s has a local label, which only exists on the machine that built it; use a public release", v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package installer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheckLock(t *testing.T) {
+	lf := NewLockfile("3.11")
+	lf.AddPackage("requests", LockPackage{Version: "2.31.0", Source: "pypi"})
+	lf.AddPackage("patched", LockPackage{Version: "1.0+acme.1", Source: "pypi"})
+	lf.AddPackage("internal", LockPackage{Version: "2.0", Source: SourceURL, URL: "https://example.com/internal-2.0-py3-none-any.whl"})
+
+	if v := (Policy{}).CheckLock(lf); len(v) != 0 {
+		t.Errorf("empty policy reported %v", v)
+	}
+	if v := (Policy{ForbidVCS: true}).CheckLock(lf); len(v) != 0 {
+		t.Errorf("ForbidVCS reported %v for a lock without VCS packages", v)
+	}
+	v := (Policy{ForbidLocalVersions: true, ForbidDirectReferences: true}).CheckLock(lf)
+	if len(v) != 2 || v[0].Package != "internal" || v[1].Package != "patched" {
+		t.Fatalf("violations = %v, want internal and patched", v)
+	}
+	if !strings.Contains(v[0].Problem, "direct reference") || !strings.Contains(v[1].Problem, "local label") {
+		t.Errorf("problems = %v", v)
+	}
+}
+
+func TestPolicyCheckRequirement(t *testing.T) {
+	policy := Policy{ForbidLocalVersions: true, ForbidVCS: true}
+	tests := []struct {
+		requirement string
+		problem     string
+	}{
+		{"requests>=2.0", ""},
+		{"tool @ git+https://github.com/acme/tool@v1", "version control"},
+		{"tool @ https://example.com/tool-1.0-py3-none-any.whl", ""},
+		{`torch==2.1.0+cu118 ; sys_platform == "linux"`, "local label"},
+	}
+	for _, tt := range tests {
+		problem := policy.CheckRequirement(tt.requirement)
+		if (tt.problem == "") != (problem == "") || !strings.Contains(problem, tt.problem) {
+			t.Errorf("CheckRequirement(%q) = %q, want %q", tt.requirement, problem, tt.problem)
+		}
+	}
+	if problem := (Policy{ForbidDirectReferences: true}).CheckRequirement("tool @ https://example.com/tool.whl"); !strings.Contains(problem, "direct reference") {
+		t.Errorf("direct reference problem = %q", problem)
+	}
+}
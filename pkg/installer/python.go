@@ -0,0 +1,166 @@
+package installer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// PythonVersionFile is the pyenv file naming the interpreter a project uses
+const PythonVersionFile = ".python-version"
+
+// ReadPythonVersion returns the first version named in the .python-version
+// file in dir or its nearest parent, as pyenv does, and the file it came
+// from. Both are empty when no file exists.
+func ReadPythonVersion(dir string) (string, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for {
+		path := filepath.Join(dir, PythonVersionFile)
+		if f, err := os.Open(path); err == nil {
+			defer f.Close()
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if line != "" && !strings.HasPrefix(line, "#") {
+					return line, path, nil
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				return "", "", fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			return "", "", fmt.Errorf("%s names no Python version", path)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", nil
+		}
+		dir = parent
+	}
+}
+
+// pythonVersionPattern extracts the version from `python --version` output
+var pythonVersionPattern = regexp.MustCompile(`Python (\d+\.\d+(?:\.\d+)?\S*)`)
+
+// pythonMinorPattern matches the major.minor prefix of a version such as 3.12.1
+var pythonMinorPattern = regexp.MustCompile(`^(?:python)?(\d+\.\d+)(?:[.+a-z]|$)`)
+
+// PythonMinor returns the major.minor part of a Python version or a
+// .python-version entry, reporting false for names such as "pypy" or "system"
+func PythonMinor(v string) (string, bool) {
+	match := pythonMinorPattern.FindStringSubmatch(v)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// InterpreterVersion runs python --version and returns the version, e.g. "3.12.1"
+func InterpreterVersion(python string) (string, error) {
+	output, err := exec.Command(python, "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s --version: %w", python, err)
+	}
+	match := pythonVersionPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return "", fmt.Errorf("unexpected output from %s --version: %q", python, strings.TrimSpace(string(output)))
+	}
+	return match[1], nil
+}
+
+// MatchesPythonRequest reports whether an interpreter version satisfies a
+// .python-version entry such as "3.12" or "3.12.1", which match by prefix
+func MatchesPythonRequest(interpreter, request string) bool {
+	request = strings.TrimPrefix(request, "python")
+	return interpreter == request || strings.HasPrefix(interpreter, request+".")
+}
+
+// SatisfiesRequiresPython reports whether an interpreter version is allowed
+// by a requires-python specifier. Empty or unparsable specifiers allow any version.
+func SatisfiesRequiresPython(interpreter, requires string) bool {
+	ok, err := version.Satisfies(interpreter, requires)
+	return err != nil || ok
+}
+
+// FindPython locates an interpreter for a project and returns its path and
+// version. request is the version from .python-version and requires is the
+// project's requires-python; either may be empty. A request is looked up in
+// pyenv and as a versioned command such as python3.12 before python3 and
+// python; without one, versioned commands are tried last.
+func FindPython(request, requires string) (string, string, error) {
+	if request == "system" {
+		request = ""
+	}
+	var candidates []string
+	if request != "" {
+		if root := pyenvRoot(); root != "" {
+			candidates = append(candidates, filepath.Join(root, "versions", request, "bin", "python"))
+		}
+		if parts := strings.SplitN(strings.TrimPrefix(request, "python"), ".", 3); len(parts) >= 2 {
+			candidates = append(candidates, "python"+parts[0]+"."+parts[1])
+		}
+	}
+	candidates = append(candidates, "python3", "python", "py")
+	if request == "" && requires != "" {
+		// Fall back to the newest versioned interpreter that fits
+		for minor := 14; minor >= 7; minor-- {
+			candidates = append(candidates, fmt.Sprintf("python3.%d", minor))
+		}
+	}
+
+	var seen []string
+	for _, candidate := range candidates {
+		path, err := exec.LookPath(candidate)
+		if err != nil {
+			continue
+		}
+		v, err := InterpreterVersion(path)
+		if err != nil {
+			continue
+		}
+		seen = append(seen, fmt.Sprintf("%s (%s)", candidate, v))
+		if request != "" && !MatchesPythonRequest(v, request) {
+			continue
+		}
+		if !SatisfiesRequiresPython(v, requires) {
+			continue
+		}
+		return path, v, nil
+	}
+
+	want := "Python"
+	if request != "" {
+		want += " " + request
+	}
+	if requires != "" {
+		want += " matching requires-python " + requires
+	}
+	if len(seen) == 0 {
+		return "", "", fmt.Errorf("%s not found: no Python interpreter in PATH. Install it and ensure it is in your PATH", want)
+	}
+	return "", "", fmt.Errorf("%s not found; available interpreters: %s. Install a matching version or change %s", want, strings.Join(seen, ", "), PythonVersionFile)
+}
+
+// pyenvRoot returns the pyenv installation directory, if any
+func pyenvRoot() string {
+	if root := os.Getenv("PYENV_ROOT"); root != "" {
+		return root
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	root := filepath.Join(home, ".pyenv")
+	if _, err := os.Stat(root); err != nil {
+		return ""
+	}
+	return root
+}
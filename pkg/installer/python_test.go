@@ -0,0 +1,76 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestReadPythonVersion_Parent(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, PythonVersionFile), []byte("# pinned for CI\n3.12.1\n3.11\n"), 0644)
+	sub := filepath.Join(root, "src", "pkg")
+	os.MkdirAll(sub, 0755)
+
+	request, source, err := ReadPythonVersion(sub)
+	if err != nil {
+		t.Fatalf("ReadPythonVersion failed: %v", err)
+	}
+	if request != "3.12.1" || source != filepath.Join(root, PythonVersionFile) {
+		t.Errorf("ReadPythonVersion = %q, %q", request, source)
+	}
+}
+
+func TestPythonVersionMatching(t *testing.T) {
+	for _, tt := range []struct {
+		interpreter, request string
+		want                 bool
+	}{
+		{"3.12.1", "3.12", true},
+		{"3.12.1", "3.12.1", true},
+		{"3.12.1", "python3.12", true},
+		{"3.1.4", "3.12", false},
+		{"3.11.9", "3.12", false},
+	} {
+		if got := MatchesPythonRequest(tt.interpreter, tt.request); got != tt.want {
+			t.Errorf("MatchesPythonRequest(%q, %q) = %v", tt.interpreter, tt.request, got)
+		}
+	}
+	if !SatisfiesRequiresPython("3.12.1", ">=3.9,<4") || SatisfiesRequiresPython("3.8.10", ">=3.9") {
+		t.Error("SatisfiesRequiresPython did not apply the specifier")
+	}
+	for v, want := range map[string]string{"3.12.1": "3.12", "3.13": "3.13", "python3.10": "3.10", "3.13t": "3.13", "pypy3.10": "", "system": ""} {
+		if got, _ := PythonMinor(v); got != want {
+			t.Errorf("PythonMinor(%q) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestFindPython(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake interpreters are shell scripts")
+	}
+	bin := t.TempDir()
+	for name, v := range map[string]string{"python3": "3.10.4", "python3.12": "3.12.2"} {
+		script := "#!/bin/sh\necho Python " + v + "\n"
+		os.WriteFile(filepath.Join(bin, name), []byte(script), 0755)
+	}
+	t.Setenv("PATH", bin)
+	t.Setenv("PYENV_ROOT", filepath.Join(bin, "no-pyenv"))
+
+	if path, v, err := FindPython("", ""); err != nil || v != "3.10.4" || filepath.Base(path) != "python3" {
+		t.Errorf("default = %q, %q, %v", path, v, err)
+	}
+	if _, v, err := FindPython("3.12", ""); err != nil || v != "3.12.2" {
+		t.Errorf(".python-version 3.12 = %q, %v", v, err)
+	}
+	if _, v, err := FindPython("", ">=3.11"); err != nil || v != "3.12.2" {
+		t.Errorf("requires-python >=3.11 = %q, %v", v, err)
+	}
+	_, _, err := FindPython("3.9", "")
+	if err == nil || !strings.Contains(err.Error(), "python3 (3.10.4)") {
+		t.Errorf("expected an error listing the available interpreters, got %v", err)
+	}
+}
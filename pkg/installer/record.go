@@ -0,0 +1,59 @@
+package installer
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RecordHash returns data's digest in the form RECORD files use:
+// sha256=<urlsafe base64 without padding>
+func RecordHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256=" + base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// recordEntry formats the hash and size columns of a RECORD row for data
+func recordEntry(data []byte) string {
+	return fmt.Sprintf("%s,%d", RecordHash(data), len(data))
+}
+
+// readWheelRecord maps each member of a wheel to the "hash,size" columns its
+// own .dist-info/RECORD lists for it. Members without a hash are left out,
+// and a wheel without a RECORD yields an empty map.
+func readWheelRecord(reader *zip.Reader) (map[string]string, error) {
+	hashes := make(map[string]string)
+	for _, file := range reader.File {
+		dir, name, ok := strings.Cut(file.Name, "/")
+		if !ok || name != "RECORD" || !strings.HasSuffix(dir, ".dist-info") {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		r := csv.NewReader(bytes.NewReader(data))
+		r.FieldsPerRecord = -1
+		rows, err := r.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", file.Name, err)
+		}
+		for _, row := range rows {
+			if len(row) >= 3 && strings.Contains(row[1], "=") {
+				hashes[row[0]] = row[1] + "," + row[2]
+			}
+		}
+		break
+	}
+	return hashes, nil
+}
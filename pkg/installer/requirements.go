@@ -0,0 +1,106 @@
+package installer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// The parts of a lock zephyr export --split can write to separate files
+const (
+	SplitDirect     = "direct"
+	SplitTransitive = "transitive"
+)
+
+// ParseSplit validates a comma-separated list of lock parts
+func ParseSplit(value string) ([]string, error) {
+	var parts []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != SplitDirect && part != SplitTransitive {
+			return nil, fmt.Errorf("unknown split %q (expected direct, transitive or both)", part)
+		}
+		if !seen[part] {
+			seen[part] = true
+			parts = append(parts, part)
+		}
+	}
+	return parts, nil
+}
+
+// SplitPackages divides the locked packages, except exclude, into those
+// named in direct and the rest, each sorted by name
+func (lf *Lockfile) SplitPackages(direct []string, exclude string) (directNames, transitiveNames []string) {
+	isDirect := make(map[string]bool, len(direct))
+	for _, name := range direct {
+		isDirect[pkgname.Normalize(name)] = true
+	}
+	for name := range lf.Packages {
+		switch {
+		case pkgname.Normalize(name) == pkgname.Normalize(exclude):
+		case isDirect[pkgname.Normalize(name)]:
+			directNames = append(directNames, name)
+		default:
+			transitiveNames = append(transitiveNames, name)
+		}
+	}
+	sort.Strings(directNames)
+	sort.Strings(transitiveNames)
+	return directNames, transitiveNames
+}
+
+// RenderRequirements renders the named locked packages as a pip requirements
+// file pinned to their locked versions. Hashes are included only when every
+// package has one, since pip checks hashes for all requirements or none.
+// Each pin is followed by the locked packages that require it, as pip-compile
+// writes them, so reviewers can see why a pin is there.
+func (lf *Lockfile) RenderRequirements(names []string, header string) string {
+	hashed := len(names) > 0
+	for _, name := range names {
+		if lf.Packages[name].Hash == "" {
+			hashed = false
+		}
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(header, "\n"), "\n") {
+		if line != "" {
+			fmt.Fprintf(&b, "# %s\n", line)
+		}
+	}
+	for _, name := range names {
+		pkg := lf.Packages[name]
+		requirement := name + "==" + pkg.Version
+		if (pkg.Source == SourceURL || pkg.Source == SourceFile) && pkg.URL != "" {
+			requirement = name + " @ " + pkg.URL
+		}
+		if pkg.Markers != "" {
+			requirement += " ; " + pkg.Markers
+		}
+		if hashed {
+			requirement += " \\\n    --hash=" + lockHashPrefix + strings.TrimPrefix(pkg.Hash, lockHashPrefix)
+		}
+		b.WriteString(requirement + "\n")
+		b.WriteString(viaComment(lf.Dependents(name)))
+	}
+	return b.String()
+}
+
+// viaComment renders the "# via" lines naming the packages that require a pin,
+// or "" when none does
+func viaComment(dependents []string) string {
+	switch len(dependents) {
+	case 0:
+		return ""
+	case 1:
+		return "    # via " + dependents[0] + "\n"
+	}
+	var b strings.Builder
+	b.WriteString("    # via\n")
+	for _, dependent := range dependents {
+		b.WriteString("    #   " + dependent + "\n")
+	}
+	return b.String()
+}
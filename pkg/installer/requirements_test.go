@@ -0,0 +1,57 @@
+package installer
+
+import "testing"
+
+func TestParseSplit(t *testing.T) {
+	parts, err := ParseSplit("direct, transitive,direct")
+	if err != nil || len(parts) != 2 || parts[0] != SplitDirect || parts[1] != SplitTransitive {
+		t.Errorf("ParseSplit = %v, %v", parts, err)
+	}
+	if _, err := ParseSplit("direct,dev"); err == nil {
+		t.Error("expected an error for an unknown part")
+	}
+}
+
+func TestRenderRequirements(t *testing.T) {
+	lf := NewLockfile("3.11")
+	lf.AddPackage("myapp", LockPackage{Version: "0.1.0", Source: "pypi"})
+	lf.AddPackage("flask", LockPackage{Version: "3.0.0", Source: "pypi", Hash: "sha256:aa"})
+	lf.AddPackage("werkzeug", LockPackage{Version: "3.0.1", Source: "pypi", Hash: "sha256:bb"})
+	lf.AddPackage("colorama", LockPackage{Version: "0.4.6", Source: "pypi", Hash: "sha256:cc", Markers: `sys_platform == "win32"`})
+	lf.AddPackage("internal", LockPackage{Version: "2.0", Source: SourceURL, URL: "https://example.com/internal-2.0-py3-none-any.whl"})
+
+	direct, transitive := lf.SplitPackages([]string{"Flask", "internal", "absent"}, "MyApp")
+	if len(direct) != 2 || direct[0] != "flask" || direct[1] != "internal" {
+		t.Errorf("direct = %v", direct)
+	}
+	if len(transitive) != 2 || transitive[0] != "colorama" || transitive[1] != "werkzeug" {
+		t.Errorf("transitive = %v", transitive)
+	}
+
+	want := "# Pinned transitive dependencies\n" +
+		"colorama==0.4.6 ; sys_platform == \"win32\" \\\n    --hash=sha256:cc\n" +
+		"werkzeug==3.0.1 \\\n    --hash=sha256:bb\n"
+	if got := lf.RenderRequirements(transitive, "Pinned transitive dependencies\n"); got != want {
+		t.Errorf("transitive file =\n%s\nwant\n%s", got, want)
+	}
+	// internal has no hash, so neither line may carry one
+	want = "flask==3.0.0\ninternal @ https://example.com/internal-2.0-py3-none-any.whl\n"
+	if got := lf.RenderRequirements(direct, ""); got != want {
+		t.Errorf("direct file =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderRequirementsProvenance(t *testing.T) {
+	lf := NewLockfile("3.11")
+	lf.AddPackage("flask", LockPackage{Version: "3.0.0", Source: "pypi", Dependencies: map[string]string{"werkzeug": ">=3.0", "markupsafe": ">=2.1"}})
+	lf.AddPackage("jinja2", LockPackage{Version: "3.1.2", Source: "pypi", Dependencies: map[string]string{"MarkupSafe[speedups]": ">=2.0"}})
+	lf.AddPackage("werkzeug", LockPackage{Version: "3.0.1", Source: "pypi", Dependencies: map[string]string{"markupsafe": ">=2.1"}})
+	lf.AddPackage("markupsafe", LockPackage{Version: "2.1.3", Source: "pypi"})
+
+	want := "flask==3.0.0\n" +
+		"markupsafe==2.1.3\n    # via\n    #   flask\n    #   jinja2\n    #   werkzeug\n" +
+		"werkzeug==3.0.1\n    # via flask\n"
+	if got := lf.RenderRequirements([]string{"flask", "markupsafe", "werkzeug"}, ""); got != want {
+		t.Errorf("requirements =\n%s\nwant\n%s", got, want)
+	}
+}
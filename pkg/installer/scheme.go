@@ -0,0 +1,81 @@
+package installer
+
+import (
+	"path/filepath"
+	"runtime"
+)
+
+// Scheme is the set of directories a wheel is installed into, as in Python's
+// sysconfig install schemes. Without one the installer uses the virtual
+// environment's layout.
+type Scheme struct {
+	Purelib string
+	Platlib string
+	Scripts string
+	Data    string
+	// Headers holds one directory of C headers per distribution
+	Headers string
+	// Interpreter is the Python that script shebangs point at, sdists are
+	// built with and wheel tags are checked against
+	Interpreter string
+}
+
+// PrefixScheme returns the layout of a Python installed under prefix, such
+// as /usr or /opt/app, for an interpreter of version pythonMinor (e.g. 3.12):
+// the posix_prefix scheme, or nt on Windows
+func PrefixScheme(prefix, interpreter, pythonMinor string) Scheme {
+	if runtime.GOOS == "windows" {
+		return Scheme{
+			Purelib:     filepath.Join(prefix, "Lib", "site-packages"),
+			Platlib:     filepath.Join(prefix, "Lib", "site-packages"),
+			Scripts:     filepath.Join(prefix, "Scripts"),
+			Data:        prefix,
+			Headers:     filepath.Join(prefix, "Include"),
+			Interpreter: interpreter,
+		}
+	}
+	pythonDir := "python" + pythonMinor
+	return Scheme{
+		Purelib:     filepath.Join(prefix, "lib", pythonDir, "site-packages"),
+		Platlib:     filepath.Join(prefix, "lib", pythonDir, "site-packages"),
+		Scripts:     filepath.Join(prefix, "bin"),
+		Data:        prefix,
+		Headers:     filepath.Join(prefix, "include", pythonDir),
+		Interpreter: interpreter,
+	}
+}
+
+// Staged returns the scheme with its directories moved under root, for
+// building a tree that a package manager later installs at / (DESTDIR).
+// The interpreter is left alone, so scripts still name the installed path.
+func (s Scheme) Staged(root string) Scheme {
+	if root == "" {
+		return s
+	}
+	stage := func(dir string) string {
+		if volume := filepath.VolumeName(dir); volume != "" {
+			dir = dir[len(volume):]
+		}
+		return filepath.Join(root, dir)
+	}
+	s.Purelib = stage(s.Purelib)
+	s.Platlib = stage(s.Platlib)
+	s.Scripts = stage(s.Scripts)
+	s.Data = stage(s.Data)
+	s.Headers = stage(s.Headers)
+	return s
+}
+
+// SetScheme installs into the directories of scheme instead of the virtual
+// environment's, e.g. a PrefixScheme for packaging locked dependencies
+func (wi *WheelInstaller) SetScheme(scheme Scheme) {
+	wi.scheme = &scheme
+}
+
+// interpreter returns the Python the installed packages run with
+func (wi *WheelInstaller) interpreter() string {
+	if wi.scheme != nil {
+		return wi.scheme.Interpreter
+	}
+	return NewVirtualEnvironment(wi.venvPath).GetPythonPath()
+}
@@ -0,0 +1,60 @@
+package installer
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/fsutil"
+	"rimraf-adi.com/zephyr/pkg/markers"
+)
+
+func TestPrefixSchemeStaged(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("posix_prefix layout")
+	}
+	scheme := PrefixScheme("/usr", "/usr/bin/python3.12", "3.12").Staged("/build/root")
+	if scheme.Purelib != "/build/root/usr/lib/python3.12/site-packages" || scheme.Scripts != "/build/root/usr/bin" || scheme.Headers != "/build/root/usr/include/python3.12" || scheme.Data != "/build/root/usr" {
+		t.Errorf("unexpected staged scheme: %+v", scheme)
+	}
+	if scheme.Interpreter != "/usr/bin/python3.12" {
+		t.Errorf("staging should not move the interpreter, got %s", scheme.Interpreter)
+	}
+}
+
+func TestInstallWheel_PrefixScheme(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("posix_prefix layout")
+	}
+	dir := t.TempDir()
+	data, err := os.ReadFile(createScriptWheel(t, dir, "fabric", map[string]string{"fab": "fabric:main"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fsutil.NewMemFS()
+	fsys.MkdirAll("/build", 0755)
+	fsys.WriteFile("/build/fabric-1.0.0-py3-none-any.whl", data, 0644)
+	target, err := markers.ParseTarget("linux-amd64-3.12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wi := NewWheelInstaller("/build/root/usr")
+	wi.SetFS(fsys)
+	wi.SetTarget(target)
+	wi.SetScheme(PrefixScheme("/usr", "/usr/bin/python3.12", "3.12").Staged("/build/root"))
+	if err := wi.InstallWheel("/build/fabric-1.0.0-py3-none-any.whl", "fabric"); err != nil {
+		t.Fatalf("InstallWheel failed: %v", err)
+	}
+	if _, err := fsys.Stat("/build/root/usr/lib/python3.12/site-packages/fabric/__init__.py"); err != nil {
+		t.Errorf("package not installed into the staged prefix: %v", err)
+	}
+	launcher, err := fsys.ReadFile("/build/root/usr/bin/fab")
+	if err != nil {
+		t.Fatalf("launcher not written: %v", err)
+	}
+	if !strings.HasPrefix(string(launcher), "#!/usr/bin/python3.12\n") {
+		t.Errorf("launcher should run the installed interpreter, got:\n%s", launcher)
+	}
+}
@@ -0,0 +1,298 @@
+package installer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/fsutil"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// ScriptOwnerPrefix tags launchers with the distribution that wrote them
+const ScriptOwnerPrefix = "# zephyr-owner: "
+
+// EntryPoint is one entry from a distribution's entry_points.txt
+type EntryPoint struct {
+	Group  string
+	Name   string
+	Module string
+	Attr   string
+}
+
+// ParseEntryPoints parses the INI-style entry_points.txt format
+func ParseEntryPoints(content string) ([]EntryPoint, error) {
+	var entries []EntryPoint
+	group := ""
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid entry point on line %d: %q", lineNo, line)
+		}
+		// Drop any trailing [extras] annotation
+		if i := strings.Index(value, "["); i >= 0 {
+			value = value[:i]
+		}
+		module, attr, _ := strings.Cut(strings.TrimSpace(value), ":")
+		entries = append(entries, EntryPoint{
+			Group:  group,
+			Name:   strings.TrimSpace(name),
+			Module: strings.TrimSpace(module),
+			Attr:   strings.TrimSpace(attr),
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// ScriptConflictError reports two distributions providing the same launcher
+type ScriptConflictError struct {
+	Script   string
+	Existing string
+	Incoming string
+}
+
+func (e *ScriptConflictError) Error() string {
+	return fmt.Sprintf("console script %q is provided by both %s and %s. List the package that should own it under script-precedence in buildmeta.yaml", e.Script, e.Existing, e.Incoming)
+}
+
+// SetScriptPrecedence orders distributions by which wins a console script
+// collision; earlier entries win. Collisions between distributions that are
+// not listed fail the install.
+func (wi *WheelInstaller) SetScriptPrecedence(packages []string) {
+	wi.scriptPrecedence = packages
+}
+
+// scriptRank returns the position of a distribution in the precedence list, or -1
+func (wi *WheelInstaller) scriptRank(dist string) int {
+	for i, name := range wi.scriptPrecedence {
+		if pkgname.Normalize(name) == pkgname.Normalize(dist) {
+			return i
+		}
+	}
+	return -1
+}
+
+// installScripts writes launchers for the console and GUI scripts of a wheel
+func (wi *WheelInstaller) installScripts(metadata *WheelMetadata, createdPaths *[]string) error {
+	if metadata.EntryPoints == "" {
+		return nil
+	}
+	entries, err := ParseEntryPoints(metadata.EntryPoints)
+	if err != nil {
+		return fmt.Errorf("failed to parse entry_points.txt of %s: %w", metadata.Name, err)
+	}
+	binDir := wi.binPath()
+	for _, entry := range entries {
+		if entry.Group != "console_scripts" && entry.Group != "gui_scripts" {
+			continue
+		}
+		target := filepath.Join(binDir, entry.Name)
+		_, statErr := wi.fs.Stat(target)
+		exists := statErr == nil
+		if exists {
+			owner := wi.scriptOwner(entry.Name)
+			switch {
+			case owner == "":
+				fmt.Fprintf(os.Stderr, "[zephyr] Warning: Replacing %s, which is not owned by any installed package, with the launcher from %s\n", target, metadata.Name)
+			case pkgname.Normalize(owner) == pkgname.Normalize(metadata.Name):
+				// Reinstalling or upgrading the same distribution
+			default:
+				existingRank, incomingRank := wi.scriptRank(owner), wi.scriptRank(metadata.Name)
+				if existingRank < 0 && incomingRank < 0 {
+					return &ScriptConflictError{Script: entry.Name, Existing: owner, Incoming: metadata.Name}
+				}
+				if incomingRank < 0 || (existingRank >= 0 && existingRank < incomingRank) {
+					fmt.Fprintf(os.Stderr, "[zephyr] Warning: Keeping console script %s from %s; %s also provides it\n", entry.Name, owner, metadata.Name)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "[zephyr] Warning: Console script %s from %s replaces the one from %s\n", entry.Name, metadata.Name, owner)
+			}
+		}
+		if err := trackMkdirAll(wi.fs, binDir, 0755, createdPaths); err != nil {
+			return fmt.Errorf("failed to create scripts directory '%s': %w. Check permissions.", binDir, err)
+		}
+		if err := wi.fs.WriteFile(target, []byte(wi.launcher(entry, metadata.Name)), 0755); err != nil {
+			return fmt.Errorf("failed to write console script '%s': %w. Check permissions.", target, err)
+		}
+		if !exists {
+			*createdPaths = append(*createdPaths, target)
+		}
+	}
+	return nil
+}
+
+// launcher renders the Python launcher for an entry point
+func (wi *WheelInstaller) launcher(entry EntryPoint, dist string) string {
+	var b strings.Builder
+	b.WriteString(scriptShebang(wi.scriptPython(), ""))
+	b.WriteString("# -*- coding: utf-8 -*-\n")
+	b.WriteString(ScriptOwnerPrefix + dist + "\n")
+	b.WriteString("import re\nimport sys\n")
+	if entry.Attr == "" {
+		b.WriteString("import runpy\n")
+	} else {
+		importName, _, _ := strings.Cut(entry.Attr, ".")
+		fmt.Fprintf(&b, "from %s import %s\n", entry.Module, importName)
+	}
+	b.WriteString("if __name__ == \"__main__\":\n")
+	b.WriteString("    sys.argv[0] = re.sub(r\"(-script\\.pyw|\\.exe)?$\", \"\", sys.argv[0])\n")
+	if entry.Attr == "" {
+		fmt.Fprintf(&b, "    runpy.run_module(%q, run_name=\"__main__\")\n", entry.Module)
+	} else {
+		fmt.Fprintf(&b, "    sys.exit(%s())\n", entry.Attr)
+	}
+	return b.String()
+}
+
+// scriptOwner returns the distribution that owns a launcher in the scripts
+// directory: the one recorded in a zephyr launcher, otherwise the installed
+// distribution whose entry_points.txt declares it
+func (wi *WheelInstaller) scriptOwner(name string) string {
+	if data, err := wi.fs.ReadFile(filepath.Join(wi.binPath(), name)); err == nil {
+		for _, line := range strings.SplitN(string(data), "\n", 5) {
+			if strings.HasPrefix(line, ScriptOwnerPrefix) {
+				return strings.TrimSpace(strings.TrimPrefix(line, ScriptOwnerPrefix))
+			}
+		}
+	}
+	matches, _ := wi.fs.Glob(filepath.Join(wi.getSitePackagesPath(), "*.dist-info", "entry_points.txt"))
+	sort.Strings(matches)
+	for _, match := range matches {
+		data, err := wi.fs.ReadFile(match)
+		if err != nil {
+			continue
+		}
+		entries, err := ParseEntryPoints(string(data))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.Name == name && (entry.Group == "console_scripts" || entry.Group == "gui_scripts") {
+				dist, _, _ := strings.Cut(strings.TrimSuffix(filepath.Base(filepath.Dir(match)), ".dist-info"), "-")
+				return dist
+			}
+		}
+	}
+	return ""
+}
+
+// binPath returns the environment's scripts directory
+func (wi *WheelInstaller) binPath() string {
+	if wi.scheme != nil {
+		return wi.scheme.Scripts
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(wi.venvPath, "Scripts")
+	}
+	return filepath.Join(wi.venvPath, "bin")
+}
+
+// maxShebangLength is the longest "#!" line every supported kernel executes;
+// Linux truncated longer ones before 5.1
+const maxShebangLength = 127
+
+// pythonCommandPattern matches the interpreter names a shebang is rewritten for
+var pythonCommandPattern = regexp.MustCompile(`^python(\d+(\.\d+)?)?w?$`)
+
+// scriptPython returns the absolute path of the interpreter installed scripts run with
+func (wi *WheelInstaller) scriptPython() string {
+	python := wi.interpreter()
+	if abs, err := filepath.Abs(python); err == nil {
+		python = abs
+	}
+	return python
+}
+
+// scriptShebang returns the lines that make a script run with python and
+// args. When the "#!" line would be too long for the kernel or the path has
+// spaces, it is a /bin/sh preamble that execs python on the script instead,
+// which Python reads as a string literal, as pip writes.
+func scriptShebang(python, args string) string {
+	line := "#!" + python
+	if args != "" {
+		line += " " + args
+	}
+	if runtime.GOOS == "windows" || (len(line) <= maxShebangLength && !strings.ContainsAny(python, " \t")) {
+		return line + "\n"
+	}
+	exec := fmt.Sprintf("%q", python)
+	if args != "" {
+		exec += " " + args
+	}
+	return "#!/bin/sh\n'''exec' " + exec + ` "$0" "$@"` + "\n' '''\n"
+}
+
+// rewriteShebang points a script's Python shebang at python. Wheels mark the
+// scripts to rewrite with "#!python" or "#!pythonw"; shebangs naming a Python
+// through /usr/bin/env or by the build machine's path are normalized too, so
+// the script runs in the environment it is installed into. Other scripts,
+// such as shell scripts, are returned unchanged.
+func rewriteShebang(content []byte, python string) []byte {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return content
+	}
+	line, rest, _ := bytes.Cut(content, []byte("\n"))
+	fields := strings.Fields(strings.TrimSuffix(string(line[2:]), "\r"))
+	if len(fields) == 0 {
+		return content
+	}
+	command, args := fields[0], fields[1:]
+	if path.Base(command) == "env" {
+		// env options such as -S change how the line is split; leave those alone
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			return content
+		}
+		command, args = args[0], args[1:]
+	}
+	if !pythonCommandPattern.MatchString(path.Base(command)) {
+		return content
+	}
+	return append([]byte(scriptShebang(python, strings.Join(args, " "))), rest...)
+}
+
+// rewriteScripts rewrites the shebangs of the wheel's .data/scripts once they
+// are installed through fsys, records the rewritten files' hashes in place
+// of those the wheel lists and makes the scripts executable
+func (wi *WheelInstaller) rewriteScripts(fsys fsutil.FS, reader *zip.Reader, metadata *WheelMetadata) error {
+	python := wi.scriptPython()
+	for _, file := range reader.File {
+		if !isScriptPath(file.Name) || file.FileInfo().IsDir() {
+			continue
+		}
+		target := wi.installPath(file.Name, metadata)
+		content, err := fsys.ReadFile(target)
+		if err != nil {
+			return fmt.Errorf("failed to read script '%s': %w", target, err)
+		}
+		if rewritten := rewriteShebang(content, python); !bytes.Equal(rewritten, content) {
+			if err := fsys.WriteFile(target, rewritten, 0755); err != nil {
+				return fmt.Errorf("failed to rewrite the shebang of '%s': %w. Check permissions.", target, err)
+			}
+			metadata.FileHashes[wi.recordPath(target)] = recordEntry(rewritten)
+		}
+		// Copies from the cache do not keep the executable bit
+		if err := fsys.Chmod(target, 0755); err != nil {
+			return fmt.Errorf("failed to make script '%s' executable: %w. Check permissions.", target, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package installer
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/installer/wheeltest"
+)
+
+// createScriptWheel builds a wheel for dist that declares the given console scripts
+func createScriptWheel(t *testing.T, dir, dist string, scripts map[string]string) string {
+	entryPoints := "[console_scripts]\n"
+	for name, target := range scripts {
+		entryPoints += name + " = " + target + "\n"
+	}
+	return wheeltest.Wheel{Name: dist, Files: map[string]string{
+		dist + "-1.0.0.dist-info/entry_points.txt": entryPoints,
+		dist + "/__init__.py":                      "def main():\n    print('" + dist + "')\n",
+	}}.Build(t, dir)
+}
+
+func TestParseEntryPoints(t *testing.T) {
+	entries, err := ParseEntryPoints(`
+[console_scripts]
+fab = fabric.main:program.run
+serve = app.server [extra]
+
+[myplugin.hooks]
+thing = pkg.mod:Thing
+`)
+	if err != nil {
+		t.Fatalf("ParseEntryPoints failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+	if e := entries[0]; e.Group != "console_scripts" || e.Name != "fab" || e.Module != "fabric.main" || e.Attr != "program.run" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if e := entries[1]; e.Module != "app.server" || e.Attr != "" {
+		t.Errorf("unexpected module-only entry: %+v", e)
+	}
+	if _, err := ParseEntryPoints("fab = x:y\n"); err == nil {
+		t.Error("ParseEntryPoints should reject entries outside a section")
+	}
+}
+
+func TestInstallScripts(t *testing.T) {
+	dir := t.TempDir()
+	venvPath := filepath.Join(dir, "venv")
+	wi := NewWheelInstaller(venvPath)
+	if err := wi.InstallWheel(createScriptWheel(t, dir, "fabric", map[string]string{"fab": "fabric:main"}), "fabric"); err != nil {
+		t.Fatalf("InstallWheel failed: %v", err)
+	}
+	launcher := filepath.Join(wi.binPath(), "fab")
+	data, err := os.ReadFile(launcher)
+	if err != nil {
+		t.Fatalf("launcher not written: %v", err)
+	}
+	if !strings.Contains(string(data), "from fabric import main") || wi.scriptOwner("fab") != "fabric" {
+		t.Errorf("unexpected launcher:\n%s", data)
+	}
+	distInfo := filepath.Join(wi.getSitePackagesPath(), "fabric-1.0.0.dist-info", "entry_points.txt")
+	if _, err := os.Stat(distInfo); err != nil {
+		t.Errorf("entry_points.txt not installed: %v", err)
+	}
+
+	// Reinstalling the same distribution is not a collision
+	if err := wi.InstallWheel(createScriptWheel(t, dir, "fabric", map[string]string{"fab": "fabric:main"}), "fabric"); err != nil {
+		t.Errorf("reinstall failed: %v", err)
+	}
+
+	// Without a precedence the second provider fails and is rolled back
+	err = wi.InstallWheel(createScriptWheel(t, dir, "fab_classic", map[string]string{"fab": "fab_classic:main"}), "fab-classic")
+	var conflict *ScriptConflictError
+	if !errors.As(err, &conflict) || conflict.Existing != "fabric" || conflict.Incoming != "fab_classic" {
+		t.Fatalf("expected ScriptConflictError, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(wi.getSitePackagesPath(), "fab_classic")); err == nil {
+		t.Error("conflicting install should be rolled back")
+	}
+	if wi.scriptOwner("fab") != "fabric" {
+		t.Error("existing launcher should be kept after a failed install")
+	}
+
+	// A higher precedence replaces the launcher, a lower one keeps it
+	wi.SetScriptPrecedence([]string{"fab-classic", "fabric"})
+	if err := wi.InstallWheel(createScriptWheel(t, dir, "fab_classic", map[string]string{"fab": "fab_classic:main"}), "fab-classic"); err != nil {
+		t.Fatalf("InstallWheel with precedence failed: %v", err)
+	}
+	if owner := wi.scriptOwner("fab"); owner != "fab_classic" {
+		t.Errorf("owner = %s, expected fab_classic", owner)
+	}
+	wi.SetScriptPrecedence([]string{"fab-classic"})
+	if err := wi.InstallWheel(createScriptWheel(t, dir, "fabric", map[string]string{"fab": "fabric:main"}), "fabric"); err != nil {
+		t.Fatalf("InstallWheel with lower precedence failed: %v", err)
+	}
+	if owner := wi.scriptOwner("fab"); owner != "fab_classic" {
+		t.Errorf("owner = %s, expected fab_classic to be kept", owner)
+	}
+}
+
+func TestLauncherRuns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("launchers are POSIX scripts")
+	}
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not available")
+	}
+	dir := t.TempDir()
+	venvPath := filepath.Join(dir, "venv")
+	wi := NewWheelInstaller(venvPath)
+	if err := os.MkdirAll(wi.binPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(python, filepath.Join(wi.binPath(), "python")); err != nil {
+		t.Fatal(err)
+	}
+	if err := wi.InstallWheel(createScriptWheel(t, dir, "hello", map[string]string{"hello": "hello:main"}), "hello"); err != nil {
+		t.Fatalf("InstallWheel failed: %v", err)
+	}
+	cmd := exec.Command(filepath.Join(wi.binPath(), "hello"))
+	cmd.Env = append(os.Environ(), "PYTHONPATH="+wi.getSitePackagesPath())
+	out, err := cmd.CombinedOutput()
+	if err != nil || strings.TrimSpace(string(out)) != "hello" {
+		t.Errorf("launcher failed: %v\n%s", err, out)
+	}
+}
+
+func TestRewriteShebang(t *testing.T) {
+	python := "/srv/app/.venv/bin/python"
+	cases := map[string]string{
+		"#!python\nprint(1)\n":                       "#!/srv/app/.venv/bin/python\nprint(1)\n",
+		"#!pythonw -u\nprint(1)\n":                   "#!/srv/app/.venv/bin/python -u\nprint(1)\n",
+		"#!/usr/bin/env python3\r\nprint(1)\n":       "#!/srv/app/.venv/bin/python\nprint(1)\n",
+		"#!/opt/build/bin/python3.11 -E\nprint(1)\n": "#!/srv/app/.venv/bin/python -E\nprint(1)\n",
+		"#!/bin/sh\necho hi\n":                       "#!/bin/sh\necho hi\n",
+		"#!/usr/bin/env -S python3 -u\nprint(1)\n":   "#!/usr/bin/env -S python3 -u\nprint(1)\n",
+		"print(1)\n": "print(1)\n",
+	}
+	for in, want := range cases {
+		if got := string(rewriteShebang([]byte(in), python)); got != want {
+			t.Errorf("rewriteShebang(%q) = %q, want %q", in, got, want)
+		}
+	}
+	long := "/" + strings.Repeat("very-long-directory/", 8) + "bin/python"
+	got := string(rewriteShebang([]byte("#!python\nprint(1)\n"), long))
+	if !strings.HasPrefix(got, "#!/bin/sh\n'''exec' \""+long+"\" \"$0\" \"$@\"\n' '''\n") || !strings.HasSuffix(got, "\nprint(1)\n") {
+		t.Errorf("long interpreter paths should use the /bin/sh preamble, got:\n%s", got)
+	}
+}
+
+func TestInstallDataScripts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("scripts run through shebangs")
+	}
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not available")
+	}
+	// A path too long for a "#!" line exercises the /bin/sh preamble
+	dir := filepath.Join(t.TempDir(), strings.Repeat("deep", 30))
+	venvPath := filepath.Join(dir, "venv")
+	wi := NewWheelInstaller(venvPath)
+	if err := os.MkdirAll(wi.binPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(python, filepath.Join(wi.binPath(), "python")); err != nil {
+		t.Fatal(err)
+	}
+	wheelPath := wheeltest.Wheel{Name: "demo", Files: map[string]string{
+		"demo-1.0.0.data/scripts/demo-tool": "#!python\nimport sys\nprint('ran with', sys.argv[1])\n",
+	}}.Build(t, dir)
+	if err := wi.InstallWheel(wheelPath, "demo"); err != nil {
+		t.Fatalf("InstallWheel failed: %v", err)
+	}
+	script := filepath.Join(wi.binPath(), "demo-tool")
+	out, err := exec.Command(script, "arg").CombinedOutput()
+	if err != nil || strings.TrimSpace(string(out)) != "ran with arg" {
+		t.Errorf("rewritten script failed: %v\n%s", err, out)
+	}
+	content, _ := os.ReadFile(script)
+	record, _ := os.ReadFile(filepath.Join(wi.getSitePackagesPath(), "demo-1.0.0.dist-info", "RECORD"))
+	if !strings.Contains(string(record), ","+recordEntry(content)) {
+		t.Errorf("RECORD should hold the rewritten script's hash:\n%s", record)
+	}
+}
@@ -0,0 +1,95 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/cache"
+	"rimraf-adi.com/zephyr/pkg/remedy"
+)
+
+// buildOptionVariables are the environment variables that change what a build
+// backend compiles, and so are part of a built wheel's cache key
+var buildOptionVariables = []string{
+	"CC", "CXX", "CFLAGS", "CXXFLAGS", "CPPFLAGS", "LDFLAGS",
+	"ARCHFLAGS", "MACOSX_DEPLOYMENT_TARGET", "PIP_CONFIG_SETTINGS",
+}
+
+// buildWheelFromSdist builds sdist into a wheel in dir with python
+var buildWheelFromSdist = func(python, sdist, dir string) error {
+	cmd := exec.Command(python, "-m", "pip", "wheel", "--no-deps", "--no-cache-dir", "--wheel-dir", dir, sdist)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("pip wheel failed: %w\n%s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// interpreterPlatform returns the full version and sysconfig platform of python
+var interpreterPlatform = func(python string) (string, string, error) {
+	output, err := exec.Command(python, "-c", "import platform, sysconfig; print(platform.python_version()); print(sysconfig.get_platform())").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to query %s: %w", python, err)
+	}
+	lines := strings.Fields(string(output))
+	if len(lines) != 2 {
+		return "", "", fmt.Errorf("unexpected output from %s: %q", python, strings.TrimSpace(string(output)))
+	}
+	return lines[0], lines[1], nil
+}
+
+// SetBuiltWheelCache overrides the cache of wheels built from sdists
+func (wi *WheelInstaller) SetBuiltWheelCache(c *cache.BuiltWheelCache) {
+	wi.built = c
+}
+
+// buildFromSdist turns a cached sdist into a wheel with the environment's
+// interpreter and returns the wheel's path in the artifact cache. Builds are
+// cached by the sdist digest, interpreter version, platform and build
+// options, so later syncs and other projects reuse them.
+func (wi *WheelInstaller) buildFromSdist(sdistPath, filename, packageName, version string) (string, error) {
+	if wi.target != nil {
+		return "", fmt.Errorf("%s %s publishes no wheel for %s and an sdist can only be built for the running interpreter", packageName, version, wi.target)
+	}
+	python := wi.interpreter()
+	pythonVersion, platform, err := interpreterPlatform(python)
+	if err != nil {
+		return "", err
+	}
+	key := cache.BuildKey{SdistSHA256: filepath.Base(sdistPath), Python: pythonVersion, Platform: platform}
+	for _, name := range buildOptionVariables {
+		if value, ok := os.LookupEnv(name); ok {
+			key.Options = append(key.Options, name+"="+value)
+		}
+	}
+
+	wheelPath, ok := wi.built.Lookup(key)
+	if ok {
+		fmt.Fprintf(os.Stderr, "[zephyr] Using cached build of %s\n", filename)
+	} else {
+		fmt.Fprintf(os.Stderr, "[zephyr] Building wheel for %s %s from %s...\n", packageName, version, filename)
+		wheelPath, err = wi.built.Ensure(key, func(dir string) error {
+			// Build backends need the sdist under its published name
+			source, err := os.MkdirTemp("", "zephyr-sdist-*")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(source)
+			sdist := filepath.Join(source, filename)
+			if err := cache.LinkFile(sdistPath, sdist, cache.LinkModeHardlink); err != nil {
+				return err
+			}
+			return buildWheelFromSdist(python, sdist, dir)
+		})
+		if err != nil {
+			return "", remedy.Annotate(fmt.Errorf("failed to build %s: %w", filename, err))
+		}
+	}
+	digest, err := wi.cache.StoreFile(wheelPath, "")
+	if err != nil {
+		return "", err
+	}
+	return wi.cache.Path(digest), nil
+}
@@ -0,0 +1,58 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/cache"
+	"rimraf-adi.com/zephyr/pkg/markers"
+)
+
+func TestBuildFromSdist(t *testing.T) {
+	defer func(build func(string, string, string) error, platform func(string) (string, string, error)) {
+		buildWheelFromSdist, interpreterPlatform = build, platform
+	}(buildWheelFromSdist, interpreterPlatform)
+	interpreterPlatform = func(string) (string, string, error) { return "3.11.4", "linux-x86_64", nil }
+	var built []string
+	buildWheelFromSdist = func(python, sdist, dir string) error {
+		if filepath.Base(sdist) != "native-1.0.tar.gz" {
+			t.Errorf("sdist should keep its published name, got %s", sdist)
+		}
+		built = append(built, os.Getenv("CFLAGS"))
+		return os.WriteFile(filepath.Join(dir, "native-1.0-cp311-cp311-linux_x86_64.whl"), []byte("built with "+os.Getenv("CFLAGS")), 0644)
+	}
+
+	wi := NewWheelInstaller(t.TempDir())
+	wi.SetCache(cache.NewArtifactCache(t.TempDir()))
+	wi.SetBuiltWheelCache(cache.NewBuiltWheelCache(t.TempDir()))
+	digest, err := wi.cache.Store(strings.NewReader("sdist"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CFLAGS", "-O2")
+	first, err := wi.buildFromSdist(wi.cache.Path(digest), "native-1.0.tar.gz", "native", "1.0")
+	if err != nil {
+		t.Fatalf("buildFromSdist failed: %v", err)
+	}
+	if data, _ := os.ReadFile(first); string(data) != "built with -O2" {
+		t.Errorf("Built wheel should be in the artifact cache, got %q", data)
+	}
+	if _, err := wi.buildFromSdist(wi.cache.Path(digest), "native-1.0.tar.gz", "native", "1.0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CFLAGS", "-O3")
+	if _, err := wi.buildFromSdist(wi.cache.Path(digest), "native-1.0.tar.gz", "native", "1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(built, ",") != "-O2,-O3" {
+		t.Errorf("Expected one build per set of options, got %v", built)
+	}
+
+	wi.SetTarget(markers.Target{OS: "windows", Arch: "x86_64", Python: "3.11"})
+	if _, err := wi.buildFromSdist(wi.cache.Path(digest), "native-1.0.tar.gz", "native", "1.0"); err == nil {
+		t.Error("Building for another target should fail")
+	}
+}
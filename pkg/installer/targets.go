@@ -0,0 +1,203 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"rimraf-adi.com/zephyr/pkg/markers"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+	"rimraf-adi.com/zephyr/pkg/pypi"
+	"rimraf-adi.com/zephyr/pkg/solver"
+)
+
+// MetadataFetcher fetches release metadata for a single package version
+type MetadataFetcher interface {
+	FetchVersionMetadata(packageName, version string) (*pypi.PyPIMetadata, error)
+}
+
+// TargetResolution is the set of locked packages needed on one target and the artifact chosen for each
+type TargetResolution struct {
+	Target    markers.Target
+	Artifacts map[string]string
+}
+
+// ResolveTargets evaluates dependency markers and wheel tags of the locked packages for every
+// target concurrently, then records on each package which targets need it and which file to use
+func (lf *Lockfile) ResolveTargets(fetcher MetadataFetcher, roots []string, targets []markers.Target) error {
+	metadata, err := lf.fetchLockedMetadata(fetcher)
+	if err != nil {
+		return err
+	}
+
+	results := make([]*TargetResolution, len(targets))
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target markers.Target) {
+			defer wg.Done()
+			results[i], errs[i] = lf.resolveTarget(metadata, roots, target)
+		}(i, target)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	// Merge in target order so the lockfile is identical regardless of scheduling
+	lf.Targets = make([]string, 0, len(targets))
+	for name, pkg := range lf.Packages {
+		pkg.Targets = nil
+		pkg.Wheels = nil
+		lf.Packages[name] = pkg
+	}
+	for _, result := range results {
+		targetName := result.Target.String()
+		lf.Targets = append(lf.Targets, targetName)
+		for name, artifact := range result.Artifacts {
+			pkg := lf.Packages[name]
+			pkg.Targets = append(pkg.Targets, targetName)
+			if pkg.Wheels == nil {
+				pkg.Wheels = make(map[string]string)
+			}
+			pkg.Wheels[targetName] = artifact
+			lf.Packages[name] = pkg
+		}
+	}
+	return nil
+}
+
+// fetchLockedMetadata fetches metadata for every locked package concurrently
+func (lf *Lockfile) fetchLockedMetadata(fetcher MetadataFetcher) (map[string]*pypi.PyPIMetadata, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	metadata := make(map[string]*pypi.PyPIMetadata, len(lf.Packages))
+	for name, pkg := range lf.Packages {
+		wg.Add(1)
+		go func(name, version string) {
+			defer wg.Done()
+			meta, err := fetcher.FetchVersionMetadata(name, version)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to fetch metadata for %s %s: %w", name, version, err))
+				return
+			}
+			metadata[pkgname.Normalize(name)] = meta
+		}(name, pkg.Version)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return metadata, nil
+}
+
+// resolveTarget walks the dependency graph from roots, following only requirements whose
+// markers hold on the target, and picks a compatible artifact for each reached package
+func (lf *Lockfile) resolveTarget(metadata map[string]*pypi.PyPIMetadata, roots []string, target markers.Target) (*TargetResolution, error) {
+	env := target.Environment()
+	lockedNames := make(map[string]string, len(lf.Packages))
+	for name := range lf.Packages {
+		lockedNames[pkgname.Normalize(name)] = name
+	}
+
+	result := &TargetResolution{Target: target, Artifacts: make(map[string]string)}
+	visited := make(map[string]bool)
+	var queue []string
+	for _, root := range roots {
+		queue = append(queue, solver.ExpandExtras(root)...)
+	}
+	for len(queue) > 0 {
+		base, extra, _ := solver.SplitExtraPackage(queue[0])
+		queue = queue[1:]
+		name, ok := lockedNames[pkgname.Normalize(base)]
+		if !ok || visited[solver.ExtraPackage(name, extra)] {
+			continue
+		}
+		visited[solver.ExtraPackage(name, extra)] = true
+		meta := metadata[pkgname.Normalize(name)]
+		if extra != "" {
+			// An extra pulls in its base package plus the requirements guarded by it
+			queue = append(queue, name)
+		} else {
+			artifact, err := selectArtifact(meta.URLs, target)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s on %s: %w", name, lf.Packages[name].Version, target, err)
+			}
+			result.Artifacts[name] = artifact
+		}
+		extraEnv := make(markers.Environment, len(env))
+		for k, v := range env {
+			extraEnv[k] = v
+		}
+		extraEnv["extra"] = extra
+		for _, requirement := range meta.Info.RequiresDist {
+			spec, marker := markers.SplitRequirement(requirement)
+			applies, err := markers.Evaluate(marker, extraEnv)
+			if err != nil {
+				return nil, fmt.Errorf("%s requirement '%s': %w", name, requirement, err)
+			}
+			if applies {
+				queue = append(queue, solver.ExpandExtras(requirementWithExtras(spec))...)
+			}
+		}
+	}
+	return result, nil
+}
+
+// selectArtifact picks the most specific compatible wheel for target, falling back to an sdist
+func selectArtifact(releases []pypi.Release, target markers.Target) (string, error) {
+	release, err := selectRelease(releases, target)
+	if err != nil {
+		return "", err
+	}
+	return release.Filename, nil
+}
+
+// selectRelease is selectArtifact returning the whole release
+func selectRelease(releases []pypi.Release, target markers.Target) (*pypi.Release, error) {
+	var candidates []*pypi.Release
+	var sdist *pypi.Release
+	for i, release := range releases {
+		if release.Yanked {
+			continue
+		}
+		switch release.Packagetype {
+		case "bdist_wheel":
+			wheel, err := pypi.ParseWheelFilename(release.Filename)
+			if err == nil && wheel.CompatibleWith(target) {
+				candidates = append(candidates, &releases[i])
+			}
+		case "sdist":
+			sdist = &releases[i]
+		}
+	}
+	if len(candidates) > 0 {
+		// Platform specific wheels win over pure-python ones
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return !strings.HasSuffix(candidates[i].Filename, "-any.whl") && strings.HasSuffix(candidates[j].Filename, "-any.whl")
+		})
+		return candidates[0], nil
+	}
+	if sdist != nil {
+		return sdist, nil
+	}
+	return nil, pypi.NewNoCompatibleArtifactError("", "", releases, target)
+}
+
+// requirementWithExtras strips the version specifier from a requirement, keeping "name[extras]"
+func requirementWithExtras(spec string) string {
+	name := markers.RequirementName(spec)
+	rest := strings.TrimSpace(spec[len(name):])
+	if strings.HasPrefix(rest, "[") {
+		if end := strings.Index(rest, "]"); end > 0 {
+			return name + strings.ReplaceAll(rest[:end+1], " ", "")
+		}
+	}
+	return name
+}
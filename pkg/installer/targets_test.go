@@ -0,0 +1,133 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/markers"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+	"rimraf-adi.com/zephyr/pkg/pypi"
+)
+
+type fakeFetcher map[string]*pypi.PyPIMetadata
+
+func (f fakeFetcher) FetchVersionMetadata(name, version string) (*pypi.PyPIMetadata, error) {
+	if meta, ok := f[pkgname.Normalize(name)]; ok {
+		return meta, nil
+	}
+	return nil, fmt.Errorf("package %s not found", name)
+}
+
+func wheelRelease(filename string) pypi.Release {
+	return pypi.Release{Filename: filename, Packagetype: "bdist_wheel"}
+}
+
+func TestLockfileResolveTargets(t *testing.T) {
+	fetcher := fakeFetcher{
+		"app-lib": {
+			Info: pypi.PackageInfo{RequiresDist: []string{
+				`colorama>=0.4 ; sys_platform == "win32"`,
+				`uvloop ; sys_platform != "win32"`,
+				`pytest ; extra == "test"`,
+			}},
+			URLs: []pypi.Release{wheelRelease("app_lib-1.0-py3-none-any.whl")},
+		},
+		"colorama": {URLs: []pypi.Release{wheelRelease("colorama-0.4.6-py2.py3-none-any.whl")}},
+		"uvloop": {URLs: []pypi.Release{
+			wheelRelease("uvloop-0.19.0-cp311-cp311-manylinux_2_17_x86_64.whl"),
+			wheelRelease("uvloop-0.19.0-cp311-cp311-macosx_10_9_universal2.whl"),
+		}},
+		"pytest": {URLs: []pypi.Release{wheelRelease("pytest-8.0.0-py3-none-any.whl")}},
+	}
+	lf := NewLockfile("3.11")
+	for name := range fetcher {
+		lf.AddPackage(name, LockPackage{Version: "1.0", Source: "pypi"})
+	}
+	targets := []markers.Target{
+		{OS: "linux", Arch: "x86_64", Python: "3.11"},
+		{OS: "windows", Arch: "x86_64", Python: "3.11"},
+		{OS: "macos", Arch: "arm64", Python: "3.11"},
+	}
+	if err := lf.ResolveTargets(fetcher, []string{"App_Lib"}, targets); err != nil {
+		t.Fatalf("ResolveTargets failed: %v", err)
+	}
+	if !reflect.DeepEqual(lf.Targets, []string{"linux-x86_64-3.11", "windows-x86_64-3.11", "macos-arm64-3.11"}) {
+		t.Errorf("Unexpected targets: %v", lf.Targets)
+	}
+	if got := lf.Packages["colorama"].Targets; !reflect.DeepEqual(got, []string{"windows-x86_64-3.11"}) {
+		t.Errorf("colorama should only be needed on windows, got %v", got)
+	}
+	uvloop := lf.Packages["uvloop"]
+	if !reflect.DeepEqual(uvloop.Targets, []string{"linux-x86_64-3.11", "macos-arm64-3.11"}) {
+		t.Errorf("Unexpected uvloop targets: %v", uvloop.Targets)
+	}
+	if uvloop.Wheels["macos-arm64-3.11"] != "uvloop-0.19.0-cp311-cp311-macosx_10_9_universal2.whl" {
+		t.Errorf("Unexpected macOS wheel: %v", uvloop.Wheels)
+	}
+	if len(lf.Packages["pytest"].Targets) != 0 {
+		t.Errorf("Extra-only dependency should not be selected, got %v", lf.Packages["pytest"].Targets)
+	}
+}
+
+func TestLockfileResolveTargetsNoCompatibleWheel(t *testing.T) {
+	fetcher := fakeFetcher{
+		"uvloop": {URLs: []pypi.Release{wheelRelease("uvloop-0.19.0-cp311-cp311-manylinux_2_17_x86_64.whl")}},
+	}
+	lf := NewLockfile("3.11")
+	lf.AddPackage("uvloop", LockPackage{Version: "0.19.0"})
+	err := lf.ResolveTargets(fetcher, []string{"uvloop"}, []markers.Target{{OS: "windows", Arch: "x86_64", Python: "3.11"}})
+	if err == nil {
+		t.Error("Expected error when no artifact matches the target")
+	}
+}
+
+func TestLockfileResolveTargetsFollowsExtras(t *testing.T) {
+	fetcher := fakeFetcher{
+		"requests": {
+			Info: pypi.PackageInfo{RequiresDist: []string{`PySocks!=1.5.7 ; extra == "socks"`}},
+			URLs: []pypi.Release{wheelRelease("requests-2.31.0-py3-none-any.whl")},
+		},
+		"pysocks": {URLs: []pypi.Release{wheelRelease("PySocks-1.7.1-py3-none-any.whl")}},
+	}
+	lf := NewLockfile("3.11")
+	lf.AddPackage("requests", LockPackage{Version: "2.31.0"})
+	lf.AddPackage("PySocks", LockPackage{Version: "1.7.1"})
+	target := markers.Target{OS: "linux", Arch: "x86_64", Python: "3.11"}
+	if err := lf.ResolveTargets(fetcher, []string{"requests[socks]"}, []markers.Target{target}); err != nil {
+		t.Fatalf("ResolveTargets failed: %v", err)
+	}
+	if len(lf.Packages["PySocks"].Targets) != 1 || len(lf.Packages["requests"].Targets) != 1 {
+		t.Errorf("Expected extra and base to be selected, got %+v", lf.Packages)
+	}
+}
+
+func TestChooseRelease(t *testing.T) {
+	wi := NewWheelInstaller(t.TempDir())
+	linux := markers.Target{OS: "linux", Arch: "x86_64", Python: "3.11"}
+	releases := []pypi.Release{
+		wheelRelease("pkg-1.0-py3-none-any.whl"),
+		wheelRelease("pkg-1.0-cp311-cp311-manylinux_2_17_x86_64.whl"),
+	}
+	// The first wheel is kept while it fits, so pinned hashes stay valid
+	if release, err := wi.chooseRelease(releases, "pkg", "1.0", linux); err != nil || release.Filename != "pkg-1.0-py3-none-any.whl" {
+		t.Errorf("chooseRelease = %v, %v", release, err)
+	}
+	releases = []pypi.Release{
+		wheelRelease("pkg-1.0-cp311-cp311-macosx_11_0_arm64.whl"),
+		wheelRelease("pkg-1.0-cp311-cp311-manylinux_2_17_x86_64.whl"),
+		{Filename: "pkg-1.0.tar.gz", Packagetype: "sdist"},
+	}
+	if release, err := wi.chooseRelease(releases, "pkg", "1.0", linux); err != nil || release.Filename != "pkg-1.0-cp311-cp311-manylinux_2_17_x86_64.whl" {
+		t.Errorf("chooseRelease = %v, %v", release, err)
+	}
+	if release, err := wi.chooseRelease(releases, "pkg", "1.0", markers.Target{OS: "windows", Arch: "x86_64", Python: "3.11"}); err != nil || release.Packagetype != "sdist" {
+		t.Errorf("Expected the sdist fallback, got %v, %v", release, err)
+	}
+	_, err := wi.chooseRelease(releases[:2], "pkg", "1.0", markers.Target{OS: "windows", Arch: "x86_64", Python: "3.11"})
+	var noMatch *pypi.NoCompatibleArtifactError
+	if !errors.As(err, &noMatch) || noMatch.Package != "pkg" || len(noMatch.Wheels) != 2 {
+		t.Errorf("Expected a NoCompatibleArtifactError naming the package, got %v", err)
+	}
+}
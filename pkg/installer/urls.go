@@ -0,0 +1,144 @@
+package installer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+	"rimraf-adi.com/zephyr/pkg/pypi"
+)
+
+// LockedArtifact is the file a locked package is installed from
+type LockedArtifact struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Target is the os-arch-python target the artifact was chosen for, or ""
+	// for the artifact installed on the current machine
+	Target   string `json:"target,omitempty"`
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+	SHA256   string `json:"sha256,omitempty"`
+}
+
+// Artifacts lists the file each locked package, except exclude, installs
+// from, sorted by name. Packages locked for targets get one artifact per
+// target; the rest get the file zephyr sync would download. Index packages
+// are looked up through fetcher, while URL and file packages use their
+// locked location.
+func (lf *Lockfile) Artifacts(fetcher MetadataFetcher, exclude string) ([]LockedArtifact, error) {
+	indexed := &Lockfile{Packages: make(map[string]LockPackage)}
+	var names []string
+	for name, pkg := range lf.Packages {
+		if pkgname.Normalize(name) == pkgname.Normalize(exclude) {
+			continue
+		}
+		names = append(names, name)
+		if pkg.Source != SourceURL && pkg.Source != SourceFile {
+			indexed.Packages[name] = pkg
+		}
+	}
+	sort.Strings(names)
+	metadata, err := indexed.fetchLockedMetadata(fetcher)
+	if err != nil {
+		return nil, err
+	}
+
+	var artifacts []LockedArtifact
+	for _, name := range names {
+		pkg := lf.Packages[name]
+		if _, ok := indexed.Packages[name]; !ok {
+			artifacts = append(artifacts, LockedArtifact{
+				Name:     name,
+				Version:  pkg.Version,
+				Filename: pkg.URL[strings.LastIndexAny(pkg.URL, `/\`)+1:],
+				URL:      pkg.URL,
+				SHA256:   strings.TrimPrefix(pkg.Hash, lockHashPrefix),
+			})
+			continue
+		}
+		releases := metadata[pkgname.Normalize(name)].URLs
+		if len(pkg.Wheels) == 0 {
+			release, err := installedRelease(releases)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", name, pkg.Version, err)
+			}
+			artifacts = append(artifacts, releaseArtifact(name, pkg, "", release))
+			continue
+		}
+		for _, target := range pkg.Targets {
+			release, err := releaseNamed(releases, pkg.Wheels[target])
+			if err != nil {
+				return nil, fmt.Errorf("%s %s on %s: %w", name, pkg.Version, target, err)
+			}
+			artifacts = append(artifacts, releaseArtifact(name, pkg, target, release))
+		}
+	}
+	return artifacts, nil
+}
+
+// installedRelease picks the first wheel, which InstallWheelFromPyPI installs
+// wherever it is compatible, or failing that the sdist
+func installedRelease(releases []pypi.Release) (pypi.Release, error) {
+	for _, packagetype := range []string{"bdist_wheel", "sdist"} {
+		for _, release := range releases {
+			if release.Packagetype == packagetype {
+				return release, nil
+			}
+		}
+	}
+	return pypi.Release{}, fmt.Errorf("no wheel or sdist published")
+}
+
+// releaseNamed finds the file a target resolution recorded
+func releaseNamed(releases []pypi.Release, filename string) (pypi.Release, error) {
+	for _, release := range releases {
+		if release.Filename == filename {
+			return release, nil
+		}
+	}
+	return pypi.Release{}, fmt.Errorf("%s is no longer published", filename)
+}
+
+// releaseArtifact describes release, preferring the index's digest over the
+// one pinned in the lockfile
+func releaseArtifact(name string, pkg LockPackage, target string, release pypi.Release) LockedArtifact {
+	digest := strings.ToLower(release.Digests.SHA256)
+	if digest == "" && target == "" {
+		digest = strings.TrimPrefix(pkg.Hash, lockHashPrefix)
+	}
+	return LockedArtifact{
+		Name:     name,
+		Version:  pkg.Version,
+		Target:   target,
+		Filename: release.Filename,
+		URL:      release.URL,
+		SHA256:   digest,
+	}
+}
+
+// WriteArtifacts writes artifacts to w as an indented JSON array or, for
+// format "csv", as CSV with a header row
+func WriteArtifacts(w io.Writer, artifacts []LockedArtifact, format string) error {
+	switch format {
+	case "json":
+		if artifacts == nil {
+			artifacts = []LockedArtifact{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(artifacts)
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"name", "version", "target", "filename", "url", "sha256"})
+		for _, a := range artifacts {
+			writer.Write([]string{a.Name, a.Version, a.Target, a.Filename, a.URL, a.SHA256})
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("unknown format %q (expected json or csv)", format)
+}
@@ -0,0 +1,77 @@
+package installer
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/pypi"
+)
+
+func TestLockfileArtifacts(t *testing.T) {
+	fetcher := fakeFetcher{
+		"requests": {URLs: []pypi.Release{
+			{Filename: "requests-2.31.0.tar.gz", URL: "https://files.example/requests-2.31.0.tar.gz", Packagetype: "sdist"},
+			{Filename: "requests-2.31.0-py3-none-any.whl", URL: "https://files.example/requests-2.31.0-py3-none-any.whl", Packagetype: "bdist_wheel", Digests: pypi.Digests{SHA256: "AA"}},
+		}},
+		"uvloop": {URLs: []pypi.Release{
+			{Filename: "uvloop-0.19.0-cp311-cp311-manylinux_2_17_x86_64.whl", URL: "https://files.example/linux.whl", Packagetype: "bdist_wheel", Digests: pypi.Digests{SHA256: "bb"}},
+			{Filename: "uvloop-0.19.0-cp311-cp311-macosx_10_9_universal2.whl", URL: "https://files.example/macos.whl", Packagetype: "bdist_wheel", Digests: pypi.Digests{SHA256: "cc"}},
+		}},
+	}
+	lf := NewLockfile("3.11")
+	lf.AddPackage("myapp", LockPackage{Version: "0.1.0", Source: "pypi"})
+	lf.AddPackage("requests", LockPackage{Version: "2.31.0", Source: "pypi"})
+	lf.AddPackage("uvloop", LockPackage{
+		Version: "0.19.0",
+		Source:  "pypi",
+		Targets: []string{"linux-x86_64-3.11", "macos-arm64-3.11"},
+		Wheels: map[string]string{
+			"linux-x86_64-3.11": "uvloop-0.19.0-cp311-cp311-manylinux_2_17_x86_64.whl",
+			"macos-arm64-3.11":  "uvloop-0.19.0-cp311-cp311-macosx_10_9_universal2.whl",
+		},
+	})
+	lf.AddPackage("internal", LockPackage{Version: "2.0", Source: SourceURL, URL: "https://example.com/wheels/internal-2.0-py3-none-any.whl", Hash: "sha256:dd"})
+
+	artifacts, err := lf.Artifacts(fetcher, "MyApp")
+	if err != nil {
+		t.Fatalf("Artifacts failed: %v", err)
+	}
+	want := []LockedArtifact{
+		{Name: "internal", Version: "2.0", Filename: "internal-2.0-py3-none-any.whl", URL: "https://example.com/wheels/internal-2.0-py3-none-any.whl", SHA256: "dd"},
+		{Name: "requests", Version: "2.31.0", Filename: "requests-2.31.0-py3-none-any.whl", URL: "https://files.example/requests-2.31.0-py3-none-any.whl", SHA256: "aa"},
+		{Name: "uvloop", Version: "0.19.0", Target: "linux-x86_64-3.11", Filename: "uvloop-0.19.0-cp311-cp311-manylinux_2_17_x86_64.whl", URL: "https://files.example/linux.whl", SHA256: "bb"},
+		{Name: "uvloop", Version: "0.19.0", Target: "macos-arm64-3.11", Filename: "uvloop-0.19.0-cp311-cp311-macosx_10_9_universal2.whl", URL: "https://files.example/macos.whl", SHA256: "cc"},
+	}
+	if !reflect.DeepEqual(artifacts, want) {
+		t.Errorf("Artifacts =\n%+v\nwant\n%+v", artifacts, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteArtifacts(&buf, artifacts[:2], "csv"); err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "name,version,target,filename,url,sha256\n" +
+		"internal,2.0,,internal-2.0-py3-none-any.whl,https://example.com/wheels/internal-2.0-py3-none-any.whl,dd\n" +
+		"requests,2.31.0,,requests-2.31.0-py3-none-any.whl,https://files.example/requests-2.31.0-py3-none-any.whl,aa\n"
+	if buf.String() != wantCSV {
+		t.Errorf("CSV =\n%s\nwant\n%s", buf.String(), wantCSV)
+	}
+	buf.Reset()
+	if err := WriteArtifacts(&buf, nil, "json"); err != nil || strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("empty JSON = %q, %v", buf.String(), err)
+	}
+	if err := WriteArtifacts(&buf, nil, "xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestLockfileArtifactsUnpublished(t *testing.T) {
+	lf := NewLockfile("3.11")
+	lf.AddPackage("uvloop", LockPackage{Version: "0.19.0", Source: "pypi", Targets: []string{"linux-x86_64-3.11"}, Wheels: map[string]string{"linux-x86_64-3.11": "gone.whl"}})
+	fetcher := fakeFetcher{"uvloop": {URLs: []pypi.Release{wheelRelease("other.whl")}}}
+	if _, err := lf.Artifacts(fetcher, ""); err == nil || !strings.Contains(err.Error(), "gone.whl") {
+		t.Errorf("expected an error naming the missing file, got %v", err)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/pkgname"
 )
 
 // VirtualEnvironment represents a Python virtual environment
@@ -27,6 +29,11 @@ func (venv *VirtualEnvironment) Create() error {
 	if err != nil {
 		return fmt.Errorf("Python not found: %w. Please install Python 3.7+ and ensure it is in your PATH.", err)
 	}
+	return venv.CreateWithPython(pythonCmd)
+}
+
+// CreateWithPython creates the virtual environment with the given interpreter
+func (venv *VirtualEnvironment) CreateWithPython(pythonCmd string) error {
 	cmd := exec.Command(pythonCmd, "-m", "venv", venv.Path)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -57,6 +64,22 @@ func (venv *VirtualEnvironment) Activate() error {
 	return nil
 }
 
+// ActivateEnv applies the activation variables to env instead of the current process
+func (venv *VirtualEnvironment) ActivateEnv(env map[string]string) {
+	absPath, err := filepath.Abs(venv.Path)
+	if err != nil {
+		absPath = venv.Path
+	}
+	binDir := filepath.Join(absPath, filepath.Base(venv.GetBinPath()))
+	env["VIRTUAL_ENV"] = absPath
+	if current := env["PATH"]; current != "" {
+		env["PATH"] = binDir + string(os.PathListSeparator) + current
+	} else {
+		env["PATH"] = binDir
+	}
+	delete(env, "PYTHONHOME")
+}
+
 // Deactivate deactivates the virtual environment
 func (venv *VirtualEnvironment) Deactivate() error {
 	// Restore original environment variables
@@ -174,6 +197,31 @@ func (venv *VirtualEnvironment) UninstallPackage(packageName string) error {
 	return nil
 }
 
+// InstalledDistributions maps normalized names of distributions installed in
+// the environment's site-packages to their versions
+func (venv *VirtualEnvironment) InstalledDistributions() (map[string]string, error) {
+	patterns := []string{
+		filepath.Join(venv.Path, "lib", "python*", "site-packages", "*.dist-info"),
+		filepath.Join(venv.Path, "Lib", "site-packages", "*.dist-info"),
+	}
+	installed := make(map[string]string)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan site-packages: %w", err)
+		}
+		for _, match := range matches {
+			base := strings.TrimSuffix(filepath.Base(match), ".dist-info")
+			name, version, ok := strings.Cut(base, "-")
+			if !ok {
+				continue
+			}
+			installed[pkgname.Normalize(name)] = version
+		}
+	}
+	return installed, nil
+}
+
 // Exists checks if the virtual environment exists
 func (venv *VirtualEnvironment) Exists() bool {
 	pythonPath := venv.GetPythonPath()
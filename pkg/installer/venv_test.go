@@ -63,4 +63,36 @@ func TestVirtualEnvironmentFindPython(t *testing.T) {
 	if py == "" {
 		t.Error("findPython returned empty string")
 	}
-} 
\ No newline at end of file
+} 
+func TestVirtualEnvironmentActivateEnv(t *testing.T) {
+	dir := t.TempDir()
+	venv := NewVirtualEnvironment(dir)
+	env := map[string]string{"PATH": "/usr/bin", "PYTHONHOME": "/opt/python"}
+	venv.ActivateEnv(env)
+	if env["VIRTUAL_ENV"] != dir {
+		t.Errorf("VIRTUAL_ENV = %q, expected %q", env["VIRTUAL_ENV"], dir)
+	}
+	expectedPath := venv.GetBinPath() + string(filepath.ListSeparator) + "/usr/bin"
+	if env["PATH"] != expectedPath {
+		t.Errorf("PATH = %q, expected %q", env["PATH"], expectedPath)
+	}
+	if _, ok := env["PYTHONHOME"]; ok {
+		t.Error("ActivateEnv should unset PYTHONHOME")
+	}
+}
+
+func TestVirtualEnvironmentInstalledDistributions(t *testing.T) {
+	dir := t.TempDir()
+	for _, distInfo := range []string{"Flask_Login-0.6.3.dist-info", "requests-2.31.0.dist-info"} {
+		if err := os.MkdirAll(filepath.Join(dir, "lib", "python3.12", "site-packages", distInfo), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	installed, err := NewVirtualEnvironment(dir).InstalledDistributions()
+	if err != nil {
+		t.Fatalf("InstalledDistributions failed: %v", err)
+	}
+	if installed["flask-login"] != "0.6.3" || installed["requests"] != "2.31.0" || len(installed) != 2 {
+		t.Errorf("unexpected distributions: %v", installed)
+	}
+}
@@ -2,55 +2,153 @@ package installer
 
 import (
 	"archive/zip"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"rimraf-adi.com/zephyr/pkg/cache"
+	"rimraf-adi.com/zephyr/pkg/fsutil"
+	"rimraf-adi.com/zephyr/pkg/markers"
+	"rimraf-adi.com/zephyr/pkg/progress"
 	"rimraf-adi.com/zephyr/pkg/pypi"
+	"rimraf-adi.com/zephyr/pkg/remedy"
 )
 
 // WheelInstaller handles wheel file installation
 type WheelInstaller struct {
 	venvPath string
+	cache    *cache.ArtifactCache
+	unpacked *cache.UnpackedCache
+	built    *cache.BuiltWheelCache
+	linkMode cache.LinkMode
+	scriptPrecedence []string
+	allowOverwrite   bool
+	target           *markers.Target
+	// scheme replaces the venv layout when installing into a prefix
+	scheme           *Scheme
+	checksums        *cache.ChecksumDB
+	// lockedHashes and hashes map name==version to the artifact sha256
+	// pinned by the lockfile and seen by this installer
+	lockedHashes map[string]string
+	hashes       map[string]string
+	installed    []InstalledArtifact
+	// refresh downloads and extracts artifacts again instead of using cached copies
+	refresh      bool
+	// fs holds the environment; the caches stay on disk
+	fs           fsutil.FS
+	timings      InstallTimings
+	progress     progress.Handler
+}
+
+// InstallTimings adds up where InstallWheelFromPyPI spent its time
+type InstallTimings struct {
+	// Download covers finding, fetching and verifying artifacts, and building sdists
+	Download time.Duration
+	// Extract covers unpacking wheels into the environment
+	Extract time.Duration
+	// CacheHits counts artifacts taken from the cache without downloading
+	CacheHits int
+}
+
+// InstalledArtifact is a wheel the installer put into the environment
+type InstalledArtifact struct {
+	Name    string
+	Version string
+	// Source is "pypi", or SourceURL or SourceFile for direct installs
+	Source string
+	SHA256 string
 }
 
 // NewWheelInstaller creates a new wheel installer
 func NewWheelInstaller(venvPath string) *WheelInstaller {
 	return &WheelInstaller{
 		venvPath: venvPath,
+		cache:    cache.NewDefaultArtifactCache(),
+		unpacked: cache.NewDefaultUnpackedCache(),
+		built:    cache.NewDefaultBuiltWheelCache(),
+		linkMode: cache.LinkModeCopy,
+		checksums: cache.NewDefaultChecksumDB(),
+		hashes:    make(map[string]string),
+		fs:        fsutil.OS,
 	}
 }
 
+// SetFS makes the installer read and write the environment, and local wheel
+// files, through fsys instead of the disk. Artifacts from the caches are
+// still read from disk, and linked installs need fsys to be fsutil.OS.
+func (wi *WheelInstaller) SetFS(fsys fsutil.FS) {
+	wi.fs = fsys
+}
+
+// SetCache overrides the artifact cache used for downloaded wheels
+func (wi *WheelInstaller) SetCache(c *cache.ArtifactCache) {
+	wi.cache = c
+}
+
+// SetUnpackedCache overrides the cache of extracted wheels used by linked installs
+func (wi *WheelInstaller) SetUnpackedCache(c *cache.UnpackedCache) {
+	wi.unpacked = c
+}
+
+// SetLinkMode selects how cached wheel files are placed into site-packages
+func (wi *WheelInstaller) SetLinkMode(mode cache.LinkMode) {
+	wi.linkMode = mode
+}
+
+// SetRefresh makes the installer download every artifact again, verifying it
+// against its pinned digest, and extract it afresh rather than trust copies
+// in the cache, for recovering environments whose files were modified
+func (wi *WheelInstaller) SetRefresh(refresh bool) {
+	wi.refresh = refresh
+}
+
 // InstallWheel installs a wheel file into the virtual environment
 func (wi *WheelInstaller) InstallWheel(wheelPath, packageName string) error {
-	reader, err := zip.OpenReader(wheelPath)
+	reader, closer, err := fsutil.OpenZip(wi.fs, wheelPath)
 	if err != nil {
 		return fmt.Errorf("failed to open wheel file '%s': %w. Ensure the file exists and is a valid .whl archive.", wheelPath, err)
 	}
-	defer reader.Close()
+	defer closer.Close()
 	metadata, err := wi.parseWheelMetadata(reader)
 	if err != nil {
 		return fmt.Errorf("failed to parse wheel metadata for '%s': %w. The wheel may be corrupted or missing METADATA.", wheelPath, err)
 	}
+	if err := checkWheelFilename(wheelPath, metadata); err != nil {
+		return err
+	}
+	if err := wi.checkWheelTags(metadata); err != nil {
+		return err
+	}
+	if err := wi.checkFileCollisions(reader, metadata); err != nil {
+		return err
+	}
 	createdPaths := []string{}
 	sitePackages := wi.getSitePackagesPath()
-	if err := wi.extractWheel(reader, sitePackages, metadata, &createdPaths); err != nil {
+	if err := wi.extractWheel(wi.fs, reader, wi.schemeDest(metadata), &createdPaths); err != nil {
+		wi.rollbackCreatedPaths(createdPaths)
+		return remedy.Annotate(fmt.Errorf("failed to extract wheel '%s' to site-packages: %w. Check permissions and disk space.", wheelPath, err))
+	}
+	if err := wi.rewriteScripts(wi.fs, reader, metadata); err != nil {
 		wi.rollbackCreatedPaths(createdPaths)
-		return fmt.Errorf("failed to extract wheel '%s' to site-packages: %w. Check permissions and disk space.", wheelPath, err)
+		return err
 	}
 	if err := wi.installMetadata(sitePackages, metadata, &createdPaths); err != nil {
 		wi.rollbackCreatedPaths(createdPaths)
 		return fmt.Errorf("failed to install metadata for '%s': %w. The wheel may be malformed.", wheelPath, err)
 	}
+	if err := wi.installScripts(metadata, &createdPaths); err != nil {
+		wi.rollbackCreatedPaths(createdPaths)
+		return fmt.Errorf("failed to install console scripts for '%s': %w", wheelPath, err)
+	}
 	return nil
 }
 
 // parseWheelMetadata parses metadata from wheel file
-func (wi *WheelInstaller) parseWheelMetadata(reader *zip.ReadCloser) (*WheelMetadata, error) {
+func (wi *WheelInstaller) parseWheelMetadata(reader *zip.Reader) (*WheelMetadata, error) {
 	metadata := &WheelMetadata{}
 	
 	// Look for METADATA file
@@ -68,11 +166,17 @@ func (wi *WheelInstaller) parseWheelMetadata(reader *zip.ReadCloser) (*WheelMeta
 			}
 			
 			metadata.RawMetadata = string(content)
-			metadata.parseMetadata()
+			if err := metadata.parseMetadata(); err != nil {
+				return nil, fmt.Errorf("invalid METADATA: %w", err)
+			}
 			break
 		}
 	}
 	
+	if metadata.DistInfoName == "" {
+		return nil, fmt.Errorf("wheel has no .dist-info/METADATA file")
+	}
+	
 	// Look for WHEEL file
 	for _, file := range reader.File {
 		if strings.HasSuffix(file.Name, ".dist-info/WHEEL") {
@@ -91,60 +195,132 @@ func (wi *WheelInstaller) parseWheelMetadata(reader *zip.ReadCloser) (*WheelMeta
 			break
 		}
 	}
+	if metadata.WheelInfo == "" {
+		return nil, fmt.Errorf("wheel has no .dist-info/WHEEL file")
+	}
+	wheel, err := ParseWheelFile(metadata.WheelInfo)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Wheel = wheel
+	
+	// Look for entry_points.txt
+	for _, file := range reader.File {
+		if strings.HasSuffix(file.Name, ".dist-info/entry_points.txt") {
+			rc, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			
+			content, err := io.ReadAll(rc)
+			if err != nil {
+				return nil, err
+			}
+			
+			metadata.EntryPoints = string(content)
+			break
+		}
+	}
+	
+	record, err := readWheelRecord(reader)
+	if err != nil {
+		return nil, err
+	}
+	metadata.FileHashes = make(map[string]string)
+	for _, file := range reader.File {
+		if !strings.Contains(file.Name, ".dist-info/") && !file.FileInfo().IsDir() {
+			installed := wi.recordPath(wi.installPath(file.Name, metadata))
+			metadata.Files = append(metadata.Files, installed)
+			if hash, ok := record[file.Name]; ok {
+				metadata.FileHashes[installed] = hash
+			}
+		}
+	}
 	
 	return metadata, nil
 }
 
-// Helper for atomic install: track created dirs
-func trackMkdirAll(path string, perm os.FileMode, createdPaths *[]string) error {
-	err := os.MkdirAll(path, perm)
+// Helper for atomic install: track created dirs. Only the outermost directory
+// that did not exist yet is recorded, so rollback never removes existing ones.
+func trackMkdirAll(fsys fsutil.FS, path string, perm os.FileMode, createdPaths *[]string) error {
+	if _, err := fsys.Stat(path); err == nil {
+		return nil
+	}
+	top := path
+	for {
+		parent := filepath.Dir(top)
+		if parent == top {
+			break
+		}
+		if _, err := fsys.Stat(parent); err == nil {
+			break
+		}
+		top = parent
+	}
+	err := fsys.MkdirAll(path, perm)
 	if err == nil {
-		*createdPaths = append(*createdPaths, path)
+		*createdPaths = append(*createdPaths, top)
 	}
 	return err
 }
 
 // Helper for atomic install: track created files
-func trackCreateFile(path string, createdPaths *[]string) (*os.File, error) {
-	f, err := os.Create(path)
+func trackCreateFile(fsys fsutil.FS, path string, createdPaths *[]string) (io.WriteCloser, error) {
+	f, err := fsys.Create(path)
 	if err == nil {
 		*createdPaths = append(*createdPaths, path)
 	}
 	return f, err
 }
 
-// extractWheel extracts wheel contents to site-packages
-func (wi *WheelInstaller) extractWheel(reader *zip.ReadCloser, sitePackages string, metadata *WheelMetadata, createdPaths *[]string) error {
+// extractWheel extracts wheel contents into fsys, placing each member at the path dest maps it to
+func (wi *WheelInstaller) extractWheel(fsys fsutil.FS, reader *zip.Reader, dest func(name string) string, createdPaths *[]string) error {
+	if err := checkArchiveMembers(reader.File); err != nil {
+		return err
+	}
 	for _, file := range reader.File {
 		if strings.Contains(file.Name, ".dist-info/") {
 			continue
 		}
-		targetPath := filepath.Join(sitePackages, file.Name)
+		targetPath := dest(file.Name)
 		if file.FileInfo().IsDir() {
-			if err := trackMkdirAll(targetPath, 0755, createdPaths); err != nil {
+			if err := trackMkdirAll(fsys, targetPath, 0755, createdPaths); err != nil {
 				return fmt.Errorf("failed to create directory '%s': %w. Check permissions.", targetPath, err)
 			}
 			continue
 		}
 		parentDir := filepath.Dir(targetPath)
-		if err := trackMkdirAll(parentDir, 0755, createdPaths); err != nil {
+		if err := trackMkdirAll(fsys, parentDir, 0755, createdPaths); err != nil {
 			return fmt.Errorf("failed to create parent directory '%s': %w. Check permissions.", parentDir, err)
 		}
-		if err := wi.extractFileTracked(file, targetPath, createdPaths); err != nil {
+		if err := extractFileTracked(fsys, file, targetPath, createdPaths); err != nil {
 			return fmt.Errorf("failed to extract file '%s' to '%s': %w. Check disk space and permissions.", file.Name, targetPath, err)
 		}
+		if isScriptPath(file.Name) {
+			if err := fsys.Chmod(targetPath, 0755); err != nil {
+				return fmt.Errorf("failed to make script '%s' executable: %w. Check permissions.", targetPath, err)
+			}
+		}
 	}
 	return nil
 }
 
-// extractFile extracts a single file from the wheel
-func (wi *WheelInstaller) extractFileTracked(file *zip.File, targetPath string, createdPaths *[]string) error {
+// schemeDest maps wheel members onto the environment's install scheme
+func (wi *WheelInstaller) schemeDest(metadata *WheelMetadata) func(string) string {
+	return func(name string) string {
+		return wi.installPath(name, metadata)
+	}
+}
+
+// extractFileTracked extracts a single file from the wheel
+func extractFileTracked(fsys fsutil.FS, file *zip.File, targetPath string, createdPaths *[]string) error {
 	rc, err := file.Open()
 	if err != nil {
 		return fmt.Errorf("failed to open file in wheel: %w. The wheel may be corrupted.", err)
 	}
 	defer rc.Close()
-	targetFile, err := trackCreateFile(targetPath, createdPaths)
+	targetFile, err := trackCreateFile(fsys, targetPath, createdPaths)
 	if err != nil {
 		return fmt.Errorf("failed to create file '%s': %w. Check permissions and disk space.", targetPath, err)
 	}
@@ -159,26 +335,44 @@ func (wi *WheelInstaller) extractFileTracked(file *zip.File, targetPath string,
 // installMetadata installs wheel metadata
 func (wi *WheelInstaller) installMetadata(sitePackages string, metadata *WheelMetadata, createdPaths *[]string) error {
 	distInfoDir := filepath.Join(sitePackages, metadata.DistInfoName)
-	if err := trackMkdirAll(distInfoDir, 0755, createdPaths); err != nil {
+	if err := trackMkdirAll(wi.fs, distInfoDir, 0755, createdPaths); err != nil {
 		return fmt.Errorf("failed to create dist-info directory '%s': %w. Check permissions.", distInfoDir, err)
 	}
 	metadataPath := filepath.Join(distInfoDir, "METADATA")
-	f, err := trackCreateFile(metadataPath, createdPaths)
+	f, err := trackCreateFile(wi.fs, metadataPath, createdPaths)
 	if err != nil {
 		return fmt.Errorf("failed to write METADATA file '%s': %w. Check permissions and disk space.", metadataPath, err)
 	}
 	f.Write([]byte(metadata.RawMetadata))
 	f.Close()
 	wheelPath := filepath.Join(distInfoDir, "WHEEL")
-	f, err = trackCreateFile(wheelPath, createdPaths)
+	f, err = trackCreateFile(wi.fs, wheelPath, createdPaths)
 	if err != nil {
 		return fmt.Errorf("failed to write WHEEL file '%s': %w. Check permissions and disk space.", wheelPath, err)
 	}
 	f.Write([]byte(metadata.WheelInfo))
 	f.Close()
+	if metadata.EntryPoints != "" {
+		entryPointsPath := filepath.Join(distInfoDir, "entry_points.txt")
+		f, err = trackCreateFile(wi.fs, entryPointsPath, createdPaths)
+		if err != nil {
+			return fmt.Errorf("failed to write entry_points.txt file '%s': %w. Check permissions and disk space.", entryPointsPath, err)
+		}
+		f.Write([]byte(metadata.EntryPoints))
+		f.Close()
+	}
+	if len(metadata.Overwrites) > 0 {
+		overwritesPath := filepath.Join(distInfoDir, overwritesFile)
+		f, err = trackCreateFile(wi.fs, overwritesPath, createdPaths)
+		if err != nil {
+			return fmt.Errorf("failed to write %s file '%s': %w. Check permissions and disk space.", overwritesFile, overwritesPath, err)
+		}
+		f.Write([]byte(formatOverwrites(metadata.Overwrites)))
+		f.Close()
+	}
 	recordPath := filepath.Join(distInfoDir, "RECORD")
 	recordContent := wi.generateRecordFile(sitePackages, metadata)
-	f, err = trackCreateFile(recordPath, createdPaths)
+	f, err = trackCreateFile(wi.fs, recordPath, createdPaths)
 	if err != nil {
 		return fmt.Errorf("failed to write RECORD file '%s': %w. Check permissions and disk space.", recordPath, err)
 	}
@@ -187,38 +381,64 @@ func (wi *WheelInstaller) installMetadata(sitePackages string, metadata *WheelMe
 	return nil
 }
 
-// generateRecordFile generates a RECORD file for the wheel
+// generateRecordFile generates a RECORD file for the wheel, with the hash
+// and size of every file so the installed environment can be verified later
 func (wi *WheelInstaller) generateRecordFile(sitePackages string, metadata *WheelMetadata) string {
-	// This is a simplified implementation
-	// A real implementation would calculate hashes and include all files
 	var lines []string
 	
 	// Add metadata files
-	lines = append(lines, fmt.Sprintf("%s/METADATA,sha256=...,%d", metadata.DistInfoName, len(metadata.RawMetadata)))
-	lines = append(lines, fmt.Sprintf("%s/WHEEL,sha256=...,%d", metadata.DistInfoName, len(metadata.WheelInfo)))
-	lines = append(lines, fmt.Sprintf("%s/RECORD,sha256=...,%d", metadata.DistInfoName, 0))
+	lines = append(lines, fmt.Sprintf("%s/METADATA,%s", metadata.DistInfoName, recordEntry([]byte(metadata.RawMetadata))))
+	lines = append(lines, fmt.Sprintf("%s/WHEEL,%s", metadata.DistInfoName, recordEntry([]byte(metadata.WheelInfo))))
+	if metadata.EntryPoints != "" {
+		lines = append(lines, fmt.Sprintf("%s/entry_points.txt,%s", metadata.DistInfoName, recordEntry([]byte(metadata.EntryPoints))))
+	}
+	lines = append(lines, fmt.Sprintf("%s/RECORD,,", metadata.DistInfoName))
+	
+	// Installed files are listed so later installs can tell who owns them
+	for _, file := range metadata.Files {
+		if hash, ok := metadata.FileHashes[file]; ok {
+			lines = append(lines, file+","+hash)
+		} else {
+			lines = append(lines, file+",,")
+		}
+	}
 	
 	return strings.Join(lines, "\n")
 }
 
 // getSitePackagesPath returns the site-packages path for the virtual environment
 func (wi *WheelInstaller) getSitePackagesPath() string {
-	// Determine Python version (simplified)
-	pythonVersion := "3.11" // This should be detected from the venv
-	
+	if wi.scheme != nil {
+		wi.fs.MkdirAll(wi.scheme.Purelib, 0755)
+		return wi.scheme.Purelib
+	}
 	// Construct site-packages path
-	sitePackages := filepath.Join(wi.venvPath, "lib", "python"+pythonVersion, "site-packages")
+	sitePackages := filepath.Join(wi.venvPath, "lib", wi.pythonDir(), "site-packages")
 	
 	// Create directory if it doesn't exist
-	if err := os.MkdirAll(sitePackages, 0755); err != nil {
+	if err := wi.fs.MkdirAll(sitePackages, 0755); err != nil {
 		// Fallback to a simpler path
 		sitePackages = filepath.Join(wi.venvPath, "site-packages")
-		os.MkdirAll(sitePackages, 0755)
+		wi.fs.MkdirAll(sitePackages, 0755)
 	}
 	
 	return sitePackages
 }
 
+// pythonDir returns the versioned directory name, e.g. python3.12, of the
+// interpreter installed into: the target's when set, else the one the
+// environment's lib directory was created for, else python3.11
+func (wi *WheelInstaller) pythonDir() string {
+	if wi.target != nil {
+		return "python" + wi.target.PythonMinor()
+	}
+	matches, _ := wi.fs.Glob(filepath.Join(wi.venvPath, "lib", "python*", "site-packages"))
+	if len(matches) == 1 {
+		return filepath.Base(filepath.Dir(matches[0]))
+	}
+	return "python3.11"
+}
+
 // WheelMetadata represents wheel metadata
 type WheelMetadata struct {
 	Name         string
@@ -230,112 +450,412 @@ type WheelMetadata struct {
 	License      string
 	RequiresDist []string
 	RawMetadata  string
+	Core         *pypi.CoreMetadata
 	WheelInfo    string
+	EntryPoints  string
 	DistInfoName string
+	Wheel        *WheelFile
+	// Files lists the site-packages paths the wheel installs
+	Files []string
+	// FileHashes maps Files to the "hash,size" RECORD columns the wheel lists for them
+	FileHashes map[string]string
+	// Overwrites lists files taken over from other distributions
+	Overwrites []FileCollision
 }
 
 // parseMetadata parses the raw metadata string
-func (wm *WheelMetadata) parseMetadata() {
-	lines := strings.Split(wm.RawMetadata, "\n")
-	
-	for _, line := range lines {
-		if strings.HasPrefix(line, "Name: ") {
-			wm.Name = strings.TrimSpace(strings.TrimPrefix(line, "Name: "))
-		} else if strings.HasPrefix(line, "Version: ") {
-			wm.Version = strings.TrimSpace(strings.TrimPrefix(line, "Version: "))
-		} else if strings.HasPrefix(line, "Summary: ") {
-			wm.Summary = strings.TrimSpace(strings.TrimPrefix(line, "Summary: "))
-		} else if strings.HasPrefix(line, "Author: ") {
-			wm.Author = strings.TrimSpace(strings.TrimPrefix(line, "Author: "))
-		} else if strings.HasPrefix(line, "Author-email: ") {
-			wm.AuthorEmail = strings.TrimSpace(strings.TrimPrefix(line, "Author-email: "))
-		} else if strings.HasPrefix(line, "License: ") {
-			wm.License = strings.TrimSpace(strings.TrimPrefix(line, "License: "))
-		} else if strings.HasPrefix(line, "Requires-Dist: ") {
-			req := strings.TrimSpace(strings.TrimPrefix(line, "Requires-Dist: "))
-			wm.RequiresDist = append(wm.RequiresDist, req)
-		}
+func (wm *WheelMetadata) parseMetadata() error {
+	core, err := pypi.ParseCoreMetadata(wm.RawMetadata)
+	if err != nil {
+		return err
 	}
+	wm.Core = core
+	wm.Name = core.Name
+	wm.Version = core.Version
+	wm.Summary = core.Summary
+	wm.Description = core.Description
+	wm.Author = core.Author
+	wm.AuthorEmail = core.AuthorEmail
+	wm.License = core.License
+	wm.RequiresDist = core.RequiresDist
 	
-	// Generate dist-info name
-	if wm.Name != "" && wm.Version != "" {
-		wm.DistInfoName = fmt.Sprintf("%s-%s.dist-info", wm.Name, wm.Version)
+	// The dist-info name becomes a directory in site-packages
+	if err := checkDistribution(wm.Name, wm.Version); err != nil {
+		return err
 	}
+	wm.DistInfoName = fmt.Sprintf("%s-%s.dist-info", wm.Name, wm.Version)
+	return nil
 }
 
 // Helper to rollback created files/dirs
 func (wi *WheelInstaller) rollbackCreatedPaths(createdPaths []string) {
 	for i := len(createdPaths) - 1; i >= 0; i-- {
-		os.RemoveAll(createdPaths[i])
+		wi.fs.RemoveAll(createdPaths[i])
 	}
 }
 
+// SetProgress makes the installer report downloads and installs as they happen
+func (wi *WheelInstaller) SetProgress(handler progress.Handler) {
+	wi.progress = handler
+}
+
 // InstallWheelFromPyPI downloads and installs a wheel from PyPI with atomic rollback and hash verification
 func (wi *WheelInstaller) InstallWheelFromPyPI(packageName, version string) error {
+	wi.progress.Emit(progress.Event{Kind: progress.InstallStarted, Package: packageName, Version: version})
+	err := remedy.Annotate(wi.installWheelFromPyPI(packageName, version))
+	wi.progress.Emit(progress.Event{Kind: progress.InstallFinished, Package: packageName, Version: version, Err: err})
+	return err
+}
+
+// installWheelFromPyPI does the work of InstallWheelFromPyPI
+func (wi *WheelInstaller) installWheelFromPyPI(packageName, version string) error {
 	fmt.Fprintf(os.Stderr, "[zephyr] Resolving wheel for %s %s...\n", packageName, version)
+	started := time.Now()
+	client := pypi.NewPyPIClient()
+	release, wheelPath, err := wi.fetchRelease(client, packageName, version, func() (*pypi.Release, error) {
+		release, err := wi.findRelease(client, packageName, version)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not find wheel for %s %s: %v\n", packageName, version, err)
+			return nil, fmt.Errorf("failed to find wheel: %w", err)
+		}
+		return release, nil
+	})
+	if err != nil {
+		return err
+	}
+	if release.Packagetype == "sdist" {
+		if wheelPath, err = wi.buildFromSdist(wheelPath, release.Filename, packageName, version); err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", err)
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "[zephyr] Installing wheel for %s %s...\n", packageName, version)
+	extracting := time.Now()
+	wi.timings.Download += extracting.Sub(started)
+	createdPaths := []string{}
+	if wi.linkMode == cache.LinkModeCopy {
+		err = wi.InstallWheelTracked(wheelPath, packageName, &createdPaths)
+	} else {
+		err = wi.installCachedWheelTracked(filepath.Base(wheelPath), &createdPaths)
+	}
+	wi.timings.Extract += time.Since(extracting)
+	if err != nil {
+		wi.rollbackCreatedPaths(createdPaths)
+		fmt.Fprintf(os.Stderr, "[zephyr] Error: Atomic install failed for %s %s, rolled back: %v\n", packageName, version, err)
+		return fmt.Errorf("atomic install failed, rolled back: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "[zephyr] Successfully installed %s %s\n", packageName, version)
+	wi.installed = append(wi.installed, InstalledArtifact{Name: packageName, Version: version, Source: "pypi", SHA256: wi.hashes[artifactKey(packageName, version)]})
+	return nil
+}
+
+// Timings returns the time spent installing from the index so far
+func (wi *WheelInstaller) Timings() InstallTimings {
+	return wi.timings
+}
+
+// Installed lists the wheels installed so far, in installation order
+func (wi *WheelInstaller) Installed() []InstalledArtifact {
+	return wi.installed
+}
+
+// DownloadWheel fetches the wheel for a package version through the artifact cache and
+// links it into destDir under its original filename. With a target set, the most
+// specific wheel compatible with the target is chosen.
+func (wi *WheelInstaller) DownloadWheel(packageName, version, destDir string) (string, error) {
 	client := pypi.NewPyPIClient()
-	release, err := client.FindWheelForVersion(packageName, version, "any")
+	release, cachedPath, err := wi.fetchRelease(client, packageName, version, func() (*pypi.Release, error) {
+		var release *pypi.Release
+		var err error
+		if wi.target != nil {
+			var releases []pypi.Release
+			if releases, err = client.GetReleasesForVersion(packageName, version); err == nil {
+				release, err = wi.chooseRelease(releases, packageName, version, *wi.target)
+			}
+			if err == nil && release.Packagetype != "bdist_wheel" {
+				// A download is for another machine, which would have to build the sdist itself
+				err = pypi.NewNoCompatibleArtifactError(packageName, version, releases, *wi.target)
+			}
+		} else {
+			release, err = client.FindWheelForVersion(packageName, version, "any")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find wheel: %w", err)
+		}
+		if release.Packagetype != "bdist_wheel" {
+			return nil, fmt.Errorf("no wheel published for %s %s", packageName, version)
+		}
+		return release, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(destDir, release.Filename)
+	if err := wi.cache.Link(filepath.Base(cachedPath), dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// findRelease picks the file of a package version to install into the
+// environment, or the first wheel when the interpreter cannot be determined
+func (wi *WheelInstaller) findRelease(client *pypi.PyPIClient, packageName, version string) (*pypi.Release, error) {
+	target, ok := wi.installTarget()
+	if !ok {
+		return client.FindWheelForVersion(packageName, version, "any")
+	}
+	releases, err := client.GetReleasesForVersion(packageName, version)
+	if err != nil {
+		return nil, err
+	}
+	return wi.chooseRelease(releases, packageName, version, target)
+}
+
+// chooseRelease keeps the first published wheel when it suits target, so the
+// artifact and the hash pinned for it do not change, and otherwise picks the
+// most specific compatible wheel or the sdist. When neither exists the error
+// lists the tags considered and the files published.
+func (wi *WheelInstaller) chooseRelease(releases []pypi.Release, packageName, version string, target markers.Target) (*pypi.Release, error) {
+	for i, release := range releases {
+		if release.Packagetype != "bdist_wheel" {
+			continue
+		}
+		if wheel, err := pypi.ParseWheelFilename(release.Filename); err == nil && wheel.CompatibleWith(target) {
+			return &releases[i], nil
+		}
+		break
+	}
+	release, err := selectRelease(releases, target)
+	var noMatch *pypi.NoCompatibleArtifactError
+	if errors.As(err, &noMatch) {
+		noMatch.Package, noMatch.Version = packageName, version
+	}
+	return release, err
+}
+
+// fetchRelease fetches the release find picks into the artifact cache. When
+// the index no longer serves the file, or serves one that does not match its
+// published digest, the package's metadata is taken to be stale: it is
+// refetched and the release picked and fetched once more.
+func (wi *WheelInstaller) fetchRelease(client *pypi.PyPIClient, packageName, version string, find func() (*pypi.Release, error)) (*pypi.Release, string, error) {
+	release, err := find()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not find wheel for %s %s: %v\n", packageName, version, err)
-		return fmt.Errorf("failed to find wheel: %w", err)
+		return nil, "", err
+	}
+	path, err := wi.fetchWheel(client, release, packageName, version)
+	if err == nil || !staleMetadata(err) {
+		return release, path, err
+	}
+	fmt.Fprintf(os.Stderr, "[zephyr] Warning: Index metadata for %s looks stale, refetching it and retrying once\n", packageName)
+	if _, refreshErr := client.RefreshPackageMetadata(packageName); refreshErr != nil {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: Could not refetch metadata for %s: %v\n", packageName, refreshErr)
+		return nil, "", err
 	}
-	reader, err := client.DownloadRelease(*release)
+	if release, err = find(); err != nil {
+		return nil, "", err
+	}
+	path, err = wi.fetchWheel(client, release, packageName, version)
+	return release, path, err
+}
+
+// staleMetadata reports whether a fetch failed the way outdated index metadata
+// makes it fail: a listed file is gone, or a file differs from the digest the
+// index published. Mismatches against a lockfile or checksum pin are not.
+func staleMetadata(err error) bool {
+	var mismatch *cache.HashMismatchError
+	return errors.Is(err, pypi.ErrArtifactNotFound) || errors.As(err, &mismatch)
+}
+
+// fetchWheel returns the path of a verified copy of the release in the artifact cache,
+// downloading it only when no artifact with the expected digest is cached yet
+func (wi *WheelInstaller) fetchWheel(client *pypi.PyPIClient, release *pypi.Release, packageName, version string) (string, error) {
+	expected, pinnedBy, err := wi.expectedHash(release, packageName, version)
 	if err != nil {
+		return "", err
+	}
+	if wi.cache.Has(expected) && !wi.refresh {
+		if err := wi.cache.Verify(expected); err == nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Using cached %s\n", release.Filename)
+			wi.timings.CacheHits++
+			wi.hashes[artifactKey(packageName, version)] = strings.ToLower(expected)
+			return wi.cache.Path(expected), nil
+		}
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: Cached %s is corrupted, downloading again\n", release.Filename)
+	}
+	wi.progress.Emit(progress.Event{Kind: progress.DownloadStarted, Package: packageName, Version: version, File: release.Filename, Total: release.Size})
+	digest, err := wi.downloadRelease(client, release, expected, pinnedBy, packageName, version)
+	wi.progress.Emit(progress.Event{Kind: progress.DownloadFinished, Package: packageName, Version: version, File: release.Filename, Total: release.Size, Err: err})
+	if err != nil {
+		return "", err
+	}
+	wi.hashes[artifactKey(packageName, version)] = digest
+	return wi.cache.Path(digest), nil
+}
+
+// downloadRelease downloads the release into the artifact cache, verifying it
+// against expected, and returns its digest. The download goes to a partial
+// file under the cache root first, so an interrupted one resumes next time.
+func (wi *WheelInstaller) downloadRelease(client *pypi.PyPIClient, release *pypi.Release, expected, pinnedBy, packageName, version string) (string, error) {
+	client.SetProgress(wi.progress)
+	partials := filepath.Join(wi.cache.Root, ".partial")
+	if err := os.MkdirAll(partials, 0755); err != nil {
+		return "", fmt.Errorf("failed to create download directory: %w", err)
+	}
+	if expected != "" {
+		fmt.Fprintf(os.Stderr, "[zephyr] Verifying SHA256 for %s...\n", release.Filename)
+	}
+	downloaded := filepath.Join(partials, release.Filename)
+	err := client.DownloadReleaseTo(*release, downloaded, expected)
+	fmt.Fprintln(os.Stderr) // Print newline after progress
+	var mismatch *cache.HashMismatchError
+	if err != nil && !errors.As(err, &mismatch) {
 		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not download wheel for %s %s: %v\n", packageName, version, err)
-		return fmt.Errorf("failed to download wheel: %w", err)
+		return "", fmt.Errorf("failed to download wheel: %w", err)
+	}
+	digest := ""
+	if err == nil {
+		digest, err = wi.cache.StoreFile(downloaded, expected)
+		os.Remove(downloaded)
 	}
-	defer reader.Close()
-	tempFile, err := os.CreateTemp("", "wheel-*.whl")
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[zephyr] Error: Could not create temp file for %s %s: %v\n", packageName, version, err)
-		return fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
-	hasher := sha256.New()
-	multiWriter := io.MultiWriter(tempFile, hasher)
-	if _, err := io.Copy(multiWriter, reader); err != nil {
+		if errors.As(err, &mismatch) && pinnedBy != "" {
+			tofu := &cache.ChecksumMismatchError{URL: cache.ChecksumKey(release.URL), Pinned: mismatch.Expected, Actual: mismatch.Actual, DB: pinnedBy}
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: %v\n", tofu)
+			return "", tofu
+		}
+		if errors.As(err, &mismatch) {
+			fmt.Fprintf(os.Stderr, "[zephyr] Error: SHA256 hash mismatch for %s: expected %s, got %s\n", packageName, mismatch.Expected, mismatch.Actual)
+			return "", fmt.Errorf("%s %s: %w", packageName, version, mismatch)
+		}
 		fmt.Fprintf(os.Stderr, "[zephyr] Error: Failed to write wheel for %s %s: %v\n", packageName, version, err)
-		return fmt.Errorf("failed to write temp file: %w", err)
+		return "", fmt.Errorf("failed to cache wheel: %w", err)
 	}
-	fmt.Fprintln(os.Stderr) // Print newline after progress
-	if release.Digests.SHA256 != "" {
-		fmt.Fprintf(os.Stderr, "[zephyr] Verifying SHA256 for %s...\n", release.Filename)
-		actualHash := hex.EncodeToString(hasher.Sum(nil))
-		if !strings.EqualFold(actualHash, release.Digests.SHA256) {
-			fmt.Fprintf(os.Stderr, "[zephyr] Error: SHA256 hash mismatch for %s: expected %s, got %s\n", packageName, release.Digests.SHA256, actualHash)
-			return fmt.Errorf("SHA256 hash mismatch for %s: expected %s, got %s", packageName, release.Digests.SHA256, actualHash)
+	if expected == "" {
+		fmt.Fprintf(os.Stderr, "[zephyr] Warning: The index publishes no digest for %s; pinned sha256 %s on first use\n", release.Filename, digest)
+		if err := wi.checksums.Pin(release.URL, digest); err != nil {
+			return "", err
 		}
 	}
-	fmt.Fprintf(os.Stderr, "[zephyr] Installing wheel for %s %s...\n", packageName, version)
+	return digest, nil
+}
+
+// InstallCachedWheel installs a wheel from the artifact cache, linking its files
+// from an extracted copy in the cache according to the link mode
+func (wi *WheelInstaller) InstallCachedWheel(digest, packageName string) error {
 	createdPaths := []string{}
-	err = wi.InstallWheelTracked(tempFile.Name(), packageName, &createdPaths)
-	if err != nil {
+	if err := wi.installCachedWheelTracked(digest, &createdPaths); err != nil {
 		wi.rollbackCreatedPaths(createdPaths)
-		fmt.Fprintf(os.Stderr, "[zephyr] Error: Atomic install failed for %s %s, rolled back: %v\n", packageName, version, err)
-		return fmt.Errorf("atomic install failed, rolled back: %w", err)
+		return fmt.Errorf("failed to install cached wheel for '%s': %w", packageName, err)
 	}
-	fmt.Fprintf(os.Stderr, "[zephyr] Successfully installed %s %s\n", packageName, version)
 	return nil
 }
 
-// InstallWheelTracked is like InstallWheel but takes createdPaths for rollback
-func (wi *WheelInstaller) InstallWheelTracked(wheelPath, packageName string, createdPaths *[]string) error {
+// installCachedWheelTracked extracts the cached wheel once and links its files into site-packages
+func (wi *WheelInstaller) installCachedWheelTracked(digest string, createdPaths *[]string) error {
+	wheelPath := wi.cache.Path(digest)
 	reader, err := zip.OpenReader(wheelPath)
 	if err != nil {
 		return fmt.Errorf("failed to open wheel file '%s': %w. Ensure the file exists and is a valid .whl archive.", wheelPath, err)
 	}
 	defer reader.Close()
+	metadata, err := wi.parseWheelMetadata(&reader.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to parse wheel metadata for '%s': %w. The wheel may be corrupted or missing METADATA.", wheelPath, err)
+	}
+	if err := wi.checkWheelTags(metadata); err != nil {
+		return err
+	}
+	if err := wi.checkFileCollisions(&reader.Reader, metadata); err != nil {
+		return err
+	}
+	if wi.refresh {
+		// A hardlinked install shares its files with the extracted copy, so
+		// edits made in site-packages may have reached the cache too
+		if err := wi.unpacked.Remove(digest); err != nil {
+			return fmt.Errorf("failed to discard extracted copy of '%s': %w", wheelPath, err)
+		}
+	}
+	unpackedDir, err := wi.unpacked.Ensure(digest, func(dir string) error {
+		scratch := []string{}
+		return wi.extractWheel(fsutil.OS, &reader.Reader, func(name string) string {
+			return filepath.Join(dir, filepath.FromSlash(name))
+		}, &scratch)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to extract wheel '%s' into cache: %w. Check disk space and permissions.", wheelPath, err)
+	}
+	sitePackages := wi.getSitePackagesPath()
+	if err := wi.linkTree(unpackedDir, metadata, createdPaths); err != nil {
+		return err
+	}
+	if err := wi.rewriteScripts(fsutil.OS, &reader.Reader, metadata); err != nil {
+		return err
+	}
+	if err := wi.installMetadata(sitePackages, metadata, createdPaths); err != nil {
+		return err
+	}
+	return wi.installScripts(metadata, createdPaths)
+}
+
+// linkTree places the files of an extracted wheel under source into the
+// install scheme using the installer's link mode
+func (wi *WheelInstaller) linkTree(source string, metadata *WheelMetadata, createdPaths *[]string) error {
+	return filepath.WalkDir(source, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil || rel == "." || d.IsDir() {
+			return err
+		}
+		targetPath := wi.installPath(filepath.ToSlash(rel), metadata)
+		parentDir := filepath.Dir(targetPath)
+		if err := trackMkdirAll(fsutil.OS, parentDir, 0755, createdPaths); err != nil {
+			return fmt.Errorf("failed to create directory '%s': %w. Check permissions.", parentDir, err)
+		}
+		os.Remove(targetPath)
+		linkMode := wi.linkMode
+		if isScriptPath(filepath.ToSlash(rel)) {
+			// Scripts get their shebangs rewritten, which must not reach the cache
+			linkMode = cache.LinkModeCopy
+		}
+		if err := cache.LinkFile(path, targetPath, linkMode); err != nil {
+			return fmt.Errorf("failed to link '%s' to '%s': %w. Check disk space and permissions.", rel, targetPath, err)
+		}
+		*createdPaths = append(*createdPaths, targetPath)
+		return nil
+	})
+}
+
+// InstallWheelTracked is like InstallWheel but takes createdPaths for rollback
+func (wi *WheelInstaller) InstallWheelTracked(wheelPath, packageName string, createdPaths *[]string) error {
+	reader, closer, err := fsutil.OpenZip(wi.fs, wheelPath)
+	if err != nil {
+		return fmt.Errorf("failed to open wheel file '%s': %w. Ensure the file exists and is a valid .whl archive.", wheelPath, err)
+	}
+	defer closer.Close()
 	metadata, err := wi.parseWheelMetadata(reader)
 	if err != nil {
 		return fmt.Errorf("failed to parse wheel metadata for '%s': %w. The wheel may be corrupted or missing METADATA.", wheelPath, err)
 	}
+	if err := checkWheelFilename(wheelPath, metadata); err != nil {
+		return err
+	}
+	if err := wi.checkWheelTags(metadata); err != nil {
+		return err
+	}
+	if err := wi.checkFileCollisions(reader, metadata); err != nil {
+		return err
+	}
 	sitePackages := wi.getSitePackagesPath()
-	if err := wi.extractWheel(reader, sitePackages, metadata, createdPaths); err != nil {
+	if err := wi.extractWheel(wi.fs, reader, wi.schemeDest(metadata), createdPaths); err != nil {
+		return remedy.Annotate(err)
+	}
+	if err := wi.rewriteScripts(wi.fs, reader, metadata); err != nil {
 		return err
 	}
 	if err := wi.installMetadata(sitePackages, metadata, createdPaths); err != nil {
 		return err
 	}
-	return nil
+	return wi.installScripts(metadata, createdPaths)
 } 
\ No newline at end of file
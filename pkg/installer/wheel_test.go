@@ -2,9 +2,14 @@ package installer
 
 import (
 	"archive/zip"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/cache"
+	"rimraf-adi.com/zephyr/pkg/fsutil"
+	"rimraf-adi.com/zephyr/pkg/installer/wheeltest"
 )
 
 func createTestWheel(t *testing.T, dir, name string) string {
@@ -57,4 +62,148 @@ func TestInstallWheel_InvalidWheel(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for invalid wheel, got nil")
 	}
-} 
\ No newline at end of file
+}
+
+func TestInstallCachedWheel_Hardlink(t *testing.T) {
+	dir := t.TempDir()
+	venvPath := filepath.Join(dir, "venv")
+	os.MkdirAll(venvPath, 0755)
+	wi := NewWheelInstaller(venvPath)
+	wi.SetCache(cache.NewArtifactCache(filepath.Join(dir, "cache", "artifacts")))
+	wi.SetUnpackedCache(cache.NewUnpackedCache(filepath.Join(dir, "cache", "unpacked")))
+	wi.SetLinkMode(cache.LinkModeHardlink)
+	wheelPath := createTestWheel(t, dir, "foo-1.0.0-py3-none-any.whl")
+	digest, err := wi.cache.StoreFile(wheelPath, "")
+	if err != nil {
+		t.Fatalf("StoreFile failed: %v", err)
+	}
+	if err := wi.InstallCachedWheel(digest, "foo"); err != nil {
+		t.Fatalf("InstallCachedWheel failed: %v", err)
+	}
+	installed := filepath.Join(venvPath, "lib", "python3.11", "site-packages", "foo", "__init__.py")
+	installedInfo, err := os.Stat(installed)
+	if err != nil {
+		t.Fatalf("Package file not installed: %v", err)
+	}
+	cachedInfo, err := os.Stat(filepath.Join(wi.unpacked.Path(digest), "foo", "__init__.py"))
+	if err != nil {
+		t.Fatalf("Wheel not extracted into cache: %v", err)
+	}
+	if !os.SameFile(installedInfo, cachedInfo) {
+		t.Error("Expected installed file to be hard-linked to the cached copy")
+	}
+}
+
+func TestInstallCachedWheel_RefreshReextracts(t *testing.T) {
+	dir := t.TempDir()
+	venvPath := filepath.Join(dir, "venv")
+	wi := NewWheelInstaller(venvPath)
+	wi.SetCache(cache.NewArtifactCache(filepath.Join(dir, "cache", "artifacts")))
+	wi.SetUnpackedCache(cache.NewUnpackedCache(filepath.Join(dir, "cache", "unpacked")))
+	wi.SetLinkMode(cache.LinkModeHardlink)
+	digest, err := wi.cache.StoreFile(createTestWheel(t, dir, "foo-1.0.0-py3-none-any.whl"), "")
+	if err != nil {
+		t.Fatalf("StoreFile failed: %v", err)
+	}
+	if err := wi.InstallCachedWheel(digest, "foo"); err != nil {
+		t.Fatalf("InstallCachedWheel failed: %v", err)
+	}
+	// Patching the hardlinked install patches the extracted copy as well
+	installed := filepath.Join(venvPath, "lib", "python3.11", "site-packages", "foo", "__init__.py")
+	os.Chmod(installed, 0644)
+	if err := os.WriteFile(installed, []byte("patched"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wi.SetRefresh(true)
+	if err := wi.InstallCachedWheel(digest, "foo"); err != nil {
+		t.Fatalf("InstallCachedWheel with refresh failed: %v", err)
+	}
+	if content, _ := os.ReadFile(installed); string(content) != "# test package" {
+		t.Errorf("reinstalled file = %q, want the wheel's content", content)
+	}
+}
+
+func TestInstallWheel_FileCollisions(t *testing.T) {
+	dir := t.TempDir()
+	wi := NewWheelInstaller(filepath.Join(dir, "venv"))
+	sitePackages := wi.getSitePackagesPath()
+	nsInit := "__path__ = __import__('pkgutil').extend_path(__path__, __name__)\n"
+	if err := wi.InstallWheel(wheeltest.Wheel{Name: "alpha", Files: map[string]string{
+		"ns/__init__.py":       nsInit,
+		"ns/alpha/__init__.py": "",
+		"shared/data.txt":      "alpha",
+	}}.Build(t, dir), "alpha"); err != nil {
+		t.Fatalf("InstallWheel failed: %v", err)
+	}
+
+	// Namespace packages share an identical __init__.py; shared/data.txt differs
+	beta := wheeltest.Wheel{Name: "beta", Files: map[string]string{
+		"ns/__init__.py":      nsInit,
+		"ns/beta/__init__.py": "",
+		"shared/data.txt":     "beta",
+	}}.Build(t, dir)
+	err := wi.InstallWheel(beta, "beta")
+	var collision *FileCollisionError
+	if !errors.As(err, &collision) {
+		t.Fatalf("expected FileCollisionError, got %v", err)
+	}
+	if len(collision.Collisions) != 1 || collision.Collisions[0] != (FileCollision{Path: "shared/data.txt", Owner: "alpha"}) {
+		t.Errorf("unexpected collisions: %+v", collision.Collisions)
+	}
+	if _, err := os.Stat(filepath.Join(sitePackages, "ns", "beta")); err == nil {
+		t.Error("nothing should be installed when files collide")
+	}
+
+	wi.SetAllowOverwrite(true)
+	if err := wi.InstallWheel(beta, "beta"); err != nil {
+		t.Fatalf("InstallWheel with overwrite failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(sitePackages, "shared", "data.txt")); string(data) != "beta" {
+		t.Errorf("data.txt = %q, expected beta's copy", data)
+	}
+	owners, err := wi.fileOwners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owners["shared/data.txt"] != "beta" || owners["ns/alpha/__init__.py"] != "alpha" {
+		t.Errorf("unexpected owners: %v", owners)
+	}
+
+	// The recorded owner is respected by later installs
+	wi.SetAllowOverwrite(false)
+	if err := wi.InstallWheel(beta, "beta"); err != nil {
+		t.Errorf("reinstalling the new owner failed: %v", err)
+	}
+	err = wi.InstallWheel(wheeltest.Wheel{Name: "alpha", Files: map[string]string{"shared/data.txt": "alpha"}}.Build(t, dir), "alpha")
+	if !errors.As(err, &collision) || collision.Collisions[0].Owner != "beta" {
+		t.Errorf("expected collision with beta, got %v", err)
+	}
+}
+
+func TestInstallWheel_MemFS(t *testing.T) {
+	dir := t.TempDir()
+	wheelPath := createTestWheel(t, dir, "foo-1.0.0-py3-none-any.whl")
+	data, err := os.ReadFile(wheelPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fsutil.NewMemFS()
+	fsys.MkdirAll("/work/venv", 0755)
+	fsys.WriteFile("/work/foo-1.0.0-py3-none-any.whl", data, 0644)
+
+	wi := NewWheelInstaller("/work/venv")
+	wi.SetFS(fsys)
+	if err := wi.InstallWheel("/work/foo-1.0.0-py3-none-any.whl", "foo"); err != nil {
+		t.Fatalf("InstallWheel failed: %v", err)
+	}
+	sitePackages := filepath.Join("/work/venv", "lib", "python3.11", "site-packages")
+	if content, err := fsys.ReadFile(filepath.Join(sitePackages, "foo", "__init__.py")); err != nil || string(content) != "# test package" {
+		t.Errorf("Package file not installed in memory: %q %v", content, err)
+	}
+	if _, err := fsys.Stat(filepath.Join(sitePackages, "foo-1.0.0.dist-info", "RECORD")); err != nil {
+		t.Errorf("RECORD not written: %v", err)
+	}
+	if _, err := os.Stat("/work/venv"); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing on disk, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package installer
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/markers"
+	"rimraf-adi.com/zephyr/pkg/pypi"
+)
+
+// supportedWheelMajor is the Wheel-Version major this installer understands
+const supportedWheelMajor = "1"
+
+// WheelFile holds the fields of a wheel's .dist-info/WHEEL file
+type WheelFile struct {
+	WheelVersion  string
+	Generator     string
+	RootIsPurelib bool
+	Build         string
+	Tags          []string
+}
+
+// ParseWheelFile parses the contents of a WHEEL file and rejects wheel
+// versions this installer cannot handle
+func ParseWheelFile(content string) (*WheelFile, error) {
+	wf := &WheelFile{}
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "wheel-version":
+			wf.WheelVersion = value
+		case "generator":
+			wf.Generator = value
+		case "root-is-purelib":
+			wf.RootIsPurelib = strings.EqualFold(value, "true")
+		case "build":
+			wf.Build = value
+		case "tag":
+			wf.Tags = append(wf.Tags, value)
+		}
+	}
+	if wf.WheelVersion == "" {
+		return nil, fmt.Errorf("WHEEL file has no Wheel-Version")
+	}
+	major, _, _ := strings.Cut(wf.WheelVersion, ".")
+	if major != supportedWheelMajor {
+		return nil, fmt.Errorf("unsupported Wheel-Version %s (zephyr supports %s.x)", wf.WheelVersion, supportedWheelMajor)
+	}
+	return wf, nil
+}
+
+// CompatibleWith reports whether any of the wheel's tags installs on target.
+// Wheels that declare no tags are accepted.
+func (wf *WheelFile) CompatibleWith(target markers.Target) bool {
+	if len(wf.Tags) == 0 {
+		return true
+	}
+	for _, tag := range wf.Tags {
+		parts := strings.Split(tag, "-")
+		if len(parts) != 3 {
+			continue
+		}
+		wheel := &pypi.WheelFilename{
+			PythonTags:   strings.Split(parts[0], "."),
+			ABITags:      strings.Split(parts[1], "."),
+			PlatformTags: strings.Split(parts[2], "."),
+		}
+		if wheel.CompatibleWith(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// IncompatibleWheelError reports a wheel whose tags do not match the environment
+type IncompatibleWheelError struct {
+	Package string
+	Tags    []string
+	Target  markers.Target
+}
+
+func (e *IncompatibleWheelError) Error() string {
+	return fmt.Sprintf("wheel for %s (tags %s) cannot be installed on %s. Use a wheel built for this platform and Python version", e.Package, strings.Join(e.Tags, ", "), e.Target)
+}
+
+// SetTarget sets the platform and Python version wheel tags are validated
+// against instead of the virtual environment's own interpreter
+func (wi *WheelInstaller) SetTarget(target markers.Target) {
+	wi.target = &target
+}
+
+// installTarget returns the environment wheels are installed into. It reports
+// false when the interpreter version cannot be determined.
+func (wi *WheelInstaller) installTarget() (markers.Target, bool) {
+	if wi.target != nil {
+		return *wi.target, true
+	}
+	version, err := InterpreterVersion(wi.interpreter())
+	if err != nil {
+		return markers.Target{}, false
+	}
+	target, err := markers.ParseTarget(fmt.Sprintf("%s-%s-%s", runtime.GOOS, runtime.GOARCH, version))
+	if err != nil {
+		return markers.Target{}, false
+	}
+	return target, true
+}
+
+// checkWheelTags refuses wheels built for another platform or Python version
+func (wi *WheelInstaller) checkWheelTags(metadata *WheelMetadata) error {
+	target, ok := wi.installTarget()
+	if !ok || metadata.Wheel.CompatibleWith(target) {
+		return nil
+	}
+	return &IncompatibleWheelError{Package: metadata.Name, Tags: metadata.Wheel.Tags, Target: target}
+}
+
+// installPath maps a wheel member to its location in the environment. Root
+// files go to purelib or platlib according to Root-Is-Purelib, and members of
+// the .data directory go to the install scheme path they are filed under.
+func (wi *WheelInstaller) installPath(name string, metadata *WheelMetadata) string {
+	root := wi.getPlatlibPath()
+	if metadata.Wheel == nil || metadata.Wheel.RootIsPurelib {
+		root = wi.getSitePackagesPath()
+	}
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) == 3 && strings.HasSuffix(parts[0], ".data") {
+		rest := filepath.FromSlash(parts[2])
+		switch parts[1] {
+		case "purelib":
+			return filepath.Join(wi.getSitePackagesPath(), rest)
+		case "platlib":
+			return filepath.Join(wi.getPlatlibPath(), rest)
+		case "scripts":
+			return filepath.Join(wi.binPath(), rest)
+		case "data":
+			if wi.scheme != nil {
+				return filepath.Join(wi.scheme.Data, rest)
+			}
+			return filepath.Join(wi.venvPath, rest)
+		case "headers":
+			if wi.scheme != nil {
+				return filepath.Join(wi.scheme.Headers, metadata.Name, rest)
+			}
+			return filepath.Join(wi.venvPath, "include", "site", wi.pythonDir(), metadata.Name, rest)
+		}
+	}
+	return filepath.Join(root, filepath.FromSlash(name))
+}
+
+// recordPath returns an installed file's path relative to site-packages, as written to RECORD
+func (wi *WheelInstaller) recordPath(target string) string {
+	rel, err := filepath.Rel(wi.getSitePackagesPath(), target)
+	if err != nil {
+		return filepath.ToSlash(target)
+	}
+	return path.Clean(filepath.ToSlash(rel))
+}
+
+// isScriptPath reports whether a wheel member is installed as an executable script
+func isScriptPath(name string) bool {
+	parts := strings.SplitN(name, "/", 3)
+	return len(parts) == 3 && strings.HasSuffix(parts[0], ".data") && parts[1] == "scripts"
+}
+
+// getPlatlibPath returns the directory for platform-specific packages. Virtual
+// environments usually share it with purelib; a separate lib64 tree is used
+// only when it exists and is not a link to lib.
+func (wi *WheelInstaller) getPlatlibPath() string {
+	if wi.scheme != nil {
+		return wi.scheme.Platlib
+	}
+	purelib := wi.getSitePackagesPath()
+	platlib := filepath.Join(wi.venvPath, "lib64", wi.pythonDir(), "site-packages")
+	info, err := wi.fs.Stat(platlib)
+	if err != nil || !info.IsDir() {
+		return purelib
+	}
+	resolvedPure, err1 := filepath.EvalSymlinks(purelib)
+	resolvedPlat, err2 := filepath.EvalSymlinks(platlib)
+	if err1 != nil || err2 != nil || resolvedPure == resolvedPlat {
+		return purelib
+	}
+	return platlib
+}
@@ -0,0 +1,94 @@
+package installer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/installer/wheeltest"
+	"rimraf-adi.com/zephyr/pkg/markers"
+)
+
+func TestParseWheelFile(t *testing.T) {
+	wf, err := ParseWheelFile("Wheel-Version: 1.0\nGenerator: bdist_wheel (0.41.2)\nRoot-Is-Purelib: false\nTag: cp311-cp311-manylinux_2_17_x86_64\nTag: cp311-cp311-manylinux2014_x86_64\n")
+	if err != nil {
+		t.Fatalf("ParseWheelFile failed: %v", err)
+	}
+	if wf.WheelVersion != "1.0" || wf.RootIsPurelib || len(wf.Tags) != 2 {
+		t.Errorf("unexpected WHEEL fields: %+v", wf)
+	}
+	if _, err := ParseWheelFile("Wheel-Version: 2.0\n"); err == nil {
+		t.Error("ParseWheelFile should reject Wheel-Version 2.0")
+	}
+	if _, err := ParseWheelFile("Generator: test\n"); err == nil {
+		t.Error("ParseWheelFile should require Wheel-Version")
+	}
+}
+
+func TestWheelFileCompatibleWith(t *testing.T) {
+	linux, _ := markers.ParseTarget("linux-x86_64-3.11")
+	windows, _ := markers.ParseTarget("windows-x86_64-3.11")
+	wf := &WheelFile{Tags: []string{"cp311-cp311-manylinux_2_17_x86_64"}}
+	if !wf.CompatibleWith(linux) || wf.CompatibleWith(windows) {
+		t.Error("manylinux wheel should only match linux")
+	}
+	if pure := (&WheelFile{Tags: []string{"py3-none-any"}}); !pure.CompatibleWith(windows) {
+		t.Error("pure wheel should match any target")
+	}
+}
+
+func TestInstallWheel_RejectsIncompatibleTags(t *testing.T) {
+	dir := t.TempDir()
+	wi := NewWheelInstaller(filepath.Join(dir, "venv"))
+	target, _ := markers.ParseTarget("macos-arm64-3.11")
+	wi.SetTarget(target)
+	wheel := wheeltest.Wheel{Name: "demo", Tag: "cp311-cp311-win_amd64", Files: map[string]string{"demo/__init__.py": ""}}.Build(t, dir)
+	err := wi.InstallWheel(wheel, "demo")
+	var incompatible *IncompatibleWheelError
+	if !errors.As(err, &incompatible) {
+		t.Fatalf("expected IncompatibleWheelError, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(wi.getSitePackagesPath(), "demo")); err == nil {
+		t.Error("incompatible wheel should not be installed")
+	}
+}
+
+func TestInstallWheel_DataScheme(t *testing.T) {
+	dir := t.TempDir()
+	venvPath := filepath.Join(dir, "venv")
+	wi := NewWheelInstaller(venvPath)
+	wheel := wheeltest.Wheel{Name: "demo", Files: map[string]string{
+		"demo/__init__.py":                      "",
+		"demo-1.0.0.data/purelib/demo_extra.py": "",
+		"demo-1.0.0.data/scripts/demo-tool":     "#!/bin/sh\necho demo\n",
+		"demo-1.0.0.data/data/share/demo.txt":   "shared",
+		"demo-1.0.0.data/headers/demo.h":        "",
+	}}.Build(t, dir)
+	if err := wi.InstallWheel(wheel, "demo"); err != nil {
+		t.Fatalf("InstallWheel failed: %v", err)
+	}
+	sitePackages := wi.getSitePackagesPath()
+	for _, path := range []string{
+		filepath.Join(sitePackages, "demo", "__init__.py"),
+		filepath.Join(sitePackages, "demo_extra.py"),
+		filepath.Join(venvPath, "share", "demo.txt"),
+		filepath.Join(venvPath, "include", "site", "python3.11", "demo", "demo.h"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s: %v", path, err)
+		}
+	}
+	info, err := os.Stat(filepath.Join(wi.binPath(), "demo-tool"))
+	if err != nil || info.Mode()&0111 == 0 {
+		t.Errorf("script not installed as executable: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(sitePackages, "demo-1.0.0.data")); err == nil {
+		t.Error(".data directory should not be copied into site-packages")
+	}
+	record, _ := os.ReadFile(filepath.Join(sitePackages, "demo-1.0.0.dist-info", "RECORD"))
+	if !strings.Contains(string(record), "\ndemo_extra.py,") || !strings.Contains(string(record), "\n../../../bin/demo-tool,") {
+		t.Errorf("RECORD missing installed paths:\n%s", record)
+	}
+}
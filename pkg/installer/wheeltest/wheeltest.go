@@ -0,0 +1,107 @@
+// Package wheeltest builds wheels for tests.
+package wheeltest
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Wheel describes a wheel to build. METADATA, WHEEL and RECORD are
+// generated unless Files holds them.
+type Wheel struct {
+	// Name is the distribution name as written in the filename
+	Name string
+	// Version defaults to 1.0.0
+	Version string
+	// Tag is the compatibility tag, py3-none-any by default
+	Tag string
+	// Metadata holds extra METADATA lines, such as Requires-Dist
+	Metadata string
+	// Files maps member paths to their contents
+	Files map[string]string
+	// Symlinks maps member paths to the targets of symlink members
+	Symlinks map[string]string
+}
+
+// DistInfo returns the wheel's .dist-info directory, with a trailing slash
+func (w Wheel) DistInfo() string {
+	return w.Name + "-" + w.version() + ".dist-info/"
+}
+
+func (w Wheel) version() string {
+	if w.Version == "" {
+		return "1.0.0"
+	}
+	return w.Version
+}
+
+func (w Wheel) tag() string {
+	if w.Tag == "" {
+		return "py3-none-any"
+	}
+	return w.Tag
+}
+
+// Build writes the wheel into dir and returns its path
+func (w Wheel) Build(t testing.TB, dir string) string {
+	t.Helper()
+	members := map[string]string{
+		w.DistInfo() + "METADATA": "Metadata-Version: 2.1\nName: " + w.Name + "\nVersion: " + w.version() + "\n" + w.Metadata,
+		w.DistInfo() + "WHEEL":    fmt.Sprintf("Wheel-Version: 1.0\nGenerator: zephyr-test\nRoot-Is-Purelib: %t\nTag: %s\n", strings.HasSuffix(w.tag(), "-none-any"), w.tag()),
+	}
+	for name, content := range w.Files {
+		members[name] = content
+	}
+	names := make([]string, 0, len(members)+len(w.Symlinks))
+	for name := range members {
+		names = append(names, name)
+	}
+	for name := range w.Symlinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// PEP 427 escapes "-" in the filename's name component
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s-%s.whl", strings.ReplaceAll(w.Name, "-", "_"), w.version(), w.tag()))
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create wheel: %v", err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	var record []string
+	for _, name := range names {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		content, isLink := w.Symlinks[name]
+		if isLink {
+			header.SetMode(os.ModeSymlink | 0777)
+		} else {
+			content = members[name]
+		}
+		member, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		member.Write([]byte(content))
+		digest := sha256.Sum256([]byte(content))
+		record = append(record, fmt.Sprintf("%s,sha256=%s,%d", name, base64.RawURLEncoding.EncodeToString(digest[:]), len(content)))
+	}
+	if _, ok := w.Files[w.DistInfo()+"RECORD"]; !ok {
+		member, err := zw.Create(w.DistInfo() + "RECORD")
+		if err != nil {
+			t.Fatalf("Failed to add RECORD: %v", err)
+		}
+		member.Write([]byte(strings.Join(append(record, w.DistInfo()+"RECORD,,"), "\n") + "\n"))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to write wheel: %v", err)
+	}
+	return path
+}
@@ -0,0 +1,362 @@
+// Package interpreters downloads and manages standalone CPython builds from
+// the python-build-standalone project. Each build is unpacked under
+// <data dir>/python/cpython-<version>-<os>-<arch> and can be used to create
+// virtual environments when no suitable system interpreter exists.
+package interpreters
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/dirs"
+	"rimraf-adi.com/zephyr/pkg/flock"
+	"rimraf-adi.com/zephyr/pkg/netutil"
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// DefaultReleasesURL lists the latest python-build-standalone release in the
+// GitHub releases API format
+const DefaultReleasesURL = "https://api.github.com/repos/astral-sh/python-build-standalone/releases/latest"
+
+// Installation is a managed interpreter
+type Installation struct {
+	Version string
+	// Dir is the directory the build was unpacked into
+	Dir string
+}
+
+// Executable returns the path of the installation's python executable
+func (i *Installation) Executable() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(i.Dir, "python", "python.exe")
+	}
+	return filepath.Join(i.Dir, "python", "bin", "python3")
+}
+
+// Manager installs interpreters into Root from the release listed at ReleasesURL
+type Manager struct {
+	Root        string
+	ReleasesURL string
+	GOOS        string
+	GOARCH      string
+}
+
+// NewManager returns a manager for the interpreters in the data directory,
+// downloading from python_downloads_url when it is configured
+func NewManager() *Manager {
+	releases := DefaultReleasesURL
+	if cfg, _ := netutil.LoadConfig(); cfg != nil && cfg.PythonDownloadsURL != "" {
+		releases = cfg.PythonDownloadsURL
+	}
+	return &Manager{
+		Root:        filepath.Join(dirs.DataDir(), "python"),
+		ReleasesURL: releases,
+		GOOS:        runtime.GOOS,
+		GOARCH:      runtime.GOARCH,
+	}
+}
+
+// List returns the installed interpreters for this platform, newest first
+func (m *Manager) List() ([]*Installation, error) {
+	entries, err := os.ReadDir(m.Root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", m.Root, err)
+	}
+	suffix := "-" + m.GOOS + "-" + m.GOARCH
+	var installs []*Installation
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !strings.HasPrefix(name, "cpython-") || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		installs = append(installs, &Installation{
+			Version: strings.TrimSuffix(strings.TrimPrefix(name, "cpython-"), suffix),
+			Dir:     filepath.Join(m.Root, name),
+		})
+	}
+	sort.Slice(installs, func(i, j int) bool { return version.Compare(installs[i].Version, installs[j].Version) > 0 })
+	return installs, nil
+}
+
+// Find returns the newest installed interpreter matching request, a version
+// prefix such as "3.12" (empty matches any), and the requires-python specifier
+func (m *Manager) Find(request, requires string) (*Installation, error) {
+	installs, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, install := range installs {
+		if matchesRequest(install.Version, request) && satisfies(install.Version, requires) {
+			return install, nil
+		}
+	}
+	return nil, nil
+}
+
+// Uninstall removes the installed interpreter with exactly the given version
+func (m *Manager) Uninstall(v string) error {
+	dir := filepath.Join(m.Root, m.dirName(v))
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("Python %s is not installed. List installed versions with: zephyr python list", v)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", dir, err)
+	}
+	return nil
+}
+
+// Install downloads the newest build matching request, verifies it against
+// the release's SHA256SUMS and unpacks it. An already installed match is
+// returned as is.
+func (m *Manager) Install(request string) (*Installation, bool, error) {
+	if existing, err := m.Find(request, ""); err != nil || existing != nil {
+		return existing, false, err
+	}
+	triple, err := m.triple()
+	if err != nil {
+		return nil, false, err
+	}
+	release, err := m.fetchRelease()
+	if err != nil {
+		return nil, false, err
+	}
+	asset, v := release.find(request, triple)
+	if asset == nil {
+		return nil, false, fmt.Errorf("release %s has no CPython %s build for %s. Request a version listed at %s", release.Tag, request, triple, m.ReleasesURL)
+	}
+
+	if err := os.MkdirAll(m.Root, 0755); err != nil {
+		return nil, false, fmt.Errorf("failed to create %s: %w", m.Root, err)
+	}
+	lock, err := flock.Acquire(filepath.Join(m.Root, ".lock"))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to lock %s: %w", m.Root, err)
+	}
+	defer lock.Release()
+	install := &Installation{Version: v, Dir: filepath.Join(m.Root, m.dirName(v))}
+	// Another process may have installed it while we waited for the lock
+	if _, err := os.Stat(install.Executable()); err == nil {
+		return install, false, nil
+	}
+
+	expected, err := release.checksum(asset.Name)
+	if err != nil {
+		return nil, false, err
+	}
+	staging, err := os.MkdirTemp(m.Root, ".incoming-*")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+	// Builds are large, so the archive is kept outside staging until it is
+	// complete and a download cut short resumes on the next attempt
+	downloads := filepath.Join(m.Root, ".downloads")
+	if err := os.MkdirAll(downloads, 0755); err != nil {
+		return nil, false, fmt.Errorf("failed to create %s: %w", downloads, err)
+	}
+	archive := filepath.Join(downloads, asset.Name)
+	defer os.Remove(archive)
+	err = netutil.DownloadFile(netutil.NewHTTPClient(0), asset.URL, archive, netutil.DownloadOptions{SHA256: expected, Resume: true})
+	var mismatch *netutil.DigestMismatchError
+	if errors.As(err, &mismatch) {
+		return nil, false, fmt.Errorf("%s has sha256 %s but SHA256SUMS lists %s. The download was corrupted or tampered with; try again", asset.Name, mismatch.Actual, expected)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to download %s: %w", asset.URL, err)
+	}
+	unpacked := filepath.Join(staging, "unpacked")
+	if err := extractTarGz(archive, unpacked); err != nil {
+		return nil, false, fmt.Errorf("failed to unpack %s: %w", asset.Name, err)
+	}
+	if err := os.Rename(unpacked, install.Dir); err != nil {
+		return nil, false, fmt.Errorf("failed to move Python %s into place: %w", v, err)
+	}
+	return install, true, nil
+}
+
+// dirName returns the directory an interpreter version is unpacked into
+func (m *Manager) dirName(v string) string {
+	return "cpython-" + v + "-" + m.GOOS + "-" + m.GOARCH
+}
+
+// triple returns the target triple python-build-standalone names builds for this platform with
+func (m *Manager) triple() (string, error) {
+	arch := map[string]string{"amd64": "x86_64", "arm64": "aarch64"}[m.GOARCH]
+	if arch == "" {
+		return "", fmt.Errorf("no managed Python builds for %s/%s", m.GOOS, m.GOARCH)
+	}
+	switch m.GOOS {
+	case "linux":
+		return arch + "-unknown-linux-gnu", nil
+	case "darwin":
+		return arch + "-apple-darwin", nil
+	case "windows":
+		return arch + "-pc-windows-msvc", nil
+	}
+	return "", fmt.Errorf("no managed Python builds for %s/%s", m.GOOS, m.GOARCH)
+}
+
+// release is the subset of the GitHub releases API zephyr reads
+type release struct {
+	Tag    string  `json:"tag_name"`
+	Assets []asset `json:"assets"`
+}
+
+// asset is a file attached to a release
+type asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// assetPattern matches install_only builds, e.g.
+// cpython-3.12.4+20240713-x86_64-unknown-linux-gnu-install_only.tar.gz
+var assetPattern = regexp.MustCompile(`^cpython-(\d+\.\d+\.\d+)\+[^-]+-(.+)-install_only\.tar\.gz$`)
+
+// fetchRelease reads the release listing
+func (m *Manager) fetchRelease() (*release, error) {
+	resp, err := netutil.NewHTTPClient(0).Get(m.ReleasesURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Python releases from %s: %w", m.ReleasesURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch Python releases from %s: HTTP %d", m.ReleasesURL, resp.StatusCode)
+	}
+	var r release
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to parse Python releases from %s: %w", m.ReleasesURL, err)
+	}
+	return &r, nil
+}
+
+// find returns the newest install_only asset for triple matching request
+func (r *release) find(request, triple string) (*asset, string) {
+	var best *asset
+	bestVersion := ""
+	for i, a := range r.Assets {
+		match := assetPattern.FindStringSubmatch(a.Name)
+		if match == nil || match[2] != triple || !matchesRequest(match[1], request) {
+			continue
+		}
+		if best == nil || version.Compare(match[1], bestVersion) > 0 {
+			best, bestVersion = &r.Assets[i], match[1]
+		}
+	}
+	return best, bestVersion
+}
+
+// checksum returns the digest SHA256SUMS lists for name, or "" when the
+// release publishes no SHA256SUMS
+func (r *release) checksum(name string) (string, error) {
+	for _, a := range r.Assets {
+		if a.Name != "SHA256SUMS" {
+			continue
+		}
+		resp, err := netutil.NewHTTPClient(0).Get(a.URL)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch SHA256SUMS: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("failed to fetch SHA256SUMS: HTTP %d", resp.StatusCode)
+		}
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("failed to read SHA256SUMS: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+				return strings.ToLower(fields[0]), nil
+			}
+		}
+		return "", fmt.Errorf("SHA256SUMS does not list %s", name)
+	}
+	return "", nil
+}
+
+// extractTarGz unpacks a gzipped tarball into dest, refusing entries that
+// would land outside it
+func extractTarGz(archive, dest string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("archive entry %q escapes the install directory", header.Name)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if filepath.IsAbs(header.Linkname) || !strings.HasPrefix(filepath.Join(filepath.Dir(target), header.Linkname), filepath.Clean(dest)+string(os.PathSeparator)) {
+				return fmt.Errorf("archive symlink %q points outside the install directory", header.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode)&0777|0600)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// matchesRequest reports whether a full version matches a version prefix such as "3.12"
+func matchesRequest(v, request string) bool {
+	request = strings.TrimPrefix(strings.TrimPrefix(request, "cpython"), "python")
+	return request == "" || v == request || strings.HasPrefix(v, request+".")
+}
+
+// satisfies reports whether v is allowed by a requires-python specifier
+func satisfies(v, requires string) bool {
+	ok, err := version.Satisfies(v, requires)
+	return err != nil || ok
+}
@@ -0,0 +1,131 @@
+package interpreters
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildTarball returns a gzipped tarball laid out like an install_only build
+func buildTarball(t *testing.T, entries map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range entries {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// newReleaseServer serves a release with builds of the given versions for linux x86_64
+func newReleaseServer(t *testing.T, tarball []byte, sums string, versions ...string) *httptest.Server {
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	mux.HandleFunc("/release", func(w http.ResponseWriter, r *http.Request) {
+		var assets []string
+		for _, v := range versions {
+			name := fmt.Sprintf("cpython-%s+20240713-x86_64-unknown-linux-gnu-install_only.tar.gz", v)
+			assets = append(assets, fmt.Sprintf(`{"name": %q, "browser_download_url": %q}`, name, server.URL+"/download/"+name))
+		}
+		assets = append(assets, fmt.Sprintf(`{"name": "SHA256SUMS", "browser_download_url": %q}`, server.URL+"/SHA256SUMS"))
+		fmt.Fprintf(w, `{"tag_name": "20240713", "assets": [%s]}`, strings.Join(assets, ","))
+	})
+	mux.HandleFunc("/download/", func(w http.ResponseWriter, r *http.Request) { w.Write(tarball) })
+	mux.HandleFunc("/SHA256SUMS", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(sums)) })
+	server = httptest.NewServer(mux)
+	return server
+}
+
+func testManager(t *testing.T, server *httptest.Server) *Manager {
+	return &Manager{Root: t.TempDir(), ReleasesURL: server.URL + "/release", GOOS: "linux", GOARCH: "amd64"}
+}
+
+func TestInstall(t *testing.T) {
+	tarball := buildTarball(t, map[string]string{"python/bin/python3": "#!/bin/sh\necho Python 3.12.4\n"})
+	sum := sha256.Sum256(tarball)
+	name := "cpython-3.12.4+20240713-x86_64-unknown-linux-gnu-install_only.tar.gz"
+	server := newReleaseServer(t, tarball, hex.EncodeToString(sum[:])+"  "+name+"\n", "3.11.9", "3.12.3", "3.12.4")
+	defer server.Close()
+	m := testManager(t, server)
+
+	install, downloaded, err := m.Install("3.12")
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if !downloaded || install.Version != "3.12.4" {
+		t.Errorf("Install = %+v, downloaded %v", install, downloaded)
+	}
+	if _, err := os.Stat(install.Executable()); err != nil {
+		t.Errorf("interpreter not unpacked: %v", err)
+	}
+
+	// A second install of a matching version is a no-op
+	if again, downloaded, err := m.Install("3.12"); err != nil || downloaded || again.Dir != install.Dir {
+		t.Errorf("re-install = %+v, %v, %v", again, downloaded, err)
+	}
+	if found, err := m.Find("", ">=3.12"); err != nil || found == nil || found.Version != "3.12.4" {
+		t.Errorf("Find = %+v, %v", found, err)
+	}
+	if found, _ := m.Find("3.11", ""); found != nil {
+		t.Errorf("Find(3.11) = %+v", found)
+	}
+
+	if err := m.Uninstall("3.12.4"); err != nil {
+		t.Fatalf("Uninstall failed: %v", err)
+	}
+	if installs, _ := m.List(); len(installs) != 0 {
+		t.Errorf("List after uninstall = %+v", installs)
+	}
+}
+
+func TestInstall_ChecksumMismatch(t *testing.T) {
+	tarball := buildTarball(t, map[string]string{"python/bin/python3": "x"})
+	name := "cpython-3.12.4+20240713-x86_64-unknown-linux-gnu-install_only.tar.gz"
+	server := newReleaseServer(t, tarball, strings.Repeat("0", 64)+"  "+name+"\n", "3.12.4")
+	defer server.Close()
+	m := testManager(t, server)
+
+	if _, _, err := m.Install("3.12"); err == nil || !strings.Contains(err.Error(), "SHA256SUMS") {
+		t.Fatalf("expected a checksum error, got %v", err)
+	}
+	if installs, _ := m.List(); len(installs) != 0 {
+		t.Errorf("a corrupted build was installed: %+v", installs)
+	}
+}
+
+func TestInstall_NoBuild(t *testing.T) {
+	server := newReleaseServer(t, nil, "", "3.12.4")
+	defer server.Close()
+	m := testManager(t, server)
+	if _, _, err := m.Install("3.9"); err == nil {
+		t.Error("expected an error for a version without a build")
+	}
+	m.GOARCH = "mips"
+	if _, _, err := m.Install("3.12"); err == nil {
+		t.Error("expected an error for an unsupported platform")
+	}
+}
+
+func TestExtractTarGz_RejectsTraversal(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "evil.tar.gz")
+	os.WriteFile(archive, buildTarball(t, map[string]string{"../escape": "x"}), 0644)
+	if err := extractTarGz(archive, filepath.Join(dir, "out")); err == nil {
+		t.Error("expected an error for an entry outside the destination")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape")); err == nil {
+		t.Error("entry was written outside the destination")
+	}
+}
@@ -0,0 +1,262 @@
+// Package jsonrpc serves JSON-RPC 2.0 over a byte stream such as stdio or a
+// local socket. Messages may be framed with LSP-style Content-Length headers
+// or sent one per line; replies use the framing of the request.
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Standard JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Error is a JSON-RPC error object. Handlers return one to choose the code
+// and attach data; any other error is reported as an internal error.
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// InvalidParams returns an error for params a handler cannot use
+func InvalidParams(format string, args ...interface{}) *Error {
+	return &Error{Code: CodeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// Handler answers one method; params is the raw params member, or nil
+type Handler func(params json.RawMessage) (interface{}, error)
+
+// ShutdownMethod ends the connection after its reply is sent
+const ShutdownMethod = "shutdown"
+
+// Server dispatches requests to registered handlers. Requests on one
+// connection run concurrently, so a slow resolve does not hold up
+// completions, and replies may arrive out of order.
+type Server struct {
+	methods map[string]Handler
+}
+
+// NewServer returns a server with no methods
+func NewServer() *Server {
+	return &Server{methods: make(map[string]Handler)}
+}
+
+// Register makes handler answer method
+func (s *Server) Register(method string, handler Handler) {
+	s.methods[method] = handler
+}
+
+// Methods lists the registered method names, sorted
+func (s *Server) Methods() []string {
+	names := make([]string, 0, len(s.methods))
+	for name := range s.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// conn reads messages from r and writes replies to w, one at a time
+type conn struct {
+	reader  *bufio.Reader
+	mu      sync.Mutex
+	w       io.Writer
+	headers bool
+}
+
+// Serve handles messages from r until it is exhausted, a shutdown request
+// is answered, or reading fails. It waits for running handlers to reply.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	c := &conn{reader: bufio.NewReader(r), w: w}
+	var running sync.WaitGroup
+	defer running.Wait()
+	for {
+		data, err := c.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.reply(response{ID: json.RawMessage("null"), Error: &Error{Code: CodeParseError, Message: "invalid JSON: " + err.Error()}})
+			continue
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			c.reply(response{ID: nullID(req.ID), Error: &Error{Code: CodeInvalidRequest, Message: `expected a "jsonrpc": "2.0" request with a method`}})
+			continue
+		}
+		if req.Method == ShutdownMethod {
+			running.Wait()
+			if req.ID != nil {
+				c.reply(response{ID: req.ID, Result: true})
+			}
+			return nil
+		}
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			result, err := s.call(req)
+			if req.ID == nil {
+				// Notifications get no reply
+				return
+			}
+			c.reply(response{ID: req.ID, Result: result, Error: err})
+		}()
+	}
+}
+
+// ServeListener serves every connection accepted from l until it is closed
+func (s *Server) ServeListener(l net.Listener) error {
+	for {
+		nc, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer nc.Close()
+			s.Serve(nc, nc)
+		}()
+	}
+}
+
+// call runs the handler for req, turning panics and plain errors into
+// JSON-RPC errors so one bad request cannot take the server down
+func (s *Server) call(req request) (result interface{}, rpcErr *Error) {
+	handler, ok := s.methods[req.Method]
+	if !ok {
+		return nil, &Error{Code: CodeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			result, rpcErr = nil, &Error{Code: CodeInternalError, Message: fmt.Sprint(r)}
+		}
+	}()
+	result, err := handler(req.Params)
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			return nil, e
+		}
+		return nil, &Error{Code: CodeInternalError, Message: err.Error()}
+	}
+	if result == nil {
+		// A successful reply must carry a result member
+		result = json.RawMessage("null")
+	}
+	return result, nil
+}
+
+// read returns the next message, detecting Content-Length framing
+func (c *conn) read() ([]byte, error) {
+	for {
+		line, err := c.reader.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(bytes.TrimSpace(line)) == 0) {
+			return nil, err
+		}
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) == 0 {
+			continue
+		}
+		name, value, ok := strings.Cut(string(trimmed), ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			return trimmed, nil
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("invalid Content-Length header %q", trimmed)
+		}
+		// Skip any further headers up to the blank line
+		for {
+			header, err := c.reader.ReadString('\n')
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(header) == "" {
+				break
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, body); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.headers = true
+		c.mu.Unlock()
+		return body, nil
+	}
+}
+
+// reply writes one response in the connection's framing
+func (c *conn) reply(resp response) {
+	resp.JSONRPC = "2.0"
+	data, err := encode(resp)
+	if err != nil {
+		data, _ = encode(response{JSONRPC: "2.0", ID: resp.ID, Error: &Error{Code: CodeInternalError, Message: "failed to encode result: " + err.Error()}})
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.headers {
+		fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+		return
+	}
+	c.w.Write(append(data, '\n'))
+}
+
+// encode marshals v without escaping <, > and &, which are common in
+// version specifiers
+func encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// nullID returns id, or a JSON null when the request had none
+func nullID(id json.RawMessage) json.RawMessage {
+	if id == nil {
+		return json.RawMessage("null")
+	}
+	return id
+}
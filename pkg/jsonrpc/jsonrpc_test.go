@@ -0,0 +1,106 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func newTestServer() *Server {
+	s := NewServer()
+	s.Register("echo", func(params json.RawMessage) (interface{}, error) {
+		var p struct{ Text string }
+		if err := json.Unmarshal(params, &p); err != nil || p.Text == "" {
+			return nil, InvalidParams("text is required")
+		}
+		return map[string]string{"text": p.Text}, nil
+	})
+	s.Register("fail", func(json.RawMessage) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	s.Register("panic", func(json.RawMessage) (interface{}, error) {
+		panic("handler bug")
+	})
+	return s
+}
+
+// decodeLines parses newline-delimited replies, sorted by id with null first
+func decodeLines(t *testing.T, out string) []map[string]interface{} {
+	t.Helper()
+	var replies []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var reply map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &reply); err != nil {
+			t.Fatalf("invalid reply %q: %v", line, err)
+		}
+		replies = append(replies, reply)
+	}
+	key := func(reply map[string]interface{}) string {
+		if reply["id"] == nil {
+			return ""
+		}
+		return fmt.Sprint(reply["id"])
+	}
+	sort.Slice(replies, func(i, j int) bool {
+		return key(replies[i]) < key(replies[j])
+	})
+	return replies
+}
+
+func TestServeLines(t *testing.T) {
+	in := strings.Join([]string{
+		`{"jsonrpc": "2.0", "id": 1, "method": "echo", "params": {"text": "hi"}}`,
+		`{"jsonrpc": "2.0", "id": 2, "method": "echo", "params": {}}`,
+		`{"jsonrpc": "2.0", "id": 3, "method": "nope"}`,
+		`{"jsonrpc": "2.0", "id": 4, "method": "fail"}`,
+		`{"jsonrpc": "2.0", "id": 5, "method": "panic"}`,
+		`{"jsonrpc": "2.0", "method": "echo", "params": {"text": "notification"}}`,
+		`{not json`,
+		`{"jsonrpc": "2.0", "id": 6, "method": "shutdown"}`,
+		`{"jsonrpc": "2.0", "id": 7, "method": "echo", "params": {"text": "after shutdown"}}`,
+	}, "\n")
+	var out bytes.Buffer
+	if err := newTestServer().Serve(strings.NewReader(in), &out); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+	replies := decodeLines(t, out.String())
+	if len(replies) != 7 {
+		t.Fatalf("expected 7 replies, got %d:\n%s", len(replies), out.String())
+	}
+	code := func(reply map[string]interface{}) float64 {
+		e, _ := reply["error"].(map[string]interface{})
+		c, _ := e["code"].(float64)
+		return c
+	}
+	if replies[0]["id"] != nil || code(replies[0]) != CodeParseError {
+		t.Errorf("parse error reply = %v", replies[0])
+	}
+	if result, _ := replies[1]["result"].(map[string]interface{}); result["text"] != "hi" {
+		t.Errorf("echo reply = %v", replies[1])
+	}
+	for i, want := range []float64{CodeInvalidParams, CodeMethodNotFound, CodeInternalError, CodeInternalError} {
+		if got := code(replies[i+2]); got != want {
+			t.Errorf("reply %v has code %v, want %v", replies[i+2]["id"], got, want)
+		}
+	}
+	if replies[6]["result"] != true {
+		t.Errorf("shutdown reply = %v", replies[6])
+	}
+}
+
+func TestServeContentLength(t *testing.T) {
+	body := `{"jsonrpc": "2.0", "id": "a", "method": "echo", "params": {"text": "framed"}}`
+	in := fmt.Sprintf("Content-Length: %d\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n%s", len(body), body)
+	var out bytes.Buffer
+	if err := newTestServer().Serve(strings.NewReader(in), &out); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+	header, reply, ok := strings.Cut(out.String(), "\r\n\r\n")
+	if !ok || header != fmt.Sprintf("Content-Length: %d", len(reply)) || !strings.Contains(reply, `"text":"framed"`) {
+		t.Errorf("unexpected framed reply %q", out.String())
+	}
+}
@@ -0,0 +1,271 @@
+package markers
+
+import (
+	"fmt"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// Environment holds the PEP 508 marker variables of an interpreter and platform
+type Environment map[string]string
+
+// versionVariables are compared as PEP 440 versions rather than strings
+var versionVariables = map[string]bool{
+	"python_version":         true,
+	"python_full_version":    true,
+	"implementation_version": true,
+}
+
+// Evaluate reports whether a PEP 508 environment marker holds in env.
+// An empty marker always holds.
+func Evaluate(marker string, env Environment) (bool, error) {
+	if strings.TrimSpace(marker) == "" {
+		return true, nil
+	}
+	tokens, err := tokenize(marker)
+	if err != nil {
+		return false, err
+	}
+	p := &parser{tokens: tokens, env: env}
+	result, err := p.parseOr()
+	if err != nil {
+		return false, fmt.Errorf("invalid marker '%s': %w", marker, err)
+	}
+	if p.pos != len(p.tokens) {
+		return false, fmt.Errorf("invalid marker '%s': unexpected '%s'", marker, p.tokens[p.pos].text)
+	}
+	return result, nil
+}
+
+// SplitRequirement separates a requirement string into its specifier and marker parts
+func SplitRequirement(requirement string) (string, string) {
+	if idx := strings.Index(requirement, ";"); idx >= 0 {
+		return strings.TrimSpace(requirement[:idx]), strings.TrimSpace(requirement[idx+1:])
+	}
+	return strings.TrimSpace(requirement), ""
+}
+
+// RequirementName returns the distribution name at the start of a requirement string
+func RequirementName(requirement string) string {
+	end := strings.IndexAny(requirement, " [(<>=!~;@")
+	if end < 0 {
+		return strings.TrimSpace(requirement)
+	}
+	return strings.TrimSpace(requirement[:end])
+}
+
+type tokenKind int
+
+const (
+	tokenString tokenKind = iota
+	tokenVariable
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits a marker expression into tokens
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenLParen, "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenRParen, ")"})
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in marker '%s'", s)
+			}
+			tokens = append(tokens, token{tokenString, s[i+1 : i+1+end]})
+			i += end + 2
+		case strings.ContainsRune("<>=!~", rune(c)):
+			j := i
+			for j < len(s) && strings.ContainsRune("<>=!~", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, token{tokenOp, s[i:j]})
+			i = j
+		default:
+			j := i
+			for j < len(s) && (isIdentChar(s[j])) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected character '%c' in marker '%s'", c, s)
+			}
+			word := s[i:j]
+			i = j
+			switch word {
+			case "and":
+				tokens = append(tokens, token{tokenAnd, word})
+			case "or":
+				tokens = append(tokens, token{tokenOr, word})
+			case "in":
+				tokens = append(tokens, token{tokenOp, word})
+			case "not":
+				tokens = append(tokens, token{tokenOp, "not in"})
+				rest := strings.TrimLeft(s[i:], " \t")
+				if !strings.HasPrefix(rest, "in") {
+					return nil, fmt.Errorf("expected 'in' after 'not' in marker '%s'", s)
+				}
+				i = len(s) - len(rest) + 2
+			default:
+				tokens = append(tokens, token{tokenVariable, word})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// isIdentChar reports whether c may appear in a marker variable name
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	env    Environment
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return false, err
+	}
+	for t := p.peek(); t != nil && t.kind == tokenOr; t = p.peek() {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return false, err
+		}
+		left = left || right
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (bool, error) {
+	left, err := p.parseAtom()
+	if err != nil {
+		return false, err
+	}
+	for t := p.peek(); t != nil && t.kind == tokenAnd; t = p.peek() {
+		p.pos++
+		right, err := p.parseAtom()
+		if err != nil {
+			return false, err
+		}
+		left = left && right
+	}
+	return left, nil
+}
+
+func (p *parser) parseAtom() (bool, error) {
+	t := p.peek()
+	if t == nil {
+		return false, fmt.Errorf("unexpected end of marker")
+	}
+	if t.kind == tokenLParen {
+		p.pos++
+		result, err := p.parseOr()
+		if err != nil {
+			return false, err
+		}
+		if t := p.peek(); t == nil || t.kind != tokenRParen {
+			return false, fmt.Errorf("missing ')'")
+		}
+		p.pos++
+		return result, nil
+	}
+	if p.pos+3 > len(p.tokens) {
+		return false, fmt.Errorf("incomplete comparison")
+	}
+	lhs, op, rhs := p.tokens[p.pos], p.tokens[p.pos+1], p.tokens[p.pos+2]
+	if op.kind != tokenOp || !isValue(lhs) || !isValue(rhs) {
+		return false, fmt.Errorf("expected comparison near '%s'", lhs.text)
+	}
+	p.pos += 3
+	variable := ""
+	if lhs.kind == tokenVariable {
+		variable = lhs.text
+	} else if rhs.kind == tokenVariable {
+		variable = rhs.text
+	}
+	return compare(p.resolve(lhs), op.text, p.resolve(rhs), variable)
+}
+
+// isValue reports whether t can be an operand of a comparison
+func isValue(t token) bool {
+	return t.kind == tokenString || t.kind == tokenVariable
+}
+
+// resolve returns the value of a string literal or environment variable
+func (p *parser) resolve(t token) string {
+	if t.kind == tokenVariable {
+		return p.env[t.text]
+	}
+	return t.text
+}
+
+// compare applies a marker operator to two resolved values
+func compare(lhs, op, rhs, variable string) (bool, error) {
+	switch op {
+	case "in":
+		return strings.Contains(rhs, lhs), nil
+	case "not in":
+		return !strings.Contains(rhs, lhs), nil
+	}
+	if variable == "extra" {
+		lhs, rhs = pkgname.Normalize(lhs), pkgname.Normalize(rhs)
+	}
+	if versionVariables[variable] {
+		if a, err := version.Parse(lhs); err == nil {
+			if b, err := version.Parse(rhs); err == nil {
+				return compareVersions(a, op, b)
+			}
+		}
+	}
+	switch op {
+	case "==", "===":
+		return lhs == rhs, nil
+	case "!=":
+		return lhs != rhs, nil
+	case "<":
+		return lhs < rhs, nil
+	case "<=":
+		return lhs <= rhs, nil
+	case ">":
+		return lhs > rhs, nil
+	case ">=":
+		return lhs >= rhs, nil
+	}
+	return false, fmt.Errorf("unsupported operator '%s'", op)
+}
+
+// compareVersions applies a PEP 440 comparison operator
+func compareVersions(a *version.Version, op string, b *version.Version) (bool, error) {
+	return a.Matches(op, b)
+}
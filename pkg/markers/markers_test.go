@@ -0,0 +1,100 @@
+package markers
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	env := Target{OS: "linux", Arch: "x86_64", Python: "3.11"}.Environment()
+	cases := []struct {
+		marker string
+		want   bool
+	}{
+		{"", true},
+		{`python_version >= "3.8"`, true},
+		{`python_version < "3.10"`, false},
+		{`python_full_version >= "3.11.0"`, true},
+		{`sys_platform == "win32"`, false},
+		{`sys_platform == "linux" and platform_machine == "x86_64"`, true},
+		{`sys_platform == "darwin" or (os_name == "posix" and python_version > "3.9")`, true},
+		{`"linux" in sys_platform`, true},
+		{`platform_system not in "Windows Darwin"`, true},
+		{`extra == "socks"`, false},
+		{`python_version ~= "3.7"`, true},
+	}
+	for _, c := range cases {
+		got, err := Evaluate(c.marker, env)
+		if err != nil {
+			t.Errorf("Evaluate(%q) failed: %v", c.marker, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Evaluate(%q) = %v, want %v", c.marker, got, c.want)
+		}
+	}
+}
+
+func TestEvaluateExtraAndErrors(t *testing.T) {
+	env := Environment{"extra": "Socks_Proxy"}
+	if ok, err := Evaluate(`extra == "socks-proxy"`, env); err != nil || !ok {
+		t.Errorf("Expected normalized extra to match, got %v, %v", ok, err)
+	}
+	for _, bad := range []string{`python_version >=`, `(python_version > "3"`, `os_name = "nt"x`, `"unterminated`} {
+		if _, err := Evaluate(bad, env); err == nil {
+			t.Errorf("Expected error for marker %q", bad)
+		}
+	}
+}
+
+func TestSplitRequirement(t *testing.T) {
+	spec, marker := SplitRequirement(`pywin32>=300 ; sys_platform == "win32"`)
+	if spec != "pywin32>=300" || marker != `sys_platform == "win32"` {
+		t.Errorf("Unexpected split: %q, %q", spec, marker)
+	}
+	if name := RequirementName("requests[socks] (>=2.0)"); name != "requests" {
+		t.Errorf("Expected requests, got %q", name)
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	target, err := ParseTarget("darwin-aarch64-3.12")
+	if err != nil {
+		t.Fatalf("ParseTarget failed: %v", err)
+	}
+	if target.String() != "macos-arm64-3.12" {
+		t.Errorf("Unexpected target %s", target)
+	}
+	env := target.Environment()
+	if env["sys_platform"] != "darwin" || env["platform_machine"] != "arm64" || env["python_full_version"] != "3.12.0" {
+		t.Errorf("Unexpected environment: %v", env)
+	}
+	if _, err := ParseTarget("solaris-sparc-3.11"); err == nil {
+		t.Error("Expected error for unsupported os")
+	}
+	if _, err := ParseTarget("linux-3.11"); err == nil {
+		t.Error("Expected error for malformed target")
+	}
+}
+
+func TestParsePlatformTag(t *testing.T) {
+	for platform, want := range map[string]string{
+		"manylinux2014_x86_64":   "linux-x86_64-3.10",
+		"manylinux_2_28_aarch64": "linux-arm64-3.10",
+		"linux_x86_64":           "linux-x86_64-3.10",
+		"macosx_11_0_arm64":      "macos-arm64-3.10",
+		"macosx-10.9-intel":      "macos-x86_64-3.10",
+		"win_amd64":              "windows-x86_64-3.10",
+		"win32":                  "windows-x86-3.10",
+	} {
+		target, err := ParsePlatformTag(platform, "3.10")
+		if err != nil || target.String() != want {
+			t.Errorf("ParsePlatformTag(%s) = %s, %v; want %s", platform, target, err, want)
+		}
+	}
+	for _, platform := range []string{"musllinux_1_2_x86_64", "macosx_11_0_universal2", "any", "solaris"} {
+		if _, err := ParsePlatformTag(platform, "3.10"); err == nil {
+			t.Errorf("Expected an error for %s", platform)
+		}
+	}
+	if _, err := ParsePlatformTag("win_amd64", ""); err == nil {
+		t.Error("Expected an error without a Python version")
+	}
+}
@@ -0,0 +1,155 @@
+package markers
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Target is an (os, arch, python) combination a lockfile can be resolved for
+type Target struct {
+	OS     string
+	Arch   string
+	Python string
+}
+
+// ParseTarget parses a target of the form os-arch-python, e.g. linux-x86_64-3.11
+func ParseTarget(s string) (Target, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return Target{}, fmt.Errorf("invalid target '%s' (expected os-arch-python, e.g. linux-x86_64-3.11)", s)
+	}
+	t := Target{
+		OS:     normalizeOS(parts[0]),
+		Arch:   normalizeArch(parts[1]),
+		Python: strings.TrimPrefix(parts[2], "py"),
+	}
+	switch t.OS {
+	case "linux", "macos", "windows":
+	default:
+		return Target{}, fmt.Errorf("unsupported target os '%s' (expected linux, macos or windows)", parts[0])
+	}
+	return t, nil
+}
+
+var (
+	// linuxPlatformTag matches manylinux1_x86_64, manylinux2014_aarch64,
+	// manylinux_2_28_x86_64 and linux_x86_64
+	linuxPlatformTag = regexp.MustCompile(`^(?:manylinux\d+|manylinux_\d+_\d+|linux)_(\w+)$`)
+	// macPlatformTag matches macosx_11_0_arm64
+	macPlatformTag = regexp.MustCompile(`^macosx_\d+_\d+_(\w+)$`)
+)
+
+// ParsePlatformTag builds the target for a wheel platform tag such as
+// manylinux2014_x86_64, macosx_11_0_arm64 or win_amd64, as given to pip's
+// --platform, and a Python version
+func ParsePlatformTag(platform, python string) (Target, error) {
+	tag := strings.ToLower(strings.NewReplacer("-", "_", ".", "_").Replace(platform))
+	t := Target{Python: strings.TrimPrefix(python, "py")}
+	if m := linuxPlatformTag.FindStringSubmatch(tag); m != nil {
+		t.OS, t.Arch = "linux", normalizeArch(m[1])
+	} else if m := macPlatformTag.FindStringSubmatch(tag); m != nil {
+		t.OS, t.Arch = "macos", normalizeArch(m[1])
+		if t.Arch == "intel" {
+			t.Arch = "x86_64"
+		}
+	} else {
+		switch tag {
+		case "win_amd64":
+			t.OS, t.Arch = "windows", "x86_64"
+		case "win_arm64":
+			t.OS, t.Arch = "windows", "arm64"
+		case "win32":
+			t.OS, t.Arch = "windows", "x86"
+		}
+	}
+	switch {
+	case t.OS == "":
+		return Target{}, fmt.Errorf("unsupported platform '%s' (expected a manylinux, linux, macosx or win tag, e.g. manylinux2014_x86_64)", platform)
+	case strings.HasPrefix(t.Arch, "universal"):
+		return Target{}, fmt.Errorf("platform '%s' names no single architecture; use macosx_..._arm64 or macosx_..._x86_64", platform)
+	case t.Python == "":
+		return Target{}, fmt.Errorf("a Python version is needed to target '%s'", platform)
+	}
+	return t, nil
+}
+
+// HostTarget returns the target for this machine's os and arch with the
+// given Python version
+func HostTarget(python string) (Target, error) {
+	return ParseTarget(fmt.Sprintf("%s-%s-%s", runtime.GOOS, runtime.GOARCH, python))
+}
+
+// String returns the target in os-arch-python form
+func (t Target) String() string {
+	return fmt.Sprintf("%s-%s-%s", t.OS, t.Arch, t.Python)
+}
+
+// PythonMinor returns the major.minor part of the target's Python version
+func (t Target) PythonMinor() string {
+	parts := strings.SplitN(t.Python, ".", 3)
+	if len(parts) >= 2 {
+		return parts[0] + "." + parts[1]
+	}
+	return t.Python
+}
+
+// Environment returns the marker variables a CPython interpreter on the target reports
+func (t Target) Environment() Environment {
+	full := t.Python
+	if strings.Count(full, ".") < 2 {
+		full = t.PythonMinor() + ".0"
+	}
+	env := Environment{
+		"python_version":                 t.PythonMinor(),
+		"python_full_version":            full,
+		"implementation_name":            "cpython",
+		"implementation_version":         full,
+		"platform_python_implementation": "CPython",
+		"platform_machine":               t.Arch,
+		"extra":                          "",
+	}
+	switch t.OS {
+	case "linux":
+		env["os_name"], env["sys_platform"], env["platform_system"] = "posix", "linux", "Linux"
+		if t.Arch == "arm64" {
+			env["platform_machine"] = "aarch64"
+		}
+	case "macos":
+		env["os_name"], env["sys_platform"], env["platform_system"] = "posix", "darwin", "Darwin"
+	case "windows":
+		env["os_name"], env["sys_platform"], env["platform_system"] = "nt", "win32", "Windows"
+		switch t.Arch {
+		case "x86_64":
+			env["platform_machine"] = "AMD64"
+		case "arm64":
+			env["platform_machine"] = "ARM64"
+		}
+	}
+	return env
+}
+
+// normalizeOS maps common OS spellings onto linux, macos and windows
+func normalizeOS(s string) string {
+	switch strings.ToLower(s) {
+	case "darwin", "macosx", "osx", "mac":
+		return "macos"
+	case "win", "win32", "nt":
+		return "windows"
+	}
+	return strings.ToLower(s)
+}
+
+// normalizeArch maps common architecture spellings onto x86_64, arm64 and x86
+func normalizeArch(s string) string {
+	switch strings.ToLower(s) {
+	case "amd64", "x64":
+		return "x86_64"
+	case "aarch64":
+		return "arm64"
+	case "i386", "i686", "win32":
+		return "x86"
+	}
+	return strings.ToLower(s)
+}
@@ -0,0 +1,76 @@
+package netutil
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// IndexTokenEnv is the environment variable holding an API token for the
+// configured index when index_token_env does not name another
+const IndexTokenEnv = "ZEPHYR_INDEX_TOKEN"
+
+// TokenUsername is the username indexes expect alongside an API token
+const TokenUsername = "__token__"
+
+// CredentialFunc returns the username and password for the index rawURL
+// belongs to, or ok false when none are stored
+type CredentialFunc func(rawURL string) (username, password string, ok bool)
+
+// credentialLookup supplies credentials for requests; nil sends none
+var credentialLookup CredentialFunc
+
+// SetCredentials makes every client send the credentials lookup returns as
+// basic auth, for indexes whose tokens are kept out of the config
+func SetCredentials(lookup CredentialFunc) {
+	credentialLookup = lookup
+}
+
+// authTransport adds credentials to requests that carry none: a token from
+// the environment for the configured index, then the stored credentials
+type authTransport struct {
+	base http.RoundTripper
+	// indexPrefix and indexToken are the configured index's URL, with a
+	// trailing slash, and the token injected for it through the environment
+	indexPrefix string
+	indexToken  string
+}
+
+// newAuthTransport resolves the index token once, when a client is built,
+// so requests do not read the config file again
+func newAuthTransport(base http.RoundTripper) authTransport {
+	t := authTransport{base: base}
+	cfg, _ := LoadConfig()
+	name := IndexTokenEnv
+	if cfg != nil && cfg.IndexTokenEnv != "" {
+		name = cfg.IndexTokenEnv
+	}
+	if token := os.Getenv(name); token != "" {
+		t.indexPrefix, t.indexToken = GetPyPIBaseURL()+"/", token
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper
+func (t authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.User != nil || req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+	username, password, ok := TokenUsername, "", true
+	if t.indexToken != "" && strings.HasPrefix(req.URL.String(), t.indexPrefix) {
+		password = t.indexToken
+	}
+	if password == "" {
+		if credentialLookup == nil {
+			return t.base.RoundTrip(req)
+		}
+		username, password, ok = credentialLookup(req.URL.String())
+	}
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the caller's request
+	clone := req.Clone(req.Context())
+	clone.SetBasicAuth(username, password)
+	return t.base.RoundTrip(clone)
+}
@@ -0,0 +1,73 @@
+package netutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthTransport(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		got = append(got, username+":"+password)
+	}))
+	defer ts.Close()
+
+	SetCredentials(func(rawURL string) (string, string, bool) {
+		if strings.HasPrefix(rawURL, ts.URL+"/private/") {
+			return "__token__", "secret", true
+		}
+		return "", "", false
+	})
+	defer SetCredentials(nil)
+
+	client := &http.Client{Transport: newAuthTransport(http.DefaultTransport)}
+	for _, path := range []string{"/private/simple/", "/public/simple/"} {
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/private/upload", nil)
+	req.SetBasicAuth("explicit", "password")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+
+	want := []string{"__token__:secret", ":", "explicit:password"}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("credentials sent = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestAuthTransport_IndexToken(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		got = append(got, username+":"+password)
+	}))
+	defer ts.Close()
+	t.Setenv("ZEPHYR_INDEX_URL", ts.URL+"/index")
+	t.Setenv(IndexTokenEnv, "pypi-injected")
+
+	client := &http.Client{Transport: newAuthTransport(http.DefaultTransport)}
+	// The token is resolved when the client is built, not on every request
+	t.Setenv(IndexTokenEnv, "pypi-changed")
+	for _, path := range []string{"/index/simple/foo/", "/elsewhere/"} {
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+	if len(got) != 2 || got[0] != "__token__:pypi-injected" || got[1] != ":" {
+		t.Errorf("credentials sent = %v, want the token for the index only", got)
+	}
+}
@@ -0,0 +1,259 @@
+package netutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// PartialSuffix is appended to the destination of a download while it is in
+// progress; the file is renamed into place only once it is complete and verified
+const PartialSuffix = ".part"
+
+// maxResumeAttempts bounds how often one DownloadFile call picks an
+// interrupted transfer back up
+const maxResumeAttempts = 3
+
+// DownloadOptions tune DownloadFile
+type DownloadOptions struct {
+	// SHA256 is the hex digest the file must have; "" skips the check
+	SHA256 string
+	// Progress, when set, is called as data arrives with the bytes written
+	// so far, including any resumed part, and the total, or -1 if unknown
+	Progress func(written, total int64)
+	// Resume keeps the partial file of an interrupted transfer and continues
+	// it with a Range request, both within the call and across calls
+	Resume bool
+}
+
+// DigestMismatchError reports a download whose content does not hash to the expected digest
+type DigestMismatchError struct {
+	URL      string
+	Expected string
+	Actual   string
+}
+
+// Error implements the error interface
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf("%s has sha256 %s but %s was expected", e.URL, e.Actual, e.Expected)
+}
+
+// TruncatedDownloadError reports a download that ended before the length the
+// server announced, such as a connection dropped or cut short by a proxy.
+// Unlike a DigestMismatchError, the content received so far may be intact.
+type TruncatedDownloadError struct {
+	URL      string
+	Expected int64
+	Received int64
+}
+
+// Error implements the error interface
+func (e *TruncatedDownloadError) Error() string {
+	return fmt.Sprintf("download of %s was truncated: received %d of %d bytes", e.URL, e.Received, e.Expected)
+}
+
+// Unwrap lets callers match truncation with errors.Is(err, io.ErrUnexpectedEOF)
+func (e *TruncatedDownloadError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+// DownloadFile downloads a URL to a local path. The body is streamed to
+// filepath plus PartialSuffix, verified against opts.SHA256 and renamed over
+// filepath, so readers never see a partial or corrupt file. A body shorter
+// than its Content-Length is a TruncatedDownloadError. Downloads wait for a
+// free slot and share the configured rate and size limits.
+func DownloadFile(client *http.Client, url, filepath string, opts DownloadOptions) error {
+	partial := filepath + PartialSuffix
+	if !opts.Resume {
+		os.Remove(partial)
+	}
+	var err error
+	for attempt := 0; ; attempt++ {
+		var interrupted bool
+		interrupted, err = fetchPartial(client, url, partial, opts)
+		if err == nil || !opts.Resume || !interrupted || attempt == maxResumeAttempts {
+			break
+		}
+	}
+	if err != nil {
+		if !opts.Resume {
+			os.Remove(partial)
+		}
+		return err
+	}
+	if opts.SHA256 != "" {
+		actual, err := FileSHA256(partial)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(actual, opts.SHA256) {
+			// A resumed file that does not verify cannot be repaired by resuming again
+			os.Remove(partial)
+			return &DigestMismatchError{URL: url, Expected: strings.ToLower(opts.SHA256), Actual: actual}
+		}
+	}
+	if err := os.Rename(partial, filepath); err != nil {
+		return fmt.Errorf("failed to move download into place: %w", err)
+	}
+	return nil
+}
+
+// fetchPartial appends the rest of url to partial, or rewrites it when the
+// server cannot resume. It reports whether a failure interrupted a transfer
+// that another attempt could pick up.
+func fetchPartial(client *http.Client, url, partial string, opts DownloadOptions) (bool, error) {
+	var offset int64
+	if info, err := os.Stat(partial); err == nil && opts.Resume {
+		offset = info.Size()
+	}
+	var resp *http.Response
+	body, err := StartDownload(func() (io.ReadCloser, error) {
+		req, err := CreatePyPIRequest("GET", url)
+		if err != nil {
+			return nil, err
+		}
+		// The file's own bytes are hashed and resumed, not an encoding of them
+		req.Header.Set("Accept-Encoding", "identity")
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		resp, err = client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Body, nil
+	})
+	if err != nil {
+		return resp == nil, err
+	}
+	defer body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			os.Remove(partial)
+			return true, fmt.Errorf("%s resumed at the wrong offset (Content-Range %q)", url, resp.Header.Get("Content-Range"))
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The partial file is no prefix of the current file; start over
+		os.Remove(partial)
+		return true, fmt.Errorf("%s cannot resume at byte %d", url, offset)
+	case resp.StatusCode == http.StatusOK:
+		// The server ignored the Range header and sent the whole file
+		offset = 0
+	default:
+		return false, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+
+	// Servers may encode the file regardless; its ranges then index the
+	// encoding, so such a download cannot be resumed
+	encoded := resp.Header.Get("Content-Encoding") != "" && !strings.EqualFold(resp.Header.Get("Content-Encoding"), "identity")
+	if encoded {
+		if offset > 0 {
+			os.Remove(partial)
+			return true, fmt.Errorf("%s cannot resume an encoded response", url)
+		}
+		// Decode the paced, size-limited body rather than the raw one, and
+		// limit the decoded bytes too so a small gzip bomb cannot fill the disk
+		resp.Body = body
+		if err := DecodeBody(resp); err != nil {
+			return false, fmt.Errorf("failed to download %s: %w", url, err)
+		}
+		body = struct {
+			io.Reader
+			io.Closer
+		}{LimitSize(resp.Body, "decoded download", ArtifactLimits().MaxArtifactSize), resp.Body}
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	out, err := os.OpenFile(partial, flags, 0644)
+	if err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", partial, err)
+	}
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	w := &progressWriter{w: out, written: offset, total: total, progress: opts.Progress}
+	if _, err := io.Copy(w, body); err != nil {
+		out.Close()
+		if w.failed {
+			return false, fmt.Errorf("failed to write %s: %w", partial, err)
+		}
+		var tooLarge *SizeLimitError
+		if errors.As(err, &tooLarge) {
+			os.Remove(partial)
+			return false, err
+		}
+		if encoded {
+			os.Remove(partial)
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) && total >= 0 {
+			return true, &TruncatedDownloadError{URL: url, Expected: total, Received: w.written}
+		}
+		return true, fmt.Errorf("download of %s interrupted after %d bytes: %w", url, w.written, err)
+	}
+	if total >= 0 && w.written != total {
+		out.Close()
+		if w.written > total {
+			os.Remove(partial)
+			return false, fmt.Errorf("%s sent %d bytes but announced %d", url, w.written, total)
+		}
+		return true, &TruncatedDownloadError{URL: url, Expected: total, Received: w.written}
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return false, fmt.Errorf("failed to sync %s: %w", partial, err)
+	}
+	if err := out.Close(); err != nil {
+		return false, fmt.Errorf("failed to close %s: %w", partial, err)
+	}
+	return false, nil
+}
+
+// progressWriter counts the bytes written through it and reports them
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	total    int64
+	progress func(written, total int64)
+	// failed is set when the destination, not the source, returned the error
+	failed bool
+}
+
+func (p *progressWriter) Write(buf []byte) (int, error) {
+	n, err := p.w.Write(buf)
+	p.written += int64(n)
+	if err != nil {
+		p.failed = true
+	}
+	if p.progress != nil && n > 0 {
+		p.progress(p.written, p.total)
+	}
+	return n, err
+}
+
+// FileSHA256 returns the hex SHA256 of a file
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,151 @@
+package netutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDownloadFile_ResumesPartialDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("zephyr"), 1000)
+	sum := sha256.Sum256(content)
+	var ranges []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "file.whl", time.Time{}, bytes.NewReader(content))
+	}))
+	defer ts.Close()
+
+	dest := filepath.Join(t.TempDir(), "file.whl")
+	// An interrupted earlier attempt left the first 100 bytes behind
+	if err := os.WriteFile(dest+PartialSuffix, content[:100], 0644); err != nil {
+		t.Fatal(err)
+	}
+	var lastWritten, lastTotal int64
+	err := DownloadFile(ts.Client(), ts.URL+"/file.whl", dest, DownloadOptions{
+		SHA256:   hex.EncodeToString(sum[:]),
+		Resume:   true,
+		Progress: func(written, total int64) { lastWritten, lastTotal = written, total },
+	})
+	if err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=100-" {
+		t.Errorf("Expected one request resuming at byte 100, got %q", ranges)
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, content) {
+		t.Errorf("Downloaded content mismatch: %d bytes", len(got))
+	}
+	if lastWritten != int64(len(content)) || lastTotal != int64(len(content)) {
+		t.Errorf("Expected progress to end at %d/%d, got %d/%d", len(content), len(content), lastWritten, lastTotal)
+	}
+	if _, err := os.Stat(dest + PartialSuffix); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial file to be gone, got %v", err)
+	}
+}
+
+func TestDownloadFile_DigestMismatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered"))
+	}))
+	defer ts.Close()
+
+	dest := filepath.Join(t.TempDir(), "file.whl")
+	err := DownloadFile(ts.Client(), ts.URL+"/file.whl", dest, DownloadOptions{SHA256: "00ff"})
+	var mismatch *DigestMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Expected != "00ff" {
+		t.Fatalf("Expected a digest mismatch, got %v", err)
+	}
+	for _, path := range []string{dest, dest + PartialSuffix} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to exist, got %v", path, err)
+		}
+	}
+}
+
+func TestDownloadFile_Truncated(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Announce more than is sent; the server then drops the connection
+		w.Header().Set("Content-Length", "1000")
+		w.Write(bytes.Repeat([]byte("x"), 100))
+	}))
+	defer ts.Close()
+
+	dest := filepath.Join(t.TempDir(), "file.whl")
+	err := DownloadFile(ts.Client(), ts.URL+"/file.whl", dest, DownloadOptions{SHA256: "00"})
+	var truncated *TruncatedDownloadError
+	if !errors.As(err, &truncated) {
+		t.Fatalf("Expected TruncatedDownloadError, got %v", err)
+	}
+	if truncated.Expected != 1000 || truncated.Received != 100 {
+		t.Errorf("Expected 100 of 1000 bytes, got %+v", truncated)
+	}
+	var mismatch *DigestMismatchError
+	if errors.As(err, &mismatch) {
+		t.Error("A truncated download must not be reported as a digest mismatch")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("Expected no file at the destination, got %v", err)
+	}
+}
+
+func TestDownloadFile_EncodedResponse(t *testing.T) {
+	content := bytes.Repeat([]byte("zephyr"), 1000)
+	sum := sha256.Sum256(content)
+	var acceptEncoding string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		// A misconfigured server that compresses regardless
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write(content)
+		gz.Close()
+	}))
+	defer ts.Close()
+
+	dest := filepath.Join(t.TempDir(), "file.whl")
+	if err := DownloadFile(ts.Client(), ts.URL+"/file.whl", dest, DownloadOptions{SHA256: hex.EncodeToString(sum[:])}); err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	if acceptEncoding != "identity" {
+		t.Errorf("Expected downloads to ask for identity encoding, got %q", acceptEncoding)
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, content) {
+		t.Errorf("Expected the decoded file, got %d bytes", len(got))
+	}
+}
+
+func TestDownloadFile_EncodedResponseOverLimit(t *testing.T) {
+	saved := limits
+	defer func() { limits = saved }()
+	ArtifactLimits()
+	limits.MaxArtifactSize = 64 << 10
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Compresses to far less than the limit but decodes to far more
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write(make([]byte, 1<<20))
+		gz.Close()
+	}))
+	defer ts.Close()
+
+	dest := filepath.Join(t.TempDir(), "bomb.whl")
+	err := DownloadFile(ts.Client(), ts.URL+"/bomb.whl", dest, DownloadOptions{})
+	var limit *SizeLimitError
+	if !errors.As(err, &limit) {
+		t.Fatalf("expected SizeLimitError for the decoded body, got %v", err)
+	}
+	if _, err := os.Stat(dest + PartialSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected the partial download to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package netutil
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DecodeBody replaces resp.Body with a reader of the decoded document when
+// the server sent it gzip or deflate encoded, so callers read the same bytes
+// however the index compresses them. The Go transport only decodes gzip, and
+// only when it asked for it; this covers indexes and proxies that send
+// deflate, or compress responses nobody asked them to. Identity responses
+// are left alone. Once decoded, Content-Length no longer applies and is
+// reset to -1. The caller still closes the body, as before.
+func DecodeBody(resp *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	var decoded io.ReadCloser
+	switch encoding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to decode gzip response: %w", err)
+		}
+		decoded = gz
+	case "deflate":
+		// RFC 9110 deflate is zlib-wrapped, but some servers send raw deflate
+		buffered := bufio.NewReader(resp.Body)
+		header, _ := buffered.Peek(2)
+		if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			z, err := zlib.NewReader(buffered)
+			if err != nil {
+				return fmt.Errorf("failed to decode deflate response: %w", err)
+			}
+			decoded = z
+		} else {
+			decoded = flate.NewReader(buffered)
+		}
+	default:
+		return fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+	resp.Body = &decodedBody{Reader: decoded, decoder: decoded, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// decodedBody reads through a decoder and closes it with the response body
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	body    io.Closer
+}
+
+func (d *decodedBody) Close() error {
+	d.decoder.Close()
+	return d.body.Close()
+}
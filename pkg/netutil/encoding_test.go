@@ -0,0 +1,55 @@
+package netutil
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestDecodeBody(t *testing.T) {
+	document := []byte(`{"info": {"name": "zephyr"}}`)
+	encode := map[string]func(io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		// Some servers send raw deflate without the zlib wrapper
+		"raw deflate": func(w io.Writer) io.WriteCloser { fw, _ := flate.NewWriter(w, flate.DefaultCompression); return fw },
+	}
+	for name, newWriter := range encode {
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		w.Write(document)
+		w.Close()
+		encoding := name
+		if name == "raw deflate" {
+			encoding = "deflate"
+		}
+		resp := &http.Response{
+			Header:        http.Header{"Content-Encoding": {encoding}, "Content-Length": {"99"}},
+			Body:          io.NopCloser(&buf),
+			ContentLength: int64(buf.Len()),
+		}
+		if err := DecodeBody(resp); err != nil {
+			t.Fatalf("%s: DecodeBody failed: %v", name, err)
+		}
+		got, err := io.ReadAll(resp.Body)
+		if err != nil || !bytes.Equal(got, document) {
+			t.Errorf("%s: got %q, %v", name, got, err)
+		}
+		if resp.ContentLength != -1 || resp.Header.Get("Content-Encoding") != "" {
+			t.Errorf("%s: expected the encoding headers to be cleared, got %d %v", name, resp.ContentLength, resp.Header)
+		}
+	}
+
+	plain := &http.Response{Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(document)), ContentLength: int64(len(document))}
+	if err := DecodeBody(plain); err != nil || plain.ContentLength != int64(len(document)) {
+		t.Errorf("Expected identity responses untouched, got %v", err)
+	}
+	br := &http.Response{Header: http.Header{"Content-Encoding": {"br"}}, Body: io.NopCloser(bytes.NewReader(document))}
+	if err := DecodeBody(br); err == nil {
+		t.Error("Expected an error for an unsupported encoding")
+	}
+}
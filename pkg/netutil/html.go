@@ -64,35 +64,19 @@ func (p *HTMLParser) ExtractPackageLinks() ([]string, error) {
 	return links, nil
 }
 
-// ExtractDownloadLinks extracts download links from a package page
+// ExtractDownloadLinks extracts download links from a package page, with the
+// PEP 503 hash fragment and the data-requires-python and data-yanked (PEP
+// 592) attributes of each, so servers that only offer the HTML simple index
+// still support Python version filtering, skipping yanked files and hash
+// pinning
 func (p *HTMLParser) ExtractDownloadLinks() ([]DownloadLink, error) {
 	var links []DownloadLink
 	
 	var traverse func(*html.Node)
 	traverse = func(n *html.Node) {
 		if n.Type == html.ElementNode && n.Data == "a" {
-			var href, text string
-			for _, attr := range n.Attr {
-				if attr.Key == "href" {
-					href = attr.Val
-				}
-			}
-			
-			// Extract text content
-			if n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
-				text = strings.TrimSpace(n.FirstChild.Data)
-			}
-			
-			if href != "" && text != "" {
-				// Check if it's a download link (ends with .whl, .tar.gz, etc.)
-				if strings.HasSuffix(href, ".whl") || 
-				   strings.HasSuffix(href, ".tar.gz") || 
-				   strings.HasSuffix(href, ".zip") {
-					links = append(links, DownloadLink{
-						URL:  href,
-						Text: text,
-					})
-				}
+			if link, ok := downloadLink(n); ok {
+				links = append(links, link)
 			}
 		}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
@@ -104,10 +88,69 @@ func (p *HTMLParser) ExtractDownloadLinks() ([]DownloadLink, error) {
 	return links, nil
 }
 
+// downloadLink reads an anchor that links to a wheel or sdist
+func downloadLink(n *html.Node) (DownloadLink, bool) {
+	var link DownloadLink
+	href := ""
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "href":
+			href = attr.Val
+		case "data-requires-python":
+			// The parser has already unescaped &gt; and &lt;
+			link.RequiresPython = attr.Val
+		case "data-yanked":
+			// The attribute's presence marks the file yanked; its value is the reason
+			link.Yanked, link.YankedReason = true, attr.Val
+		}
+	}
+	
+	// Extract text content
+	if n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+		link.Text = strings.TrimSpace(n.FirstChild.Data)
+	}
+	if href == "" || link.Text == "" {
+		return link, false
+	}
+	
+	// The hash travels in the fragment, as in file.whl#sha256=<hex>
+	href, fragment, _ := strings.Cut(href, "#")
+	if algorithm, digest, ok := strings.Cut(fragment, "="); ok && algorithm != "" && digest != "" {
+		link.Hashes = map[string]string{strings.ToLower(algorithm): digest}
+	}
+	link.URL = href
+	
+	path, _, _ := strings.Cut(href, "?")
+	link.Filename = path[strings.LastIndex(path, "/")+1:]
+	// Check if it's a download link (ends with .whl, .tar.gz, etc.)
+	if strings.HasSuffix(link.Filename, ".whl") || 
+	   strings.HasSuffix(link.Filename, ".tar.gz") || 
+	   strings.HasSuffix(link.Filename, ".zip") {
+		return link, true
+	}
+	return link, false
+}
+
 // DownloadLink represents a download link from PyPI
 type DownloadLink struct {
+	// URL is the href without its hash fragment
 	URL  string
 	Text string
+	// Filename is the last path segment of URL
+	Filename string
+	// Hashes maps a hash algorithm, such as sha256, to the hex digest from
+	// the URL fragment; nil when the index gave none
+	Hashes map[string]string
+	// RequiresPython is the data-requires-python specifier, or ""
+	RequiresPython string
+	// Yanked reports a data-yanked attribute, and YankedReason its value
+	Yanked       bool
+	YankedReason string
+}
+
+// SHA256 returns the link's sha256 digest, or "" when the index gave none
+func (l DownloadLink) SHA256() string {
+	return l.Hashes["sha256"]
 }
 
 // FetchAndParseHTML fetches HTML content and parses it
@@ -127,6 +170,9 @@ func FetchAndParseHTML(client *http.Client, url string) (*HTMLParser, error) {
 		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
 	}
 	
+	if err := DecodeBody(resp); err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
@@ -1,7 +1,6 @@
 package netutil
 
 import (
-	"strings"
 	"testing"
 )
 
@@ -21,9 +20,15 @@ func TestNewHTMLParser_ValidHTML(t *testing.T) {
 }
 
 func TestNewHTMLParser_InvalidHTML(t *testing.T) {
-	_, err := NewHTMLParser("<html><body><a href='foo'>foo")
-	if err == nil {
-		t.Error("Expected error for invalid HTML, got nil")
+	// HTML5 parsing recovers from malformed markup instead of failing, so an
+	// unclosed link is still found
+	parser, err := NewHTMLParser("<html><body><a href='foo'>foo")
+	if err != nil {
+		t.Fatalf("NewHTMLParser failed: %v", err)
+	}
+	links, err := parser.ExtractPackageLinks()
+	if err != nil || len(links) != 1 || links[0] != "foo" {
+		t.Errorf("Expected the unclosed link to be recovered, got %v (%v)", links, err)
 	}
 }
 
@@ -48,4 +53,35 @@ func TestParsePyPIPackagePage(t *testing.T) {
 	if info.Name != "foo" || info.Description != "desc" || len(info.DownloadLinks) != 1 {
 		t.Errorf("PyPIPackageInfo mismatch: %+v", info)
 	}
-} 
\ No newline at end of file
+} 
+func TestExtractDownloadLinks_SimpleIndexAttributes(t *testing.T) {
+	html := `<html><body>
+<a href="../../packages/foo-1.0-py3-none-any.whl#sha256=abc123" data-requires-python="&gt;=3.8">foo-1.0-py3-none-any.whl</a>
+<a href="https://files.example.com/foo-0.9.tar.gz#SHA256=def456" data-yanked="broken build">foo-0.9.tar.gz</a>
+<a href="foo-0.8.zip" data-yanked>foo-0.8.zip</a>
+<a href="/simple/">index</a>
+</body></html>`
+	parser, _ := NewHTMLParser(html)
+	links, err := parser.ExtractDownloadLinks()
+	if err != nil {
+		t.Fatalf("ExtractDownloadLinks failed: %v", err)
+	}
+	if len(links) != 3 {
+		t.Fatalf("Expected 3 download links, got %+v", links)
+	}
+	wheel := links[0]
+	if wheel.URL != "../../packages/foo-1.0-py3-none-any.whl" || wheel.Filename != "foo-1.0-py3-none-any.whl" {
+		t.Errorf("Wheel URL mismatch: %+v", wheel)
+	}
+	if wheel.SHA256() != "abc123" || wheel.RequiresPython != ">=3.8" || wheel.Yanked {
+		t.Errorf("Wheel attributes mismatch: %+v", wheel)
+	}
+	sdist := links[1]
+	if sdist.SHA256() != "def456" || !sdist.Yanked || sdist.YankedReason != "broken build" || sdist.RequiresPython != "" {
+		t.Errorf("Sdist attributes mismatch: %+v", sdist)
+	}
+	zip := links[2]
+	if !zip.Yanked || zip.YankedReason != "" || zip.Hashes != nil {
+		t.Errorf("Zip attributes mismatch: %+v", zip)
+	}
+}
@@ -6,21 +6,109 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"rimraf-adi.com/zephyr/pkg/dirs"
 )
 
 const (
 	DefaultTimeout = 30 * time.Second
-	DefaultUserAgent = "Zephyr/1.0.0 (Python Package Manager)"
 	DefaultPyPIBaseURL = "https://pypi.org"
 )
 
 // Config represents Zephyr configuration
-// Supports global (~/.zephyr/config.toml or config.yaml) and project-level (.zephyrrc or pyproject.toml)
+// Supports global (config.yaml in the zephyr config directory) and project-level (.zephyrrc or pyproject.toml)
 type Config struct {
 	IndexURL string `yaml:"index_url"`
+	// Proxy is used for every request instead of the HTTP(S)_PROXY variables
+	Proxy string `yaml:"proxy"`
+	// MaxConnections bounds idle connections kept across all hosts
+	MaxConnections int `yaml:"max_connections"`
+	// MaxConnectionsPerHost bounds concurrent connections to one host
+	MaxConnectionsPerHost int `yaml:"max_connections_per_host"`
+	// LimitRate caps the combined download speed, e.g. "10MB/s"
+	LimitRate string `yaml:"limit_rate"`
+	// MaxParallelDownloads bounds how many artifacts download at once
+	MaxParallelDownloads int `yaml:"max_parallel_downloads"`
+	// MaxRequestsPerSecond paces how many requests start each second
+	MaxRequestsPerSecond int `yaml:"max_requests_per_second"`
+	// MaxParallelRequests bounds how many requests wait for a response at once
+	MaxParallelRequests int `yaml:"max_parallel_requests"`
+	// CacheMaxAge evicts cache entries unused for longer than this, e.g. "30d"
+	CacheMaxAge string `yaml:"cache_max_age"`
+	// CacheMaxSize caps the cache size, e.g. "5GB"; sync prunes when it is exceeded
+	CacheMaxSize string `yaml:"cache_max_size"`
+	// PythonDownloadsURL lists managed Python builds in the GitHub releases
+	// API format, for mirrors of python-build-standalone
+	PythonDownloadsURL string `yaml:"python_downloads_url"`
+	// UserAgentSuffix is appended to the User-Agent, e.g. an organization tag
+	// that a corporate proxy admits
+	UserAgentSuffix string `yaml:"user_agent_suffix"`
+	// MaxArtifactSize rejects downloads larger than this, e.g. "4GB"
+	MaxArtifactSize string `yaml:"max_artifact_size"`
+	// MaxExtractedSize rejects archives that unpack to more than this, e.g. "16GB"
+	MaxExtractedSize string `yaml:"max_extracted_size"`
+	// MaxCompressionRatio rejects archive members that expand more than this
+	// many times, which is how zip bombs fill a disk
+	MaxCompressionRatio int `yaml:"max_compression_ratio"`
+	// IndexTokenEnv names the environment variable holding an API token for
+	// index_url, sent as basic auth with the __token__ username
+	IndexTokenEnv string `yaml:"index_token_env"`
+	// InternalPackages are name patterns, such as acme-*, for packages that
+	// must only come from index_url and must not exist on the public index
+	InternalPackages []string `yaml:"internal_packages,omitempty"`
+	// PublicIndexURL is the index internal packages are checked against;
+	// empty means https://pypi.org
+	PublicIndexURL string `yaml:"public_index_url"`
+	// Repositories are named upload targets for zephyr publish --repository
+	Repositories map[string]*Repository `yaml:"repositories,omitempty"`
+	// Profiles are named sets of overrides, such as a staging index for dev
+	// and a blessed mirror for prod, selected with --profile
+	Profiles map[string]*Config `yaml:"profiles,omitempty"`
+}
+
+// profile is the name of the selected profile, or "" for none
+var profile string
+
+// SetProfile selects the named profile from the loaded configuration. A
+// profile no config file defines is an error; "" clears the selection.
+func SetProfile(name string) error {
+	if name != "" {
+		profile = ""
+		cfg, _ := LoadConfig()
+		if _, ok := cfg.Profiles[name]; !ok {
+			names := make([]string, 0, len(cfg.Profiles))
+			for defined := range cfg.Profiles {
+				names = append(names, defined)
+			}
+			sort.Strings(names)
+			if len(names) == 0 {
+				return fmt.Errorf("unknown profile %q: no profiles are defined in .zephyrrc or %s", name, filepath.Join(dirs.ConfigDir(), "config.yaml"))
+			}
+			return fmt.Errorf("unknown profile %q: defined profiles are %s", name, strings.Join(names, ", "))
+		}
+	}
+	profile = name
+	return nil
+}
+
+// Profile returns the profile selected with SetProfile
+func Profile() string {
+	return profile
+}
+
+// Repository is an index zephyr publish uploads to, with the token scoped
+// to it. Tokens are read from the environment, never from the config file.
+type Repository struct {
+	// URL is the upload endpoint, e.g. https://test.pypi.org/legacy/
+	URL string `yaml:"url"`
+	// Username is sent with the token; empty means __token__
+	Username string `yaml:"username"`
+	// TokenEnv names the environment variable holding this repository's token
+	TokenEnv string `yaml:"token_env"`
 }
 
 var globalConfig *Config
@@ -32,14 +120,10 @@ func LoadConfig() (*Config, error) {
 		return mergeConfig(globalConfig, projectConfig), nil
 	}
 	// Load global config
-	home, err := os.UserHomeDir()
-	if err == nil {
-		globalPath := filepath.Join(home, ".zephyr", "config.yaml")
-		if _, err := os.Stat(globalPath); err == nil {
-			cfg, err := parseConfigFile(globalPath)
-			if err == nil {
-				globalConfig = cfg
-			}
+	if globalPath := GlobalConfigPath(); globalPath != "" {
+		cfg, err := parseConfigFile(globalPath)
+		if err == nil {
+			globalConfig = cfg
 		}
 	}
 	// Load project config
@@ -53,6 +137,22 @@ func LoadConfig() (*Config, error) {
 	return mergeConfig(globalConfig, projectConfig), nil
 }
 
+// GlobalConfigPath returns the global config.yaml in the zephyr config
+// directory, falling back to ~/.zephyr/config.yaml from older releases. It
+// returns "" when neither exists.
+func GlobalConfigPath() string {
+	candidates := []string{filepath.Join(dirs.ConfigDir(), "config.yaml")}
+	if legacy := dirs.LegacyDir(); legacy != "" && os.Getenv(dirs.HomeEnv) == "" {
+		candidates = append(candidates, filepath.Join(legacy, "config.yaml"))
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
 func parseConfigFile(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -70,10 +170,31 @@ func mergeConfig(global, project *Config) *Config {
 	if global != nil {
 		*cfg = *global
 	}
-	if project != nil {
-		if project.IndexURL != "" {
-			cfg.IndexURL = project.IndexURL
+	overlayConfig(cfg, project)
+	if project != nil && len(project.Repositories) > 0 {
+		// A project repository replaces a global one of the same name
+		repositories := make(map[string]*Repository, len(cfg.Repositories)+len(project.Repositories))
+		for name, r := range cfg.Repositories {
+			repositories[name] = r
+		}
+		for name, r := range project.Repositories {
+			repositories[name] = r
+		}
+		cfg.Repositories = repositories
+	}
+	if project != nil && len(project.Profiles) > 0 {
+		// A project profile replaces a global one of the same name
+		profiles := make(map[string]*Config, len(cfg.Profiles)+len(project.Profiles))
+		for name, p := range cfg.Profiles {
+			profiles[name] = p
+		}
+		for name, p := range project.Profiles {
+			profiles[name] = p
 		}
+		cfg.Profiles = profiles
+	}
+	if profile != "" {
+		overlayConfig(cfg, cfg.Profiles[profile])
 	}
 	// Environment variable override
 	if env := os.Getenv("ZEPHYR_INDEX_URL"); env != "" {
@@ -82,15 +203,72 @@ func mergeConfig(global, project *Config) *Config {
 	return cfg
 }
 
+// overlayConfig copies the settings project sets over cfg
+func overlayConfig(cfg, project *Config) {
+	if project != nil {
+		if project.IndexURL != "" {
+			cfg.IndexURL = project.IndexURL
+		}
+		if project.Proxy != "" {
+			cfg.Proxy = project.Proxy
+		}
+		if project.MaxConnections > 0 {
+			cfg.MaxConnections = project.MaxConnections
+		}
+		if project.MaxConnectionsPerHost > 0 {
+			cfg.MaxConnectionsPerHost = project.MaxConnectionsPerHost
+		}
+		if project.LimitRate != "" {
+			cfg.LimitRate = project.LimitRate
+		}
+		if project.MaxParallelDownloads > 0 {
+			cfg.MaxParallelDownloads = project.MaxParallelDownloads
+		}
+		if project.MaxRequestsPerSecond > 0 {
+			cfg.MaxRequestsPerSecond = project.MaxRequestsPerSecond
+		}
+		if project.MaxParallelRequests > 0 {
+			cfg.MaxParallelRequests = project.MaxParallelRequests
+		}
+		if project.CacheMaxAge != "" {
+			cfg.CacheMaxAge = project.CacheMaxAge
+		}
+		if project.CacheMaxSize != "" {
+			cfg.CacheMaxSize = project.CacheMaxSize
+		}
+		if project.PythonDownloadsURL != "" {
+			cfg.PythonDownloadsURL = project.PythonDownloadsURL
+		}
+		if project.UserAgentSuffix != "" {
+			cfg.UserAgentSuffix = project.UserAgentSuffix
+		}
+		if project.MaxArtifactSize != "" {
+			cfg.MaxArtifactSize = project.MaxArtifactSize
+		}
+		if project.MaxExtractedSize != "" {
+			cfg.MaxExtractedSize = project.MaxExtractedSize
+		}
+		if project.MaxCompressionRatio > 0 {
+			cfg.MaxCompressionRatio = project.MaxCompressionRatio
+		}
+		if project.IndexTokenEnv != "" {
+			cfg.IndexTokenEnv = project.IndexTokenEnv
+		}
+		if len(project.InternalPackages) > 0 {
+			// Projects add to the patterns an organization declares globally
+			cfg.InternalPackages = append(append([]string(nil), cfg.InternalPackages...), project.InternalPackages...)
+		}
+		if project.PublicIndexURL != "" {
+			cfg.PublicIndexURL = project.PublicIndexURL
+		}
+	}
+}
+
 // NewPyPIClient creates a new HTTP client configured for PyPI or custom index
 func NewPyPIClient() *http.Client {
 	return &http.Client{
 		Timeout: DefaultTimeout,
-		Transport: &http.Transport{
-			MaxIdleConns:        10,
-			IdleConnTimeout:     30 * time.Second,
-			DisableCompression:  false,
-		},
+		Transport: clientTransport(),
 	}
 }
 
@@ -111,17 +289,13 @@ func NewHTTPClient(timeout time.Duration) *http.Client {
 	
 	return &http.Client{
 		Timeout: timeout,
-		Transport: &http.Transport{
-			MaxIdleConns:        10,
-			IdleConnTimeout:     30 * time.Second,
-			DisableCompression:  false,
-		},
+		Transport: clientTransport(),
 	}
 }
 
 // AddPyPIHeaders adds PyPI-compatible headers to an HTTP request
 func AddPyPIHeaders(req *http.Request) {
-	req.Header.Set("User-Agent", DefaultUserAgent)
+	req.Header.Set("User-Agent", UserAgent())
 	req.Header.Set("Accept", "application/json, text/html, */*")
 	req.Header.Set("Accept-Encoding", "gzip, deflate")
 	req.Header.Set("Connection", "keep-alive")
@@ -138,12 +312,6 @@ func CreatePyPIRequest(method, url string) (*http.Request, error) {
 	return req, nil
 }
 
-// SetCustomUserAgent sets a custom user agent for requests
-func SetCustomUserAgent(userAgent string) {
-	// This would be used to override the default user agent
-	// Implementation depends on how you want to manage global state
-}
-
 // RetryableHTTPClient creates an HTTP client with retry logic
 type RetryableHTTPClient struct {
 	client  *http.Client
@@ -204,29 +372,3 @@ func IsRetryableError(err error) bool {
 	// Retry on network errors
 	return true
 }
-
-// DownloadFile downloads a file from a URL to a local path
-func DownloadFile(client *http.Client, url, filepath string) error {
-	req, err := CreatePyPIRequest("GET", url)
-	if err != nil {
-		return err
-	}
-	
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return &HTTPError{
-			StatusCode: resp.StatusCode,
-			Status:     resp.Status,
-		}
-	}
-	
-	// TODO: Implement file writing logic
-	// This would use os.Create and io.Copy to write the response body to the file
-	
-	return nil
-} 
\ No newline at end of file
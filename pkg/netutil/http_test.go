@@ -22,6 +22,41 @@ func TestMergeConfig(t *testing.T) {
 	os.Unsetenv("ZEPHYR_INDEX_URL")
 }
 
+func TestMergeConfig_Profiles(t *testing.T) {
+	global := &Config{IndexURL: "https://pypi.example.com", Profiles: map[string]*Config{
+		"dev":  {IndexURL: "https://staging.example.com"},
+		"prod": {IndexURL: "https://global-mirror.example.com"},
+	}}
+	project := &Config{Profiles: map[string]*Config{
+		"prod": {IndexURL: "https://mirror.example.com", Proxy: "http://proxy:3128"},
+	}}
+	defer func() { profile = "" }()
+
+	if cfg := mergeConfig(global, project); cfg.IndexURL != "https://pypi.example.com" {
+		t.Errorf("Without a profile IndexURL = %s", cfg.IndexURL)
+	}
+	profile = "dev"
+	if cfg := mergeConfig(global, project); cfg.IndexURL != "https://staging.example.com" {
+		t.Errorf("dev profile IndexURL = %s", cfg.IndexURL)
+	}
+	profile = "prod"
+	cfg := mergeConfig(global, project)
+	if cfg.IndexURL != "https://mirror.example.com" || cfg.Proxy != "http://proxy:3128" {
+		t.Errorf("Project prod profile not applied: %+v", cfg)
+	}
+}
+
+func TestSetProfile_Unknown(t *testing.T) {
+	t.Setenv("ZEPHYR_HOME", t.TempDir())
+	defer SetProfile("")
+	if err := SetProfile("staging"); err == nil {
+		t.Error("Expected an error selecting an undefined profile")
+	}
+	if Profile() != "" {
+		t.Errorf("Failed SetProfile left %q selected", Profile())
+	}
+}
+
 func TestAddPyPIHeaders(t *testing.T) {
 	req, _ := http.NewRequest("GET", "https://pypi.org", nil)
 	AddPyPIHeaders(req)
@@ -47,7 +82,7 @@ func TestDownloadFile_NotFound(t *testing.T) {
 	client := NewPyPIClient()
 	dir := t.TempDir()
 	file := filepath.Join(dir, "out.txt")
-	err := DownloadFile(client, "http://localhost:9999/notfound", file)
+	err := DownloadFile(client, "http://localhost:9999/notfound", file, DownloadOptions{})
 	if err == nil {
 		t.Error("Expected error for download from invalid URL")
 	}
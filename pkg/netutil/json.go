@@ -10,6 +10,9 @@ import (
 // DecodeJSONResponse decodes a JSON response from an HTTP response
 func DecodeJSONResponse(resp *http.Response, v interface{}) error {
 	defer resp.Body.Close()
+	if err := DecodeBody(resp); err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
 	
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
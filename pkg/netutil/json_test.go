@@ -12,7 +12,6 @@ import (
 func TestDecodeJSONResponse_Success(t *testing.T) {
 	obj := map[string]string{"foo": "bar"}
 	data, _ := json.Marshal(obj)
-	req := httptest.NewRequest("GET", "/", nil)
 	rw := httptest.NewRecorder()
 	rw.Write(data)
 	resp := rw.Result()
@@ -57,7 +56,7 @@ func TestValidateJSON(t *testing.T) {
 }
 
 func TestJSONMapMethods(t *testing.T) {
-	m := JSONMap{"foo": "bar", "num": 42, "bool": true, "arr": []interface{}{1, 2}, "map": map[string]interface{}{"x": 1}}
+	m := JSONMap{"foo": "bar", "num": 42, "bool": true, "arr": []interface{}{1, 2}, "map": map[string]interface{}{"x": 1.0}}
 	if v, _ := m.GetString("foo"); v != "bar" {
 		t.Error("GetString failed")
 	}
@@ -79,7 +78,7 @@ func TestJSONMapMethods(t *testing.T) {
 }
 
 func TestJSONArrayMethods(t *testing.T) {
-	a := JSONArray{"foo", 42, map[string]interface{}{"x": 1}}
+	a := JSONArray{"foo", 42, map[string]interface{}{"x": 1.0}}
 	if v, _ := a.GetString(0); v != "foo" {
 		t.Error("GetString failed")
 	}
@@ -0,0 +1,139 @@
+package netutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	// DefaultMaxArtifactSize bounds a single download
+	DefaultMaxArtifactSize = 4 << 30
+	// DefaultMaxExtractedSize bounds the unpacked size of a single archive
+	DefaultMaxExtractedSize = 16 << 30
+	// DefaultMaxCompressionRatio bounds how much an archive member may expand
+	DefaultMaxCompressionRatio = 200
+)
+
+// Limits bound what a downloaded artifact may cost in disk and memory, so a
+// malicious or corrupted archive fails instead of filling the disk
+type Limits struct {
+	// MaxArtifactSize is the largest download accepted, in bytes
+	MaxArtifactSize int64
+	// MaxExtractedSize is the largest total size an archive may unpack to
+	MaxExtractedSize int64
+	// MaxCompressionRatio is the largest uncompressed-to-compressed ratio
+	// allowed for an archive member
+	MaxCompressionRatio int64
+}
+
+// SizeLimitError reports an artifact or archive over one of the Limits
+type SizeLimitError struct {
+	// What names the artifact or archive member
+	What string
+	// Limit names the exceeded setting
+	Limit string
+	Size  int64
+	Max   int64
+}
+
+// Error implements the error interface
+func (e *SizeLimitError) Error() string {
+	return fmt.Sprintf("security error: %s exceeds %s (%d > %d); raise %s in the config if this is expected", e.What, e.Limit, e.Size, e.Max, e.Limit)
+}
+
+var (
+	// limitsMu guards limits, which SetArtifactLimits may change while
+	// downloads and extractions read them
+	limitsMu   sync.RWMutex
+	limits     = Limits{DefaultMaxArtifactSize, DefaultMaxExtractedSize, DefaultMaxCompressionRatio}
+	limitsOnce sync.Once
+)
+
+// configureLimits applies the max_artifact_size, max_extracted_size and
+// max_compression_ratio config settings
+func configureLimits() {
+	cfg, _ := LoadConfig()
+	if cfg == nil {
+		return
+	}
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	for _, setting := range []struct {
+		name  string
+		value string
+		limit *int64
+	}{
+		{"max_artifact_size", cfg.MaxArtifactSize, &limits.MaxArtifactSize},
+		{"max_extracted_size", cfg.MaxExtractedSize, &limits.MaxExtractedSize},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		size, err := ParseRate(setting.value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Warning: Ignoring %s: %v\n", setting.name, err)
+			continue
+		}
+		*setting.limit = size
+	}
+	if cfg.MaxCompressionRatio > 0 {
+		limits.MaxCompressionRatio = int64(cfg.MaxCompressionRatio)
+	}
+}
+
+// ArtifactLimits returns the configured size limits
+func ArtifactLimits() Limits {
+	limitsOnce.Do(configureLimits)
+	limitsMu.RLock()
+	defer limitsMu.RUnlock()
+	return limits
+}
+
+// SetArtifactLimits overrides the configured size limits. Zero fields keep
+// their current value.
+func SetArtifactLimits(l Limits) {
+	limitsOnce.Do(configureLimits)
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	if l.MaxArtifactSize > 0 {
+		limits.MaxArtifactSize = l.MaxArtifactSize
+	}
+	if l.MaxExtractedSize > 0 {
+		limits.MaxExtractedSize = l.MaxExtractedSize
+	}
+	if l.MaxCompressionRatio > 0 {
+		limits.MaxCompressionRatio = l.MaxCompressionRatio
+	}
+}
+
+// sizeLimitedReader fails once more than max bytes have been read
+type sizeLimitedReader struct {
+	reader io.Reader
+	what   string
+	read   int64
+	max    int64
+}
+
+// LimitSize wraps r so reading more than max bytes from it fails with a
+// SizeLimitError naming what. A max of zero or less disables the limit.
+func LimitSize(r io.Reader, what string, max int64) io.Reader {
+	if max <= 0 {
+		return r
+	}
+	return &sizeLimitedReader{reader: r, what: what, max: max}
+}
+
+func (r *sizeLimitedReader) Read(buf []byte) (int, error) {
+	// Read one byte past the limit so an artifact of exactly max bytes passes
+	if remaining := r.max - r.read + 1; int64(len(buf)) > remaining {
+		buf = buf[:remaining]
+	}
+	n, err := r.reader.Read(buf)
+	r.read += int64(n)
+	if r.read > r.max {
+		return n - int(r.read-r.max), &SizeLimitError{What: r.what, Limit: "max_artifact_size", Size: r.read, Max: r.max}
+	}
+	return n, err
+}
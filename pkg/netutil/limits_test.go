@@ -0,0 +1,47 @@
+package netutil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestLimitSize(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+	got, err := io.ReadAll(LimitSize(bytes.NewReader(data), "exact.whl", 1000))
+	if err != nil || len(got) != 1000 {
+		t.Fatalf("reading exactly the limit: got %d bytes, err %v", len(got), err)
+	}
+
+	got, err = io.ReadAll(LimitSize(bytes.NewReader(data), "big.whl", 999))
+	var limit *SizeLimitError
+	if !errors.As(err, &limit) {
+		t.Fatalf("expected SizeLimitError, got %v", err)
+	}
+	if limit.What != "big.whl" || limit.Max != 999 {
+		t.Errorf("unexpected error fields: %+v", limit)
+	}
+	if len(got) > 999 {
+		t.Errorf("read %d bytes past a 999 byte limit", len(got))
+	}
+}
+
+func TestStartDownload_SizeLimit(t *testing.T) {
+	saved := limits
+	defer func() { limits = saved }()
+	ArtifactLimits()
+	limits.MaxArtifactSize = 10
+
+	body, err := StartDownload(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(make([]byte, 100))), nil
+	})
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	defer body.Close()
+	var limit *SizeLimitError
+	if _, err := io.ReadAll(body); !errors.As(err, &limit) {
+		t.Fatalf("expected SizeLimitError, got %v", err)
+	}
+}
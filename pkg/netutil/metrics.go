@@ -0,0 +1,67 @@
+package netutil
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// RequestMetrics summarizes the HTTP traffic of every zephyr client since
+// the process started
+type RequestMetrics struct {
+	Requests int64
+	// Bytes counts response body bytes read
+	Bytes int64
+	// Elapsed adds up the time spent waiting for response headers
+	Elapsed time.Duration
+}
+
+var (
+	requestCount atomic.Int64
+	bytesRead    atomic.Int64
+	waitNanos    atomic.Int64
+)
+
+// Metrics returns the traffic counted so far
+func Metrics() RequestMetrics {
+	return RequestMetrics{
+		Requests: requestCount.Load(),
+		Bytes:    bytesRead.Load(),
+		Elapsed:  time.Duration(waitNanos.Load()),
+	}
+}
+
+// metricsTransport counts requests, response bytes and waiting time
+type metricsTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	requestCount.Add(1)
+	waitNanos.Add(int64(time.Since(started)))
+	if err == nil {
+		resp.Body = countingBody{ReadCloser: resp.Body}
+	}
+	return resp, err
+}
+
+// countingBody adds the bytes read from a response body to the metrics
+type countingBody struct {
+	io.ReadCloser
+}
+
+// Read implements io.Reader
+func (b countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	bytesRead.Add(int64(n))
+	return n, err
+}
+
+// clientTransport is the transport zephyr's HTTP clients send requests through
+func clientTransport() http.RoundTripper {
+	return userAgentTransport{base: newAuthTransport(pacingTransport{base: metricsTransport{base: SharedTransport()}})}
+}
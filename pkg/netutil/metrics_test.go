@@ -0,0 +1,36 @@
+package netutil
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	before := Metrics()
+	client := &http.Client{Transport: metricsTransport{base: http.DefaultTransport}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	after := Metrics()
+	if requests := after.Requests - before.Requests; requests != 2 {
+		t.Errorf("counted %d requests, want 2", requests)
+	}
+	if bytes := after.Bytes - before.Bytes; bytes != 20 {
+		t.Errorf("counted %d bytes, want 20", bytes)
+	}
+	if after.Elapsed <= before.Elapsed {
+		t.Error("expected the time waiting for responses to grow")
+	}
+}
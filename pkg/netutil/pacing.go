@@ -0,0 +1,128 @@
+package netutil
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MaxRetryAfter caps how long zephyr waits when an index asks it to back off
+const MaxRetryAfter = time.Minute
+
+var (
+	requestPacer  *RateLimiter
+	requestSlots  chan struct{}
+	pacingOnce    sync.Once
+	backoffMu     sync.Mutex
+	backoffByHost = make(map[string]time.Time)
+)
+
+// configurePacing applies the max_requests_per_second and
+// max_parallel_requests config settings
+func configurePacing() {
+	cfg, _ := LoadConfig()
+	if cfg == nil {
+		return
+	}
+	if cfg.MaxRequestsPerSecond > 0 {
+		requestPacer = NewRateLimiter(int64(cfg.MaxRequestsPerSecond))
+	}
+	if cfg.MaxParallelRequests > 0 {
+		requestSlots = make(chan struct{}, cfg.MaxParallelRequests)
+	}
+}
+
+// SetRequestPacing bounds how many requests start per second and how many
+// wait for a response at once, overriding the config. Zero removes a bound.
+func SetRequestPacing(perSecond, parallel int) {
+	pacingOnce.Do(configurePacing)
+	requestPacer, requestSlots = nil, nil
+	if perSecond > 0 {
+		requestPacer = NewRateLimiter(int64(perSecond))
+	}
+	if parallel > 0 {
+		requestSlots = make(chan struct{}, parallel)
+	}
+}
+
+// pacingTransport spaces requests out by the configured pacing and backs off
+// when an index answers 429 or 503 with Retry-After, so crawls of the index
+// behave well against PyPI's infrastructure and small private indexes
+type pacingTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t pacingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	pacingOnce.Do(configurePacing)
+	resp, err := t.send(req)
+	if err != nil || !retryable(req) {
+		return resp, err
+	}
+	wait, ok := retryAfter(resp)
+	if !ok {
+		return resp, nil
+	}
+	// Hold back every request to the host, then try this one once more
+	backoffMu.Lock()
+	backoffByHost[req.URL.Host] = time.Now().Add(wait)
+	backoffMu.Unlock()
+	fmt.Fprintf(os.Stderr, "[zephyr] %s asked zephyr to slow down; retrying in %s\n", req.URL.Host, wait.Round(time.Second))
+	resp.Body.Close()
+	return t.send(req)
+}
+
+// send waits for the host's backoff, the request rate and a free slot
+func (t pacingTransport) send(req *http.Request) (*http.Response, error) {
+	backoffMu.Lock()
+	until := backoffByHost[req.URL.Host]
+	backoffMu.Unlock()
+	if wait := time.Until(until); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	requestPacer.WaitN(1)
+	if slots := requestSlots; slots != nil {
+		slots <- struct{}{}
+		defer func() { <-slots }()
+	}
+	return t.base.RoundTrip(req)
+}
+
+// retryable reports whether req can be sent again: a GET or HEAD without a body
+func retryable(req *http.Request) bool {
+	return (req.Method == http.MethodGet || req.Method == http.MethodHead) && req.Body == nil
+}
+
+// retryAfter returns how long a 429 or 503 response asks clients to wait,
+// capped at MaxRetryAfter
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		wait = time.Until(at)
+	} else {
+		return 0, false
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > MaxRetryAfter {
+		wait = MaxRetryAfter
+	}
+	return wait, true
+}
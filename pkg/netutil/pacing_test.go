@@ -0,0 +1,67 @@
+package netutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPacingTransport_RetryAfter(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Transport: pacingTransport{base: http.DefaultTransport}}
+	started := time.Now()
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || attempts != 2 {
+		t.Errorf("status %d after %d attempts, want 200 after 2", resp.StatusCode, attempts)
+	}
+	if elapsed := time.Since(started); elapsed < time.Second {
+		t.Errorf("retried after %s, before Retry-After elapsed", elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		status int
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{http.StatusTooManyRequests, "5", 5 * time.Second, true},
+		{http.StatusServiceUnavailable, "3600", MaxRetryAfter, true},
+		{http.StatusTooManyRequests, "", 0, false},
+		{http.StatusOK, "5", 0, false},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		if got, ok := retryAfter(resp); got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%d, %q) = %s, %v", tt.status, tt.header, got, ok)
+		}
+	}
+}
+
+func TestSetRequestPacing(t *testing.T) {
+	SetRequestPacing(0, 1)
+	defer SetRequestPacing(0, 0)
+	if cap(requestSlots) != 1 || requestPacer != nil {
+		t.Errorf("pacing not applied: %d slots, pacer %v", cap(requestSlots), requestPacer)
+	}
+}
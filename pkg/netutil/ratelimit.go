@@ -0,0 +1,183 @@
+package netutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateChunk bounds a single limited read so throughput stays smooth
+const rateChunk = 32 * 1024
+
+// RateLimiter is a token bucket shared by concurrent downloads, holding up to
+// one second of traffic
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter allowing bytesPerSecond on average
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	return &RateLimiter{rate: float64(bytesPerSecond), tokens: float64(bytesPerSecond), last: time.Now()}
+}
+
+// WaitN blocks until n bytes may be transferred. Callers reserve tokens in
+// turn, so concurrent readers share the rate instead of each getting it.
+func (l *RateLimiter) WaitN(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// Reader wraps r so reads from it are paced by the limiter
+func (l *RateLimiter) Reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &limitedReader{reader: r, limiter: l}
+}
+
+type limitedReader struct {
+	reader  io.Reader
+	limiter *RateLimiter
+}
+
+func (r *limitedReader) Read(buf []byte) (int, error) {
+	if len(buf) > rateChunk {
+		buf = buf[:rateChunk]
+	}
+	n, err := r.reader.Read(buf)
+	r.limiter.WaitN(n)
+	return n, err
+}
+
+// ParseRate parses a transfer rate such as "500K", "10MB/s" or "1.5MiB/s"
+// into bytes per second. K, M and G are powers of 1024, as in curl.
+func ParseRate(value string) (int64, error) {
+	s := strings.TrimSpace(value)
+	s = strings.TrimSuffix(s, "/s")
+	upper := strings.ToUpper(s)
+	multiplier := 1.0
+	for _, unit := range []struct {
+		suffix string
+		scale  float64
+	}{
+		{"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	} {
+		if strings.HasSuffix(upper, unit.suffix) {
+			multiplier = unit.scale
+			s = s[:len(s)-len(unit.suffix)]
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid rate %q: expected a positive size such as 500K or 10MB/s", value)
+	}
+	return int64(number * multiplier), nil
+}
+
+var (
+	downloadLimiter *RateLimiter
+	downloadSlots   chan struct{}
+	politenessOnce  sync.Once
+)
+
+// configurePoliteness applies the limit_rate and max_parallel_downloads config settings
+func configurePoliteness() {
+	cfg, _ := LoadConfig()
+	if cfg == nil {
+		return
+	}
+	if cfg.LimitRate != "" {
+		rate, err := ParseRate(cfg.LimitRate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Warning: Ignoring limit_rate: %v\n", err)
+		} else {
+			downloadLimiter = NewRateLimiter(rate)
+		}
+	}
+	if cfg.MaxParallelDownloads > 0 {
+		downloadSlots = make(chan struct{}, cfg.MaxParallelDownloads)
+	}
+}
+
+// SetRateLimit caps the combined speed of all downloads, overriding the
+// limit_rate setting. Zero removes the limit.
+func SetRateLimit(bytesPerSecond int64) {
+	politenessOnce.Do(configurePoliteness)
+	downloadLimiter = nil
+	if bytesPerSecond > 0 {
+		downloadLimiter = NewRateLimiter(bytesPerSecond)
+	}
+}
+
+// SetMaxParallelDownloads bounds how many downloads run at once, overriding
+// the max_parallel_downloads setting. Zero removes the bound.
+func SetMaxParallelDownloads(n int) {
+	politenessOnce.Do(configurePoliteness)
+	downloadSlots = nil
+	if n > 0 {
+		downloadSlots = make(chan struct{}, n)
+	}
+}
+
+// StartDownload calls open once a download slot is free and paces the body it
+// returns by the download rate limit. Reading more than max_artifact_size from
+// the body fails. Closing the result frees the slot.
+func StartDownload(open func() (io.ReadCloser, error)) (io.ReadCloser, error) {
+	politenessOnce.Do(configurePoliteness)
+	slots := downloadSlots
+	if slots != nil {
+		slots <- struct{}{}
+	}
+	release := func() {
+		if slots != nil {
+			<-slots
+		}
+	}
+	body, err := open()
+	if err != nil {
+		release()
+		return nil, err
+	}
+	limited := LimitSize(body, "download", ArtifactLimits().MaxArtifactSize)
+	return &download{Reader: downloadLimiter.Reader(limited), body: body, release: release}, nil
+}
+
+type download struct {
+	io.Reader
+	body    io.Closer
+	release func()
+	once    sync.Once
+}
+
+func (d *download) Close() error {
+	err := d.body.Close()
+	d.once.Do(d.release)
+	return err
+}
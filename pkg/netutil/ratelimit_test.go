@@ -0,0 +1,96 @@
+package netutil
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{"1024", 1024},
+		{"500K", 500 * 1024},
+		{"10MB/s", 10 * 1024 * 1024},
+		{"1.5MiB/s", 3 * 512 * 1024},
+		{"2g", 2 << 30},
+		{"100B/s", 100},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.input)
+		if err != nil {
+			t.Errorf("ParseRate(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRate(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+	for _, input := range []string{"", "fast", "-5M", "0"} {
+		if _, err := ParseRate(input); err == nil {
+			t.Errorf("ParseRate(%q) should fail", input)
+		}
+	}
+}
+
+func TestRateLimiter_Reader(t *testing.T) {
+	// The bucket starts full with one second of traffic, so reading two
+	// seconds' worth takes about one second
+	limiter := NewRateLimiter(64 * 1024)
+	data := bytes.Repeat([]byte("x"), 128*1024)
+	start := time.Now()
+	n, err := io.Copy(io.Discard, limiter.Reader(bytes.NewReader(data)))
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("copy = %d, %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond || elapsed > 3*time.Second {
+		t.Errorf("reading 128KiB at 64KiB/s took %s, want about 1s", elapsed)
+	}
+
+	var unlimited *RateLimiter
+	if r := bytes.NewReader(data); unlimited.Reader(r) != r {
+		t.Error("a nil limiter should not wrap the reader")
+	}
+}
+
+func TestStartDownload_ParallelLimit(t *testing.T) {
+	SetRateLimit(0)
+	SetMaxParallelDownloads(2)
+	defer SetMaxParallelDownloads(0)
+
+	var active, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body, err := StartDownload(func() (io.ReadCloser, error) {
+				now := atomic.AddInt32(&active, 1)
+				for {
+					old := atomic.LoadInt32(&peak)
+					if now <= old || atomic.CompareAndSwapInt32(&peak, old, now) {
+						break
+					}
+				}
+				return io.NopCloser(bytes.NewReader([]byte("wheel"))), nil
+			})
+			if err != nil {
+				t.Errorf("StartDownload failed: %v", err)
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+			io.Copy(io.Discard, body)
+			atomic.AddInt32(&active, -1)
+			body.Close()
+		}()
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Errorf("%d downloads ran at once, want at most 2", peak)
+	}
+}
@@ -0,0 +1,111 @@
+package netutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultMaxConnections bounds idle connections kept across all hosts
+	DefaultMaxConnections = 100
+	// DefaultMaxConnectionsPerHost allows parallel downloads from one index
+	// without opening a connection per file
+	DefaultMaxConnectionsPerHost = 16
+)
+
+var (
+	sharedTransport     *http.Transport
+	sharedTransportOnce sync.Once
+)
+
+// ErrOffline is returned for every connection attempted while network access
+// is disabled
+var ErrOffline = errors.New("network access is disabled (--offline or ZEPHYR_OFFLINE)")
+
+// offline makes every transport refuse to dial
+var offline atomic.Bool
+
+// SetOffline disables or re-enables network access for all zephyr HTTP
+// clients, including ones created earlier
+func SetOffline(disabled bool) {
+	offline.Store(disabled)
+}
+
+// Offline reports whether network access is disabled
+func Offline() bool {
+	return offline.Load()
+}
+
+// SharedTransport returns the transport every zephyr HTTP client uses, so
+// metadata and artifact requests share one pool of keep-alive connections.
+// It negotiates HTTP/2 when the server supports it, honours HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY, and is sized by the max_connections and
+// max_connections_per_host config settings.
+func SharedTransport() *http.Transport {
+	sharedTransportOnce.Do(func() {
+		cfg, _ := LoadConfig()
+		transport, err := NewTransport(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[zephyr] Warning: %v, using the proxy environment variables\n", err)
+			transport, _ = NewTransport(nil)
+		}
+		sharedTransport = transport
+	})
+	return sharedTransport
+}
+
+// NewTransport builds a pooled, HTTP/2-capable transport from cfg. A nil cfg
+// or unset limits use the defaults.
+func NewTransport(cfg *Config) (*http.Transport, error) {
+	maxConns := DefaultMaxConnections
+	perHost := DefaultMaxConnectionsPerHost
+	proxy := http.ProxyFromEnvironment
+	if cfg != nil {
+		if cfg.MaxConnections > 0 {
+			maxConns = cfg.MaxConnections
+		}
+		if cfg.MaxConnectionsPerHost > 0 {
+			perHost = cfg.MaxConnectionsPerHost
+		}
+		if cfg.Proxy != "" {
+			proxyURL, err := url.Parse(cfg.Proxy)
+			if err != nil || proxyURL.Host == "" {
+				return nil, fmt.Errorf("invalid proxy URL %q", cfg.Proxy)
+			}
+			proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	dialer := &net.Dialer{
+		Timeout:   DefaultTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           offlineDialer(dialer.DialContext),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          maxConns,
+		MaxIdleConnsPerHost:   perHost,
+		MaxConnsPerHost:       perHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}, nil
+}
+
+// offlineDialer wraps dial so it fails with ErrOffline while network access is disabled
+func offlineDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if offline.Load() {
+			return nil, fmt.Errorf("refusing to connect to %s: %w", addr, ErrOffline)
+		}
+		return dial(ctx, network, addr)
+	}
+}
@@ -0,0 +1,106 @@
+package netutil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewTransport_Defaults(t *testing.T) {
+	transport, err := NewTransport(nil)
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	if !transport.ForceAttemptHTTP2 {
+		t.Error("expected HTTP/2 to be attempted")
+	}
+	if transport.MaxIdleConns != DefaultMaxConnections {
+		t.Errorf("MaxIdleConns = %d, want %d", transport.MaxIdleConns, DefaultMaxConnections)
+	}
+	if transport.MaxIdleConnsPerHost != DefaultMaxConnectionsPerHost || transport.MaxConnsPerHost != DefaultMaxConnectionsPerHost {
+		t.Errorf("per-host limits = %d/%d, want %d", transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost, DefaultMaxConnectionsPerHost)
+	}
+	if transport.Proxy == nil {
+		t.Error("expected the proxy environment variables to be honoured")
+	}
+}
+
+func TestNewTransport_Config(t *testing.T) {
+	transport, err := NewTransport(&Config{MaxConnections: 8, MaxConnectionsPerHost: 2, Proxy: "http://proxy.example.com:3128"})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	if transport.MaxIdleConns != 8 || transport.MaxConnsPerHost != 2 {
+		t.Errorf("limits = %d/%d, want 8/2", transport.MaxIdleConns, transport.MaxConnsPerHost)
+	}
+	req, _ := http.NewRequest("GET", "https://pypi.org/simple/", nil)
+	proxyURL, err := transport.Proxy(req)
+	if err != nil || proxyURL == nil || proxyURL.Host != "proxy.example.com:3128" {
+		t.Errorf("Proxy = %v, %v; want proxy.example.com:3128", proxyURL, err)
+	}
+
+	if _, err := NewTransport(&Config{Proxy: "not a url"}); err == nil {
+		t.Error("expected an error for an invalid proxy URL")
+	}
+}
+
+func TestMergeConfig_ConnectionLimits(t *testing.T) {
+	global := &Config{MaxConnections: 50, MaxConnectionsPerHost: 4}
+	project := &Config{MaxConnectionsPerHost: 32}
+	cfg := mergeConfig(global, project)
+	if cfg.MaxConnections != 50 || cfg.MaxConnectionsPerHost != 32 {
+		t.Errorf("merged limits = %d/%d, want 50/32", cfg.MaxConnections, cfg.MaxConnectionsPerHost)
+	}
+}
+
+func TestSharedTransport(t *testing.T) {
+	if SharedTransport() != SharedTransport() {
+		t.Error("expected one shared transport")
+	}
+	if NewPyPIClient().Transport != NewHTTPClient(0).Transport {
+		t.Error("expected clients to share the transport")
+	}
+}
+
+func TestNewTransport_HTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	transport, err := NewTransport(nil)
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("negotiated %s, want HTTP/2", resp.Proto)
+	}
+}
+
+func TestSetOffline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	transport, _ := NewTransport(nil)
+	client := &http.Client{Transport: transport}
+
+	SetOffline(true)
+	defer SetOffline(false)
+	if _, err := client.Get(server.URL); !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected ErrOffline, got %v", err)
+	}
+	SetOffline(false)
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request after re-enabling the network failed: %v", err)
+	}
+	resp.Body.Close()
+}
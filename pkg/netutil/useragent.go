@@ -0,0 +1,99 @@
+package netutil
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var (
+	uaMu sync.RWMutex
+	// toolVersion is the zephyr release named in the User-Agent
+	toolVersion = "dev"
+	// customUserAgent replaces the generated User-Agent when set
+	customUserAgent string
+)
+
+// ciProviders maps the variable each CI service sets to the name reported for it
+var ciProviders = []struct{ env, name string }{
+	{"GITHUB_ACTIONS", "github-actions"},
+	{"GITLAB_CI", "gitlab-ci"},
+	{"CIRCLECI", "circleci"},
+	{"BUILDKITE", "buildkite"},
+	{"TF_BUILD", "azure-pipelines"},
+	{"JENKINS_URL", "jenkins"},
+	{"TRAVIS", "travis"},
+	{"CI", "ci"},
+}
+
+// SetToolVersion records the zephyr release reported in the User-Agent
+func SetToolVersion(version string) {
+	uaMu.Lock()
+	defer uaMu.Unlock()
+	toolVersion = version
+}
+
+// SetCustomUserAgent replaces the whole User-Agent zephyr sends; "" restores
+// the generated one
+func SetCustomUserAgent(userAgent string) {
+	uaMu.Lock()
+	defer uaMu.Unlock()
+	customUserAgent = userAgent
+}
+
+// UserAgent returns the User-Agent zephyr identifies itself with, such as
+// "zephyr/1.4.0 (linux/amd64; ci/github-actions) acme-build". The CI service
+// is detected from its environment variables and the trailing suffix comes
+// from ZEPHYR_USER_AGENT_SUFFIX or the user_agent_suffix config setting, for
+// proxies that only admit known tools.
+func UserAgent() string {
+	uaMu.RLock()
+	defer uaMu.RUnlock()
+	if customUserAgent != "" {
+		return customUserAgent
+	}
+	details := []string{runtime.GOOS + "/" + runtime.GOARCH}
+	if ci := detectCI(); ci != "" {
+		details = append(details, "ci/"+ci)
+	}
+	ua := fmt.Sprintf("zephyr/%s (%s)", toolVersion, strings.Join(details, "; "))
+	suffix := os.Getenv("ZEPHYR_USER_AGENT_SUFFIX")
+	if suffix == "" {
+		if cfg, _ := LoadConfig(); cfg != nil {
+			suffix = cfg.UserAgentSuffix
+		}
+	}
+	if suffix = strings.TrimSpace(suffix); suffix != "" {
+		ua += " " + suffix
+	}
+	return ua
+}
+
+// detectCI names the CI service zephyr runs under, or returns ""
+func detectCI() string {
+	for _, provider := range ciProviders {
+		if value := os.Getenv(provider.env); value != "" && value != "false" && value != "0" {
+			return provider.name
+		}
+	}
+	return ""
+}
+
+// userAgentTransport sets the zephyr User-Agent on requests that have none
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") != "" {
+		return t.base.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the caller's request
+	clone := req.Clone(req.Context())
+	clone.Header.Set("User-Agent", UserAgent())
+	return t.base.RoundTrip(clone)
+}
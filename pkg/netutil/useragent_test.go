@@ -0,0 +1,54 @@
+package netutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUserAgent(t *testing.T) {
+	for _, provider := range ciProviders {
+		t.Setenv(provider.env, "")
+	}
+	t.Setenv("ZEPHYR_USER_AGENT_SUFFIX", "")
+	SetToolVersion("1.2.3")
+	defer SetToolVersion("dev")
+
+	ua := UserAgent()
+	if !strings.HasPrefix(ua, "zephyr/1.2.3 ("+runtime.GOOS+"/"+runtime.GOARCH) || strings.Contains(ua, "ci/") {
+		t.Errorf("UserAgent() = %q", ua)
+	}
+
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("ZEPHYR_USER_AGENT_SUFFIX", "acme-build/7")
+	if ua := UserAgent(); !strings.Contains(ua, "; ci/github-actions)") || !strings.HasSuffix(ua, ") acme-build/7") {
+		t.Errorf("UserAgent() = %q, want CI and suffix", ua)
+	}
+
+	SetCustomUserAgent("custom/1.0")
+	defer SetCustomUserAgent("")
+	if ua := UserAgent(); ua != "custom/1.0" {
+		t.Errorf("UserAgent() = %q, want the custom value", ua)
+	}
+}
+
+func TestUserAgentTransport(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("User-Agent"))
+	}))
+	defer server.Close()
+	SetCustomUserAgent("zephyr-test")
+	defer SetCustomUserAgent("")
+
+	client := &http.Client{Transport: userAgentTransport{base: http.DefaultTransport}}
+	client.Get(server.URL)
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("User-Agent", "twine-compatible")
+	client.Do(req)
+	if len(got) != 2 || got[0] != "zephyr-test" || got[1] != "twine-compatible" {
+		t.Errorf("User-Agent headers = %v", got)
+	}
+}
@@ -0,0 +1,17 @@
+// Package pkgname compares Python project names the way package indexes do.
+package pkgname
+
+import (
+	"regexp"
+	"strings"
+)
+
+// separators are the runs of characters PEP 503 treats as equivalent
+var separators = regexp.MustCompile(`[-_.]+`)
+
+// Normalize returns the PEP 503 form of a project name: lowercased, with
+// every run of "-", "_" and "." replaced by a single "-". PEP 685 normalizes
+// extra names the same way.
+func Normalize(name string) string {
+	return separators.ReplaceAllString(strings.ToLower(name), "-")
+}
@@ -0,0 +1,18 @@
+package pkgname
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"requests":           "requests",
+		"Charset_Normalizer": "charset-normalizer",
+		"zope.interface":     "zope-interface",
+		"foo__bar":           "foo-bar",
+		"Foo-_.Bar":          "foo-bar",
+	}
+	for name, want := range tests {
+		if got := Normalize(name); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Package progress defines the events zephyr's library packages report while
+// they resolve, download and install, so that programs embedding them, such
+// as GUIs and IDE plugins, can render their own progress.
+package progress
+
+// Kind identifies what an event reports
+type Kind string
+
+const (
+	// ResolutionStarted is sent when a solver starts; Package and Version name the root
+	ResolutionStarted Kind = "resolution-started"
+	// PackageDecided is sent when the solver chooses a version of Package
+	PackageDecided Kind = "package-decided"
+	// ResolutionFinished is sent when a solver stops, with Err set if it failed
+	ResolutionFinished Kind = "resolution-finished"
+	// DownloadStarted is sent before an artifact of Package is downloaded
+	DownloadStarted Kind = "download-started"
+	// DownloadProgress is sent as an artifact downloads, with Bytes read of Total
+	DownloadProgress Kind = "download-progress"
+	// DownloadFinished is sent once an artifact is downloaded and verified, or failed
+	DownloadFinished Kind = "download-finished"
+	// InstallStarted is sent before Package is installed into an environment
+	InstallStarted Kind = "install-started"
+	// InstallFinished is sent once Package is installed, with Err set if it failed
+	InstallFinished Kind = "install-finished"
+)
+
+// Event is a single progress report
+type Event struct {
+	Kind    Kind
+	Package string
+	Version string
+	// File is the artifact a download event is about
+	File string
+	// Bytes and Total count the bytes downloaded so far and expected; Total
+	// is 0 when the index does not publish the size
+	Bytes int64
+	Total int64
+	// Err is set on finished events when the step failed
+	Err error
+}
+
+// Handler receives events. It is called synchronously from the goroutine
+// doing the work, so it should return quickly.
+type Handler func(Event)
+
+// Emit passes e to h, doing nothing when no handler is set
+func (h Handler) Emit(e Event) {
+	if h != nil {
+		h(e)
+	}
+}
+
+// ToChannel returns a Handler sending events to ch. Events are dropped while
+// ch is full, so a slow reader never stalls resolution or installation.
+func ToChannel(ch chan<- Event) Handler {
+	return func(e Event) {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
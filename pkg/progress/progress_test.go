@@ -0,0 +1,22 @@
+package progress
+
+import "testing"
+
+func TestToChannel(t *testing.T) {
+	ch := make(chan Event, 1)
+	h := ToChannel(ch)
+	h.Emit(Event{Kind: ResolutionStarted, Package: "app"})
+	// A full channel drops the event instead of blocking
+	h.Emit(Event{Kind: ResolutionFinished})
+	if e := <-ch; e.Kind != ResolutionStarted || e.Package != "app" {
+		t.Errorf("unexpected event %+v", e)
+	}
+	select {
+	case e := <-ch:
+		t.Errorf("expected the second event to be dropped, got %+v", e)
+	default:
+	}
+
+	var none Handler
+	none.Emit(Event{Kind: PackageDecided})
+}
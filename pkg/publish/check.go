@@ -0,0 +1,201 @@
+package publish
+
+import (
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// CheckResult lists what an index would reject in an artifact (Errors) and
+// what it would accept but render poorly (Warnings)
+type CheckResult struct {
+	Errors   []string
+	Warnings []string
+}
+
+// OK reports whether the artifact can be uploaded
+func (r *CheckResult) OK() bool {
+	return len(r.Errors) == 0
+}
+
+func (r *CheckResult) errorf(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r *CheckResult) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// metadataVersions are the core metadata versions indexes accept
+var metadataVersions = map[string]bool{"1.0": true, "1.1": true, "1.2": true, "2.0": true, "2.1": true, "2.2": true, "2.3": true, "2.4": true}
+
+// projectNamePattern is the PEP 508 project name syntax
+var projectNamePattern = regexp.MustCompile(`(?i)^([a-z0-9]|[a-z0-9][a-z0-9._-]*[a-z0-9])$`)
+
+// classifierCategories are the top-level trove classifier categories
+var classifierCategories = map[string]bool{
+	"Development Status": true, "Environment": true, "Framework": true, "Intended Audience": true,
+	"License": true, "Natural Language": true, "Operating System": true, "Programming Language": true,
+	"Topic": true, "Typing": true,
+}
+
+// developmentStatuses are the only Development Status classifiers
+var developmentStatuses = map[string]bool{
+	"1 - Planning": true, "2 - Pre-Alpha": true, "3 - Alpha": true, "4 - Beta": true,
+	"5 - Production/Stable": true, "6 - Mature": true, "7 - Inactive": true,
+}
+
+// Check validates an artifact's metadata the way twine check and the index's
+// upload endpoint do, so problems surface before anything is sent
+func Check(a *Artifact) *CheckResult {
+	result := &CheckResult{}
+
+	metadataVersion := firstValue(a.Metadata["Metadata-Version"])
+	if metadataVersion == "" {
+		result.errorf("Metadata-Version is missing")
+	} else if !metadataVersions[metadataVersion] {
+		result.errorf("Metadata-Version %s is not a known core metadata version (1.0 to 2.4)", metadataVersion)
+	}
+	if name := a.Name(); !projectNamePattern.MatchString(name) {
+		result.errorf("Name %q is not a valid project name: use letters, digits, '.', '_' and '-', starting and ending with a letter or digit", name)
+	}
+	if v, err := version.Parse(a.Version()); err != nil {
+		result.errorf("Version %q is not a valid PEP 440 version: %v", a.Version(), err)
+	} else if v.IsLocal() {
+		result.errorf("Version %s has a local label; indexes reject local versions, so drop the +%s suffix", a.Version(), strings.SplitN(a.Version(), "+", 2)[1])
+	}
+	if requires := firstValue(a.Metadata["Requires-Python"]); requires != "" {
+		if _, err := version.Satisfies("0", requires); err != nil {
+			result.errorf("Requires-Python %q is not a valid specifier: %v", requires, err)
+		}
+	}
+
+	for _, classifier := range a.Metadata["Classifier"] {
+		if problem := checkClassifier(classifier); problem != "" {
+			result.errorf("Classifier %q %s", classifier, problem)
+		}
+	}
+
+	hasLicense := firstValue(a.Metadata["License"]) != "" || firstValue(a.Metadata["License-Expression"]) != ""
+	for _, classifier := range a.Metadata["Classifier"] {
+		hasLicense = hasLicense || strings.HasPrefix(classifier, "License :: ")
+	}
+	if !hasLicense {
+		result.errorf("no license: set license in buildmeta.yaml or add a \"License :: \" classifier")
+	}
+
+	checkDescription(a, result)
+	if firstValue(a.Metadata["Summary"]) == "" {
+		result.warnf("Summary is empty: set description in buildmeta.yaml for the one-line summary shown in search results")
+	}
+	return result
+}
+
+// checkClassifier returns why a trove classifier would be rejected, or ""
+func checkClassifier(classifier string) string {
+	parts := strings.Split(classifier, " :: ")
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" || part != strings.TrimSpace(part) || strings.Contains(part, "::") {
+			return "is malformed: segments must be separated by \" :: \""
+		}
+	}
+	if parts[0] == "Private" {
+		return "is private; indexes reject Private :: classifiers, which exist to prevent accidental uploads"
+	}
+	if !classifierCategories[parts[0]] {
+		return fmt.Sprintf("has unknown category %q; see https://pypi.org/classifiers/", parts[0])
+	}
+	if len(parts) < 2 {
+		return "names only a category; pick a classifier within it"
+	}
+	if parts[0] == "Development Status" && (len(parts) != 2 || !developmentStatuses[parts[1]]) {
+		return "is not a development status; use one of \"1 - Planning\" to \"7 - Inactive\""
+	}
+	return ""
+}
+
+// checkDescription checks the long description exists and will render
+func checkDescription(a *Artifact, result *CheckResult) {
+	description := firstValue(a.Metadata["Description"])
+	if description == "" {
+		result.errorf("no long description: add a README.md or README.rst to the project so the index has a project page")
+		return
+	}
+	contentType := firstValue(a.Metadata["Description-Content-Type"])
+	if contentType == "" {
+		result.warnf("Description-Content-Type is missing, so the index renders the long description as reStructuredText")
+		contentType = "text/x-rst"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		result.errorf("Description-Content-Type %q is invalid: %v", contentType, err)
+		return
+	}
+	switch mediaType {
+	case "text/plain":
+	case "text/markdown":
+		if variant, ok := params["variant"]; ok && variant != "GFM" && variant != "CommonMark" {
+			result.errorf("Description-Content-Type has unknown markdown variant %q; use GFM or CommonMark", variant)
+		}
+	case "text/x-rst":
+		for _, problem := range checkRST(description) {
+			result.errorf("long description does not render as reStructuredText: %s", problem)
+		}
+	default:
+		result.errorf("Description-Content-Type %q is not supported; use text/markdown, text/x-rst or text/plain", mediaType)
+	}
+}
+
+// rstAdornmentChars are the punctuation characters section adornments use
+const rstAdornmentChars = "=-`:'\"~^_*+#<>"
+
+// isRSTAdornment reports whether a line is a section underline or overline:
+// three or more of one punctuation character
+func isRSTAdornment(line string) bool {
+	line = strings.TrimRight(line, " \t")
+	if len(line) < 3 || !strings.ContainsRune(rstAdornmentChars, rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// checkRST finds the reStructuredText mistakes that stop PyPI's renderer:
+// section adornments shorter than their titles and unbalanced inline literals
+func checkRST(text string) []string {
+	var problems []string
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		adornment := strings.TrimRight(lines[i], " \t")
+		title := strings.TrimRight(lines[i-1], " \t")
+		if !isRSTAdornment(adornment) || strings.TrimSpace(title) == "" || isRSTAdornment(title) {
+			continue
+		}
+		if strings.HasPrefix(title, " ") || strings.HasPrefix(title, "\t") {
+			continue
+		}
+		if len([]rune(adornment)) < len([]rune(title)) {
+			problems = append(problems, fmt.Sprintf("line %d: title underline too short for %q", i+1, title))
+		}
+	}
+	inLiteralBlock := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasSuffix(trimmed, "::") || strings.HasPrefix(trimmed, ".. code") {
+			inLiteralBlock = true
+			continue
+		}
+		if inLiteralBlock {
+			if trimmed == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+				continue
+			}
+			inLiteralBlock = false
+		}
+		if strings.Count(line, "``")%2 != 0 {
+			problems = append(problems, fmt.Sprintf("line %d: inline literal start-string without end-string", i+1))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,61 @@
+package publish
+
+import (
+	"strings"
+	"testing"
+)
+
+func artifactWith(fields map[string]string) *Artifact {
+	metadata := map[string][]string{
+		"Metadata-Version":         {"2.1"},
+		"Name":                     {"demo"},
+		"Version":                  {"1.0.0"},
+		"Summary":                  {"A demo"},
+		"License":                  {"MIT"},
+		"Description-Content-Type": {"text/markdown"},
+		"Description":              {"# Demo\n"},
+	}
+	for key, value := range fields {
+		if value == "" {
+			delete(metadata, key)
+			continue
+		}
+		metadata[key] = strings.Split(value, "|")
+	}
+	return &Artifact{Path: "demo-1.0.0-py3-none-any.whl", Metadata: metadata}
+}
+
+func TestCheck(t *testing.T) {
+	if result := Check(artifactWith(nil)); !result.OK() || len(result.Warnings) != 0 {
+		t.Errorf("clean artifact: %+v", result)
+	}
+	for _, tt := range []struct {
+		fields map[string]string
+		want   string
+	}{
+		{map[string]string{"Metadata-Version": "3.0"}, "Metadata-Version 3.0"},
+		{map[string]string{"Name": "-demo"}, "not a valid project name"},
+		{map[string]string{"Version": "1.0.0+local"}, "local label"},
+		{map[string]string{"Version": "one"}, "not a valid PEP 440"},
+		{map[string]string{"Classifier": "Private :: Do Not Upload"}, "indexes reject Private"},
+		{map[string]string{"Classifier": "Development Status :: 4 - beta"}, "not a development status"},
+		{map[string]string{"Classifier": "Topic ::Utilities"}, "malformed"},
+		{map[string]string{"Classifier": "Frameworks :: Django"}, "unknown category"},
+		{map[string]string{"License": ""}, "no license"},
+		{map[string]string{"Description": ""}, "no long description"},
+		{map[string]string{"Description-Content-Type": "text/html"}, "not supported"},
+		{map[string]string{"Description-Content-Type": "text/markdown; variant=Markua"}, "unknown markdown variant"},
+		{map[string]string{"Description-Content-Type": "text/x-rst", "Description": "Demo project\n=====\n\nText\n"}, "title underline too short"},
+		{map[string]string{"Description-Content-Type": "text/x-rst", "Description": "Run ``demo --help to start\n"}, "inline literal"},
+	} {
+		result := Check(artifactWith(tt.fields))
+		if result.OK() || !strings.Contains(strings.Join(result.Errors, "\n"), tt.want) {
+			t.Errorf("Check(%v) errors = %v, want %q", tt.fields, result.Errors, tt.want)
+		}
+	}
+
+	result := Check(artifactWith(map[string]string{"License": "", "Classifier": "License :: OSI Approved :: MIT License", "Description-Content-Type": "", "Description": "Demo\n====\n\n::\n\n    ``odd\n"}))
+	if !result.OK() || len(result.Warnings) != 1 {
+		t.Errorf("expected only a content type warning: %+v", result)
+	}
+}
@@ -0,0 +1,185 @@
+package publish
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/netutil"
+)
+
+// ErrNoAmbientCredentials means the process is not running in a CI job that
+// can issue OIDC tokens
+var ErrNoAmbientCredentials = errors.New("no OIDC token available: trusted publishing works in GitHub Actions jobs with `permissions: id-token: write` and GitLab CI jobs with an id_tokens entry named <AUDIENCE>_ID_TOKEN")
+
+// TrustedPublisher exchanges a CI provider's OIDC token for a short-lived
+// index API token, as PyPI's trusted publishing does
+type TrustedPublisher struct {
+	// IndexURL is the index's web root, e.g. https://pypi.org
+	IndexURL string
+	Client   *http.Client
+	// Getenv reads the CI environment; os.Getenv when nil
+	Getenv func(string) string
+}
+
+// NewTrustedPublisher returns a trusted publisher for the index that serves repositoryURL
+func NewTrustedPublisher(repositoryURL string) (*TrustedPublisher, error) {
+	indexURL, err := IndexURLForRepository(repositoryURL)
+	if err != nil {
+		return nil, err
+	}
+	return &TrustedPublisher{IndexURL: indexURL, Client: netutil.NewHTTPClient(0)}, nil
+}
+
+// IndexURLForRepository returns the web root of the index behind an upload
+// URL: upload.pypi.org maps to pypi.org, other hosts to themselves
+func IndexURLForRepository(repositoryURL string) (string, error) {
+	if repositoryURL == "" {
+		repositoryURL = DefaultRepositoryURL
+	}
+	u, err := url.Parse(repositoryURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid repository URL '%s'", repositoryURL)
+	}
+	host := u.Host
+	if host == "upload.pypi.org" {
+		host = "pypi.org"
+	}
+	return u.Scheme + "://" + host, nil
+}
+
+// Available reports whether the environment looks like a CI job that can issue OIDC tokens
+func (p *TrustedPublisher) Available() bool {
+	if p.getenv("ACTIONS_ID_TOKEN_REQUEST_URL") != "" && p.getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN") != "" {
+		return true
+	}
+	return p.getenv("GITLAB_CI") != ""
+}
+
+// Token obtains an OIDC token from the CI provider and exchanges it with the
+// index for an API token that can upload to the projects the publisher is
+// configured for
+func (p *TrustedPublisher) Token() (string, error) {
+	audience, err := p.audience()
+	if err != nil {
+		return "", err
+	}
+	oidcToken, err := p.ambientToken(audience)
+	if err != nil {
+		return "", err
+	}
+	body, _ := json.Marshal(map[string]string{"token": oidcToken})
+	resp, err := p.client().Post(p.IndexURL+"/_/oidc/mint-token", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange the OIDC token with %s: %w", p.IndexURL, err)
+	}
+	defer resp.Body.Close()
+	var minted struct {
+		Token   string `json:"token"`
+		Message string `json:"message"`
+		Errors  []struct {
+			Code        string `json:"code"`
+			Description string `json:"description"`
+		} `json:"errors"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	json.Unmarshal(data, &minted)
+	if resp.StatusCode != http.StatusOK || minted.Token == "" {
+		var reasons []string
+		for _, e := range minted.Errors {
+			reasons = append(reasons, e.Description)
+		}
+		if len(reasons) == 0 && minted.Message != "" {
+			reasons = append(reasons, minted.Message)
+		}
+		return "", fmt.Errorf("%s refused the OIDC token (HTTP %d): %s. Check that a trusted publisher matching this repository and workflow is configured for the project", p.IndexURL, resp.StatusCode, strings.Join(reasons, "; "))
+	}
+	return minted.Token, nil
+}
+
+// audience asks the index which audience its OIDC tokens must carry
+func (p *TrustedPublisher) audience() (string, error) {
+	var result struct {
+		Audience string `json:"audience"`
+	}
+	resp, err := p.client().Get(p.IndexURL + "/_/oidc/audience")
+	if err != nil {
+		return "", fmt.Errorf("failed to reach %s: %w", p.IndexURL, err)
+	}
+	if err := netutil.DecodeJSONResponse(resp, &result); err != nil {
+		return "", fmt.Errorf("%s does not support trusted publishing: %w", p.IndexURL, err)
+	}
+	if result.Audience == "" {
+		return "", fmt.Errorf("%s returned no OIDC audience", p.IndexURL)
+	}
+	return result.Audience, nil
+}
+
+// nonAlphanumeric matches characters that cannot appear in a variable name
+var nonAlphanumeric = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+// ambientToken returns an OIDC token for audience from GitHub Actions or GitLab CI
+func (p *TrustedPublisher) ambientToken(audience string) (string, error) {
+	if requestURL := p.getenv("ACTIONS_ID_TOKEN_REQUEST_URL"); requestURL != "" {
+		requestToken := p.getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+		if requestToken == "" {
+			return "", ErrNoAmbientCredentials
+		}
+		u, err := url.Parse(requestURL)
+		if err != nil {
+			return "", fmt.Errorf("invalid ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+		}
+		query := u.Query()
+		query.Set("audience", audience)
+		u.RawQuery = query.Encode()
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Authorization", "bearer "+requestToken)
+		resp, err := p.client().Do(req)
+		if err != nil {
+			return "", fmt.Errorf("failed to request an OIDC token from GitHub Actions: %w", err)
+		}
+		var result struct {
+			Value string `json:"value"`
+		}
+		if err := netutil.DecodeJSONResponse(resp, &result); err != nil {
+			return "", fmt.Errorf("GitHub Actions issued no OIDC token: %w. Grant the job `permissions: id-token: write`", err)
+		}
+		if result.Value == "" {
+			return "", fmt.Errorf("GitHub Actions issued an empty OIDC token. Grant the job `permissions: id-token: write`")
+		}
+		return result.Value, nil
+	}
+	if p.getenv("GITLAB_CI") != "" {
+		// GitLab exposes tokens declared under id_tokens as variables
+		name := strings.ToUpper(nonAlphanumeric.ReplaceAllString(audience, "_")) + "_ID_TOKEN"
+		if token := p.getenv(name); token != "" {
+			return token, nil
+		}
+		return "", fmt.Errorf("GitLab CI provided no %s: declare it under id_tokens with aud: %s", name, audience)
+	}
+	return "", ErrNoAmbientCredentials
+}
+
+func (p *TrustedPublisher) getenv(key string) string {
+	if p.Getenv != nil {
+		return p.Getenv(key)
+	}
+	return os.Getenv(key)
+}
+
+func (p *TrustedPublisher) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return netutil.NewHTTPClient(0)
+}
@@ -0,0 +1,301 @@
+// Package publish uploads built wheels and sdists to a package index using
+// the legacy upload API that PyPI, TestPyPI and most private indexes accept.
+package publish
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/netutil"
+)
+
+// DefaultRepositoryURL is PyPI's upload endpoint
+const DefaultRepositoryURL = "https://upload.pypi.org/legacy/"
+
+// TokenUsername is the username an index expects alongside an API token
+const TokenUsername = "__token__"
+
+// Uploader uploads distributions to RepositoryURL with HTTP basic auth
+type Uploader struct {
+	RepositoryURL string
+	Username      string
+	Password      string
+	Client        *http.Client
+}
+
+// NewUploader returns an uploader that authenticates with an API token
+func NewUploader(repositoryURL, token string) *Uploader {
+	if repositoryURL == "" {
+		repositoryURL = DefaultRepositoryURL
+	}
+	return &Uploader{
+		RepositoryURL: repositoryURL,
+		Username:      TokenUsername,
+		Password:      token,
+		Client:        netutil.NewHTTPClient(0),
+	}
+}
+
+// Artifact is a distribution file and the core metadata read from it
+type Artifact struct {
+	Path string
+	// Filetype is bdist_wheel or sdist
+	Filetype string
+	// PyVersion is the wheel's python tag, or "source" for an sdist
+	PyVersion string
+	Metadata  map[string][]string
+}
+
+// Name returns the distribution name from the artifact's metadata
+func (a *Artifact) Name() string {
+	return firstValue(a.Metadata["Name"])
+}
+
+// Version returns the version from the artifact's metadata
+func (a *Artifact) Version() string {
+	return firstValue(a.Metadata["Version"])
+}
+
+// OpenArtifact reads the core metadata of a wheel or .tar.gz sdist
+func OpenArtifact(path string) (*Artifact, error) {
+	base := filepath.Base(path)
+	switch {
+	case strings.HasSuffix(base, ".whl"):
+		parts := strings.Split(strings.TrimSuffix(base, ".whl"), "-")
+		if len(parts) != 5 && len(parts) != 6 {
+			return nil, fmt.Errorf("invalid wheel filename: %s", base)
+		}
+		metadata, err := wheelMetadata(path)
+		if err != nil {
+			return nil, err
+		}
+		return &Artifact{Path: path, Filetype: "bdist_wheel", PyVersion: parts[len(parts)-3], Metadata: metadata}, nil
+	case strings.HasSuffix(base, ".tar.gz"):
+		metadata, err := sdistMetadata(path)
+		if err != nil {
+			return nil, err
+		}
+		return &Artifact{Path: path, Filetype: "sdist", PyVersion: "source", Metadata: metadata}, nil
+	}
+	return nil, fmt.Errorf("%s is not a wheel or .tar.gz sdist", base)
+}
+
+// wheelMetadata reads the METADATA file from a wheel's dist-info directory
+func wheelMetadata(path string) (map[string][]string, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wheel '%s': %w", path, err)
+	}
+	defer reader.Close()
+	for _, f := range reader.File {
+		dir, name := pathSplit(f.Name)
+		if name == "METADATA" && strings.HasSuffix(dir, ".dist-info") && !strings.Contains(dir, "/") {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+			}
+			defer rc.Close()
+			return parseMetadata(rc)
+		}
+	}
+	return nil, fmt.Errorf("wheel '%s' has no dist-info METADATA", path)
+}
+
+// sdistMetadata reads the top-level PKG-INFO file from an sdist
+func sdistMetadata(path string) (map[string][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sdist '%s': %w", path, err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sdist '%s': %w", path, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("sdist '%s' has no PKG-INFO", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sdist '%s': %w", path, err)
+		}
+		if dir, name := pathSplit(header.Name); name == "PKG-INFO" && dir != "" && !strings.Contains(dir, "/") {
+			return parseMetadata(tr)
+		}
+	}
+}
+
+// pathSplit splits an archive member name into its directory and base name
+func pathSplit(name string) (string, string) {
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return "", name
+	}
+	return name[:i], name[i+1:]
+}
+
+// parseMetadata parses the email-header fields of a METADATA or PKG-INFO
+// file, storing the message body under Description
+func parseMetadata(r io.Reader) (map[string][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	metadata := make(map[string][]string)
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if line == "" {
+			// The message body is the long description
+			if body := strings.TrimSpace(strings.Join(lines[i+1:], "\n")); body != "" && len(metadata["Description"]) == 0 {
+				metadata["Description"] = []string{body}
+			}
+			break
+		}
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			metadata[key] = append(metadata[key], strings.TrimSpace(value))
+		}
+	}
+	if len(metadata["Name"]) == 0 || len(metadata["Version"]) == 0 {
+		return nil, fmt.Errorf("metadata has no Name or Version")
+	}
+	return metadata, nil
+}
+
+// uploadFields maps core metadata fields to the upload API's form fields
+var uploadFields = map[string]string{
+	"Metadata-Version":         "metadata_version",
+	"Name":                     "name",
+	"Version":                  "version",
+	"Summary":                  "summary",
+	"Description":              "description",
+	"Description-Content-Type": "description_content_type",
+	"Home-page":                "home_page",
+	"Author":                   "author",
+	"Author-email":             "author_email",
+	"License":                  "license",
+	"Keywords":                 "keywords",
+	"Classifier":               "classifiers",
+	"Requires-Python":          "requires_python",
+	"Requires-Dist":            "requires_dist",
+	"Provides-Extra":           "provides_extra",
+	"Project-URL":              "project_urls",
+}
+
+// UploadError is an upload the index rejected
+type UploadError struct {
+	File       string
+	StatusCode int
+	Message    string
+}
+
+func (e *UploadError) Error() string {
+	msg := fmt.Sprintf("index rejected %s: HTTP %d %s", e.File, e.StatusCode, e.Message)
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		msg += ". Check the API token and that its scope covers this project, or the trusted publisher configured for this project on the index"
+	case http.StatusBadRequest:
+		if strings.Contains(strings.ToLower(e.Message), "already exists") {
+			msg += ". Versions cannot be re-uploaded; bump the version in buildmeta.yaml and rebuild"
+		}
+	}
+	return msg
+}
+
+// Upload sends one artifact to the index
+func (u *Uploader) Upload(artifact *Artifact) error {
+	content, err := os.ReadFile(artifact.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read '%s': %w", artifact.Path, err)
+	}
+	sha := sha256.Sum256(content)
+	md5sum := md5.Sum(content)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := [][2]string{
+		{":action", "file_upload"},
+		{"protocol_version", "1"},
+		{"filetype", artifact.Filetype},
+		{"pyversion", artifact.PyVersion},
+		{"sha256_digest", hex.EncodeToString(sha[:])},
+		{"md5_digest", hex.EncodeToString(md5sum[:])},
+	}
+	for key, field := range uploadFields {
+		for _, value := range artifact.Metadata[key] {
+			fields = append(fields, [2]string{field, value})
+		}
+	}
+	for _, field := range fields {
+		if err := form.WriteField(field[0], field[1]); err != nil {
+			return fmt.Errorf("failed to encode upload: %w", err)
+		}
+	}
+	part, err := form.CreateFormFile("content", filepath.Base(artifact.Path))
+	if err != nil {
+		return fmt.Errorf("failed to encode upload: %w", err)
+	}
+	part.Write(content)
+	if err := form.Close(); err != nil {
+		return fmt.Errorf("failed to encode upload: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u.RepositoryURL, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("User-Agent", netutil.UserAgent())
+	req.SetBasicAuth(u.Username, u.Password)
+	client := u.Client
+	if client == nil {
+		client = netutil.NewHTTPClient(0)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", filepath.Base(artifact.Path), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		// PyPI puts the reason in the status line; other indexes use the body
+		text := strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)))
+		if line := firstLine(string(message)); text == http.StatusText(resp.StatusCode) && line != "" && !strings.HasPrefix(line, "<") {
+			text = line
+		}
+		return &UploadError{File: filepath.Base(artifact.Path), StatusCode: resp.StatusCode, Message: text}
+	}
+	return nil
+}
+
+// firstLine returns the first non-empty line of s, trimmed
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// firstValue returns the first value of a metadata field, or ""
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,149 @@
+package publish
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createWheel writes a minimal wheel with the given METADATA
+func createWheel(t *testing.T, dir, filename, metadata string) string {
+	path := filepath.Join(dir, filename)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	entry, _ := w.Create("demo-1.0.0.dist-info/METADATA")
+	entry.Write([]byte(metadata))
+	w.Close()
+	f.Close()
+	return path
+}
+
+// newIndex serves the trusted publishing and upload endpoints of a fake index
+func newIndex(t *testing.T, uploads *[]map[string][]string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_/oidc/audience", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"audience": "testindex"}`))
+	})
+	mux.HandleFunc("/_/oidc/mint-token", func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Token string }
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Token != "oidc-for-testindex" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"message": "Token request failed", "errors": [{"code": "invalid-publisher", "description": "valid token, but no corresponding publisher"}]}`))
+			return
+		}
+		w.Write([]byte(`{"success": true, "token": "pypi-minted"}`))
+	})
+	mux.HandleFunc("/github-token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "bearer request-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"value": "oidc-for-` + r.URL.Query().Get("audience") + `"}`))
+	})
+	mux.HandleFunc("/legacy/", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != TokenUsername || pass != "pypi-minted" {
+			http.Error(w, "Invalid or non-existent authentication information.", http.StatusForbidden)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*uploads = append(*uploads, r.MultipartForm.Value)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestTrustedPublishingUpload(t *testing.T) {
+	var uploads []map[string][]string
+	server := newIndex(t, &uploads)
+	defer server.Close()
+
+	env := map[string]string{
+		"ACTIONS_ID_TOKEN_REQUEST_URL":   server.URL + "/github-token?api-version=2.0",
+		"ACTIONS_ID_TOKEN_REQUEST_TOKEN": "request-token",
+	}
+	publisher := &TrustedPublisher{IndexURL: server.URL, Client: server.Client(), Getenv: func(k string) string { return env[k] }}
+	if !publisher.Available() {
+		t.Fatal("GitHub Actions environment not detected")
+	}
+	token, err := publisher.Token()
+	if err != nil || token != "pypi-minted" {
+		t.Fatalf("Token = %q, %v", token, err)
+	}
+
+	wheel := createWheel(t, t.TempDir(), "demo-1.0.0-py3-none-any.whl", "Metadata-Version: 2.1\nName: demo\nVersion: 1.0.0\nRequires-Dist: click\nRequires-Dist: requests>=2\n\nLong description\n")
+	artifact, err := OpenArtifact(wheel)
+	if err != nil {
+		t.Fatalf("OpenArtifact failed: %v", err)
+	}
+	uploader := &Uploader{RepositoryURL: server.URL + "/legacy/", Username: TokenUsername, Password: token, Client: server.Client()}
+	if err := uploader.Upload(artifact); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if len(uploads) != 1 {
+		t.Fatalf("expected one upload, got %d", len(uploads))
+	}
+	form := uploads[0]
+	if form["name"][0] != "demo" || form["filetype"][0] != "bdist_wheel" || form["pyversion"][0] != "py3" || len(form["requires_dist"]) != 2 || len(form["sha256_digest"][0]) != 64 {
+		t.Errorf("unexpected upload form %v", form)
+	}
+
+	uploader.Password = "stale"
+	err = uploader.Upload(artifact)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 403") {
+		t.Errorf("expected an authentication error, got %v", err)
+	}
+}
+
+func TestTrustedPublishing_GitLabAndErrors(t *testing.T) {
+	var uploads []map[string][]string
+	server := newIndex(t, &uploads)
+	defer server.Close()
+
+	env := map[string]string{"GITLAB_CI": "true", "TESTINDEX_ID_TOKEN": "oidc-for-testindex"}
+	publisher := &TrustedPublisher{IndexURL: server.URL, Client: server.Client(), Getenv: func(k string) string { return env[k] }}
+	if token, err := publisher.Token(); err != nil || token != "pypi-minted" {
+		t.Errorf("GitLab Token = %q, %v", token, err)
+	}
+
+	env["TESTINDEX_ID_TOKEN"] = "someone-else"
+	if _, err := publisher.Token(); err == nil || !strings.Contains(err.Error(), "no corresponding publisher") {
+		t.Errorf("expected the index's refusal, got %v", err)
+	}
+	delete(env, "TESTINDEX_ID_TOKEN")
+	if _, err := publisher.Token(); err == nil || !strings.Contains(err.Error(), "TESTINDEX_ID_TOKEN") {
+		t.Errorf("expected a missing id_tokens error, got %v", err)
+	}
+
+	outside := &TrustedPublisher{IndexURL: server.URL, Client: server.Client(), Getenv: func(string) string { return "" }}
+	if outside.Available() {
+		t.Error("trusted publishing detected outside CI")
+	}
+	if _, err := outside.Token(); err != ErrNoAmbientCredentials {
+		t.Errorf("expected ErrNoAmbientCredentials, got %v", err)
+	}
+}
+
+func TestIndexURLForRepository(t *testing.T) {
+	for repository, want := range map[string]string{
+		"":                                  "https://pypi.org",
+		"https://upload.pypi.org/legacy/":   "https://pypi.org",
+		"https://test.pypi.org/legacy/":     "https://test.pypi.org",
+		"https://pkgs.example.com/upload/x": "https://pkgs.example.com",
+	} {
+		if got, err := IndexURLForRepository(repository); err != nil || got != want {
+			t.Errorf("IndexURLForRepository(%q) = %q, %v", repository, got, err)
+		}
+	}
+}
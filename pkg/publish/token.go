@@ -0,0 +1,50 @@
+package publish
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// pypiTokenPrefix starts every API token PyPI and TestPyPI issue
+const pypiTokenPrefix = "pypi-"
+
+// isPyPI reports whether repositoryURL uploads to PyPI or TestPyPI, which
+// accept only API tokens now that they require two-factor authentication
+func isPyPI(repositoryURL string) bool {
+	if repositoryURL == "" {
+		return true
+	}
+	u, err := url.Parse(repositoryURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return host == "upload.pypi.org" || host == "test.pypi.org"
+}
+
+// CheckCredentials catches credentials PyPI would reject before anything is
+// uploaded: PyPI and TestPyPI take only API tokens, sent with the __token__
+// username, since password logins cannot complete a two-factor challenge.
+// Other indexes accept whatever they are configured for.
+func CheckCredentials(repositoryURL, username, token string) error {
+	if !isPyPI(repositoryURL) {
+		return nil
+	}
+	if username != "" && username != TokenUsername {
+		return fmt.Errorf("%s accepts only API tokens with the username %s, not %q: password uploads are rejected for accounts with two-factor authentication", repositoryURL, TokenUsername, username)
+	}
+	if !strings.HasPrefix(token, pypiTokenPrefix) {
+		return fmt.Errorf("the token for %s does not look like a PyPI API token (they start with %q); create one at %s/manage/account/token/", repositoryURL, pypiTokenPrefix, indexOrDefault(repositoryURL))
+	}
+	return nil
+}
+
+// indexOrDefault returns the web root behind repositoryURL, or PyPI's
+func indexOrDefault(repositoryURL string) string {
+	index, err := IndexURLForRepository(repositoryURL)
+	if err != nil {
+		return "https://pypi.org"
+	}
+	return index
+}
@@ -0,0 +1,24 @@
+package publish
+
+import "testing"
+
+func TestCheckCredentials(t *testing.T) {
+	tests := []struct {
+		repository string
+		username   string
+		token      string
+		ok         bool
+	}{
+		{"", TokenUsername, "pypi-AgEIcHlwaS5vcmc", true},
+		{"https://test.pypi.org/legacy/", "", "pypi-AgENdGVzdC5weXBp", true},
+		{"https://upload.pypi.org/legacy/", "alice", "hunter2", false},
+		{"https://upload.pypi.org/legacy/", TokenUsername, "hunter2", false},
+		{"https://pkgs.example.com/upload/", "alice", "hunter2", true},
+	}
+	for _, tt := range tests {
+		err := CheckCredentials(tt.repository, tt.username, tt.token)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckCredentials(%q, %q, %q) = %v, want ok=%v", tt.repository, tt.username, tt.token, err, tt.ok)
+		}
+	}
+}
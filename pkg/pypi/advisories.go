@@ -0,0 +1,58 @@
+package pypi
+
+import (
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// Vulnerability is a known advisory affecting a release, as reported by the
+// PyPI JSON API from the OSV database
+type Vulnerability struct {
+	ID        string    `json:"id"`
+	Aliases   []string  `json:"aliases"`
+	Summary   string    `json:"summary"`
+	Details   string    `json:"details"`
+	FixedIn   []string  `json:"fixed_in"`
+	Link      string    `json:"link"`
+	Source    string    `json:"source"`
+	Withdrawn Timestamp `json:"withdrawn"`
+}
+
+// Active reports whether the advisory still applies
+func (v Vulnerability) Active() bool {
+	return v.Withdrawn.IsZero()
+}
+
+// ActiveVulnerabilities filters out withdrawn advisories
+func ActiveVulnerabilities(vulns []Vulnerability) []Vulnerability {
+	var active []Vulnerability
+	for _, vuln := range vulns {
+		if vuln.Active() {
+			active = append(active, vuln)
+		}
+	}
+	return active
+}
+
+// FixedVersion returns the lowest version above current that fixes every
+// active advisory in vulns. It returns "" when any advisory has no fix.
+func FixedVersion(current string, vulns []Vulnerability) string {
+	fixed := ""
+	for _, vuln := range ActiveVulnerabilities(vulns) {
+		lowest := ""
+		for _, candidate := range vuln.FixedIn {
+			if version.Compare(candidate, current) <= 0 {
+				continue
+			}
+			if lowest == "" || version.Compare(candidate, lowest) < 0 {
+				lowest = candidate
+			}
+		}
+		if lowest == "" {
+			return ""
+		}
+		if fixed == "" || version.Compare(lowest, fixed) > 0 {
+			fixed = lowest
+		}
+	}
+	return fixed
+}
@@ -0,0 +1,31 @@
+package pypi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFixedVersion(t *testing.T) {
+	var meta PyPIMetadata
+	data := `{"info": {"name": "foo", "version": "1.2.0"}, "vulnerabilities": [
+		{"id": "PYSEC-1", "fixed_in": ["1.2.3", "2.0.1"], "withdrawn": null},
+		{"id": "GHSA-2", "fixed_in": ["1.2.5"]},
+		{"id": "PYSEC-3", "fixed_in": [], "withdrawn": "2024-01-01T00:00:00Z"}
+	]}`
+	if err := json.Unmarshal([]byte(data), &meta); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	active := ActiveVulnerabilities(meta.Vulnerabilities)
+	if len(active) != 2 {
+		t.Fatalf("expected withdrawn advisory to be ignored, got %+v", active)
+	}
+	if fixed := FixedVersion("1.2.0", active); fixed != "1.2.5" {
+		t.Errorf("FixedVersion = %q, expected 1.2.5", fixed)
+	}
+	if fixed := FixedVersion("2.0.0", active); fixed != "" {
+		t.Errorf("FixedVersion should be empty when an advisory has no later fix, got %q", fixed)
+	}
+	if fixed := FixedVersion("1.2.0", nil); fixed != "" {
+		t.Errorf("FixedVersion without advisories = %q", fixed)
+	}
+}
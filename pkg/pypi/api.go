@@ -2,18 +2,24 @@ package pypi
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"sync"
 	"time"
 
+	"rimraf-adi.com/zephyr/pkg/cache"
 	"rimraf-adi.com/zephyr/pkg/netutil"
+	"rimraf-adi.com/zephyr/pkg/progress"
 )
 
 const (
 	PyPIBaseURL     = "https://pypi.org"
 	PyPIJSONEndpoint = "/pypi/%s/json"
+	PyPIVersionJSONEndpoint = "/pypi/%s/%s/json"
 	PyPISimpleEndpoint = "/simple/%s/"
 )
 
@@ -22,6 +28,7 @@ type PyPIMetadata struct {
 	Info     PackageInfo     `json:"info"`
 	Releases map[string][]Release `json:"releases"`
 	URLs     []Release       `json:"urls"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"`
 }
 
 // PackageInfo contains basic package information
@@ -35,6 +42,7 @@ type PackageInfo struct {
 	License      string   `json:"license"`
 	HomePage     string   `json:"home_page"`
 	ProjectURL   string   `json:"project_url"`
+	ProjectURLs  map[string]string `json:"project_urls"`
 	RequiresPython string `json:"requires_python"`
 	RequiresDist []string `json:"requires_dist"`
 	Platform     []string `json:"platform"`
@@ -46,10 +54,37 @@ type Release struct {
 	Filename    string    `json:"filename"`
 	URL         string    `json:"url"`
 	Size        int64     `json:"size"`
-	UploadTime  time.Time `json:"upload_time"`
+	UploadTime  Timestamp `json:"upload_time"`
 	Digests     Digests   `json:"digests"`
 	PythonVersion string  `json:"python_version"`
 	Packagetype string    `json:"packagetype"`
+	RequiresPython string `json:"requires_python"`
+	Yanked      bool      `json:"yanked"`
+	YankedReason string   `json:"yanked_reason"`
+}
+
+// Timestamp is a time.Time that accepts PyPI's zone-less upload_time format
+type Timestamp struct {
+	time.Time
+}
+
+// UnmarshalJSON parses both RFC 3339 and PyPI's "2006-01-02T15:04:05" layout
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if value == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid upload time %q", value)
 }
 
 // Digests contains hash information
@@ -58,10 +93,30 @@ type Digests struct {
 	SHA256 string `json:"sha256"`
 }
 
+// ErrArtifactNotFound is wrapped by DownloadRelease when the index no longer
+// serves a file its metadata lists
+var ErrArtifactNotFound = errors.New("artifact not found on the index")
+
+// APIError is returned when the JSON API answers with an error status
+type APIError struct {
+	StatusCode int
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return fmt.Sprintf("PyPI API returned status %d", e.StatusCode)
+}
+
 // PyPIClient handles communication with PyPI
 type PyPIClient struct {
 	httpClient *http.Client
 	baseURL    string
+
+	mu sync.Mutex
+	// refreshed holds project documents refetched past every cache, by
+	// normalized name, which later lookups use instead of fetching again
+	refreshed map[string]*PyPIMetadata
+	progress  progress.Handler
 }
 
 // NewPyPIClient creates a new PyPI client
@@ -81,45 +136,174 @@ type progressReader struct {
 	read     int64
 	lastMB   int64
 	filename string
+	progress progress.Handler
 }
 
 func (p *progressReader) Read(buf []byte) (int, error) {
 	n, err := p.reader.Read(buf)
 	if n > 0 {
-		p.read += int64(n)
-		mb := p.read / (1024 * 1024)
-		if mb > p.lastMB || err == io.EOF {
-			fmt.Fprintf(os.Stderr, "\rDownloading %s: %d/%d MB", p.filename, p.read/(1024*1024), p.total/(1024*1024))
-			p.lastMB = mb
-		}
+		p.advance(p.read+int64(n), err == io.EOF)
 	}
 	return n, err
 }
 
+// advance records that read bytes have arrived, reporting them to the
+// handler and printing every 1MB or when done
+func (p *progressReader) advance(read int64, done bool) {
+	p.read = read
+	p.progress.Emit(progress.Event{Kind: progress.DownloadProgress, File: p.filename, Bytes: p.read, Total: p.total})
+	mb := p.read / (1024 * 1024)
+	if mb > p.lastMB || done {
+		fmt.Fprintf(os.Stderr, "\rDownloading %s: %d/%d MB", p.filename, p.read/(1024*1024), p.total/(1024*1024))
+		p.lastMB = mb
+	}
+}
+
+// SetProgress makes DownloadRelease and DownloadReleaseTo report the bytes read as downloads proceed
+func (c *PyPIClient) SetProgress(handler progress.Handler) {
+	c.progress = handler
+}
+
 // FetchPackageMetadata retrieves package metadata from PyPI
 func (c *PyPIClient) FetchPackageMetadata(packageName string) (*PyPIMetadata, error) {
+	if err := c.checkInternal(packageName); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	metadata, ok := c.refreshed[cacheKey(packageName, "")]
+	c.mu.Unlock()
+	if ok {
+		return metadata, nil
+	}
 	endpoint := fmt.Sprintf(PyPIJSONEndpoint, packageName)
-	url := c.baseURL + endpoint
-	
-	resp, err := c.httpClient.Get(url)
+	metadata, err := c.fetchMetadata(c.baseURL+endpoint, packageName, false)
+	var apiErr *APIError
+	if err == nil || (errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound) {
+		return metadata, err
+	}
+	// Keep resolving from the simple index when the JSON API is unavailable
+	fallback, simpleErr := c.fetchSimpleMetadata(packageName)
+	if simpleErr != nil {
+		logf("simple index fallback for %s failed: %v", packageName, simpleErr)
+		return nil, err
+	}
+	logf("JSON API failed for %s (%v); using the simple index and core metadata instead", packageName, err)
+	return fallback, nil
+}
+
+// RefreshPackageMetadata drops the stored document for a package and fetches
+// it again, asking caches between zephyr and the index to revalidate. Later
+// FetchPackageMetadata calls on this client return the refreshed document.
+// It is used when the metadata disagrees with the files the index serves.
+func (c *PyPIClient) RefreshPackageMetadata(packageName string) (*PyPIMetadata, error) {
+	if err := c.checkInternal(packageName); err != nil {
+		return nil, err
+	}
+	if metadataStore != nil {
+		metadataStore.Delete(packageName)
+	}
+	endpoint := fmt.Sprintf(PyPIJSONEndpoint, packageName)
+	metadata, err := c.fetchMetadata(c.baseURL+endpoint, packageName, true)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.refreshed == nil {
+		c.refreshed = make(map[string]*PyPIMetadata)
+	}
+	c.refreshed[cacheKey(packageName, "")] = metadata
+	c.mu.Unlock()
+	return metadata, nil
+}
+
+// FetchVersionMetadata retrieves the metadata of one specific release from PyPI
+func (c *PyPIClient) FetchVersionMetadata(packageName, version string) (*PyPIMetadata, error) {
+	if err := c.checkInternal(packageName); err != nil {
+		return nil, err
+	}
+	// The name and version come from the command line; escape them so a
+	// slash or "#" cannot point the request at another path
+	endpoint := fmt.Sprintf(PyPIVersionJSONEndpoint, url.PathEscape(packageName), url.PathEscape(version))
+	return c.fetchMetadata(c.baseURL+endpoint, "", false)
+}
+
+// fetchMetadata retrieves and decodes a PyPI JSON API document. A project
+// document is kept in the metadata store under storeAs when it is set.
+// With revalidate, intermediate caches must not answer from a stored copy.
+func (c *PyPIClient) fetchMetadata(url, storeAs string, revalidate bool) (*PyPIMetadata, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch package metadata: %w", err)
+	}
+	if revalidate {
+		req.Header.Set("Cache-Control", "no-cache")
+		req.Header.Set("Pragma", "no-cache")
+	}
+	// Ask the index to answer 304 when the stored copy is still current
+	var stored []byte
+	if storeAs != "" && metadataStore != nil && !revalidate {
+		if validators, ok := metadataStore.Validators(storeAs); ok {
+			if data, _, ok := metadataStore.Get(storeAs, 0); ok {
+				stored = data
+				if validators.ETag != "" {
+					req.Header.Set("If-None-Match", validators.ETag)
+				}
+				if validators.LastModified != "" {
+					req.Header.Set("If-Modified-Since", validators.LastModified)
+				}
+			}
+		}
+	}
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch package metadata: %w", err)
 	}
 	defer resp.Body.Close()
 	
+	if resp.StatusCode == http.StatusNotModified && stored != nil {
+		metadataStore.Touch(storeAs)
+		return decodeMetadata(stored)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("PyPI API returned status %d", resp.StatusCode)
+		return nil, &APIError{StatusCode: resp.StatusCode}
 	}
 	
+	if err := netutil.DecodeBody(resp); err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 	
+	metadata, err := decodeMetadata(body)
+	if err != nil {
+		return nil, err
+	}
+	if storeAs != "" && metadataStore != nil {
+		// The store only speeds up later lookups, so failing to write it is not an error
+		metadataStore.PutValidated(storeAs, body, cache.Validators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")})
+	}
+	return metadata, nil
+}
+
+// decodeMetadata parses a PyPI JSON API document, applying the
+// --exclude-newer cutoff
+func decodeMetadata(body []byte) (*PyPIMetadata, error) {
 	var metadata PyPIMetadata
 	if err := json.Unmarshal(body, &metadata); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
 	}
+	if !excludeNewer.IsZero() {
+		releases, files := len(metadata.Releases), len(metadata.URLs)
+		metadata.excludeUploadedAfter(excludeNewer)
+		if releases > 0 && len(metadata.Releases) == 0 {
+			return nil, fmt.Errorf("no release of %s was uploaded before %s", metadata.Info.Name, excludeNewer.Format(time.RFC3339))
+		}
+		if releases == 0 && files > 0 && len(metadata.URLs) == 0 {
+			return nil, fmt.Errorf("%s %s was uploaded after %s", metadata.Info.Name, metadata.Info.Version, excludeNewer.Format(time.RFC3339))
+		}
+	}
 	
 	return &metadata, nil
 }
@@ -139,6 +323,9 @@ func (c *PyPIClient) FetchSimpleIndex(packageName string) (string, error) {
 		return "", fmt.Errorf("PyPI simple index returned status %d", resp.StatusCode)
 	}
 	
+	if err := netutil.DecodeBody(resp); err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", fmt.Errorf("failed to read response body: %w", err)
@@ -189,23 +376,70 @@ func (c *PyPIClient) GetReleasesForVersion(packageName, version string) ([]Relea
 
 // DownloadRelease downloads a specific release
 func (c *PyPIClient) DownloadRelease(release Release) (io.ReadCloser, error) {
+	if max := netutil.ArtifactLimits().MaxArtifactSize; max > 0 && release.Size > max {
+		return nil, &netutil.SizeLimitError{What: release.Filename, Limit: "max_artifact_size", Size: release.Size, Max: max}
+	}
 	fmt.Fprintf(os.Stderr, "[zephyr] Downloading %s (%.2f MB)...\n", release.Filename, float64(release.Size)/(1024*1024))
-	resp, err := c.httpClient.Get(release.URL)
+	// Downloads wait for a free slot and share the configured rate limit
+	body, err := netutil.StartDownload(func() (io.ReadCloser, error) {
+		resp, err := c.httpClient.Get(release.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download release: %w", err)
+		}
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+			resp.Body.Close()
+			return nil, fmt.Errorf("download failed with status %d: %w", resp.StatusCode, ErrArtifactNotFound)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("download failed with status %d", resp.StatusCode)
+		}
+		return resp.Body, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to download release: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf("download failed with status %d", resp.StatusCode)
+		return nil, err
 	}
 
-	pr := &progressReader{reader: resp.Body, total: release.Size, filename: release.Filename}
-	// Wrap in a ReadCloser that closes the underlying resp.Body
+	pr := &progressReader{reader: body, total: release.Size, filename: release.Filename, progress: c.progress}
+	// Wrap in a ReadCloser that closes the download
 	return struct {
 		io.Reader
 		io.Closer
-	}{Reader: pr, Closer: resp.Body}, nil
+	}{Reader: pr, Closer: body}, nil
+}
+
+// DownloadReleaseTo downloads a release to dest with netutil.DownloadFile,
+// checking it against expected when that is set. An interrupted download
+// leaves a partial file next to dest that the next call resumes.
+func (c *PyPIClient) DownloadReleaseTo(release Release, dest, expected string) error {
+	if max := netutil.ArtifactLimits().MaxArtifactSize; max > 0 && release.Size > max {
+		return &netutil.SizeLimitError{What: release.Filename, Limit: "max_artifact_size", Size: release.Size, Max: max}
+	}
+	fmt.Fprintf(os.Stderr, "[zephyr] Downloading %s (%.2f MB)...\n", release.Filename, float64(release.Size)/(1024*1024))
+	pr := &progressReader{total: release.Size, filename: release.Filename, progress: c.progress}
+	err := netutil.DownloadFile(c.httpClient, release.URL, dest, netutil.DownloadOptions{
+		SHA256: expected,
+		Resume: true,
+		Progress: func(written, total int64) {
+			pr.advance(written, written == total)
+		},
+	})
+	var httpErr *netutil.HTTPError
+	if errors.As(err, &httpErr) {
+		if httpErr.StatusCode == http.StatusNotFound || httpErr.StatusCode == http.StatusGone {
+			return fmt.Errorf("download failed with status %d: %w", httpErr.StatusCode, ErrArtifactNotFound)
+		}
+		return fmt.Errorf("download failed with status %d", httpErr.StatusCode)
+	}
+	var mismatch *netutil.DigestMismatchError
+	if errors.As(err, &mismatch) {
+		// Report it as the cache does, so callers handle both the same way
+		return &cache.HashMismatchError{Expected: mismatch.Expected, Actual: mismatch.Actual}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download release: %w", err)
+	}
+	return nil
 }
 
 // FindWheelForVersion finds the best wheel for a given version and platform
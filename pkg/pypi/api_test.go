@@ -1,6 +1,7 @@
 package pypi
 
 import (
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -102,17 +103,71 @@ func TestDownloadRelease(t *testing.T) {
 }
 
 func TestFindWheelForVersion(t *testing.T) {
-	client := &PyPIClient{}
-	releases := []Release{
-		{Filename: "foo-1.0.0.whl", Packagetype: "bdist_wheel"},
-		{Filename: "foo-1.0.0.tar.gz", Packagetype: "sdist"},
-	}
-	// Simulate GetReleasesForVersion
-	client.GetReleasesForVersion = func(pkg, ver string) ([]Release, error) {
-		return releases, nil
-	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"info": {"name": "foo", "version": "1.0.0"}, "releases": {"1.0.0": [{"filename": "foo-1.0.0.tar.gz", "packagetype": "sdist"}, {"filename": "foo-1.0.0.whl", "packagetype": "bdist_wheel"}]}, "urls": []}`))
+	}))
+	defer ts.Close()
+	client := &PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}
 	rel, err := client.FindWheelForVersion("foo", "1.0.0", "any")
 	if err != nil || rel.Filename != "foo-1.0.0.whl" {
 		t.Errorf("FindWheelForVersion failed: %v, rel=%+v", err, rel)
 	}
-} 
\ No newline at end of file
+}
+
+func TestFetchVersionMetadata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pypi/foo/1.0.0/json" {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write([]byte(`{"info": {"name": "foo", "version": "1.0.0", "requires_python": ">=3.8"}, "urls": [{"filename": "foo-1.0.0.tar.gz", "upload_time": "2024-01-01T00:00:00", "yanked": true, "yanked_reason": "broken"}]}`))
+	}))
+	defer ts.Close()
+	client := &PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}
+	meta, err := client.FetchVersionMetadata("foo", "1.0.0")
+	if err != nil {
+		t.Fatalf("FetchVersionMetadata failed: %v", err)
+	}
+	if meta.Info.RequiresPython != ">=3.8" || len(meta.URLs) != 1 {
+		t.Fatalf("Metadata mismatch: %+v", meta)
+	}
+	rel := meta.URLs[0]
+	if !rel.Yanked || rel.YankedReason != "broken" || rel.UploadTime.Year() != 2024 {
+		t.Errorf("Release mismatch: %+v", rel)
+	}
+	if _, err := client.FetchVersionMetadata("foo", "1.0.0/json#"); err == nil {
+		t.Error("Expected the version to be escaped into one path segment")
+	}
+}
+
+func TestRefreshPackageMetadata(t *testing.T) {
+	version := "1.0.0"
+	var revalidated bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		revalidated = r.Header.Get("Cache-Control") == "no-cache"
+		w.Write([]byte(`{"info": {"name": "foo", "version": "` + version + `"}, "releases": {}, "urls": []}`))
+	}))
+	defer ts.Close()
+	client := &PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}
+	if _, err := client.FetchPackageMetadata("foo"); err != nil || revalidated {
+		t.Fatalf("FetchPackageMetadata = %v, revalidated %v", err, revalidated)
+	}
+	version = "1.0.1"
+	meta, err := client.RefreshPackageMetadata("Foo")
+	if err != nil || !revalidated || meta.Info.Version != "1.0.1" {
+		t.Fatalf("RefreshPackageMetadata = %+v, %v, revalidated %v", meta, err, revalidated)
+	}
+	version = "1.0.2"
+	if meta, err := client.FetchPackageMetadata("foo"); err != nil || meta.Info.Version != "1.0.1" {
+		t.Errorf("Expected the refreshed document to be reused, got %+v, %v", meta, err)
+	}
+}
+
+func TestDownloadRelease_NotFound(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+	client := &PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}
+	if _, err := client.DownloadRelease(Release{URL: ts.URL + "/gone.whl"}); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("Expected ErrArtifactNotFound, got %v", err)
+	}
+}
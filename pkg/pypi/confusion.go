@@ -0,0 +1,118 @@
+package pypi
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"rimraf-adi.com/zephyr/pkg/netutil"
+)
+
+// DependencyConfusionError is returned for a package declared internal that
+// would not come from the internal index: the configured index is the public
+// one, or the public index serves a package of the same name that an attacker
+// could have published to shadow it
+type DependencyConfusionError struct {
+	Package string
+	// Pattern is the internal_packages entry the name matches
+	Pattern     string
+	PublicIndex string
+	// OnPublicIndex is set when the public index serves the name
+	OnPublicIndex bool
+}
+
+func (e *DependencyConfusionError) Error() string {
+	if e.OnPublicIndex {
+		return fmt.Sprintf("%s matches the internal package pattern %q but %s also serves a package of that name; refusing to resolve it, as the public package may be a dependency confusion attack. Rename the internal package or remove the public one", e.Package, e.Pattern, e.PublicIndex)
+	}
+	return fmt.Sprintf("%s matches the internal package pattern %q but index_url is the public index %s; set index_url to the internal index", e.Package, e.Pattern, e.PublicIndex)
+}
+
+var (
+	internalOnce     sync.Once
+	internalPatterns []string
+	publicIndex      = PyPIBaseURL
+	// publicChecks holds the result of looking up each internal name on the
+	// public index, by normalized name, so it is asked once per run
+	publicChecks sync.Map
+)
+
+// configureInternal applies the internal_packages and public_index_url config settings
+func configureInternal() {
+	cfg, _ := netutil.LoadConfig()
+	if cfg == nil {
+		return
+	}
+	internalPatterns = cfg.InternalPackages
+	if cfg.PublicIndexURL != "" {
+		publicIndex = strings.TrimRight(cfg.PublicIndexURL, "/")
+	}
+}
+
+// SetInternalPackages declares the name patterns of packages that must only
+// come from the configured index, and the public index they must be absent
+// from ("" for PyPI), overriding the config
+func SetInternalPackages(patterns []string, publicIndexURL string) {
+	internalOnce.Do(configureInternal)
+	internalPatterns = patterns
+	publicIndex = PyPIBaseURL
+	if publicIndexURL != "" {
+		publicIndex = strings.TrimRight(publicIndexURL, "/")
+	}
+	publicChecks = sync.Map{}
+}
+
+// internalPattern returns the internal_packages pattern name matches, or ""
+func internalPattern(name string) string {
+	internalOnce.Do(configureInternal)
+	normalized := cacheKey(name, "")
+	for _, pattern := range internalPatterns {
+		if matched, _ := path.Match(cacheKey(pattern, ""), normalized); matched {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// checkInternal refuses an internal package unless it comes from a
+// non-public index and the public index does not serve its name. Offline,
+// the public index cannot be asked, so only the configured index is checked.
+func (c *PyPIClient) checkInternal(packageName string) error {
+	pattern := internalPattern(packageName)
+	if pattern == "" {
+		return nil
+	}
+	if strings.TrimRight(c.baseURL, "/") == publicIndex {
+		return &DependencyConfusionError{Package: packageName, Pattern: pattern, PublicIndex: publicIndex}
+	}
+	if netutil.Offline() {
+		return nil
+	}
+	key := cacheKey(packageName, "")
+	if result, ok := publicChecks.Load(key); ok {
+		err, _ := result.(error)
+		return err
+	}
+	err := c.lookupPublic(packageName, pattern)
+	publicChecks.Store(key, err)
+	return err
+}
+
+// lookupPublic asks the public index whether it serves packageName. Failing
+// to get an answer is an error too, since the guard must not fail open.
+func (c *PyPIClient) lookupPublic(packageName, pattern string) error {
+	resp, err := c.httpClient.Head(publicIndex + fmt.Sprintf(PyPISimpleEndpoint, cacheKey(packageName, "")))
+	if err != nil {
+		return fmt.Errorf("could not check that internal package %s is absent from %s: %w", packageName, publicIndex, err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil
+	case http.StatusOK:
+		return &DependencyConfusionError{Package: packageName, Pattern: pattern, PublicIndex: publicIndex, OnPublicIndex: true}
+	}
+	return fmt.Errorf("could not check that internal package %s is absent from %s: status %d", packageName, publicIndex, resp.StatusCode)
+}
@@ -0,0 +1,52 @@
+package pypi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFetchPackageMetadata_InternalPackages(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"info": {"name": "acme-utils", "version": "2.0"}, "releases": {"2.0": []}}`))
+	}))
+	defer internal.Close()
+	var lookups atomic.Int32
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		if r.URL.Path == "/simple/acme-squatted/" {
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer public.Close()
+	SetInternalPackages([]string{"acme-*", "Corp_Tools"}, public.URL)
+	defer SetInternalPackages(nil, "")
+
+	client := &PyPIClient{httpClient: internal.Client(), baseURL: internal.URL}
+	if _, err := client.FetchPackageMetadata("Acme_Utils"); err != nil {
+		t.Fatalf("internal package absent from the public index should resolve: %v", err)
+	}
+	client.FetchPackageMetadata("acme-utils")
+	if lookups.Load() != 1 {
+		t.Errorf("public index asked %d times, want once", lookups.Load())
+	}
+
+	_, err := client.FetchPackageMetadata("acme-squatted")
+	var confusion *DependencyConfusionError
+	if !errors.As(err, &confusion) || !confusion.OnPublicIndex || confusion.Pattern != "acme-*" {
+		t.Fatalf("expected a DependencyConfusionError for a name the public index serves, got %v", err)
+	}
+	if _, err := client.FetchPackageMetadata("requests"); err != nil {
+		t.Errorf("packages outside the patterns should not be checked: %v", err)
+	}
+
+	onPublic := &PyPIClient{httpClient: public.Client(), baseURL: public.URL + "/"}
+	_, err = onPublic.FetchPackageMetadata("corp.tools")
+	if !errors.As(err, &confusion) || confusion.OnPublicIndex || !strings.Contains(err.Error(), "index_url") {
+		t.Errorf("expected a DependencyConfusionError for resolving from the public index, got %v", err)
+	}
+}
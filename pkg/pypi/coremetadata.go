@@ -0,0 +1,228 @@
+package pypi
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ProjectURL is a labelled Project-URL entry
+type ProjectURL struct {
+	Label string
+	URL   string
+}
+
+// CoreMetadata is the structured form of a distribution's METADATA or PKG-INFO file
+type CoreMetadata struct {
+	MetadataVersion        string
+	Name                   string
+	Version                string
+	Summary                string
+	Description            string
+	DescriptionContentType string
+	Keywords               string
+	HomePage               string
+	DownloadURL            string
+	Author                 string
+	AuthorEmail            string
+	Maintainer             string
+	MaintainerEmail        string
+	License                string
+	RequiresPython         string
+	Platforms              []string
+	Classifiers            []string
+	RequiresDist           []string
+	RequiresExternal       []string
+	ProvidesExtra          []string
+	ProvidesDist           []string
+	ObsoletesDist          []string
+	Dynamic                []string
+	ProjectURLs            []ProjectURL
+	// Headers holds every field by lower-cased name, including unknown ones
+	Headers map[string][]string
+}
+
+// ParseCoreMetadata parses core metadata in its RFC 822 email-header form.
+// Fields may be repeated and continued on indented lines; the description is
+// taken from the message body, or from a Description field whose continuation
+// lines use the "        |" prefix.
+func ParseCoreMetadata(content string) (*CoreMetadata, error) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	lines := strings.Split(content, "\n")
+	meta := &CoreMetadata{Headers: make(map[string][]string)}
+	type field struct {
+		name  string
+		lines []string
+	}
+	var fields []field
+	body := ""
+	for i, line := range lines {
+		if line == "" {
+			body = strings.Join(lines[i+1:], "\n")
+			break
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("line %d: continuation line without a field", i+1)
+			}
+			fields[len(fields)-1].lines = append(fields[len(fields)-1].lines, line)
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("line %d: expected 'Field: value'", i+1)
+		}
+		fields = append(fields, field{name: strings.TrimSpace(name), lines: []string{strings.TrimSpace(value)}})
+	}
+	for _, f := range fields {
+		key := strings.ToLower(f.name)
+		var value string
+		if key == "description" {
+			value = joinDescription(f.lines)
+		} else {
+			parts := make([]string, len(f.lines))
+			for i, l := range f.lines {
+				parts[i] = strings.TrimSpace(l)
+			}
+			value = strings.TrimSpace(strings.Join(parts, " "))
+		}
+		meta.Headers[key] = append(meta.Headers[key], value)
+		meta.set(key, value)
+	}
+	if body = strings.TrimRight(body, "\n"); body != "" {
+		meta.Description = body
+	}
+	return meta, nil
+}
+
+// set assigns a single field value to its structured counterpart
+func (m *CoreMetadata) set(key, value string) {
+	switch key {
+	case "metadata-version":
+		m.MetadataVersion = value
+	case "name":
+		m.Name = value
+	case "version":
+		m.Version = value
+	case "summary":
+		m.Summary = value
+	case "description":
+		m.Description = value
+	case "description-content-type":
+		m.DescriptionContentType = value
+	case "keywords":
+		m.Keywords = value
+	case "home-page":
+		m.HomePage = value
+	case "download-url":
+		m.DownloadURL = value
+	case "author":
+		m.Author = value
+	case "author-email":
+		m.AuthorEmail = value
+	case "maintainer":
+		m.Maintainer = value
+	case "maintainer-email":
+		m.MaintainerEmail = value
+	case "license":
+		m.License = value
+	case "requires-python":
+		m.RequiresPython = value
+	case "platform":
+		m.Platforms = append(m.Platforms, value)
+	case "classifier":
+		m.Classifiers = append(m.Classifiers, value)
+	case "requires-dist":
+		m.RequiresDist = append(m.RequiresDist, value)
+	case "requires-external":
+		m.RequiresExternal = append(m.RequiresExternal, value)
+	case "provides-extra":
+		m.ProvidesExtra = append(m.ProvidesExtra, value)
+	case "provides-dist":
+		m.ProvidesDist = append(m.ProvidesDist, value)
+	case "obsoletes-dist":
+		m.ObsoletesDist = append(m.ObsoletesDist, value)
+	case "dynamic":
+		m.Dynamic = append(m.Dynamic, value)
+	case "project-url":
+		label, url, ok := strings.Cut(value, ",")
+		if !ok {
+			label, url = "", value
+		}
+		m.ProjectURLs = append(m.ProjectURLs, ProjectURL{Label: strings.TrimSpace(label), URL: strings.TrimSpace(url)})
+	}
+}
+
+// joinDescription rebuilds a multi-line Description field, dropping the
+// indentation and "|" markers setuptools uses to keep blank lines
+func joinDescription(lines []string) string {
+	out := []string{lines[0]}
+	for _, l := range lines[1:] {
+		trimmed := strings.TrimLeft(l, " \t")
+		if strings.HasPrefix(trimmed, "|") {
+			trimmed = trimmed[1:]
+		} else if len(l) >= 8 && strings.TrimSpace(l[:8]) == "" {
+			// Plain 8-space indentation keeps any deeper indentation intact
+			trimmed = l[8:]
+		}
+		out = append(out, trimmed)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// CoreMetadata returns the index's view of a release in core metadata form
+func (info PackageInfo) CoreMetadata() *CoreMetadata {
+	meta := &CoreMetadata{
+		Name:           info.Name,
+		Version:        info.Version,
+		Summary:        info.Summary,
+		Description:    info.Description,
+		Author:         info.Author,
+		AuthorEmail:    info.AuthorEmail,
+		License:        info.License,
+		HomePage:       info.HomePage,
+		RequiresPython: info.RequiresPython,
+		RequiresDist:   info.RequiresDist,
+		Platforms:      info.Platform,
+		Classifiers:    info.Classifier,
+	}
+	for label, url := range info.ProjectURLs {
+		meta.ProjectURLs = append(meta.ProjectURLs, ProjectURL{Label: label, URL: url})
+	}
+	// JSON maps are unordered, so list the URLs by label
+	sort.Slice(meta.ProjectURLs, func(i, j int) bool {
+		return meta.ProjectURLs[i].Label < meta.ProjectURLs[j].Label
+	})
+	return meta
+}
+
+// ReadWheelCoreMetadata parses the .dist-info/METADATA file inside a wheel
+func ReadWheelCoreMetadata(wheelPath string) (*CoreMetadata, error) {
+	reader, err := zip.OpenReader(wheelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wheel %s: %w", wheelPath, err)
+	}
+	defer reader.Close()
+	for _, file := range reader.File {
+		if !strings.HasSuffix(file.Name, ".dist-info/METADATA") || strings.Count(file.Name, "/") != 1 {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		meta, err := ParseCoreMetadata(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file.Name, err)
+		}
+		return meta, nil
+	}
+	return nil, fmt.Errorf("%s has no .dist-info/METADATA", wheelPath)
+}
@@ -0,0 +1,89 @@
+package pypi
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const sampleMetadata = "Metadata-Version: 2.1\r\n" +
+	"Name: demo\n" +
+	"Version: 1.2.0\n" +
+	"Summary: A demo package\n" +
+	"Classifier: Programming Language :: Python :: 3\n" +
+	"Classifier: License :: OSI Approved :: MIT License\n" +
+	"Project-URL: Homepage, https://example.com\n" +
+	"Project-URL: Issues, https://example.com/issues\n" +
+	"Keywords: demo,\n" +
+	"  testing\n" +
+	"Provides-Extra: cli\n" +
+	"Requires-Dist: click>=8; extra == \"cli\"\n" +
+	"Provides-Extra: docs\n" +
+	"X-Custom: kept\n" +
+	"\n" +
+	"# Demo\n" +
+	"\n" +
+	"Long description.\n"
+
+func TestParseCoreMetadata(t *testing.T) {
+	meta, err := ParseCoreMetadata(sampleMetadata)
+	if err != nil {
+		t.Fatalf("ParseCoreMetadata failed: %v", err)
+	}
+	if meta.MetadataVersion != "2.1" || meta.Name != "demo" || meta.Version != "1.2.0" {
+		t.Errorf("unexpected identity: %+v", meta)
+	}
+	if len(meta.Classifiers) != 2 || !reflect.DeepEqual(meta.ProvidesExtra, []string{"cli", "docs"}) {
+		t.Errorf("repeated fields lost: %v %v", meta.Classifiers, meta.ProvidesExtra)
+	}
+	if !reflect.DeepEqual(meta.ProjectURLs, []ProjectURL{{"Homepage", "https://example.com"}, {"Issues", "https://example.com/issues"}}) {
+		t.Errorf("unexpected project URLs: %+v", meta.ProjectURLs)
+	}
+	if meta.Keywords != "demo, testing" {
+		t.Errorf("continuation not unfolded: %q", meta.Keywords)
+	}
+	if meta.Description != "# Demo\n\nLong description." {
+		t.Errorf("unexpected description: %q", meta.Description)
+	}
+	if meta.Headers["x-custom"][0] != "kept" {
+		t.Errorf("unknown header not kept: %v", meta.Headers)
+	}
+}
+
+func TestParseCoreMetadata_DescriptionField(t *testing.T) {
+	meta, err := ParseCoreMetadata("Name: demo\nDescription: First line\n        |\n        |    indented\n        last\n")
+	if err != nil {
+		t.Fatalf("ParseCoreMetadata failed: %v", err)
+	}
+	if meta.Description != "First line\n\n    indented\nlast" {
+		t.Errorf("unexpected description: %q", meta.Description)
+	}
+}
+
+func TestParseCoreMetadata_Malformed(t *testing.T) {
+	if _, err := ParseCoreMetadata("  leading continuation\n"); err == nil {
+		t.Error("expected error for continuation without field")
+	}
+	if _, err := ParseCoreMetadata("Name: demo\nnot a header\n"); err == nil {
+		t.Error("expected error for line without colon")
+	}
+}
+
+func TestReadWheelCoreMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "demo-1.2.0-py3-none-any.whl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	m, _ := w.Create("demo-1.2.0.dist-info/METADATA")
+	m.Write([]byte(sampleMetadata))
+	w.Close()
+	f.Close()
+	meta, err := ReadWheelCoreMetadata(path)
+	if err != nil || meta.Name != "demo" {
+		t.Fatalf("ReadWheelCoreMetadata = %+v, %v", meta, err)
+	}
+}
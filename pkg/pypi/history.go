@@ -0,0 +1,122 @@
+package pypi
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// VersionSummary describes one released version of a package
+type VersionSummary struct {
+	Version    string
+	UploadTime time.Time
+	Prerelease bool
+	Yanked     bool
+}
+
+// VersionHistory summarizes every release in the metadata, newest version first.
+// The upload time of a version is the earliest upload among its files.
+func VersionHistory(metadata *PyPIMetadata) []VersionSummary {
+	history := make([]VersionSummary, 0, len(metadata.Releases))
+	for v, files := range metadata.Releases {
+		summary := VersionSummary{
+			Version:    v,
+			Prerelease: version.IsPrerelease(v),
+			Yanked:     len(files) > 0,
+		}
+		for _, file := range files {
+			if !file.UploadTime.IsZero() && (summary.UploadTime.IsZero() || file.UploadTime.Before(summary.UploadTime)) {
+				summary.UploadTime = file.UploadTime.Time
+			}
+			if !file.Yanked {
+				summary.Yanked = false
+			}
+		}
+		history = append(history, summary)
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return version.Compare(history[i].Version, history[j].Version) > 0
+	})
+	return history
+}
+
+// LatestVersions returns the newest stable and newest pre-release versions in a
+// history, ignoring yanked releases. A pre-release is only reported when it is
+// newer than the latest stable release.
+func LatestVersions(history []VersionSummary) (stable, prerelease string) {
+	for _, summary := range history {
+		if summary.Yanked {
+			continue
+		}
+		if summary.Prerelease {
+			if prerelease == "" && stable == "" {
+				prerelease = summary.Version
+			}
+			continue
+		}
+		if stable == "" {
+			stable = summary.Version
+		}
+	}
+	return stable, prerelease
+}
+
+// ProjectLink is a labelled project URL
+type ProjectLink struct {
+	Kind  string
+	Label string
+	URL   string
+}
+
+// projectLinkKinds maps a link kind to label keywords, in display order
+var projectLinkKinds = []struct {
+	kind     string
+	keywords []string
+}{
+	{"changelog", []string{"changelog", "change log", "changes", "release notes", "history", "news"}},
+	{"documentation", []string{"documentation", "docs", "doc"}},
+	{"repository", []string{"repository", "source", "code", "github", "gitlab"}},
+	{"issues", []string{"issue", "bug", "tracker"}},
+	{"homepage", []string{"homepage", "home"}},
+}
+
+// ProjectLinks classifies project_urls into changelog, documentation, repository,
+// issues, homepage and other links, ordered by kind and then label
+func ProjectLinks(info PackageInfo) []ProjectLink {
+	var links []ProjectLink
+	for label, url := range info.ProjectURLs {
+		links = append(links, ProjectLink{Kind: classifyProjectLink(label), Label: label, URL: url})
+	}
+	if info.HomePage != "" && len(links) == 0 {
+		links = append(links, ProjectLink{Kind: "homepage", Label: "Homepage", URL: info.HomePage})
+	}
+	rank := func(kind string) int {
+		for i, k := range projectLinkKinds {
+			if k.kind == kind {
+				return i
+			}
+		}
+		return len(projectLinkKinds)
+	}
+	sort.SliceStable(links, func(i, j int) bool {
+		if ri, rj := rank(links[i].Kind), rank(links[j].Kind); ri != rj {
+			return ri < rj
+		}
+		return links[i].Label < links[j].Label
+	})
+	return links
+}
+
+func classifyProjectLink(label string) string {
+	lower := strings.ToLower(label)
+	for _, k := range projectLinkKinds {
+		for _, keyword := range k.keywords {
+			if strings.Contains(lower, keyword) {
+				return k.kind
+			}
+		}
+	}
+	return "other"
+}
@@ -0,0 +1,53 @@
+package pypi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestVersionHistoryAndLatest(t *testing.T) {
+	var meta PyPIMetadata
+	data := `{"info": {"name": "foo"}, "releases": {
+		"1.0.0": [{"upload_time": "2023-01-01T00:00:00"}, {"upload_time": "2022-12-31T10:00:00"}],
+		"1.10.0": [{"upload_time": "2024-01-01T00:00:00"}],
+		"1.2.0": [{"upload_time": "2023-06-01T00:00:00"}],
+		"2.0.0b1": [{"upload_time": "2024-03-01T00:00:00"}],
+		"2.0.0": [{"upload_time": "2024-04-01T00:00:00", "yanked": true}]
+	}}`
+	if err := json.Unmarshal([]byte(data), &meta); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	history := VersionHistory(&meta)
+	if len(history) != 5 || history[0].Version != "2.0.0" || history[4].Version != "1.0.0" {
+		t.Fatalf("History order mismatch: %+v", history)
+	}
+	if history[4].UploadTime.Day() != 31 {
+		t.Errorf("Expected earliest upload time, got %v", history[4].UploadTime)
+	}
+	if !history[0].Yanked || !history[1].Prerelease {
+		t.Errorf("Flags mismatch: %+v", history[:2])
+	}
+	stable, pre := LatestVersions(history)
+	if stable != "1.10.0" || pre != "2.0.0b1" {
+		t.Errorf("LatestVersions = %s, %s", stable, pre)
+	}
+}
+
+func TestProjectLinks(t *testing.T) {
+	info := PackageInfo{ProjectURLs: map[string]string{
+		"Source":        "https://github.com/psf/requests",
+		"Documentation": "https://requests.readthedocs.io",
+		"Changelog":     "https://github.com/psf/requests/blob/main/HISTORY.md",
+		"Funding":       "https://example.com/fund",
+	}}
+	links := ProjectLinks(info)
+	kinds := []string{"changelog", "documentation", "repository", "other"}
+	if len(links) != len(kinds) {
+		t.Fatalf("Expected %d links, got %+v", len(kinds), links)
+	}
+	for i, kind := range kinds {
+		if links[i].Kind != kind {
+			t.Errorf("links[%d].Kind = %s, want %s", i, links[i].Kind, kind)
+		}
+	}
+}
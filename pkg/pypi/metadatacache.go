@@ -0,0 +1,103 @@
+package pypi
+
+import (
+	"sync"
+	"time"
+
+	"rimraf-adi.com/zephyr/pkg/cache"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// DefaultMetadataTTL is how long a long-running process reuses fetched metadata
+const DefaultMetadataTTL = 10 * time.Minute
+
+// MetadataCache keeps package metadata in memory so a long-running process,
+// such as an editor integration, answers repeated queries without refetching
+type MetadataCache struct {
+	client *PyPIClient
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedMetadata
+}
+
+type cachedMetadata struct {
+	metadata *PyPIMetadata
+	fetched  time.Time
+}
+
+// NewMetadataCache returns a cache that fetches through client and keeps
+// entries for ttl
+func NewMetadataCache(client *PyPIClient, ttl time.Duration) *MetadataCache {
+	return &MetadataCache{client: client, ttl: ttl, now: time.Now, entries: make(map[string]cachedMetadata)}
+}
+
+// cacheKey normalizes a project name, and the version when given
+func cacheKey(name, version string) string {
+	key := pkgname.Normalize(name)
+	if version != "" {
+		key += "==" + version
+	}
+	return key
+}
+
+// Package returns the metadata of every release of a package
+func (c *MetadataCache) Package(name string) (*PyPIMetadata, error) {
+	return c.get(cacheKey(name, ""), func() (*PyPIMetadata, error) {
+		return c.client.FetchPackageMetadata(name)
+	})
+}
+
+// Version returns the metadata of one release of a package
+func (c *MetadataCache) Version(name, version string) (*PyPIMetadata, error) {
+	return c.get(cacheKey(name, version), func() (*PyPIMetadata, error) {
+		return c.client.FetchVersionMetadata(name, version)
+	})
+}
+
+// get returns the cached entry for key, fetching it when missing or stale.
+// Failed fetches are not cached.
+func (c *MetadataCache) get(key string, fetch func() (*PyPIMetadata, error)) (*PyPIMetadata, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.now().Sub(entry.fetched) < c.ttl {
+		return entry.metadata, nil
+	}
+	metadata, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[key] = cachedMetadata{metadata: metadata, fetched: c.now()}
+	c.mu.Unlock()
+	return metadata, nil
+}
+
+// metadataStore keeps every project document clients fetch; nil disables it
+var metadataStore *cache.MetadataStore
+
+// SetMetadataStore makes all clients save the project documents they fetch
+// to store, for later lookups that must not wait on the network
+func SetMetadataStore(store *cache.MetadataStore) {
+	metadataStore = store
+}
+
+// StoredPackageMetadata returns the document last fetched for a package and
+// when it was fetched, when the metadata store has one younger than maxAge.
+// A maxAge of zero accepts any age.
+func StoredPackageMetadata(name string, maxAge time.Duration) (*PyPIMetadata, time.Time, bool) {
+	if metadataStore == nil {
+		return nil, time.Time{}, false
+	}
+	data, stored, ok := metadataStore.Get(name, maxAge)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	metadata, err := decodeMetadata(data)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return metadata, stored, true
+}
@@ -0,0 +1,105 @@
+package pypi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rimraf-adi.com/zephyr/pkg/cache"
+)
+
+func TestMetadataCache(t *testing.T) {
+	fetches := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if r.URL.Path == "/pypi/missing/json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"info": {"name": "Foo.Bar", "version": "2.0.0"}, "releases": {}, "urls": []}`))
+	}))
+	defer ts.Close()
+	now := time.Now()
+	cache := NewMetadataCache(&PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	for _, name := range []string{"Foo.Bar", "foo-bar", "foo_bar"} {
+		if meta, err := cache.Package(name); err != nil || meta.Info.Version != "2.0.0" {
+			t.Fatalf("Package(%s) = %v, %v", name, meta, err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("equivalent names fetched %d times, want once", fetches)
+	}
+	cache.Version("foo-bar", "2.0.0")
+	if fetches != 2 {
+		t.Errorf("version metadata should be cached separately, fetches = %d", fetches)
+	}
+
+	now = now.Add(2 * time.Minute)
+	cache.Package("foo-bar")
+	if fetches != 3 {
+		t.Errorf("stale entry not refetched, fetches = %d", fetches)
+	}
+
+	cache.Package("missing")
+	if _, err := cache.Package("missing"); err == nil || fetches != 5 {
+		t.Errorf("failures must not be cached: err = %v, fetches = %d", err, fetches)
+	}
+}
+
+func TestMetadataStore(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"info": {"name": "Foo.Bar", "version": "2.0.0"}, "releases": {"2.0.0": []}, "urls": []}`))
+	}))
+	defer ts.Close()
+	client := &PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}
+
+	if _, _, ok := StoredPackageMetadata("foo-bar", 0); ok {
+		t.Fatal("found stored metadata without a store")
+	}
+	SetMetadataStore(cache.NewMetadataStore(t.TempDir()))
+	defer SetMetadataStore(nil)
+	if _, err := client.FetchVersionMetadata("Foo.Bar", "2.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := StoredPackageMetadata("foo-bar", 0); ok {
+		t.Error("version documents must not be stored as the project document")
+	}
+	if _, err := client.FetchPackageMetadata("Foo.Bar"); err != nil {
+		t.Fatal(err)
+	}
+	meta, stored, ok := StoredPackageMetadata("foo_bar", time.Hour)
+	if !ok || meta.Info.Version != "2.0.0" || len(meta.Releases) != 1 || stored.IsZero() {
+		t.Errorf("StoredPackageMetadata = %+v, %v, %v", meta, stored, ok)
+	}
+}
+
+func TestFetchPackageMetadata_ConditionalGET(t *testing.T) {
+	full, notModified := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"info": {"name": "foo", "version": "1.0.0"}, "releases": {}, "urls": []}`))
+	}))
+	defer ts.Close()
+	SetMetadataStore(cache.NewMetadataStore(t.TempDir()))
+	defer SetMetadataStore(nil)
+
+	client := &PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}
+	for i := 0; i < 2; i++ {
+		meta, err := client.FetchPackageMetadata("foo")
+		if err != nil || meta.Info.Version != "1.0.0" {
+			t.Fatalf("FetchPackageMetadata = %+v, %v", meta, err)
+		}
+	}
+	if full != 1 || notModified != 1 {
+		t.Errorf("full responses = %d, 304s = %d; want the second fetch revalidated", full, notModified)
+	}
+}
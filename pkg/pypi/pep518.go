@@ -7,17 +7,19 @@ import (
 	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"rimraf-adi.com/zephyr/pkg/fsutil"
 )
 
 // PEP518BuildSystem represents the build-system section in pyproject.toml
 type PEP518BuildSystem struct {
-	Requires []string `yaml:"requires"`
-	Backend  string   `yaml:"build-backend"`
+	Requires []string
+	Backend  string
 }
 
 // PEP518Config represents the pyproject.toml configuration
 type PEP518Config struct {
-	BuildSystem PEP518BuildSystem `yaml:"build-system"`
+	BuildSystem PEP518BuildSystem
 }
 
 // ParsePEP518Config parses pyproject.toml for PEP 518 build dependencies
@@ -30,13 +32,119 @@ func ParsePEP518Config(projectDir string) (*PEP518Config, error) {
 	}
 	
 	var config PEP518Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse pyproject.toml: %w", err)
+	values := tomlTableValues(string(data), "build-system")
+	// The values used here are strings and arrays of strings, which read the
+	// same as YAML flow scalars and sequences
+	if value, ok := values["requires"]; ok {
+		if err := yaml.Unmarshal([]byte(value), &config.BuildSystem.Requires); err != nil {
+			return nil, fmt.Errorf("failed to parse pyproject.toml: build-system.requires: %w", err)
+		}
+	}
+	if value, ok := values["build-backend"]; ok {
+		if err := yaml.Unmarshal([]byte(value), &config.BuildSystem.Backend); err != nil {
+			return nil, fmt.Errorf("failed to parse pyproject.toml: build-system.build-backend: %w", err)
+		}
 	}
 	
 	return &config, nil
 }
 
+// tomlTableValues returns the raw values of the keys of table in a TOML
+// document, with multi-line arrays joined onto one line. Multi-line strings
+// are skipped.
+func tomlTableValues(content, table string) map[string]string {
+	values := make(map[string]string)
+	current, multiline := "", ""
+	key, value, depth := "", "", 0
+	for _, line := range strings.Split(content, "\n") {
+		if multiline != "" {
+			if strings.Contains(line, multiline) {
+				multiline = ""
+			}
+			continue
+		}
+		if depth > 0 {
+			value += " " + strings.TrimSpace(stripTOMLComment(line))
+			depth += bracketDepth(line)
+			if depth <= 0 && current == table {
+				values[key] = value
+			}
+			continue
+		}
+		trimmed := strings.TrimSpace(stripTOMLComment(line))
+		switch {
+		case trimmed == "":
+		case strings.HasPrefix(trimmed, "["):
+			current = strings.TrimSpace(strings.Trim(trimmed, "[]"))
+		default:
+			idx := strings.Index(trimmed, "=")
+			if idx <= 0 {
+				continue
+			}
+			key = strings.Trim(strings.TrimSpace(trimmed[:idx]), `"'`)
+			value = strings.TrimSpace(trimmed[idx+1:])
+			for _, quote := range []string{`"""`, `'''`} {
+				if strings.HasPrefix(value, quote) && !strings.Contains(value[len(quote):], quote) {
+					multiline = quote
+				}
+			}
+			if multiline != "" {
+				continue
+			}
+			depth = bracketDepth(value)
+			if depth <= 0 && current == table {
+				values[key] = value
+			}
+		}
+	}
+	return values
+}
+
+// stripTOMLComment drops a # comment that is not inside a string
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// bracketDepth returns how many more [ than ] line has outside strings
+func bracketDepth(line string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return depth
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth
+}
+
 // GetBuildDependencies gets the build dependencies for a project
 func GetBuildDependencies(projectDir string) ([]string, error) {
 	config, err := ParsePEP518Config(projectDir)
@@ -87,13 +195,14 @@ func DefaultBuildSystem() *PEP518Config {
 func CreateDefaultPyProject(projectDir string) error {
 	config := DefaultBuildSystem()
 	
-	data, err := yaml.Marshal(config)
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+	requires := make([]string, len(config.BuildSystem.Requires))
+	for i, requirement := range config.BuildSystem.Requires {
+		requires[i] = fmt.Sprintf("%q", requirement)
 	}
+	data := []byte(fmt.Sprintf("[build-system]\nrequires = [%s]\nbuild-backend = %q\n", strings.Join(requires, ", "), config.BuildSystem.Backend))
 	
 	pyprojectPath := filepath.Join(projectDir, "pyproject.toml")
-	if err := os.WriteFile(pyprojectPath, data, 0644); err != nil {
+	if err := fsutil.WriteFileAtomic(pyprojectPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write pyproject.toml: %w", err)
 	}
 	
@@ -69,4 +69,45 @@ func TestDefaultBuildSystem(t *testing.T) {
 	if cfg.BuildSystem.Backend == "" || len(cfg.BuildSystem.Requires) == 0 {
 		t.Error("DefaultBuildSystem should set backend and requires")
 	}
-} 
\ No newline at end of file
+} 
+func TestParsePEP518Config_MultiLineAndOtherTables(t *testing.T) {
+	dir := t.TempDir()
+	pyproject := `[project]
+name = "foo"
+description = """
+requires = ["not", "this"]
+"""
+
+[build-system]
+requires = [
+    "setuptools>=61.0", # for pyproject support
+    'wheel',
+]
+build-backend = "setuptools.build_meta"
+
+[tool.other]
+requires = ["nor-this"]
+`
+	os.WriteFile(filepath.Join(dir, "pyproject.toml"), []byte(pyproject), 0644)
+	cfg, err := ParsePEP518Config(dir)
+	if err != nil {
+		t.Fatalf("ParsePEP518Config failed: %v", err)
+	}
+	if cfg.BuildSystem.Backend != "setuptools.build_meta" || len(cfg.BuildSystem.Requires) != 2 || cfg.BuildSystem.Requires[1] != "wheel" {
+		t.Errorf("Parsed config mismatch: %+v", cfg.BuildSystem)
+	}
+}
+
+func TestCreateDefaultPyProject(t *testing.T) {
+	dir := t.TempDir()
+	if err := CreateDefaultPyProject(dir); err != nil {
+		t.Fatalf("CreateDefaultPyProject failed: %v", err)
+	}
+	cfg, err := ParsePEP518Config(dir)
+	if err != nil {
+		t.Fatalf("ParsePEP518Config failed: %v", err)
+	}
+	if err := ValidateBuildSystem(cfg); err != nil {
+		t.Errorf("Default pyproject.toml does not read back: %v", err)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 
 	"gopkg.in/yaml.v3"
+
+	"rimraf-adi.com/zephyr/pkg/fsutil"
 )
 
 // PEP621Project represents the project metadata section in pyproject.toml
@@ -160,7 +162,7 @@ func WritePEP621Config(projectDir string, config *PEP621Config) error {
 	}
 	
 	pyprojectPath := filepath.Join(projectDir, "pyproject.toml")
-	if err := os.WriteFile(pyprojectPath, data, 0644); err != nil {
+	if err := fsutil.WriteFileAtomic(pyprojectPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write pyproject.toml: %w", err)
 	}
 	
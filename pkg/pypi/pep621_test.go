@@ -1,8 +1,6 @@
 package pypi
 
 import (
-	"os"
-	"path/filepath"
 	"testing"
 )
 
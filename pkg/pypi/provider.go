@@ -0,0 +1,128 @@
+package pypi
+
+import (
+	"fmt"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/markers"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+	"rimraf-adi.com/zephyr/pkg/solver"
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// Provider is a solver.Provider reading versions and dependencies from the
+// index, as seen from one target: releases whose files are all yanked or
+// need another Python are skipped, and requirements whose markers do not
+// hold on the target are left out
+type Provider struct {
+	client *PyPIClient
+	target markers.Target
+}
+
+// NewProvider returns a provider asking client about packages for target
+func NewProvider(client *PyPIClient, target markers.Target) *Provider {
+	return &Provider{client: client, target: target}
+}
+
+// Versions implements solver.Provider. Prereleases are listed too; the
+// solver only picks one when a specifier names a prerelease or no final
+// release fits.
+func (p *Provider) Versions(name string) ([]string, error) {
+	metadata, err := p.client.FetchPackageMetadata(name)
+	if err != nil {
+		return nil, err
+	}
+	python := p.target.Environment()["python_full_version"]
+	var versions []string
+	for v, files := range metadata.Releases {
+		if installable(files, python) {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+// installable reports whether any file of a release is not yanked and
+// supports python
+func installable(files []Release, python string) bool {
+	for _, file := range files {
+		if file.Yanked {
+			continue
+		}
+		if file.RequiresPython == "" {
+			return true
+		}
+		// An unparseable requires-python is not a reason to hide the release
+		if ok, err := version.Satisfies(python, file.RequiresPython); ok || err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Dependencies implements solver.Provider. For an extra pseudo-package such
+// as "requests[socks]" only the requirements the extra enables are returned.
+func (p *Provider) Dependencies(name, ver string) (map[string]solver.VersionConstraint, error) {
+	base, extra, _ := solver.SplitExtraPackage(name)
+	metadata, err := p.client.FetchVersionMetadata(base, ver)
+	if err != nil {
+		return nil, err
+	}
+	env := p.target.Environment()
+	dependencies := make(map[string]solver.VersionConstraint)
+	for _, requirement := range metadata.Info.RequiresDist {
+		spec, marker := markers.SplitRequirement(requirement)
+		env["extra"] = extra
+		applies, err := markers.Evaluate(marker, env)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s requirement '%s': %w", base, ver, requirement, err)
+		}
+		if applies && extra != "" {
+			// Requirements the base package has anyway belong to it, not the extra
+			env["extra"] = ""
+			always, err := markers.Evaluate(marker, env)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s requirement '%s': %w", base, ver, requirement, err)
+			}
+			applies = !always
+		}
+		if !applies {
+			continue
+		}
+		dependency, specifier := splitSpecifier(spec)
+		dependencies[normalizeRequirementName(dependency)] = solver.ParseConstraint(specifier)
+	}
+	return dependencies, nil
+}
+
+// normalizeRequirementName normalizes a requirement name and its extras, so
+// that "Charset_Normalizer" and "charset-normalizer" are one package
+func normalizeRequirementName(name string) string {
+	open := strings.Index(name, "[")
+	if open < 0 {
+		return pkgname.Normalize(name)
+	}
+	extras := strings.Split(strings.TrimSuffix(name[open+1:], "]"), ",")
+	for i, extra := range extras {
+		extras[i] = pkgname.Normalize(strings.TrimSpace(extra))
+	}
+	return pkgname.Normalize(name[:open]) + "[" + strings.Join(extras, ",") + "]"
+}
+
+// splitSpecifier splits a requirement without markers into its name, with
+// any extras as "name[a,b]", and its version specifier
+func splitSpecifier(spec string) (string, string) {
+	name := markers.RequirementName(spec)
+	rest := strings.TrimSpace(spec[len(name):])
+	if strings.HasPrefix(rest, "[") {
+		if end := strings.Index(rest, "]"); end > 0 {
+			name += strings.ReplaceAll(rest[:end+1], " ", "")
+			rest = strings.TrimSpace(rest[end+1:])
+		}
+	}
+	if strings.HasPrefix(rest, "@") {
+		// A direct reference allows whatever version the URL holds
+		rest = ""
+	}
+	return name, rest
+}
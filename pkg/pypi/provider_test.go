@@ -0,0 +1,85 @@
+package pypi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/markers"
+	"rimraf-adi.com/zephyr/pkg/solver"
+)
+
+func providerServer(t *testing.T) *Provider {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pypi/provtest/json":
+			w.Write([]byte(`{"info": {"name": "provtest", "version": "2.1.0"}, "releases": {
+				"1.0.0": [{"filename": "provtest-1.0.0.tar.gz"}],
+				"2.0.0": [{"filename": "provtest-2.0.0.tar.gz", "yanked": true}],
+				"2.1.0": [{"filename": "provtest-2.1.0.tar.gz"}],
+				"3.0.0": [{"filename": "provtest-3.0.0.tar.gz", "requires_python": ">=3.13"}],
+				"3.1.0b1": [{"filename": "provtest-3.1.0b1.tar.gz"}],
+				"4.0.0": []}}`))
+		case "/pypi/provtest/2.1.0/json":
+			w.Write([]byte(`{"info": {"name": "provtest", "version": "2.1.0", "requires_dist": [
+				"idna (>=2.5)",
+				"Charset_Normalizer<4",
+				"urllib3[socks]<3,>=1.21.1",
+				"colorama; sys_platform == \"win32\"",
+				"pysocks>=1.5.6; extra == \"socks\"",
+				"chardet<6; python_version < \"3.12\" or extra == \"socks\""]}}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	target, err := markers.ParseTarget("linux-x86_64-3.12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewProvider(&PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}, target)
+}
+
+func TestProviderVersions(t *testing.T) {
+	versions, err := providerServer(t).Versions("provtest")
+	if err != nil {
+		t.Fatalf("Versions failed: %v", err)
+	}
+	got := map[string]bool{}
+	for _, v := range versions {
+		got[v] = true
+	}
+	want := map[string]bool{"1.0.0": true, "2.1.0": true, "3.1.0b1": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected only installable releases %v, got %v", want, versions)
+	}
+}
+
+func TestProviderDependencies(t *testing.T) {
+	provider := providerServer(t)
+	deps, err := provider.Dependencies("provtest", "2.1.0")
+	if err != nil {
+		t.Fatalf("Dependencies failed: %v", err)
+	}
+	want := map[string]solver.VersionConstraint{
+		"idna":               {Min: "2.5"},
+		"charset-normalizer": {Max: "4"},
+		"urllib3[socks]":     {Min: "1.21.1", Max: "3"},
+	}
+	if !reflect.DeepEqual(deps, want) {
+		t.Errorf("Expected %v, got %v", want, deps)
+	}
+
+	deps, err = provider.Dependencies("provtest[socks]", "2.1.0")
+	if err != nil {
+		t.Fatalf("Dependencies of extra failed: %v", err)
+	}
+	want = map[string]solver.VersionConstraint{
+		"pysocks": {Min: "1.5.6"},
+		"chardet": {Max: "6"},
+	}
+	if !reflect.DeepEqual(deps, want) {
+		t.Errorf("Expected only the extra's requirements %v, got %v", want, deps)
+	}
+}
@@ -0,0 +1,317 @@
+package pypi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"rimraf-adi.com/zephyr/pkg/netutil"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// simpleAccept asks for the PEP 691 JSON form of a simple index page, and
+// takes the HTML form from indexes that do not serve it
+const simpleAccept = "application/vnd.pypi.simple.v1+json, application/vnd.pypi.simple.v1+html;q=0.2, text/html;q=0.01"
+
+// Logger receives diagnostic messages, such as bugreport.Log
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// debugLog receives notes about degraded metadata sources; nil disables them
+var debugLog Logger
+
+// SetDebugLog makes all clients note in log when they fall back to the simple index
+func SetDebugLog(log Logger) {
+	debugLog = log
+}
+
+// logf writes to the debug log when one is set
+func logf(format string, args ...interface{}) {
+	if debugLog != nil {
+		debugLog.Printf(format, args...)
+	}
+}
+
+// simpleFile is one file listed on a simple index project page
+type simpleFile struct {
+	Release
+	// metadata reports whether the index serves the file's core metadata
+	// at its URL plus ".metadata" (PEP 658)
+	metadata bool
+}
+
+// simpleProject is the PEP 691 JSON form of a project page
+type simpleProject struct {
+	Name  string `json:"name"`
+	Files []struct {
+		Filename       string            `json:"filename"`
+		URL            string            `json:"url"`
+		Hashes         map[string]string `json:"hashes"`
+		RequiresPython string            `json:"requires-python"`
+		Size           int64             `json:"size"`
+		UploadTime     Timestamp         `json:"upload-time"`
+		Yanked         json.RawMessage   `json:"yanked"`
+		CoreMetadata   json.RawMessage   `json:"core-metadata"`
+		DistInfo       json.RawMessage   `json:"dist-info-metadata"`
+	} `json:"files"`
+}
+
+// fetchSimpleMetadata builds a project document from the simple index and the
+// core metadata it serves for the latest release, for when the JSON API fails.
+// Description, URLs and other fields only the JSON API has are left empty.
+func (c *PyPIClient) fetchSimpleMetadata(packageName string) (*PyPIMetadata, error) {
+	// Project pages live under the PEP 503 name; escape it like the JSON API paths
+	pageURL := c.baseURL + fmt.Sprintf(PyPISimpleEndpoint, url.PathEscape(pkgname.Normalize(packageName)))
+	req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch simple index: %w", err)
+	}
+	req.Header.Set("Accept", simpleAccept)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch simple index: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("PyPI simple index returned status %d", resp.StatusCode)
+	}
+	if err := netutil.DecodeBody(resp); err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	base, err := url.Parse(resp.Request.URL.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid simple index URL: %w", err)
+	}
+	var files []simpleFile
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		files, err = parseSimpleJSON(body, base)
+	} else {
+		files, err = parseSimpleHTML(string(body), base)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &PyPIMetadata{Info: PackageInfo{Name: packageName}, Releases: make(map[string][]Release)}
+	withMetadata := make(map[string]string)
+	for _, file := range files {
+		v := fileVersion(file.Filename)
+		if v == "" {
+			continue
+		}
+		metadata.Releases[v] = append(metadata.Releases[v], file.Release)
+		if file.metadata && file.Packagetype == "bdist_wheel" && withMetadata[v] == "" {
+			withMetadata[v] = file.URL
+		}
+	}
+	if len(metadata.Releases) == 0 {
+		return nil, fmt.Errorf("the simple index lists no files for %s", packageName)
+	}
+	for v := range metadata.Releases {
+		if metadata.Info.Version == "" || newerRelease(v, metadata.Info.Version) {
+			metadata.Info.Version = v
+		}
+	}
+	if !excludeNewer.IsZero() {
+		// HTML pages carry no upload times, and files without one would pass
+		// the cutoff, so the snapshot cannot be honoured from such a page
+		for _, file := range files {
+			if file.UploadTime.IsZero() {
+				return nil, fmt.Errorf("the simple index gives no upload time for %s, so files uploaded after %s cannot be excluded", file.Filename, excludeNewer.Format(time.RFC3339))
+			}
+		}
+		metadata.excludeUploadedAfter(excludeNewer)
+		if len(metadata.Releases) == 0 {
+			return nil, fmt.Errorf("no release of %s was uploaded before %s", packageName, excludeNewer.Format(time.RFC3339))
+		}
+	}
+	metadata.URLs = metadata.Releases[metadata.Info.Version]
+	if fileURL := withMetadata[metadata.Info.Version]; fileURL != "" {
+		if core, err := c.fetchCoreMetadata(fileURL); err != nil {
+			logf("simple index: no core metadata for %s %s: %v", packageName, metadata.Info.Version, err)
+		} else {
+			metadata.Info.Name = core.Name
+			metadata.Info.Summary = core.Summary
+			metadata.Info.Author = core.Author
+			metadata.Info.AuthorEmail = core.AuthorEmail
+			metadata.Info.License = core.License
+			metadata.Info.HomePage = core.HomePage
+			metadata.Info.RequiresPython = core.RequiresPython
+			metadata.Info.RequiresDist = core.RequiresDist
+			metadata.Info.Classifier = core.Classifiers
+		}
+	}
+	return metadata, nil
+}
+
+// fetchCoreMetadata downloads the PEP 658 metadata file served next to fileURL
+func (c *PyPIClient) fetchCoreMetadata(fileURL string) (*CoreMetadata, error) {
+	resp, err := c.httpClient.Get(fileURL + ".metadata")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch core metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("core metadata returned status %d", resp.StatusCode)
+	}
+	if err := netutil.DecodeBody(resp); err != nil {
+		return nil, fmt.Errorf("failed to read core metadata: %w", err)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read core metadata: %w", err)
+	}
+	return ParseCoreMetadata(string(content))
+}
+
+// parseSimpleJSON reads the files of a PEP 691 JSON project page
+func parseSimpleJSON(body []byte, base *url.URL) ([]simpleFile, error) {
+	var project simpleProject
+	if err := json.Unmarshal(body, &project); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal simple index JSON: %w", err)
+	}
+	files := make([]simpleFile, 0, len(project.Files))
+	for _, f := range project.Files {
+		file := simpleFile{Release: Release{
+			Filename:       f.Filename,
+			URL:            resolveURL(base, f.URL),
+			Size:           f.Size,
+			UploadTime:     f.UploadTime,
+			Digests:        Digests{SHA256: f.Hashes["sha256"], MD5: f.Hashes["md5"]},
+			RequiresPython: f.RequiresPython,
+			Packagetype:    packageType(f.Filename),
+		}}
+		file.Yanked, file.YankedReason = jsonFlag(f.Yanked)
+		if served, _ := jsonFlag(f.CoreMetadata); served {
+			file.metadata = true
+		} else if served, _ := jsonFlag(f.DistInfo); served {
+			file.metadata = true
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// jsonFlag reads a PEP 691 field that is false, true, a reason string or a
+// hash object, reporting whether it is set and the reason given
+func jsonFlag(raw json.RawMessage) (bool, string) {
+	var set bool
+	if len(raw) == 0 || json.Unmarshal(raw, &set) == nil {
+		return set, ""
+	}
+	var reason string
+	if json.Unmarshal(raw, &reason) == nil {
+		return true, reason
+	}
+	return string(raw) != "null", ""
+}
+
+// parseSimpleHTML reads the files of a PEP 503 HTML project page, with the
+// hash in each link's fragment and the PEP 503, 592 and 658 data attributes
+func parseSimpleHTML(content string, base *url.URL) ([]simpleFile, error) {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse simple index HTML: %w", err)
+	}
+	var files []simpleFile
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			if file, ok := simpleLink(n, base); ok {
+				files = append(files, file)
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			traverse(child)
+		}
+	}
+	traverse(doc)
+	return files, nil
+}
+
+// simpleLink turns an anchor on a project page into the file it links to
+func simpleLink(n *html.Node, base *url.URL) (simpleFile, bool) {
+	var file simpleFile
+	href := ""
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "href":
+			href = attr.Val
+		case "data-requires-python":
+			file.RequiresPython = attr.Val
+		case "data-yanked":
+			file.Yanked, file.YankedReason = true, attr.Val
+		case "data-core-metadata", "data-dist-info-metadata":
+			file.metadata = attr.Val != "false"
+		}
+	}
+	link, err := url.Parse(href)
+	if href == "" || err != nil {
+		return file, false
+	}
+	if algorithm, digest, ok := strings.Cut(link.Fragment, "="); ok {
+		switch algorithm {
+		case "sha256":
+			file.Digests.SHA256 = digest
+		case "md5":
+			file.Digests.MD5 = digest
+		}
+	}
+	link.Fragment = ""
+	file.URL = base.ResolveReference(link).String()
+	file.Filename = link.Path[strings.LastIndex(link.Path, "/")+1:]
+	file.Packagetype = packageType(file.Filename)
+	return file, file.Packagetype != ""
+}
+
+// resolveURL resolves a possibly relative file URL against the project page
+func resolveURL(base *url.URL, ref string) string {
+	link, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(link).String()
+}
+
+// sdistSuffixes are the archive extensions source distributions are published with
+var sdistSuffixes = []string{".tar.gz", ".zip", ".tar.bz2", ".tgz"}
+
+// packageType classifies a filename as the JSON API does, or returns "" for
+// files zephyr does not install
+func packageType(filename string) string {
+	if strings.HasSuffix(filename, ".whl") {
+		return "bdist_wheel"
+	}
+	for _, suffix := range sdistSuffixes {
+		if strings.HasSuffix(filename, suffix) {
+			return "sdist"
+		}
+	}
+	return ""
+}
+
+// fileVersion returns the version a wheel or sdist filename names, or ""
+func fileVersion(filename string) string {
+	if wheel, err := ParseWheelFilename(filename); err == nil {
+		return wheel.Version
+	}
+	for _, suffix := range sdistSuffixes {
+		if stem := strings.TrimSuffix(filename, suffix); stem != filename {
+			if i := strings.LastIndex(stem, "-"); i > 0 {
+				return stem[i+1:]
+			}
+		}
+	}
+	return ""
+}
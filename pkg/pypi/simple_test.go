@@ -0,0 +1,149 @@
+package pypi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingLog struct {
+	lines []string
+}
+
+func (l *recordingLog) Printf(format string, args ...interface{}) {
+	l.lines = append(l.lines, format)
+}
+
+func TestFetchPackageMetadata_SimpleFallback(t *testing.T) {
+	log := &recordingLog{}
+	SetDebugLog(log)
+	defer SetDebugLog(nil)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pypi/demo/json":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/simple/demo/":
+			w.Write([]byte(`<html><body>
+<a href="../../files/demo-1.0.tar.gz#sha256=aa">demo-1.0.tar.gz</a>
+<a href="../../files/demo-1.1-py3-none-any.whl#sha256=bb" data-requires-python="&gt;=3.8" data-core-metadata="sha256=cc">demo-1.1-py3-none-any.whl</a>
+<a href="../../files/demo-2.0b1-py3-none-any.whl" data-yanked="broken">demo-2.0b1-py3-none-any.whl</a>
+</body></html>`))
+		case "/files/demo-1.1-py3-none-any.whl.metadata":
+			w.Write([]byte("Metadata-Version: 2.1\nName: Demo\nVersion: 1.1\nSummary: A demo\nRequires-Dist: six\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	client := &PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}
+
+	meta, err := client.FetchPackageMetadata("demo")
+	if err != nil {
+		t.Fatalf("FetchPackageMetadata failed: %v", err)
+	}
+	if meta.Info.Version != "1.1" || meta.Info.Name != "Demo" || meta.Info.Summary != "A demo" || len(meta.Info.RequiresDist) != 1 {
+		t.Errorf("unexpected info from the simple index: %+v", meta.Info)
+	}
+	if len(meta.Releases) != 3 || len(meta.URLs) != 1 {
+		t.Fatalf("unexpected releases: %+v", meta.Releases)
+	}
+	wheel := meta.URLs[0]
+	if wheel.URL != ts.URL+"/files/demo-1.1-py3-none-any.whl" || wheel.Digests.SHA256 != "bb" || wheel.RequiresPython != ">=3.8" || wheel.Packagetype != "bdist_wheel" {
+		t.Errorf("unexpected wheel: %+v", wheel)
+	}
+	if sdist := meta.Releases["1.0"][0]; sdist.Packagetype != "sdist" || sdist.Digests.SHA256 != "aa" {
+		t.Errorf("unexpected sdist: %+v", sdist)
+	}
+	if pre := meta.Releases["2.0b1"][0]; !pre.Yanked || pre.YankedReason != "broken" {
+		t.Errorf("expected a yanked pre-release: %+v", pre)
+	}
+	if len(log.lines) == 0 || !strings.Contains(log.lines[len(log.lines)-1], "simple index") {
+		t.Errorf("expected the fallback to be logged, got %v", log.lines)
+	}
+}
+
+func TestFetchPackageMetadata_SimpleJSONFallback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/simple/demo/" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.pypi.simple.v1+json")
+		w.Write([]byte(`{"name": "demo", "files": [
+{"filename": "demo-1.0-py3-none-any.whl", "url": "https://files.example/demo-1.0-py3-none-any.whl", "hashes": {"sha256": "aa"}, "requires-python": ">=3.7", "yanked": false, "upload-time": "2024-01-02T03:04:05Z"}
+]}`))
+	}))
+	defer ts.Close()
+	client := &PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}
+
+	meta, err := client.FetchPackageMetadata("demo")
+	if err != nil {
+		t.Fatalf("FetchPackageMetadata failed: %v", err)
+	}
+	if meta.Info.Version != "1.0" || len(meta.URLs) != 1 || meta.URLs[0].Digests.SHA256 != "aa" || meta.URLs[0].UploadTime.IsZero() {
+		t.Errorf("unexpected metadata from PEP 691 JSON: %+v", meta)
+	}
+}
+
+func TestFetchPackageMetadata_NotFoundSkipsFallback(t *testing.T) {
+	fetches := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+	client := &PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}
+	if _, err := client.FetchPackageMetadata("missing"); err == nil || fetches != 1 {
+		t.Errorf("expected one failed fetch for a missing project, got %v after %d fetches", err, fetches)
+	}
+}
+
+func TestFetchPackageMetadata_SimpleFallbackNormalizesName(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/simple/demo-pkg/" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`<a href="/files/demo_pkg-1.0-py3-none-any.whl">demo_pkg-1.0-py3-none-any.whl</a>`))
+	}))
+	defer ts.Close()
+	client := &PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}
+
+	meta, err := client.FetchPackageMetadata("Demo_Pkg")
+	if err != nil {
+		t.Fatalf("FetchPackageMetadata failed: %v", err)
+	}
+	if meta.Info.Version != "1.0" {
+		t.Errorf("unexpected metadata from the normalized page: %+v", meta.Info)
+	}
+}
+
+func TestFetchPackageMetadata_SimpleHTMLFallbackWithCutoff(t *testing.T) {
+	log := &recordingLog{}
+	SetDebugLog(log)
+	defer SetDebugLog(nil)
+	SetExcludeNewer(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer SetExcludeNewer(time.Time{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/simple/demo/" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`<a href="/files/demo-1.0-py3-none-any.whl">demo-1.0-py3-none-any.whl</a>`))
+	}))
+	defer ts.Close()
+	client := &PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}
+
+	// Without upload times the page cannot show which files predate the cutoff
+	if _, err := client.FetchPackageMetadata("demo"); err == nil {
+		t.Fatal("expected the HTML fallback to be refused under --exclude-newer")
+	}
+	if len(log.lines) == 0 || !strings.Contains(log.lines[len(log.lines)-1], "fallback") {
+		t.Errorf("expected the refused fallback to be logged, got %v", log.lines)
+	}
+	if _, err := client.fetchSimpleMetadata("demo"); err == nil || !strings.Contains(err.Error(), "no upload time") {
+		t.Errorf("expected a missing upload time error, got %v", err)
+	}
+}
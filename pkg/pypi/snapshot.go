@@ -0,0 +1,78 @@
+package pypi
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// excludeNewer hides files uploaded after it from every client; zero disables it
+var excludeNewer time.Time
+
+// SetExcludeNewer makes all clients ignore files uploaded after cutoff, so a
+// resolution sees the index as it was at that moment. A zero cutoff disables it.
+func SetExcludeNewer(cutoff time.Time) {
+	excludeNewer = cutoff
+}
+
+// ExcludeNewer returns the cutoff set by SetExcludeNewer
+func ExcludeNewer() time.Time {
+	return excludeNewer
+}
+
+// ParseCutoff parses an --exclude-newer value: a date such as 2024-06-01,
+// meaning midnight UTC at its start, or an RFC 3339 timestamp
+func ParseCutoff(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q: expected a date such as 2024-06-01 or an RFC 3339 time", value)
+}
+
+// excludeUploadedAfter drops files uploaded after cutoff, and versions left
+// without files, and points Info.Version at the newest remaining release.
+// Files without an upload time are kept.
+func (m *PyPIMetadata) excludeUploadedAfter(cutoff time.Time) {
+	m.URLs = filterUploadedAfter(m.URLs, cutoff)
+	if len(m.Releases) == 0 {
+		return
+	}
+	latest := ""
+	for v, files := range m.Releases {
+		kept := filterUploadedAfter(files, cutoff)
+		if len(kept) == 0 && len(files) > 0 {
+			delete(m.Releases, v)
+			continue
+		}
+		m.Releases[v] = kept
+		if len(kept) > 0 && (latest == "" || newerRelease(v, latest)) {
+			latest = v
+		}
+	}
+	m.Info.Version = latest
+}
+
+// filterUploadedAfter returns the files uploaded at or before cutoff
+func filterUploadedAfter(files []Release, cutoff time.Time) []Release {
+	kept := files[:0:0]
+	for _, f := range files {
+		if f.UploadTime.IsZero() || !f.UploadTime.After(cutoff) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// newerRelease reports whether a should replace b as the latest version,
+// preferring final releases over pre-releases as PyPI does
+func newerRelease(a, b string) bool {
+	if preA, preB := version.IsPrerelease(a), version.IsPrerelease(b); preA != preB {
+		return preB
+	}
+	return version.Compare(a, b) > 0
+}
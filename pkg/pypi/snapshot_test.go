@@ -0,0 +1,74 @@
+package pypi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const snapshotJSON = `{
+	"info": {"name": "foo", "version": "2.0.0"},
+	"releases": {
+		"1.0.0": [{"filename": "foo-1.0.0.tar.gz", "upload_time": "2024-01-10T08:00:00"}],
+		"1.1.0rc1": [{"filename": "foo-1.1.0rc1.tar.gz", "upload_time": "2024-05-01T08:00:00"}],
+		"2.0.0": [
+			{"filename": "foo-2.0.0.tar.gz", "upload_time": "2024-05-30T08:00:00"},
+			{"filename": "foo-2.0.0-py3-none-any.whl", "upload_time": "2024-07-02T08:00:00"}
+		],
+		"3.0.0": [{"filename": "foo-3.0.0.tar.gz", "upload_time": "2024-08-01T08:00:00"}]
+	},
+	"urls": [{"filename": "foo-3.0.0.tar.gz", "upload_time": "2024-08-01T08:00:00"}]
+}`
+
+func TestExcludeNewer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(snapshotJSON))
+	}))
+	defer ts.Close()
+	client := &PyPIClient{httpClient: ts.Client(), baseURL: ts.URL}
+
+	cutoff, err := ParseCutoff("2024-06-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetExcludeNewer(cutoff)
+	defer SetExcludeNewer(time.Time{})
+
+	meta, err := client.FetchPackageMetadata("foo")
+	if err != nil {
+		t.Fatalf("FetchPackageMetadata failed: %v", err)
+	}
+	if _, ok := meta.Releases["3.0.0"]; ok {
+		t.Error("3.0.0 was uploaded after the cutoff but is listed")
+	}
+	if files := meta.Releases["2.0.0"]; len(files) != 1 || files[0].Filename != "foo-2.0.0.tar.gz" {
+		t.Errorf("2.0.0 files = %+v", files)
+	}
+	if meta.Info.Version != "2.0.0" {
+		t.Errorf("Info.Version = %q, want 2.0.0", meta.Info.Version)
+	}
+	if len(meta.URLs) != 0 {
+		t.Errorf("URLs = %+v", meta.URLs)
+	}
+
+	SetExcludeNewer(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	if _, err := client.FetchPackageMetadata("foo"); err == nil {
+		t.Error("expected an error when every release is newer than the cutoff")
+	}
+}
+
+func TestParseCutoff(t *testing.T) {
+	for value, want := range map[string]time.Time{
+		"2024-06-01":                time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		"2024-06-01T12:30:00Z":      time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC),
+		"2024-06-01T14:30:00+02:00": time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC),
+	} {
+		if got, err := ParseCutoff(value); err != nil || !got.Equal(want) {
+			t.Errorf("ParseCutoff(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	if _, err := ParseCutoff("June"); err == nil {
+		t.Error("expected an error for an invalid timestamp")
+	}
+}
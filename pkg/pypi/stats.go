@@ -0,0 +1,93 @@
+package pypi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"rimraf-adi.com/zephyr/pkg/netutil"
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+const (
+	PyPIStatsBaseURL        = "https://pypistats.org"
+	PyPIStatsRecentEndpoint = "/api/packages/%s/recent"
+)
+
+// DownloadStats holds recent download counts reported by pypistats.org
+type DownloadStats struct {
+	LastDay   int64 `json:"last_day"`
+	LastWeek  int64 `json:"last_week"`
+	LastMonth int64 `json:"last_month"`
+}
+
+// StatsClient queries the public pypistats.org API
+type StatsClient struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewStatsClient creates a new pypistats client
+func NewStatsClient() *StatsClient {
+	return &StatsClient{
+		httpClient: netutil.NewHTTPClient(10 * time.Second),
+		baseURL:    PyPIStatsBaseURL,
+	}
+}
+
+// FetchRecentDownloads retrieves the last day/week/month download counts for a package
+func (c *StatsClient) FetchRecentDownloads(packageName string) (*DownloadStats, error) {
+	// pypistats keys packages by their normalized lowercase name
+	name := pkgname.Normalize(packageName)
+	url := c.baseURL + fmt.Sprintf(PyPIStatsRecentEndpoint, name)
+
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch download stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pypistats returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var payload struct {
+		Data DownloadStats `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return &payload.Data, nil
+}
+
+// MaintenanceReport summarizes how actively a package is being released
+type MaintenanceReport struct {
+	Package       string
+	LatestVersion string
+	LastRelease   time.Time
+	Unmaintained  bool
+}
+
+// CheckMaintenance flags a package as unmaintained when its newest non-yanked
+// release is older than maxAge
+func CheckMaintenance(metadata *PyPIMetadata, maxAge time.Duration, now time.Time) MaintenanceReport {
+	report := MaintenanceReport{Package: metadata.Info.Name}
+	for _, summary := range VersionHistory(metadata) {
+		if summary.Yanked {
+			continue
+		}
+		if summary.UploadTime.After(report.LastRelease) {
+			report.LastRelease = summary.UploadTime
+			report.LatestVersion = summary.Version
+		}
+	}
+	report.Unmaintained = report.LastRelease.IsZero() || now.Sub(report.LastRelease) > maxAge
+	return report
+}
@@ -0,0 +1,46 @@
+package pypi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchRecentDownloads(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/packages/typing-extensions/recent" {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write([]byte(`{"data": {"last_day": 10, "last_month": 300, "last_week": 70}, "package": "typing-extensions", "type": "recent_downloads"}`))
+	}))
+	defer ts.Close()
+	client := &StatsClient{httpClient: ts.Client(), baseURL: ts.URL}
+	stats, err := client.FetchRecentDownloads("Typing_Extensions")
+	if err != nil {
+		t.Fatalf("FetchRecentDownloads failed: %v", err)
+	}
+	if stats.LastMonth != 300 || stats.LastDay != 10 {
+		t.Errorf("Stats mismatch: %+v", stats)
+	}
+}
+
+func TestCheckMaintenance(t *testing.T) {
+	var meta PyPIMetadata
+	json.Unmarshal([]byte(`{"info": {"name": "old"}, "releases": {
+		"1.0": [{"upload_time": "2019-01-01T00:00:00"}],
+		"1.1": [{"upload_time": "2020-06-01T00:00:00"}],
+		"1.2": [{"upload_time": "2023-01-01T00:00:00", "yanked": true}]
+	}}`), &meta)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := CheckMaintenance(&meta, 2*365*24*time.Hour, now)
+	if !report.Unmaintained || report.LatestVersion != "1.1" {
+		t.Errorf("Expected unmaintained report for 1.1, got %+v", report)
+	}
+	report = CheckMaintenance(&meta, 5*365*24*time.Hour, now)
+	if report.Unmaintained {
+		t.Errorf("Package should be considered maintained with a 5 year window: %+v", report)
+	}
+}
@@ -0,0 +1,227 @@
+package pypi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/markers"
+)
+
+// WheelFilename holds the components of a PEP 427 wheel filename
+type WheelFilename struct {
+	Name         string
+	Version      string
+	Build        string
+	PythonTags   []string
+	ABITags      []string
+	PlatformTags []string
+}
+
+// ParseWheelFilename splits a wheel filename into its name, version and tag components
+func ParseWheelFilename(filename string) (*WheelFilename, error) {
+	if !strings.HasSuffix(filename, ".whl") {
+		return nil, fmt.Errorf("not a wheel filename: %s", filename)
+	}
+	parts := strings.Split(strings.TrimSuffix(filename, ".whl"), "-")
+	if len(parts) != 5 && len(parts) != 6 {
+		return nil, fmt.Errorf("invalid wheel filename: %s", filename)
+	}
+	wheel := &WheelFilename{
+		Name:    parts[0],
+		Version: parts[1],
+	}
+	if len(parts) == 6 {
+		wheel.Build = parts[2]
+		parts = append(parts[:2], parts[3:]...)
+	}
+	wheel.PythonTags = strings.Split(parts[2], ".")
+	wheel.ABITags = strings.Split(parts[3], ".")
+	wheel.PlatformTags = strings.Split(parts[4], ".")
+	return wheel, nil
+}
+
+// Tags expands the compressed tag sets into individual python-abi-platform triples
+func (w *WheelFilename) Tags() []string {
+	var tags []string
+	for _, py := range w.PythonTags {
+		for _, abi := range w.ABITags {
+			for _, plat := range w.PlatformTags {
+				tags = append(tags, fmt.Sprintf("%s-%s-%s", py, abi, plat))
+			}
+		}
+	}
+	return tags
+}
+
+// TagString returns the compressed tag triple as it appears in the filename
+func (w *WheelFilename) TagString() string {
+	return fmt.Sprintf("%s-%s-%s",
+		strings.Join(w.PythonTags, "."),
+		strings.Join(w.ABITags, "."),
+		strings.Join(w.PlatformTags, "."))
+}
+
+// CompatibleWith reports whether any of the wheel's tags can be installed on a CPython
+// interpreter for the given target
+func (w *WheelFilename) CompatibleWith(t markers.Target) bool {
+	for _, py := range w.PythonTags {
+		for _, abi := range w.ABITags {
+			if !pythonTagMatches(py, abi, t.PythonMinor()) {
+				continue
+			}
+			for _, plat := range w.PlatformTags {
+				if platformTagMatches(plat, t) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// pythonTagMatches checks a python/abi tag pair against a major.minor CPython version
+func pythonTagMatches(py, abi, pythonMinor string) bool {
+	parts := strings.SplitN(pythonMinor, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	major, minor := parts[0], parts[1]
+	cpTag := "cp" + major + minor
+	switch abi {
+	case "none":
+		return py == "py"+major || py == "py"+major+minor || py == cpTag
+	case "abi3":
+		// abi3 wheels built for an older CPython keep working on newer minors
+		if !strings.HasPrefix(py, "cp"+major) {
+			return false
+		}
+		built, err1 := strconv.Atoi(strings.TrimPrefix(py, "cp"+major))
+		target, err2 := strconv.Atoi(minor)
+		return err1 == nil && err2 == nil && built <= target
+	}
+	return py == cpTag && (abi == cpTag || abi == cpTag+"m")
+}
+
+// platformTagMatches checks a wheel platform tag against a target os and arch
+func platformTagMatches(plat string, t markers.Target) bool {
+	if plat == "any" {
+		return true
+	}
+	switch t.OS {
+	case "linux":
+		arch := t.Arch
+		if arch == "arm64" {
+			arch = "aarch64"
+		} else if arch == "x86" {
+			arch = "i686"
+		}
+		return (strings.HasPrefix(plat, "manylinux") || plat == "linux_"+arch) && strings.HasSuffix(plat, "_"+arch)
+	case "macos":
+		if !strings.HasPrefix(plat, "macosx_") {
+			return false
+		}
+		return strings.HasSuffix(plat, "_"+t.Arch) || strings.HasSuffix(plat, "_universal2") ||
+			(t.Arch == "x86_64" && (strings.HasSuffix(plat, "_intel") || strings.HasSuffix(plat, "_universal")))
+	case "windows":
+		switch t.Arch {
+		case "x86_64":
+			return plat == "win_amd64"
+		case "arm64":
+			return plat == "win_arm64"
+		case "x86":
+			return plat == "win32"
+		}
+	}
+	return false
+}
+
+// TargetTags describes the tags a wheel needs to install on a CPython
+// interpreter for the target: its python-abi pairs and platform patterns
+func TargetTags(t markers.Target) (pythonTags, platformTags []string) {
+	major, minor, _ := strings.Cut(t.PythonMinor(), ".")
+	cp := "cp" + major + minor
+	pythonTags = []string{cp + "-" + cp, cp + "-abi3", "cp" + major + "X-abi3 (X <= " + minor + ")", "py" + major + "-none", "py" + major + minor + "-none", cp + "-none"}
+	switch t.OS {
+	case "linux":
+		arch := t.Arch
+		if arch == "arm64" {
+			arch = "aarch64"
+		} else if arch == "x86" {
+			arch = "i686"
+		}
+		platformTags = []string{"manylinux*_" + arch, "linux_" + arch}
+	case "macos":
+		platformTags = []string{"macosx_*_" + t.Arch, "macosx_*_universal2"}
+		if t.Arch == "x86_64" {
+			platformTags = append(platformTags, "macosx_*_intel", "macosx_*_universal")
+		}
+	case "windows":
+		switch t.Arch {
+		case "x86_64":
+			platformTags = []string{"win_amd64"}
+		case "arm64":
+			platformTags = []string{"win_arm64"}
+		case "x86":
+			platformTags = []string{"win32"}
+		}
+	}
+	return pythonTags, append(platformTags, "any")
+}
+
+// NoCompatibleArtifactError explains why none of a release's files can be
+// installed on a target
+type NoCompatibleArtifactError struct {
+	// Package and Version may be empty when the caller adds them
+	Package string
+	Version string
+	Target  markers.Target
+	// PythonTags and PlatformTags are the tags that were considered
+	PythonTags   []string
+	PlatformTags []string
+	// Wheels lists the published wheels, none of which match
+	Wheels []string
+	// Sdist is the published sdist that could be built from source, or ""
+	Sdist string
+}
+
+// NewNoCompatibleArtifactError describes releases, the files published for
+// one version, against the target
+func NewNoCompatibleArtifactError(packageName, version string, releases []Release, t markers.Target) *NoCompatibleArtifactError {
+	e := &NoCompatibleArtifactError{Package: packageName, Version: version, Target: t}
+	e.PythonTags, e.PlatformTags = TargetTags(t)
+	for _, release := range releases {
+		if release.Yanked {
+			continue
+		}
+		switch release.Packagetype {
+		case "bdist_wheel":
+			e.Wheels = append(e.Wheels, release.Filename)
+		case "sdist":
+			e.Sdist = release.Filename
+		}
+	}
+	return e
+}
+
+// Error implements the error interface
+func (e *NoCompatibleArtifactError) Error() string {
+	var b strings.Builder
+	subject := "no wheel"
+	if e.Package != "" {
+		subject = fmt.Sprintf("no wheel of %s %s", e.Package, e.Version)
+	}
+	fmt.Fprintf(&b, "%s matches %s\n", subject, e.Target)
+	fmt.Fprintf(&b, "  tags considered: %s on %s\n", strings.Join(e.PythonTags, ", "), strings.Join(e.PlatformTags, ", "))
+	if len(e.Wheels) == 0 {
+		b.WriteString("  wheels published: none\n")
+	} else {
+		fmt.Fprintf(&b, "  wheels published: %s\n", strings.Join(e.Wheels, ", "))
+	}
+	if e.Sdist != "" {
+		fmt.Fprintf(&b, "  sdist fallback: %s can be built from source with a compiler and the package's build requirements", e.Sdist)
+	} else {
+		b.WriteString("  sdist fallback: none, no sdist is published; pick a version or platform with a matching wheel")
+	}
+	return b.String()
+}
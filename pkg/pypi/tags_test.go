@@ -0,0 +1,87 @@
+package pypi
+
+import (
+	"strings"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/markers"
+)
+
+func TestParseWheelFilename(t *testing.T) {
+	w, err := ParseWheelFilename("requests-2.31.0-py3-none-any.whl")
+	if err != nil {
+		t.Fatalf("ParseWheelFilename failed: %v", err)
+	}
+	if w.Name != "requests" || w.Version != "2.31.0" || w.TagString() != "py3-none-any" {
+		t.Errorf("Parsed wheel mismatch: %+v", w)
+	}
+	w, err = ParseWheelFilename("numpy-1.26.0-1-cp311-cp311-manylinux_2_17_x86_64.manylinux2014_x86_64.whl")
+	if err != nil {
+		t.Fatalf("ParseWheelFilename failed: %v", err)
+	}
+	if w.Build != "1" || len(w.Tags()) != 2 {
+		t.Errorf("Expected build tag and two expanded tags, got %+v / %v", w, w.Tags())
+	}
+	if _, err := ParseWheelFilename("foo-1.0.tar.gz"); err == nil {
+		t.Error("Expected error for sdist filename")
+	}
+	if _, err := ParseWheelFilename("foo-1.0.whl"); err == nil {
+		t.Error("Expected error for malformed wheel filename")
+	}
+}
+
+func TestWheelCompatibleWith(t *testing.T) {
+	linux := markers.Target{OS: "linux", Arch: "x86_64", Python: "3.11"}
+	mac := markers.Target{OS: "macos", Arch: "arm64", Python: "3.12"}
+	win := markers.Target{OS: "windows", Arch: "x86_64", Python: "3.11"}
+	cases := []struct {
+		filename string
+		target   markers.Target
+		want     bool
+	}{
+		{"six-1.16.0-py2.py3-none-any.whl", mac, true},
+		{"numpy-1.26.0-cp311-cp311-manylinux_2_17_x86_64.manylinux2014_x86_64.whl", linux, true},
+		{"numpy-1.26.0-cp311-cp311-manylinux_2_17_x86_64.manylinux2014_x86_64.whl", win, false},
+		{"numpy-1.26.0-cp312-cp312-macosx_11_0_arm64.whl", mac, true},
+		{"numpy-1.26.0-cp311-cp311-win_amd64.whl", win, true},
+		{"cryptography-41.0.0-cp37-abi3-manylinux_2_28_x86_64.whl", linux, true},
+		{"numpy-1.26.0-cp310-cp310-manylinux_2_17_x86_64.whl", linux, false},
+	}
+	for _, c := range cases {
+		wheel, err := ParseWheelFilename(c.filename)
+		if err != nil {
+			t.Fatalf("ParseWheelFilename failed: %v", err)
+		}
+		if got := wheel.CompatibleWith(c.target); got != c.want {
+			t.Errorf("%s on %s: got %v, want %v", c.filename, c.target, got, c.want)
+		}
+	}
+}
+
+func TestNoCompatibleArtifactError(t *testing.T) {
+	releases := []Release{
+		{Filename: "uvloop-0.19.0-cp311-cp311-manylinux_2_17_x86_64.whl", Packagetype: "bdist_wheel"},
+		{Filename: "uvloop-0.19.0-cp311-cp311-macosx_10_9_universal2.whl", Packagetype: "bdist_wheel"},
+		{Filename: "uvloop-0.18.0-cp311-cp311-win_amd64.whl", Packagetype: "bdist_wheel", Yanked: true},
+	}
+	target := markers.Target{OS: "windows", Arch: "x86_64", Python: "3.11"}
+	err := NewNoCompatibleArtifactError("uvloop", "0.19.0", releases, target)
+	msg := err.Error()
+	for _, want := range []string{
+		"no wheel of uvloop 0.19.0 matches windows-x86_64-3.11",
+		"cp311-cp311, cp311-abi3",
+		"on win_amd64, any",
+		"wheels published: uvloop-0.19.0-cp311-cp311-manylinux_2_17_x86_64.whl, uvloop-0.19.0-cp311-cp311-macosx_10_9_universal2.whl\n",
+		"sdist fallback: none",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error missing %q:\n%s", want, msg)
+		}
+	}
+
+	releases = append(releases, Release{Filename: "uvloop-0.19.0.tar.gz", Packagetype: "sdist"})
+	err = NewNoCompatibleArtifactError("uvloop", "0.19.0", releases, markers.Target{OS: "linux", Arch: "arm64", Python: "3.12"})
+	if msg := err.Error(); !strings.Contains(msg, "manylinux*_aarch64, linux_aarch64, any") || !strings.Contains(msg, "sdist fallback: uvloop-0.19.0.tar.gz can be built") {
+		t.Errorf("unexpected error:\n%s", msg)
+	}
+}
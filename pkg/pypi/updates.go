@@ -0,0 +1,78 @@
+package pypi
+
+import (
+	"fmt"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// UpdatePolicy limits how far zephyr update may move a dependency
+type UpdatePolicy string
+
+const (
+	// PolicyLatest allows any newer release
+	PolicyLatest UpdatePolicy = "latest"
+	// PolicyMinor allows semver-compatible releases: the same major version,
+	// or the same minor version while the major version is 0
+	PolicyMinor UpdatePolicy = "minor"
+	// PolicyPatch allows releases with the same major and minor version
+	PolicyPatch UpdatePolicy = "patch"
+	// PolicySecurity only moves to the lowest release fixing known advisories
+	PolicySecurity UpdatePolicy = "security"
+)
+
+// DefaultUpdatePolicy is used when neither the package nor the project sets one
+const DefaultUpdatePolicy = PolicyMinor
+
+// ParseUpdatePolicy validates a policy name, defaulting "" to DefaultUpdatePolicy
+func ParseUpdatePolicy(name string) (UpdatePolicy, error) {
+	switch policy := UpdatePolicy(strings.ToLower(name)); policy {
+	case "":
+		return DefaultUpdatePolicy, nil
+	case PolicyLatest, PolicyMinor, PolicyPatch, PolicySecurity:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown update policy %q (expected latest, minor, patch or security)", name)
+}
+
+// SelectUpdate picks the newest release in history that policy allows moving
+// current to. Yanked releases are skipped, as are pre-releases unless current
+// is one. It returns "" when no newer release is allowed; PolicySecurity never
+// selects from history, see FixedVersion.
+func SelectUpdate(history []VersionSummary, current string, policy UpdatePolicy) string {
+	if policy == PolicySecurity {
+		return ""
+	}
+	cur, err := version.Parse(current)
+	if err != nil {
+		cur = nil
+	}
+	for _, summary := range history {
+		if summary.Yanked || (summary.Prerelease && (cur == nil || !cur.IsPrerelease())) {
+			continue
+		}
+		candidate, err := version.Parse(summary.Version)
+		if err != nil {
+			continue
+		}
+		if cur == nil {
+			return summary.Version
+		}
+		if candidate.Compare(cur) <= 0 {
+			continue
+		}
+		switch policy {
+		case PolicyMinor:
+			if candidate.Major() != cur.Major() || (cur.Major() == 0 && candidate.Minor() != cur.Minor()) {
+				continue
+			}
+		case PolicyPatch:
+			if candidate.Major() != cur.Major() || candidate.Minor() != cur.Minor() {
+				continue
+			}
+		}
+		return summary.Version
+	}
+	return ""
+}
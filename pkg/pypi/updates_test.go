@@ -0,0 +1,49 @@
+package pypi
+
+import (
+	"testing"
+)
+
+func TestSelectUpdate(t *testing.T) {
+	history := []VersionSummary{
+		{Version: "3.0.0b1", Prerelease: true},
+		{Version: "2.1.0"},
+		{Version: "1.9.0", Yanked: true},
+		{Version: "1.5.2"},
+		{Version: "1.4.9"},
+		{Version: "1.4.1"},
+		{Version: "0.9.0"},
+		{Version: "0.8.5"},
+		{Version: "0.8.1"},
+	}
+	tests := []struct {
+		current  string
+		policy   UpdatePolicy
+		expected string
+	}{
+		{"1.4.1", PolicyLatest, "2.1.0"},
+		{"1.4.1", PolicyMinor, "1.5.2"},
+		{"1.4.1", PolicyPatch, "1.4.9"},
+		{"1.4.1", PolicySecurity, ""},
+		{"2.1.0", PolicyLatest, ""},
+		{"0.8.1", PolicyMinor, "0.8.5"},
+		{"3.0.0a1", PolicyLatest, "3.0.0b1"},
+	}
+	for _, tt := range tests {
+		if got := SelectUpdate(history, tt.current, tt.policy); got != tt.expected {
+			t.Errorf("SelectUpdate(%s, %s) = %q, expected %q", tt.current, tt.policy, got, tt.expected)
+		}
+	}
+}
+
+func TestParseUpdatePolicy(t *testing.T) {
+	if policy, err := ParseUpdatePolicy(""); err != nil || policy != DefaultUpdatePolicy {
+		t.Errorf("ParseUpdatePolicy(\"\") = %q, %v", policy, err)
+	}
+	if policy, err := ParseUpdatePolicy("Patch"); err != nil || policy != PolicyPatch {
+		t.Errorf("ParseUpdatePolicy(Patch) = %q, %v", policy, err)
+	}
+	if _, err := ParseUpdatePolicy("sometimes"); err == nil {
+		t.Error("ParseUpdatePolicy should reject unknown policies")
+	}
+}
@@ -0,0 +1,171 @@
+// Package remedy is the catalog of advice for failures that have a known fix,
+// such as a missing compiler or a full disk. The installer and builder
+// annotate their errors with it so every command explains a failure the same
+// way, and the CLI prints the advice under the error.
+package remedy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"rimraf-adi.com/zephyr/pkg/netutil"
+	"rimraf-adi.com/zephyr/pkg/pypi"
+	"rimraf-adi.com/zephyr/pkg/sysreq"
+)
+
+// Remedy is the advice for one kind of failure
+type Remedy struct {
+	// ID names the failure, e.g. "missing-compiler"
+	ID string
+	// Advice says how to fix it
+	Advice string
+}
+
+// entry is one failure signature in the catalog
+type entry struct {
+	id string
+	// advise returns the advice when err matches, or ""; text is the error
+	// message, which for builds includes the build output
+	advise func(err error, text string) string
+}
+
+// Error is an error with the remedy found for it
+type Error struct {
+	Err    error
+	Remedy Remedy
+}
+
+// Error implements the error interface; the advice is left to Explain, so
+// messages read the same with and without a remedy
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the annotated error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Find returns the remedy for err when its signature is in the catalog
+func Find(err error) (Remedy, bool) {
+	if err == nil {
+		return Remedy{}, false
+	}
+	var annotated *Error
+	if errors.As(err, &annotated) {
+		return annotated.Remedy, true
+	}
+	text := err.Error()
+	for _, e := range catalog {
+		if advice := e.advise(err, text); advice != "" {
+			return Remedy{ID: e.id, Advice: advice}, true
+		}
+	}
+	return Remedy{}, false
+}
+
+// Annotate attaches the remedy for err, returning err unchanged when it has
+// none or already carries one
+func Annotate(err error) error {
+	var annotated *Error
+	if err == nil || errors.As(err, &annotated) {
+		return err
+	}
+	if r, ok := Find(err); ok {
+		return &Error{Err: err, Remedy: r}
+	}
+	return err
+}
+
+// Explain returns the advice for err, or "" when the catalog has none
+func Explain(err error) string {
+	r, _ := Find(err)
+	return r.Advice
+}
+
+// compilerMissing matches the ways setuptools, pip and compilers report that
+// no C or C++ compiler could be run
+var compilerMissing = regexp.MustCompile(`unable to execute '?([\w.+-]*(?:gcc|g\+\+|cc|clang))'?|command '([^']*(?:gcc|g\+\+|cc|clang))' failed: No such file|([\w.+-]*(?:gcc|g\+\+|cc|clang)): (?:command )?not found|Microsoft Visual C\+\+ [\d.]+ or greater is required`)
+
+// libraryMissing matches the linker failing to find a library
+var libraryMissing = regexp.MustCompile(`cannot find -l([\w+.-]+)`)
+
+// glibcMissing matches the dynamic loader rejecting a library built against a newer glibc
+var glibcMissing = regexp.MustCompile("version `(GLIBC_[\\d.]+)' not found")
+
+// catalog lists the known failures, most specific first
+var catalog = []entry{
+	{"missing-compiler", func(err error, text string) string {
+		m := compilerMissing.FindStringSubmatch(text)
+		if m == nil {
+			return ""
+		}
+		if strings.HasPrefix(m[0], "Microsoft Visual C++") {
+			return "building this package needs the Microsoft C++ Build Tools: install them from https://visualstudio.microsoft.com/visual-cpp-build-tools/, or pick a version that publishes a wheel for your platform"
+		}
+		return "building this package from source needs a C compiler, which was not found; " + sysreq.ToolHint("cc") + ", or pick a version that publishes a wheel for your platform"
+	}},
+	{"missing-python-headers", func(err error, text string) string {
+		if !strings.Contains(text, "Python.h: No such file or directory") {
+			return ""
+		}
+		return "the Python headers C extensions compile against are missing; " + sysreq.PythonHeadersHint()
+	}},
+	{"missing-library", func(err error, text string) string {
+		m := libraryMissing.FindStringSubmatch(text)
+		if m == nil {
+			return ""
+		}
+		return fmt.Sprintf("the linker could not find lib%s; %s", m[1], sysreq.LibraryHint(m[1]))
+	}},
+	{"glibc-too-old", func(err error, text string) string {
+		m := glibcMissing.FindStringSubmatch(text)
+		if m == nil {
+			return ""
+		}
+		return fmt.Sprintf("a compiled module needs %s, newer than this system's C library; pin an older release of the package, or run on a newer distribution or base image", m[1])
+	}},
+	{"no-compatible-wheel", func(err error, text string) string {
+		var noMatch *pypi.NoCompatibleArtifactError
+		if !errors.As(err, &noMatch) || noMatch.Target.OS != "linux" {
+			return ""
+		}
+		for _, wheel := range noMatch.Wheels {
+			if strings.Contains(wheel, "manylinux") && strings.Contains(wheel, noMatch.Target.Arch) {
+				return "the published Linux wheels target a newer glibc (their manylinux tag) than zephyr considers; pin an older release that still publishes manylinux2014 wheels, run on a newer distribution or base image, or install a compiler so the sdist can be built"
+			}
+		}
+		return ""
+	}},
+	{"truncated-download", func(err error, text string) string {
+		var truncated *netutil.TruncatedDownloadError
+		if !errors.As(err, &truncated) {
+			return ""
+		}
+		return "the connection closed before the whole file arrived; run the command again to resume the download, and if it keeps happening check for a proxy or firewall that cuts off large transfers, or lower the load with --max-parallel-downloads"
+	}},
+	{"disk-full", func(err error, text string) string {
+		if !errors.Is(err, syscall.ENOSPC) && !strings.Contains(text, "no space left on device") {
+			return ""
+		}
+		return "the disk is full; free some space, or run zephyr cache prune --max-size 1GB to shrink the wheel cache, then try again"
+	}},
+	{"permission-denied", func(err error, text string) string {
+		if !errors.Is(err, fs.ErrPermission) && !strings.Contains(text, "permission denied") {
+			return ""
+		}
+		where := "the environment"
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			where = pathErr.Path
+		}
+		if strings.Contains(text, "site-packages") || strings.Contains(where, "site-packages") {
+			return fmt.Sprintf("%s is not writable by you; install into a virtual environment you own (zephyr venv create) instead of a system Python, and do not run zephyr with sudo", where)
+		}
+		return fmt.Sprintf("%s is not writable by you; check its owner with ls -ld, and do not run zephyr with sudo", where)
+	}},
+}
@@ -0,0 +1,68 @@
+package remedy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"syscall"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/markers"
+	"rimraf-adi.com/zephyr/pkg/netutil"
+	"rimraf-adi.com/zephyr/pkg/pypi"
+)
+
+func TestFind(t *testing.T) {
+	noWheel := &pypi.NoCompatibleArtifactError{
+		Target: markers.Target{OS: "linux", Arch: "x86_64", Python: "3.11"},
+		Wheels: []string{"foo-1.0-cp311-cp311-manylinux_2_34_x86_64.whl"},
+	}
+	tests := []struct {
+		name string
+		err  error
+		id   string
+	}{
+		{"gcc missing", errors.New("pip wheel failed: exit status 1\nerror: command 'gcc' failed: No such file or directory"), "missing-compiler"},
+		{"cc not found", errors.New("building 'x' extension\nunable to execute 'x86_64-linux-gnu-gcc': No such file or directory"), "missing-compiler"},
+		{"msvc", errors.New("error: Microsoft Visual C++ 14.0 or greater is required."), "missing-compiler"},
+		{"python headers", errors.New("src/x.c:1:10: fatal error: Python.h: No such file or directory"), "missing-python-headers"},
+		{"library", errors.New("/usr/bin/ld: cannot find -lpq: No such file or directory"), "missing-library"},
+		{"glibc", errors.New("ImportError: /lib64/libc.so.6: version `GLIBC_2.34' not found"), "glibc-too-old"},
+		{"manylinux", fmt.Errorf("install foo: %w", noWheel), "no-compatible-wheel"},
+		{"truncated", fmt.Errorf("failed to download release: %w", &netutil.TruncatedDownloadError{URL: "https://files/x.whl", Expected: 100, Received: 40}), "truncated-download"},
+		{"disk full", fmt.Errorf("failed to extract: %w", &fs.PathError{Op: "write", Path: "/venv/x", Err: syscall.ENOSPC}), "disk-full"},
+		{"site-packages", fmt.Errorf("failed to extract: %w", &fs.PathError{Op: "open", Path: "/usr/lib/python3/site-packages/x.py", Err: fs.ErrPermission}), "permission-denied"},
+	}
+	for _, tt := range tests {
+		r, ok := Find(tt.err)
+		if !ok || r.ID != tt.id || r.Advice == "" {
+			t.Errorf("%s: Find = %+v, %v; want %s", tt.name, r, ok, tt.id)
+		}
+	}
+	if r, ok := Find(errors.New("connection reset by peer")); ok {
+		t.Errorf("unexpected remedy for an unknown failure: %+v", r)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	cause := &fs.PathError{Op: "open", Path: "/usr/lib/python3/site-packages/x.py", Err: fs.ErrPermission}
+	err := Annotate(fmt.Errorf("failed to extract: %w", cause))
+	var annotated *Error
+	if !errors.As(err, &annotated) {
+		t.Fatalf("expected an annotated error, got %T", err)
+	}
+	if !errors.Is(err, fs.ErrPermission) || err.Error() != "failed to extract: open /usr/lib/python3/site-packages/x.py: permission denied" {
+		t.Errorf("annotation must keep the message and chain, got %q", err)
+	}
+	if !strings.Contains(Explain(fmt.Errorf("install: %w", err)), "virtual environment") {
+		t.Errorf("Explain = %q", Explain(err))
+	}
+	if Annotate(err) != err {
+		t.Error("annotating twice should return the error unchanged")
+	}
+	plain := errors.New("something else")
+	if Annotate(plain) != plain || Annotate(nil) != nil {
+		t.Error("errors without a remedy should be returned unchanged")
+	}
+}
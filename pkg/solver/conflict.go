@@ -0,0 +1,211 @@
+package solver
+
+import "fmt"
+
+// Conflict describes why the last Solve failed: the incompatibility conflict
+// resolution could not get past, and everything it was derived from
+type Conflict struct {
+	// Incompatibility is the root cause, a set of terms that cannot all hold
+	Incompatibility Incompatibility
+	// Derivation lists the incompatibilities the root cause rests on, each
+	// once: the chain it was derived through, then the incompatibilities
+	// that the assignments of its packages were derived from
+	Derivation []Incompatibility
+	// Assignments are the partial solution's assignments to the packages
+	// the root cause names, when solving failed
+	Assignments []Assignment
+	// RootPackage is the package that was being solved for
+	RootPackage string
+}
+
+// ConflictError is returned when Solve proves the dependencies unsatisfiable
+type ConflictError struct {
+	Conflict *Conflict
+	// message keeps the wording of the failure for Error
+	message string
+}
+
+// Error implements the error interface
+func (e *ConflictError) Error() string {
+	return e.message
+}
+
+// GetLastConflict returns the conflict that made the last Solve fail, or nil
+// when it succeeded or stopped for another reason
+func (s *Solver) GetLastConflict() *Conflict {
+	return s.lastConflict
+}
+
+// Derivation returns the incompatibility followed by the incompatibilities it
+// was derived from, following Cause links
+func (i Incompatibility) Derivation() []Incompatibility {
+	chain := []Incompatibility{i}
+	seen := map[string]bool{i.key(): true}
+	for cause := i.Cause; cause != nil && !seen[cause.key()]; cause = cause.Cause {
+		seen[cause.key()] = true
+		chain = append(chain, *cause)
+	}
+	return chain
+}
+
+// conflictError records root, which could not be resolved, as the last
+// conflict along with the causes it was found next to
+func (s *Solver) conflictError(root Incompatibility, causes ...Incompatibility) *ConflictError {
+	conflict := &Conflict{Incompatibility: root, RootPackage: s.rootPackage}
+	seen := map[string]bool{root.key(): true}
+	add := func(incompatibility Incompatibility) {
+		for _, step := range incompatibility.Derivation() {
+			if !seen[step.key()] {
+				seen[step.key()] = true
+				conflict.Derivation = append(conflict.Derivation, step)
+			}
+		}
+	}
+	add(root)
+	for _, cause := range causes {
+		add(cause)
+	}
+	named := make(map[string]bool)
+	for _, term := range root.Terms {
+		named[term.Package] = true
+	}
+	for _, assignment := range s.partialSolution.Assignments {
+		if !named[assignment.Term.Package] {
+			continue
+		}
+		conflict.Assignments = append(conflict.Assignments, assignment)
+		if assignment.Cause != nil {
+			add(*assignment.Cause)
+		}
+	}
+	s.lastConflict = conflict
+
+	message := fmt.Sprintf("version solving failed: conflict detected in %s", root)
+	if len(causes) > 0 {
+		message = fmt.Sprintf("version solving failed: conflict detected between %s and %s", root, causes[0])
+	}
+	return &ConflictError{Conflict: conflict, message: message}
+}
+
+// ConflictReport is a Conflict as a graph for tools to render: every
+// incompatibility involved gets an ID, and terms and assignments refer to
+// the incompatibilities that caused them by ID
+type ConflictReport struct {
+	// RootCause is the ID of the incompatibility that could not be resolved
+	RootCause         int                     `json:"root_cause"`
+	Incompatibilities []IncompatibilityReport `json:"incompatibilities"`
+	// Packages lists what the partial solution held for each package the
+	// root cause names, by name
+	Packages []PackageReport `json:"packages"`
+}
+
+// IncompatibilityReport is one incompatibility of a ConflictReport
+type IncompatibilityReport struct {
+	ID int `json:"id"`
+	// Kind is "dependency" (a version requires a range of another package),
+	// "root" (the project requires a package), "no-versions" (no version of
+	// a range can be chosen), "derived" (concluded from CausedBy) or
+	// "conflict" for any other set of terms
+	Kind     string       `json:"kind"`
+	Terms    []TermReport `json:"terms"`
+	CausedBy *int         `json:"caused_by,omitempty"`
+	Text     string       `json:"text"`
+}
+
+// TermReport is one term of an incompatibility or assignment
+type TermReport struct {
+	Package    string `json:"package"`
+	Constraint string `json:"constraint"`
+	Negated    bool   `json:"negated"`
+}
+
+// PackageReport lists the assignments to one package
+type PackageReport struct {
+	Name        string             `json:"name"`
+	Assignments []AssignmentReport `json:"assignments"`
+}
+
+// AssignmentReport is one assignment of the partial solution
+type AssignmentReport struct {
+	Term          TermReport `json:"term"`
+	Decision      bool       `json:"decision"`
+	DecisionLevel int        `json:"decision_level"`
+	CausedBy      *int       `json:"caused_by,omitempty"`
+}
+
+// Report returns the conflict as a graph of IDs
+func (c *Conflict) Report() *ConflictReport {
+	report := &ConflictReport{}
+	ids := make(map[string]int)
+	var add func(incompatibility Incompatibility) int
+	add = func(incompatibility Incompatibility) int {
+		key := incompatibility.key()
+		if id, ok := ids[key]; ok {
+			return id
+		}
+		id := len(report.Incompatibilities)
+		ids[key] = id
+		report.Incompatibilities = append(report.Incompatibilities, IncompatibilityReport{
+			ID:    id,
+			Kind:  incompatibilityKind(incompatibility, c.RootPackage),
+			Terms: termReports(incompatibility.Terms),
+			Text:  incompatibility.String(),
+		})
+		if incompatibility.Cause != nil {
+			cause := add(*incompatibility.Cause)
+			report.Incompatibilities[id].CausedBy = &cause
+		}
+		return id
+	}
+	report.RootCause = add(c.Incompatibility)
+	for _, incompatibility := range c.Derivation {
+		add(incompatibility)
+	}
+	byName := make(map[string]int)
+	for _, assignment := range c.Assignments {
+		index, ok := byName[assignment.Term.Package]
+		if !ok {
+			index = len(report.Packages)
+			byName[assignment.Term.Package] = index
+			report.Packages = append(report.Packages, PackageReport{Name: assignment.Term.Package})
+		}
+		entry := AssignmentReport{
+			Term:          termReports([]Term{assignment.Term})[0],
+			Decision:      assignment.IsDecision,
+			DecisionLevel: assignment.DecisionLevel,
+		}
+		if assignment.Cause != nil {
+			cause := add(*assignment.Cause)
+			entry.CausedBy = &cause
+		}
+		report.Packages[index].Assignments = append(report.Packages[index].Assignments, entry)
+	}
+	return report
+}
+
+// incompatibilityKind classifies an incompatibility for a ConflictReport
+func incompatibilityKind(incompatibility Incompatibility, rootPackage string) string {
+	if incompatibility.Cause != nil {
+		return "derived"
+	}
+	terms := incompatibility.Terms
+	if len(terms) == 1 && !terms[0].Negated {
+		return "no-versions"
+	}
+	if len(terms) == 2 && !terms[0].Negated && terms[1].Negated {
+		if terms[0].Package == rootPackage {
+			return "root"
+		}
+		return "dependency"
+	}
+	return "conflict"
+}
+
+// termReports converts terms for a ConflictReport
+func termReports(terms []Term) []TermReport {
+	reports := make([]TermReport, len(terms))
+	for i, term := range terms {
+		reports[i] = TermReport{Package: term.Package, Constraint: term.Version.String(), Negated: term.Negated}
+	}
+	return reports
+}
@@ -120,6 +120,11 @@ func (s *Solver) backtrackFromConflict(incompatibility Incompatibility) {
 	
 	// Backtrack the partial solution
 	s.partialSolution.Backtrack(backtrackLevel)
+
+	// Incompatibilities are not collected here. Dropping the ones introduced
+	// above backtrackLevel waits on determineBacktrackLevel computing real
+	// levels: it always returns 0, and a conflict at level 0 fails the solve,
+	// so there is never a later search for stale incompatibilities to slow down.
 }
 
 // determineBacktrackLevel determines the decision level to backtrack to
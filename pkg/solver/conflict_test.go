@@ -0,0 +1,104 @@
+package solver
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetLastConflict(t *testing.T) {
+	provider := tableProvider{
+		"web":  {"2.0": {"json": {Max: "2"}}},
+		"json": {"1.0": nil, "2.0": nil},
+	}
+	s := NewSolver("app", "1.0.0")
+	s.SetProvider(provider)
+	s.AddRootDependency("web", VersionConstraint{})
+	s.AddRootDependency("json", VersionConstraint{Min: "2"})
+	_, err := s.Solve()
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("Expected a ConflictError, got %v", err)
+	}
+	conflict := s.GetLastConflict()
+	if conflict == nil || conflict != conflictErr.Conflict {
+		t.Fatalf("Expected the error's conflict to be the last conflict, got %+v", conflict)
+	}
+	if conflict.Incompatibility.String() != "{web 2.0, not json <2}" {
+		t.Errorf("Unexpected root cause %s", conflict.Incompatibility)
+	}
+	derivation := map[string]bool{}
+	for _, incompatibility := range conflict.Derivation {
+		derivation[incompatibility.String()] = true
+	}
+	if !derivation["{app 1.0.0, not json >=2}"] || !derivation["{app 1.0.0, not web any}"] {
+		t.Errorf("Expected the root requirements in the derivation, got %v", conflict.Derivation)
+	}
+	if len(conflict.Assignments) == 0 {
+		t.Error("Expected the assignments of the conflicting packages")
+	}
+
+	s = NewSolver("app", "1.0.0")
+	s.SetProvider(tableProvider{"json": {"2.0": nil}})
+	s.AddRootDependency("json", VersionConstraint{})
+	if _, err := s.Solve(); err != nil || s.GetLastConflict() != nil {
+		t.Errorf("Expected no conflict after a successful solve, got %v, %+v", err, s.GetLastConflict())
+	}
+}
+
+func TestGetLastConflict_ContradictoryUnits(t *testing.T) {
+	s := NewSolver("app", "1.0.0")
+	s.AddIncompatibility(Incompatibility{Terms: []Term{{Package: "json", Version: VersionConstraint{Min: "2"}}}})
+	s.AddIncompatibility(Incompatibility{Terms: []Term{{Package: "json", Version: VersionConstraint{Min: "2"}, Negated: true}}})
+	if _, err := s.Solve(); err == nil {
+		t.Fatal("Expected contradictory units to fail")
+	}
+	conflict := s.GetLastConflict()
+	if conflict == nil || len(conflict.Derivation) != 1 || conflict.Derivation[0].String() != "{not json >=2}" {
+		t.Errorf("Expected the contradicting unit as the derivation, got %+v", conflict)
+	}
+}
+
+func TestIncompatibilityDerivation(t *testing.T) {
+	external := Incompatibility{Terms: []Term{{Package: "web", Version: VersionConstraint{Specific: "2.0"}}, {Package: "json", Version: VersionConstraint{Max: "2"}, Negated: true}}}
+	derived := Incompatibility{Terms: []Term{{Package: "web", Version: VersionConstraint{Specific: "2.0"}}}, Cause: &external}
+	chain := derived.Derivation()
+	if len(chain) != 2 || chain[0].String() != derived.String() || chain[1].String() != external.String() {
+		t.Errorf("Unexpected derivation %v", chain)
+	}
+}
+
+func TestConflictReport(t *testing.T) {
+	provider := tableProvider{
+		"web":  {"2.0": {"json": {Max: "2"}}},
+		"json": {"1.0": nil, "2.0": nil},
+	}
+	s := NewSolver("app", "1.0.0")
+	s.SetProvider(provider)
+	s.AddRootDependency("web", VersionConstraint{})
+	s.AddRootDependency("json", VersionConstraint{Min: "2"})
+	if _, err := s.Solve(); err == nil {
+		t.Fatal("Expected resolution to fail")
+	}
+	report := s.GetLastConflict().Report()
+	root := report.Incompatibilities[report.RootCause]
+	if root.Kind != "dependency" || root.Text != "{web 2.0, not json <2}" {
+		t.Errorf("Unexpected root cause %+v", root)
+	}
+	kinds := map[string]int{}
+	for _, incompatibility := range report.Incompatibilities {
+		kinds[incompatibility.Kind]++
+	}
+	if kinds["root"] != 2 {
+		t.Errorf("Expected both root requirements in the report, got %+v", report.Incompatibilities)
+	}
+	for _, pkg := range report.Packages {
+		for _, assignment := range pkg.Assignments {
+			if assignment.CausedBy != nil && report.Incompatibilities[*assignment.CausedBy].Kind != "root" {
+				t.Errorf("%s should be derived from a root requirement, got %+v", pkg.Name, report.Incompatibilities[*assignment.CausedBy])
+			}
+		}
+	}
+	if len(report.Packages) != 2 {
+		t.Errorf("Expected web and json in the report, got %+v", report.Packages)
+	}
+}
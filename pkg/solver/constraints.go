@@ -0,0 +1,40 @@
+package solver
+
+import "rimraf-adi.com/zephyr/pkg/pkgname"
+
+// Constraints limit the versions of packages without requiring them, like
+// pip's -c files: a constraint only takes effect once something depends on
+// its package, and then pins or bounds the version chosen for it.
+
+// AddConstraint limits name to the versions constraint allows whenever it is
+// part of the solution. Package names are compared case-insensitively.
+func (s *Solver) AddConstraint(name string, constraint VersionConstraint) {
+	if s.constraints == nil {
+		s.constraints = make(map[string]VersionConstraint)
+	}
+	s.constraints[pkgname.Normalize(name)] = constraint
+}
+
+// constrainVersion applies the constraint on packageName, or its base
+// package for an extra, to the version chosen for term. A pin replaces the
+// choice, and "" is returned when the two cannot agree.
+func (s *Solver) constrainVersion(packageName string, term Term, version string) string {
+	base, _, _ := SplitExtraPackage(packageName)
+	constraint, ok := s.constraints[pkgname.Normalize(base)]
+	if !ok || version == "" {
+		return version
+	}
+	if constraint.IsSpecific() {
+		if term.Version.IsSpecific() && !constraint.Allows(term.Version.Specific) {
+			return ""
+		}
+		if !term.Version.IsSpecific() && !term.Version.Allows(constraint.Specific) {
+			return ""
+		}
+		return constraint.Specific
+	}
+	if !constraint.Allows(version) {
+		return ""
+	}
+	return version
+}
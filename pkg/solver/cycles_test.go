@@ -0,0 +1,131 @@
+package solver
+
+import (
+	"testing"
+	"time"
+)
+
+// solveWithTimeout fails the test instead of hanging if Solve does not terminate
+func solveWithTimeout(t *testing.T, s *Solver) (*PartialSolution, error) {
+	t.Helper()
+	type result struct {
+		solution *PartialSolution
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		solution, err := s.Solve()
+		done <- result{solution, err}
+	}()
+	select {
+	case r := <-done:
+		return r.solution, r.err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Solve did not terminate")
+		return nil, nil
+	}
+}
+
+// dependsOn builds the incompatibility "pkg (any version) depends on dep"
+func dependsOn(pkg, dep string) Incompatibility {
+	return Incompatibility{Terms: []Term{
+		{Package: pkg, Version: VersionConstraint{}},
+		{Package: dep, Version: VersionConstraint{}, Negated: true},
+	}}
+}
+
+func decidedPackages(solution *PartialSolution) map[string]string {
+	decided := make(map[string]string)
+	for _, assignment := range solution.Assignments {
+		if assignment.IsDecision {
+			decided[assignment.Term.Package] = assignment.Term.Version.String()
+		}
+	}
+	return decided
+}
+
+func TestSolver_MutualDependencyCycle(t *testing.T) {
+	s := NewSolver("root", "1.0.0")
+	s.AddRootDependency("a", VersionConstraint{})
+	s.AddIncompatibility(dependsOn("a", "b"))
+	s.AddIncompatibility(dependsOn("b", "c"))
+	s.AddIncompatibility(dependsOn("c", "a"))
+	solution, err := solveWithTimeout(t, s)
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	decided := decidedPackages(solution)
+	for _, pkg := range []string{"root", "a", "b", "c"} {
+		if _, ok := decided[pkg]; !ok {
+			t.Errorf("Expected %s in solution, got %v", pkg, decided)
+		}
+	}
+	if len(decided) != 4 {
+		t.Errorf("Expected exactly 4 packages, got %v", decided)
+	}
+}
+
+func TestSolver_SelfReference(t *testing.T) {
+	s := NewSolver("root", "1.0.0")
+	s.AddRootDependency("a", VersionConstraint{Specific: "1.0.0"})
+	// a 1.0.0 depends on a >=1.0.0, which it satisfies itself
+	s.AddIncompatibility(Incompatibility{Terms: []Term{
+		{Package: "a", Version: VersionConstraint{Specific: "1.0.0"}},
+		{Package: "a", Version: VersionConstraint{Min: "1.0.0"}, Negated: true},
+	}})
+	if len(s.GetIncompatibilities()) != 1 {
+		t.Errorf("Expected satisfied self-reference to be dropped, got %v", s.GetIncompatibilities())
+	}
+	solution, err := solveWithTimeout(t, s)
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	if decidedPackages(solution)["a"] != "1.0.0" {
+		t.Errorf("Expected a 1.0.0, got %v", decidedPackages(solution))
+	}
+}
+
+func TestSolver_UnsatisfiableSelfReference(t *testing.T) {
+	s := NewSolver("root", "1.0.0")
+	s.AddRootDependency("a", VersionConstraint{Specific: "2.0.0"})
+	// a 2.0.0 depends on a <2.0.0 and can therefore never be selected
+	s.AddIncompatibility(Incompatibility{Terms: []Term{
+		{Package: "a", Version: VersionConstraint{Specific: "2.0.0"}},
+		{Package: "a", Version: VersionConstraint{Max: "2.0.0"}, Negated: true},
+	}})
+	if _, err := solveWithTimeout(t, s); err == nil {
+		t.Error("Expected solving to fail for unsatisfiable self-reference")
+	}
+}
+
+func TestSolver_ExtrasCycle(t *testing.T) {
+	s := NewSolver("root", "1.0.0")
+	s.AddRootDependency("pkg[test]", VersionConstraint{})
+	// pkg[test] depends on helper, which depends back on pkg
+	s.AddExtraDependency("pkg", "1.0.0", "test", Term{Package: "helper"})
+	s.AddIncompatibility(dependsOn("helper", "pkg"))
+	solution, err := solveWithTimeout(t, s)
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	decided := decidedPackages(solution)
+	for _, pkg := range []string{"pkg", "pkg[test]", "helper"} {
+		if decided[pkg] != "1.0.0" {
+			t.Errorf("Expected %s 1.0.0, got %v", pkg, decided)
+		}
+	}
+}
+
+func TestSolver_CycleIntoConflictTerminates(t *testing.T) {
+	s := NewSolver("root", "1.0.0")
+	s.AddRootDependency("a", VersionConstraint{})
+	s.AddIncompatibility(dependsOn("a", "b"))
+	// b cannot be installed together with a
+	s.AddIncompatibility(Incompatibility{Terms: []Term{
+		{Package: "b", Version: VersionConstraint{}},
+		{Package: "a", Version: VersionConstraint{}},
+	}})
+	if _, err := solveWithTimeout(t, s); err == nil {
+		t.Error("Expected conflict error, got nil")
+	}
+}
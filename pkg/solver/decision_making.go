@@ -1,7 +1,11 @@
 package solver
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
+
+	"rimraf-adi.com/zephyr/pkg/progress"
 )
 
 // DecisionResult represents the result of decision making
@@ -9,12 +13,17 @@ type DecisionResult struct {
 	Success bool
 	NextPackage string
 	Error string
+	// Aborted is set when the provider could not be asked about a package
+	Aborted error
 }
 
 // DecisionMaking performs decision making to choose the next package version
 func (s *Solver) DecisionMaking() DecisionResult {
 	// Find a package with a positive derivation but no decision
-	packageName := s.findPackageForDecision()
+	packageName, err := s.findPackageForDecision()
+	if err != nil {
+		return DecisionResult{Aborted: err}
+	}
 	if packageName == "" {
 		// No more decisions to make - we have a solution
 		return DecisionResult{Success: true}
@@ -30,18 +39,30 @@ func (s *Solver) DecisionMaking() DecisionResult {
 	}
 	
 	// Find a version that matches the term
-	version := s.findMatchingVersion(packageName, *term)
+	candidate, err := s.findMatchingVersion(packageName, *term)
+	if err != nil {
+		return DecisionResult{Aborted: err}
+	}
+	version := s.constrainVersion(packageName, *term, candidate)
+	if version != "" && s.partialSolution.Satisfies(Term{Package: packageName, Version: VersionConstraint{Specific: version}}) == Contradicted {
+		// The candidate was already ruled out, e.g. by a cycle that led back to this package
+		version = ""
+	}
 	if version == "" {
-		// No matching version found - add an incompatibility
+		// No matching version found - add an incompatibility. Candidates were
+		// filtered by the current decisions, which conflict resolution never
+		// undoes short of failing, so it holds for the rest of the solve.
 		incompatibility := Incompatibility{
 			Terms: []Term{*term},
 		}
-		s.incompatibilities = append(s.incompatibilities, incompatibility)
+		s.addIncompatibility(incompatibility)
 		return DecisionResult{NextPackage: packageName}
 	}
 	
 	// Add dependencies for this version
-	s.addDependenciesForVersion(packageName, version)
+	if err := s.addDependenciesForVersion(packageName, version); err != nil {
+		return DecisionResult{Aborted: err}
+	}
 	
 	// Create the decision assignment
 	decisionTerm := Term{
@@ -58,90 +79,139 @@ func (s *Solver) DecisionMaking() DecisionResult {
 	}
 	
 	s.partialSolution.AddAssignment(assignment)
+	s.progress.Emit(progress.Event{Kind: progress.PackageDecided, Package: packageName, Version: version})
 	
 	return DecisionResult{NextPackage: packageName}
 }
 
-// findPackageForDecision finds a package that needs a decision
-func (s *Solver) findPackageForDecision() string {
+// findPackageForDecision finds a package that needs a decision. As in the
+// PubGrub reference implementation the one with the fewest versions left to
+// try goes first, so dead ends are found before effort is spent elsewhere;
+// without a provider the counts are unknown and every package ties. Ties go
+// to the lowest tieBreakKey, so the choice depends on the packages involved
+// and not on the order their terms were derived in.
+func (s *Solver) findPackageForDecision() (string, error) {
+	decided := make(map[string]bool)
+	for _, assignment := range s.partialSolution.Assignments {
+		if assignment.IsDecision {
+			decided[assignment.Term.Package] = true
+		}
+	}
+	
 	// Look for packages that have positive derivations but no decisions
+	best, bestCount, bestKey := "", 0, ""
 	for _, assignment := range s.partialSolution.Assignments {
-		if !assignment.IsDecision && !assignment.Term.Negated {
-			// Check if we already have a decision for this package
-			hasDecision := false
-			for _, otherAssignment := range s.partialSolution.Assignments {
-				if otherAssignment.IsDecision && otherAssignment.Term.Package == assignment.Term.Package {
-					hasDecision = true
-					break
-				}
-			}
-			
-			if !hasDecision {
-				return assignment.Term.Package
-			}
+		name := assignment.Term.Package
+		if assignment.IsDecision || assignment.Term.Negated || decided[name] {
+			continue
+		}
+		// Count each package once
+		decided[name] = true
+		count, err := s.remainingVersions(name)
+		if err != nil {
+			return "", err
+		}
+		key := s.tieBreakKey(name)
+		if best == "" || count < bestCount || (count == bestCount && key < bestKey) {
+			best, bestCount, bestKey = name, count, key
 		}
 	}
 	
-	return ""
+	return best, nil
 }
 
-// getTermForPackage gets the term for a package from the partial solution
+// remainingVersions counts the versions of packageName the solver could
+// still choose. Counts are cached until the package's assignments change, so
+// each decision only recounts the packages the last step touched.
+func (s *Solver) remainingVersions(packageName string) (int, error) {
+	if s.provider == nil {
+		return 0, nil
+	}
+	revision := s.partialSolution.revision(packageName)
+	if cached, ok := s.remaining[packageName]; ok && cached.revision == revision {
+		return cached.count, nil
+	}
+	term := s.getTermForPackage(packageName)
+	if term == nil {
+		return 0, nil
+	}
+	candidates, err := s.candidateVersions(packageName, *term)
+	if err != nil {
+		return 0, err
+	}
+	if s.remaining == nil {
+		s.remaining = make(map[string]remainingCount)
+	}
+	s.remaining[packageName] = remainingCount{revision: revision, count: len(candidates)}
+	return len(candidates), nil
+}
+
+// SetSeed makes decisions break ties between packages in a pseudo-random
+// order derived from seed instead of by name. The same seed always gives
+// the same order; 0 restores ordering by name.
+func (s *Solver) SetSeed(seed int64) {
+	s.seed = seed
+}
+
+// tieBreakKey orders packages that are equally good to decide next: by name,
+// or by a hash of the seed and name when a seed is set
+func (s *Solver) tieBreakKey(packageName string) string {
+	if s.seed == 0 {
+		return packageName
+	}
+	h := fnv.New64a()
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], uint64(s.seed))
+	h.Write(seed[:])
+	h.Write([]byte(packageName))
+	return fmt.Sprintf("%016x%s", h.Sum64(), packageName)
+}
+
+// getTermForPackage returns the intersection of the positive terms the
+// partial solution holds for a package, or nil if it holds none
 func (s *Solver) getTermForPackage(packageName string) *Term {
-	// Find all assignments for this package
-	var terms []Term
+	var term *Term
 	for _, assignment := range s.partialSolution.Assignments {
-		if assignment.Term.Package == packageName {
-			terms = append(terms, assignment.Term)
+		if assignment.Term.Package != packageName || assignment.Term.Negated {
+			continue
+		}
+		if term == nil {
+			term = &Term{Package: packageName, Version: assignment.Term.Version}
+		} else {
+			term.Version = term.Version.intersect(assignment.Term.Version)
 		}
 	}
-	
-	if len(terms) == 0 {
-		return nil
-	}
-	
-	// For now, just return the first term
-	// In a full implementation, we would intersect all terms
-	return &terms[0]
+	return term
 }
 
 // findMatchingVersion finds a version that matches the given term
-func (s *Solver) findMatchingVersion(packageName string, term Term) string {
-	// This is a simplified implementation
-	// In a real implementation, this would query the package registry
-	// and find a version that satisfies the term
+func (s *Solver) findMatchingVersion(packageName string, term Term) (string, error) {
+	if s.provider != nil {
+		return s.providedVersion(packageName, term)
+	}
 	
-	// For now, just return a dummy version
+	// Without a provider only the incompatibilities say which versions exist
 	if term.Version.IsSpecific() {
-		return term.Version.Specific
+		return term.Version.Specific, nil
+	}
+	if s.objective == ObjectiveMinimizePackages {
+		if version := s.minimalVersion(packageName, term); version != "" {
+			return version, nil
+		}
 	}
 	
 	// Return a default version
-	return "1.0.0"
+	return "1.0.0", nil
 }
 
 // addDependenciesForVersion adds dependencies for a specific version
-func (s *Solver) addDependenciesForVersion(packageName, version string) {
-	// This is a simplified implementation
-	// In a real implementation, this would:
-	// 1. Query the package registry for dependencies
-	// 2. Convert dependencies to incompatibilities
-	// 3. Add them to the solver
-	
-	// For now, just add a dummy incompatibility
-	dependency := Incompatibility{
-		Terms: []Term{
-			{
-				Package: packageName,
-				Version: VersionConstraint{Specific: version},
-				Negated: false,
-			},
-			{
-				Package: "dependency",
-				Version: VersionConstraint{Min: "1.0.0"},
-				Negated: true,
-			},
-		},
+func (s *Solver) addDependenciesForVersion(packageName, version string) error {
+	// Extra pseudo-packages always require the same version of their base package
+	if _, _, ok := SplitExtraPackage(packageName); ok {
+		s.addIncompatibility(extraBaseIncompatibility(packageName, version))
 	}
-	
-	s.incompatibilities = append(s.incompatibilities, dependency)
+	if s.provider != nil {
+		return s.addProvidedDependencies(packageName, version)
+	}
+	return nil
 } 
\ No newline at end of file
@@ -35,9 +35,6 @@ type DerivationNode struct {
 
 // buildDerivationGraph builds the derivation graph for an incompatibility
 func (s *Solver) buildDerivationGraph(root Incompatibility) *DerivationNode {
-	// This is a simplified implementation
-	// In the full algorithm, this would traverse the cause relationships
-	
 	node := &DerivationNode{
 		Incompatibility: root,
 		Causes:          []*DerivationNode{},
@@ -45,8 +42,9 @@ func (s *Solver) buildDerivationGraph(root Incompatibility) *DerivationNode {
 		LineNumber:      0,
 	}
 	
-	// Count outgoing edges
+	// Follow the cause the incompatibility was derived from
 	if root.Cause != nil {
+		node.Causes = append(node.Causes, s.buildDerivationGraph(*root.Cause))
 		node.OutgoingEdges++
 	}
 	
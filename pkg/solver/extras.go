@@ -0,0 +1,123 @@
+package solver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+)
+
+// Extras are modelled as synthetic packages: "requests[socks]" is a package of its own whose
+// every version depends on exactly the same version of "requests" plus the extra's
+// requirements. Conflicts between extras therefore surface during solving instead of
+// being flattened into the base package up front.
+
+// ExtraPackage returns the name of the pseudo-package for base with extra enabled
+func ExtraPackage(base, extra string) string {
+	return fmt.Sprintf("%s[%s]", base, strings.ToLower(strings.TrimSpace(extra)))
+}
+
+// SplitExtraPackage splits an extra pseudo-package name into its base package and extra
+func SplitExtraPackage(name string) (string, string, bool) {
+	open := strings.Index(name, "[")
+	if open <= 0 || !strings.HasSuffix(name, "]") {
+		return name, "", false
+	}
+	extra := strings.TrimSpace(name[open+1 : len(name)-1])
+	if extra == "" || strings.Contains(extra, ",") {
+		return name, "", false
+	}
+	return strings.TrimSpace(name[:open]), extra, true
+}
+
+// ExpandExtras turns a requirement name such as "requests[socks,security]" into one
+// pseudo-package per extra, or returns the name unchanged when it has no extras
+func ExpandExtras(name string) []string {
+	open := strings.Index(name, "[")
+	if open <= 0 || !strings.HasSuffix(name, "]") {
+		return []string{name}
+	}
+	base := strings.TrimSpace(name[:open])
+	var names []string
+	for _, extra := range strings.Split(name[open+1:len(name)-1], ",") {
+		if strings.TrimSpace(extra) != "" {
+			names = append(names, ExtraPackage(base, extra))
+		}
+	}
+	if len(names) == 0 {
+		return []string{base}
+	}
+	return names
+}
+
+// AddRootDependency records that the root package requires name within constraint.
+// Names with extras are expanded into their pseudo-packages.
+func (s *Solver) AddRootDependency(name string, constraint VersionConstraint) {
+	if s.rootNames == nil {
+		s.rootNames = make(map[string]string)
+	}
+	base := strings.TrimSpace(strings.SplitN(name, "[", 2)[0])
+	s.rootNames[pkgname.Normalize(base)] = base
+	for _, pkg := range ExpandExtras(name) {
+		s.AddIncompatibility(Incompatibility{
+			Terms: []Term{
+				{Package: s.rootPackage, Version: VersionConstraint{Specific: s.rootVersion}},
+				{Package: pkg, Version: constraint, Negated: true},
+			},
+		})
+	}
+}
+
+// rootSpelling spells a dependency name the way the root requires the same
+// package, so that "Django" from the project and "django" from an index stay
+// one package
+func (s *Solver) rootSpelling(name string) string {
+	base, extras := name, ""
+	if open := strings.Index(name, "["); open > 0 {
+		base, extras = name[:open], name[open:]
+	}
+	if spelling, ok := s.rootNames[pkgname.Normalize(base)]; ok {
+		return spelling + extras
+	}
+	return name
+}
+
+// AddExtraDependency records that version of base, with extra enabled, requires dependency
+func (s *Solver) AddExtraDependency(base, version, extra string, dependency Term) {
+	dependency.Negated = true
+	s.AddIncompatibility(Incompatibility{
+		Terms: []Term{
+			{Package: ExtraPackage(base, extra), Version: VersionConstraint{Specific: version}},
+			dependency,
+		},
+	})
+}
+
+// extraBaseIncompatibility pins an extra pseudo-package to the same version of its base package
+func extraBaseIncompatibility(name, version string) Incompatibility {
+	base, _, _ := SplitExtraPackage(name)
+	return Incompatibility{
+		Terms: []Term{
+			{Package: name, Version: VersionConstraint{Specific: version}},
+			{Package: base, Version: VersionConstraint{Specific: version}, Negated: true},
+		},
+	}
+}
+
+// Extras returns the extras selected for each base package in the solution
+func (ps *PartialSolution) Extras() map[string][]string {
+	extras := make(map[string][]string)
+	for _, assignment := range ps.Assignments {
+		if !assignment.IsDecision {
+			continue
+		}
+		if base, extra, ok := SplitExtraPackage(assignment.Term.Package); ok {
+			extras[base] = append(extras[base], extra)
+		}
+	}
+	for base := range extras {
+		sort.Strings(extras[base])
+	}
+	return extras
+}
@@ -0,0 +1,58 @@
+package solver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitAndExpandExtras(t *testing.T) {
+	base, extra, ok := SplitExtraPackage("requests[socks]")
+	if !ok || base != "requests" || extra != "socks" {
+		t.Errorf("Unexpected split: %s, %s, %v", base, extra, ok)
+	}
+	if _, _, ok := SplitExtraPackage("requests"); ok {
+		t.Error("Plain package should not be an extra pseudo-package")
+	}
+	got := ExpandExtras("requests[socks, Security]")
+	want := []string{"requests[socks]", "requests[security]"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got := ExpandExtras("requests"); !reflect.DeepEqual(got, []string{"requests"}) {
+		t.Errorf("Expected plain name unchanged, got %v", got)
+	}
+}
+
+func TestAddRootDependencyWithExtras(t *testing.T) {
+	s := NewSolver("app", "1.0.0")
+	s.AddRootDependency("requests[socks,security]", VersionConstraint{Min: "2.0.0"})
+	incs := s.GetIncompatibilities()
+	if len(incs) != 2 {
+		t.Fatalf("Expected one incompatibility per extra, got %d", len(incs))
+	}
+	if incs[1].String() != "{app 1.0.0, not requests[security] >=2.0.0}" {
+		t.Errorf("Unexpected incompatibility %s", incs[1])
+	}
+}
+
+func TestDecisionOnExtraPinsBasePackage(t *testing.T) {
+	s := NewSolver("app", "1.0.0")
+	s.partialSolution.AddAssignment(Assignment{
+		Term:       Term{Package: "requests[socks]", Version: VersionConstraint{Specific: "2.31.0"}},
+		IsDecision: false,
+	})
+	s.DecisionMaking()
+	found := false
+	for _, inc := range s.GetIncompatibilities() {
+		if inc.String() == "{requests[socks] 2.31.0, not requests 2.31.0}" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected extra to be pinned to its base version, got %v", s.GetIncompatibilities())
+	}
+	extras := s.GetSolution().Extras()
+	if !reflect.DeepEqual(extras, map[string][]string{"requests": {"socks"}}) {
+		t.Errorf("Unexpected extras %v", extras)
+	}
+}
@@ -0,0 +1,90 @@
+package solver
+
+import (
+	"fmt"
+
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// Objective selects which of several valid solutions the solver prefers
+type Objective int
+
+const (
+	// ObjectiveDefault takes the first version that satisfies each term
+	ObjectiveDefault Objective = iota
+	// ObjectiveMinimizePackages prefers the versions that pull in the fewest
+	// packages not already in the solution. Extras are packages of their own,
+	// so versions requiring optional extras are avoided too.
+	ObjectiveMinimizePackages
+)
+
+// ParseObjective parses the value of --minimize: "" for the default, or "packages"
+func ParseObjective(value string) (Objective, error) {
+	switch value {
+	case "":
+		return ObjectiveDefault, nil
+	case "packages":
+		return ObjectiveMinimizePackages, nil
+	}
+	return ObjectiveDefault, fmt.Errorf("unknown objective %q (expected packages)", value)
+}
+
+// SetObjective sets how the solver chooses between versions that all satisfy a term
+func (s *Solver) SetObjective(objective Objective) {
+	s.objective = objective
+}
+
+// minimalVersion returns the version of packageName allowed by term whose
+// dependencies add the fewest new packages, preferring the newest on a tie.
+// Only versions named in incompatibilities are candidates; "" means none was.
+func (s *Solver) minimalVersion(packageName string, term Term) string {
+	best, bestCost := "", 0
+	for _, candidate := range s.knownVersions(packageName) {
+		if !term.Version.Allows(candidate) {
+			continue
+		}
+		cost := s.newDependencies(packageName, candidate)
+		if best == "" || cost < bestCost || (cost == bestCost && version.Compare(candidate, best) > 0) {
+			best, bestCost = candidate, cost
+		}
+	}
+	return best
+}
+
+// knownVersions lists the specific versions of packageName that incompatibilities mention
+func (s *Solver) knownVersions(packageName string) []string {
+	seen := make(map[string]bool)
+	var versions []string
+	for _, incompatibility := range s.incompatibilities {
+		for _, term := range incompatibility.Terms {
+			if term.Package == packageName && !term.Negated && term.Version.IsSpecific() && !seen[term.Version.Specific] {
+				seen[term.Version.Specific] = true
+				versions = append(versions, term.Version.Specific)
+			}
+		}
+	}
+	return versions
+}
+
+// newDependencies counts the packages that choosing version of packageName
+// would require and that the partial solution does not already include
+func (s *Solver) newDependencies(packageName, version string) int {
+	added := make(map[string]bool)
+	for _, incompatibility := range s.incompatibilities {
+		if len(incompatibility.Terms) != 2 {
+			continue
+		}
+		dependent, dependency := incompatibility.Terms[0], incompatibility.Terms[1]
+		if dependent.Negated {
+			dependent, dependency = dependency, dependent
+		}
+		if dependent.Negated || !dependency.Negated || dependent.Package != packageName || !dependent.Version.Allows(version) {
+			continue
+		}
+		if dependency.Package == packageName || s.partialSolution.Satisfies(Term{Package: dependency.Package}) == Satisfied {
+			continue
+		}
+		added[dependency.Package] = true
+	}
+	return len(added)
+}
@@ -0,0 +1,287 @@
+package solver
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/pkgname"
+	"rimraf-adi.com/zephyr/pkg/version"
+)
+
+// Provider tells the solver which versions of a package exist and what each
+// of them depends on. It is the only thing tying the solver to an ecosystem:
+// PyPI, a lockfile or an in-memory table for tests all work the same way.
+type Provider interface {
+	// Versions lists the versions of name that may be chosen, in any order.
+	// Prereleases belong in the list: as PEP 440 asks, the solver only
+	// chooses one when a constraint names a prerelease or no final release
+	// is left.
+	Versions(name string) ([]string, error)
+	// Dependencies returns the packages version of name requires, keyed by
+	// name. Keys may carry extras ("requests[socks]"). For an extra
+	// pseudo-package it returns only what the extra adds; the solver pins
+	// the base package itself.
+	Dependencies(name, version string) (map[string]VersionConstraint, error)
+}
+
+// SetProvider makes the solver choose versions from provider and learn their
+// dependencies as it decides them. Without a provider every dependency must
+// be added as an incompatibility before Solve is called.
+func (s *Solver) SetProvider(provider Provider) {
+	s.provider = provider
+	s.providedVersions = make(map[string][]string)
+	s.providedDependencies = make(map[string]map[string]VersionConstraint)
+	s.dependenciesAdded = make(map[string]bool)
+}
+
+// versionsOf returns the provider's versions of packageName, newest first.
+// An extra pseudo-package has the versions of its base package.
+func (s *Solver) versionsOf(packageName string) ([]string, error) {
+	base, _, _ := SplitExtraPackage(packageName)
+	if versions, ok := s.providedVersions[base]; ok {
+		return versions, nil
+	}
+	versions, err := s.provider.Versions(base)
+	if err != nil {
+		return nil, err
+	}
+	versions = append([]string(nil), versions...)
+	sort.SliceStable(versions, func(i, j int) bool {
+		return version.Compare(versions[i], versions[j]) > 0
+	})
+	s.providedVersions[base] = versions
+	return versions, nil
+}
+
+// dependenciesOf returns the provider's dependencies of version of packageName
+func (s *Solver) dependenciesOf(packageName, version string) (map[string]VersionConstraint, error) {
+	key := packageName + "==" + version
+	if dependencies, ok := s.providedDependencies[key]; ok {
+		return dependencies, nil
+	}
+	dependencies, err := s.provider.Dependencies(packageName, version)
+	if err != nil {
+		return nil, err
+	}
+	s.providedDependencies[key] = dependencies
+	return dependencies, nil
+}
+
+// providedVersion picks the version of packageName to try next: the newest
+// one allowed by term, by everything already derived about the package and
+// by its constraint. Under ObjectiveMinimizePackages the one adding the
+// fewest packages wins instead, which fetches the dependencies of every
+// candidate. "" means no version is left.
+func (s *Solver) providedVersion(packageName string, term Term) (string, error) {
+	candidates, err := s.candidateVersions(packageName, term)
+	if err != nil {
+		return "", err
+	}
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	if s.objective != ObjectiveMinimizePackages {
+		return candidates[0], nil
+	}
+	best, bestCost := "", 0
+	for _, candidate := range candidates {
+		dependencies, err := s.dependenciesOf(packageName, candidate)
+		if err != nil {
+			return "", err
+		}
+		cost := 0
+		for name := range dependencies {
+			for _, pkg := range ExpandExtras(name) {
+				if s.partialSolution.Satisfies(Term{Package: pkg}) != Satisfied {
+					cost++
+				}
+			}
+		}
+		// Candidates are newest first, so ties keep the newest
+		if best == "" || cost < bestCost {
+			best, bestCost = candidate, cost
+		}
+	}
+	return best, nil
+}
+
+// candidateVersions returns the provider's versions of packageName allowed
+// by term, by everything already derived about the package and by its
+// constraint, newest first. Prereleases are left out unless one of those
+// names a prerelease or nothing else is left.
+func (s *Solver) candidateVersions(packageName string, term Term) ([]string, error) {
+	versions, err := s.versionsOf(packageName)
+	if err != nil {
+		return nil, err
+	}
+	base, _, _ := SplitExtraPackage(packageName)
+	constraint, constrained := s.constraints[pkgname.Normalize(base)]
+	var candidates []string
+	for _, candidate := range versions {
+		if !term.Version.Allows(candidate) || !s.allowedByAssignments(packageName, candidate) {
+			continue
+		}
+		if constrained && !constraint.Allows(candidate) {
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+	if term.Version.namesPrerelease() || constrained && constraint.namesPrerelease() || s.assignmentsNamePrerelease(packageName) {
+		return candidates, nil
+	}
+	var finals []string
+	for _, candidate := range candidates {
+		if !version.IsPrerelease(candidate) {
+			finals = append(finals, candidate)
+		}
+	}
+	if len(finals) == 0 {
+		return candidates, nil
+	}
+	return finals, nil
+}
+
+// assignmentsNamePrerelease reports whether a positive term the partial
+// solution holds for packageName names a prerelease
+func (s *Solver) assignmentsNamePrerelease(packageName string) bool {
+	for _, assignment := range s.partialSolution.Assignments {
+		if assignment.Term.Package == packageName && !assignment.Term.Negated && assignment.Term.Version.namesPrerelease() {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedByAssignments reports whether choosing version of packageName
+// agrees with every term the partial solution holds for it
+func (s *Solver) allowedByAssignments(packageName, version string) bool {
+	for _, assignment := range s.partialSolution.Assignments {
+		if assignment.Term.Package != packageName {
+			continue
+		}
+		if assignment.Term.Version.Allows(version) == assignment.Term.Negated {
+			return false
+		}
+	}
+	return true
+}
+
+// addProvidedDependencies adds an incompatibility for each dependency of
+// version of packageName, the first time that version is decided
+func (s *Solver) addProvidedDependencies(packageName, version string) error {
+	key := packageName + "==" + version
+	if s.dependenciesAdded[key] {
+		return nil
+	}
+	dependencies, err := s.dependenciesOf(packageName, version)
+	if err != nil {
+		return err
+	}
+	s.dependenciesAdded[key] = true
+	names := make([]string, 0, len(dependencies))
+	for name := range dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, pkg := range ExpandExtras(s.rootSpelling(name)) {
+			s.AddIncompatibility(Incompatibility{
+				Terms: []Term{
+					{Package: packageName, Version: VersionConstraint{Specific: version}},
+					{Package: pkg, Version: dependencies[name], Negated: true},
+				},
+			})
+		}
+	}
+	return nil
+}
+
+// ParseConstraint converts a PEP 440 specifier such as ">=1.2,<2" into a
+// VersionConstraint. ~= and ==X.* become ranges, a bare version or == pins
+// it and != clauses become exclusions.
+func ParseConstraint(specifier string) VersionConstraint {
+	var vc VersionConstraint
+	specifier = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(specifier), "("), ")")
+	for _, clause := range strings.Split(specifier, ",") {
+		clause = strings.TrimSpace(clause)
+		switch {
+		case clause == "" || clause == "*":
+		case strings.HasPrefix(clause, "!="):
+			vc.Exclude = append(vc.Exclude, strings.TrimSpace(clause[2:]))
+		case strings.HasPrefix(clause, "==="):
+			vc.Specific = strings.TrimSpace(clause[3:])
+		case strings.HasPrefix(clause, "~="):
+			v := strings.TrimSpace(clause[2:])
+			vc.raiseMin(v, false)
+			if upper, ok := bumpRelease(v, 2); ok {
+				vc.lowerMax(upper, false)
+			}
+		case strings.HasPrefix(clause, "=="):
+			v := strings.TrimSpace(clause[2:])
+			if prefix := strings.TrimSuffix(v, ".*"); prefix != v {
+				vc.raiseMin(prefix, false)
+				if upper, ok := bumpRelease(prefix, 1); ok {
+					vc.lowerMax(upper, false)
+				}
+			} else {
+				vc.Specific = v
+			}
+		case strings.HasPrefix(clause, ">="):
+			vc.raiseMin(strings.TrimSpace(clause[2:]), false)
+		case strings.HasPrefix(clause, "<="):
+			vc.lowerMax(strings.TrimSpace(clause[2:]), true)
+		case strings.HasPrefix(clause, ">"):
+			vc.raiseMin(strings.TrimSpace(clause[1:]), true)
+		case strings.HasPrefix(clause, "<"):
+			vc.lowerMax(strings.TrimSpace(clause[1:]), false)
+		default:
+			vc.Specific = clause
+		}
+	}
+	return vc
+}
+
+// raiseMin tightens the lower bound to v if that is stricter
+func (vc *VersionConstraint) raiseMin(v string, exclusive bool) {
+	cmp := 1
+	if vc.Min != "" {
+		cmp = version.Compare(v, vc.Min)
+	}
+	if cmp > 0 {
+		vc.Min, vc.MinExclusive = v, exclusive
+	} else if cmp == 0 && exclusive {
+		vc.MinExclusive = true
+	}
+}
+
+// lowerMax tightens the upper bound to v if that is stricter
+func (vc *VersionConstraint) lowerMax(v string, inclusive bool) {
+	cmp := -1
+	if vc.Max != "" {
+		cmp = version.Compare(v, vc.Max)
+	}
+	if cmp < 0 {
+		vc.Max, vc.MaxInclusive = v, inclusive
+	} else if cmp == 0 && !inclusive {
+		vc.MaxInclusive = false
+	}
+}
+
+// bumpRelease drops the last drop-1 components of v's release and increments
+// the one before: bumpRelease("2.1.3", 2) is "2.2", bumpRelease("2.1", 1) is "2.2"
+func bumpRelease(v string, drop int) (string, bool) {
+	parsed, err := version.Parse(v)
+	if err != nil || len(parsed.Release) < drop {
+		return "", false
+	}
+	release := parsed.Release[:len(parsed.Release)-drop+1]
+	parts := make([]string, len(release))
+	for i, n := range release {
+		if i == len(release)-1 {
+			n++
+		}
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, "."), true
+}
@@ -0,0 +1,271 @@
+package solver
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// tableProvider is a Provider backed by a map of name -> version -> dependencies
+type tableProvider map[string]map[string]map[string]VersionConstraint
+
+func (p tableProvider) Versions(name string) ([]string, error) {
+	releases, ok := p[name]
+	if !ok {
+		return nil, errors.New("no such package " + name)
+	}
+	var versions []string
+	for v := range releases {
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+func (p tableProvider) Dependencies(name, version string) (map[string]VersionConstraint, error) {
+	return p[name][version], nil
+}
+
+func decisions(solution *PartialSolution) map[string]string {
+	decided := make(map[string]string)
+	for _, assignment := range solution.Assignments {
+		if assignment.IsDecision {
+			decided[assignment.Term.Package] = assignment.Term.Version.Specific
+		}
+	}
+	return decided
+}
+
+func TestSolveWithProvider(t *testing.T) {
+	provider := tableProvider{
+		"requests": {
+			"1.0.0":  nil,
+			"2.31.0": {"urllib3": {Min: "1.21.1", Max: "3"}, "idna": {Min: "2.5"}},
+			"2.32.0": {"urllib3": {Min: "1.21.1", Max: "3"}, "idna": {Min: "2.5"}},
+		},
+		"urllib3": {"1.26.18": nil, "2.2.1": nil, "3.0.0": nil},
+		"idna":    {"3.6": nil},
+	}
+	s := NewSolver("app", "1.0.0")
+	s.SetProvider(provider)
+	s.AddRootDependency("requests", VersionConstraint{Min: "2.0"})
+	s.AddConstraint("requests", VersionConstraint{Max: "2.32.0"})
+	solution, err := s.Solve()
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	want := map[string]string{"app": "1.0.0", "requests": "2.31.0", "urllib3": "2.2.1", "idna": "3.6"}
+	if got := decisions(solution); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestSolveWithProviderExtras(t *testing.T) {
+	provider := tableProvider{
+		"requests": {"2.31.0": nil},
+		"requests[socks]": {
+			"2.31.0": {"pysocks": {Min: "1.5.6"}},
+		},
+		"pysocks": {"1.7.1": nil},
+	}
+	s := NewSolver("app", "1.0.0")
+	s.SetProvider(extraVersions{provider})
+	s.AddRootDependency("requests[socks]", VersionConstraint{})
+	solution, err := s.Solve()
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	want := map[string]string{"app": "1.0.0", "requests[socks]": "2.31.0", "requests": "2.31.0", "pysocks": "1.7.1"}
+	if got := decisions(solution); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+// extraVersions fails the test's expectations if the solver asks for the
+// versions of an extra pseudo-package instead of its base package
+type extraVersions struct{ tableProvider }
+
+func (p extraVersions) Versions(name string) ([]string, error) {
+	if _, _, ok := SplitExtraPackage(name); ok {
+		return nil, errors.New("versions requested for extra " + name)
+	}
+	return p.tableProvider.Versions(name)
+}
+
+func TestSolveWithProviderError(t *testing.T) {
+	s := NewSolver("app", "1.0.0")
+	s.SetProvider(tableProvider{})
+	s.AddRootDependency("missing", VersionConstraint{})
+	if _, err := s.Solve(); err == nil || err.Error() != "no such package missing" {
+		t.Errorf("Expected the provider's error, got %v", err)
+	}
+}
+
+func TestSolveHonoursInclusiveAndExcludedBounds(t *testing.T) {
+	provider := tableProvider{
+		"pkg": {"1.4": nil, "1.5": nil, "1.6": nil},
+		"lib": {"2.0": nil, "2.1": nil, "2.2": nil},
+	}
+	s := NewSolver("app", "1.0.0")
+	s.SetProvider(provider)
+	s.AddRootDependency("pkg", ParseConstraint("<=1.5"))
+	s.AddRootDependency("lib", ParseConstraint(">2.0,!=2.2"))
+	solution, err := s.Solve()
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	want := map[string]string{"app": "1.0.0", "pkg": "1.5", "lib": "2.1"}
+	if got := decisions(solution); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestProvidedDependenciesUseRootSpelling(t *testing.T) {
+	provider := tableProvider{
+		"Django":     {"4.2": nil, "5.0": nil},
+		"app-plugin": {"1.0": {"django": {Max: "5"}}},
+	}
+	s := NewSolver("app", "1.0.0")
+	s.SetProvider(provider)
+	s.AddRootDependency("Django", VersionConstraint{})
+	s.AddRootDependency("app-plugin", VersionConstraint{})
+	solution, err := s.Solve()
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	want := map[string]string{"app": "1.0.0", "Django": "4.2", "app-plugin": "1.0"}
+	if got := decisions(solution); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected one Django package, got %v", got)
+	}
+}
+
+func TestPrereleasesOnlyWhenAskedFor(t *testing.T) {
+	provider := tableProvider{
+		"lib":  {"1.0": nil, "2.0b1": nil},
+		"beta": {"0.1a1": nil, "0.1a2": nil},
+	}
+	tests := []struct {
+		name       string
+		constraint VersionConstraint
+		want       string
+	}{
+		{"lib", VersionConstraint{}, "1.0"},
+		{"lib", ParseConstraint(">=1.0"), "1.0"},
+		{"lib", ParseConstraint("==2.0b1"), "2.0b1"},
+		{"lib", ParseConstraint(">=2.0b1"), "2.0b1"},
+		{"beta", VersionConstraint{}, "0.1a2"},
+	}
+	for _, tt := range tests {
+		s := NewSolver("app", "1.0.0")
+		s.SetProvider(provider)
+		s.AddRootDependency(tt.name, tt.constraint)
+		solution, err := s.Solve()
+		if err != nil {
+			t.Errorf("%s %s: Solve failed: %v", tt.name, tt.constraint, err)
+			continue
+		}
+		if got := decisions(solution)[tt.name]; got != tt.want {
+			t.Errorf("%s %s: expected %s, got %s", tt.name, tt.constraint, tt.want, got)
+		}
+	}
+}
+
+func TestParseConstraint(t *testing.T) {
+	tests := map[string]VersionConstraint{
+		"":                {},
+		">=1.2,<2":        {Min: "1.2", Max: "2"},
+		"<3, >=1.21.1":    {Min: "1.21.1", Max: "3"},
+		"(>=2.5)":         {Min: "2.5"},
+		">2.5":            {Min: "2.5", MinExclusive: true},
+		"<=1.5":           {Max: "1.5", MaxInclusive: true},
+		">=2,>2":          {Min: "2", MinExclusive: true},
+		"<=2,<2":          {Max: "2"},
+		"~=2.1":           {Min: "2.1", Max: "3"},
+		"~=2.1.3":         {Min: "2.1.3", Max: "2.2"},
+		"==1.4.*":         {Min: "1.4", Max: "1.5"},
+		"==1.4.2":         {Specific: "1.4.2"},
+		"===1.4.2":        {Specific: "1.4.2"},
+		">=1,!=1.5,>=1.3": {Min: "1.3", Exclude: []string{"1.5"}},
+		"!=1.5.*":         {Exclude: []string{"1.5.*"}},
+		"1.0":             {Specific: "1.0"},
+	}
+	for specifier, want := range tests {
+		if got := ParseConstraint(specifier); !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseConstraint(%q) = %+v, want %+v", specifier, got, want)
+		}
+	}
+}
+
+func TestConstraintOperators(t *testing.T) {
+	tests := []struct {
+		specifier string
+		allowed   []string
+		denied    []string
+	}{
+		{"<=1.5", []string{"1.4", "1.5"}, []string{"1.5.1", "2.0"}},
+		{"<1.5", []string{"1.4.9"}, []string{"1.5", "1.6"}},
+		{">1.5", []string{"1.5.1", "2.0"}, []string{"1.5", "1.4"}},
+		{">=1.5", []string{"1.5", "2.0"}, []string{"1.4"}},
+		{"==1.5", []string{"1.5", "1.5.0"}, []string{"1.5.1"}},
+		{"~=1.5.2", []string{"1.5.2", "1.5.9"}, []string{"1.5.1", "1.6"}},
+		{"==1.5.*", []string{"1.5", "1.5.3"}, []string{"1.4", "1.6"}},
+		{"!=1.5", []string{"1.4", "1.5.1"}, []string{"1.5"}},
+		{">=1,!=1.5.*", []string{"1.4", "1.6"}, []string{"1.5", "1.5.7", "0.9"}},
+		{"!=1.5,==1.5", nil, []string{"1.5"}},
+	}
+	for _, tt := range tests {
+		vc := ParseConstraint(tt.specifier)
+		for _, v := range tt.allowed {
+			if !vc.Allows(v) {
+				t.Errorf("%q (%s) should allow %s", tt.specifier, vc, v)
+			}
+		}
+		for _, v := range tt.denied {
+			if vc.Allows(v) {
+				t.Errorf("%q (%s) should not allow %s", tt.specifier, vc, v)
+			}
+		}
+	}
+}
+
+func TestDecisionPrefersFewestVersions(t *testing.T) {
+	provider := tableProvider{
+		"alpha": {"1.0": nil, "2.0": nil, "3.0": nil},
+		"beta":  {"1.0": nil, "2.0": nil, "3.0": nil},
+		"zeta":  {"1.0": nil, "2.0": nil},
+	}
+	s := NewSolver("app", "1.0.0")
+	s.SetProvider(provider)
+	s.AddRootDependency("alpha", VersionConstraint{})
+	s.AddRootDependency("beta", VersionConstraint{Min: "3.0"})
+	s.AddRootDependency("zeta", VersionConstraint{})
+	solution, err := s.Solve()
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	var order []string
+	for _, assignment := range solution.Assignments {
+		if assignment.IsDecision {
+			order = append(order, assignment.Term.Package)
+		}
+	}
+	if want := []string{"app", "beta", "zeta", "alpha"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("Expected the most constrained package first %v, got %v", want, order)
+	}
+}
+
+func TestRemainingVersionsFollowsAssignments(t *testing.T) {
+	s := NewSolver("app", "1.0.0")
+	s.SetProvider(tableProvider{"lib": {"1.0": nil, "2.0": nil, "3.0": nil}})
+	s.partialSolution.AddAssignment(Assignment{Term: Term{Package: "lib", Version: VersionConstraint{Min: "1.0"}}})
+	if count, _ := s.remainingVersions("lib"); count != 3 {
+		t.Fatalf("Expected 3 versions, got %d", count)
+	}
+	s.partialSolution.AddAssignment(Assignment{Term: Term{Package: "lib", Version: VersionConstraint{Max: "3.0"}}, DecisionLevel: 1})
+	if count, _ := s.remainingVersions("lib"); count != 2 {
+		t.Errorf("Expected the count to intersect both terms, got %d", count)
+	}
+	s.partialSolution.Backtrack(0)
+	if count, _ := s.remainingVersions("lib"); count != 3 {
+		t.Errorf("Expected the count to be refreshed after backtracking, got %d", count)
+	}
+}
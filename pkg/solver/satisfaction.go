@@ -11,35 +11,58 @@ const (
 
 // Satisfies checks if a set of terms satisfies another term
 func (ps *PartialSolution) Satisfies(term Term) SatisfactionResult {
-	// Check if any assignment contradicts the term
+	result := Inconclusive
 	for _, assignment := range ps.Assignments {
-		if assignment.Term.Package == term.Package {
-			if assignment.Term.Negated != term.Negated {
-				// One is positive, one is negative - check if they're compatible
-				if !areCompatible(assignment.Term.Version, term.Version) {
-					return Contradicted
-				}
-			} else {
-				// Both are positive or both are negative - check if they're compatible
-				if !areCompatible(assignment.Term.Version, term.Version) {
-					return Contradicted
-				}
-			}
+		if assignment.Term.Package != term.Package {
+			continue
+		}
+		switch termRelation(assignment.Term, term) {
+		case Contradicted:
+			return Contradicted
+		case Satisfied:
+			result = Satisfied
 		}
 	}
-	
-	// Check if any assignment satisfies the term
+	return result
+}
+
+// Contains reports whether the exact term has already been assigned
+func (ps *PartialSolution) Contains(term Term) bool {
 	for _, assignment := range ps.Assignments {
-		if assignment.Term.Package == term.Package {
-			if assignment.Term.Negated == term.Negated {
-				// Both are positive or both are negative - check if assignment satisfies term
-				if satisfies(assignment.Term.Version, term.Version) {
-					return Satisfied
-				}
-			}
+		if assignment.Term.Package == term.Package &&
+			assignment.Term.Negated == term.Negated &&
+			assignment.Term.Version.String() == term.Version.String() {
+			return true
 		}
 	}
-	
+	return false
+}
+
+// termRelation reports whether an assigned term satisfies or contradicts another term
+// for the same package
+func termRelation(assigned, term Term) SatisfactionResult {
+	// A positive specific version decides every term outright
+	if !assigned.Negated && assigned.Version.IsSpecific() {
+		if term.Version.Allows(assigned.Version.Specific) != term.Negated {
+			return Satisfied
+		}
+		return Contradicted
+	}
+	if assigned.Version.String() == term.Version.String() {
+		if assigned.Negated == term.Negated {
+			return Satisfied
+		}
+		return Contradicted
+	}
+	if !assigned.Negated && !term.Negated && term.Version.String() == "any" {
+		return Satisfied
+	}
+	if assigned.Negated && assigned.Version.String() == "any" {
+		if term.Negated {
+			return Satisfied
+		}
+		return Contradicted
+	}
 	return Inconclusive
 }
 
@@ -81,7 +104,8 @@ func (ps *PartialSolution) AlmostSatisfies(incompatibility Incompatibility) *Ter
 			satisfiedCount++
 		} else if result == Inconclusive {
 			if unsatisfiedTerm == nil {
-				unsatisfiedTerm = &term
+				inconclusive := term
+				unsatisfiedTerm = &inconclusive
 			}
 		}
 	}
@@ -92,32 +116,3 @@ func (ps *PartialSolution) AlmostSatisfies(incompatibility Incompatibility) *Ter
 	
 	return nil
 }
-
-// areCompatible checks if two version constraints are compatible
-func areCompatible(v1, v2 VersionConstraint) bool {
-	// If either is "any", they're compatible
-	if v1.String() == "any" || v2.String() == "any" {
-		return true
-	}
-	
-	// For now, assume they're compatible if they're not explicitly incompatible
-	// This is a simplified implementation
-	return true
-}
-
-// satisfies checks if v1 satisfies v2
-func satisfies(v1, v2 VersionConstraint) bool {
-	// If v2 is "any", v1 always satisfies it
-	if v2.String() == "any" {
-		return true
-	}
-	
-	// If v1 is "any", it satisfies everything
-	if v1.String() == "any" {
-		return true
-	}
-	
-	// For now, assume they satisfy if they're the same
-	// This is a simplified implementation
-	return v1.String() == v2.String()
-} 
\ No newline at end of file
@@ -2,6 +2,9 @@ package solver
 
 import (
 	"fmt"
+	"time"
+
+	"rimraf-adi.com/zephyr/pkg/progress"
 )
 
 // Solver represents the Pubgrub version solver
@@ -10,6 +13,36 @@ type Solver struct {
 	incompatibilities []Incompatibility
 	rootPackage string
 	rootVersion string
+	maxIterations int
+	timeout time.Duration
+	iterations int
+	started time.Time
+	// constraints limit package versions without requiring the packages
+	constraints map[string]VersionConstraint
+	// rootNames maps the normalized names of root dependencies to their spelling
+	rootNames map[string]string
+	objective Objective
+	// seed, when set, orders ties between packages pseudo-randomly
+	seed int64
+	// known holds the key of every incompatibility, to skip duplicates
+	known map[string]bool
+	// lastConflict is why the last Solve failed, if it did
+	lastConflict *Conflict
+	progress progress.Handler
+	// provider supplies versions and dependencies; the maps cache its answers
+	provider Provider
+	providedVersions map[string][]string
+	providedDependencies map[string]map[string]VersionConstraint
+	dependenciesAdded map[string]bool
+	// remaining caches remainingVersions per package
+	remaining map[string]remainingCount
+}
+
+// remainingCount is a cached version count, valid while the package's
+// partial solution revision is unchanged
+type remainingCount struct {
+	revision int
+	count int
 }
 
 // NewSolver creates a new solver instance
@@ -19,24 +52,54 @@ func NewSolver(rootPackage, rootVersion string) *Solver {
 		incompatibilities: []Incompatibility{},
 		rootPackage: rootPackage,
 		rootVersion: rootVersion,
+		maxIterations: DefaultMaxIterations,
+		timeout: DefaultTimeout,
 	}
 }
 
+// SetProgress makes the solver report when it starts, decides a package and finishes
+func (s *Solver) SetProgress(handler progress.Handler) {
+	s.progress = handler
+}
+
 // Solve performs version solving using the Pubgrub algorithm
 func (s *Solver) Solve() (*PartialSolution, error) {
+	s.progress.Emit(progress.Event{Kind: progress.ResolutionStarted, Package: s.rootPackage, Version: s.rootVersion})
+	solution, err := s.solve()
+	s.progress.Emit(progress.Event{Kind: progress.ResolutionFinished, Package: s.rootPackage, Version: s.rootVersion, Err: err})
+	return solution, err
+}
+
+// solve runs the main loop of Solve
+func (s *Solver) solve() (*PartialSolution, error) {
 	// Initialize the solver with the root package
+	s.startWatchdog()
+	s.lastConflict = nil
+	s.remaining = nil
 	s.initializeRootPackage()
 	
+	// Unit incompatibilities that contradict each other can never be satisfied
+	if a, b := s.findContradictoryUnits(); a != nil {
+		return nil, s.conflictError(*a, *b)
+	}
+	
 	// Set the next package to process
 	nextPackage := s.rootPackage
 	
 	// Main solving loop
 	for {
 		// Perform unit propagation
+		if err := s.tick(); err != nil {
+			return nil, err
+		}
+		
 		result := s.UnitPropagation(nextPackage)
+		if result.Aborted != nil {
+			return nil, result.Aborted
+		}
 		if !result.Success {
 			// Version solving has failed
-			return nil, fmt.Errorf("version solving failed: conflict detected")
+			return nil, s.conflictError(*result.Conflict)
 		}
 		
 		// Perform decision making
@@ -46,6 +109,10 @@ func (s *Solver) Solve() (*PartialSolution, error) {
 			return &s.partialSolution, nil
 		}
 		
+		if decisionResult.Aborted != nil {
+			return nil, decisionResult.Aborted
+		}
+		
 		if decisionResult.Error != "" {
 			return nil, fmt.Errorf("decision making failed: %s", decisionResult.Error)
 		}
@@ -80,36 +147,76 @@ func (s *Solver) initializeRootPackage() {
 
 // addRootIncompatibilities adds incompatibilities for the root package
 func (s *Solver) addRootIncompatibilities() {
-	// This is a simplified implementation
-	// In a real implementation, this would:
-	// 1. Read the root package's dependencies
-	// 2. Convert them to incompatibilities
-	// 3. Add them to the solver
-	
-	// For now, just add a dummy incompatibility
-	rootIncompatibility := Incompatibility{
-		Terms: []Term{
-			{
-				Package: s.rootPackage,
-				Version: VersionConstraint{Specific: s.rootVersion},
-				Negated: false,
-			},
-			{
-				Package: "dependency",
-				Version: VersionConstraint{Min: "1.0.0"},
-				Negated: true,
-			},
-		},
+	// The root package's dependencies are supplied by the caller through
+	// AddRootDependency or AddIncompatibility before Solve is called
+}
+
+// findContradictoryUnits returns two single-term incompatibilities forbidding a term and its negation
+func (s *Solver) findContradictoryUnits() (*Incompatibility, *Incompatibility) {
+	for i := range s.incompatibilities {
+		a := &s.incompatibilities[i]
+		if len(a.Terms) != 1 {
+			continue
+		}
+		for j := i + 1; j < len(s.incompatibilities); j++ {
+			b := &s.incompatibilities[j]
+			if len(b.Terms) == 1 && a.Terms[0].Package == b.Terms[0].Package &&
+				a.Terms[0].Negated != b.Terms[0].Negated &&
+				a.Terms[0].Version.String() == b.Terms[0].Version.String() {
+				return a, b
+			}
+		}
 	}
-	
-	s.incompatibilities = append(s.incompatibilities, rootIncompatibility)
+	return nil, nil
 }
 
-// AddIncompatibility adds an incompatibility to the solver
+// AddIncompatibility adds an incompatibility to the solver unless one with
+// the same terms, in any order, is already known
 func (s *Solver) AddIncompatibility(incompatibility Incompatibility) {
+	incompatibility, ok := normalizeSelfReference(incompatibility)
+	if !ok {
+		return
+	}
+	s.addIncompatibility(incompatibility)
+}
+
+// addIncompatibility appends incompatibility unless it duplicates a known one
+func (s *Solver) addIncompatibility(incompatibility Incompatibility) {
+	key := incompatibility.key()
+	if s.known == nil {
+		s.known = make(map[string]bool)
+	}
+	if s.known[key] {
+		return
+	}
+	s.known[key] = true
 	s.incompatibilities = append(s.incompatibilities, incompatibility)
 }
 
+// normalizeSelfReference simplifies a package version that depends on its own package.
+// If the version satisfies its own requirement the incompatibility can never be satisfied
+// and is dropped; otherwise it reduces to forbidding that version.
+func normalizeSelfReference(incompatibility Incompatibility) (Incompatibility, bool) {
+	if len(incompatibility.Terms) != 2 {
+		return incompatibility, true
+	}
+	a, b := incompatibility.Terms[0], incompatibility.Terms[1]
+	if a.Package != b.Package || a.Negated == b.Negated {
+		return incompatibility, true
+	}
+	positive, negative := a, b
+	if positive.Negated {
+		positive, negative = b, a
+	}
+	if !positive.Version.IsSpecific() {
+		return incompatibility, true
+	}
+	if negative.Version.Allows(positive.Version.Specific) {
+		return incompatibility, false
+	}
+	return Incompatibility{Terms: []Term{positive}, Cause: incompatibility.Cause}, true
+}
+
 // GetSolution returns the current partial solution
 func (s *Solver) GetSolution() *PartialSolution {
 	return &s.partialSolution
@@ -1,7 +1,10 @@
 package solver
 
 import (
+	"fmt"
 	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/progress"
 )
 
 func TestNewSolver(t *testing.T) {
@@ -175,4 +178,142 @@ func TestSolver_ErrorReporting(t *testing.T) {
 	if report == nil || len(report.Lines) == 0 {
 		t.Error("GenerateErrorReport failed")
 	}
-} 
\ No newline at end of file
+}
+
+func TestSolver_Constraints(t *testing.T) {
+	s := NewSolver("app", "1.0.0")
+	s.AddConstraint("Requests", VersionConstraint{Specific: "2.31.0"})
+	s.AddConstraint("urllib3", VersionConstraint{Specific: "1.26.18"})
+	s.AddRootDependency("requests", VersionConstraint{Min: "2.0"})
+	solution, err := s.Solve()
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	if a := solution.GetAssignmentByPackage("requests"); a == nil || a.Term.Version.Specific != "2.31.0" {
+		t.Errorf("Expected requests pinned to 2.31.0 by its constraint, got %v", a)
+	}
+	if a := solution.GetAssignmentByPackage("urllib3"); a != nil {
+		t.Errorf("A constraint alone must not add a package, got %v", a)
+	}
+
+	s = NewSolver("app", "1.0.0")
+	s.AddConstraint("requests", VersionConstraint{Specific: "2.31.0"})
+	s.AddRootDependency("requests", VersionConstraint{Specific: "2.28.0"})
+	if _, err := s.Solve(); err == nil {
+		t.Error("Expected a conflict between the dependency and its constraint")
+	}
+}
+
+func TestSolver_MinimizePackages(t *testing.T) {
+	build := func() *Solver {
+		s := NewSolver("app", "1.0.0")
+		s.AddRootDependency("client", VersionConstraint{Min: "1.0.0"})
+		for _, dep := range []string{"client[gpu]", "numpy"} {
+			s.AddIncompatibility(Incompatibility{Terms: []Term{
+				{Package: "client", Version: VersionConstraint{Specific: "1.0.0"}},
+				{Package: dep, Negated: true},
+			}})
+		}
+		s.AddIncompatibility(Incompatibility{Terms: []Term{
+			{Package: "client", Version: VersionConstraint{Specific: "2.0.0"}},
+			{Package: "numpy", Negated: true},
+		}})
+		return s
+	}
+
+	solution, err := build().Solve()
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	if a := solution.GetAssignmentByPackage("client[gpu]"); a == nil {
+		t.Error("Expected the default objective to take client 1.0.0 with its gpu extra")
+	}
+
+	s := build()
+	s.SetObjective(ObjectiveMinimizePackages)
+	solution, err = s.Solve()
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	decided := decidedPackages(solution)
+	if decided["client"] != "2.0.0" {
+		t.Errorf("Expected client 2.0.0 to avoid the extra, got %v", decided)
+	}
+	if _, ok := decided["client[gpu]"]; ok {
+		t.Errorf("Expected no gpu extra when minimizing packages, got %v", decided)
+	}
+
+	if _, err := ParseObjective("size"); err == nil {
+		t.Error("Expected an error for an unknown objective")
+	}
+}
+
+func TestSolver_Progress(t *testing.T) {
+	var kinds []progress.Kind
+	var decided []string
+	s := NewSolver("app", "1.0.0")
+	s.SetProgress(func(e progress.Event) {
+		kinds = append(kinds, e.Kind)
+		if e.Kind == progress.PackageDecided {
+			decided = append(decided, e.Package+"=="+e.Version)
+		}
+	})
+	s.AddRootDependency("requests", VersionConstraint{Specific: "2.31.0"})
+	if _, err := s.Solve(); err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	if len(kinds) < 2 || kinds[0] != progress.ResolutionStarted || kinds[len(kinds)-1] != progress.ResolutionFinished {
+		t.Errorf("Expected resolution to start and finish, got %v", kinds)
+	}
+	if len(decided) != 1 || decided[0] != "requests==2.31.0" {
+		t.Errorf("Expected requests==2.31.0 to be decided, got %v", decided)
+	}
+}
+
+// decisionOrder solves for names added in the given order and returns the packages in the order they were decided
+func decisionOrder(t *testing.T, names []string, seed int64) []string {
+	s := NewSolver("app", "1.0.0")
+	s.SetSeed(seed)
+	for _, name := range names {
+		s.AddRootDependency(name, VersionConstraint{})
+	}
+	solution, err := s.Solve()
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	var order []string
+	for _, assignment := range solution.Assignments {
+		if assignment.IsDecision {
+			order = append(order, assignment.Term.Package)
+		}
+	}
+	return order
+}
+
+func TestDecisionOrderIsDeterministic(t *testing.T) {
+	forward := decisionOrder(t, []string{"alpha", "beta", "gamma", "delta"}, 0)
+	backward := decisionOrder(t, []string{"delta", "gamma", "beta", "alpha"}, 0)
+	if fmt.Sprint(forward) != "[app alpha beta delta gamma]" || fmt.Sprint(backward) != fmt.Sprint(forward) {
+		t.Errorf("Expected packages decided by name whatever the input order, got %v and %v", forward, backward)
+	}
+	seeded := decisionOrder(t, []string{"alpha", "beta", "gamma", "delta"}, 42)
+	if again := decisionOrder(t, []string{"delta", "gamma", "beta", "alpha"}, 42); fmt.Sprint(again) != fmt.Sprint(seeded) {
+		t.Errorf("Expected the same seed to give the same order, got %v and %v", seeded, again)
+	}
+	if len(seeded) != len(forward) {
+		t.Errorf("Expected a seed to change only the order, got %v", seeded)
+	}
+}
+
+func TestAddIncompatibilityDeduplicates(t *testing.T) {
+	s := NewSolver("app", "1.0.0")
+	s.AddRootDependency("requests", VersionConstraint{Min: "2.0"})
+	s.AddRootDependency("requests", VersionConstraint{Min: "2.0"})
+	s.AddIncompatibility(Incompatibility{Terms: []Term{
+		{Package: "requests", Version: VersionConstraint{Min: "2.0"}, Negated: true},
+		{Package: "app", Version: VersionConstraint{Specific: "1.0.0"}},
+	}})
+	if incs := s.GetIncompatibilities(); len(incs) != 1 {
+		t.Errorf("Expected duplicates to be dropped, got %v", incs)
+	}
+}
@@ -2,7 +2,10 @@ package solver
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+
+	"rimraf-adi.com/zephyr/pkg/version"
 )
 
 // Term represents a statement about a package that may be true or false
@@ -18,6 +21,13 @@ type VersionConstraint struct {
 	Min      string
 	Max      string
 	Specific string
+	// MinExclusive makes Min a strict lower bound (>) instead of >=
+	MinExclusive bool
+	// MaxInclusive makes Max an inclusive upper bound (<=) instead of <
+	MaxInclusive bool
+	// Exclude lists versions left out of the range (!=). An entry ending
+	// in ".*" leaves out every version with that prefix.
+	Exclude []string
 }
 
 // IsSpecific returns true if this constraint represents a specific version
@@ -25,20 +35,105 @@ func (vc VersionConstraint) IsSpecific() bool {
 	return vc.Specific != ""
 }
 
+// Allows reports whether the given version falls within the constraint
+func (vc VersionConstraint) Allows(v string) bool {
+	for _, excluded := range vc.Exclude {
+		if matchesExclusion(v, excluded) {
+			return false
+		}
+	}
+	if vc.IsSpecific() {
+		return version.Compare(v, vc.Specific) == 0
+	}
+	if vc.Min != "" {
+		if cmp := version.Compare(v, vc.Min); cmp < 0 || cmp == 0 && vc.MinExclusive {
+			return false
+		}
+	}
+	if vc.Max != "" {
+		if cmp := version.Compare(v, vc.Max); cmp > 0 || cmp == 0 && !vc.MaxInclusive {
+			return false
+		}
+	}
+	return true
+}
+
+// intersect returns a constraint allowing the versions both vc and other
+// allow. An exact version the other side rules out is kept but excluded, so
+// the result allows nothing.
+func (vc VersionConstraint) intersect(other VersionConstraint) VersionConstraint {
+	result := VersionConstraint{Exclude: append(append([]string(nil), vc.Exclude...), other.Exclude...)}
+	specific := vc.Specific
+	if specific == "" {
+		specific = other.Specific
+	}
+	if specific != "" {
+		result.Specific = specific
+		if !vc.Allows(specific) || !other.Allows(specific) {
+			result.Exclude = append(result.Exclude, specific)
+		}
+		return result
+	}
+	for _, side := range []VersionConstraint{vc, other} {
+		if side.Min != "" {
+			result.raiseMin(side.Min, side.MinExclusive)
+		}
+		if side.Max != "" {
+			result.lowerMax(side.Max, side.MaxInclusive)
+		}
+	}
+	return result
+}
+
+// matchesExclusion reports whether v is excluded by a != clause
+func matchesExclusion(v, excluded string) bool {
+	prefix := strings.TrimSuffix(excluded, ".*")
+	if prefix == excluded {
+		return version.Compare(v, excluded) == 0
+	}
+	upper, ok := bumpRelease(prefix, 1)
+	return version.Compare(v, prefix) >= 0 && ok && version.Compare(v, upper) < 0
+}
+
+// namesPrerelease reports whether any version the constraint mentions is a
+// prerelease, which PEP 440 takes as asking for prereleases
+func (vc VersionConstraint) namesPrerelease() bool {
+	for _, v := range []string{vc.Specific, vc.Min, vc.Max} {
+		if v != "" && version.IsPrerelease(v) {
+			return true
+		}
+	}
+	return false
+}
+
 // String returns a string representation of the version constraint
 func (vc VersionConstraint) String() string {
+	var parts []string
 	if vc.IsSpecific() {
-		return vc.Specific
+		parts = append(parts, vc.Specific)
+	} else {
+		if vc.Min != "" {
+			operator := ">="
+			if vc.MinExclusive {
+				operator = ">"
+			}
+			parts = append(parts, operator+vc.Min)
+		}
+		if vc.Max != "" {
+			operator := "<"
+			if vc.MaxInclusive {
+				operator = "<="
+			}
+			parts = append(parts, operator+vc.Max)
+		}
 	}
-	
-	if vc.Min != "" && vc.Max != "" {
-		return fmt.Sprintf(">=%s <%s", vc.Min, vc.Max)
-	} else if vc.Min != "" {
-		return fmt.Sprintf(">=%s", vc.Min)
-	} else if vc.Max != "" {
-		return fmt.Sprintf("<%s", vc.Max)
+	for _, excluded := range vc.Exclude {
+		parts = append(parts, "!="+excluded)
 	}
-	return "any"
+	if len(parts) == 0 {
+		return "any"
+	}
+	return strings.Join(parts, " ")
 }
 
 // String returns a string representation of the term
@@ -65,6 +160,17 @@ func (i Incompatibility) String() string {
 	return fmt.Sprintf("{%s}", strings.Join(terms, ", "))
 }
 
+// key returns the incompatibility's terms in a canonical order, so that two
+// incompatibilities listing the same terms differently have the same key
+func (i Incompatibility) key() string {
+	terms := make([]string, len(i.Terms))
+	for j, term := range i.Terms {
+		terms[j] = term.String()
+	}
+	sort.Strings(terms)
+	return strings.Join(terms, ", ")
+}
+
 // Assignment represents a term that has been assigned a truth value
 type Assignment struct {
 	Term          Term
@@ -76,11 +182,30 @@ type Assignment struct {
 // PartialSolution represents the current state of the solver
 type PartialSolution struct {
 	Assignments []Assignment
+	// revisions holds, per package, the clock reading when its assignments
+	// last changed, so results derived from them can be cached
+	revisions map[string]int
+	clock int
 }
 
 // AddAssignment adds a new assignment to the partial solution
 func (ps *PartialSolution) AddAssignment(assignment Assignment) {
 	ps.Assignments = append(ps.Assignments, assignment)
+	ps.touch(assignment.Term.Package)
+}
+
+// touch records that the assignments of pkg changed
+func (ps *PartialSolution) touch(pkg string) {
+	if ps.revisions == nil {
+		ps.revisions = make(map[string]int)
+	}
+	ps.clock++
+	ps.revisions[pkg] = ps.clock
+}
+
+// revision returns a value that changes whenever the assignments of pkg do
+func (ps *PartialSolution) revision(pkg string) int {
+	return ps.revisions[pkg]
 }
 
 // GetAssignmentByPackage returns the assignment for a given package, if any
@@ -105,6 +230,7 @@ func (ps *PartialSolution) GetDecisionLevel() int {
 func (ps *PartialSolution) Backtrack(level int) {
 	for i := len(ps.Assignments) - 1; i >= 0; i-- {
 		if ps.Assignments[i].DecisionLevel > level {
+			ps.touch(ps.Assignments[i].Term.Package)
 			ps.Assignments = ps.Assignments[:i]
 		} else {
 			break
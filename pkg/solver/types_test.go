@@ -5,7 +5,7 @@ import (
 	"testing"
 )
 
-func TestTermString(t *testing.T) {
+func TestTermString_Compact(t *testing.T) {
 	term := Term{Package: "foo", Version: VersionConstraint{Specific: "1.0.0"}, Negated: false}
 	if term.String() != "foo 1.0.0" {
 		t.Errorf("Expected 'foo 1.0.0', got '%s'", term.String())
@@ -16,7 +16,7 @@ func TestTermString(t *testing.T) {
 	}
 }
 
-func TestVersionConstraintString(t *testing.T) {
+func TestVersionConstraintString_Compact(t *testing.T) {
 	tests := []struct {
 		vc       VersionConstraint
 		expected string
@@ -34,7 +34,7 @@ func TestVersionConstraintString(t *testing.T) {
 	}
 }
 
-func TestIncompatibilityString(t *testing.T) {
+func TestIncompatibilityString_Compact(t *testing.T) {
 	inc := Incompatibility{Terms: []Term{{Package: "foo", Version: VersionConstraint{Specific: "1.0.0"}, Negated: false}, {Package: "bar", Version: VersionConstraint{Specific: "2.0.0"}, Negated: true}}}
 	exp := "{foo 1.0.0, not bar 2.0.0}"
 	if inc.String() != exp {
@@ -59,4 +59,23 @@ func TestAssignmentAndPartialSolution(t *testing.T) {
 	if len(ps.Assignments) != 0 {
 		t.Error("Backtrack failed")
 	}
-} 
\ No newline at end of file
+}
+
+func TestVersionConstraintIntersect(t *testing.T) {
+	tests := []struct {
+		a, b     VersionConstraint
+		expected string
+	}{
+		{VersionConstraint{Min: "1.0"}, VersionConstraint{Max: "2.0"}, ">=1.0 <2.0"},
+		{VersionConstraint{Min: "1.0"}, VersionConstraint{Min: "1.5", MinExclusive: true}, ">1.5"},
+		{VersionConstraint{Max: "2.0", MaxInclusive: true}, VersionConstraint{Max: "2.0"}, "<2.0"},
+		{VersionConstraint{Min: "1.0", Exclude: []string{"1.2"}}, VersionConstraint{Exclude: []string{"1.3"}}, ">=1.0 !=1.2 !=1.3"},
+		{VersionConstraint{Min: "1.0"}, VersionConstraint{Specific: "1.5"}, "1.5"},
+		{VersionConstraint{Specific: "0.9"}, VersionConstraint{Min: "1.0"}, "0.9 !=0.9"},
+	}
+	for _, test := range tests {
+		if got := test.a.intersect(test.b).String(); got != test.expected {
+			t.Errorf("%s intersect %s: expected '%s', got '%s'", test.a, test.b, test.expected, got)
+		}
+	}
+}
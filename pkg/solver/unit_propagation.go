@@ -4,21 +4,21 @@ package solver
 type UnitPropagationResult struct {
 	Success bool
 	Conflict *Incompatibility
+	Aborted error
 }
 
 // UnitPropagation performs unit propagation on the given package
 func (s *Solver) UnitPropagation(packageName string) UnitPropagationResult {
-	changed := map[string]bool{packageName: true}
+	changed := newWorklist(packageName)
 	
-	for len(changed) > 0 {
-		// Remove an element from changed
-		var currentPackage string
-		for pkg := range changed {
-			currentPackage = pkg
-			delete(changed, pkg)
-			break
+	for changed.Len() > 0 {
+		if err := s.tick(); err != nil {
+			return UnitPropagationResult{Success: false, Aborted: err}
 		}
 		
+		// Take packages in the order they changed, so every run propagates alike
+		currentPackage := changed.Pop()
+		
 		// Get incompatibilities that refer to this package
 		incompatibilities := s.getIncompatibilitiesForPackage(currentPackage)
 		
@@ -31,11 +31,16 @@ func (s *Solver) UnitPropagation(packageName string) UnitPropagationResult {
 			if result == Satisfied {
 				// We have a conflict
 				resolvedIncompatibility := s.resolveConflict(incompatibility)
-				if resolvedIncompatibility == nil {
-					// Version solving has failed
+				if resolvedIncompatibility == nil || s.isUnresolvable(*resolvedIncompatibility) {
+					// Backtracking could not undo the conflict
+					// Version solving has failed; report the root cause when one was derived
+					conflict := &incompatibility
+					if resolvedIncompatibility != nil {
+						conflict = resolvedIncompatibility
+					}
 					return UnitPropagationResult{
 						Success: false,
-						Conflict: &incompatibility,
+						Conflict: conflict,
 					}
 				}
 				
@@ -55,7 +60,7 @@ func (s *Solver) UnitPropagation(packageName string) UnitPropagationResult {
 					s.partialSolution.AddAssignment(assignment)
 					
 					// Replace changed with only the package from the unsatisfied term
-					changed = map[string]bool{unsatisfiedTerm.Package: true}
+					changed = newWorklist(unsatisfiedTerm.Package)
 				}
 				
 			} else if result == Inconclusive {
@@ -65,6 +70,10 @@ func (s *Solver) UnitPropagation(packageName string) UnitPropagationResult {
 					// Add the negation of the unsatisfied term
 					negatedTerm := *unsatisfiedTerm
 					negatedTerm.Negated = !negatedTerm.Negated
+					if s.partialSolution.Contains(negatedTerm) {
+						// Already derived, e.g. by a dependency cycle; deriving it again would livelock
+						continue
+					}
 					
 					assignment := Assignment{
 						Term:          negatedTerm,
@@ -76,7 +85,7 @@ func (s *Solver) UnitPropagation(packageName string) UnitPropagationResult {
 					s.partialSolution.AddAssignment(assignment)
 					
 					// Add the package to changed
-					changed[unsatisfiedTerm.Package] = true
+					changed.Push(unsatisfiedTerm.Package)
 				}
 			}
 		}
@@ -85,6 +94,15 @@ func (s *Solver) UnitPropagation(packageName string) UnitPropagationResult {
 	return UnitPropagationResult{Success: true}
 }
 
+// isUnresolvable reports whether a conflict is still satisfied after backtracking. The root
+// decision is never retracted, so a lone root term is terminal rather than a failure.
+func (s *Solver) isUnresolvable(incompatibility Incompatibility) bool {
+	if len(incompatibility.Terms) == 1 && s.isRootCause(incompatibility) {
+		return false
+	}
+	return s.partialSolution.SatisfiesIncompatibility(incompatibility) == Satisfied
+}
+
 // getIncompatibilitiesForPackage returns incompatibilities that refer to the given package
 func (s *Solver) getIncompatibilitiesForPackage(packageName string) []Incompatibility {
 	var result []Incompatibility
@@ -99,4 +117,41 @@ func (s *Solver) getIncompatibilitiesForPackage(packageName string) []Incompatib
 	}
 	
 	return result
-} 
\ No newline at end of file
+} 
+
+// worklist is a first-in first-out queue of package names holding each name
+// at most once
+type worklist struct {
+	names []string
+	queued map[string]bool
+}
+
+// newWorklist returns a worklist holding names in order
+func newWorklist(names ...string) *worklist {
+	w := &worklist{queued: make(map[string]bool)}
+	for _, name := range names {
+		w.Push(name)
+	}
+	return w
+}
+
+// Push queues name unless it is already waiting
+func (w *worklist) Push(name string) {
+	if !w.queued[name] {
+		w.queued[name] = true
+		w.names = append(w.names, name)
+	}
+}
+
+// Pop removes and returns the name queued first
+func (w *worklist) Pop() string {
+	name := w.names[0]
+	w.names = w.names[1:]
+	delete(w.queued, name)
+	return name
+}
+
+// Len returns the number of queued names
+func (w *worklist) Len() int {
+	return len(w.names)
+}
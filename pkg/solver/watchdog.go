@@ -0,0 +1,155 @@
+package solver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxIterations bounds solver steps (decisions plus propagation passes)
+	DefaultMaxIterations = 100000
+	// DefaultTimeout bounds the wall-clock time of a single Solve call
+	DefaultTimeout = 5 * time.Minute
+)
+
+// WatchdogError is returned when Solve exceeds its iteration or time budget
+type WatchdogError struct {
+	Reason     string
+	Iterations int
+	Elapsed    time.Duration
+	// Dump is a snapshot of the partial solution and pending incompatibilities
+	Dump string
+	// Decisions are the package versions chosen before the abort
+	Decisions []Term
+	// MostConstrained are the undecided packages named by the most incompatibilities, most first
+	MostConstrained []ConstrainedPackage
+}
+
+// ConstrainedPackage counts the incompatibilities that name a package
+type ConstrainedPackage struct {
+	Name              string
+	Incompatibilities int
+}
+
+// Error implements the error interface
+func (e *WatchdogError) Error() string {
+	return fmt.Sprintf("version solving aborted: %s (%d iterations in %s)", e.Reason, e.Iterations, e.Elapsed.Round(time.Millisecond))
+}
+
+// SetMaxIterations sets the iteration budget, zero or less disables the limit
+func (s *Solver) SetMaxIterations(n int) {
+	s.maxIterations = n
+}
+
+// SetTimeout sets the wall-clock budget, zero or less disables the limit
+func (s *Solver) SetTimeout(d time.Duration) {
+	s.timeout = d
+}
+
+// startWatchdog resets the budget counters at the start of Solve
+func (s *Solver) startWatchdog() {
+	s.iterations = 0
+	s.started = time.Now()
+}
+
+// tick counts one solver step and reports a WatchdogError once a budget is exhausted
+func (s *Solver) tick() error {
+	s.iterations++
+	if s.maxIterations > 0 && s.iterations > s.maxIterations {
+		return s.watchdogError(fmt.Sprintf("exceeded iteration budget of %d", s.maxIterations))
+	}
+	if s.timeout > 0 && time.Since(s.started) > s.timeout {
+		return s.watchdogError(fmt.Sprintf("exceeded time budget of %s", s.timeout))
+	}
+	return nil
+}
+
+// watchdogError builds a WatchdogError with the current diagnostic dump
+func (s *Solver) watchdogError(reason string) *WatchdogError {
+	return &WatchdogError{
+		Reason:          reason,
+		Iterations:      s.iterations,
+		Elapsed:         time.Since(s.started),
+		Dump:            s.DumpState(),
+		Decisions:       s.decisions(),
+		MostConstrained: s.mostConstrained(maxConstrainedPackages),
+	}
+}
+
+// maxConstrainedPackages bounds how many packages a WatchdogError lists as most constrained
+const maxConstrainedPackages = 5
+
+// decisions returns the decided terms of the partial solution, without the root package
+func (s *Solver) decisions() []Term {
+	var terms []Term
+	for _, assignment := range s.partialSolution.Assignments {
+		if assignment.IsDecision && assignment.Term.Package != s.rootPackage {
+			terms = append(terms, assignment.Term)
+		}
+	}
+	return terms
+}
+
+// mostConstrained ranks the packages without a decision by how many incompatibilities
+// name them, returning at most n
+func (s *Solver) mostConstrained(n int) []ConstrainedPackage {
+	decided := map[string]bool{s.rootPackage: true}
+	for _, term := range s.decisions() {
+		decided[term.Package] = true
+	}
+	counts := make(map[string]int)
+	for _, incompatibility := range s.incompatibilities {
+		named := make(map[string]bool)
+		for _, term := range incompatibility.Terms {
+			if !decided[term.Package] && !named[term.Package] {
+				named[term.Package] = true
+				counts[term.Package]++
+			}
+		}
+	}
+	packages := make([]ConstrainedPackage, 0, len(counts))
+	for name, count := range counts {
+		packages = append(packages, ConstrainedPackage{Name: name, Incompatibilities: count})
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Incompatibilities != packages[j].Incompatibilities {
+			return packages[i].Incompatibilities > packages[j].Incompatibilities
+		}
+		return packages[i].Name < packages[j].Name
+	})
+	if len(packages) > n {
+		packages = packages[:n]
+	}
+	return packages
+}
+
+// DumpState renders the partial solution and the incompatibilities that are not yet
+// contradicted by it, for diagnosing resolutions that do not make progress
+func (s *Solver) DumpState() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Partial solution (%d assignments):\n", len(s.partialSolution.Assignments))
+	for _, assignment := range s.partialSolution.Assignments {
+		kind := "derived "
+		if assignment.IsDecision {
+			kind = "decision"
+		}
+		fmt.Fprintf(&b, "  [level %d] %s %s", assignment.DecisionLevel, kind, assignment.Term)
+		if assignment.Cause != nil {
+			fmt.Fprintf(&b, " (from %s)", assignment.Cause)
+		}
+		b.WriteString("\n")
+	}
+	var pending []string
+	for _, incompatibility := range s.incompatibilities {
+		if s.partialSolution.SatisfiesIncompatibility(incompatibility) != Contradicted {
+			pending = append(pending, incompatibility.String())
+		}
+	}
+	fmt.Fprintf(&b, "Pending incompatibilities (%d of %d):\n", len(pending), len(s.incompatibilities))
+	for _, incompatibility := range pending {
+		fmt.Fprintf(&b, "  %s\n", incompatibility)
+	}
+	return b.String()
+}
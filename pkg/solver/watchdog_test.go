@@ -0,0 +1,80 @@
+package solver
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func chainSolver() *Solver {
+	s := NewSolver("root", "1.0.0")
+	s.AddRootDependency("a", VersionConstraint{})
+	s.AddIncompatibility(dependsOn("a", "b"))
+	s.AddIncompatibility(dependsOn("b", "c"))
+	s.AddIncompatibility(dependsOn("c", "d"))
+	return s
+}
+
+func TestSolver_MaxIterations(t *testing.T) {
+	s := chainSolver()
+	s.SetMaxIterations(3)
+	_, err := s.Solve()
+	var watchdog *WatchdogError
+	if !errors.As(err, &watchdog) {
+		t.Fatalf("Expected WatchdogError, got %v", err)
+	}
+	if !strings.Contains(watchdog.Error(), "iteration budget of 3") {
+		t.Errorf("Unexpected error message: %s", watchdog.Error())
+	}
+	if !strings.Contains(watchdog.Dump, "decision root 1.0.0") || !strings.Contains(watchdog.Dump, "Pending incompatibilities") {
+		t.Errorf("Dump is missing solver state:\n%s", watchdog.Dump)
+	}
+}
+
+func TestSolver_Timeout(t *testing.T) {
+	s := chainSolver()
+	s.SetTimeout(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	_, err := s.Solve()
+	var watchdog *WatchdogError
+	if !errors.As(err, &watchdog) || !strings.Contains(watchdog.Reason, "time budget") {
+		t.Fatalf("Expected time budget WatchdogError, got %v", err)
+	}
+}
+
+func TestSolver_WithinBudget(t *testing.T) {
+	s := chainSolver()
+	s.SetMaxIterations(0)
+	s.SetTimeout(0)
+	solution, err := s.Solve()
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	if len(decidedPackages(solution)) != 5 {
+		t.Errorf("Expected 5 decided packages, got %v", decidedPackages(solution))
+	}
+}
+
+func TestSolver_TimeoutPartialResults(t *testing.T) {
+	s := chainSolver()
+	s.AddIncompatibility(dependsOn("b", "d"))
+	s.SetMaxIterations(8)
+	_, err := s.Solve()
+	var watchdog *WatchdogError
+	if !errors.As(err, &watchdog) {
+		t.Fatalf("Expected WatchdogError, got %v", err)
+	}
+	if len(watchdog.Decisions) != 2 || watchdog.Decisions[0].Package != "a" || watchdog.Decisions[1].Package != "b" {
+		t.Errorf("Expected the decisions for a and b without root, got %v", watchdog.Decisions)
+	}
+	expected := []ConstrainedPackage{{Name: "c", Incompatibilities: 2}, {Name: "d", Incompatibilities: 2}}
+	if len(watchdog.MostConstrained) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, watchdog.MostConstrained)
+	}
+	for i := range expected {
+		if watchdog.MostConstrained[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, watchdog.MostConstrained)
+		}
+	}
+}
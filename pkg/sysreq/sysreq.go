@@ -0,0 +1,299 @@
+// Package sysreq checks for the system tools and libraries a project declares
+// in buildmeta.yaml, such as a C compiler or libpq, and suggests the system
+// package providing each missing one for the host's package manager.
+package sysreq
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Kind distinguishes the two sorts of requirement
+type Kind string
+
+const (
+	// Tool is a command that must be on PATH
+	Tool Kind = "tool"
+	// Library is a native library, with its headers, that extensions link against
+	Library Kind = "library"
+)
+
+// Result is the outcome of checking one requirement
+type Result struct {
+	Kind Kind
+	Name string
+	// Location is where the requirement was found; empty when it is missing
+	Location string
+	// Hint says how to install a missing requirement
+	Hint string
+}
+
+// Found reports whether the requirement is satisfied
+func (r Result) Found() bool {
+	return r.Location != ""
+}
+
+func (r Result) String() string {
+	if r.Found() {
+		return fmt.Sprintf("%s %s (%s)", r.Kind, r.Name, r.Location)
+	}
+	return fmt.Sprintf("%s %s not found; %s", r.Kind, r.Name, r.Hint)
+}
+
+// Missing returns the results whose requirement is not satisfied
+func Missing(results []Result) []Result {
+	var missing []Result
+	for _, r := range results {
+		if !r.Found() {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+// Check looks for each tool on PATH and each library in the linker's search
+// directories or pkg-config, in the order given
+func Check(tools, libraries []string) []Result {
+	pm := DetectPackageManager()
+	var results []Result
+	for _, name := range tools {
+		r := Result{Kind: Tool, Name: name}
+		if path, err := lookPath(name); err == nil {
+			r.Location = path
+		} else {
+			r.Hint = hint(pm, toolPackages[name], name)
+		}
+		results = append(results, r)
+	}
+	for _, name := range libraries {
+		r := Result{Kind: Library, Name: name}
+		base := libraryBase(name)
+		if location := findLibrary(base); location != "" {
+			r.Location = location
+		} else {
+			r.Hint = hint(pm, libraryPackages[base], "lib"+base)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// lookPath and findLibrary are replaced in tests
+var (
+	lookPath    = exec.LookPath
+	findLibrary = systemFindLibrary
+)
+
+// libraryBase reduces libpq, pq, libpq.so.5 and libpq.dylib to pq
+func libraryBase(name string) string {
+	base := filepath.Base(name)
+	if i := strings.Index(base, ".so"); i >= 0 {
+		base = base[:i]
+	}
+	base = strings.TrimSuffix(base, ".dylib")
+	if alias, ok := libraryAliases[base]; ok {
+		return alias
+	}
+	return strings.TrimPrefix(base, "lib")
+}
+
+// libraryAliases maps project names people write for libraries to the
+// library's own name
+var libraryAliases = map[string]string{
+	"openssl":    "ssl",
+	"zlib":       "z",
+	"postgresql": "pq",
+}
+
+// systemFindLibrary returns where lib<base> is installed, or its pkg-config
+// module, or "" when neither exists
+func systemFindLibrary(base string) string {
+	for _, dir := range libraryDirs() {
+		matches, _ := filepath.Glob(filepath.Join(dir, "lib"+base+".*"))
+		for _, match := range matches {
+			ext := strings.TrimPrefix(filepath.Base(match), "lib"+base)
+			if strings.HasPrefix(ext, ".so") || ext == ".dylib" || ext == ".a" || ext == ".tbd" {
+				return match
+			}
+		}
+	}
+	if _, err := exec.LookPath("pkg-config"); err == nil {
+		for _, module := range []string{"lib" + base, base} {
+			if exec.Command("pkg-config", "--exists", module).Run() == nil {
+				return "pkg-config " + module
+			}
+		}
+	}
+	return ""
+}
+
+// libraryDirs lists the directories the system linker searches
+func libraryDirs() []string {
+	env := "LD_LIBRARY_PATH"
+	dirs := []string{"/usr/local/lib", "/usr/lib", "/lib", "/usr/lib64", "/lib64"}
+	if runtime.GOOS == "darwin" {
+		env = "DYLD_LIBRARY_PATH"
+		dirs = []string{"/opt/homebrew/lib", "/usr/local/lib", "/opt/local/lib", "/usr/lib"}
+	} else {
+		// Debian puts libraries in multiarch directories such as /usr/lib/x86_64-linux-gnu
+		multiarch, _ := filepath.Glob("/usr/lib/*-linux-*")
+		dirs = append(dirs, multiarch...)
+		multiarch, _ = filepath.Glob("/lib/*-linux-*")
+		dirs = append(dirs, multiarch...)
+	}
+	return append(filepath.SplitList(os.Getenv(env)), dirs...)
+}
+
+// PackageManager is a system package manager hints are written for
+type PackageManager string
+
+// The package managers hints can be written for
+const (
+	Apt    PackageManager = "apt"
+	Dnf    PackageManager = "dnf"
+	Apk    PackageManager = "apk"
+	Pacman PackageManager = "pacman"
+	Brew   PackageManager = "brew"
+)
+
+// packageManagers orders the managers hints fall back to when the host's is unknown
+var packageManagers = []PackageManager{Apt, Dnf, Apk, Pacman, Brew}
+
+// InstallCommand returns the command installing pkg with pm
+func (pm PackageManager) InstallCommand(pkg string) string {
+	switch pm {
+	case Apt:
+		return "sudo apt-get install " + pkg
+	case Dnf:
+		return "sudo dnf install " + pkg
+	case Apk:
+		return "sudo apk add " + pkg
+	case Pacman:
+		return "sudo pacman -S " + pkg
+	case Brew:
+		return "brew install " + pkg
+	}
+	return ""
+}
+
+// osRelease is read to detect the Linux distribution; it is replaced in tests
+var osRelease = "/etc/os-release"
+
+// DetectPackageManager guesses the host's package manager: Homebrew on
+// macOS, and on Linux the one of the distribution named by /etc/os-release.
+// It returns "" when there is no telling.
+func DetectPackageManager() PackageManager {
+	if runtime.GOOS == "darwin" {
+		return Brew
+	}
+	f, err := os.Open(osRelease)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok && (key == "ID" || key == "ID_LIKE") {
+			ids = append(ids, strings.Fields(strings.Trim(value, `"'`))...)
+		}
+	}
+	for _, id := range ids {
+		switch id {
+		case "debian", "ubuntu":
+			return Apt
+		case "fedora", "rhel", "centos":
+			return Dnf
+		case "alpine":
+			return Apk
+		case "arch":
+			return Pacman
+		}
+	}
+	return ""
+}
+
+// hint suggests how to install a missing requirement from the packages
+// known to provide it
+func hint(pm PackageManager, packages map[PackageManager]string, name string) string {
+	if pkg := packages[pm]; pkg != "" {
+		return "install it with: " + pm.InstallCommand(pkg)
+	}
+	if pm == "" && len(packages) > 0 {
+		var commands []string
+		for _, m := range packageManagers {
+			if pkg := packages[m]; pkg != "" {
+				commands = append(commands, m.InstallCommand(pkg))
+			}
+		}
+		return "install it with one of: " + strings.Join(commands, "; ")
+	}
+	return fmt.Sprintf("install the system package that provides %s", name)
+}
+
+// ToolHint suggests how to install a build tool on this host
+func ToolHint(name string) string {
+	return hint(DetectPackageManager(), toolPackages[name], name)
+}
+
+// LibraryHint suggests how to install a native library, with its headers, on
+// this host; name may be written as libpq, pq or libpq.so.5
+func LibraryHint(name string) string {
+	base := libraryBase(name)
+	return hint(DetectPackageManager(), libraryPackages[base], "lib"+base)
+}
+
+// PythonHeadersHint suggests how to install the headers C extensions compile
+// against, which distributions split from the interpreter
+func PythonHeadersHint() string {
+	return hint(DetectPackageManager(), pythonHeaderPackages, "Python.h")
+}
+
+// pythonHeaderPackages names the packages providing Python.h
+var pythonHeaderPackages = map[PackageManager]string{
+	Apt: "python3-dev", Dnf: "python3-devel", Apk: "python3-dev", Pacman: "python",
+}
+
+// toolPackages names the packages providing common build tools
+var toolPackages = map[string]map[PackageManager]string{
+	"gcc":        {Apt: "gcc", Dnf: "gcc", Apk: "gcc", Pacman: "gcc", Brew: "gcc"},
+	"g++":        {Apt: "g++", Dnf: "gcc-c++", Apk: "g++", Pacman: "gcc", Brew: "gcc"},
+	"cc":         {Apt: "build-essential", Dnf: "gcc", Apk: "build-base", Pacman: "base-devel"},
+	"make":       {Apt: "make", Dnf: "make", Apk: "make", Pacman: "make", Brew: "make"},
+	"cmake":      {Apt: "cmake", Dnf: "cmake", Apk: "cmake", Pacman: "cmake", Brew: "cmake"},
+	"pkg-config": {Apt: "pkg-config", Dnf: "pkgconf-pkg-config", Apk: "pkgconf", Pacman: "pkgconf", Brew: "pkg-config"},
+	"gfortran":   {Apt: "gfortran", Dnf: "gcc-gfortran", Apk: "gfortran", Pacman: "gcc-fortran", Brew: "gcc"},
+	"swig":       {Apt: "swig", Dnf: "swig", Apk: "swig", Pacman: "swig", Brew: "swig"},
+	"git":        {Apt: "git", Dnf: "git", Apk: "git", Pacman: "git", Brew: "git"},
+	"rustc":      {Apt: "rustc", Dnf: "rust", Apk: "rust", Pacman: "rust", Brew: "rust"},
+	"cargo":      {Apt: "cargo", Dnf: "cargo", Apk: "cargo", Pacman: "rust", Brew: "rust"},
+	"pg_config":  {Apt: "libpq-dev", Dnf: "libpq-devel", Apk: "libpq-dev", Pacman: "postgresql-libs", Brew: "libpq"},
+}
+
+// libraryPackages names the development packages, with headers, providing
+// common libraries, keyed by the library name without its lib prefix
+var libraryPackages = map[string]map[PackageManager]string{
+	"pq":          {Apt: "libpq-dev", Dnf: "libpq-devel", Apk: "libpq-dev", Pacman: "postgresql-libs", Brew: "libpq"},
+	"ssl":         {Apt: "libssl-dev", Dnf: "openssl-devel", Apk: "openssl-dev", Pacman: "openssl", Brew: "openssl"},
+	"crypto":      {Apt: "libssl-dev", Dnf: "openssl-devel", Apk: "openssl-dev", Pacman: "openssl", Brew: "openssl"},
+	"ffi":         {Apt: "libffi-dev", Dnf: "libffi-devel", Apk: "libffi-dev", Pacman: "libffi", Brew: "libffi"},
+	"z":           {Apt: "zlib1g-dev", Dnf: "zlib-devel", Apk: "zlib-dev", Pacman: "zlib", Brew: "zlib"},
+	"xml2":        {Apt: "libxml2-dev", Dnf: "libxml2-devel", Apk: "libxml2-dev", Pacman: "libxml2", Brew: "libxml2"},
+	"xslt":        {Apt: "libxslt1-dev", Dnf: "libxslt-devel", Apk: "libxslt-dev", Pacman: "libxslt", Brew: "libxslt"},
+	"jpeg":        {Apt: "libjpeg-dev", Dnf: "libjpeg-turbo-devel", Apk: "libjpeg-turbo-dev", Pacman: "libjpeg-turbo", Brew: "jpeg"},
+	"png":         {Apt: "libpng-dev", Dnf: "libpng-devel", Apk: "libpng-dev", Pacman: "libpng", Brew: "libpng"},
+	"mysqlclient": {Apt: "libmysqlclient-dev", Dnf: "mysql-devel", Apk: "mariadb-connector-c-dev", Pacman: "mariadb-libs", Brew: "mysql-client"},
+	"GL":          {Apt: "libgl1-mesa-dev", Dnf: "mesa-libGL-devel", Apk: "mesa-dev", Pacman: "mesa"},
+	"yaml":        {Apt: "libyaml-dev", Dnf: "libyaml-devel", Apk: "yaml-dev", Pacman: "libyaml", Brew: "libyaml"},
+	"sqlite3":     {Apt: "libsqlite3-dev", Dnf: "sqlite-devel", Apk: "sqlite-dev", Pacman: "sqlite", Brew: "sqlite"},
+	"bz2":         {Apt: "libbz2-dev", Dnf: "bzip2-devel", Apk: "bzip2-dev", Pacman: "bzip2", Brew: "bzip2"},
+	"lzma":        {Apt: "liblzma-dev", Dnf: "xz-devel", Apk: "xz-dev", Pacman: "xz", Brew: "xz"},
+	"openblas":    {Apt: "libopenblas-dev", Dnf: "openblas-devel", Apk: "openblas-dev", Pacman: "openblas", Brew: "openblas"},
+	"hdf5":        {Apt: "libhdf5-dev", Dnf: "hdf5-devel", Apk: "hdf5-dev", Pacman: "hdf5", Brew: "hdf5"},
+}
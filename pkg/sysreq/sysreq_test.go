@@ -0,0 +1,78 @@
+package sysreq
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	release := filepath.Join(t.TempDir(), "os-release")
+	os.WriteFile(release, []byte("ID=rocky\nID_LIKE=\"rhel centos fedora\"\n"), 0644)
+	defer func(path string) { osRelease = path }(osRelease)
+	osRelease = release
+	defer func(original func(string) (string, error)) { lookPath = original }(lookPath)
+	lookPath = func(name string) (string, error) {
+		if name == "gcc" {
+			return "/usr/bin/gcc", nil
+		}
+		return "", errors.New("not found")
+	}
+	defer func(original func(string) string) { findLibrary = original }(findLibrary)
+	var searched []string
+	findLibrary = func(base string) string {
+		searched = append(searched, base)
+		if base == "z" {
+			return "/usr/lib64/libz.so.1"
+		}
+		return ""
+	}
+
+	results := Check([]string{"gcc", "pkg-config", "frobnicate"}, []string{"zlib", "libpq.so.5", "libweird"})
+	if strings.Join(searched, ",") != "z,pq,weird" {
+		t.Errorf("searched for %v", searched)
+	}
+	missing := Missing(results)
+	if len(results) != 6 || len(missing) != 4 {
+		t.Fatalf("results = %v", results)
+	}
+	want := []string{
+		"tool pkg-config not found; install it with: sudo dnf install pkgconf-pkg-config",
+		"tool frobnicate not found; install the system package that provides frobnicate",
+		"library libpq.so.5 not found; install it with: sudo dnf install libpq-devel",
+		"library libweird not found; install the system package that provides libweird",
+	}
+	for i, r := range missing {
+		if r.String() != want[i] {
+			t.Errorf("missing[%d] = %q, want %q", i, r, want[i])
+		}
+	}
+	if results[0].String() != "tool gcc (/usr/bin/gcc)" {
+		t.Errorf("results[0] = %q", results[0])
+	}
+
+	// Without a known distribution every package manager is suggested
+	osRelease = filepath.Join(t.TempDir(), "missing")
+	got := Check(nil, []string{"pq"})[0].Hint
+	if !strings.Contains(got, "sudo apt-get install libpq-dev; sudo dnf install libpq-devel") {
+		t.Errorf("hint without a distribution = %q", got)
+	}
+}
+
+func TestDetectPackageManager(t *testing.T) {
+	defer func(path string) { osRelease = path }(osRelease)
+	for content, want := range map[string]PackageManager{
+		"ID=ubuntu\nID_LIKE=debian\n": Apt,
+		"ID=alpine\n":                 Apk,
+		"ID=\"arch\"\n":               Pacman,
+		"ID=plan9\n":                  "",
+	} {
+		osRelease = filepath.Join(t.TempDir(), "os-release")
+		os.WriteFile(osRelease, []byte(content), 0644)
+		if got := DetectPackageManager(); got != want {
+			t.Errorf("DetectPackageManager(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Package tasks runs buildmeta scripts as a dependency graph
+package tasks
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"rimraf-adi.com/zephyr/pkg/buildmeta"
+)
+
+// Runner executes tasks and their dependencies
+type Runner struct {
+	Tasks map[string]buildmeta.Task
+	// Dir is the project directory task working directories are relative to
+	Dir string
+	// Env is the base environment every task inherits
+	Env map[string]string
+	// Jobs bounds how many independent tasks run at once
+	Jobs   int
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewRunner creates a runner for the given tasks
+func NewRunner(tasks map[string]buildmeta.Task, dir string, env map[string]string) *Runner {
+	return &Runner{
+		Tasks:  tasks,
+		Dir:    dir,
+		Env:    env,
+		Jobs:   runtime.NumCPU(),
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// TaskError reports which task in a pipeline failed
+type TaskError struct {
+	Task string
+	Err  error
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("task %q failed: %v", e.Task, e.Err)
+}
+
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// Has reports whether name is a defined task
+func (r *Runner) Has(name string) bool {
+	_, ok := r.Tasks[name]
+	return ok
+}
+
+// Plan returns name and its transitive dependencies in a valid execution order
+func (r *Runner) Plan(name string) ([]string, error) {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var order []string
+	var visit func(task string, path []string) error
+	visit = func(task string, path []string) error {
+		switch state[task] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("task dependency cycle: %s", strings.Join(append(path, task), " -> "))
+		}
+		t, ok := r.Tasks[task]
+		if !ok {
+			if len(path) == 0 {
+				return fmt.Errorf("unknown task %q", task)
+			}
+			return fmt.Errorf("task %q depends on unknown task %q", path[len(path)-1], task)
+		}
+		state[task] = visiting
+		for _, dep := range t.DependsOn {
+			if err := visit(dep, append(path, task)); err != nil {
+				return err
+			}
+		}
+		state[task] = done
+		order = append(order, task)
+		return nil
+	}
+	if err := visit(name, nil); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// Run executes name after all of its dependencies. Dependencies that do not
+// depend on each other run in parallel, up to Jobs at a time, with their
+// output prefixed by the task name. args are appended to name's own command.
+// Once a task fails no new tasks are started.
+func (r *Runner) Run(name string, args []string) error {
+	order, err := r.Plan(name)
+	if err != nil {
+		return err
+	}
+	jobs := r.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	var (
+		mu       sync.Mutex
+		cond     = sync.NewCond(&mu)
+		finished = make(map[string]bool)
+		started  = make(map[string]bool)
+		running  int
+		firstErr error
+		outMu    sync.Mutex
+	)
+	ready := func(task string) bool {
+		for _, dep := range r.Tasks[task].DependsOn {
+			if !finished[dep] {
+				return false
+			}
+		}
+		return true
+	}
+
+	mu.Lock()
+	for len(finished) < len(order) && firstErr == nil {
+		launched := false
+		for _, task := range order {
+			if started[task] || running >= jobs || !ready(task) {
+				continue
+			}
+			started[task] = true
+			running++
+			launched = true
+			go func(task string) {
+				var taskErr error
+				if task == name {
+					taskErr = r.exec(task, args, r.Stdin, r.Stdout, r.Stderr)
+				} else {
+					stdout := &prefixWriter{mu: &outMu, w: r.Stdout, prefix: "[" + task + "] "}
+					stderr := &prefixWriter{mu: &outMu, w: r.Stderr, prefix: "[" + task + "] "}
+					taskErr = r.exec(task, nil, nil, stdout, stderr)
+					stdout.Flush()
+					stderr.Flush()
+				}
+				mu.Lock()
+				running--
+				finished[task] = true
+				if taskErr != nil && firstErr == nil {
+					firstErr = &TaskError{Task: task, Err: taskErr}
+				}
+				cond.Broadcast()
+				mu.Unlock()
+			}(task)
+		}
+		if !launched {
+			cond.Wait()
+		}
+	}
+	for running > 0 {
+		cond.Wait()
+	}
+	mu.Unlock()
+	return firstErr
+}
+
+// exec runs a single task's command through the platform shell
+func (r *Runner) exec(name string, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	task := r.Tasks[name]
+	if task.Cmd == "" {
+		return nil
+	}
+	command := task.Cmd
+	for _, arg := range args {
+		command += " " + shellQuote(arg)
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("/bin/sh", "-c", command)
+	}
+	cmd.Dir = r.Dir
+	if task.Cwd != "" {
+		if filepath.IsAbs(task.Cwd) {
+			cmd.Dir = task.Cwd
+		} else {
+			cmd.Dir = filepath.Join(r.Dir, task.Cwd)
+		}
+	}
+	env := make(map[string]string, len(r.Env)+len(task.Env))
+	for k, v := range r.Env {
+		env[k] = v
+	}
+	for k, v := range task.Env {
+		env[k] = v
+	}
+	cmd.Env = buildmeta.EnvList(env)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// shellQuote quotes an argument for /bin/sh when it contains special characters
+func shellQuote(arg string) string {
+	if arg != "" && strings.IndexFunc(arg, func(c rune) bool {
+		return !(c == '-' || c == '_' || c == '.' || c == '/' || c == '=' || c == ':' || c == ',' ||
+			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9'))
+	}) < 0 {
+		return arg
+	}
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// Names returns the defined task names in sorted order
+func (r *Runner) Names() []string {
+	names := make([]string, 0, len(r.Tasks))
+	for name := range r.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prefixWriter prefixes each complete line before writing it under a shared lock
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    bytes.Buffer
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf.Write(b)
+	for {
+		i := bytes.IndexByte(p.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		line := p.buf.Next(i + 1)
+		p.mu.Lock()
+		_, err := fmt.Fprintf(p.w, "%s%s", p.prefix, line)
+		p.mu.Unlock()
+		if err != nil {
+			return len(b), err
+		}
+	}
+}
+
+// Flush writes any trailing output that did not end in a newline
+func (p *prefixWriter) Flush() {
+	if p.buf.Len() == 0 {
+		return
+	}
+	p.mu.Lock()
+	fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.buf.Bytes())
+	p.mu.Unlock()
+	p.buf.Reset()
+}
@@ -0,0 +1,110 @@
+package tasks
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"rimraf-adi.com/zephyr/pkg/buildmeta"
+)
+
+func newTestRunner(t *testing.T, tasks map[string]buildmeta.Task) (*Runner, *bytes.Buffer) {
+	if runtime.GOOS == "windows" {
+		t.Skip("task commands use /bin/sh")
+	}
+	var out bytes.Buffer
+	r := NewRunner(tasks, t.TempDir(), map[string]string{"PATH": os.Getenv("PATH"), "BASE": "base"})
+	r.Stdin = nil
+	r.Stdout = &out
+	r.Stderr = &out
+	return r, &out
+}
+
+func TestPlanOrdersDependencies(t *testing.T) {
+	r := NewRunner(map[string]buildmeta.Task{
+		"a": {Cmd: "a", DependsOn: []string{"b", "c"}},
+		"b": {Cmd: "b", DependsOn: []string{"c"}},
+		"c": {Cmd: "c"},
+	}, ".", nil)
+	order, err := r.Plan("a")
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if strings.Join(order, ",") != "c,b,a" {
+		t.Errorf("Plan = %v, expected [c b a]", order)
+	}
+}
+
+func TestPlanErrors(t *testing.T) {
+	r := NewRunner(map[string]buildmeta.Task{
+		"a":       {Cmd: "a", DependsOn: []string{"b"}},
+		"b":       {Cmd: "b", DependsOn: []string{"a"}},
+		"missing": {Cmd: "x", DependsOn: []string{"nope"}},
+	}, ".", nil)
+	if _, err := r.Plan("a"); err == nil || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Errorf("Plan should report the cycle, got %v", err)
+	}
+	if _, err := r.Plan("missing"); err == nil || !strings.Contains(err.Error(), `"nope"`) {
+		t.Errorf("Plan should report the unknown dependency, got %v", err)
+	}
+	if _, err := r.Plan("undefined"); err == nil {
+		t.Error("Plan should fail for an undefined task")
+	}
+}
+
+func TestRunDependenciesCwdAndEnv(t *testing.T) {
+	r, out := newTestRunner(t, map[string]buildmeta.Task{
+		"prepare": {Cmd: "echo prepared > marker"},
+		"sub":     {Cmd: "basename $(pwd)", Cwd: "sub"},
+		"main":    {Cmd: "cat marker && echo $BASE $TASK", DependsOn: []string{"prepare", "sub"}, Env: map[string]string{"TASK": "task"}},
+	})
+	if err := os.Mkdir(filepath.Join(r.Dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Run("main", []string{"it's"}); err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, out)
+	}
+	output := out.String()
+	for _, want := range []string{"[sub] sub\n", "prepared\nbase task it's\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestRunParallelIndependentTasks(t *testing.T) {
+	// Each task waits for the other's marker, so they only finish when run concurrently
+	r, out := newTestRunner(t, map[string]buildmeta.Task{
+		"left":  {Cmd: "touch left; for i in $(seq 50); do [ -f right ] && exit 0; sleep 0.1; done; exit 1"},
+		"right": {Cmd: "touch right; for i in $(seq 50); do [ -f left ] && exit 0; sleep 0.1; done; exit 1"},
+		"all":   {DependsOn: []string{"left", "right"}},
+	})
+	r.Jobs = 2
+	if err := r.Run("all", nil); err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, out)
+	}
+}
+
+func TestRunStopsAfterFailure(t *testing.T) {
+	r, out := newTestRunner(t, map[string]buildmeta.Task{
+		"fail": {Cmd: "exit 3"},
+		"next": {Cmd: "touch ran", DependsOn: []string{"fail"}},
+	})
+	err := r.Run("next", nil)
+	var taskErr *TaskError
+	if !errors.As(err, &taskErr) || taskErr.Task != "fail" {
+		t.Fatalf("expected TaskError for fail, got %v\n%s", err, out)
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("expected exit code 3, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(r.Dir, "ran")); err == nil {
+		t.Error("dependent task should not run after a failure")
+	}
+}
@@ -0,0 +1,103 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+)
+
+// specifierOperators are the PEP 440 comparison operators, longest first
+var specifierOperators = []string{"===", "~=", "==", "!=", "<=", ">=", "<", ">"}
+
+// Matches applies a PEP 440 comparison operator to v and other
+func (v *Version) Matches(op string, other *Version) (bool, error) {
+	c := v.Compare(other)
+	switch op {
+	case "==", "===":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	case "~=":
+		if len(other.Release) < 2 || c < 0 {
+			return false, nil
+		}
+		prefix := other.Release[:len(other.Release)-1]
+		for i, n := range prefix {
+			if i >= len(v.Release) || v.Release[i] != n {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("unsupported operator '%s'", op)
+}
+
+// Satisfies reports whether version s matches every clause of a
+// comma-separated specifier such as ">=1.2,!=1.3.*,<2". An empty
+// specifier matches any version.
+func Satisfies(s, specifier string) (bool, error) {
+	v, err := Parse(s)
+	if err != nil {
+		return false, err
+	}
+	for _, clause := range strings.Split(specifier, ",") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+		op := ""
+		for _, candidate := range specifierOperators {
+			if strings.HasPrefix(clause, candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return false, fmt.Errorf("invalid specifier %q: missing operator", clause)
+		}
+		target := strings.TrimSpace(clause[len(op):])
+		var ok bool
+		if strings.HasSuffix(target, ".*") && (op == "==" || op == "!=") {
+			ok, err = v.hasPrefix(strings.TrimSuffix(target, ".*"))
+			if op == "!=" {
+				ok = !ok
+			}
+		} else {
+			var other *Version
+			if other, err = Parse(target); err == nil {
+				ok, err = v.Matches(op, other)
+			}
+		}
+		if err != nil {
+			return false, fmt.Errorf("invalid specifier %q: %w", clause, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// hasPrefix reports whether v's release starts with the segments of prefix, as in "==1.2.*"
+func (v *Version) hasPrefix(prefix string) (bool, error) {
+	p, err := Parse(prefix)
+	if err != nil {
+		return false, err
+	}
+	if p.Epoch != v.Epoch {
+		return false, nil
+	}
+	for i, n := range p.Release {
+		if v.component(i) != n {
+			return false, nil
+		}
+	}
+	return true, nil
+}
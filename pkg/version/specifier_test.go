@@ -0,0 +1,34 @@
+package version
+
+import "testing"
+
+func TestSatisfies(t *testing.T) {
+	tests := []struct {
+		version, spec string
+		want          bool
+	}{
+		{"1.4.2", "", true},
+		{"1.4.2", ">=1.2,<2", true},
+		{"2.0", ">=1.2,<2", false},
+		{"1.3.5", "!=1.3.*", false},
+		{"1.4", "==1.4.*", true},
+		{"1.4.2", "~=1.4", true},
+		{"2.0", "~=1.4", false},
+		{"1.4.2", "~=1.4.1", true},
+		{"1.5.0", "~=1.4.1", false},
+		{"1.0", "==1.0.0", true},
+	}
+	for _, tt := range tests {
+		got, err := Satisfies(tt.version, tt.spec)
+		if err != nil {
+			t.Errorf("Satisfies(%q, %q) failed: %v", tt.version, tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Satisfies(%q, %q) = %v, want %v", tt.version, tt.spec, got, tt.want)
+		}
+	}
+	if _, err := Satisfies("1.0", "1.0"); err == nil {
+		t.Error("expected error for specifier without operator")
+	}
+}
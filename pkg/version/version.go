@@ -0,0 +1,310 @@
+package version
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed PEP 440 version
+type Version struct {
+	Epoch   int
+	Release []int
+	// PreKind is "a", "b" or "rc" for pre-releases, empty otherwise
+	PreKind string
+	PreNum  int
+	// Post is -1 when the version has no post-release segment
+	Post int
+	// Dev is -1 when the version has no dev-release segment
+	Dev   int
+	Local string
+	raw   string
+}
+
+// Parse parses a PEP 440 version string, accepting the common non-normalized spellings
+func Parse(s string) (*Version, error) {
+	raw := s
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "v")
+	if s == "" {
+		return nil, fmt.Errorf("invalid version %q", raw)
+	}
+	v := &Version{Post: -1, Dev: -1, raw: raw}
+
+	if idx := strings.Index(s, "+"); idx >= 0 {
+		v.Local = s[idx+1:]
+		s = s[:idx]
+		if v.Local == "" {
+			return nil, fmt.Errorf("invalid version %q: empty local segment", raw)
+		}
+	}
+	if idx := strings.Index(s, "!"); idx >= 0 {
+		epoch, err := strconv.Atoi(s[:idx])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: bad epoch", raw)
+		}
+		v.Epoch = epoch
+		s = s[idx+1:]
+	}
+
+	// Release segment
+	i := 0
+	for {
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if start == i {
+			return nil, fmt.Errorf("invalid version %q", raw)
+		}
+		n, _ := strconv.Atoi(s[start:i])
+		v.Release = append(v.Release, n)
+		if i < len(s) && s[i] == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9' {
+			i++
+			continue
+		}
+		break
+	}
+
+	rest := s[i:]
+	for rest != "" {
+		rest = strings.TrimLeft(rest, ".-_")
+		kind, num, remaining, ok := splitSegment(rest)
+		if !ok {
+			return nil, fmt.Errorf("invalid version %q", raw)
+		}
+		switch kind {
+		case "a", "alpha":
+			v.PreKind, v.PreNum = "a", num
+		case "b", "beta":
+			v.PreKind, v.PreNum = "b", num
+		case "rc", "c", "pre", "preview":
+			v.PreKind, v.PreNum = "rc", num
+		case "post", "rev", "r":
+			v.Post = num
+		case "dev":
+			v.Dev = num
+		default:
+			return nil, fmt.Errorf("invalid version %q: unknown segment %q", raw, kind)
+		}
+		rest = remaining
+	}
+	return v, nil
+}
+
+// MustParse is like Parse but panics on invalid input; intended for tests and constants
+func MustParse(s string) *Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// splitSegment splits a leading "<letters><digits>" segment off s
+func splitSegment(s string) (string, int, string, bool) {
+	i := 0
+	for i < len(s) && s[i] >= 'a' && s[i] <= 'z' {
+		i++
+	}
+	if i == 0 {
+		return "", 0, s, false
+	}
+	kind := s[:i]
+	rest := strings.TrimLeft(s[i:], ".-_")
+	j := 0
+	for j < len(rest) && rest[j] >= '0' && rest[j] <= '9' {
+		j++
+	}
+	num := 0
+	if j > 0 {
+		num, _ = strconv.Atoi(rest[:j])
+	}
+	return kind, num, rest[j:], true
+}
+
+// IsPrerelease reports whether the version is an alpha, beta, rc or dev release
+func (v *Version) IsPrerelease() bool {
+	return v.PreKind != "" || v.Dev >= 0
+}
+
+// IsLocal reports whether the version carries a +local label
+func (v *Version) IsLocal() bool {
+	return v.Local != ""
+}
+
+// Major returns the first release component
+func (v *Version) Major() int {
+	return v.component(0)
+}
+
+// Minor returns the second release component, or zero if absent
+func (v *Version) Minor() int {
+	return v.component(1)
+}
+
+// Micro returns the third release component, or zero if absent
+func (v *Version) Micro() int {
+	return v.component(2)
+}
+
+func (v *Version) component(i int) int {
+	if i < len(v.Release) {
+		return v.Release[i]
+	}
+	return 0
+}
+
+// String returns the normalized form of the version
+func (v *Version) String() string {
+	var b strings.Builder
+	if v.Epoch != 0 {
+		fmt.Fprintf(&b, "%d!", v.Epoch)
+	}
+	for i, n := range v.Release {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(strconv.Itoa(n))
+	}
+	if v.PreKind != "" {
+		fmt.Fprintf(&b, "%s%d", v.PreKind, v.PreNum)
+	}
+	if v.Post >= 0 {
+		fmt.Fprintf(&b, ".post%d", v.Post)
+	}
+	if v.Dev >= 0 {
+		fmt.Fprintf(&b, ".dev%d", v.Dev)
+	}
+	if v.Local != "" {
+		b.WriteString("+" + v.Local)
+	}
+	return b.String()
+}
+
+// Compare returns -1, 0 or 1 depending on whether v sorts before, equal to or after other
+func (v *Version) Compare(other *Version) int {
+	if c := compareInt(v.Epoch, other.Epoch); c != 0 {
+		return c
+	}
+	n := len(v.Release)
+	if len(other.Release) > n {
+		n = len(other.Release)
+	}
+	for i := 0; i < n; i++ {
+		if c := compareInt(v.component(i), other.component(i)); c != 0 {
+			return c
+		}
+	}
+	if c := compareInt(v.preRank(), other.preRank()); c != 0 {
+		return c
+	}
+	if v.PreKind != "" && v.PreKind == other.PreKind {
+		if c := compareInt(v.PreNum, other.PreNum); c != 0 {
+			return c
+		}
+	}
+	if c := compareInt(v.Post, other.Post); c != 0 {
+		return c
+	}
+	if c := compareInt(devRank(v.Dev), devRank(other.Dev)); c != 0 {
+		return c
+	}
+	return compareLocal(v.Local, other.Local)
+}
+
+// preRank orders dev-only releases before alphas, betas, rcs and finals
+func (v *Version) preRank() int {
+	switch v.PreKind {
+	case "a":
+		return 1
+	case "b":
+		return 2
+	case "rc":
+		return 3
+	}
+	if v.Dev >= 0 && v.Post < 0 {
+		return 0
+	}
+	return 4
+}
+
+// devRank makes a missing dev segment sort after any dev release
+func devRank(dev int) int {
+	if dev < 0 {
+		return int(^uint(0) >> 1)
+	}
+	return dev
+}
+
+// compareLocal compares local labels segment by segment as PEP 440 describes
+func compareLocal(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return -1
+	}
+	if b == "" {
+		return 1
+	}
+	as, bs := strings.FieldsFunc(a, isLocalSep), strings.FieldsFunc(b, isLocalSep)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if c := compareInt(an, bn); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			return 1
+		case bErr == nil:
+			return -1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(len(as), len(bs))
+}
+
+func isLocalSep(r rune) bool {
+	return r == '.' || r == '-' || r == '_'
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Compare parses and compares two version strings, falling back to string
+// comparison when either side is not a valid PEP 440 version
+func Compare(a, b string) int {
+	va, errA := Parse(a)
+	vb, errB := Parse(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return va.Compare(vb)
+}
+
+// IsPrerelease reports whether a version string denotes a pre-release
+func IsPrerelease(s string) bool {
+	v, err := Parse(s)
+	return err == nil && v.IsPrerelease()
+}
+
+// Sort sorts version strings in ascending PEP 440 order
+func Sort(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return Compare(versions[i], versions[j]) < 0
+	})
+}
@@ -0,0 +1,65 @@
+package version
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAndString(t *testing.T) {
+	tests := map[string]string{
+		"1.0":              "1.0",
+		"v2.31.0":          "2.31.0",
+		"1.0.0-alpha.1":    "1.0.0a1",
+		"1.0RC2":           "1.0rc2",
+		"2!1.0.post3":      "2!1.0.post3",
+		"1.0.dev4":         "1.0.dev4",
+		"1.0+ubuntu.1":     "1.0+ubuntu.1",
+		"1.0-preview-1":    "1.0rc1",
+		"1.2.3.post1.dev2": "1.2.3.post1.dev2",
+	}
+	for in, want := range tests {
+		v, err := Parse(in)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", in, err)
+			continue
+		}
+		if v.String() != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", in, v.String(), want)
+		}
+	}
+	for _, bad := range []string{"", "abc", "1.0+", "1.0.zzz"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Expected error parsing %q", bad)
+		}
+	}
+}
+
+func TestCompareOrdering(t *testing.T) {
+	ordered := []string{
+		"1.0.dev1", "1.0a1", "1.0a2.dev1", "1.0a2", "1.0b1", "1.0rc1", "1.0",
+		"1.0+local", "1.0.post1.dev1", "1.0.post1", "1.1", "1.10", "2!0.1",
+	}
+	for i := 0; i < len(ordered)-1; i++ {
+		if Compare(ordered[i], ordered[i+1]) >= 0 {
+			t.Errorf("Expected %s < %s", ordered[i], ordered[i+1])
+		}
+	}
+	if Compare("1.0", "1.0.0") != 0 {
+		t.Error("Trailing zeros should compare equal")
+	}
+}
+
+func TestSortAndPrerelease(t *testing.T) {
+	versions := []string{"2.0.0", "1.10.0", "1.2.0", "2.0.0rc1"}
+	Sort(versions)
+	want := []string{"1.2.0", "1.10.0", "2.0.0rc1", "2.0.0"}
+	if !reflect.DeepEqual(versions, want) {
+		t.Errorf("Sort = %v, want %v", versions, want)
+	}
+	if !IsPrerelease("2.0.0rc1") || IsPrerelease("2.0.0") || !IsPrerelease("1.0.dev0") {
+		t.Error("IsPrerelease mismatch")
+	}
+	if MustParse("1.2.3").Minor() != 2 || MustParse("1").Micro() != 0 {
+		t.Error("Component accessors mismatch")
+	}
+}
@@ -0,0 +1,98 @@
+// Package watch polls project files for changes, so zephyr can react to
+// edits without a platform-specific file notification API.
+package watch
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultInterval is how often watched files are checked
+	DefaultInterval = 300 * time.Millisecond
+	// DefaultDebounce is how long files must stay unchanged before a burst
+	// of edits, such as an editor's save sequence, is reported
+	DefaultDebounce = 500 * time.Millisecond
+)
+
+// Watcher reports changes to a fixed set of files
+type Watcher struct {
+	Paths    []string
+	Interval time.Duration
+	Debounce time.Duration
+}
+
+// New returns a watcher for paths with the default interval and debounce
+func New(paths ...string) *Watcher {
+	return &Watcher{Paths: paths, Interval: DefaultInterval, Debounce: DefaultDebounce}
+}
+
+// state identifies a file's content; a missing file has the zero state
+type state struct {
+	exists bool
+	digest [sha256.Size]byte
+}
+
+// snapshot reads the current state of every watched path. Contents are
+// compared rather than modification times, so saves that rewrite identical
+// bytes do not count as changes.
+func (w *Watcher) snapshot() map[string]state {
+	states := make(map[string]state, len(w.Paths))
+	for _, path := range w.Paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			states[path] = state{}
+			continue
+		}
+		states[path] = state{exists: true, digest: sha256.Sum256(data)}
+	}
+	return states
+}
+
+// Run polls until ctx is done. Once a change has been followed by Debounce
+// without further changes, it calls onChange with the paths that changed,
+// sorted. onChange runs on the polling goroutine, so edits made while it
+// runs are reported by the next call.
+func (w *Watcher) Run(ctx context.Context, onChange func(changed []string)) error {
+	interval, debounce := w.Interval, w.Debounce
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if debounce < 0 {
+		debounce = 0
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	previous := w.snapshot()
+	pending := make(map[string]bool)
+	var lastChange time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		current := w.snapshot()
+		for path, s := range current {
+			if previous[path] != s {
+				pending[path] = true
+				lastChange = time.Now()
+			}
+		}
+		previous = current
+		if len(pending) == 0 || time.Since(lastChange) < debounce {
+			continue
+		}
+		changed := make([]string, 0, len(pending))
+		for path := range pending {
+			changed = append(changed, path)
+		}
+		sort.Strings(changed)
+		pending = make(map[string]bool)
+		onChange(changed)
+	}
+}
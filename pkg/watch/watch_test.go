@@ -0,0 +1,65 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunDebouncesChanges(t *testing.T) {
+	dir := t.TempDir()
+	buildmeta := filepath.Join(dir, "buildmeta.yaml")
+	pyproject := filepath.Join(dir, "pyproject.toml")
+	os.WriteFile(buildmeta, []byte("name: demo\n"), 0644)
+
+	w := &Watcher{Paths: []string{buildmeta, pyproject}, Interval: 10 * time.Millisecond, Debounce: 100 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := make(chan []string, 10)
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx, func(changed []string) { calls <- changed })
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+	// Rewriting identical content is not a change
+	os.WriteFile(buildmeta, []byte("name: demo\n"), 0644)
+	// A burst of edits across both files is reported once
+	for i := 0; i < 3; i++ {
+		os.WriteFile(buildmeta, []byte("name: demo\ndependencies:\n  requests: \">=2."+string(rune('0'+i))+"\"\n"), 0644)
+		time.Sleep(20 * time.Millisecond)
+	}
+	os.WriteFile(pyproject, []byte("[project]\n"), 0644)
+
+	select {
+	case changed := <-calls:
+		if !reflect.DeepEqual(changed, []string{buildmeta, pyproject}) {
+			t.Errorf("changed = %v", changed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change reported")
+	}
+	select {
+	case changed := <-calls:
+		t.Errorf("burst reported more than once, extra call with %v", changed)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	os.Remove(pyproject)
+	select {
+	case changed := <-calls:
+		if !reflect.DeepEqual(changed, []string{pyproject}) {
+			t.Errorf("changed = %v after removal", changed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("removal not reported")
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run returned %v after cancel", err)
+	}
+}